@@ -56,6 +56,12 @@ func main() {
 	// Create application container
 	container := app.NewContainer(cfg, database)
 
+	// Start background jobs; every replica runs the scheduler and advisory
+	// locks pick the one that executes each run
+	if cfg.Scheduler.Enabled {
+		container.Scheduler.Start(context.Background())
+	}
+
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%s", cfg.Server.Port),
@@ -79,6 +85,9 @@ func main() {
 
 	log.Println("Shutting down server...")
 
+	// Stop scheduling new job runs and wait for running ones
+	container.Scheduler.Stop()
+
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -1,14 +1,23 @@
 package app
 
 import (
+	"context"
+	"log"
+	"net"
+
 	"gebase/internal/auth"
 	"gebase/internal/config"
+	"gebase/internal/geoip"
 	"gebase/internal/http/handlers"
 	"gebase/internal/http/router"
+	"gebase/internal/mail"
+	"gebase/internal/push"
 	"gebase/internal/middleware"
 	"gebase/internal/repository"
+	"gebase/internal/scheduler"
 	"gebase/internal/service"
 
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
@@ -35,12 +44,68 @@ type Container struct {
 	DeviceRepo            *repository.DeviceRepository
 	SessionRepo           *repository.SessionRepository
 	SessionHistoryRepo    *repository.SessionSystemHistoryRepository
+	RefreshTokenRepo      *repository.RefreshTokenRepository
+	SigningKeyRepo        *repository.SigningKeyRepository
+	RevokedTokenRepo      *repository.RevokedTokenRepository
+	OrgSecurityPolicyRepo *repository.OrganizationSecurityPolicyRepository
+	UserMFARepo           *repository.UserMFARepository
+	MFARecoveryCodeRepo   *repository.MFARecoveryCodeRepository
+	LoginAttemptRepo      *repository.LoginAttemptRepository
+	LoginLockoutRepo      *repository.LoginLockoutRepository
+	PasswordHistoryRepo   *repository.PasswordHistoryRepository
+	PasswordResetTokenRepo *repository.PasswordResetTokenRepository
+	PasswordResetRequestRepo *repository.PasswordResetRequestRepository
+	OIDCLoginStateRepo    *repository.OIDCLoginStateRepository
+	OAuthClientRepo       *repository.OAuthClientRepository
+	OAuthConsentRepo      *repository.OAuthConsentRepository
+	OAuthCodeRepo         *repository.OAuthAuthorizationCodeRepository
+	LDAPConfigRepo        *repository.OrganizationLDAPConfigRepository
+	LDAPGroupMappingRepo  *repository.LDAPGroupRoleMappingRepository
+	SessionLimitRepo      *repository.SessionLimitPolicyRepository
+	SessionTimeoutRepo    *repository.SessionTimeoutPolicyRepository
+	JobRunRepo            *repository.JobRunRepository
+	ImpersonationRepo     *repository.ImpersonationEventRepository
+	AccessTokenRepo       *repository.PersonalAccessTokenRepository
+	DeviceEnrollmentRepo  *repository.DeviceEnrollmentPolicyRepository
+	DeviceProofNonceRepo  *repository.DeviceProofNonceRepository
+	DeviceCommandRepo     *repository.DeviceCommandRepository
+	DeviceConfigProfileRepo *repository.DeviceConfigProfileRepository
+	DeviceConfigVersionRepo *repository.DeviceConfigVersionRepository
+	DeviceConfigSchemaRepo  *repository.DeviceConfigSchemaRepository
+	DevicePresenceEventRepo  *repository.DevicePresenceEventRepository
+	DevicePresencePolicyRepo *repository.DevicePresencePolicyRepository
+	DeviceSilenceAlertRepo   *repository.DeviceSilenceAlertRepository
+	PushTemplateRepo         *repository.PushTemplateRepository
+	PushNotificationRepo     *repository.PushNotificationRepository
+	PushDeliveryRepo         *repository.PushDeliveryRepository
+	LoginRiskPolicyRepo      *repository.LoginRiskPolicyRepository
+	LoginRiskEventRepo       *repository.LoginRiskEventRepository
 	LanguageRepo          *repository.LanguageRepository
 	TranslationRepo       *repository.TranslationRepository
 
 	// Auth
-	JWTService     *auth.JWTService
-	SessionService *auth.SessionService
+	SecretBox         *auth.SecretBox
+	KeyRing           *auth.KeyRing
+	JWTService        *auth.JWTService
+	SessionService    *auth.SessionService
+	RevocationService *auth.RevocationService
+	OIDCClient        *auth.OIDCClient
+	DeviceProofVerifier *auth.DeviceProofVerifier
+
+	// Mail
+	MailSender mail.Sender
+
+	// Push notifications
+	PushSender *push.Sender
+
+	// Login location lookups; nil without a GeoIP database
+	GeoIP *geoip.Reader
+
+	// Redis connection; nil unless the permission cache uses it
+	Redis *redis.Client
+
+	// Permission sets of users; nil when caching is off
+	PermissionCache *service.PermissionCache
 
 	// Services
 	AuthService       *service.AuthService
@@ -51,6 +116,29 @@ type Container struct {
 	PermissionService *service.PermissionService
 	MenuService       *service.MenuService
 	DeviceService     *service.DeviceService
+	DeviceEnrollmentService *service.DeviceEnrollmentService
+	DeviceCommandService    *service.DeviceCommandService
+	DeviceConfigService     *service.DeviceConfigService
+	DevicePresenceService   *service.DevicePresenceService
+	PushService             *service.PushService
+	LoginRiskService        *service.LoginRiskService
+	MFAService        *service.MFAService
+	LoginProtectionService *service.LoginProtectionService
+	PasswordPolicyService  *service.PasswordPolicyService
+	PasswordResetService   *service.PasswordResetService
+	OIDCService            *service.OIDCService
+	OAuthService           *service.OAuthService
+	LDAPService            *service.LDAPService
+	SessionLimitService    *service.SessionLimitService
+	SessionTimeoutService  *service.SessionTimeoutService
+	MaintenanceService     *service.MaintenanceService
+	ImpersonationService   *service.ImpersonationService
+	AccessTokenService     *service.AccessTokenService
+	ServiceAccountService  *service.ServiceAccountService
+	Authenticators         *service.Authenticators
+
+	// Background jobs
+	Scheduler *scheduler.Scheduler
 
 	// Middleware
 	AuthMiddleware   *middleware.AuthMiddleware
@@ -58,13 +146,30 @@ type Container struct {
 	DeviceMiddleware *middleware.DeviceMiddleware
 
 	// Handlers
-	AuthHandler   *handlers.AuthHandler
-	UserHandler   *handlers.UserHandler
-	OrgHandler    *handlers.OrganizationHandler
-	SystemHandler *handlers.SystemHandler
-	RoleHandler   *handlers.RoleHandler
-	MenuHandler   *handlers.MenuHandler
-	DeviceHandler *handlers.DeviceHandler
+	AuthHandler           *handlers.AuthHandler
+	UserHandler           *handlers.UserHandler
+	OrgHandler            *handlers.OrganizationHandler
+	SystemHandler         *handlers.SystemHandler
+	RoleHandler           *handlers.RoleHandler
+	MenuHandler           *handlers.MenuHandler
+	DeviceHandler         *handlers.DeviceHandler
+	DeviceEnrollmentHandler *handlers.DeviceEnrollmentHandler
+	DeviceCommandHandler    *handlers.DeviceCommandHandler
+	DeviceConfigHandler     *handlers.DeviceConfigHandler
+	DevicePresenceHandler   *handlers.DevicePresenceHandler
+	PushHandler             *handlers.PushHandler
+	LoginRiskHandler        *handlers.LoginRiskHandler
+	PermissionHandler       *handlers.PermissionHandler
+	KeyHandler            *handlers.KeyHandler
+	MFAHandler            *handlers.MFAHandler
+	OIDCHandler           *handlers.OIDCHandler
+	OAuthHandler          *handlers.OAuthHandler
+	LDAPHandler           *handlers.LDAPHandler
+	SessionHandler        *handlers.SessionHandler
+	JobHandler            *handlers.JobHandler
+	ImpersonationHandler  *handlers.ImpersonationHandler
+	AccessTokenHandler    *handlers.AccessTokenHandler
+	ServiceAccountHandler *handlers.ServiceAccountHandler
 
 	// Router
 	Router *router.Router
@@ -80,6 +185,7 @@ func NewContainer(cfg *config.Config, db *gorm.DB) *Container {
 	c.initRepositories()
 	c.initAuth()
 	c.initServices()
+	c.initScheduler()
 	c.initMiddleware()
 	c.initHandlers()
 	c.initRouter()
@@ -105,16 +211,100 @@ func (c *Container) initRepositories() {
 	c.DeviceRepo = repository.NewDeviceRepository(c.DB)
 	c.SessionRepo = repository.NewSessionRepository(c.DB)
 	c.SessionHistoryRepo = repository.NewSessionSystemHistoryRepository(c.DB)
+	c.RefreshTokenRepo = repository.NewRefreshTokenRepository(c.DB)
+	c.SigningKeyRepo = repository.NewSigningKeyRepository(c.DB)
+	c.RevokedTokenRepo = repository.NewRevokedTokenRepository(c.DB)
+	c.OrgSecurityPolicyRepo = repository.NewOrganizationSecurityPolicyRepository(c.DB)
+	c.UserMFARepo = repository.NewUserMFARepository(c.DB)
+	c.MFARecoveryCodeRepo = repository.NewMFARecoveryCodeRepository(c.DB)
+	c.LoginAttemptRepo = repository.NewLoginAttemptRepository(c.DB)
+	c.LoginLockoutRepo = repository.NewLoginLockoutRepository(c.DB)
+	c.PasswordHistoryRepo = repository.NewPasswordHistoryRepository(c.DB)
+	c.PasswordResetTokenRepo = repository.NewPasswordResetTokenRepository(c.DB)
+	c.PasswordResetRequestRepo = repository.NewPasswordResetRequestRepository(c.DB)
+	c.OIDCLoginStateRepo = repository.NewOIDCLoginStateRepository(c.DB)
+	c.OAuthClientRepo = repository.NewOAuthClientRepository(c.DB)
+	c.OAuthConsentRepo = repository.NewOAuthConsentRepository(c.DB)
+	c.OAuthCodeRepo = repository.NewOAuthAuthorizationCodeRepository(c.DB)
+	c.LDAPConfigRepo = repository.NewOrganizationLDAPConfigRepository(c.DB)
+	c.LDAPGroupMappingRepo = repository.NewLDAPGroupRoleMappingRepository(c.DB)
+	c.SessionLimitRepo = repository.NewSessionLimitPolicyRepository(c.DB)
+	c.SessionTimeoutRepo = repository.NewSessionTimeoutPolicyRepository(c.DB)
+	c.JobRunRepo = repository.NewJobRunRepository(c.DB)
+	c.ImpersonationRepo = repository.NewImpersonationEventRepository(c.DB)
+	c.AccessTokenRepo = repository.NewPersonalAccessTokenRepository(c.DB)
+	c.DeviceEnrollmentRepo = repository.NewDeviceEnrollmentPolicyRepository(c.DB)
+	c.DeviceProofNonceRepo = repository.NewDeviceProofNonceRepository(c.DB)
+	c.DeviceCommandRepo = repository.NewDeviceCommandRepository(c.DB)
+	c.DeviceConfigProfileRepo = repository.NewDeviceConfigProfileRepository(c.DB)
+	c.DeviceConfigVersionRepo = repository.NewDeviceConfigVersionRepository(c.DB)
+	c.DeviceConfigSchemaRepo = repository.NewDeviceConfigSchemaRepository(c.DB)
+	c.DevicePresenceEventRepo = repository.NewDevicePresenceEventRepository(c.DB)
+	c.DevicePresencePolicyRepo = repository.NewDevicePresencePolicyRepository(c.DB)
+	c.DeviceSilenceAlertRepo = repository.NewDeviceSilenceAlertRepository(c.DB)
+	c.PushTemplateRepo = repository.NewPushTemplateRepository(c.DB)
+	c.PushNotificationRepo = repository.NewPushNotificationRepository(c.DB)
+	c.PushDeliveryRepo = repository.NewPushDeliveryRepository(c.DB)
+	c.LoginRiskPolicyRepo = repository.NewLoginRiskPolicyRepository(c.DB)
+	c.LoginRiskEventRepo = repository.NewLoginRiskEventRepository(c.DB)
 	c.LanguageRepo = repository.NewLanguageRepository(c.DB)
 	c.TranslationRepo = repository.NewTranslationRepository(c.DB)
 }
 
 func (c *Container) initAuth() {
-	c.JWTService = auth.NewJWTService(c.Config)
-	c.SessionService = auth.NewSessionService(c.Config, c.SessionRepo, c.SessionHistoryRepo)
+	c.SecretBox = auth.NewSecretBox(c.Config)
+	c.KeyRing = auth.NewKeyRing(c.Config, c.SigningKeyRepo, c.SecretBox)
+	// Make sure an active signing key exists before the first request
+	if err := c.KeyRing.Rotate(context.Background(), false); err != nil {
+		log.Printf("Warning: failed to initialize signing keys: %v", err)
+	}
+
+	c.JWTService = auth.NewJWTService(c.Config, c.KeyRing)
+	c.SessionService = auth.NewSessionService(c.Config, c.SessionRepo, c.SessionHistoryRepo, c.RefreshTokenRepo, c.DeviceRepo, c.UserSystemRoleRepo, c.SessionLimitRepo, c.SessionTimeoutRepo)
+	c.RevocationService = auth.NewRevocationService(c.Config, c.RevokedTokenRepo, c.SessionRepo, c.UserSystemRoleRepo)
+	c.OIDCClient = auth.NewOIDCClient(c.Config)
+	c.DeviceProofVerifier = auth.NewDeviceProofVerifier(c.Config, c.DeviceProofNonceRepo)
+	c.MailSender = mail.NewSender(c.Config)
+	c.PushSender = push.NewSender(c.Config)
+
+	if path := c.Config.LoginRisk.GeoIPDatabase; path != "" {
+		reader, err := geoip.Open(path)
+		if err != nil {
+			log.Printf("Warning: failed to open GeoIP database, login locations are unavailable: %v", err)
+		} else {
+			c.GeoIP = reader
+		}
+	}
+
+	if c.Config.PermissionCache.Backend == config.PermissionCacheRedis {
+		c.Redis = redis.NewClient(&redis.Options{
+			Addr:     net.JoinHostPort(c.Config.Redis.Host, c.Config.Redis.Port),
+			Password: c.Config.Redis.Password,
+			DB:       c.Config.Redis.DB,
+		})
+		if err := c.Redis.Ping(context.Background()).Err(); err != nil {
+			log.Printf("Warning: failed to reach Redis, permissions are checked against the database until it is up: %v", err)
+		}
+	}
+	permissionCache, err := service.NewPermissionCache(c.Config.PermissionCache, c.Redis)
+	if err != nil {
+		log.Printf("Warning: permission cache is disabled: %v", err)
+	}
+	c.PermissionCache = permissionCache
+	go c.PermissionCache.Listen(context.Background())
 }
 
 func (c *Container) initServices() {
+	c.MFAService = service.NewMFAService(c.Config, c.UserRepo, c.UserMFARepo, c.MFARecoveryCodeRepo, c.OrgSecurityPolicyRepo, c.SecretBox)
+	c.LoginProtectionService = service.NewLoginProtectionService(c.Config, c.LoginAttemptRepo, c.LoginLockoutRepo)
+	c.PasswordPolicyService = service.NewPasswordPolicyService(c.Config, c.OrgSecurityPolicyRepo, c.PasswordHistoryRepo)
+	c.LDAPService = service.NewLDAPService(c.Config, c.LDAPConfigRepo, c.LDAPGroupMappingRepo, c.OrganizationRepo, c.RoleRepo, c.UserSystemRoleRepo, c.RevocationService, c.SecretBox, c.PermissionCache)
+	c.Authenticators = service.NewAuthenticators(c.OrgSecurityPolicyRepo, c.LDAPService)
+	c.SessionLimitService = service.NewSessionLimitService(c.SessionLimitRepo, c.OrganizationRepo, c.RoleRepo)
+	c.SessionTimeoutService = service.NewSessionTimeoutService(c.SessionTimeoutRepo, c.OrganizationRepo)
+	c.PushService = service.NewPushService(c.Config, c.PushSender, c.PushTemplateRepo, c.PushNotificationRepo, c.PushDeliveryRepo, c.DeviceRepo, c.UserRepo, c.OrganizationRepo)
+	c.LoginRiskService = service.NewLoginRiskService(c.Config, c.LoginRiskPolicyRepo, c.LoginRiskEventRepo, c.SessionRepo, c.OrganizationRepo, c.UserRepo, c.GeoIP, c.MailSender, c.PushService)
+	c.DeviceEnrollmentService = service.NewDeviceEnrollmentService(c.Config, c.DeviceEnrollmentRepo, c.DeviceRepo, c.OrganizationRepo, c.UserRepo, c.SessionRepo, c.MailSender)
 	c.AuthService = service.NewAuthService(
 		c.UserRepo,
 		c.DeviceRepo,
@@ -125,30 +315,89 @@ func (c *Container) initServices() {
 		c.MenuRepo,
 		c.JWTService,
 		c.SessionService,
+		c.RevocationService,
+		c.MFAService,
+		c.LoginProtectionService,
+		c.PasswordPolicyService,
+		c.Authenticators,
+		c.LoginRiskService,
+		c.DeviceProofVerifier,
+		c.DeviceEnrollmentService,
 	)
-	c.UserService = service.NewUserService(c.UserRepo, c.UserSystemRoleRepo, c.SessionRepo)
-	c.OrganizationService = service.NewOrganizationService(c.OrganizationRepo, c.OrganizationTypeRepo, c.OrganizationSystemRepo)
+	c.OIDCService = service.NewOIDCService(c.Config, c.OIDCClient, c.OIDCLoginStateRepo, c.UserRepo, c.OrganizationRepo, c.DeviceRepo, c.LoginProtectionService, c.AuthService)
+	c.OAuthService = service.NewOAuthService(c.Config, c.OAuthClientRepo, c.OAuthConsentRepo, c.OAuthCodeRepo, c.UserRepo, c.DeviceRepo, c.JWTService, c.SessionService, c.DeviceProofVerifier)
+	c.UserService = service.NewUserService(c.UserRepo, c.UserSystemRoleRepo, c.SessionRepo, c.RevocationService, c.PasswordPolicyService, c.Authenticators, c.PermissionCache)
+	c.PasswordResetService = service.NewPasswordResetService(c.Config, c.UserRepo, c.PasswordResetTokenRepo, c.PasswordResetRequestRepo, c.UserService, c.SessionService, c.MailSender)
+	c.OrganizationService = service.NewOrganizationService(c.OrganizationRepo, c.OrganizationTypeRepo, c.OrganizationSystemRepo, c.OrgSecurityPolicyRepo, c.LDAPConfigRepo)
 	c.SystemService = service.NewSystemService(c.SystemRepo, c.ModuleRepo, c.MenuRepo)
-	c.RoleService = service.NewRoleService(c.RoleRepo, c.RolePermissionRepo, c.RoleMenuRepo)
-	c.PermissionService = service.NewPermissionService(c.PermissionRepo, c.ModuleRepo, c.ActionRepo, c.SystemRepo)
+	c.RoleService = service.NewRoleService(c.RoleRepo, c.RolePermissionRepo, c.RoleMenuRepo, c.RevocationService, c.PermissionCache)
+	c.PermissionService = service.NewPermissionService(c.PermissionRepo, c.ModuleRepo, c.ActionRepo, c.SystemRepo, c.PermissionCache)
 	c.MenuService = service.NewMenuService(c.MenuRepo)
-	c.DeviceService = service.NewDeviceService(c.DeviceRepo, c.SessionRepo)
+	c.DeviceCommandService = service.NewDeviceCommandService(c.Config, c.DeviceCommandRepo, c.DeviceRepo, c.SessionService)
+	c.DeviceConfigService = service.NewDeviceConfigService(c.DeviceConfigProfileRepo, c.DeviceConfigVersionRepo, c.DeviceConfigSchemaRepo, c.DeviceRepo, c.OrganizationRepo)
+	c.DevicePresenceService = service.NewDevicePresenceService(c.Config, c.DevicePresenceEventRepo, c.DevicePresencePolicyRepo, c.DeviceSilenceAlertRepo, c.DeviceRepo, c.OrganizationRepo, c.UserRepo, c.MailSender)
+	c.DeviceService = service.NewDeviceService(c.Config, c.DeviceRepo, c.SessionRepo, c.DeviceEnrollmentService, c.DeviceCommandService, c.DeviceConfigService, c.DevicePresenceService, c.DeviceProofVerifier)
+	c.ImpersonationService = service.NewImpersonationService(c.UserRepo, c.PermissionRepo, c.ImpersonationRepo, c.JWTService, c.SessionService)
+	c.AccessTokenService = service.NewAccessTokenService(c.Config, c.AccessTokenRepo, c.UserRepo, c.SystemRepo, c.PermissionRepo)
+	c.ServiceAccountService = service.NewServiceAccountService(c.UserRepo, c.AccessTokenService)
+	c.MaintenanceService = service.NewMaintenanceService(c.Config, c.SessionService, c.DeviceRepo, c.LoginAttemptRepo, c.PasswordResetTokenRepo, c.PasswordResetRequestRepo, c.OIDCLoginStateRepo, c.OAuthCodeRepo, c.RevokedTokenRepo, c.RefreshTokenRepo, c.DeviceProofNonceRepo, c.DeviceCommandRepo, c.DevicePresenceEventRepo, c.DeviceSilenceAlertRepo, c.PushNotificationRepo, c.LoginRiskEventRepo, c.JobRunRepo)
+}
+
+// initScheduler registers the built-in maintenance jobs; main starts the
+// scheduler once the server is up
+func (c *Container) initScheduler() {
+	c.Scheduler = scheduler.New(c.DB, c.JobRunRepo)
+
+	jobs := []struct {
+		name     string
+		schedule string
+		run      scheduler.JobFunc
+	}{
+		{"session_cleanup", c.Config.Scheduler.SessionCleanupSchedule, c.MaintenanceService.CleanupExpiredSessions},
+		{"stale_devices", c.Config.Scheduler.StaleDeviceSchedule, c.MaintenanceService.MarkStaleDevices},
+		{"device_command_expiry", c.Config.Scheduler.DeviceCommandSchedule, c.MaintenanceService.ExpireDeviceCommands},
+		{"device_presence", c.Config.Scheduler.DevicePresenceSchedule, c.DevicePresenceService.Sweep},
+		{"push_retry", c.Config.Scheduler.PushRetrySchedule, c.PushService.RetryPending},
+		{"log_retention", c.Config.Scheduler.LogRetentionSchedule, c.MaintenanceService.PurgeLogs},
+	}
+	for _, job := range jobs {
+		if err := c.Scheduler.Register(job.name, job.schedule, job.run); err != nil {
+			log.Printf("Warning: job %s not scheduled: %v", job.name, err)
+		}
+	}
 }
 
 func (c *Container) initMiddleware() {
-	c.AuthMiddleware = middleware.NewAuthMiddleware(c.JWTService, c.SessionService)
-	c.RBACMiddleware = middleware.NewRBACMiddleware(c.PermissionService)
+	c.AuthMiddleware = middleware.NewAuthMiddleware(c.JWTService, c.SessionService, c.RevocationService, c.AccessTokenService, c.DeviceService)
+	c.RBACMiddleware = middleware.NewRBACMiddleware(c.PermissionService, c.Config)
 	c.DeviceMiddleware = middleware.NewDeviceMiddleware(c.DeviceService)
 }
 
 func (c *Container) initHandlers() {
-	c.AuthHandler = handlers.NewAuthHandler(c.AuthService, c.PermissionService, c.MenuService)
-	c.UserHandler = handlers.NewUserHandler(c.UserService)
+	c.AuthHandler = handlers.NewAuthHandler(c.AuthService, c.PermissionService, c.MenuService, c.PasswordResetService, c.ImpersonationService)
+	c.UserHandler = handlers.NewUserHandler(c.UserService, c.LoginProtectionService)
 	c.OrgHandler = handlers.NewOrganizationHandler(c.OrganizationService)
 	c.SystemHandler = handlers.NewSystemHandler(c.SystemService)
 	c.RoleHandler = handlers.NewRoleHandler(c.RoleService)
 	c.MenuHandler = handlers.NewMenuHandler(c.MenuService, c.SystemService)
 	c.DeviceHandler = handlers.NewDeviceHandler(c.DeviceService)
+	c.DeviceEnrollmentHandler = handlers.NewDeviceEnrollmentHandler(c.DeviceEnrollmentService)
+	c.DeviceCommandHandler = handlers.NewDeviceCommandHandler(c.DeviceCommandService, c.DeviceService)
+	c.DeviceConfigHandler = handlers.NewDeviceConfigHandler(c.DeviceConfigService, c.DeviceService)
+	c.DevicePresenceHandler = handlers.NewDevicePresenceHandler(c.DevicePresenceService)
+	c.PushHandler = handlers.NewPushHandler(c.PushService)
+	c.LoginRiskHandler = handlers.NewLoginRiskHandler(c.LoginRiskService)
+	c.PermissionHandler = handlers.NewPermissionHandler(c.PermissionService)
+	c.KeyHandler = handlers.NewKeyHandler(c.KeyRing)
+	c.MFAHandler = handlers.NewMFAHandler(c.MFAService)
+	c.OIDCHandler = handlers.NewOIDCHandler(c.OIDCService)
+	c.OAuthHandler = handlers.NewOAuthHandler(c.OAuthService, c.Config.OAuth.ConsentURL)
+	c.LDAPHandler = handlers.NewLDAPHandler(c.LDAPService)
+	c.SessionHandler = handlers.NewSessionHandler(c.AuthService, c.SessionLimitService, c.SessionTimeoutService)
+	c.JobHandler = handlers.NewJobHandler(c.Scheduler, c.MaintenanceService)
+	c.ImpersonationHandler = handlers.NewImpersonationHandler(c.ImpersonationService)
+	c.AccessTokenHandler = handlers.NewAccessTokenHandler(c.AccessTokenService)
+	c.ServiceAccountHandler = handlers.NewServiceAccountHandler(c.ServiceAccountService)
 }
 
 func (c *Container) initRouter() {
@@ -164,5 +413,22 @@ func (c *Container) initRouter() {
 		c.SystemHandler,
 		c.RoleHandler,
 		c.MenuHandler,
+		c.KeyHandler,
+		c.MFAHandler,
+		c.OIDCHandler,
+		c.OAuthHandler,
+		c.LDAPHandler,
+		c.SessionHandler,
+		c.JobHandler,
+		c.ImpersonationHandler,
+		c.AccessTokenHandler,
+		c.ServiceAccountHandler,
+		c.DeviceEnrollmentHandler,
+		c.DeviceCommandHandler,
+		c.DeviceConfigHandler,
+		c.DevicePresenceHandler,
+		c.PushHandler,
+		c.LoginRiskHandler,
+		c.PermissionHandler,
 	)
 }
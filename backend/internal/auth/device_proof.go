@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidDeviceKey    = errors.New("device key must be an EC P-256, Ed25519 or RSA (2048 bit or more) public JWK")
+	ErrInvalidDeviceProof  = errors.New("invalid device proof")
+	ErrDeviceProofReplayed = errors.New("device proof has already been used")
+	ErrDeviceProofRequired = errors.New("device proof required")
+	ErrDeviceKeyMismatch   = errors.New("token is bound to a different device key")
+	ErrDeviceKeyRequired   = errors.New("device must register a proof-of-possession key")
+	ErrDeviceMismatch      = errors.New("token was issued on a different device")
+)
+
+// DeviceProofType is the typ header of device proofs, as in DPoP (RFC 9449)
+const DeviceProofType = "dpop+jwt"
+
+// minRSAKeyBits is the smallest RSA modulus accepted as a device key
+const minRSAKeyBits = 2048
+
+// DeviceKey is a device's proof-of-possession public key
+type DeviceKey struct {
+	// JWK is the RFC 7638 canonical form of the key: only the required
+	// members, in lexicographic order
+	JWK        string
+	Algorithm  string
+	Thumbprint string
+	public     crypto.PublicKey
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// ParseDeviceKey parses a public JWK registered by a device. EC keys sign
+// with ES256, Ed25519 keys with EdDSA and RSA keys with RS256.
+func ParseDeviceKey(raw []byte) (*DeviceKey, error) {
+	var jwk jsonWebKey
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return nil, ErrInvalidDeviceKey
+	}
+
+	key := &DeviceKey{}
+	switch jwk.Kty {
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, ErrInvalidDeviceKey
+		}
+		x, errX := decodeKeyBytes(jwk.X, 32)
+		y, errY := decodeKeyBytes(jwk.Y, 32)
+		if errX != nil || errY != nil {
+			return nil, ErrInvalidDeviceKey
+		}
+		public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !public.Curve.IsOnCurve(public.X, public.Y) {
+			return nil, ErrInvalidDeviceKey
+		}
+		key.public = public
+		key.Algorithm = jwt.SigningMethodES256.Alg()
+		key.JWK = fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`, jwk.X, jwk.Y)
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, ErrInvalidDeviceKey
+		}
+		x, err := decodeKeyBytes(jwk.X, ed25519.PublicKeySize)
+		if err != nil {
+			return nil, ErrInvalidDeviceKey
+		}
+		key.public = ed25519.PublicKey(x)
+		key.Algorithm = jwt.SigningMethodEdDSA.Alg()
+		key.JWK = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, jwk.X)
+	case "RSA":
+		n, errN := decodeKeyBytes(jwk.N, 0)
+		e, errE := decodeKeyBytes(jwk.E, 0)
+		if errN != nil || errE != nil || len(e) > 4 {
+			return nil, ErrInvalidDeviceKey
+		}
+		public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if public.N.BitLen() < minRSAKeyBits || public.E < 3 {
+			return nil, ErrInvalidDeviceKey
+		}
+		key.public = public
+		key.Algorithm = jwt.SigningMethodRS256.Alg()
+		key.JWK = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, jwk.E, jwk.N)
+	default:
+		return nil, ErrInvalidDeviceKey
+	}
+
+	sum := sha256.Sum256([]byte(key.JWK))
+	key.Thumbprint = base64.RawURLEncoding.EncodeToString(sum[:])
+	return key, nil
+}
+
+// decodeKeyBytes decodes a base64url JWK member, checking its length when size is set
+func decodeKeyBytes(value string, size int) ([]byte, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(decoded) == 0 || (size > 0 && len(decoded) != size) {
+		return nil, ErrInvalidDeviceKey
+	}
+	return decoded, nil
+}
+
+// DeviceProofClaims are the claims of a device proof. The jti is a nonce
+// that may only be used once per key.
+type DeviceProofClaims struct {
+	jwt.RegisteredClaims
+	// HTM is the HTTP method of the request
+	HTM string `json:"htm"`
+	// HTU is the request URL; only its path is compared, as the host and
+	// scheme seen by the server depend on the proxies in front of it
+	HTU string `json:"htu"`
+	// ATH is the base64url SHA-256 hash of the access token sent with the
+	// request, required when there is one
+	ATH string `json:"ath,omitempty"`
+}
+
+// DeviceProofRequest is the request a device proof must cover
+type DeviceProofRequest struct {
+	Proof  string
+	Method string
+	Path   string
+	// AccessToken is the bearer token sent with the request, if any
+	AccessToken string
+}
+
+// DeviceProofVerifier checks the signed proofs devices attach to requests
+// to show they hold the private half of their registered key
+type DeviceProofVerifier struct {
+	config    *config.Config
+	nonceRepo *repository.DeviceProofNonceRepository
+}
+
+func NewDeviceProofVerifier(cfg *config.Config, nonceRepo *repository.DeviceProofNonceRepository) *DeviceProofVerifier {
+	return &DeviceProofVerifier{
+		config:    cfg,
+		nonceRepo: nonceRepo,
+	}
+}
+
+// Verify checks that req.Proof is signed with key, covers the request's
+// method, path and access token, is recent and has not been used before
+func (v *DeviceProofVerifier) Verify(ctx context.Context, key *DeviceKey, req *DeviceProofRequest) error {
+	if req.Proof == "" {
+		return ErrDeviceProofRequired
+	}
+
+	claims := &DeviceProofClaims{}
+	token, err := jwt.ParseWithClaims(req.Proof, claims,
+		func(*jwt.Token) (interface{}, error) { return key.public, nil },
+		jwt.WithValidMethods([]string{key.Algorithm}),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(v.config.Device.ProofMaxAge))
+	if err != nil || !token.Valid {
+		return ErrInvalidDeviceProof
+	}
+	if typ, _ := token.Header["typ"].(string); typ != DeviceProofType {
+		return ErrInvalidDeviceProof
+	}
+
+	if claims.ID == "" || len(claims.ID) > 64 || claims.IssuedAt == nil {
+		return ErrInvalidDeviceProof
+	}
+	if time.Since(claims.IssuedAt.Time) > v.config.Device.ProofMaxAge {
+		return ErrInvalidDeviceProof
+	}
+	if !strings.EqualFold(claims.HTM, req.Method) || proofPath(claims.HTU) != req.Path {
+		return ErrInvalidDeviceProof
+	}
+	if req.AccessToken != "" && claims.ATH != AccessTokenHash(req.AccessToken) {
+		return ErrInvalidDeviceProof
+	}
+
+	// A proof is accepted until ProofMaxAge after it was issued, in either direction
+	fresh, err := v.nonceRepo.Use(ctx, key.Thumbprint, claims.ID, claims.IssuedAt.Add(2*v.config.Device.ProofMaxAge))
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrDeviceProofReplayed
+	}
+	return nil
+}
+
+// VerifyRequest checks a request made from device: claims, if any, must have
+// been issued on the device and bound to its key, and the request must carry
+// a fresh proof signed with that key. Devices without a key pass unless
+// DEVICE_PROOF_REQUIRED is set. The proof of requests with device-bound
+// claims is checked by VerifyBinding when the token is authenticated, so
+// here they only have to match the device.
+func (v *DeviceProofVerifier) VerifyRequest(ctx context.Context, device *domain.Device, claims *Claims, req *DeviceProofRequest) error {
+	if claims != nil && claims.DeviceID != 0 && claims.DeviceID != device.ID {
+		return ErrDeviceMismatch
+	}
+	if claims != nil && claims.IsDeviceBound() {
+		if claims.Confirmation.JKT != device.KeyThumbprint {
+			return ErrDeviceKeyMismatch
+		}
+		return nil
+	}
+
+	if !device.HasKey() {
+		if v.config.Device.ProofRequired {
+			return ErrDeviceKeyRequired
+		}
+		return nil
+	}
+
+	key, err := ParseDeviceKey([]byte(device.PublicKeyJWK))
+	if err != nil {
+		return err
+	}
+	return v.Verify(ctx, key, req)
+}
+
+// VerifyBinding checks a request authenticated with device-bound claims
+// carries a fresh proof signed with the key they are bound to, which must
+// still be the key of device, the device they were issued on
+func (v *DeviceProofVerifier) VerifyBinding(ctx context.Context, device *domain.Device, claims *Claims, req *DeviceProofRequest) error {
+	if device.ID != claims.DeviceID || !device.HasKey() {
+		return ErrDeviceKeyMismatch
+	}
+	key, err := ParseDeviceKey([]byte(device.PublicKeyJWK))
+	if err != nil {
+		return err
+	}
+	if claims.Confirmation.JKT != key.Thumbprint {
+		return ErrDeviceKeyMismatch
+	}
+	return v.Verify(ctx, key, req)
+}
+
+// AccessTokenHash returns the ath value of a proof sent with accessToken
+func AccessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// proofPath returns the path of an htu claim, which may be a full URL or just a path
+func proofPath(htu string) string {
+	parsed, err := url.Parse(htu)
+	if err != nil {
+		return ""
+	}
+	return parsed.Path
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// testDeviceKey is an Ed25519 device key pair
+type testDeviceKey struct {
+	*DeviceKey
+	private ed25519.PrivateKey
+}
+
+func newTestDeviceKey(t *testing.T) *testDeviceKey {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	jwk := fmt.Sprintf(`{"kty":"OKP","crv":"Ed25519","x":"%s"}`, base64.RawURLEncoding.EncodeToString(public))
+	key, err := ParseDeviceKey([]byte(jwk))
+	if err != nil {
+		t.Fatalf("ParseDeviceKey: %v", err)
+	}
+	return &testDeviceKey{DeviceKey: key, private: private}
+}
+
+// proof signs a proof for POST /api/v1/devices/heartbeat with access token
+// "token-1", letting edit change it first
+func (k *testDeviceKey) proof(t *testing.T, edit func(*jwt.Token, *DeviceProofClaims)) string {
+	t.Helper()
+
+	claims := &DeviceProofClaims{
+		RegisteredClaims: jwt.RegisteredClaims{ID: uuid.New().String(), IssuedAt: jwt.NewNumericDate(time.Now())},
+		HTM:              "POST",
+		HTU:              "https://api.example.com/api/v1/devices/heartbeat",
+		ATH:              AccessTokenHash("token-1"),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["typ"] = DeviceProofType
+	if edit != nil {
+		edit(token, claims)
+	}
+	signed, err := token.SignedString(k.private)
+	if err != nil {
+		t.Fatalf("sign proof: %v", err)
+	}
+	return signed
+}
+
+func newTestDeviceProofVerifier(t *testing.T) *DeviceProofVerifier {
+	t.Helper()
+
+	db := newTestDB(t, &domain.DeviceProofNonce{})
+	cfg := &config.Config{Device: config.DeviceConfig{ProofMaxAge: time.Minute}}
+	return NewDeviceProofVerifier(cfg, repository.NewDeviceProofNonceRepository(db))
+}
+
+func TestDeviceProofVerify(t *testing.T) {
+	key := newTestDeviceKey(t)
+	other := newTestDeviceKey(t)
+	tests := []struct {
+		name    string
+		proof   string
+		wantErr error
+	}{
+		{"valid", key.proof(t, nil), nil},
+		{"missing", "", ErrDeviceProofRequired},
+		{"other key", other.proof(t, nil), ErrInvalidDeviceProof},
+		{"other method", key.proof(t, func(_ *jwt.Token, c *DeviceProofClaims) { c.HTM = "GET" }), ErrInvalidDeviceProof},
+		{"other path", key.proof(t, func(_ *jwt.Token, c *DeviceProofClaims) { c.HTU = "/api/v1/devices/register" }), ErrInvalidDeviceProof},
+		{"other access token", key.proof(t, func(_ *jwt.Token, c *DeviceProofClaims) { c.ATH = AccessTokenHash("token-2") }), ErrInvalidDeviceProof},
+		{"too old", key.proof(t, func(_ *jwt.Token, c *DeviceProofClaims) {
+			c.IssuedAt = jwt.NewNumericDate(time.Now().Add(-5 * time.Minute))
+		}), ErrInvalidDeviceProof},
+		{"no nonce", key.proof(t, func(_ *jwt.Token, c *DeviceProofClaims) { c.ID = "" }), ErrInvalidDeviceProof},
+		{"not a proof", key.proof(t, func(token *jwt.Token, _ *DeviceProofClaims) { token.Header["typ"] = "JWT" }), ErrInvalidDeviceProof},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newTestDeviceProofVerifier(t)
+			req := &DeviceProofRequest{Proof: tt.proof, Method: "POST", Path: "/api/v1/devices/heartbeat", AccessToken: "token-1"}
+			if err := v.Verify(context.Background(), key.DeviceKey, req); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeviceProofVerifyRejectsReplay(t *testing.T) {
+	v := newTestDeviceProofVerifier(t)
+	key := newTestDeviceKey(t)
+	req := &DeviceProofRequest{Proof: key.proof(t, nil), Method: "POST", Path: "/api/v1/devices/heartbeat", AccessToken: "token-1"}
+
+	if err := v.Verify(context.Background(), key.DeviceKey, req); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := v.Verify(context.Background(), key.DeviceKey, req); !errors.Is(err, ErrDeviceProofReplayed) {
+		t.Fatalf("replayed proof error = %v, want ErrDeviceProofReplayed", err)
+	}
+}
+
+func TestDeviceProofVerifyBinding(t *testing.T) {
+	key := newTestDeviceKey(t)
+	device := &domain.Device{ID: 1, PublicKeyJWK: key.JWK, KeyAlgorithm: key.Algorithm, KeyThumbprint: key.Thumbprint}
+	tests := []struct {
+		name    string
+		claims  *Claims
+		wantErr error
+	}{
+		{"bound to the device key", &Claims{DeviceID: 1, Confirmation: &Confirmation{JKT: key.Thumbprint}}, nil},
+		{"bound to a replaced key", &Claims{DeviceID: 1, Confirmation: &Confirmation{JKT: newTestDeviceKey(t).Thumbprint}}, ErrDeviceKeyMismatch},
+		{"issued on another device", &Claims{DeviceID: 2, Confirmation: &Confirmation{JKT: key.Thumbprint}}, ErrDeviceKeyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newTestDeviceProofVerifier(t)
+			req := &DeviceProofRequest{Proof: key.proof(t, nil), Method: "POST", Path: "/api/v1/devices/heartbeat", AccessToken: "token-1"}
+			if err := v.VerifyBinding(context.Background(), device, tt.claims, req); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyBinding error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
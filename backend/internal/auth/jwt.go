@@ -1,18 +1,23 @@
 package auth
 
 import (
+	"context"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 
 	"gebase/internal/config"
 	"gebase/internal/domain"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 var (
 	ErrInvalidToken = errors.New("invalid token")
 	ErrExpiredToken = errors.New("token has expired")
+	ErrTokenReused  = errors.New("refresh token has already been used")
 )
 
 type TokenType string
@@ -20,6 +25,17 @@ type TokenType string
 const (
 	TokenTypePlatform TokenType = "platform"
 	TokenTypeSystem   TokenType = "system"
+	TokenTypeRefresh  TokenType = "refresh"
+	// TokenTypeMFAChallenge is issued after a correct password when a second factor is still required
+	TokenTypeMFAChallenge TokenType = "mfa_challenge"
+	// TokenTypeClient is issued to OAuth clients through the client credentials grant
+	TokenTypeClient TokenType = "client"
+	// TokenTypeOAuth is issued to OAuth clients acting on behalf of a user; it
+	// is only accepted by routes mapped to the scopes it was granted
+	TokenTypeOAuth TokenType = "oauth"
+	// TokenTypePersonalAccess marks claims built from a personal access token;
+	// it is never signed
+	TokenTypePersonalAccess TokenType = "personal_access"
 )
 
 type Claims struct {
@@ -33,14 +49,46 @@ type Claims struct {
 	SystemID       *int      `json:"system_id,omitempty"`
 	SystemCode     string    `json:"system_code,omitempty"`
 	RoleIDs        []int     `json:"role_ids,omitempty"`
+	// PasswordChangeRequired limits the token to changing the password
+	PasswordChangeRequired bool `json:"password_change_required,omitempty"`
+	// ClientID and Scope are set on tokens issued by the OAuth authorization server
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	// ImpersonatorID is the administrator acting as UserID in an impersonation session
+	ImpersonatorID *int64 `json:"impersonator_id,omitempty"`
+	// Confirmation binds the token to the key of the device it was issued on;
+	// requests with it must carry a proof signed with that key
+	Confirmation *Confirmation `json:"cnf,omitempty"`
+}
+
+// Confirmation is the RFC 7800 confirmation claim of a device-bound token
+type Confirmation struct {
+	// JKT is the RFC 7638 thumbprint of the device key
+	JKT string `json:"jkt"`
+}
+
+// IDTokenClaims are the OpenID Connect ID token claims issued to OAuth clients
+type IDTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce          string `json:"nonce,omitempty"`
+	AuthTime       int64  `json:"auth_time,omitempty"`
+	Email          string `json:"email,omitempty"`
+	Name           string `json:"name,omitempty"`
+	GivenName      string `json:"given_name,omitempty"`
+	FamilyName     string `json:"family_name,omitempty"`
+	OrganizationID *int64 `json:"organization_id,omitempty"`
 }
 
 type JWTService struct {
-	config *config.Config
+	config  *config.Config
+	keyRing *KeyRing
 }
 
-func NewJWTService(cfg *config.Config) *JWTService {
-	return &JWTService{config: cfg}
+func NewJWTService(cfg *config.Config, keyRing *KeyRing) *JWTService {
+	return &JWTService{
+		config:  cfg,
+		keyRing: keyRing,
+	}
 }
 
 // GeneratePlatformToken creates a platform-level token (24h expiry)
@@ -48,30 +96,35 @@ func (s *JWTService) GeneratePlatformToken(user *domain.User, session *domain.Se
 	now := time.Now()
 	claims := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			Subject:   user.Email,
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.JWT.PlatformExpiry)),
 			Issuer:    "gebase",
 		},
-		UserID:         user.ID,
-		Email:          user.Email,
-		OrganizationID: user.OrganizationID,
-		SessionID:      session.ID,
-		DeviceID:       session.DeviceID,
-		TokenType:      TokenTypePlatform,
+		UserID:                 user.ID,
+		Email:                  user.Email,
+		OrganizationID:         user.OrganizationID,
+		SessionID:              session.ID,
+		DeviceID:               session.DeviceID,
+		TokenType:              TokenTypePlatform,
+		PasswordChangeRequired: user.MustChangePassword != nil && *user.MustChangePassword,
+		ImpersonatorID:         session.ImpersonatorID,
+		Confirmation:           sessionConfirmation(session),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.config.JWT.Secret))
+	return s.sign(claims)
 }
 
-// GenerateSystemToken creates a system-level token (8h expiry)
-func (s *JWTService) GenerateSystemToken(user *domain.User, session *domain.Session, system *domain.System, roleIDs []int) (string, error) {
+// GenerateSystemToken creates a system-level token (8h expiry). The returned
+// claims carry the token ID (jti) that is bound to the session for revocation.
+func (s *JWTService) GenerateSystemToken(user *domain.User, session *domain.Session, system *domain.System, roleIDs []int) (string, *Claims, error) {
 	now := time.Now()
 	systemExpiry := 8 * time.Hour // System token expires in 8 hours
 
 	claims := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			Subject:   user.Email,
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(systemExpiry)),
@@ -86,41 +139,222 @@ func (s *JWTService) GenerateSystemToken(user *domain.User, session *domain.Sess
 		SystemID:       &system.ID,
 		SystemCode:     system.Code,
 		RoleIDs:        roleIDs,
+		ImpersonatorID: session.ImpersonatorID,
+		Confirmation:   sessionConfirmation(session),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.config.JWT.Secret))
+	signed, err := s.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, &claims, nil
 }
 
-// GenerateRefreshToken creates a refresh token (7 days expiry)
-func (s *JWTService) GenerateRefreshToken(user *domain.User, session *domain.Session) (string, error) {
+// GenerateRefreshToken creates a refresh token (7 days expiry). The returned
+// claims carry the token ID (jti) that must be recorded for rotation.
+func (s *JWTService) GenerateRefreshToken(user *domain.User, session *domain.Session) (string, *Claims, error) {
 	now := time.Now()
 	claims := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			Subject:   user.Email,
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.JWT.RefreshExpiry)),
 			Issuer:    "gebase",
 		},
-		UserID:    user.ID,
-		Email:     user.Email,
-		SessionID: session.ID,
-		DeviceID:  session.DeviceID,
-		TokenType: TokenTypePlatform,
+		UserID:         user.ID,
+		Email:          user.Email,
+		SessionID:      session.ID,
+		DeviceID:       session.DeviceID,
+		TokenType:      TokenTypeRefresh,
+		ImpersonatorID: session.ImpersonatorID,
+		Confirmation:   sessionConfirmation(session),
+	}
+
+	signed, err := s.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, &claims, nil
+}
+
+// GenerateMFAChallengeToken creates a short-lived token that lets the client
+// complete a login with a second factor. It carries no session.
+func (s *JWTService) GenerateMFAChallengeToken(user *domain.User, device *domain.Device) (string, *Claims, error) {
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   user.Email,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.MFA.ChallengeExpiry)),
+			Issuer:    "gebase",
+		},
+		UserID:         user.ID,
+		Email:          user.Email,
+		OrganizationID: user.OrganizationID,
+		DeviceID:       device.ID,
+		TokenType:      TokenTypeMFAChallenge,
+	}
+
+	signed, err := s.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, &claims, nil
+}
+
+// GenerateOAuthAccessToken creates a token for an OAuth client acting on
+// behalf of a user. It is limited to the routes of its granted scopes.
+func (s *JWTService) GenerateOAuthAccessToken(user *domain.User, session *domain.Session, clientID, scope string) (string, *Claims, error) {
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   user.Email,
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.JWT.PlatformExpiry)),
+			Issuer:    "gebase",
+		},
+		UserID:                 user.ID,
+		Email:                  user.Email,
+		OrganizationID:         user.OrganizationID,
+		SessionID:              session.ID,
+		DeviceID:               session.DeviceID,
+		TokenType:              TokenTypeOAuth,
+		PasswordChangeRequired: user.MustChangePassword != nil && *user.MustChangePassword,
+		ClientID:               clientID,
+		Scope:                  scope,
+		Confirmation:           sessionConfirmation(session),
+	}
+
+	signed, err := s.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, &claims, nil
+}
+
+// GenerateOAuthRefreshToken creates a refresh token bound to an OAuth client
+func (s *JWTService) GenerateOAuthRefreshToken(user *domain.User, session *domain.Session, clientID, scope string) (string, *Claims, error) {
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   user.Email,
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.JWT.RefreshExpiry)),
+			Issuer:    "gebase",
+		},
+		UserID:       user.ID,
+		Email:        user.Email,
+		SessionID:    session.ID,
+		DeviceID:     session.DeviceID,
+		TokenType:    TokenTypeRefresh,
+		ClientID:     clientID,
+		Scope:        scope,
+		Confirmation: sessionConfirmation(session),
+	}
+
+	signed, err := s.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, &claims, nil
+}
+
+// GenerateClientToken creates a token for an OAuth client acting on its own
+// behalf (client credentials grant). It has no user or session.
+func (s *JWTService) GenerateClientToken(clientID, scope string) (string, *Claims, error) {
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   clientID,
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.OAuth.ClientCredentialExpiry)),
+			Issuer:    "gebase",
+		},
+		TokenType: TokenTypeClient,
+		ClientID:  clientID,
+		Scope:     scope,
+	}
+
+	signed, err := s.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, &claims, nil
+}
+
+// GenerateIDToken creates an OpenID Connect ID token for a client. Profile
+// and email claims are only included when the matching scope was granted.
+func (s *JWTService) GenerateIDToken(user *domain.User, clientID, nonce string, authTime time.Time, scopes []string) (string, error) {
+	now := time.Now()
+	claims := IDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.OAuth.IDTokenExpiry)),
+			Issuer:    s.config.OAuth.Issuer,
+		},
+		Nonce:    nonce,
+		AuthTime: authTime.Unix(),
+	}
+	for _, scope := range scopes {
+		switch scope {
+		case "email":
+			claims.Email = user.Email
+		case "profile":
+			claims.GivenName = user.FirstName
+			claims.FamilyName = user.LastName
+			claims.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+			claims.OrganizationID = user.OrganizationID
+		}
+	}
+
+	return s.sign(claims)
+}
+
+// sessionConfirmation binds tokens of a session to its device key, if the device has one
+func sessionConfirmation(session *domain.Session) *Confirmation {
+	if session.DeviceKeyThumbprint == "" {
+		return nil
 	}
+	return &Confirmation{JKT: session.DeviceKeyThumbprint}
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.config.JWT.Secret))
+// Algorithm returns the JWS algorithm tokens are signed with
+func (s *JWTService) Algorithm() string {
+	return s.keyRing.SigningMethod().Alg()
+}
+
+// sign signs claims with the current key ring key, or the shared secret in HS256 mode
+func (s *JWTService) sign(claims jwt.Claims) (string, error) {
+	if !s.keyRing.IsAsymmetric() {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(s.config.JWT.Secret))
+	}
+
+	kid, key, err := s.keyRing.Current(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(s.keyRing.SigningMethod(), claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
 }
 
 // ValidateToken validates and parses a JWT token
 func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return []byte(s.config.JWT.Secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.keyFunc,
+		jwt.WithValidMethods([]string{s.keyRing.SigningMethod().Alg()}))
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -137,6 +371,19 @@ func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
+// keyFunc resolves the verification key: the kid header for asymmetric tokens, the secret otherwise
+func (s *JWTService) keyFunc(token *jwt.Token) (interface{}, error) {
+	if !s.keyRing.IsAsymmetric() {
+		return []byte(s.config.JWT.Secret), nil
+	}
+
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, ErrInvalidToken
+	}
+	return s.keyRing.PublicKey(context.Background(), kid)
+}
+
 // IsPlatformToken checks if token is a platform token
 func (c *Claims) IsPlatformToken() bool {
 	return c.TokenType == TokenTypePlatform
@@ -147,7 +394,57 @@ func (c *Claims) IsSystemToken() bool {
 	return c.TokenType == TokenTypeSystem
 }
 
+// IsRefreshToken checks if token is a refresh token
+func (c *Claims) IsRefreshToken() bool {
+	return c.TokenType == TokenTypeRefresh
+}
+
+// IsMFAChallengeToken checks if token is an MFA challenge token
+func (c *Claims) IsMFAChallengeToken() bool {
+	return c.TokenType == TokenTypeMFAChallenge
+}
+
+// IsClientToken checks if token was issued to an OAuth client without a user
+func (c *Claims) IsClientToken() bool {
+	return c.TokenType == TokenTypeClient
+}
+
+// IsOAuthToken checks if token was issued to an OAuth client on behalf of a user
+func (c *Claims) IsOAuthToken() bool {
+	return c.TokenType == TokenTypeOAuth
+}
+
+// HasScope checks if scope was granted to the token
+func (c *Claims) HasScope(scope string) bool {
+	for _, granted := range strings.Fields(c.Scope) {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPersonalAccessToken checks if claims were built from a personal access token
+func (c *Claims) IsPersonalAccessToken() bool {
+	return c.TokenType == TokenTypePersonalAccess
+}
+
+// IsAccessToken checks if token may authenticate API requests
+func (c *Claims) IsAccessToken() bool {
+	return c.IsPlatformToken() || c.IsSystemToken()
+}
+
+// IsDeviceBound checks if token may only be used with proofs from its device key
+func (c *Claims) IsDeviceBound() bool {
+	return c.Confirmation != nil
+}
+
 // HasSystem checks if token has system context
 func (c *Claims) HasSystem() bool {
 	return c.SystemID != nil
 }
+
+// IsImpersonated checks if an administrator is acting as the user
+func (c *Claims) IsImpersonated() bool {
+	return c.ImpersonatorID != nil
+}
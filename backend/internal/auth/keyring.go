@@ -0,0 +1,355 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownKey           = errors.New("unknown signing key")
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+)
+
+// reloadCooldown limits how often an unknown kid triggers a reload from the database
+const reloadCooldown = 30 * time.Second
+
+type keyEntry struct {
+	record  domain.SigningKey
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// KeyRing holds the asymmetric keys used to sign and verify tokens. Keys are
+// persisted so every replica shares them; a new key is generated once the
+// current one is older than the rotation interval, and retired keys keep
+// verifying until the overlap window has passed.
+type KeyRing struct {
+	config    *config.Config
+	repo      *repository.SigningKeyRepository
+	algorithm domain.SigningAlgorithm
+	box       *SecretBox
+
+	rotateMu   sync.Mutex
+	mu         sync.RWMutex
+	keys       map[string]*keyEntry
+	current    *keyEntry
+	lastReload time.Time
+}
+
+func NewKeyRing(cfg *config.Config, repo *repository.SigningKeyRepository, box *SecretBox) *KeyRing {
+	return &KeyRing{
+		config:    cfg,
+		repo:      repo,
+		algorithm: domain.SigningAlgorithm(cfg.JWT.SigningAlgorithm),
+		box:       box,
+		keys:      make(map[string]*keyEntry),
+	}
+}
+
+// Algorithm returns the configured signing algorithm
+func (k *KeyRing) Algorithm() domain.SigningAlgorithm {
+	return k.algorithm
+}
+
+// IsAsymmetric reports whether tokens are signed with the key ring rather than the shared secret
+func (k *KeyRing) IsAsymmetric() bool {
+	return k.algorithm != domain.SigningAlgorithmHS256
+}
+
+// SigningMethod returns the jwt signing method for the configured algorithm
+func (k *KeyRing) SigningMethod() jwt.SigningMethod {
+	switch k.algorithm {
+	case domain.SigningAlgorithmRS256:
+		return jwt.SigningMethodRS256
+	case domain.SigningAlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+// Current returns the kid and private key that new tokens are signed with,
+// rotating first if the active key is due
+func (k *KeyRing) Current(ctx context.Context) (string, crypto.PrivateKey, error) {
+	k.mu.RLock()
+	current := k.current
+	k.mu.RUnlock()
+
+	if current == nil || k.isDue(current) {
+		if err := k.Rotate(ctx, false); err != nil {
+			return "", nil, err
+		}
+		k.mu.RLock()
+		current = k.current
+		k.mu.RUnlock()
+	}
+	if current == nil || current.private == nil {
+		return "", nil, ErrUnknownKey
+	}
+
+	return current.record.KID, current.private, nil
+}
+
+// PublicKey returns the verification key for a kid, reloading from the
+// database when the kid was minted by another replica
+func (k *KeyRing) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	k.mu.RLock()
+	entry, ok := k.keys[kid]
+	stale := time.Since(k.lastReload) > reloadCooldown
+	k.mu.RUnlock()
+
+	if !ok && stale {
+		if err := k.Reload(ctx); err != nil {
+			return nil, err
+		}
+		k.mu.RLock()
+		entry, ok = k.keys[kid]
+		k.mu.RUnlock()
+	}
+
+	if !ok || !k.isVerifiable(entry) {
+		return nil, ErrUnknownKey
+	}
+	return entry.public, nil
+}
+
+// Reload replaces the in-memory keys with the verifiable keys from the database
+func (k *KeyRing) Reload(ctx context.Context) error {
+	records, err := k.repo.FindVerifiable(ctx, k.algorithm)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*keyEntry, len(records))
+	var current *keyEntry
+	for _, record := range records {
+		entry, err := k.decode(record)
+		if err != nil {
+			log.Printf("Warning: skipping signing key %s: %v", record.KID, err)
+			continue
+		}
+		keys[record.KID] = entry
+		if current == nil && !record.IsRetired() {
+			current = entry
+		}
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.current = current
+	k.lastReload = time.Now()
+	k.mu.Unlock()
+
+	return nil
+}
+
+// Rotate generates a new active key and retires the previous one. Unless forced,
+// it first reloads and only rotates if no replica has already done so.
+func (k *KeyRing) Rotate(ctx context.Context, force bool) error {
+	if !k.IsAsymmetric() {
+		return nil
+	}
+
+	k.rotateMu.Lock()
+	defer k.rotateMu.Unlock()
+
+	if err := k.Reload(ctx); err != nil {
+		return err
+	}
+
+	k.mu.RLock()
+	previous := k.current
+	k.mu.RUnlock()
+
+	if !force && previous != nil && !k.isDue(previous) {
+		return nil
+	}
+
+	record, err := k.generate()
+	if err != nil {
+		return err
+	}
+	if err := k.repo.Create(ctx, record); err != nil {
+		return err
+	}
+
+	if previous != nil {
+		expiresAt := time.Now().Add(k.config.JWT.KeyOverlap)
+		if err := k.repo.Retire(ctx, previous.record.ID, expiresAt); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Rotated JWT signing key: %s (%s)", record.KID, record.Algorithm)
+	return k.Reload(ctx)
+}
+
+// JWKS returns the public keys that currently verify tokens as a JSON Web Key Set
+func (k *KeyRing) JWKS(ctx context.Context) (JSONWebKeySet, error) {
+	k.mu.RLock()
+	stale := time.Since(k.lastReload) > reloadCooldown
+	k.mu.RUnlock()
+
+	if stale && k.IsAsymmetric() {
+		if err := k.Reload(ctx); err != nil {
+			return JSONWebKeySet{}, err
+		}
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(k.keys))}
+	for _, entry := range k.keys {
+		if !k.isVerifiable(entry) {
+			continue
+		}
+		set.Keys = append(set.Keys, toJWK(entry))
+	}
+	return set, nil
+}
+
+func (k *KeyRing) isDue(entry *keyEntry) bool {
+	return time.Since(entry.record.ActivatedAt) >= k.config.JWT.KeyRotation
+}
+
+func (k *KeyRing) isVerifiable(entry *keyEntry) bool {
+	return entry.record.ExpiresAt == nil || time.Now().Before(*entry.record.ExpiresAt)
+}
+
+func (k *KeyRing) generate() (*domain.SigningKey, error) {
+	var private crypto.PrivateKey
+	var public crypto.PublicKey
+
+	switch k.algorithm {
+	case domain.SigningAlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		private, public = key, &key.PublicKey
+	case domain.SigningAlgorithmEdDSA:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private, public = priv, pub
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := k.box.Seal(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.SigningKey{
+		KID:           uuid.New().String(),
+		Algorithm:     k.algorithm,
+		PublicKeyPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		PrivateKeyEnc: encrypted,
+		ActivatedAt:   time.Now(),
+	}, nil
+}
+
+func (k *KeyRing) decode(record domain.SigningKey) (*keyEntry, error) {
+	publicBlock, _ := pem.Decode([]byte(record.PublicKeyPEM))
+	if publicBlock == nil {
+		return nil, errors.New("invalid public key PEM")
+	}
+	public, err := x509.ParsePKIXPublicKey(publicBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &keyEntry{record: record, public: public}
+
+	// Retired keys are only needed for verification
+	if record.IsRetired() {
+		return entry, nil
+	}
+
+	privatePEM, err := k.box.Open(record.PrivateKeyEnc)
+	if err != nil {
+		return nil, err
+	}
+	privateBlock, _ := pem.Decode(privatePEM)
+	if privateBlock == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+	entry.private, err = x509.ParsePKCS8PrivateKey(privateBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// JSONWebKey is the public part of a signing key in RFC 7517 form
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is served at /.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+func toJWK(entry *keyEntry) JSONWebKey {
+	jwk := JSONWebKey{
+		Use:       "sig",
+		Algorithm: string(entry.record.Algorithm),
+		KeyID:     entry.record.KID,
+	}
+
+	switch key := entry.public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(key)
+	default:
+		jwk.KeyType = fmt.Sprintf("%T", key)
+	}
+
+	return jwk
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// newTestKeyRing signs with EdDSA keys stored in db, keeping retired keys
+// verifying for overlap
+func newTestKeyRing(db *gorm.DB, overlap time.Duration) *KeyRing {
+	cfg := &config.Config{JWT: config.JWTConfig{
+		Secret:           "test-secret",
+		SigningAlgorithm: string(domain.SigningAlgorithmEdDSA),
+		KeyRotation:      time.Hour,
+		KeyOverlap:       overlap,
+	}}
+	return NewKeyRing(cfg, repository.NewSigningKeyRepository(db), NewSecretBox(cfg))
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	tests := []struct {
+		name         string
+		overlap      time.Duration
+		wantVerified bool
+	}{
+		{"within the overlap", time.Hour, true},
+		{"after the overlap", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &domain.SigningKey{})
+			ring := newTestKeyRing(db, tt.overlap)
+			jwtService := NewJWTService(ring.config, ring)
+			ctx := context.Background()
+
+			token, err := jwtService.sign(jwt.RegisteredClaims{Subject: "alice", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+			if err != nil {
+				t.Fatalf("sign: %v", err)
+			}
+			oldKID, _, err := ring.Current(ctx)
+			if err != nil {
+				t.Fatalf("Current: %v", err)
+			}
+
+			if err := ring.Rotate(ctx, true); err != nil {
+				t.Fatalf("Rotate: %v", err)
+			}
+			newKID, _, err := ring.Current(ctx)
+			if err != nil {
+				t.Fatalf("Current after rotation: %v", err)
+			}
+			if newKID == oldKID {
+				t.Fatal("Rotate kept the signing key")
+			}
+
+			_, err = jwtService.ValidateToken(token)
+			if verified := err == nil; verified != tt.wantVerified {
+				t.Fatalf("token of the retired key: ValidateToken error = %v, want verified %v", err, tt.wantVerified)
+			}
+			set, err := ring.JWKS(ctx)
+			if err != nil {
+				t.Fatalf("JWKS: %v", err)
+			}
+			wantKeys := 1
+			if tt.wantVerified {
+				wantKeys = 2
+			}
+			if len(set.Keys) != wantKeys {
+				t.Fatalf("JWKS has %d keys, want %d", len(set.Keys), wantKeys)
+			}
+		})
+	}
+}
+
+func TestKeyRingFindsKeyOfAnotherReplica(t *testing.T) {
+	db := newTestDB(t, &domain.SigningKey{})
+	ctx := context.Background()
+	signer := newTestKeyRing(db, time.Hour)
+	verifier := newTestKeyRing(db, time.Hour)
+
+	token, err := NewJWTService(signer.config, signer).sign(jwt.RegisteredClaims{Subject: "alice"})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	// The verifier has never loaded the key; the kid makes it look it up
+	claims, err := NewJWTService(verifier.config, verifier).ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken on another replica: %v", err)
+	}
+	if claims.Subject != "alice" {
+		t.Fatalf("subject = %q, want alice", claims.Subject)
+	}
+
+	if _, err := verifier.PublicKey(ctx, "unknown"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("PublicKey of an unknown kid error = %v, want ErrUnknownKey", err)
+	}
+}
@@ -0,0 +1,367 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gebase/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrOIDCDiscovery      = errors.New("failed to load oidc provider metadata")
+	ErrOIDCExchange       = errors.New("oidc code exchange failed")
+	ErrOIDCInvalidIDToken = errors.New("invalid oidc id token")
+)
+
+// oidcMetadataTTL is how long discovery metadata and provider keys are cached
+const oidcMetadataTTL = time.Hour
+
+// OIDCProviderMetadata is the subset of the discovery document the relying party uses
+type OIDCProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCTokenResponse is the token endpoint response of the provider
+type OIDCTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// OIDCClient is a relying-party client for the configured external identity
+// provider. Discovery metadata and signing keys are fetched lazily and cached.
+type OIDCClient struct {
+	config     *config.Config
+	httpClient *http.Client
+
+	mu         sync.RWMutex
+	metadata   *OIDCProviderMetadata
+	keys       map[string]crypto.PublicKey
+	fetchedAt  time.Time
+	keysLoaded time.Time
+}
+
+func NewOIDCClient(cfg *config.Config) *OIDCClient {
+	return &OIDCClient{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		keys:       make(map[string]crypto.PublicKey),
+	}
+}
+
+// Metadata returns the provider's discovery document
+func (c *OIDCClient) Metadata(ctx context.Context) (*OIDCProviderMetadata, error) {
+	c.mu.RLock()
+	metadata := c.metadata
+	fresh := time.Since(c.fetchedAt) < oidcMetadataTTL
+	c.mu.RUnlock()
+	if metadata != nil && fresh {
+		return metadata, nil
+	}
+
+	issuer := strings.TrimSuffix(c.config.OIDC.IssuerURL, "/")
+	var doc OIDCProviderMetadata
+	if err := c.getJSON(ctx, issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCDiscovery, err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch %q", ErrOIDCDiscovery, doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: incomplete discovery document", ErrOIDCDiscovery)
+	}
+
+	c.mu.Lock()
+	c.metadata = &doc
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+
+	return &doc, nil
+}
+
+// AuthCodeURL builds the authorization request for the code flow with PKCE (S256)
+func (c *OIDCClient) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	metadata, err := c.Metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", c.config.OIDC.ClientID)
+	params.Set("redirect_uri", c.config.OIDC.RedirectURL)
+	params.Set("scope", strings.Join(c.config.OIDC.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", PKCEChallenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code at the provider's token endpoint
+func (c *OIDCClient) Exchange(ctx context.Context, code, codeVerifier string) (*OIDCTokenResponse, error) {
+	metadata, err := c.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.config.OIDC.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.config.OIDC.ClientID), url.QueryEscape(c.config.OIDC.ClientSecret))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCExchange, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCExchange, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d: %s", ErrOIDCExchange, resp.StatusCode, body)
+	}
+
+	var token OIDCTokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCExchange, err)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%w: response has no id_token", ErrOIDCExchange)
+	}
+	return &token, nil
+}
+
+// VerifyIDToken checks the ID token's signature, issuer, audience, expiry and
+// nonce and returns its claims
+func (c *OIDCClient) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (jwt.MapClaims, error) {
+	metadata, err := c.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return c.publicKey(ctx, metadata, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(c.config.OIDC.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCInvalidIDToken, err)
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrOIDCInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// UserInfo fetches additional claims with the provider access token
+func (c *OIDCClient) UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	metadata, err := c.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.UserinfoEndpoint == "" {
+		return map[string]interface{}{}, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("userinfo returned status %d", resp.StatusCode)
+	}
+
+	info := map[string]interface{}{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// publicKey returns the provider key for a kid, refetching the key set when
+// the kid is unknown (the provider rotated its keys)
+func (c *OIDCClient) publicKey(ctx context.Context, metadata *OIDCProviderMetadata, kid string) (crypto.PublicKey, error) {
+	c.mu.RLock()
+	key, ok := c.findKey(kid)
+	stale := time.Since(c.keysLoaded) > reloadCooldown
+	c.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, ErrUnknownKey
+	}
+
+	var set JSONWebKeySet
+	if err := c.getJSON(ctx, metadata.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		parsed, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = parsed
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.keysLoaded = time.Now()
+	key, ok = c.findKey(kid)
+	c.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// findKey looks up a kid; tokens without a kid are accepted when the provider has a single key
+func (c *OIDCClient) findKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, true
+		}
+	}
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+func (c *OIDCClient) getJSON(ctx context.Context, endpoint string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned status %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// PKCEChallenge derives the S256 code challenge from a code verifier
+func PKCEChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// PublicKey decodes an RSA, EC or Ed25519 JSON Web Key
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.KeyType {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrUnsupportedAlgorithm
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"gebase/internal/auth/oidctest"
+	"gebase/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testOIDCClientID    = "gebase"
+	testOIDCRedirectURL = "http://localhost:3000/oidc/callback"
+)
+
+func newTestOIDCClient(t *testing.T) (*OIDCClient, *oidctest.Provider) {
+	t.Helper()
+
+	provider := oidctest.NewProvider(t, testOIDCClientID, "s3cret/+", testOIDCRedirectURL)
+	provider.Claims = jwt.MapClaims{"sub": "42", "email": "alice@example.com"}
+
+	client := NewOIDCClient(&config.Config{OIDC: config.OIDCConfig{
+		Enabled:      true,
+		IssuerURL:    provider.URL() + "/",
+		ClientID:     testOIDCClientID,
+		ClientSecret: "s3cret/+",
+		RedirectURL:  testOIDCRedirectURL,
+		Scopes:       []string{"openid", "email"},
+	}})
+	return client, provider
+}
+
+// authorize runs the browser leg of the code flow and returns the code
+func authorize(t *testing.T, client *OIDCClient, provider *oidctest.Provider, nonce, verifier string) string {
+	t.Helper()
+
+	authorizationURL, err := client.AuthCodeURL(context.Background(), "state", nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, state, err := provider.Authorize(authorizationURL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if state != "state" {
+		t.Fatalf("state = %q", state)
+	}
+	return code
+}
+
+func TestOIDCClientDiscovery(t *testing.T) {
+	client, provider := newTestOIDCClient(t)
+
+	metadata, err := client.Metadata(context.Background())
+	if err != nil {
+		t.Fatalf("Metadata: %v", err)
+	}
+	if metadata.TokenEndpoint != provider.URL()+"/token" || metadata.JWKSURI != provider.URL()+"/jwks" {
+		t.Fatalf("unexpected metadata %+v", metadata)
+	}
+
+	authorizationURL, err := client.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatalf("parse authorization URL: %v", err)
+	}
+	params := parsed.Query()
+	if params.Get("code_challenge") != PKCEChallenge("verifier") || params.Get("code_challenge_method") != "S256" {
+		t.Errorf("authorization URL lacks the S256 challenge: %s", authorizationURL)
+	}
+	if params.Get("scope") != "openid email" || params.Get("nonce") != "nonce" {
+		t.Errorf("unexpected authorization parameters %v", params)
+	}
+}
+
+func TestOIDCClientDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	client, provider := newTestOIDCClient(t)
+	provider.Issuer = "https://idp.example.com"
+
+	if _, err := client.Metadata(context.Background()); !errors.Is(err, ErrOIDCDiscovery) {
+		t.Fatalf("Metadata error = %v, want ErrOIDCDiscovery", err)
+	}
+}
+
+func TestOIDCClientCodeFlow(t *testing.T) {
+	client, provider := newTestOIDCClient(t)
+	ctx := context.Background()
+
+	code := authorize(t, client, provider, "nonce", "verifier")
+	token, err := client.Exchange(ctx, code, "verifier")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	claims, err := client.VerifyIDToken(ctx, token.IDToken, "nonce")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims["sub"] != "42" || claims["email"] != "alice@example.com" {
+		t.Fatalf("unexpected claims %v", claims)
+	}
+
+	info, err := client.UserInfo(ctx, token.AccessToken)
+	if err != nil {
+		t.Fatalf("UserInfo: %v", err)
+	}
+	if info["sub"] != "42" {
+		t.Fatalf("unexpected userinfo %v", info)
+	}
+
+	// Codes are single use
+	if _, err := client.Exchange(ctx, code, "verifier"); !errors.Is(err, ErrOIDCExchange) {
+		t.Fatalf("second Exchange error = %v, want ErrOIDCExchange", err)
+	}
+}
+
+func TestOIDCClientExchangeRequiresCodeVerifier(t *testing.T) {
+	client, provider := newTestOIDCClient(t)
+
+	code := authorize(t, client, provider, "nonce", "verifier")
+	if _, err := client.Exchange(context.Background(), code, "another-verifier"); !errors.Is(err, ErrOIDCExchange) {
+		t.Fatalf("Exchange error = %v, want ErrOIDCExchange", err)
+	}
+}
+
+func TestOIDCClientVerifyIDTokenRejects(t *testing.T) {
+	foreignKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		nonce  string
+		tamper func(claims jwt.MapClaims)
+		key    *rsa.PrivateKey
+	}{
+		{name: "signature", nonce: "nonce", key: foreignKey},
+		{name: "issuer", nonce: "nonce", tamper: func(claims jwt.MapClaims) { claims["iss"] = "https://idp.example.com" }},
+		{name: "audience", nonce: "nonce", tamper: func(claims jwt.MapClaims) { claims["aud"] = "another-client" }},
+		{name: "expired", nonce: "nonce", tamper: func(claims jwt.MapClaims) {
+			claims["exp"] = time.Now().Add(-2 * time.Minute).Unix()
+		}},
+		{name: "no expiry", nonce: "nonce", tamper: func(claims jwt.MapClaims) { delete(claims, "exp") }},
+		{name: "nonce", nonce: "another-nonce"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, provider := newTestOIDCClient(t)
+			ctx := context.Background()
+			provider.Tamper = tt.tamper
+			if tt.key != nil {
+				provider.SigningKey = tt.key
+			}
+
+			code := authorize(t, client, provider, "nonce", "verifier")
+			token, err := client.Exchange(ctx, code, "verifier")
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			if _, err := client.VerifyIDToken(ctx, token.IDToken, tt.nonce); !errors.Is(err, ErrOIDCInvalidIDToken) {
+				t.Fatalf("VerifyIDToken error = %v, want ErrOIDCInvalidIDToken", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+// Package oidctest provides an in-process OpenID Connect provider for tests
+// of the relying party: discovery, the authorization code flow with PKCE,
+// signed ID tokens, the key set and userinfo.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyID names the provider's signing key in the key set and token headers
+const KeyID = "oidctest"
+
+// Provider is a mock identity provider for one client. Set Claims before the
+// authorization and Tamper to corrupt the ID token a code is redeemed for.
+type Provider struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+
+	// Issuer is announced in discovery and signs ID tokens; it defaults to the
+	// server URL
+	Issuer string
+	// Claims are added to the ID token issued for the next authorization
+	Claims jwt.MapClaims
+	// UserInfo is served at the userinfo endpoint; nil serves the ID token
+	// claims
+	UserInfo map[string]interface{}
+	// Tamper edits the claims of an ID token before it is signed
+	Tamper func(claims jwt.MapClaims)
+	// SigningKey signs ID tokens; replace it to sign with a key missing from
+	// the key set
+	SigningKey *rsa.PrivateKey
+
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]*authorization
+	tokens map[string]jwt.MapClaims
+}
+
+type authorization struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+// NewProvider starts a provider, closed when the test ends
+func NewProvider(t testing.TB, clientID, clientSecret, redirectURL string) *Provider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate provider key: %v", err)
+	}
+
+	p := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Claims:       jwt.MapClaims{},
+		SigningKey:   key,
+		key:          key,
+		codes:        make(map[string]*authorization),
+		tokens:       make(map[string]jwt.MapClaims),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/userinfo", p.userInfo)
+	p.server = httptest.NewServer(mux)
+	p.Issuer = p.server.URL
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+// URL is the issuer URL the relying party is configured with
+func (p *Provider) URL() string {
+	return p.server.URL
+}
+
+// Authorize plays the browser and the provider's login page: it validates an
+// authorization URL built by the relying party and returns the code and state
+// the provider redirects back with
+func (p *Provider) Authorize(authorizationURL string) (code, state string, err error) {
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		return "", "", err
+	}
+	if parsed.Scheme+"://"+parsed.Host+parsed.Path != p.server.URL+"/authorize" {
+		return "", "", fmt.Errorf("unexpected authorization endpoint %s", parsed.Path)
+	}
+
+	params := parsed.Query()
+	switch {
+	case params.Get("response_type") != "code":
+		return "", "", errors.New("response_type is not code")
+	case params.Get("client_id") != p.ClientID:
+		return "", "", errors.New("unknown client_id")
+	case params.Get("redirect_uri") != p.RedirectURL:
+		return "", "", errors.New("redirect_uri mismatch")
+	case params.Get("code_challenge_method") != "S256" || params.Get("code_challenge") == "":
+		return "", "", errors.New("missing S256 code challenge")
+	case params.Get("state") == "":
+		return "", "", errors.New("missing state")
+	}
+
+	claims := jwt.MapClaims{}
+	for name, value := range p.Claims {
+		claims[name] = value
+	}
+	if nonce := params.Get("nonce"); nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	code = randomToken()
+	p.mu.Lock()
+	p.codes[code] = &authorization{challenge: params.Get("code_challenge"), claims: claims}
+	p.mu.Unlock()
+
+	return code, params.Get("state"), nil
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 p.Issuer,
+		"authorization_endpoint": p.server.URL + "/authorize",
+		"token_endpoint":         p.server.URL + "/token",
+		"userinfo_endpoint":      p.server.URL + "/userinfo",
+		"jwks_uri":               p.server.URL + "/jwks",
+	})
+}
+
+// token redeems a code once, for the client that requested it and with the
+// verifier matching its challenge
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	clientID, _ = url.QueryUnescape(clientID)
+	clientSecret, _ = url.QueryUnescape(clientSecret)
+	if clientID != p.ClientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.ClientSecret)) != 1 {
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	p.mu.Lock()
+	auth, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+	if !ok || r.PostForm.Get("redirect_uri") != p.RedirectURL {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": p.Issuer,
+		"aud": p.ClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+	}
+	for name, value := range auth.claims {
+		claims[name] = value
+	}
+	if p.Tamper != nil {
+		p.Tamper(claims)
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = KeyID
+	signed, err := idToken.SignedString(p.SigningKey)
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	accessToken := randomToken()
+	p.mu.Lock()
+	p.tokens[accessToken] = claims
+	p.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"id_token":     signed,
+		"expires_in":   300,
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	encode := base64.RawURLEncoding.EncodeToString
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": KeyID,
+			"n":   encode(p.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *Provider) userInfo(w http.ResponseWriter, r *http.Request) {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(prefix) || header[:len(prefix)] != prefix {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	p.mu.Lock()
+	claims, ok := p.tokens[header[len(prefix):]]
+	p.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if p.UserInfo != nil {
+		writeJSON(w, http.StatusOK, p.UserInfo)
+		return
+	}
+	writeJSON(w, http.StatusOK, claims)
+}
+
+func tokenError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func randomToken() string {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gebase/internal/cache"
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+)
+
+// Revocation reasons recorded with revoked tokens
+const (
+	RevokeReasonExitSystem   = "exit_system"
+	RevokeReasonSystemSwitch = "system_switch"
+	RevokeReasonRolesChanged = "roles_changed"
+	RevokeReasonRoleChanged  = "role_permissions_changed"
+	RevokeReasonRoleDeleted  = "role_deleted"
+	RevokeReasonMFACompleted = "mfa_completed"
+	RevokeReasonMFAExhausted = "mfa_attempts_exhausted"
+)
+
+// RevocationService keeps the server-side list of revoked token IDs (jti) that
+// AuthMiddleware consults on every request. Revocations are permanent, so
+// positive lookups are cached in-process until the token expires. Negative
+// lookups are cached briefly so valid tokens do not cost a query per request;
+// revocations made here apply at once, those of other instances within the
+// cache TTL.
+type RevocationService struct {
+	revokedRepo        *repository.RevokedTokenRepository
+	sessionRepo        *repository.SessionRepository
+	userSystemRoleRepo *repository.UserSystemRoleRepository
+
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+
+	notRevoked *cache.LRU[string, struct{}]
+}
+
+func NewRevocationService(
+	cfg *config.Config,
+	revokedRepo *repository.RevokedTokenRepository,
+	sessionRepo *repository.SessionRepository,
+	userSystemRoleRepo *repository.UserSystemRoleRepository,
+) *RevocationService {
+	return &RevocationService{
+		revokedRepo:        revokedRepo,
+		sessionRepo:        sessionRepo,
+		userSystemRoleRepo: userSystemRoleRepo,
+		revoked:            make(map[string]time.Time),
+		notRevoked:         cache.NewLRU[string, struct{}](cfg.JWT.RevocationCacheSize, cfg.JWT.RevocationCacheTTL),
+	}
+}
+
+// IsRevoked checks whether a token ID has been revoked
+func (s *RevocationService) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	if tokenID == "" {
+		return false, nil
+	}
+
+	s.mu.RLock()
+	expiresAt, cached := s.revoked[tokenID]
+	s.mu.RUnlock()
+	if cached && time.Now().Before(expiresAt) {
+		return true, nil
+	}
+	if _, ok := s.notRevoked.Get(tokenID); ok {
+		return false, nil
+	}
+
+	revoked, err := s.revokedRepo.IsRevoked(ctx, tokenID)
+	if err != nil {
+		return false, err
+	}
+	if revoked {
+		s.remember(tokenID, time.Now().Add(time.Hour))
+	} else {
+		s.notRevoked.Set(tokenID, struct{}{})
+	}
+	return revoked, nil
+}
+
+// RevokeClaims revokes the token described by claims
+func (s *RevocationService) RevokeClaims(ctx context.Context, claims *Claims, reason string) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	return s.revoke(ctx, claims.ID, claims.UserID, claims.SessionID, claims.ExpiresAt.Time, reason)
+}
+
+// RevokeSessionSystemToken revokes the system token currently bound to a session
+func (s *RevocationService) RevokeSessionSystemToken(ctx context.Context, session *domain.Session, reason string) error {
+	if session.SystemTokenID == "" || session.SystemTokenExpiresAt == nil {
+		return nil
+	}
+
+	if err := s.revoke(ctx, session.SystemTokenID, session.UserID, session.ID, *session.SystemTokenExpiresAt, reason); err != nil {
+		return err
+	}
+	return s.sessionRepo.ClearSystemToken(ctx, session.ID)
+}
+
+// RevokeSystemTokensForUser revokes outstanding system tokens of a user after
+// their roles changed. Platform roles (nil systemID) apply in every system.
+func (s *RevocationService) RevokeSystemTokensForUser(ctx context.Context, userID int64, systemID *int, reason string) error {
+	return s.revokeSystemTokens(ctx, []int64{userID}, systemID, reason)
+}
+
+// RevokeSystemTokensForRole revokes outstanding system tokens of every user holding a role
+func (s *RevocationService) RevokeSystemTokensForRole(ctx context.Context, roleID int, systemID *int, reason string) error {
+	userIDs, err := s.userSystemRoleRepo.FindUserIDsByRoleID(ctx, roleID)
+	if err != nil {
+		return err
+	}
+	return s.revokeSystemTokens(ctx, userIDs, systemID, reason)
+}
+
+func (s *RevocationService) revokeSystemTokens(ctx context.Context, userIDs []int64, systemID *int, reason string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	sessions, err := s.sessionRepo.FindWithSystemToken(ctx, userIDs, systemID)
+	if err != nil {
+		return err
+	}
+
+	for i := range sessions {
+		if err := s.RevokeSessionSystemToken(ctx, &sessions[i], reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RevocationService) revoke(ctx context.Context, tokenID string, userID, sessionID int64, expiresAt time.Time, reason string) error {
+	token := &domain.RevokedToken{
+		TokenID:   tokenID,
+		UserID:    userID,
+		SessionID: sessionID,
+		Reason:    reason,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.revokedRepo.Revoke(ctx, token); err != nil {
+		return err
+	}
+
+	s.remember(tokenID, expiresAt)
+	s.notRevoked.Delete(tokenID)
+	return nil
+}
+
+func (s *RevocationService) remember(tokenID string, expiresAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range s.revoked {
+		if now.After(exp) {
+			delete(s.revoked, id)
+		}
+	}
+	s.revoked[tokenID] = expiresAt
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const testRevocationCacheTTL = 50 * time.Millisecond
+
+func newTestRevocationService(t *testing.T) (*RevocationService, *gorm.DB) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&domain.RevokedToken{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	cfg := &config.Config{JWT: config.JWTConfig{RevocationCacheSize: 100, RevocationCacheTTL: testRevocationCacheTTL}}
+	return NewRevocationService(cfg, repository.NewRevokedTokenRepository(db), nil, nil), db
+}
+
+func TestRevocationCachesValidTokens(t *testing.T) {
+	s, db := newTestRevocationService(t)
+	ctx := context.Background()
+
+	if revoked, err := s.IsRevoked(ctx, "token-1"); err != nil || revoked {
+		t.Fatalf("IsRevoked = %v, %v, want false", revoked, err)
+	}
+
+	// Revoked by another instance: trusted from the cache until it expires
+	if err := db.Create(&domain.RevokedToken{TokenID: "token-1", ExpiresAt: time.Now().Add(time.Hour)}).Error; err != nil {
+		t.Fatalf("create revoked token: %v", err)
+	}
+	if revoked, _ := s.IsRevoked(ctx, "token-1"); revoked {
+		t.Fatal("IsRevoked asked the database within the cache TTL")
+	}
+	time.Sleep(2 * testRevocationCacheTTL)
+	if revoked, _ := s.IsRevoked(ctx, "token-1"); !revoked {
+		t.Fatal("IsRevoked = false after the cache TTL, want true")
+	}
+}
+
+func TestRevocationAppliesLocalRevocationsAtOnce(t *testing.T) {
+	s, _ := newTestRevocationService(t)
+	ctx := context.Background()
+
+	if revoked, err := s.IsRevoked(ctx, "token-1"); err != nil || revoked {
+		t.Fatalf("IsRevoked = %v, %v, want false", revoked, err)
+	}
+
+	claims := &Claims{UserID: 7, RegisteredClaims: jwt.RegisteredClaims{ID: "token-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+	if err := s.RevokeClaims(ctx, claims, RevokeReasonExitSystem); err != nil {
+		t.Fatalf("RevokeClaims: %v", err)
+	}
+	if revoked, _ := s.IsRevoked(ctx, "token-1"); !revoked {
+		t.Fatal("IsRevoked = false right after RevokeClaims, want true")
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+
+	"gebase/internal/config"
+)
+
+// SecretBox seals secrets stored in the database (signing keys, MFA seeds)
+// with AES-GCM under a key derived from JWT_KEY_ENCRYPTION_KEY.
+type SecretBox struct {
+	key []byte
+}
+
+func NewSecretBox(cfg *config.Config) *SecretBox {
+	secret := cfg.JWT.KeyEncryptionKey
+	if secret == "" {
+		secret = cfg.JWT.Secret
+	}
+	key := sha256.Sum256([]byte(secret))
+	return &SecretBox{key: key[:]}
+}
+
+// Seal encrypts plaintext and returns it base64 encoded with the nonce prepended
+func (b *SecretBox) Seal(plaintext []byte) (string, error) {
+	gcm, err := b.gcm()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, plaintext, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value produced by Seal
+func (b *SecretBox) Open(encoded string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := b.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func (b *SecretBox) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(b.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -11,35 +11,128 @@ import (
 	"github.com/google/uuid"
 )
 
+// Logout reasons recorded on sessions terminated by the server
+const (
+	// LogoutReasonTokenReuse is recorded when a consumed refresh token is replayed
+	LogoutReasonTokenReuse = "refresh_token_reuse"
+	// LogoutReasonPasswordReset is recorded when the password was reset by the user
+	LogoutReasonPasswordReset = "password_reset"
+	// LogoutReasonConsentRevoked is recorded when a user revokes an OAuth client's access
+	LogoutReasonConsentRevoked = "oauth_consent_revoked"
+	// LogoutReasonRevokedByUser is recorded when a user ends one of their sessions
+	LogoutReasonRevokedByUser = "revoked_by_user"
+	// LogoutReasonRevokedByAdmin is recorded when an administrator ends a session
+	LogoutReasonRevokedByAdmin = "revoked_by_admin"
+	// LogoutReasonImpersonationEnded is recorded when an administrator stops impersonating
+	LogoutReasonImpersonationEnded = "impersonation_ended"
+	// LogoutReasonDeviceRejected is recorded when an administrator rejects the session's device
+	LogoutReasonDeviceRejected = "device_rejected"
+	// LogoutReasonDeviceKeyReset is recorded when an administrator resets the key of the session's device
+	LogoutReasonDeviceKeyReset = "device_key_reset"
+	// LogoutReasonDeviceKeyApproved is recorded when an administrator binds a pending key to the session's device
+	LogoutReasonDeviceKeyApproved = "device_key_approved"
+	// LogoutReasonRemoteCommand is recorded when an administrator sends a device the logout_all command
+	LogoutReasonRemoteCommand = "remote_logout_command"
+)
+
 type SessionService struct {
-	config      *config.Config
-	sessionRepo *repository.SessionRepository
-	historyRepo *repository.SessionSystemHistoryRepository
+	config             *config.Config
+	sessionRepo        *repository.SessionRepository
+	historyRepo        *repository.SessionSystemHistoryRepository
+	refreshTokenRepo   *repository.RefreshTokenRepository
+	deviceRepo         *repository.DeviceRepository
+	userSystemRoleRepo *repository.UserSystemRoleRepository
+	limitRepo          *repository.SessionLimitPolicyRepository
+	timeoutRepo        *repository.SessionTimeoutPolicyRepository
 }
 
 func NewSessionService(
 	cfg *config.Config,
 	sessionRepo *repository.SessionRepository,
 	historyRepo *repository.SessionSystemHistoryRepository,
+	refreshTokenRepo *repository.RefreshTokenRepository,
+	deviceRepo *repository.DeviceRepository,
+	userSystemRoleRepo *repository.UserSystemRoleRepository,
+	limitRepo *repository.SessionLimitPolicyRepository,
+	timeoutRepo *repository.SessionTimeoutPolicyRepository,
 ) *SessionService {
 	return &SessionService{
-		config:      cfg,
-		sessionRepo: sessionRepo,
-		historyRepo: historyRepo,
+		config:             cfg,
+		sessionRepo:        sessionRepo,
+		historyRepo:        historyRepo,
+		refreshTokenRepo:   refreshTokenRepo,
+		deviceRepo:         deviceRepo,
+		userSystemRoleRepo: userSystemRoleRepo,
+		limitRepo:          limitRepo,
+		timeoutRepo:        timeoutRepo,
 	}
 }
 
-// CreateSession creates a new session for user + device
+// CreateSession creates a new session for user + device. Concurrent session
+// limits either reject the login with ErrSessionLimitReached or end the
+// user's oldest sessions first.
 func (s *SessionService) CreateSession(ctx context.Context, userID int64, deviceID int64, ipAddress, userAgent string, orgID *int64) (*domain.Session, error) {
+	if err := s.enforceSessionLimits(ctx, userID, deviceID, orgID); err != nil {
+		return nil, err
+	}
+	return s.createSession(ctx, userID, deviceID, ipAddress, userAgent, orgID, nil, time.Now())
+}
+
+// CreateClientSession creates a session for tokens issued to an OAuth client
+// on behalf of the user, so revoking the client's access ends it.
+// authenticatedAt is when the user last entered their credentials in the
+// session that approved the client.
+func (s *SessionService) CreateClientSession(ctx context.Context, userID int64, deviceID int64, clientID int64, ipAddress, userAgent string, orgID *int64, authenticatedAt time.Time) (*domain.Session, error) {
+	return s.createSession(ctx, userID, deviceID, ipAddress, userAgent, orgID, &clientID, authenticatedAt)
+}
+
+// CreateImpersonationSession creates a short-lived session in which
+// impersonatorID acts as userID on the administrator's device. It does not
+// count against, or evict, the user's own sessions.
+func (s *SessionService) CreateImpersonationSession(ctx context.Context, userID, impersonatorID, deviceID int64, ipAddress, userAgent string, orgID *int64) (*domain.Session, error) {
+	thumbprint, err := s.deviceKeyThumbprint(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &domain.Session{
+		SessionToken:        uuid.New().String(),
+		UserID:              userID,
+		DeviceID:            deviceID,
+		DeviceKeyThumbprint: thumbprint,
+		OrganizationID:      orgID,
+		ImpersonatorID:      &impersonatorID,
+		IPAddress:           ipAddress,
+		UserAgent:           userAgent,
+		IsActive:            domain.Ptr(true),
+		ExpiresAt:           time.Now().Add(s.config.Impersonation.Expiry),
+	}
+
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s *SessionService) createSession(ctx context.Context, userID int64, deviceID int64, ipAddress, userAgent string, orgID *int64, clientID *int64, authenticatedAt time.Time) (*domain.Session, error) {
+	thumbprint, err := s.deviceKeyThumbprint(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
 	session := &domain.Session{
-		SessionToken:   uuid.New().String(),
-		UserID:         userID,
-		DeviceID:       deviceID,
-		OrganizationID: orgID,
-		IPAddress:      ipAddress,
-		UserAgent:      userAgent,
-		IsActive:       domain.Ptr(true),
-		ExpiresAt:      time.Now().Add(s.config.JWT.PlatformExpiry),
+		SessionToken:        uuid.New().String(),
+		UserID:              userID,
+		DeviceID:            deviceID,
+		DeviceKeyThumbprint: thumbprint,
+		OrganizationID:      orgID,
+		OAuthClientID:       clientID,
+		IPAddress:           ipAddress,
+		UserAgent:           userAgent,
+		IsActive:            domain.Ptr(true),
+		ExpiresAt:           time.Now().Add(s.config.JWT.PlatformExpiry),
+		AuthenticatedAt:     &authenticatedAt,
 	}
 
 	if err := s.sessionRepo.Create(ctx, session); err != nil {
@@ -49,6 +142,16 @@ func (s *SessionService) CreateSession(ctx context.Context, userID int64, device
 	return session, nil
 }
 
+// deviceKeyThumbprint returns the key thumbprint that tokens of a new session
+// on deviceID are bound to, empty when the device has no key
+func (s *SessionService) deviceKeyThumbprint(ctx context.Context, deviceID int64) (string, error) {
+	device, err := s.deviceRepo.FindByID(ctx, deviceID)
+	if err != nil {
+		return "", err
+	}
+	return device.KeyThumbprint, nil
+}
+
 // GetSession retrieves session by token
 func (s *SessionService) GetSession(ctx context.Context, token string) (*domain.Session, error) {
 	return s.sessionRepo.FindByToken(ctx, token)
@@ -63,15 +166,20 @@ func (s *SessionService) GetSessionByID(ctx context.Context, id int64) (*domain.
 	return session, nil
 }
 
-// UpdateActivity updates last activity timestamp
-func (s *SessionService) UpdateActivity(ctx context.Context, sessionID int64) error {
-	return s.sessionRepo.UpdateActivity(ctx, sessionID)
+// MarkAuthenticated records that the user just entered their credentials
+// again in a session, returning the time stamped
+func (s *SessionService) MarkAuthenticated(ctx context.Context, sessionID int64) (time.Time, error) {
+	now := time.Now()
+	if err := s.sessionRepo.UpdateAuthenticatedAt(ctx, sessionID, now); err != nil {
+		return time.Time{}, err
+	}
+	return now, nil
 }
 
-// SwitchSystem switches the current system for a session
-func (s *SessionService) SwitchSystem(ctx context.Context, sessionID int64, systemID int, ipAddress string) error {
+// SwitchSystem switches the current system for a session and binds the issued system token to it
+func (s *SessionService) SwitchSystem(ctx context.Context, sessionID int64, systemID int, systemToken *Claims, ipAddress string) error {
 	// Update session's current system
-	if err := s.sessionRepo.UpdateCurrentSystem(ctx, sessionID, systemID); err != nil {
+	if err := s.sessionRepo.UpdateCurrentSystem(ctx, sessionID, systemID, systemToken.ID, systemToken.ExpiresAt.Time); err != nil {
 		return err
 	}
 
@@ -83,6 +191,11 @@ func (s *SessionService) SwitchSystem(ctx context.Context, sessionID int64, syst
 	return nil
 }
 
+// ExitSystem returns a session to platform context
+func (s *SessionService) ExitSystem(ctx context.Context, sessionID int64) error {
+	return s.sessionRepo.ClearCurrentSystem(ctx, sessionID)
+}
+
 // Logout terminates a session
 func (s *SessionService) Logout(ctx context.Context, sessionID int64, reason string) error {
 	return s.sessionRepo.Logout(ctx, sessionID, reason)
@@ -93,6 +206,16 @@ func (s *SessionService) LogoutUser(ctx context.Context, userID int64, reason st
 	return s.sessionRepo.LogoutByUserID(ctx, userID, reason)
 }
 
+// LogoutOtherSessions terminates all sessions of a user except the given one
+func (s *SessionService) LogoutOtherSessions(ctx context.Context, userID, keepSessionID int64, reason string) error {
+	return s.sessionRepo.LogoutOtherSessions(ctx, userID, keepSessionID, reason)
+}
+
+// LogoutOAuthClient terminates the sessions a user granted to an OAuth client
+func (s *SessionService) LogoutOAuthClient(ctx context.Context, userID, clientID int64, reason string) error {
+	return s.sessionRepo.LogoutByOAuthClient(ctx, userID, clientID, reason)
+}
+
 // LogoutDevice terminates all sessions for a device
 func (s *SessionService) LogoutDevice(ctx context.Context, deviceID int64, reason string) error {
 	return s.sessionRepo.LogoutByDeviceID(ctx, deviceID, reason)
@@ -121,8 +244,8 @@ func (s *SessionService) CountActiveSessions(ctx context.Context) (int64, error)
 	return s.sessionRepo.CountActiveSessions(ctx)
 }
 
-// CleanupExpiredSessions marks expired sessions as inactive
-func (s *SessionService) CleanupExpiredSessions(ctx context.Context) error {
+// CleanupExpiredSessions marks expired sessions as inactive and returns how many were ended
+func (s *SessionService) CleanupExpiredSessions(ctx context.Context) (int64, error) {
 	return s.sessionRepo.CleanupExpiredSessions(ctx)
 }
 
@@ -131,16 +254,57 @@ func (s *SessionService) GetSystemSwitchHistory(ctx context.Context, sessionID i
 	return s.historyRepo.FindBySessionID(ctx, sessionID)
 }
 
-// IsSessionValid checks if session is valid (active and not expired)
-func (s *SessionService) IsSessionValid(ctx context.Context, session *domain.Session) bool {
-	if session == nil {
-		return false
+// IssueRefreshToken records a newly generated refresh token in the session's rotation family
+func (s *SessionService) IssueRefreshToken(ctx context.Context, claims *Claims, parentID *int64) (*domain.RefreshToken, error) {
+	token := &domain.RefreshToken{
+		SessionID: claims.SessionID,
+		TokenID:   claims.ID,
+		ParentID:  parentID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+
+	if err := s.refreshTokenRepo.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken consumes the presented refresh token. Replaying a token
+// that was already consumed revokes the whole session and returns ErrTokenReused.
+func (s *SessionService) RotateRefreshToken(ctx context.Context, claims *Claims) (*domain.RefreshToken, error) {
+	token, err := s.refreshTokenRepo.FindByTokenID(ctx, claims.ID)
+	if err != nil || token.SessionID != claims.SessionID {
+		return nil, ErrInvalidToken
 	}
-	if session.IsActive == nil || !*session.IsActive {
-		return false
+
+	if token.IsConsumed() {
+		return nil, s.revokeFamily(ctx, token.SessionID)
+	}
+
+	consumed, err := s.refreshTokenRepo.MarkUsed(ctx, token.ID)
+	if err != nil {
+		return nil, err
 	}
-	if time.Now().After(session.ExpiresAt) {
-		return false
+	if !consumed {
+		// Lost a race with another refresh using the same token
+		return nil, s.revokeFamily(ctx, token.SessionID)
 	}
-	return true
+
+	return token, nil
+}
+
+func (s *SessionService) revokeFamily(ctx context.Context, sessionID int64) error {
+	if err := s.refreshTokenRepo.RevokeBySessionID(ctx, sessionID); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.Logout(ctx, sessionID, LogoutReasonTokenReuse); err != nil {
+		return err
+	}
+	return ErrTokenReused
+}
+
+// IsSessionValid checks if session is valid (active, not expired and not idle)
+func (s *SessionService) IsSessionValid(ctx context.Context, session *domain.Session) bool {
+	return s.ValidateSession(ctx, session) == nil
 }
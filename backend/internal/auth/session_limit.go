@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"gebase/internal/domain"
+)
+
+// ErrSessionLimitReached is returned by CreateSession when a "reject" session
+// limit policy is already at its maximum
+var ErrSessionLimitReached = errors.New("concurrent session limit reached")
+
+// LogoutReasonSessionLimit is recorded on sessions evicted to make room for a
+// new login under an "evict_oldest" session limit policy
+const LogoutReasonSessionLimit = "session_limit_evicted"
+
+// enforceSessionLimits applies the session limit policies of the user to a
+// login from deviceID. All "reject" limits are checked before any session is
+// evicted, so a rejected login never ends other sessions.
+func (s *SessionService) enforceSessionLimits(ctx context.Context, userID, deviceID int64, orgID *int64) error {
+	device, err := s.deviceRepo.FindByID(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+
+	userRoles, err := s.userSystemRoleRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	roleIDs := make([]int, 0, len(userRoles))
+	for _, usr := range userRoles {
+		if usr.IsActive != nil && *usr.IsActive {
+			roleIDs = append(roleIDs, usr.RoleID)
+		}
+	}
+
+	policies, err := s.limitRepo.FindApplicable(ctx, orgID, roleIDs)
+	if err != nil {
+		return err
+	}
+
+	var evictions []domain.Session
+	for _, policy := range effectiveSessionLimits(policies, device.Platform) {
+		var sessions []domain.Session
+		if policy.Scope == domain.SessionLimitScopeDevice {
+			sessions, err = s.sessionRepo.FindLiveByDevice(ctx, deviceID)
+		} else {
+			sessions, err = s.sessionRepo.FindLiveByUserAndPlatforms(ctx, userID, policy.Platforms())
+		}
+		if err != nil {
+			return err
+		}
+
+		// Room is needed for the session about to be created
+		excess := len(sessions) - policy.MaxSessions + 1
+		if excess <= 0 {
+			continue
+		}
+		if policy.Action == domain.SessionLimitReject {
+			return ErrSessionLimitReached
+		}
+		evictions = append(evictions, sessions[:excess]...)
+	}
+
+	for _, session := range evictions {
+		if err := s.sessionRepo.Logout(ctx, session.ID, LogoutReasonSessionLimit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// effectiveSessionLimits picks, for every platform and scope combination that
+// covers platform, the most specific policy. Between equally specific policies
+// (a user with several limited roles) the most generous one wins.
+func effectiveSessionLimits(policies []domain.SessionLimitPolicy, platform domain.DevicePlatform) []domain.SessionLimitPolicy {
+	type limitKey struct {
+		platform string
+		scope    string
+	}
+
+	chosen := make(map[limitKey]domain.SessionLimitPolicy)
+	var order []limitKey
+	for _, policy := range policies {
+		if !policy.Matches(platform) || policy.MaxSessions < 1 {
+			continue
+		}
+		key := limitKey{platform: policy.Platform, scope: policy.Scope}
+		current, ok := chosen[key]
+		if !ok {
+			order = append(order, key)
+		}
+		if !ok ||
+			policy.Specificity() > current.Specificity() ||
+			(policy.Specificity() == current.Specificity() && policy.MaxSessions > current.MaxSessions) {
+			chosen[key] = policy
+		}
+	}
+
+	result := make([]domain.SessionLimitPolicy, 0, len(order))
+	for _, key := range order {
+		result = append(result, chosen[key])
+	}
+	return result
+}
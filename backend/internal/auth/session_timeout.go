@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gebase/internal/domain"
+)
+
+var (
+	// ErrSessionInvalid is returned for missing, logged out or expired sessions
+	ErrSessionInvalid = errors.New("session is invalid or expired")
+	// ErrSessionIdleTimeout is returned for sessions ended by the idle timeout
+	ErrSessionIdleTimeout = errors.New("session timed out due to inactivity")
+)
+
+// LogoutReasonIdleTimeout is recorded on sessions ended by the idle timeout
+const LogoutReasonIdleTimeout = "idle_timeout"
+
+// sessionTimeout is the idle timeout and sliding expiry setting in effect for
+// a session
+type sessionTimeout struct {
+	idle    time.Duration
+	sliding bool
+}
+
+// ValidateSession checks that a session is active, not expired and not idle
+// for longer than its idle timeout. Idle sessions are logged out and reported
+// as ErrSessionIdleTimeout so clients can tell them from hard expiry.
+func (s *SessionService) ValidateSession(ctx context.Context, session *domain.Session) error {
+	if session == nil || session.IsActive == nil || !*session.IsActive {
+		return ErrSessionInvalid
+	}
+	now := time.Now()
+	if now.After(session.ExpiresAt) {
+		return ErrSessionInvalid
+	}
+
+	timeout, err := s.sessionTimeout(ctx, session)
+	if err != nil {
+		return err
+	}
+	if timeout.idle <= 0 {
+		return nil
+	}
+
+	lastActivity := session.LastActivity
+	if lastActivity == nil {
+		lastActivity = session.CreatedDate
+	}
+	if lastActivity != nil && now.Sub(*lastActivity) > timeout.idle {
+		if err := s.sessionRepo.Logout(ctx, session.ID, LogoutReasonIdleTimeout); err != nil {
+			log.Printf("Warning: failed to end idle session %d: %v", session.ID, err)
+		}
+		return ErrSessionIdleTimeout
+	}
+	return nil
+}
+
+// UpdateActivity records activity on a session and, with sliding expiry,
+// pushes its expiry forward by the platform token lifetime, never past the
+// configured maximum lifetime. Impersonation sessions are never extended.
+func (s *SessionService) UpdateActivity(ctx context.Context, session *domain.Session) error {
+	timeout, err := s.sessionTimeout(ctx, session)
+	if err != nil {
+		return err
+	}
+	if !timeout.sliding || session.ImpersonatorID != nil {
+		return s.sessionRepo.UpdateActivity(ctx, session.ID, nil)
+	}
+
+	expiresAt := time.Now().Add(s.config.JWT.PlatformExpiry)
+	if s.config.Session.MaxLifetime > 0 && session.CreatedDate != nil {
+		if limit := session.CreatedDate.Add(s.config.Session.MaxLifetime); expiresAt.After(limit) {
+			expiresAt = limit
+		}
+	}
+	if !expiresAt.After(session.ExpiresAt) {
+		return s.sessionRepo.UpdateActivity(ctx, session.ID, nil)
+	}
+	return s.sessionRepo.UpdateActivity(ctx, session.ID, &expiresAt)
+}
+
+// sessionTimeout resolves the most specific timeout policy for the session's
+// organization and device platform, falling back to the global settings
+func (s *SessionService) sessionTimeout(ctx context.Context, session *domain.Session) (sessionTimeout, error) {
+	timeout := sessionTimeout{
+		idle:    s.config.Session.IdleTimeout,
+		sliding: s.config.Session.SlidingExpiry,
+	}
+
+	policies, err := s.timeoutRepo.FindApplicable(ctx, session.OrganizationID)
+	if err != nil || len(policies) == 0 {
+		return timeout, err
+	}
+
+	device, err := s.deviceRepo.FindByID(ctx, session.DeviceID)
+	if err != nil {
+		return timeout, err
+	}
+
+	var chosen *domain.SessionTimeoutPolicy
+	for i := range policies {
+		policy := &policies[i]
+		if !policy.Matches(device.Platform) {
+			continue
+		}
+		if chosen == nil || policy.Specificity(device.Platform) > chosen.Specificity(device.Platform) {
+			chosen = policy
+		}
+	}
+	if chosen == nil {
+		return timeout, nil
+	}
+
+	timeout.idle = time.Duration(chosen.IdleTimeoutMinutes) * time.Minute
+	if chosen.SlidingExpiry != nil {
+		timeout.sliding = *chosen.SlidingExpiry
+	}
+	return timeout, nil
+}
@@ -9,11 +9,26 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	Redis    RedisConfig
-	CORS     CORSConfig
+	Server          ServerConfig
+	Database        DatabaseConfig
+	JWT             JWTConfig
+	Redis           RedisConfig
+	CORS            CORSConfig
+	MFA             MFAConfig
+	Login           LoginConfig
+	Password        PasswordConfig
+	Mail            MailConfig
+	OIDC            OIDCConfig
+	OAuth           OAuthConfig
+	LDAP            LDAPConfig
+	Session         SessionConfig
+	Scheduler       SchedulerConfig
+	Impersonation   ImpersonationConfig
+	AccessToken     AccessTokenConfig
+	Device          DeviceConfig
+	Push            PushConfig
+	LoginRisk       LoginRiskConfig
+	PermissionCache PermissionCacheConfig
 }
 
 type ServerConfig struct {
@@ -36,10 +51,19 @@ type DatabaseConfig struct {
 }
 
 type JWTConfig struct {
-	Secret             string
-	PlatformExpiry     time.Duration
-	SystemExpiry       time.Duration
-	RefreshExpiry      time.Duration
+	Secret              string
+	PlatformExpiry      time.Duration
+	SystemExpiry        time.Duration
+	RefreshExpiry       time.Duration
+	SigningAlgorithm    string
+	KeyRotation         time.Duration
+	KeyOverlap          time.Duration
+	KeyEncryptionKey    string
+	RevocationCacheSize int
+	// RevocationCacheTTL is how long a token found not revoked is trusted
+	// without asking the database; revocations made by other instances can
+	// take this long to apply
+	RevocationCacheTTL time.Duration
 }
 
 type RedisConfig struct {
@@ -53,6 +77,236 @@ type CORSConfig struct {
 	AllowedOrigins []string
 }
 
+// LoginConfig controls brute-force protection on login
+type LoginConfig struct {
+	MaxAccountFailures int
+	MaxIPFailures      int
+	FailureWindow      time.Duration
+	LockoutDuration    time.Duration
+	MaxLockoutDuration time.Duration
+	FreeAttempts       int
+	DelayBase          time.Duration
+	DelayMax           time.Duration
+}
+
+// PasswordConfig is the global password policy; organizations may override it
+type PasswordConfig struct {
+	MinLength        int
+	RequireUpper     bool
+	RequireLower     bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	HistoryCount     int
+	MaxAge           time.Duration
+	BreachedListPath string
+}
+
+type MailConfig struct {
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPStartTLS bool
+	From         string
+	// PasswordResetURL is the frontend page that receives the reset token as ?token=
+	PasswordResetURL    string
+	PasswordResetExpiry time.Duration
+	// Forgotten-password requests allowed per email and per client address
+	// within PasswordResetWindow, whether or not the email is registered
+	PasswordResetMaxPerEmail int
+	PasswordResetMaxPerIP    int
+	PasswordResetWindow      time.Duration
+}
+
+// OIDCConfig configures login through an external OpenID Connect provider
+type OIDCConfig struct {
+	Enabled      bool
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the frontend page that receives ?code=&state= and posts them to /auth/oidc/callback
+	RedirectURL string
+	Scopes      []string
+	// Claims carrying the numeric IdP user and organization ids
+	UserIDClaim  string
+	OrgIDClaim   string
+	OrgNameClaim string
+	RegNoClaim   string
+	// Just-in-time provisioning of unknown users and organizations
+	ProvisionUsers         bool
+	ProvisionOrganizations bool
+	DefaultOrgTypeID       int
+	StateExpiry            time.Duration
+}
+
+// OAuthConfig configures the built-in OAuth 2.0 / OIDC authorization server
+type OAuthConfig struct {
+	// Issuer is the public base URL of this server, used in discovery and ID tokens
+	Issuer string
+	// ConsentURL is the frontend page that logs the user in and asks for consent;
+	// /oauth/authorize redirects there with the original query string
+	ConsentURL             string
+	CodeExpiry             time.Duration
+	ClientCredentialExpiry time.Duration
+	IDTokenExpiry          time.Duration
+}
+
+// LDAPConfig holds settings shared by all organization directory servers;
+// the servers themselves are configured per organization
+type LDAPConfig struct {
+	// Timeout bounds connecting to and each request against a directory
+	Timeout time.Duration
+}
+
+// SessionConfig holds the global session timeouts; session timeout policies
+// override them per organization and device platform
+type SessionConfig struct {
+	// IdleTimeout ends sessions without activity for this long; zero disables it
+	IdleTimeout time.Duration
+	// SlidingExpiry extends a session by JWT_PLATFORM_EXPIRY on every request
+	SlidingExpiry bool
+	// MaxLifetime caps sliding expiry, measured from login; zero means no cap
+	MaxLifetime time.Duration
+}
+
+// ImpersonationConfig controls administrators acting as other users
+type ImpersonationConfig struct {
+	// Expiry is the fixed lifetime of an impersonation session
+	Expiry time.Duration
+	// RestrictedActions are permission actions (the last segment of a
+	// permission code, e.g. delete) denied while impersonating
+	RestrictedActions []string
+}
+
+// AccessTokenConfig bounds the lifetime of personal access tokens
+type AccessTokenConfig struct {
+	// DefaultExpiry applies when a token is created without an expiry
+	DefaultExpiry time.Duration
+	// MaxExpiry is the longest lifetime a token may be given
+	MaxExpiry time.Duration
+}
+
+// DeviceConfig holds device management settings
+type DeviceConfig struct {
+	// EnrollmentMode (auto or manual) applies to newly registered devices not
+	// covered by an enrollment policy
+	EnrollmentMode string
+	// ProofRequired rejects devices that have not registered a
+	// proof-of-possession key; devices with a key must always send proofs.
+	// The device endpoints used without a session need a key regardless.
+	ProofRequired bool
+	// ProofMaxAge is how far a device proof's iat may be from the server clock
+	ProofMaxAge time.Duration
+	// CommandExpiry is how long a remote command waits for the device when
+	// it is queued without an expiry
+	CommandExpiry time.Duration
+	// OnlineThreshold is how long after its last heartbeat a device counts
+	// as online
+	OnlineThreshold time.Duration
+	// SilenceAlertAfter is how long a POS or kiosk device may go without a
+	// heartbeat before administrators are alerted, for organizations without
+	// a presence policy; 0 disables these alerts
+	SilenceAlertAfter time.Duration
+	// PresenceRetention is how long presence events and resolved silence
+	// alerts are kept for uptime reporting
+	PresenceRetention time.Duration
+}
+
+// PushConfig holds the push notification provider credentials. Devices on
+// Apple platforms are reached through APNs, all others through FCM. When
+// neither provider is configured notifications are only logged.
+type PushConfig struct {
+	// FCMCredentialsFile is a Google service account key with the Firebase
+	// Cloud Messaging API enabled
+	FCMCredentialsFile string
+	// FCMProjectID defaults to the project of the service account
+	FCMProjectID string
+	// APNsKeyFile is the .p8 token signing key of the Apple developer account
+	APNsKeyFile string
+	APNsKeyID   string
+	APNsTeamID  string
+	// APNsTopic is the bundle ID of the app
+	APNsTopic   string
+	APNsSandbox bool
+	// MaxAttempts is how many times a delivery is tried before it fails
+	MaxAttempts int
+	// RetryBackoff is the wait before the first retry, doubled for each
+	// following one unless the provider asks for longer
+	RetryBackoff time.Duration
+}
+
+// LoginRiskConfig controls the risk scoring of logins. The thresholds apply
+// to organizations without a login risk policy; a threshold of 0 never
+// triggers.
+type LoginRiskConfig struct {
+	Enabled bool
+	// GeoIPDatabase is a MaxMind City or Country database (.mmdb) used to
+	// locate login addresses; without it impossible travel is not detected
+	// and networks are compared by address range only
+	GeoIPDatabase string
+	// HistoryWindow is how far back past sessions count as known devices and
+	// networks
+	HistoryWindow time.Duration
+	StepUpScore   int
+	BlockScore    int
+	NotifyScore   int
+	// MaxTravelSpeed in km/h above which two logins count as impossible travel
+	MaxTravelSpeed int
+}
+
+// Permission cache backends
+const (
+	PermissionCacheNone   = "none"
+	PermissionCacheMemory = "memory"
+	PermissionCacheRedis  = "redis"
+)
+
+// PermissionCacheConfig controls caching of the permissions users hold in
+// each system. The memory backend is per process, so with several instances
+// a change reaches the others only once their entries expire; use redis there,
+// which shares entries and invalidations and keeps a short in-process tier.
+type PermissionCacheConfig struct {
+	// Backend is none, memory or redis
+	Backend string
+	// Size is the number of user and system entries held in process
+	Size int
+	// TTL bounds how long an entry is used; changes made outside the
+	// application are picked up after it
+	TTL time.Duration
+	// LocalTTL bounds how long the redis backend uses an in-process entry,
+	// in case an invalidation announced by another instance is missed
+	LocalTTL time.Duration
+}
+
+// SchedulerConfig controls the in-process maintenance job scheduler.
+// Schedules are five-field cron expressions or descriptors such as @hourly.
+type SchedulerConfig struct {
+	Enabled                bool
+	SessionCleanupSchedule string
+	StaleDeviceSchedule    string
+	// StaleDeviceAfter is how long a device may go without a heartbeat
+	StaleDeviceAfter      time.Duration
+	DeviceCommandSchedule string
+	// DevicePresenceSchedule marks silent devices offline and raises silence
+	// alerts, so it bounds how late both are noticed
+	DevicePresenceSchedule string
+	// PushRetrySchedule retries push deliveries that failed temporarily
+	PushRetrySchedule    string
+	LogRetentionSchedule string
+	// LogRetention is how long login attempts, login risk events, job run
+	// history, finished device commands and push notifications are kept
+	LogRetention time.Duration
+}
+
+type MFAConfig struct {
+	Issuer            string
+	ChallengeExpiry   time.Duration
+	RecoveryCodeCount int
+	// ChallengeMaxAttempts is how many wrong codes a login challenge takes
+	// before the password has to be entered again
+	ChallengeMaxAttempts int
+}
+
 func Load() (*Config, error) {
 	_ = godotenv.Load()
 
@@ -79,6 +333,14 @@ func Load() (*Config, error) {
 			PlatformExpiry: getDuration("JWT_PLATFORM_EXPIRY", 24*time.Hour),
 			SystemExpiry:   getDuration("JWT_SYSTEM_EXPIRY", 8*time.Hour),
 			RefreshExpiry:  getDuration("JWT_REFRESH_EXPIRY", 168*time.Hour),
+			// RS256 or EdDSA sign with rotating keys published at /.well-known/jwks.json;
+			// HS256 keeps the legacy shared-secret mode.
+			SigningAlgorithm:    getEnv("JWT_SIGNING_ALGORITHM", "RS256"),
+			KeyRotation:         getDuration("JWT_KEY_ROTATION", 720*time.Hour),
+			KeyOverlap:          getDuration("JWT_KEY_OVERLAP", 168*time.Hour),
+			KeyEncryptionKey:    getEnv("JWT_KEY_ENCRYPTION_KEY", ""),
+			RevocationCacheSize: getEnvInt("JWT_REVOCATION_CACHE_SIZE", 10000),
+			RevocationCacheTTL:  getDuration("JWT_REVOCATION_CACHE_TTL", 5*time.Second),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -89,6 +351,132 @@ func Load() (*Config, error) {
 		CORS: CORSConfig{
 			AllowedOrigins: getEnvSlice("CORS_ALLOWED_ORIGINS", []string{"http://localhost:3000", "http://localhost:3001"}),
 		},
+		MFA: MFAConfig{
+			Issuer:               getEnv("MFA_ISSUER", "Gebase"),
+			ChallengeExpiry:      getDuration("MFA_CHALLENGE_EXPIRY", 5*time.Minute),
+			RecoveryCodeCount:    getEnvInt("MFA_RECOVERY_CODE_COUNT", 10),
+			ChallengeMaxAttempts: getEnvInt("MFA_CHALLENGE_MAX_ATTEMPTS", 5),
+		},
+		Login: LoginConfig{
+			MaxAccountFailures: getEnvInt("LOGIN_MAX_ACCOUNT_FAILURES", 5),
+			MaxIPFailures:      getEnvInt("LOGIN_MAX_IP_FAILURES", 20),
+			FailureWindow:      getDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+			LockoutDuration:    getDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+			MaxLockoutDuration: getDuration("LOGIN_MAX_LOCKOUT_DURATION", 24*time.Hour),
+			// After FreeAttempts failures each retry must wait DelayBase, doubling up to DelayMax
+			FreeAttempts: getEnvInt("LOGIN_FREE_ATTEMPTS", 2),
+			DelayBase:    getDuration("LOGIN_DELAY_BASE", time.Second),
+			DelayMax:     getDuration("LOGIN_DELAY_MAX", 30*time.Second),
+		},
+		Password: PasswordConfig{
+			MinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 8),
+			RequireUpper:  getEnvBool("PASSWORD_REQUIRE_UPPER", true),
+			RequireLower:  getEnvBool("PASSWORD_REQUIRE_LOWER", true),
+			RequireDigit:  getEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+			RequireSymbol: getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+			HistoryCount:  getEnvInt("PASSWORD_HISTORY_COUNT", 5),
+			// Zero disables expiry
+			MaxAge:           getDuration("PASSWORD_MAX_AGE", 0),
+			BreachedListPath: getEnv("PASSWORD_BREACHED_LIST_PATH", ""),
+		},
+		Mail: MailConfig{
+			SMTPHost:                 getEnv("SMTP_HOST", ""),
+			SMTPPort:                 getEnv("SMTP_PORT", "587"),
+			SMTPUsername:             getEnv("SMTP_USERNAME", ""),
+			SMTPPassword:             getEnv("SMTP_PASSWORD", ""),
+			SMTPStartTLS:             getEnvBool("SMTP_STARTTLS", true),
+			From:                     getEnv("MAIL_FROM", "no-reply@gerege.mn"),
+			PasswordResetURL:         getEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+			PasswordResetExpiry:      getDuration("PASSWORD_RESET_EXPIRY", time.Hour),
+			PasswordResetMaxPerEmail: getEnvInt("PASSWORD_RESET_MAX_PER_EMAIL", 3),
+			PasswordResetMaxPerIP:    getEnvInt("PASSWORD_RESET_MAX_PER_IP", 10),
+			PasswordResetWindow:      getDuration("PASSWORD_RESET_WINDOW", time.Hour),
+		},
+		OIDC: OIDCConfig{
+			Enabled:                getEnvBool("OIDC_ENABLED", false),
+			IssuerURL:              getEnv("OIDC_ISSUER_URL", ""),
+			ClientID:               getEnv("OIDC_CLIENT_ID", ""),
+			ClientSecret:           getEnv("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:            getEnv("OIDC_REDIRECT_URL", "http://localhost:3000/auth/callback"),
+			Scopes:                 getEnvSlice("OIDC_SCOPES", []string{"openid", "profile", "email"}),
+			UserIDClaim:            getEnv("OIDC_USER_ID_CLAIM", "sub"),
+			OrgIDClaim:             getEnv("OIDC_ORG_ID_CLAIM", "org_id"),
+			OrgNameClaim:           getEnv("OIDC_ORG_NAME_CLAIM", "org_name"),
+			RegNoClaim:             getEnv("OIDC_REG_NO_CLAIM", "reg_no"),
+			ProvisionUsers:         getEnvBool("OIDC_PROVISION_USERS", true),
+			ProvisionOrganizations: getEnvBool("OIDC_PROVISION_ORGANIZATIONS", true),
+			DefaultOrgTypeID:       getEnvInt("OIDC_DEFAULT_ORG_TYPE_ID", 2),
+			StateExpiry:            getDuration("OIDC_STATE_EXPIRY", 10*time.Minute),
+		},
+		OAuth: OAuthConfig{
+			Issuer:                 getEnv("OAUTH_ISSUER", "http://localhost:8000"),
+			ConsentURL:             getEnv("OAUTH_CONSENT_URL", "http://localhost:3000/oauth/authorize"),
+			CodeExpiry:             getDuration("OAUTH_CODE_EXPIRY", time.Minute),
+			ClientCredentialExpiry: getDuration("OAUTH_CLIENT_CREDENTIAL_EXPIRY", time.Hour),
+			IDTokenExpiry:          getDuration("OAUTH_ID_TOKEN_EXPIRY", time.Hour),
+		},
+		LDAP: LDAPConfig{
+			Timeout: getDuration("LDAP_TIMEOUT", 10*time.Second),
+		},
+		Session: SessionConfig{
+			IdleTimeout:   getDuration("SESSION_IDLE_TIMEOUT", 0),
+			SlidingExpiry: getEnvBool("SESSION_SLIDING_EXPIRY", false),
+			MaxLifetime:   getDuration("SESSION_MAX_LIFETIME", 0),
+		},
+		Scheduler: SchedulerConfig{
+			Enabled:                getEnvBool("SCHEDULER_ENABLED", true),
+			SessionCleanupSchedule: getEnv("SCHEDULER_SESSION_CLEANUP", "*/15 * * * *"),
+			StaleDeviceSchedule:    getEnv("SCHEDULER_STALE_DEVICES", "0 * * * *"),
+			StaleDeviceAfter:       getDuration("DEVICE_STALE_AFTER", 720*time.Hour),
+			DeviceCommandSchedule:  getEnv("SCHEDULER_DEVICE_COMMANDS", "*/5 * * * *"),
+			DevicePresenceSchedule: getEnv("SCHEDULER_DEVICE_PRESENCE", "* * * * *"),
+			PushRetrySchedule:      getEnv("SCHEDULER_PUSH_RETRY", "* * * * *"),
+			LogRetentionSchedule:   getEnv("SCHEDULER_LOG_RETENTION", "30 3 * * *"),
+			LogRetention:           getDuration("LOG_RETENTION", 2160*time.Hour),
+		},
+		Impersonation: ImpersonationConfig{
+			Expiry:            getDuration("IMPERSONATION_EXPIRY", time.Hour),
+			RestrictedActions: getEnvSlice("IMPERSONATION_RESTRICTED_ACTIONS", []string{"delete"}),
+		},
+		AccessToken: AccessTokenConfig{
+			DefaultExpiry: getDuration("ACCESS_TOKEN_DEFAULT_EXPIRY", 2160*time.Hour),
+			MaxExpiry:     getDuration("ACCESS_TOKEN_MAX_EXPIRY", 8760*time.Hour),
+		},
+		Device: DeviceConfig{
+			EnrollmentMode:    getEnv("DEVICE_ENROLLMENT_MODE", "auto"),
+			ProofRequired:     getEnvBool("DEVICE_PROOF_REQUIRED", false),
+			ProofMaxAge:       getDuration("DEVICE_PROOF_MAX_AGE", 2*time.Minute),
+			CommandExpiry:     getDuration("DEVICE_COMMAND_EXPIRY", 72*time.Hour),
+			OnlineThreshold:   getDuration("DEVICE_ONLINE_THRESHOLD", 5*time.Minute),
+			SilenceAlertAfter: getDuration("DEVICE_SILENCE_ALERT_AFTER", 30*time.Minute),
+			PresenceRetention: getDuration("DEVICE_PRESENCE_RETENTION", 9600*time.Hour),
+		},
+		Push: PushConfig{
+			FCMCredentialsFile: getEnv("PUSH_FCM_CREDENTIALS_FILE", ""),
+			FCMProjectID:       getEnv("PUSH_FCM_PROJECT_ID", ""),
+			APNsKeyFile:        getEnv("PUSH_APNS_KEY_FILE", ""),
+			APNsKeyID:          getEnv("PUSH_APNS_KEY_ID", ""),
+			APNsTeamID:         getEnv("PUSH_APNS_TEAM_ID", ""),
+			APNsTopic:          getEnv("PUSH_APNS_TOPIC", ""),
+			APNsSandbox:        getEnvBool("PUSH_APNS_SANDBOX", false),
+			MaxAttempts:        getEnvInt("PUSH_MAX_ATTEMPTS", 5),
+			RetryBackoff:       getDuration("PUSH_RETRY_BACKOFF", time.Minute),
+		},
+		LoginRisk: LoginRiskConfig{
+			Enabled:        getEnvBool("LOGIN_RISK_ENABLED", true),
+			GeoIPDatabase:  getEnv("LOGIN_RISK_GEOIP_DB", ""),
+			HistoryWindow:  getDuration("LOGIN_RISK_HISTORY_WINDOW", 2160*time.Hour),
+			StepUpScore:    getEnvInt("LOGIN_RISK_STEP_UP_SCORE", 40),
+			BlockScore:     getEnvInt("LOGIN_RISK_BLOCK_SCORE", 0),
+			NotifyScore:    getEnvInt("LOGIN_RISK_NOTIFY_SCORE", 40),
+			MaxTravelSpeed: getEnvInt("LOGIN_RISK_MAX_TRAVEL_SPEED", 1000),
+		},
+		PermissionCache: PermissionCacheConfig{
+			Backend:  getEnv("PERMISSION_CACHE_BACKEND", PermissionCacheMemory),
+			Size:     getEnvInt("PERMISSION_CACHE_SIZE", 10000),
+			TTL:      getDuration("PERMISSION_CACHE_TTL", 5*time.Minute),
+			LocalTTL: getDuration("PERMISSION_CACHE_LOCAL_TTL", 10*time.Second),
+		},
 	}, nil
 }
 
@@ -108,6 +496,15 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
 func getDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
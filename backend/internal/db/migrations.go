@@ -31,15 +31,53 @@ func RunMigrations(db *gorm.DB) error {
 		&domain.OrganizationType{},
 		&domain.Organization{},
 		&domain.OrganizationSystem{},
+		&domain.OrganizationSecurityPolicy{},
+		&domain.OrganizationLDAPConfig{},
+		&domain.LDAPGroupRoleMapping{},
 
 		// User entities
 		&domain.User{},
 		&domain.UserSystemRole{},
+		&domain.UserMFA{},
+		&domain.MFARecoveryCode{},
+		&domain.PasswordHistory{},
+		&domain.PasswordResetToken{},
+		&domain.PasswordResetRequest{},
+		&domain.PersonalAccessToken{},
 
 		// Device & Session entities
 		&domain.Device{},
+		&domain.DeviceEnrollmentPolicy{},
+		&domain.DeviceProofNonce{},
+		&domain.DeviceCommand{},
+		&domain.DeviceConfigProfile{},
+		&domain.DeviceConfigVersion{},
+		&domain.DeviceConfigSchema{},
+		&domain.DevicePresenceEvent{},
+		&domain.DevicePresencePolicy{},
+		&domain.DeviceSilenceAlert{},
+		&domain.PushTemplate{},
+		&domain.PushNotification{},
+		&domain.PushDelivery{},
 		&domain.Session{},
 		&domain.SessionSystemHistory{},
+		&domain.SessionLimitPolicy{},
+		&domain.SessionTimeoutPolicy{},
+		&domain.RefreshToken{},
+		&domain.SigningKey{},
+		&domain.RevokedToken{},
+		&domain.LoginAttempt{},
+		&domain.ImpersonationEvent{},
+		&domain.JobRun{},
+		&domain.LoginLockout{},
+		&domain.LoginRiskPolicy{},
+		&domain.LoginRiskEvent{},
+		&domain.OIDCLoginState{},
+
+		// OAuth authorization server
+		&domain.OAuthClient{},
+		&domain.OAuthConsent{},
+		&domain.OAuthAuthorizationCode{},
 	)
 	if err != nil {
 		return err
@@ -67,6 +105,10 @@ func RunMigrations(db *gorm.DB) error {
 		log.Printf("Warning: some constraints may already exist: %v", err)
 	}
 
+	if err := migrateLegacyDeviceConfig(db); err != nil {
+		return err
+	}
+
 	log.Println("Database migrations completed successfully")
 	return nil
 }
@@ -97,6 +139,21 @@ func createUniqueConstraints(db *gorm.DB) error {
 		// Translation: language_code + key
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_translations_lang_key ON translations(language_code, key) WHERE deleted_date IS NULL`,
 
+		// DeviceConfigProfile: one profile per organization, platform or device
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_device_config_profiles_target ON device_config_profiles(scope, COALESCE(organization_id, 0), platform, COALESCE(device_id, 0)) WHERE deleted_date IS NULL`,
+
+		// DeviceConfigVersion: profile_id + version
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_device_config_versions_profile_version ON device_config_versions(profile_id, version)`,
+
+		// DeviceConfigSchema: platform
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_device_config_schemas_platform ON device_config_schemas(platform) WHERE deleted_date IS NULL`,
+
+		// DeviceSilenceAlert: one open alert per device
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_device_silence_alerts_open ON device_silence_alerts(device_id) WHERE resolved_at IS NULL`,
+
+		// PushTemplate: code
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_push_templates_code ON push_templates(code) WHERE deleted_date IS NULL`,
+
 		// DSL Field: schema_id + code
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_dsl_fields_schema_code ON dsl_fields(schema_id, code) WHERE deleted_date IS NULL`,
 
@@ -112,3 +169,30 @@ func createUniqueConstraints(db *gorm.DB) error {
 
 	return nil
 }
+
+// migrateLegacyDeviceConfig moves the configs stored in devices.config_json
+// before config profiles existed into device override profiles, then drops
+// the column
+func migrateLegacyDeviceConfig(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&domain.Device{}, "config_json") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO device_config_profiles (scope, device_id, platform, config_json, version, created_date, updated_date)
+			SELECT ?, id, '', config_json, 1, NOW(), NOW() FROM devices
+			WHERE config_json IS NOT NULL AND config_json <> '{}'::jsonb AND deleted_date IS NULL`,
+			domain.DeviceConfigScopeDevice).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`INSERT INTO device_config_versions (profile_id, version, config_json, diff_json, note, created_date, updated_date)
+			SELECT p.id, 1, p.config_json, '[]', 'Migrated from device config', NOW(), NOW() FROM device_config_profiles p
+			WHERE p.scope = ? AND NOT EXISTS (SELECT 1 FROM device_config_versions v WHERE v.profile_id = p.id)`,
+			domain.DeviceConfigScopeDevice).Error
+		if err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&domain.Device{}, "config_json")
+	})
+}
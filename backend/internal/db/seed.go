@@ -175,6 +175,11 @@ func seedModules(db *gorm.DB) error {
 		{ID: 22, Code: "log", Name: "Лог", SystemID: ptr(2), IsActive: ptr(true)},
 	}
 
+	// Admin modules added after the DSL system
+	adminModules = append(adminModules,
+		domain.Module{ID: 23, Code: "push", Name: "Мэдэгдэл", SystemID: ptr(1), IsActive: ptr(true)},
+	)
+
 	allModules := append(adminModules, dslModules...)
 	for _, module := range allModules {
 		if err := db.Where("id = ?", module.ID).FirstOrCreate(&module).Error; err != nil {
@@ -436,6 +441,11 @@ func seedPermissions(db *gorm.DB) error {
 	adminModules := []string{"user", "organization", "system", "module", "action", "role", "permission", "menu", "device", "session", "monitoring", "language", "translation"}
 	adminActions := []string{"view", "create", "update", "delete"}
 
+	// Sensitive permissions ask for the credentials again when the session
+	// last confirmed them longer ago than this
+	const sensitiveRecentAuthMinutes = 15
+	sensitive := map[string]bool{"admin.user.delete": true}
+
 	permID := 1
 	var permissions []domain.Permission
 
@@ -453,6 +463,9 @@ func seedPermissions(db *gorm.DB) error {
 				ActionID: &actionID,
 				IsActive: ptr(true),
 			}
+			if sensitive[code] {
+				perm.RecentAuthMinutes = sensitiveRecentAuthMinutes
+			}
 			permissions = append(permissions, perm)
 			permID++
 		}
@@ -483,16 +496,125 @@ func seedPermissions(db *gorm.DB) error {
 		}
 	}
 
+	// Admin permissions outside the CRUD matrix
+	executeActionID := int64(9)
+	permissions = append(permissions, domain.Permission{
+		ID:       permID,
+		Code:     "admin.user.impersonate",
+		Name:     "admin.user.impersonate",
+		SystemID: ptr(1),
+		ModuleID: 1, // user module
+		ActionID: &executeActionID,
+		IsActive: ptr(true),
+	})
+	permID++
+
+	updateActionID := int64(3)
+	sensitiveActions := []struct {
+		code     string
+		moduleID int
+	}{
+		{"admin.user.reset_password", 1},     // user module
+		{"admin.role.assign_permissions", 6}, // role module
+	}
+	// Permissions split off from the one their routes required before
+	splitFrom := map[string]string{
+		"admin.user.reset_password":     "admin.user.update",
+		"admin.role.assign_permissions": "admin.role.update",
+		"admin.push.send":               "admin.device.update",
+	}
+	for _, action := range sensitiveActions {
+		permissions = append(permissions, domain.Permission{
+			ID:                permID,
+			Code:              action.code,
+			Name:              action.code,
+			SystemID:          ptr(1),
+			ModuleID:          action.moduleID,
+			ActionID:          &updateActionID,
+			IsActive:          ptr(true),
+			RecentAuthMinutes: sensitiveRecentAuthMinutes,
+		})
+		permID++
+	}
+
+	// Sending push notifications, apart from device administration so it can
+	// be granted to the service accounts of internal callers
+	publishActionID := int64(10)
+	permissions = append(permissions, domain.Permission{
+		ID:       permID,
+		Code:     "admin.push.send",
+		Name:     "admin.push.send",
+		SystemID: ptr(1),
+		ModuleID: 23, // push module
+		ActionID: &publishActionID,
+		IsActive: ptr(true),
+	})
+	permID++
+
+	var created []domain.Permission
 	for _, perm := range permissions {
-		if err := db.Where("id = ?", perm.ID).FirstOrCreate(&perm).Error; err != nil {
-			return err
+		result := db.Where("id = ?", perm.ID).FirstOrCreate(&perm)
+		if result.Error != nil {
+			return result.Error
 		}
+		if result.RowsAffected > 0 {
+			created = append(created, perm)
+		}
+	}
+
+	if err := upgradeSeededPermissions(db, created, splitFrom, sensitive, sensitiveRecentAuthMinutes); err != nil {
+		return err
 	}
 
 	log.Println("Permissions seeded:", len(permissions))
 	return nil
 }
 
+// upgradeSeededPermissions brings a database seeded by an earlier version in
+// line with the permissions just created. Roles holding the permission a
+// split-off one was carved from are granted it, so nobody loses access, and
+// when the split-off permissions are new the permissions that became
+// sensitive with them get their recent-authentication window unless one was
+// set. Both only happen when the permissions are created, so grants and
+// windows an administrator changed later are left alone.
+func upgradeSeededPermissions(db *gorm.DB, created []domain.Permission, splitFrom map[string]string, sensitive map[string]bool, recentAuthMinutes int) error {
+	upgraded := false
+	for _, perm := range created {
+		parent, ok := splitFrom[perm.Code]
+		if !ok {
+			continue
+		}
+		upgraded = true
+
+		var roleIDs []int
+		err := db.Model(&domain.RolePermission{}).
+			Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+			Where("permissions.code = ?", parent).
+			Distinct().
+			Pluck("role_permissions.role_id", &roleIDs).Error
+		if err != nil {
+			return err
+		}
+		for _, roleID := range roleIDs {
+			rp := domain.RolePermission{RoleID: roleID, PermissionID: perm.ID}
+			if err := db.Where("role_id = ? AND permission_id = ?", rp.RoleID, rp.PermissionID).FirstOrCreate(&rp).Error; err != nil {
+				return err
+			}
+		}
+	}
+	if !upgraded {
+		return nil
+	}
+
+	var codes []string
+	for code := range sensitive {
+		codes = append(codes, code)
+	}
+	return db.Model(&domain.Permission{}).
+		Where("code IN ? AND recent_auth_minutes = 0", codes).
+		Update("recent_auth_minutes", recentAuthMinutes).Error
+}
+
 func seedRolePermissions(db *gorm.DB) error {
 	// Get all admin permissions (system_id = 1)
 	var adminPermissions []domain.Permission
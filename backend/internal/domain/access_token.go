@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// PersonalAccessTokenPrefix marks personal access tokens so they can be told
+// apart from JWTs in the Authorization header
+const PersonalAccessTokenPrefix = "gbp_"
+
+// PersonalAccessToken lets scripts and CI jobs call the API as a user or
+// service account without a session or a registered device. A token is bound
+// to one system and a subset of its owner's permissions there, stored space
+// separated. Only the SHA-256 hash of the token is stored.
+type PersonalAccessToken struct {
+	ID     int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID int64  `json:"user_id" gorm:"index"`
+	User   *User  `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Name   string `json:"name" gorm:"type:varchar(100)"`
+	// TokenPrefix is the start of the token, shown so users can recognise it
+	TokenPrefix string     `json:"token_prefix" gorm:"type:varchar(16)"`
+	TokenHash   string     `json:"-" gorm:"uniqueIndex;type:varchar(64)"`
+	SystemID    int        `json:"system_id" gorm:"index"`
+	System      *System    `json:"system,omitempty" gorm:"foreignKey:SystemID"`
+	Permissions string     `json:"permissions" gorm:"type:text"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"index"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP  string     `json:"last_used_ip,omitempty" gorm:"type:varchar(45)"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy   *int64     `json:"revoked_by,omitempty"`
+	CreatedBy   *int64     `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (PersonalAccessToken) TableName() string {
+	return "personal_access_tokens"
+}
+
+func (t *PersonalAccessToken) IsUsable() bool {
+	return t.RevokedAt == nil && time.Now().Before(t.ExpiresAt)
+}
+
+func (t *PersonalAccessToken) PermissionCodes() []string {
+	return strings.Fields(t.Permissions)
+}
+
+func (t *PersonalAccessToken) AllowsPermission(code string) bool {
+	for _, allowed := range strings.Fields(t.Permissions) {
+		if allowed == code {
+			return true
+		}
+	}
+	return false
+}
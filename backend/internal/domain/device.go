@@ -31,10 +31,96 @@ type Device struct {
 	IsActive       *bool          `json:"is_active" gorm:"default:true"`
 	RegisteredAt   *time.Time     `json:"registered_at"`
 	LastHeartbeat  *time.Time     `json:"last_heartbeat"`
-	ConfigJSON     string         `json:"config_json" gorm:"type:jsonb;default:'{}'"`
+	// StaleSince is set by the maintenance job when no heartbeat arrived for
+	// DEVICE_STALE_AFTER and cleared by the next heartbeat
+	StaleSince *time.Time `json:"stale_since"`
+	// IsOnline is set by the device's heartbeats and cleared by the presence
+	// job once none arrived for DEVICE_ONLINE_THRESHOLD
+	IsOnline          *bool      `json:"is_online" gorm:"default:false;index"`
+	PresenceChangedAt *time.Time `json:"presence_changed_at"`
+	// ApprovalStatus is set from the organization's enrollment policy when the
+	// device registers. The column defaults to approved so devices enrolled
+	// before approval existed keep working.
+	ApprovalStatus  string     `json:"approval_status" gorm:"type:varchar(20);default:'approved';index"`
+	ReviewedBy      *int64     `json:"reviewed_by,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+	RejectionReason string     `json:"rejection_reason,omitempty" gorm:"type:varchar(500)"`
+	// PublicKeyJWK is the proof-of-possession key the device registered, in
+	// its RFC 7638 canonical form. Requests from a device with a key must be
+	// signed with it, and tokens issued on the device are bound to
+	// KeyThumbprint.
+	PublicKeyJWK  string     `json:"public_key_jwk,omitempty" gorm:"type:text"`
+	KeyAlgorithm  string     `json:"key_algorithm,omitempty" gorm:"type:varchar(10)"`
+	KeyThumbprint string     `json:"key_thumbprint,omitempty" gorm:"type:varchar(64);index"`
+	KeyBoundAt    *time.Time `json:"key_bound_at,omitempty"`
+	// PendingKeyJWK is a key registered for a device that had none by a
+	// caller without a session on it. It is bound once an administrator
+	// approves it, as anyone knowing the device UID could have sent it.
+	PendingKeyJWK        string     `json:"-" gorm:"type:text"`
+	PendingKeyAlgorithm  string     `json:"-" gorm:"type:varchar(10)"`
+	PendingKeyThumbprint string     `json:"pending_key_thumbprint,omitempty" gorm:"type:varchar(64)"`
+	PendingKeyAt         *time.Time `json:"pending_key_at,omitempty"`
 	ExtraFields
 }
 
 func (Device) TableName() string {
 	return "devices"
 }
+
+func (d *Device) IsApproved() bool {
+	return d.ApprovalStatus == DeviceApprovalApproved
+}
+
+// HasKey reports whether the device registered a proof-of-possession key
+func (d *Device) HasKey() bool {
+	return d.KeyThumbprint != ""
+}
+
+// HasPendingKey reports whether a key is awaiting an administrator's approval
+func (d *Device) HasPendingKey() bool {
+	return d.PendingKeyThumbprint != ""
+}
+
+// Platform classes group platforms that share session policies
+const (
+	PlatformClassWeb     = "web"
+	PlatformClassMobile  = "mobile"
+	PlatformClassTablet  = "tablet"
+	PlatformClassDesktop = "desktop"
+	PlatformClassKiosk   = "kiosk"
+	PlatformClassPOS     = "pos"
+)
+
+// AllPlatforms lists every supported device platform
+var AllPlatforms = []DevicePlatform{
+	PlatformWeb, PlatformIOS, PlatformAndroid, PlatformTabletIOS, PlatformTabletAndroid,
+	PlatformWindowsDesktop, PlatformMacDesktop, PlatformKiosk, PlatformPOSAndroid, PlatformPOSLinux,
+}
+
+// IsValidDevicePlatform reports whether value names a device platform
+func IsValidDevicePlatform(value string) bool {
+	for _, platform := range AllPlatforms {
+		if value == string(platform) {
+			return true
+		}
+	}
+	return false
+}
+
+// Class returns the platform class of p
+func (p DevicePlatform) Class() string {
+	switch p {
+	case PlatformIOS, PlatformAndroid:
+		return PlatformClassMobile
+	case PlatformTabletIOS, PlatformTabletAndroid:
+		return PlatformClassTablet
+	case PlatformWindowsDesktop, PlatformMacDesktop:
+		return PlatformClassDesktop
+	case PlatformKiosk:
+		return PlatformClassKiosk
+	case PlatformPOSAndroid, PlatformPOSLinux:
+		return PlatformClassPOS
+	default:
+		return PlatformClassWeb
+	}
+}
@@ -0,0 +1,72 @@
+package domain
+
+import "time"
+
+// Device command types
+const (
+	// DeviceCommandLock locks the device screen until a user signs in again
+	DeviceCommandLock = "lock"
+	// DeviceCommandLogoutAll signs every user out of the device
+	DeviceCommandLogoutAll = "logout_all"
+	// DeviceCommandWipeCache clears the app's local data and caches
+	DeviceCommandWipeCache = "wipe_cache"
+	// DeviceCommandReloadConfig makes the device fetch its configuration again
+	DeviceCommandReloadConfig = "reload_config"
+	// DeviceCommandUpdateApp installs the app version given in the payload
+	DeviceCommandUpdateApp = "update_app"
+)
+
+// Device command statuses
+const (
+	DeviceCommandPending   = "pending"
+	DeviceCommandDelivered = "delivered"
+	DeviceCommandSucceeded = "succeeded"
+	DeviceCommandFailed    = "failed"
+	DeviceCommandExpired   = "expired"
+	DeviceCommandCancelled = "cancelled"
+)
+
+// DeviceCommand is an action queued by an administrator for a device. It is
+// handed to the device with every heartbeat until the device acknowledges it
+// or it expires.
+type DeviceCommand struct {
+	ID       int64   `json:"id" gorm:"primaryKey;autoIncrement"`
+	DeviceID int64   `json:"device_id" gorm:"index"`
+	Device   *Device `json:"device,omitempty" gorm:"foreignKey:DeviceID"`
+	Type     string  `json:"type" gorm:"type:varchar(30)"`
+	// PayloadJSON holds type specific arguments, e.g. the version for update_app
+	PayloadJSON string    `json:"payload_json" gorm:"type:jsonb;default:'{}'"`
+	Status      string    `json:"status" gorm:"type:varchar(20);index"`
+	IssuedBy    *int64    `json:"issued_by,omitempty"`
+	Issuer      *User     `json:"issuer,omitempty" gorm:"foreignKey:IssuedBy"`
+	ExpiresAt   time.Time `json:"expires_at" gorm:"index"`
+	// DeliveredAt is the first heartbeat the command was returned in;
+	// DeliveryCount counts every heartbeat that returned it
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	DeliveryCount int        `json:"delivery_count" gorm:"default:0"`
+	// Result reported by the device when acknowledging the command
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	ResultJSON     string     `json:"result_json" gorm:"type:jsonb;default:'{}'"`
+	ResultMessage  string     `json:"result_message,omitempty" gorm:"type:varchar(1000)"`
+	CancelledBy    *int64     `json:"cancelled_by,omitempty"`
+	CancelledAt    *time.Time `json:"cancelled_at,omitempty"`
+	ExtraFields
+}
+
+func (DeviceCommand) TableName() string {
+	return "device_commands"
+}
+
+// IsOpen reports whether the command may still be delivered and acknowledged
+func (c *DeviceCommand) IsOpen() bool {
+	return (c.Status == DeviceCommandPending || c.Status == DeviceCommandDelivered) && time.Now().Before(c.ExpiresAt)
+}
+
+// IsValidDeviceCommandType reports whether value is a device command type
+func IsValidDeviceCommandType(value string) bool {
+	switch value {
+	case DeviceCommandLock, DeviceCommandLogoutAll, DeviceCommandWipeCache, DeviceCommandReloadConfig, DeviceCommandUpdateApp:
+		return true
+	}
+	return false
+}
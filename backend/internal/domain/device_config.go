@@ -0,0 +1,63 @@
+package domain
+
+// Device config profile scopes, in the order they are layered
+const (
+	DeviceConfigScopeOrganization = "organization"
+	DeviceConfigScopePlatform     = "platform"
+	DeviceConfigScopeDevice       = "device"
+)
+
+// DeviceConfigProfile is one layer of remote device configuration. A
+// device's effective config starts from its organization's profile, then
+// applies its platform's profile and finally its own overrides, each as a
+// JSON merge patch (RFC 7386): objects merge key by key, null removes a key
+// and any other value replaces the one below it.
+type DeviceConfigProfile struct {
+	ID    int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Scope string `json:"scope" gorm:"type:varchar(20);index"`
+	// Exactly one target is set, matching Scope
+	OrganizationID *int64         `json:"organization_id,omitempty" gorm:"index"`
+	Organization   *Organization  `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+	Platform       DevicePlatform `json:"platform,omitempty" gorm:"type:varchar(50)"`
+	DeviceID       *int64         `json:"device_id,omitempty" gorm:"index"`
+	ConfigJSON     string         `json:"config_json" gorm:"type:jsonb;default:'{}'"`
+	// Version counts the profile's changes, starting at 1
+	Version int `json:"version" gorm:"default:1"`
+	ExtraFields
+}
+
+func (DeviceConfigProfile) TableName() string {
+	return "device_config_profiles"
+}
+
+// DeviceConfigVersion records one change to a config profile
+type DeviceConfigVersion struct {
+	ID         int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	ProfileID  int64  `json:"profile_id" gorm:"index"`
+	Version    int    `json:"version"`
+	ConfigJSON string `json:"config_json" gorm:"type:jsonb;default:'{}'"`
+	// DiffJSON lists the changes from the previous version as
+	// {"op", "path", "old", "new"} entries with JSON pointer paths
+	DiffJSON string `json:"diff_json" gorm:"type:jsonb;default:'[]'"`
+	Note     string `json:"note,omitempty" gorm:"type:varchar(500)"`
+	// RolledBackFrom is the version whose config this one restored
+	RolledBackFrom *int `json:"rolled_back_from,omitempty"`
+	ExtraFields
+}
+
+func (DeviceConfigVersion) TableName() string {
+	return "device_config_versions"
+}
+
+// DeviceConfigSchema is the JSON Schema the effective config of devices on
+// a platform must satisfy
+type DeviceConfigSchema struct {
+	ID         int64          `json:"id" gorm:"primaryKey;autoIncrement"`
+	Platform   DevicePlatform `json:"platform" gorm:"type:varchar(50)"`
+	SchemaJSON string         `json:"schema_json" gorm:"type:jsonb"`
+	ExtraFields
+}
+
+func (DeviceConfigSchema) TableName() string {
+	return "device_config_schemas"
+}
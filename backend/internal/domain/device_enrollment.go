@@ -0,0 +1,61 @@
+package domain
+
+// Device approval statuses
+const (
+	DeviceApprovalPending  = "pending"
+	DeviceApprovalApproved = "approved"
+	DeviceApprovalRejected = "rejected"
+)
+
+// Device enrollment modes
+const (
+	// DeviceEnrollmentAuto approves devices as they register
+	DeviceEnrollmentAuto = "auto"
+	// DeviceEnrollmentManual queues devices for an administrator's approval
+	DeviceEnrollmentManual = "manual"
+)
+
+// DeviceEnrollmentPolicy chooses whether newly registered devices of an
+// organization, a device platform or both are approved automatically or
+// wait for an administrator. The most specific policy wins, as with session
+// timeout policies; without one DEVICE_ENROLLMENT_MODE applies.
+type DeviceEnrollmentPolicy struct {
+	ID             int64         `json:"id" gorm:"primaryKey;autoIncrement"`
+	OrganizationID *int64        `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+	// Platform is a device platform, a platform class (web, mobile, tablet,
+	// desktop, kiosk, pos) or empty for every platform
+	Platform string `json:"platform" gorm:"type:varchar(50)"`
+	Mode     string `json:"mode" gorm:"type:varchar(20)"`
+	IsActive *bool  `json:"is_active" gorm:"default:true"`
+	ExtraFields
+}
+
+func (DeviceEnrollmentPolicy) TableName() string {
+	return "device_enrollment_policies"
+}
+
+// Matches reports whether the policy covers a device platform
+func (p *DeviceEnrollmentPolicy) Matches(platform DevicePlatform) bool {
+	return p.Platform == "" || p.Platform == string(platform) || p.Platform == platform.Class()
+}
+
+// Specificity ranks policies for platform: organization-specific beats
+// platform-specific, and an exact platform beats its class
+func (p *DeviceEnrollmentPolicy) Specificity(platform DevicePlatform) int {
+	score := 0
+	if p.OrganizationID != nil {
+		score += 4
+	}
+	if p.Platform == string(platform) {
+		score += 2
+	} else if p.Platform != "" {
+		score++
+	}
+	return score
+}
+
+// IsValidDeviceEnrollmentMode reports whether value is an enrollment mode
+func IsValidDeviceEnrollmentMode(value string) bool {
+	return value == DeviceEnrollmentAuto || value == DeviceEnrollmentManual
+}
@@ -0,0 +1,70 @@
+package domain
+
+import "time"
+
+// Device presence statuses
+const (
+	DevicePresenceOnline  = "online"
+	DevicePresenceOffline = "offline"
+)
+
+// DevicePresenceEvent records a device going online or offline. The
+// organization, platform and app version are copied from the device at the
+// time so fleet reports stay accurate after the device changes.
+type DevicePresenceEvent struct {
+	ID             int64          `json:"id" gorm:"primaryKey;autoIncrement"`
+	DeviceID       int64          `json:"device_id" gorm:"index:idx_device_presence_events_device_time"`
+	Status         string         `json:"status" gorm:"type:varchar(10)"`
+	OrganizationID *int64         `json:"organization_id,omitempty" gorm:"index"`
+	Platform       DevicePlatform `json:"platform" gorm:"type:varchar(50)"`
+	AppVersion     string         `json:"app_version" gorm:"type:varchar(50)"`
+	// OccurredAt is the first heartbeat of an online period, or the last one
+	// of the period an offline event ends
+	OccurredAt time.Time `json:"occurred_at" gorm:"index:idx_device_presence_events_device_time;index"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (DevicePresenceEvent) TableName() string {
+	return "device_presence_events"
+}
+
+// DevicePresencePolicy sets how long the POS and kiosk devices of an
+// organization may stay silent before administrators are alerted.
+// Organizations without a policy use DEVICE_SILENCE_ALERT_AFTER.
+type DevicePresencePolicy struct {
+	ID             int64         `json:"id" gorm:"primaryKey;autoIncrement"`
+	OrganizationID int64         `json:"organization_id" gorm:"uniqueIndex"`
+	Organization   *Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+	// SilenceAlertMinutes is how long after its last heartbeat a device is
+	// reported silent; 0 turns the alerts off for the organization
+	SilenceAlertMinutes int   `json:"silence_alert_minutes"`
+	IsActive            *bool `json:"is_active" gorm:"default:true"`
+	ExtraFields
+}
+
+func (DevicePresencePolicy) TableName() string {
+	return "device_presence_policies"
+}
+
+// DeviceSilenceAlert is raised when a POS or kiosk device has been silent
+// longer than its organization allows, and resolved by its next heartbeat
+type DeviceSilenceAlert struct {
+	ID             int64          `json:"id" gorm:"primaryKey;autoIncrement"`
+	DeviceID       int64          `json:"device_id" gorm:"index"`
+	Device         *Device        `json:"device,omitempty" gorm:"foreignKey:DeviceID"`
+	OrganizationID *int64         `json:"organization_id,omitempty" gorm:"index"`
+	Platform       DevicePlatform `json:"platform" gorm:"type:varchar(50)"`
+	LastSeenAt     time.Time      `json:"last_seen_at"`
+	RaisedAt       time.Time      `json:"raised_at" gorm:"autoCreateTime;index"`
+	ResolvedAt     *time.Time     `json:"resolved_at,omitempty"`
+}
+
+func (DeviceSilenceAlert) TableName() string {
+	return "device_silence_alerts"
+}
+
+// IsMonitoredForSilence reports whether silence alerts cover a platform
+func IsMonitoredForSilence(platform DevicePlatform) bool {
+	class := platform.Class()
+	return class == PlatformClassPOS || class == PlatformClassKiosk
+}
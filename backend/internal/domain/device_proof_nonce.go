@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// DeviceProofNonce records the jti of a device proof so it cannot be
+// replayed. Rows are only needed until the proof would be too old anyway.
+type DeviceProofNonce struct {
+	ID            int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	KeyThumbprint string    `json:"key_thumbprint" gorm:"type:varchar(64);uniqueIndex:idx_device_proof_nonces_key_jti"`
+	JTI           string    `json:"jti" gorm:"type:varchar(64);uniqueIndex:idx_device_proof_nonces_key_jti"`
+	ExpiresAt     time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (DeviceProofNonce) TableName() string {
+	return "device_proof_nonces"
+}
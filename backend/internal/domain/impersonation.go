@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// Impersonation event types
+const (
+	ImpersonationStarted = "started"
+	ImpersonationStopped = "stopped"
+)
+
+// ImpersonationEvent is an audit record of an administrator starting or
+// stopping a session as another user. Each event is listed for both users.
+type ImpersonationEvent struct {
+	ID             int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	SessionID      int64  `json:"session_id" gorm:"index"`
+	ImpersonatorID int64  `json:"impersonator_id" gorm:"index"`
+	Impersonator   *User  `json:"impersonator,omitempty" gorm:"foreignKey:ImpersonatorID"`
+	TargetUserID   int64  `json:"target_user_id" gorm:"index"`
+	TargetUser     *User  `json:"target_user,omitempty" gorm:"foreignKey:TargetUserID"`
+	Event          string `json:"event" gorm:"type:varchar(20)"`
+	// Reason is the justification given when the impersonation started
+	Reason    string    `json:"reason,omitempty" gorm:"type:text"`
+	IPAddress string    `json:"ip_address" gorm:"type:varchar(45)"`
+	UserAgent string    `json:"user_agent" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+func (ImpersonationEvent) TableName() string {
+	return "impersonation_events"
+}
@@ -0,0 +1,40 @@
+package domain
+
+import "time"
+
+// Job run statuses
+const (
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+// JobRun records one execution of a scheduled background job. A job runs at
+// most once per scheduled time across all replicas.
+type JobRun struct {
+	ID          int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	JobName     string     `json:"job_name" gorm:"type:varchar(100);uniqueIndex:idx_job_runs_schedule"`
+	ScheduledAt time.Time  `json:"scheduled_at" gorm:"uniqueIndex:idx_job_runs_schedule"`
+	StartedAt   time.Time  `json:"started_at" gorm:"index"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	Status      string     `json:"status" gorm:"type:varchar(20)"`
+	// Affected is the number of records the job changed or removed
+	Affected int64  `json:"affected"`
+	Error    string `json:"error,omitempty" gorm:"type:text"`
+	// Instance is the host name of the replica that ran the job
+	Instance string `json:"instance" gorm:"type:varchar(255)"`
+	// TriggeredBy is set when an administrator started the run manually
+	TriggeredBy *int64 `json:"triggered_by,omitempty"`
+}
+
+func (JobRun) TableName() string {
+	return "job_runs"
+}
+
+// Duration is how long the run took, or has been running
+func (r *JobRun) Duration() time.Duration {
+	if r.FinishedAt == nil {
+		return time.Since(r.StartedAt)
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
@@ -0,0 +1,57 @@
+package domain
+
+import "strings"
+
+// Authentication backends an organization can select in its security policy
+const (
+	AuthBackendLocal = "local"
+	AuthBackendLDAP  = "ldap"
+)
+
+// OrganizationLDAPConfig describes the LDAP / Active Directory server that
+// authenticates an organization's users. The service account password is
+// sealed with the SecretBox and never returned by the API.
+type OrganizationLDAPConfig struct {
+	ID                 int64         `json:"id" gorm:"primaryKey;autoIncrement"`
+	OrganizationID     int64         `json:"organization_id" gorm:"uniqueIndex"`
+	Organization       *Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+	URL                string        `json:"url" gorm:"type:varchar(255)"`
+	StartTLS           *bool         `json:"start_tls" gorm:"default:false"`
+	InsecureSkipVerify *bool         `json:"insecure_skip_verify" gorm:"default:false"`
+	BindDN             string        `json:"bind_dn" gorm:"type:varchar(500)"`
+	BindPassword       string        `json:"-" gorm:"type:text"`
+	BaseDN             string        `json:"base_dn" gorm:"type:varchar(500)"`
+	// UserFilter finds the directory entry of a user. {email} and {username}
+	// (the local part of the email) are replaced with escaped values.
+	UserFilter     string `json:"user_filter" gorm:"type:varchar(500)"`
+	GroupAttribute string `json:"group_attribute" gorm:"type:varchar(100);default:'memberOf'"`
+	// SyncRoles replaces the user's roles in mapped systems with the roles
+	// mapped from their directory groups on every login
+	SyncRoles *bool `json:"sync_roles" gorm:"default:true"`
+	ExtraFields
+}
+
+func (OrganizationLDAPConfig) TableName() string {
+	return "organization_ldap_configs"
+}
+
+// LDAPGroupRoleMapping grants a role to members of a directory group
+type LDAPGroupRoleMapping struct {
+	ID             int64   `json:"id" gorm:"primaryKey;autoIncrement"`
+	OrganizationID int64   `json:"organization_id" gorm:"index"`
+	GroupDN        string  `json:"group_dn" gorm:"type:varchar(500)"`
+	RoleID         int     `json:"role_id"`
+	Role           *Role   `json:"role,omitempty" gorm:"foreignKey:RoleID"`
+	SystemID       *int    `json:"system_id"`
+	System         *System `json:"system,omitempty" gorm:"foreignKey:SystemID"`
+	ExtraFields
+}
+
+func (LDAPGroupRoleMapping) TableName() string {
+	return "ldap_group_role_mappings"
+}
+
+// Matches compares DNs the way directories do, ignoring case
+func (m *LDAPGroupRoleMapping) Matches(groupDN string) bool {
+	return strings.EqualFold(strings.TrimSpace(m.GroupDN), strings.TrimSpace(groupDN))
+}
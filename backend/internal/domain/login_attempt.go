@@ -0,0 +1,60 @@
+package domain
+
+import "time"
+
+// Login failure reasons recorded in the attempt ledger
+const (
+	LoginFailureUnknownUser     = "unknown_user"
+	LoginFailureInvalidPassword = "invalid_password"
+	LoginFailureInvalidMFACode  = "invalid_mfa_code"
+	LoginFailureUserInactive    = "user_inactive"
+	LoginFailureLocked          = "locked"
+	LoginFailureServiceAccount  = "service_account"
+)
+
+// LoginAttempt is an entry in the login ledger. UserID is empty when the
+// email did not match an account.
+type LoginAttempt struct {
+	ID            int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Email         string    `json:"email" gorm:"type:varchar(80);index"`
+	UserID        *int64    `json:"user_id,omitempty" gorm:"index"`
+	IPAddress     string    `json:"ip_address" gorm:"type:varchar(45);index"`
+	DeviceUID     string    `json:"device_uid" gorm:"type:varchar(255)"`
+	UserAgent     string    `json:"user_agent" gorm:"type:text"`
+	Success       bool      `json:"success"`
+	FailureReason string    `json:"failure_reason,omitempty" gorm:"type:varchar(50)"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+// LoginLockout blocks logins for an account (UserID) or a client address
+// (IPAddress) until LockedUntil or until an admin unlocks it
+type LoginLockout struct {
+	ID          int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID      *int64     `json:"user_id,omitempty" gorm:"index"`
+	IPAddress   string     `json:"ip_address,omitempty" gorm:"type:varchar(45);index"`
+	FailedCount int        `json:"failed_count"`
+	LockedUntil time.Time  `json:"locked_until"`
+	UnlockedAt  *time.Time `json:"unlocked_at,omitempty"`
+	UnlockedBy  *int64     `json:"unlocked_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (LoginLockout) TableName() string {
+	return "login_lockouts"
+}
+
+func (l *LoginLockout) IsActive() bool {
+	return l.UnlockedAt == nil && time.Now().Before(l.LockedUntil)
+}
+
+// ResetAt is the point from which failures count again
+func (l *LoginLockout) ResetAt() time.Time {
+	if l.UnlockedAt != nil {
+		return *l.UnlockedAt
+	}
+	return l.CreatedAt
+}
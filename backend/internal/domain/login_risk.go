@@ -0,0 +1,100 @@
+package domain
+
+import "time"
+
+// Login risk signals
+const (
+	// LoginRiskNewDevice is a login from a device the user has not signed in
+	// from before
+	LoginRiskNewDevice = "new_device"
+	// LoginRiskUnusualNetwork is a login from an address range, and country
+	// when known, the user has not signed in from before
+	LoginRiskUnusualNetwork = "unusual_network"
+	// LoginRiskImpossibleTravel is a login too far from the previous one to
+	// have travelled in between
+	LoginRiskImpossibleTravel = "impossible_travel"
+	// LoginRiskOffHours is a login outside the organization's business hours
+	LoginRiskOffHours = "off_hours"
+)
+
+// Login risk actions
+const (
+	LoginRiskAllow  = "allow"
+	LoginRiskStepUp = "step_up"
+	LoginRiskBlock  = "block"
+)
+
+// Default scores of login risk signals for organizations without a policy
+const (
+	DefaultNewDeviceScore        = 25
+	DefaultUnusualNetworkScore   = 20
+	DefaultImpossibleTravelScore = 60
+	DefaultOffHoursScore         = 15
+)
+
+// LoginRiskPolicy sets how an organization scores logins and acts on the
+// score. Thresholds of 0 never trigger.
+type LoginRiskPolicy struct {
+	ID             int64         `json:"id" gorm:"primaryKey;autoIncrement"`
+	OrganizationID int64         `json:"organization_id" gorm:"uniqueIndex"`
+	Organization   *Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+
+	NewDeviceScore        int `json:"new_device_score"`
+	UnusualNetworkScore   int `json:"unusual_network_score"`
+	ImpossibleTravelScore int `json:"impossible_travel_score"`
+	OffHoursScore         int `json:"off_hours_score"`
+
+	// StepUpScore requires a second factor for logins scoring at least this
+	StepUpScore int `json:"step_up_score"`
+	// BlockScore rejects logins scoring at least this
+	BlockScore int `json:"block_score"`
+	// NotifyScore alerts the user and organization administrators of logins
+	// scoring at least this
+	NotifyScore int `json:"notify_score"`
+	// MaxTravelSpeedKmh is the speed between two logins above which they
+	// count as impossible travel
+	MaxTravelSpeedKmh int `json:"max_travel_speed_kmh"`
+
+	// Business hours are [BusinessHoursStart, BusinessHoursEnd) in Timezone
+	// on BusinessDays (ISO weekdays, 1 is Monday, comma separated). Equal
+	// hours turn the off-hours signal off.
+	BusinessHoursStart int    `json:"business_hours_start"`
+	BusinessHoursEnd   int    `json:"business_hours_end"`
+	BusinessDays       string `json:"business_days" gorm:"type:varchar(20)"`
+	Timezone           string `json:"timezone" gorm:"type:varchar(64)"`
+
+	IsActive *bool `json:"is_active" gorm:"default:true"`
+	ExtraFields
+}
+
+func (LoginRiskPolicy) TableName() string {
+	return "login_risk_policies"
+}
+
+// LoginRiskEvent records the assessment of a login whose password was
+// correct
+type LoginRiskEvent struct {
+	ID             int64   `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID         int64   `json:"user_id" gorm:"index"`
+	User           *User   `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	OrganizationID *int64  `json:"organization_id,omitempty" gorm:"index"`
+	DeviceID       int64   `json:"device_id"`
+	Device         *Device `json:"device,omitempty" gorm:"foreignKey:DeviceID"`
+	IPAddress      string  `json:"ip_address" gorm:"type:varchar(45)"`
+	UserAgent      string  `json:"user_agent" gorm:"type:text"`
+	// Country and City are located from IPAddress when a GeoIP database is
+	// configured
+	Country   string   `json:"country,omitempty" gorm:"type:varchar(2)"`
+	City      string   `json:"city,omitempty" gorm:"type:varchar(255)"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Score     int      `json:"score"`
+	// Signals is a comma separated list of the signals raised
+	Signals   string    `json:"signals" gorm:"type:varchar(255)"`
+	Action    string    `json:"action" gorm:"type:varchar(20);index"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+func (LoginRiskEvent) TableName() string {
+	return "login_risk_events"
+}
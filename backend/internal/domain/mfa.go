@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+// UserMFA holds a user's TOTP enrollment. The seed is sealed at rest and the
+// enrollment only takes effect once a first code has been confirmed.
+type UserMFA struct {
+	ID           int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID       int64      `json:"user_id" gorm:"uniqueIndex"`
+	User         *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	SecretEnc    string     `json:"-" gorm:"type:text"`
+	IsEnabled    *bool      `json:"is_enabled" gorm:"default:false"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep int64      `json:"-"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	ExtraFields
+}
+
+func (UserMFA) TableName() string {
+	return "user_mfa"
+}
+
+func (m *UserMFA) IsConfirmed() bool {
+	return m.IsEnabled != nil && *m.IsEnabled && m.ConfirmedAt != nil
+}
+
+// MFARecoveryCode is a single-use fallback code, stored as a SHA-256 hash
+type MFARecoveryCode struct {
+	ID        int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    int64      `json:"user_id" gorm:"index"`
+	CodeHash  string     `json:"-" gorm:"type:varchar(64);index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (MFARecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	OAuthGrantAuthorizationCode = "authorization_code"
+	OAuthGrantRefreshToken      = "refresh_token"
+	OAuthGrantClientCredentials = "client_credentials"
+)
+
+// OAuthClient is an application registered with the built-in authorization
+// server. Lists are stored space separated, as OAuth writes scopes.
+type OAuthClient struct {
+	ID           int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	ClientID     string `json:"client_id" gorm:"uniqueIndex;type:varchar(64)"`
+	SecretHash   string `json:"-" gorm:"type:varchar(255)"`
+	Name         string `json:"name" gorm:"type:varchar(255)"`
+	Description  string `json:"description" gorm:"type:text"`
+	RedirectURIs string `json:"redirect_uris" gorm:"type:text"`
+	GrantTypes   string `json:"grant_types" gorm:"type:varchar(255)"`
+	Scopes       string `json:"scopes" gorm:"type:text"`
+	// Confidential clients authenticate with a secret; public clients must use PKCE
+	IsConfidential *bool `json:"is_confidential" gorm:"default:true"`
+	// First-party clients (our own frontends) skip the consent screen
+	IsFirstParty   *bool         `json:"is_first_party" gorm:"default:false"`
+	IsActive       *bool         `json:"is_active" gorm:"default:true"`
+	OrganizationID *int64        `json:"organization_id,omitempty"`
+	Organization   *Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+	ExtraFields
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+func (c *OAuthClient) HasRedirectURI(uri string) bool {
+	for _, registered := range strings.Fields(c.RedirectURIs) {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	for _, grant := range strings.Fields(c.GrantTypes) {
+		if grant == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *OAuthClient) AllowsScope(scope string) bool {
+	for _, allowed := range strings.Fields(c.Scopes) {
+		if allowed == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *OAuthClient) IsPublic() bool {
+	return c.IsConfidential != nil && !*c.IsConfidential
+}
+
+// OAuthConsent records the scopes a user granted to a client
+type OAuthConsent struct {
+	ID        int64        `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    int64        `json:"user_id" gorm:"uniqueIndex:idx_oauth_consent_user_client"`
+	ClientID  int64        `json:"client_id" gorm:"uniqueIndex:idx_oauth_consent_user_client"`
+	Client    *OAuthClient `json:"client,omitempty" gorm:"foreignKey:ClientID"`
+	Scopes    string       `json:"scopes" gorm:"type:text"`
+	GrantedAt time.Time    `json:"granted_at"`
+	CreatedAt time.Time    `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time    `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (OAuthConsent) TableName() string {
+	return "oauth_consents"
+}
+
+// Covers reports whether every requested scope was already granted
+func (c *OAuthConsent) Covers(scopes []string) bool {
+	granted := strings.Fields(c.Scopes)
+	for _, scope := range scopes {
+		found := false
+		for _, g := range granted {
+			if g == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// OAuthAuthorizationCode is a single-use code issued by the authorization
+// endpoint. Only the SHA-256 hash of the code is stored.
+type OAuthAuthorizationCode struct {
+	ID                  int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	CodeHash            string     `json:"-" gorm:"uniqueIndex;type:varchar(64)"`
+	ClientID            int64      `json:"client_id" gorm:"index"`
+	UserID              int64      `json:"user_id"`
+	DeviceID            int64      `json:"device_id"`
+	RedirectURI         string     `json:"redirect_uri" gorm:"type:varchar(1000)"`
+	Scope               string     `json:"scope" gorm:"type:text"`
+	Nonce               string     `json:"-" gorm:"type:varchar(255)"`
+	CodeChallenge       string     `json:"-" gorm:"type:varchar(128)"`
+	CodeChallengeMethod string     `json:"-" gorm:"type:varchar(10)"`
+	AuthTime            time.Time  `json:"auth_time"`
+	ExpiresAt           time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt              *time.Time `json:"used_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (OAuthAuthorizationCode) TableName() string {
+	return "oauth_authorization_codes"
+}
+
+func (c *OAuthAuthorizationCode) IsUsable() bool {
+	return c.UsedAt == nil && time.Now().Before(c.ExpiresAt)
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// OIDCLoginState tracks an authorization request sent to the external
+// identity provider until its callback arrives. Only the SHA-256 hash of the
+// state parameter is stored; the PKCE verifier never leaves the server.
+type OIDCLoginState struct {
+	ID           int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	StateHash    string     `json:"-" gorm:"uniqueIndex;type:varchar(64)"`
+	Nonce        string     `json:"-" gorm:"type:varchar(64)"`
+	CodeVerifier string     `json:"-" gorm:"type:varchar(128)"`
+	DeviceUID    string     `json:"device_uid" gorm:"type:varchar(255)"`
+	IPAddress    string     `json:"ip_address" gorm:"type:varchar(45)"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt       *time.Time `json:"used_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
+
+func (s *OIDCLoginState) IsUsable() bool {
+	return s.UsedAt == nil && time.Now().Before(s.ExpiresAt)
+}
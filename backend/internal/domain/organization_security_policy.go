@@ -0,0 +1,28 @@
+package domain
+
+// OrganizationSecurityPolicy holds per-organization authentication requirements.
+// Organizations without a row fall back to the global defaults.
+type OrganizationSecurityPolicy struct {
+	ID                  int64         `json:"id" gorm:"primaryKey;autoIncrement"`
+	OrganizationID      int64         `json:"organization_id" gorm:"uniqueIndex"`
+	Organization        *Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+	RequireMFA          *bool         `json:"require_mfa" gorm:"default:false"`
+	RequireMFAForAdmins *bool         `json:"require_mfa_for_admins" gorm:"default:false"`
+	// AuthBackend selects how passwords are verified: local or ldap
+	AuthBackend string `json:"auth_backend" gorm:"type:varchar(20);default:'local'"`
+
+	// Password policy overrides; nil inherits the global setting
+	PasswordMinLength     *int  `json:"password_min_length"`
+	PasswordRequireUpper  *bool `json:"password_require_upper"`
+	PasswordRequireLower  *bool `json:"password_require_lower"`
+	PasswordRequireDigit  *bool `json:"password_require_digit"`
+	PasswordRequireSymbol *bool `json:"password_require_symbol"`
+	PasswordHistoryCount  *int  `json:"password_history_count"`
+	PasswordMaxAgeDays    *int  `json:"password_max_age_days"`
+
+	ExtraFields
+}
+
+func (OrganizationSecurityPolicy) TableName() string {
+	return "organization_security_policies"
+}
@@ -0,0 +1,15 @@
+package domain
+
+import "time"
+
+// PasswordHistory keeps previous password hashes so they cannot be reused
+type PasswordHistory struct {
+	ID           int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID       int64     `json:"user_id" gorm:"index"`
+	PasswordHash string    `json:"-" gorm:"type:varchar(255)"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (PasswordHistory) TableName() string {
+	return "password_histories"
+}
@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// PasswordResetToken is a single-use token mailed to a user who forgot their
+// password. Only the SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	ID        int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    int64      `json:"user_id" gorm:"index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;type:varchar(64)"`
+	IPAddress string     `json:"ip_address" gorm:"type:varchar(45)"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
+
+func (t *PasswordResetToken) IsUsable() bool {
+	return t.UsedAt == nil && time.Now().Before(t.ExpiresAt)
+}
+
+// PasswordResetRequest records a forgotten-password request, whether or not
+// the email matched an account, to throttle requests per email and address
+type PasswordResetRequest struct {
+	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Email     string    `json:"email" gorm:"type:varchar(80);index"`
+	IPAddress string    `json:"ip_address" gorm:"type:varchar(45);index"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+func (PasswordResetRequest) TableName() string {
+	return "password_reset_requests"
+}
@@ -12,6 +12,9 @@ type Permission struct {
 	ActionID    *int64  `json:"action_id"`
 	Action      *Action `json:"action,omitempty" gorm:"foreignKey:ActionID"`
 	IsActive    *bool   `json:"is_active" gorm:"default:true"`
+	// RecentAuthMinutes requires the session to have entered its credentials
+	// within this many minutes to use the permission; 0 does not
+	RecentAuthMinutes int `json:"recent_auth_minutes" gorm:"default:0"`
 	ExtraFields
 }
 
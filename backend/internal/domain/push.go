@@ -0,0 +1,85 @@
+package domain
+
+import "time"
+
+// Push notification targets
+const (
+	PushTargetUser         = "user"
+	PushTargetDevice       = "device"
+	PushTargetOrganization = "organization"
+	PushTargetPlatform     = "platform"
+)
+
+// Push delivery statuses
+const (
+	PushDeliveryPending = "pending"
+	PushDeliverySent    = "sent"
+	PushDeliveryFailed  = "failed"
+	// PushDeliveryInvalidToken means the provider rejected the device's
+	// token, which was then removed from the device
+	PushDeliveryInvalidToken = "invalid_token"
+)
+
+// PushTemplate is a reusable notification. Its title, body and data values
+// are Go text templates filled with the variables given when sending.
+type PushTemplate struct {
+	ID    int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Code  string `json:"code" gorm:"type:varchar(100)"`
+	Name  string `json:"name" gorm:"type:varchar(255)"`
+	Title string `json:"title" gorm:"type:varchar(255)"`
+	Body  string `json:"body" gorm:"type:text"`
+	// DataJSON is an object of string values sent as the message data
+	DataJSON string `json:"data_json" gorm:"type:jsonb;default:'{}'"`
+	IsActive *bool  `json:"is_active" gorm:"default:true"`
+	ExtraFields
+}
+
+func (PushTemplate) TableName() string {
+	return "push_templates"
+}
+
+// PushNotification is one send request, fanned out to a PushDelivery per
+// device of its target
+type PushNotification struct {
+	ID         int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	TargetType string `json:"target_type" gorm:"type:varchar(20);index"`
+	// TargetID is the user, device or organization; unset for platform targets
+	TargetID *int64 `json:"target_id,omitempty"`
+	// Platform is the platform targeted, or narrows the other targets
+	Platform    DevicePlatform `json:"platform,omitempty" gorm:"type:varchar(50)"`
+	TemplateID  *int64         `json:"template_id,omitempty"`
+	Template    *PushTemplate  `json:"template,omitempty" gorm:"foreignKey:TemplateID"`
+	Title       string         `json:"title" gorm:"type:varchar(255)"`
+	Body        string         `json:"body" gorm:"type:text"`
+	DataJSON    string         `json:"data_json" gorm:"type:jsonb;default:'{}'"`
+	DeviceCount int            `json:"device_count"`
+	CreatedBy   *int64         `json:"created_by,omitempty"`
+	CreatedAt   time.Time      `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+func (PushNotification) TableName() string {
+	return "push_notifications"
+}
+
+// PushDelivery is a notification to one device. Deliveries that fail
+// temporarily stay pending until NextAttemptAt.
+type PushDelivery struct {
+	ID             int64   `json:"id" gorm:"primaryKey;autoIncrement"`
+	NotificationID int64   `json:"notification_id" gorm:"index"`
+	DeviceID       int64   `json:"device_id" gorm:"index"`
+	Device         *Device `json:"device,omitempty" gorm:"foreignKey:DeviceID"`
+	Provider       string  `json:"provider" gorm:"type:varchar(10)"`
+	// Token is the device's push token when the notification was sent
+	Token         string     `json:"-" gorm:"type:varchar(500)"`
+	Status        string     `json:"status" gorm:"type:varchar(20);index:idx_push_deliveries_due"`
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" gorm:"index:idx_push_deliveries_due"`
+	LastError     string     `json:"last_error,omitempty" gorm:"type:varchar(500)"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (PushDelivery) TableName() string {
+	return "push_deliveries"
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// RefreshToken is one link in a session's refresh token rotation family.
+// Every refresh consumes the current token and issues a successor; presenting
+// a consumed or revoked token again is treated as theft and revokes the session.
+type RefreshToken struct {
+	ID        int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	SessionID int64      `json:"session_id" gorm:"index"`
+	Session   *Session   `json:"session,omitempty" gorm:"foreignKey:SessionID"`
+	TokenID   string     `json:"-" gorm:"uniqueIndex;type:varchar(64)"`
+	ParentID  *int64     `json:"parent_id,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+func (t *RefreshToken) IsConsumed() bool {
+	return t.UsedAt != nil || t.RevokedAt != nil
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// RevokedToken marks a token ID (jti) as no longer valid. Rows are only needed
+// until the token would have expired on its own.
+type RevokedToken struct {
+	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	TokenID   string    `json:"token_id" gorm:"uniqueIndex;type:varchar(64)"`
+	UserID    int64     `json:"user_id" gorm:"index"`
+	SessionID int64     `json:"session_id"`
+	Reason    string    `json:"reason" gorm:"type:varchar(100)"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	RevokedAt time.Time `json:"revoked_at" gorm:"autoCreateTime"`
+}
+
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
@@ -1,5 +1,7 @@
 package domain
 
+import "strings"
+
 type Role struct {
 	ID          int              `json:"id" gorm:"primaryKey"`
 	Code        string           `json:"code" gorm:"type:varchar(50)"`
@@ -21,3 +23,9 @@ func (Role) TableName() string {
 func (r *Role) IsPlatformRole() bool {
 	return r.SystemID == nil
 }
+
+// IsAdminRole reports whether the role grants administrative access
+// (super_admin, admin and per-system *_admin roles)
+func (r *Role) IsAdminRole() bool {
+	return r.Code == "super_admin" || r.Code == "admin" || strings.HasSuffix(r.Code, "_admin")
+}
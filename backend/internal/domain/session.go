@@ -7,11 +7,12 @@ type TokenType string
 const (
 	TokenTypePlatform TokenType = "platform"
 	TokenTypeSystem   TokenType = "system"
+	TokenTypeRefresh  TokenType = "refresh"
 )
 
 type Session struct {
 	ID              int64      `json:"id" gorm:"primaryKey;autoIncrement"`
-	SessionToken    string     `json:"session_token" gorm:"unique;type:varchar(255)"`
+	SessionToken    string     `json:"-" gorm:"unique;type:varchar(255)"`
 	UserID          int64      `json:"user_id"`
 	User            *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	DeviceID        int64      `json:"device_id"`
@@ -20,9 +21,30 @@ type Session struct {
 	CurrentSystemID *int       `json:"current_system_id"`
 	CurrentSystem   *System    `json:"current_system,omitempty" gorm:"foreignKey:CurrentSystemID"`
 
+	// jti of the system token last issued for this session, revoked on exit or role changes
+	SystemTokenID        string     `json:"-" gorm:"type:varchar(64)"`
+	SystemTokenExpiresAt *time.Time `json:"-"`
+
+	// Thumbprint of the device key when the session was created; tokens of
+	// the session are bound to it
+	DeviceKeyThumbprint string `json:"-" gorm:"type:varchar(64)"`
+
 	OrganizationID  *int64        `json:"organization_id,omitempty"`
 	Organization    *Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
 
+	// Set for sessions created through the OAuth authorization server
+	OAuthClientID *int64       `json:"oauth_client_id,omitempty"`
+	OAuthClient   *OAuthClient `json:"oauth_client,omitempty" gorm:"foreignKey:OAuthClientID"`
+
+	// Set for sessions an administrator opened as this user
+	ImpersonatorID *int64 `json:"impersonator_id,omitempty" gorm:"index"`
+	Impersonator   *User  `json:"impersonator,omitempty" gorm:"foreignKey:ImpersonatorID"`
+
+	// When the user last entered their credentials in this session, at login
+	// or on reauthentication. Unset for OAuth client and impersonation
+	// sessions.
+	AuthenticatedAt *time.Time `json:"authenticated_at,omitempty"`
+
 	IPAddress        string     `json:"ip_address" gorm:"type:varchar(45)"`
 	UserAgent        string     `json:"user_agent" gorm:"type:varchar(500)"`
 	IsActive         *bool      `json:"is_active" gorm:"default:true"`
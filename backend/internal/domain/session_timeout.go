@@ -0,0 +1,46 @@
+package domain
+
+// SessionTimeoutPolicy overrides the global idle timeout and sliding expiry
+// settings for an organization, a device platform or both. The most specific
+// policy wins: organization and platform, then organization, then platform;
+// an exact platform beats a platform class.
+type SessionTimeoutPolicy struct {
+	ID             int64         `json:"id" gorm:"primaryKey;autoIncrement"`
+	OrganizationID *int64        `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+	// Platform is a device platform, a platform class (web, mobile, tablet,
+	// desktop, kiosk, pos) or empty for every platform
+	Platform string `json:"platform" gorm:"type:varchar(50)"`
+	// IdleTimeoutMinutes ends sessions without activity for this long; zero
+	// disables the idle timeout
+	IdleTimeoutMinutes int `json:"idle_timeout_minutes"`
+	// SlidingExpiry pushes the session expiry forward on activity; nil
+	// inherits the global setting
+	SlidingExpiry *bool `json:"sliding_expiry"`
+	IsActive      *bool `json:"is_active" gorm:"default:true"`
+	ExtraFields
+}
+
+func (SessionTimeoutPolicy) TableName() string {
+	return "session_timeout_policies"
+}
+
+// Matches reports whether the policy covers a device platform
+func (p *SessionTimeoutPolicy) Matches(platform DevicePlatform) bool {
+	return p.Platform == "" || p.Platform == string(platform) || p.Platform == platform.Class()
+}
+
+// Specificity ranks policies for platform: organization-specific beats
+// platform-specific, and an exact platform beats its class
+func (p *SessionTimeoutPolicy) Specificity(platform DevicePlatform) int {
+	score := 0
+	if p.OrganizationID != nil {
+		score += 4
+	}
+	if p.Platform == string(platform) {
+		score += 2
+	} else if p.Platform != "" {
+		score++
+	}
+	return score
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+type SigningAlgorithm string
+
+const (
+	SigningAlgorithmHS256 SigningAlgorithm = "HS256"
+	SigningAlgorithmRS256 SigningAlgorithm = "RS256"
+	SigningAlgorithmEdDSA SigningAlgorithm = "EdDSA"
+)
+
+// SigningKey is an asymmetric JWT signing key. Only the newest active key signs;
+// retired keys keep verifying tokens until ExpiresAt so rotation has an overlap window.
+type SigningKey struct {
+	ID            int64            `json:"id" gorm:"primaryKey;autoIncrement"`
+	KID           string           `json:"kid" gorm:"uniqueIndex;type:varchar(64)"`
+	Algorithm     SigningAlgorithm `json:"algorithm" gorm:"type:varchar(10)"`
+	PublicKeyPEM  string           `json:"public_key_pem" gorm:"type:text"`
+	PrivateKeyEnc string           `json:"-" gorm:"type:text"`
+	ActivatedAt   time.Time        `json:"activated_at"`
+	RetiredAt     *time.Time       `json:"retired_at,omitempty"`
+	ExpiresAt     *time.Time       `json:"expires_at,omitempty"`
+	CreatedAt     time.Time        `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
+
+func (k *SigningKey) IsRetired() bool {
+	return k.RetiredAt != nil
+}
@@ -21,7 +21,11 @@ type User struct {
 	AvatarURL    *string    `json:"avatar_url,omitempty" gorm:"type:varchar(500)"`
 	IsActive     *bool      `json:"is_active" gorm:"default:true"`
 	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
+	PasswordChangedAt  *time.Time `json:"password_changed_at,omitempty"`
+	MustChangePassword *bool      `json:"must_change_password" gorm:"default:false"`
 	LanguageCode string     `json:"language_code" gorm:"type:varchar(5);default:'mn'"`
+	// Service accounts have no password and authenticate with access tokens only
+	ServiceAccount *bool `json:"is_service_account" gorm:"default:false"`
 
 	OrganizationID  *int64           `json:"organization_id,omitempty"`
 	Organization    *Organization    `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
@@ -38,6 +42,10 @@ func (User) TableName() string {
 	return "users"
 }
 
+func (u *User) IsServiceAccount() bool {
+	return u.ServiceAccount != nil && *u.ServiceAccount
+}
+
 func (u *User) GetAvailableSystems() []System {
 	systemMap := make(map[int]System)
 	for _, usr := range u.UserSystemRoles {
@@ -0,0 +1,242 @@
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Data section field types
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEndMarker = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// maxDepth bounds nested maps, arrays and pointers so a corrupt file cannot
+// recurse without end
+const maxDepth = 32
+
+var (
+	errOutOfBounds = errors.New("unexpected end of data")
+	errTooDeep     = errors.New("data nested too deep")
+)
+
+// decoder reads values of a data section. Pointers are offsets from the
+// start of buf.
+type decoder struct {
+	buf   []byte
+	depth int
+}
+
+// decode reads the value at offset, returning it and the offset after it.
+// Strings become string, doubles and floats float64, unsigned integers
+// uint16, uint32 or uint64, maps map[string]interface{} and arrays
+// []interface{}. 128-bit integers are skipped and decode as nil.
+func (d *decoder) decode(offset uint) (interface{}, uint, error) {
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		target, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		if d.depth >= maxDepth {
+			return nil, 0, errTooDeep
+		}
+		d.depth++
+		value, _, err := d.decode(target)
+		d.depth--
+		return value, next, err
+	}
+	return d.value(typ, size, offset)
+}
+
+// control reads the control byte of a field, returning its type, size and
+// the offset of its payload
+func (d *decoder) control(offset uint) (int, uint, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, errOutOfBounds
+	}
+	ctrl := d.buf[offset]
+	offset++
+
+	typ := int(ctrl >> 5)
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, errOutOfBounds
+		}
+		typ = 7 + int(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if typ == typePointer {
+		return typ, size, offset, nil
+	}
+
+	extra := uint(0)
+	switch size {
+	case 29:
+		extra = 1
+	case 30:
+		extra = 2
+	case 31:
+		extra = 3
+	}
+	if extra > 0 {
+		if offset+extra > uint(len(d.buf)) {
+			return 0, 0, 0, errOutOfBounds
+		}
+		n := uint(readUint(d.buf[offset : offset+extra]))
+		switch extra {
+		case 1:
+			size = 29 + n
+		case 2:
+			size = 285 + n
+		default:
+			size = 65821 + n
+		}
+		offset += extra
+	}
+	return typ, size, offset, nil
+}
+
+// pointer resolves a pointer field whose control bits were size
+func (d *decoder) pointer(size, offset uint) (uint, uint, error) {
+	n := (size >> 3 & 0x3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errOutOfBounds
+	}
+	raw := uint(readUint(d.buf[offset : offset+n]))
+	value := size & 0x7
+
+	var target uint
+	switch n {
+	case 1:
+		target = value<<8 | raw
+	case 2:
+		target = (value<<16 | raw) + 2048
+	case 3:
+		target = (value<<24 | raw) + 526336
+	default:
+		target = raw
+	}
+	return target, offset + n, nil
+}
+
+func (d *decoder) value(typ int, size, offset uint) (interface{}, uint, error) {
+	end := offset + size
+	switch typ {
+	case typeMap:
+		return d.decodeMap(size, offset)
+	case typeArray:
+		return d.decodeArray(size, offset)
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if end > uint(len(d.buf)) {
+		return nil, 0, errOutOfBounds
+	}
+	payload := d.buf[offset:end]
+
+	switch typ {
+	case typeString:
+		return string(payload), end, nil
+	case typeBytes:
+		return append([]byte(nil), payload...), end, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(readUint(payload)), end, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return float64(math.Float32frombits(uint32(readUint(payload)))), end, nil
+	case typeUint16:
+		return uint16(readUint(payload)), end, nil
+	case typeUint32:
+		return uint32(readUint(payload)), end, nil
+	case typeUint64:
+		return readUint(payload), end, nil
+	case typeInt32:
+		return int32(uint32(readUint(payload))), end, nil
+	case typeUint128:
+		return nil, end, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+}
+
+func (d *decoder) decodeMap(size, offset uint) (interface{}, uint, error) {
+	if d.depth >= maxDepth {
+		return nil, 0, errTooDeep
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	result := make(map[string]interface{})
+	for i := uint(0); i < size; i++ {
+		key, next, err := d.decode(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, 0, errors.New("map key is not a string")
+		}
+		value, next, err := d.decode(next)
+		if err != nil {
+			return nil, 0, err
+		}
+		result[name] = value
+		offset = next
+	}
+	return result, offset, nil
+}
+
+func (d *decoder) decodeArray(size, offset uint) (interface{}, uint, error) {
+	if d.depth >= maxDepth {
+		return nil, 0, errTooDeep
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	var result []interface{}
+	for i := uint(0); i < size; i++ {
+		value, next, err := d.decode(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, value)
+		offset = next
+	}
+	return result, offset, nil
+}
+
+// readUint reads a big-endian unsigned integer of up to 8 bytes
+func readUint(b []byte) uint64 {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
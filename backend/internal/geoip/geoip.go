@@ -0,0 +1,207 @@
+// Package geoip locates IP addresses with an offline MaxMind DB file, such
+// as GeoLite2-City or GeoIP2-City, read fully into memory.
+package geoip
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparator is the run of zero bytes between the search tree and
+// the data section
+const dataSectionSeparator = 16
+
+var ErrInvalidDatabase = errors.New("invalid MaxMind database")
+
+// Location is where an IP address is registered. Country databases have no
+// coordinates.
+type Location struct {
+	Country        string  `json:"country,omitempty"`
+	City           string  `json:"city,omitempty"`
+	Latitude       float64 `json:"latitude,omitempty"`
+	Longitude      float64 `json:"longitude,omitempty"`
+	HasCoordinates bool    `json:"-"`
+}
+
+// Reader looks up addresses in a MaxMind DB. It is safe for concurrent use.
+type Reader struct {
+	buf          []byte
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	treeSize     uint
+	ipv4Start    uint
+	databaseType string
+}
+
+// Open reads a MaxMind DB file
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(buf)
+}
+
+// New reads a MaxMind DB from its contents
+func New(buf []byte) (*Reader, error) {
+	start := bytes.LastIndex(buf, metadataMarker)
+	if start < 0 {
+		return nil, fmt.Errorf("%w: metadata not found", ErrInvalidDatabase)
+	}
+	start += len(metadataMarker)
+
+	meta := &decoder{buf: buf[start:]}
+	value, _, err := meta.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+	}
+	metadata, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", ErrInvalidDatabase)
+	}
+
+	r := &Reader{
+		nodeCount:  toUint(metadata["node_count"]),
+		recordSize: toUint(metadata["record_size"]),
+		ipVersion:  toUint(metadata["ip_version"]),
+	}
+	r.databaseType, _ = metadata["database_type"].(string)
+
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalidDatabase, r.recordSize)
+	}
+	r.treeSize = r.nodeCount * r.recordSize / 4
+	if r.nodeCount == 0 || r.treeSize+dataSectionSeparator > uint(start-len(metadataMarker)) {
+		return nil, fmt.Errorf("%w: search tree out of bounds", ErrInvalidDatabase)
+	}
+	r.buf = buf[:start-len(metadataMarker)]
+
+	// IPv4 addresses live under ::/96 of IPv6 databases
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// DatabaseType is the edition of the database, e.g. GeoLite2-City
+func (r *Reader) DatabaseType() string {
+	return r.databaseType
+}
+
+// Lookup locates an address. It returns nil when the database has no record
+// of it.
+func (r *Reader) Lookup(ip net.IP) (*Location, error) {
+	record, err := r.lookup(ip)
+	if err != nil || record == nil {
+		return nil, err
+	}
+
+	loc := &Location{}
+	if country, ok := record["country"].(map[string]interface{}); ok {
+		loc.Country, _ = country["iso_code"].(string)
+	}
+	if city, ok := record["city"].(map[string]interface{}); ok {
+		if names, ok := city["names"].(map[string]interface{}); ok {
+			loc.City, _ = names["en"].(string)
+		}
+	}
+	if location, ok := record["location"].(map[string]interface{}); ok {
+		lat, latOK := location["latitude"].(float64)
+		lon, lonOK := location["longitude"].(float64)
+		if latOK && lonOK {
+			loc.Latitude, loc.Longitude, loc.HasCoordinates = lat, lon, true
+		}
+	}
+	return loc, nil
+}
+
+func (r *Reader) lookup(ip net.IP) (map[string]interface{}, error) {
+	if ip == nil {
+		return nil, nil
+	}
+
+	node := uint(0)
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.ipVersion == 4 {
+		return nil, nil
+	}
+
+	bits := len(ip) * 8
+	for i := 0; i < bits && node < r.nodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-uint(i%8))) & 1
+		node = r.readNode(node, bit)
+	}
+	if node == r.nodeCount {
+		return nil, nil
+	}
+	if node < r.nodeCount {
+		return nil, fmt.Errorf("%w: search tree too deep", ErrInvalidDatabase)
+	}
+
+	offset := node - r.nodeCount - dataSectionSeparator
+	d := &decoder{buf: r.buf[r.treeSize+dataSectionSeparator:]}
+	value, _, err := d.decode(offset)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+	}
+	record, _ := value.(map[string]interface{})
+	return record, nil
+}
+
+// readNode returns the left (bit 0) or right (bit 1) record of a node
+func (r *Reader) readNode(node, bit uint) uint {
+	b := r.buf[node*r.recordSize/4:]
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		b = b[bit*4:]
+		return uint(b[0])<<24 | uint(b[1])<<16 | uint(b[2])<<8 | uint(b[3])
+	}
+}
+
+// Distance returns the great-circle distance in kilometres between two
+// coordinates
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func toUint(value interface{}) uint {
+	switch v := value.(type) {
+	case uint64:
+		return uint(v)
+	case uint32:
+		return uint(v)
+	case uint16:
+		return uint(v)
+	}
+	return 0
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccessTokenHandler struct {
+	accessTokenService *service.AccessTokenService
+}
+
+func NewAccessTokenHandler(accessTokenService *service.AccessTokenService) *AccessTokenHandler {
+	return &AccessTokenHandler{
+		accessTokenService: accessTokenService,
+	}
+}
+
+// ListMine godoc
+// @Summary List my access tokens
+// @Description List the current user's personal access tokens, including expired and revoked ones
+// @Tags Auth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} response.Response{data=[]domain.PersonalAccessToken}
+// @Failure 401 {object} response.Response
+// @Router /auth/tokens [get]
+func (h *AccessTokenHandler) ListMine(c *gin.Context) {
+	tokens, err := h.accessTokenService.List(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		response.InternalError(c, "Failed to list access tokens")
+		return
+	}
+
+	response.Success(c, tokens)
+}
+
+// CreateMine godoc
+// @Summary Create an access token
+// @Description Create a personal access token for scripts and CI jobs, bound to one system and a subset of the current user's permissions there. The token is only returned in this response. Send it as "Authorization: Bearer <token>"; no device headers are needed. Permissions that require recent authentication answer STEP_UP_REQUIRED unless the session reauthenticated within their window.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.CreateAccessTokenRequest true "Token"
+// @Success 201 {object} response.Response{data=service.AccessTokenCredentials}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /auth/tokens [post]
+func (h *AccessTokenHandler) CreateMine(c *gin.Context) {
+	var req service.CreateAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	result, err := h.accessTokenService.Create(c.Request.Context(), userID, &req, userID, middleware.GetAuthenticatedAt(c))
+	if err != nil {
+		respondAccessTokenError(c, err)
+		return
+	}
+
+	response.Created(c, result)
+}
+
+// RevokeMine godoc
+// @Summary Revoke an access token
+// @Description Revoke one of the current user's personal access tokens
+// @Tags Auth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Token ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /auth/tokens/{id} [delete]
+func (h *AccessTokenHandler) RevokeMine(c *gin.Context) {
+	tokenID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid token ID")
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	if err := h.accessTokenService.Revoke(c.Request.Context(), userID, tokenID, userID); err != nil {
+		respondAccessTokenError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Access token revoked"})
+}
+
+// ListForUser godoc
+// @Summary List user access tokens
+// @Description List the personal access tokens of a user
+// @Tags Users
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "User ID"
+// @Success 200 {object} response.Response{data=[]domain.PersonalAccessToken}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /users/{id}/tokens [get]
+func (h *AccessTokenHandler) ListForUser(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	tokens, err := h.accessTokenService.List(c.Request.Context(), userID)
+	if err != nil {
+		response.InternalError(c, "Failed to list access tokens")
+		return
+	}
+
+	response.Success(c, tokens)
+}
+
+// RevokeForUser godoc
+// @Summary Revoke a user access token
+// @Description Revoke one personal access token of a user
+// @Tags Users
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "User ID"
+// @Param token_id path int true "Token ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /users/{id}/tokens/{token_id} [delete]
+func (h *AccessTokenHandler) RevokeForUser(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+	tokenID, err := strconv.ParseInt(c.Param("token_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid token ID")
+		return
+	}
+
+	if err := h.accessTokenService.Revoke(c.Request.Context(), userID, tokenID, middleware.GetUserID(c)); err != nil {
+		respondAccessTokenError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Access token revoked"})
+}
+
+func respondAccessTokenError(c *gin.Context, err error) {
+	var stepUp *service.StepUpRequiredError
+	if errors.As(err, &stepUp) {
+		middleware.AbortStepUpRequired(c, stepUp.Permission, stepUp.Window)
+		return
+	}
+
+	switch err {
+	case service.ErrAccessTokenNotFound:
+		response.NotFound(c, "Access token not found")
+	case service.ErrUserNotFound:
+		response.NotFound(c, "User not found")
+	case service.ErrServiceAccountNotFound:
+		response.NotFound(c, "Service account not found")
+	case service.ErrSystemNotFound:
+		response.BadRequest(c, "System not found")
+	case service.ErrUserInactive:
+		response.BadRequest(c, "User is inactive")
+	case service.ErrAccessTokenExpiry:
+		response.BadRequest(c, err.Error())
+	case service.ErrAccessTokenPermission:
+		response.Forbidden(c, err.Error())
+	default:
+		response.InternalError(c, "Failed to manage access token")
+	}
+}
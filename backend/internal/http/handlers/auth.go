@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 
 	"gebase/internal/auth"
 	"gebase/internal/http/response"
@@ -12,26 +16,32 @@ import (
 )
 
 type AuthHandler struct {
-	authService       *service.AuthService
-	permissionService *service.PermissionService
-	menuService       *service.MenuService
+	authService          *service.AuthService
+	permissionService    *service.PermissionService
+	menuService          *service.MenuService
+	passwordResetService *service.PasswordResetService
+	impersonationService *service.ImpersonationService
 }
 
 func NewAuthHandler(
 	authService *service.AuthService,
 	permissionService *service.PermissionService,
 	menuService *service.MenuService,
+	passwordResetService *service.PasswordResetService,
+	impersonationService *service.ImpersonationService,
 ) *AuthHandler {
 	return &AuthHandler{
-		authService:       authService,
-		permissionService: permissionService,
-		menuService:       menuService,
+		authService:          authService,
+		permissionService:    permissionService,
+		menuService:          menuService,
+		passwordResetService: passwordResetService,
+		impersonationService: impersonationService,
 	}
 }
 
 // Login godoc
 // @Summary User login
-// @Description Authenticate user and return platform token
+// @Description Authenticate user and return platform token. Logins unusual for the account (new device, network or country, impossible travel, off hours) may return an MFA challenge with risk_step_up set, or be rejected with LOGIN_RISK_BLOCKED.
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -39,6 +49,9 @@ func NewAuthHandler(
 // @Success 200 {object} response.Response{data=service.LoginResponse}
 // @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 503 {object} response.Response
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req service.LoginRequest
@@ -52,6 +65,9 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	result, err := h.authService.Login(c.Request.Context(), &req, ipAddress, userAgent)
 	if err != nil {
+		if respondLoginBlocked(c, err) {
+			return
+		}
 		switch err {
 		case service.ErrInvalidCredentials:
 			response.Unauthorized(c, "Invalid email or password")
@@ -61,6 +77,18 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			response.Error(c, http.StatusBadRequest, "DEVICE_NOT_REGISTERED", "Device is not registered")
 		case service.ErrDeviceNotActive:
 			response.Forbidden(c, "Device is deactivated")
+		case service.ErrDevicePendingApproval:
+			response.Error(c, http.StatusForbidden, "DEVICE_PENDING_APPROVAL", "Device is awaiting administrator approval")
+		case service.ErrDeviceRejected:
+			response.Error(c, http.StatusForbidden, "DEVICE_REJECTED", "Device was rejected by an administrator")
+		case service.ErrDeviceOrganizationMismatch:
+			response.Error(c, http.StatusForbidden, "DEVICE_ORGANIZATION_MISMATCH", "Device belongs to another organization")
+		case service.ErrLoginRiskBlocked:
+			response.Error(c, http.StatusForbidden, "LOGIN_RISK_BLOCKED", "Login blocked as unusual for this account; contact your administrator")
+		case service.ErrDirectoryUnavailable, service.ErrLDAPNotConfigured:
+			response.Error(c, http.StatusServiceUnavailable, "DIRECTORY_UNAVAILABLE", "Directory server is unavailable")
+		case auth.ErrSessionLimitReached:
+			respondSessionLimitReached(c)
 		default:
 			response.InternalError(c, "Login failed")
 		}
@@ -70,6 +98,153 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	response.Success(c, result)
 }
 
+// LoginMFA godoc
+// @Summary Complete MFA login
+// @Description Exchange an MFA challenge token and a TOTP or recovery code for platform tokens
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body service.MFALoginRequest true "MFA token and code"
+// @Success 200 {object} response.Response{data=service.LoginResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /auth/login/mfa [post]
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var req service.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	ipAddress := middleware.GetClientIP(c)
+	userAgent := c.Request.UserAgent()
+
+	result, err := h.authService.CompleteMFALogin(c.Request.Context(), &req, ipAddress, userAgent)
+	if err != nil {
+		if respondLoginBlocked(c, err) {
+			return
+		}
+		switch err {
+		case service.ErrUserInactive:
+			response.Forbidden(c, "User account is inactive")
+		case service.ErrDeviceNotFound:
+			response.Error(c, http.StatusBadRequest, "DEVICE_NOT_REGISTERED", "Device is not registered")
+		case service.ErrDeviceNotActive:
+			response.Forbidden(c, "Device is deactivated")
+		case service.ErrDevicePendingApproval:
+			response.Error(c, http.StatusForbidden, "DEVICE_PENDING_APPROVAL", "Device is awaiting administrator approval")
+		case service.ErrDeviceRejected:
+			response.Error(c, http.StatusForbidden, "DEVICE_REJECTED", "Device was rejected by an administrator")
+		case service.ErrDeviceOrganizationMismatch:
+			response.Error(c, http.StatusForbidden, "DEVICE_ORGANIZATION_MISMATCH", "Device belongs to another organization")
+		case auth.ErrSessionLimitReached:
+			respondSessionLimitReached(c)
+		default:
+			respondMFAError(c, err, "Login failed")
+		}
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// LoginMFAEnroll godoc
+// @Summary Start MFA enrollment during login
+// @Description Start TOTP enrollment for a user whose organization requires MFA
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body map[string]string true "MFA token"
+// @Success 200 {object} response.Response{data=service.MFAEnrollment}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Router /auth/login/mfa/enroll [post]
+func (h *AuthHandler) LoginMFAEnroll(c *gin.Context) {
+	var req struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "MFA token is required")
+		return
+	}
+
+	enrollment, err := h.authService.BeginMFALoginEnrollment(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		respondMFAError(c, err, "Failed to start MFA enrollment")
+		return
+	}
+
+	response.Success(c, enrollment)
+}
+
+// ForgotPassword godoc
+// @Summary Request password reset
+// @Description Email a single-use password reset link. Succeeds for unregistered emails too to avoid revealing them; requests are throttled per email and per client address.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body service.ForgotPasswordRequest true "Account email"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 429 {object} response.Response
+// @Router /auth/forgot-password [post]
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req service.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "A valid email is required")
+		return
+	}
+
+	ipAddress := middleware.GetClientIP(c)
+	if err := h.passwordResetService.RequestReset(c.Request.Context(), req.Email, ipAddress); err != nil {
+		var throttled *service.ResetThrottledError
+		if errors.As(err, &throttled) {
+			retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			response.Error(c, http.StatusTooManyRequests, "TOO_MANY_REQUESTS",
+				fmt.Sprintf("Too many password reset requests. Try again in %d seconds.", retryAfter))
+			return
+		}
+		response.InternalError(c, "Failed to request password reset")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password with a reset token and log out all sessions
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body service.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /auth/reset-password [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req service.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Token and password are required")
+		return
+	}
+
+	if err := h.passwordResetService.ResetPassword(c.Request.Context(), &req); err != nil {
+		if respondPasswordPolicy(c, err) {
+			return
+		}
+		if err == service.ErrInvalidResetToken {
+			response.Error(c, http.StatusBadRequest, "INVALID_RESET_TOKEN", "Password reset link is invalid or has expired")
+			return
+		}
+		response.InternalError(c, "Failed to reset password")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Password has been reset. Please log in again."})
+}
+
 // SwitchSystem godoc
 // @Summary Switch to a system
 // @Description Switch to a specific system and get system token
@@ -115,6 +290,7 @@ func (h *AuthHandler) SwitchSystem(c *gin.Context) {
 // @Tags Auth
 // @Accept json
 // @Produce json
+// @Param X-Device-Proof header string false "Proof signed with the device key, required once the device has a key"
 // @Param request body map[string]string true "Refresh token"
 // @Success 200 {object} response.Response{data=service.LoginResponse}
 // @Failure 400 {object} response.Response
@@ -129,13 +305,23 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	result, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken)
+	result, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken, middleware.GetDeviceProof(c))
 	if err != nil {
-		if err == auth.ErrExpiredToken {
-			response.Unauthorized(c, "Refresh token has expired")
+		if deviceProofError(c, err) {
 			return
 		}
-		response.Unauthorized(c, "Invalid refresh token")
+		switch err {
+		case auth.ErrExpiredToken:
+			response.Unauthorized(c, "Refresh token has expired")
+		case auth.ErrTokenReused:
+			response.Error(c, http.StatusUnauthorized, "REFRESH_TOKEN_REUSED", "Refresh token has already been used. Session has been revoked.")
+		case service.ErrSessionNotFound, service.ErrSessionExpired:
+			response.Error(c, http.StatusUnauthorized, "SESSION_INVALID", "Session is invalid or expired")
+		case auth.ErrSessionIdleTimeout:
+			response.Error(c, http.StatusUnauthorized, "SESSION_IDLE_TIMEOUT", "Session timed out due to inactivity")
+		default:
+			response.Unauthorized(c, "Invalid refresh token")
+		}
 		return
 	}
 
@@ -163,20 +349,71 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	response.Success(c, gin.H{"message": "Logged out successfully"})
 }
 
+// Reauthenticate godoc
+// @Summary Reauthenticate
+// @Description Confirm the password, and a TOTP or recovery code when MFA is enabled, to unlock actions whose permission requires recent authentication. Answers the STEP_UP_REQUIRED challenge; failures count towards the account lockout.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.ReauthenticateRequest true "Credentials"
+// @Success 200 {object} response.Response{data=service.ReauthenticateResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 423 {object} response.Response
+// @Failure 429 {object} response.Response
+// @Failure 503 {object} response.Response
+// @Router /auth/reauthenticate [post]
+func (h *AuthHandler) Reauthenticate(c *gin.Context) {
+	var req service.ReauthenticateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	result, err := h.authService.Reauthenticate(
+		c.Request.Context(),
+		middleware.GetUserID(c),
+		middleware.GetSessionID(c),
+		&req,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+	)
+	if err != nil {
+		if respondLoginBlocked(c, err) {
+			return
+		}
+		switch err {
+		case service.ErrInvalidCredentials:
+			response.Unauthorized(c, "Invalid password")
+		case service.ErrMFACodeRequired:
+			response.Error(c, http.StatusBadRequest, "MFA_CODE_REQUIRED", "A verification code is required")
+		case service.ErrUserInactive:
+			response.Forbidden(c, "User account is inactive")
+		case service.ErrDirectoryUnavailable, service.ErrLDAPNotConfigured:
+			response.Error(c, http.StatusServiceUnavailable, "DIRECTORY_UNAVAILABLE", "Directory server is unavailable")
+		default:
+			respondMFAError(c, err, "Failed to reauthenticate")
+		}
+		return
+	}
+
+	response.Success(c, result)
+}
+
 // Me godoc
 // @Summary Get current user
-// @Description Get current authenticated user info
+// @Description Get current authenticated user info. is_impersonated is set while an administrator acts as the user.
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer token"
-// @Success 200 {object} response.Response
+// @Success 200 {object} response.Response{data=service.CurrentUser}
 // @Failure 401 {object} response.Response
 // @Router /auth/me [get]
 func (h *AuthHandler) Me(c *gin.Context) {
-	userID := middleware.GetUserID(c)
-
-	user, err := h.authService.GetCurrentUser(c.Request.Context(), userID)
+	user, err := h.impersonationService.GetCurrentUser(c.Request.Context(), middleware.GetClaims(c))
 	if err != nil {
 		response.InternalError(c, "Failed to get user info")
 		return
@@ -238,7 +475,7 @@ func (h *AuthHandler) GetMenus(c *gin.Context) {
 
 // ExitSystem godoc
 // @Summary Exit current system
-// @Description Exit current system and return to platform level
+// @Description Exit current system, revoke the system token and return to platform level
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -247,9 +484,13 @@ func (h *AuthHandler) GetMenus(c *gin.Context) {
 // @Failure 401 {object} response.Response
 // @Router /auth/exit-system [post]
 func (h *AuthHandler) ExitSystem(c *gin.Context) {
-	// Exit system simply acknowledges the request
-	// The frontend handles clearing the system context
-	// Session tracking can be updated here if needed
+	claims := middleware.GetClaims(c)
+
+	if err := h.authService.ExitSystem(c.Request.Context(), claims); err != nil {
+		response.InternalError(c, "Failed to exit system")
+		return
+	}
+
 	response.Success(c, gin.H{"message": "Exited system successfully"})
 }
 
@@ -275,3 +516,31 @@ func (h *AuthHandler) GetAvailableSystems(c *gin.Context) {
 	systems := user.GetAvailableSystems()
 	response.Success(c, gin.H{"systems": systems})
 }
+
+// respondLoginBlocked writes the response for a locked or throttled login and
+// reports whether err was one
+func respondLoginBlocked(c *gin.Context, err error) bool {
+	var blocked *service.LoginBlockedError
+	if !errors.As(err, &blocked) {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(blocked.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+
+	if blocked.Err == service.ErrAccountLocked {
+		response.Error(c, http.StatusLocked, "ACCOUNT_LOCKED",
+			fmt.Sprintf("Account is temporarily locked. Try again in %d seconds.", retryAfter))
+	} else {
+		response.Error(c, http.StatusTooManyRequests, "TOO_MANY_ATTEMPTS",
+			fmt.Sprintf("Too many login attempts. Try again in %d seconds.", retryAfter))
+	}
+	return true
+}
+
+// respondSessionLimitReached writes the response for a login rejected by a
+// session limit policy
+func respondSessionLimitReached(c *gin.Context) {
+	response.Error(c, http.StatusConflict, "SESSION_LIMIT_REACHED",
+		"Maximum number of concurrent sessions reached. Log out of another device and try again.")
+}
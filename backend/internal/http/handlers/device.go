@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"net/http"
 	"strconv"
 
+	"gebase/internal/auth"
 	"gebase/internal/http/response"
 	"gebase/internal/middleware"
 	"gebase/internal/service"
@@ -26,9 +28,14 @@ func NewDeviceHandler(deviceService *service.DeviceService) *DeviceHandler {
 // @Tags Devices
 // @Accept json
 // @Produce json
+// @Param Authorization header string false "Bearer token of a session on the device; needed to add a key to a device registered without one"
+// @Param X-Device-Proof header string false "Proof signed with the device key; required with public_key or once the device has a key"
 // @Param request body service.RegisterDeviceRequest true "Device info"
 // @Success 200 {object} response.Response
 // @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
 // @Router /devices/register [post]
 func (h *DeviceHandler) Register(c *gin.Context) {
 	var req service.RegisterDeviceRequest
@@ -37,8 +44,23 @@ func (h *DeviceHandler) Register(c *gin.Context) {
 		return
 	}
 
-	device, err := h.deviceService.RegisterDevice(c.Request.Context(), &req)
+	device, err := h.deviceService.RegisterDevice(c.Request.Context(), &req, middleware.GetDeviceProof(c), middleware.GetClaims(c))
 	if err != nil {
+		if err == service.ErrDeviceKeyPendingApproval {
+			response.Error(c, http.StatusForbidden, "DEVICE_KEY_PENDING_APPROVAL", "Device is registered without a key. Register the key while signed in on the device, or ask an administrator to approve it.")
+			return
+		}
+		if err == auth.ErrInvalidDeviceKey {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		if err == auth.ErrDeviceKeyMismatch {
+			response.Error(c, http.StatusConflict, "DEVICE_KEY_MISMATCH", "Device already has a different key. An administrator must reset it first.")
+			return
+		}
+		if deviceProofError(c, err) {
+			return
+		}
 		response.InternalError(c, "Failed to register device")
 		return
 	}
@@ -48,13 +70,16 @@ func (h *DeviceHandler) Register(c *gin.Context) {
 
 // Heartbeat godoc
 // @Summary Device heartbeat
-// @Description Update device heartbeat timestamp
+// @Description Update device heartbeat timestamp and fetch the device's queued commands
 // @Tags Devices
 // @Accept json
 // @Produce json
 // @Param X-Device-UID header string true "Device UID"
-// @Success 200 {object} response.Response
+// @Param X-Device-Proof header string true "Proof signed with the device key; devices without a key must register one first"
+// @Success 200 {object} response.Response{data=service.HeartbeatResponse}
 // @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
 // @Router /devices/heartbeat [post]
 func (h *DeviceHandler) Heartbeat(c *gin.Context) {
 	deviceUID := c.GetHeader("X-Device-UID")
@@ -63,12 +88,20 @@ func (h *DeviceHandler) Heartbeat(c *gin.Context) {
 		return
 	}
 
-	if err := h.deviceService.Heartbeat(c.Request.Context(), deviceUID); err != nil {
+	result, err := h.deviceService.ReportHeartbeat(c.Request.Context(), deviceUID, middleware.GetDeviceProof(c))
+	if err != nil {
+		if err == service.ErrDeviceNotFound {
+			response.NotFound(c, "Device not found")
+			return
+		}
+		if deviceProofError(c, err) {
+			return
+		}
 		response.InternalError(c, "Failed to update heartbeat")
 		return
 	}
 
-	response.Success(c, gin.H{"message": "Heartbeat recorded"})
+	response.Success(c, result)
 }
 
 // List godoc
@@ -199,66 +232,115 @@ func (h *DeviceHandler) Deactivate(c *gin.Context) {
 	response.Success(c, gin.H{"message": "Device deactivated"})
 }
 
-// UpdateConfig godoc
-// @Summary Update device config
-// @Description Update device remote configuration
+// GetSessions godoc
+// @Summary Get device sessions
+// @Description Get active sessions for a device
 // @Tags Devices
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer token"
 // @Param id path int true "Device ID"
-// @Param request body map[string]interface{} true "Config JSON"
 // @Success 200 {object} response.Response
-// @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
-// @Router /devices/{id}/config [put]
-func (h *DeviceHandler) UpdateConfig(c *gin.Context) {
+// @Router /devices/{id}/sessions [get]
+func (h *DeviceHandler) GetSessions(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		response.BadRequest(c, "Invalid device ID")
 		return
 	}
 
-	var req struct {
-		Config string `json:"config" binding:"required"`
+	sessions, err := h.deviceService.GetDeviceSessions(c.Request.Context(), id)
+	if err != nil {
+		response.InternalError(c, "Failed to get sessions")
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		response.BadRequest(c, "Config is required")
+
+	response.Success(c, gin.H{"sessions": sessions})
+}
+
+// ResetKey godoc
+// @Summary Reset device key
+// @Description Remove the device's proof-of-possession key so it can register a new one. The device's sessions are ended.
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Device ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /devices/{id}/key [delete]
+func (h *DeviceHandler) ResetKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid device ID")
 		return
 	}
 
-	userID := middleware.GetUserID(c)
-	if err := h.deviceService.UpdateDeviceConfig(c.Request.Context(), id, req.Config, userID); err != nil {
-		response.InternalError(c, "Failed to update config")
+	if err := h.deviceService.ResetDeviceKey(c.Request.Context(), id, middleware.GetUserID(c)); err != nil {
+		if err == service.ErrDeviceNotFound {
+			response.NotFound(c, "Device not found")
+			return
+		}
+		response.InternalError(c, "Failed to reset device key")
 		return
 	}
 
-	response.Success(c, gin.H{"message": "Config updated"})
+	response.Success(c, gin.H{"message": "Device key reset"})
 }
 
-// GetSessions godoc
-// @Summary Get device sessions
-// @Description Get active sessions for a device
+// ApproveKey godoc
+// @Summary Approve device key
+// @Description Bind the key registered for a device without one by a caller that was not signed in on it. The device's sessions are ended.
 // @Tags Devices
-// @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer token"
 // @Param id path int true "Device ID"
-// @Success 200 {object} response.Response
+// @Success 200 {object} response.Response{data=domain.Device}
 // @Failure 401 {object} response.Response
-// @Router /devices/{id}/sessions [get]
-func (h *DeviceHandler) GetSessions(c *gin.Context) {
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /devices/{id}/key/approve [post]
+func (h *DeviceHandler) ApproveKey(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		response.BadRequest(c, "Invalid device ID")
 		return
 	}
 
-	sessions, err := h.deviceService.GetDeviceSessions(c.Request.Context(), id)
+	device, err := h.deviceService.ApproveDeviceKey(c.Request.Context(), id, middleware.GetUserID(c))
 	if err != nil {
-		response.InternalError(c, "Failed to get sessions")
+		if err == service.ErrDeviceNotFound {
+			response.NotFound(c, "Device not found")
+			return
+		}
+		if err == service.ErrNoPendingDeviceKey {
+			response.Conflict(c, "Device has no key awaiting approval")
+			return
+		}
+		response.InternalError(c, "Failed to approve device key")
 		return
 	}
 
-	response.Success(c, gin.H{"sessions": sessions})
+	response.Success(c, device)
+}
+
+// deviceProofError responds to a failed device proof check and reports
+// whether err was one
+func deviceProofError(c *gin.Context, err error) bool {
+	switch err {
+	case auth.ErrDeviceProofRequired:
+		response.Error(c, http.StatusUnauthorized, "DEVICE_PROOF_REQUIRED", "X-Device-Proof header is required for this device")
+	case auth.ErrInvalidDeviceProof:
+		response.Error(c, http.StatusUnauthorized, "DEVICE_PROOF_INVALID", "Device proof is invalid or expired")
+	case auth.ErrDeviceProofReplayed:
+		response.Error(c, http.StatusUnauthorized, "DEVICE_PROOF_REPLAYED", "Device proof has already been used")
+	case auth.ErrDeviceKeyMismatch, auth.ErrDeviceMismatch:
+		response.Error(c, http.StatusUnauthorized, "TOKEN_DEVICE_MISMATCH", "Token was not issued to this device")
+	case auth.ErrDeviceKeyRequired:
+		response.Error(c, http.StatusForbidden, "DEVICE_KEY_REQUIRED", "Device must register a public key")
+	default:
+		return false
+	}
+	return true
 }
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"strconv"
+
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeviceCommandHandler struct {
+	commandService *service.DeviceCommandService
+	deviceService  *service.DeviceService
+}
+
+func NewDeviceCommandHandler(commandService *service.DeviceCommandService, deviceService *service.DeviceService) *DeviceCommandHandler {
+	return &DeviceCommandHandler{
+		commandService: commandService,
+		deviceService:  deviceService,
+	}
+}
+
+// Enqueue godoc
+// @Summary Send device command
+// @Description Queue a remote command (lock, logout_all, wipe_cache, reload_config, update_app) for a device. It is delivered with the device's next heartbeat. logout_all also ends the device's sessions immediately.
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Device ID"
+// @Param request body service.EnqueueDeviceCommandRequest true "Command"
+// @Success 201 {object} response.Response{data=domain.DeviceCommand}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /devices/{id}/commands [post]
+func (h *DeviceCommandHandler) Enqueue(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid device ID")
+		return
+	}
+
+	var req service.EnqueueDeviceCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	command, err := h.commandService.Enqueue(c.Request.Context(), id, &req, middleware.GetUserID(c))
+	if err != nil {
+		respondDeviceCommandError(c, err, "Failed to send device command")
+		return
+	}
+
+	response.Created(c, command)
+}
+
+// List godoc
+// @Summary List device commands
+// @Description List the commands sent to a device with their delivery and result, newest first
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Device ID"
+// @Param status query string false "Command status (pending, delivered, succeeded, failed, expired, cancelled)"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.DeviceCommand}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /devices/{id}/commands [get]
+func (h *DeviceCommandHandler) List(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid device ID")
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.commandService.List(c.Request.Context(), id, c.Query("status"), page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list device commands")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// Cancel godoc
+// @Summary Cancel device command
+// @Description Withdraw a command the device has not acknowledged yet
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Device ID"
+// @Param command_id path int true "Command ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /devices/{id}/commands/{command_id} [delete]
+func (h *DeviceCommandHandler) Cancel(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid device ID")
+		return
+	}
+	commandID, err := strconv.ParseInt(c.Param("command_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid command ID")
+		return
+	}
+
+	if err := h.commandService.Cancel(c.Request.Context(), id, commandID, middleware.GetUserID(c)); err != nil {
+		respondDeviceCommandError(c, err, "Failed to cancel device command")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Device command cancelled"})
+}
+
+// Acknowledge godoc
+// @Summary Acknowledge device command
+// @Description Called by the device to report the result of a command it received with a heartbeat
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param X-Device-UID header string true "Device UID"
+// @Param X-Device-Proof header string true "Proof signed with the device key; devices without a key must register one first"
+// @Param command_id path int true "Command ID"
+// @Param request body service.AcknowledgeDeviceCommandRequest true "Command result"
+// @Success 200 {object} response.Response{data=domain.DeviceCommand}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /devices/commands/{command_id}/ack [post]
+func (h *DeviceCommandHandler) Acknowledge(c *gin.Context) {
+	deviceUID := c.GetHeader("X-Device-UID")
+	if deviceUID == "" {
+		response.BadRequest(c, "X-Device-UID header is required")
+		return
+	}
+	commandID, err := strconv.ParseInt(c.Param("command_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid command ID")
+		return
+	}
+
+	var req service.AcknowledgeDeviceCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	command, err := h.deviceService.AcknowledgeCommand(c.Request.Context(), deviceUID, commandID, &req, middleware.GetDeviceProof(c))
+	if err != nil {
+		if deviceProofError(c, err) {
+			return
+		}
+		respondDeviceCommandError(c, err, "Failed to acknowledge device command")
+		return
+	}
+
+	response.Success(c, command)
+}
+
+func respondDeviceCommandError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrDeviceNotFound:
+		response.NotFound(c, "Device not found")
+	case service.ErrDeviceCommandNotFound:
+		response.NotFound(c, "Device command not found")
+	case service.ErrInvalidDeviceCommand, service.ErrInvalidDeviceCommandResult:
+		response.BadRequest(c, err.Error())
+	case service.ErrDeviceCommandClosed:
+		response.Conflict(c, err.Error())
+	default:
+		response.InternalError(c, fallback)
+	}
+}
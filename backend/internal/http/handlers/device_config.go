@@ -0,0 +1,482 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeviceConfigHandler struct {
+	configService *service.DeviceConfigService
+	deviceService *service.DeviceService
+}
+
+func NewDeviceConfigHandler(configService *service.DeviceConfigService, deviceService *service.DeviceService) *DeviceConfigHandler {
+	return &DeviceConfigHandler{
+		configService: configService,
+		deviceService: deviceService,
+	}
+}
+
+// Fetch godoc
+// @Summary Fetch device config
+// @Description Called by the device to fetch its effective config: its organization's profile, overlaid with its platform's profile and its own overrides. The heartbeat response carries the config version so the device knows when to fetch again.
+// @Tags Devices
+// @Produce json
+// @Param X-Device-UID header string true "Device UID"
+// @Param X-Device-Proof header string true "Proof signed with the device key; devices without a key must register one first"
+// @Success 200 {object} response.Response{data=service.EffectiveDeviceConfig}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /devices/config [get]
+func (h *DeviceConfigHandler) Fetch(c *gin.Context) {
+	deviceUID := c.GetHeader("X-Device-UID")
+	if deviceUID == "" {
+		response.BadRequest(c, "X-Device-UID header is required")
+		return
+	}
+
+	config, err := h.deviceService.FetchConfig(c.Request.Context(), deviceUID, middleware.GetDeviceProof(c))
+	if err != nil {
+		if deviceProofError(c, err) {
+			return
+		}
+		respondDeviceConfigError(c, err, "Failed to fetch device config")
+		return
+	}
+
+	response.Success(c, config)
+}
+
+// GetDeviceConfig godoc
+// @Summary Get effective device config
+// @Description Get the effective config of a device with the profile versions it is made of
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Device ID"
+// @Success 200 {object} response.Response{data=service.EffectiveDeviceConfig}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /devices/{id}/config [get]
+func (h *DeviceConfigHandler) GetDeviceConfig(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid device ID")
+		return
+	}
+
+	config, err := h.configService.GetEffectiveConfig(c.Request.Context(), id)
+	if err != nil {
+		respondDeviceConfigError(c, err, "Failed to get device config")
+		return
+	}
+
+	response.Success(c, config)
+}
+
+// SetDeviceConfig godoc
+// @Summary Set device config overrides
+// @Description Create or replace the config overrides of one device, applied over its organization and platform profiles. Each change is recorded as a new version.
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Device ID"
+// @Param request body service.UpdateDeviceConfigRequest true "Config overrides"
+// @Success 200 {object} response.Response{data=domain.DeviceConfigProfile}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /devices/{id}/config [put]
+func (h *DeviceConfigHandler) SetDeviceConfig(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid device ID")
+		return
+	}
+
+	var req service.UpdateDeviceConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Config is required")
+		return
+	}
+
+	profile, err := h.configService.SetDeviceOverrides(c.Request.Context(), id, &req, middleware.GetUserID(c))
+	if err != nil {
+		respondDeviceConfigError(c, err, "Failed to update device config")
+		return
+	}
+
+	response.Success(c, profile)
+}
+
+// ListProfiles godoc
+// @Summary List device config profiles
+// @Description List organization, platform and device config profiles
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param scope query string false "Profile scope (organization, platform, device)"
+// @Param organization_id query int false "Organization ID"
+// @Param platform query string false "Device platform"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.DeviceConfigProfile}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /devices/config-profiles [get]
+func (h *DeviceConfigHandler) ListProfiles(c *gin.Context) {
+	var orgID *int64
+	if value := c.Query("organization_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			response.BadRequest(c, "Invalid organization ID")
+			return
+		}
+		orgID = &id
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.configService.ListProfiles(c.Request.Context(), c.Query("scope"), orgID, c.Query("platform"), page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list device config profiles")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// CreateProfile godoc
+// @Summary Create device config profile
+// @Description Create the config profile of an organization, a platform or a device. The effective configs it leads to must satisfy the platform schemas.
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.CreateDeviceConfigProfileRequest true "Profile"
+// @Success 201 {object} response.Response{data=domain.DeviceConfigProfile}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /devices/config-profiles [post]
+func (h *DeviceConfigHandler) CreateProfile(c *gin.Context) {
+	var req service.CreateDeviceConfigProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	profile, err := h.configService.CreateProfile(c.Request.Context(), &req, middleware.GetUserID(c))
+	if err != nil {
+		respondDeviceConfigError(c, err, "Failed to create device config profile")
+		return
+	}
+
+	response.Created(c, profile)
+}
+
+// GetProfile godoc
+// @Summary Get device config profile
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Profile ID"
+// @Success 200 {object} response.Response{data=domain.DeviceConfigProfile}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /devices/config-profiles/{id} [get]
+func (h *DeviceConfigHandler) GetProfile(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid profile ID")
+		return
+	}
+
+	profile, err := h.configService.GetProfile(c.Request.Context(), id)
+	if err != nil {
+		respondDeviceConfigError(c, err, "Failed to get device config profile")
+		return
+	}
+
+	response.Success(c, profile)
+}
+
+// UpdateProfile godoc
+// @Summary Update device config profile
+// @Description Replace the config of a profile, recording a new version with its diff
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Profile ID"
+// @Param request body service.UpdateDeviceConfigRequest true "Config"
+// @Success 200 {object} response.Response{data=domain.DeviceConfigProfile}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /devices/config-profiles/{id} [put]
+func (h *DeviceConfigHandler) UpdateProfile(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid profile ID")
+		return
+	}
+
+	var req service.UpdateDeviceConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	profile, err := h.configService.UpdateProfile(c.Request.Context(), id, &req, middleware.GetUserID(c))
+	if err != nil {
+		respondDeviceConfigError(c, err, "Failed to update device config profile")
+		return
+	}
+
+	response.Success(c, profile)
+}
+
+// DeleteProfile godoc
+// @Summary Delete device config profile
+// @Description Delete a config profile; its version history is kept
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Profile ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /devices/config-profiles/{id} [delete]
+func (h *DeviceConfigHandler) DeleteProfile(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid profile ID")
+		return
+	}
+
+	if err := h.configService.DeleteProfile(c.Request.Context(), id, middleware.GetUserID(c)); err != nil {
+		respondDeviceConfigError(c, err, "Failed to delete device config profile")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Device config profile deleted"})
+}
+
+// ListVersions godoc
+// @Summary List device config profile versions
+// @Description List the versions of a profile with their diffs, newest first
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Profile ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.DeviceConfigVersion}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /devices/config-profiles/{id}/versions [get]
+func (h *DeviceConfigHandler) ListVersions(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid profile ID")
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.configService.ListVersions(c.Request.Context(), id, page, pageSize)
+	if err != nil {
+		respondDeviceConfigError(c, err, "Failed to list device config versions")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// Rollback godoc
+// @Summary Roll back device config profile
+// @Description Restore the config of an earlier version. The rollback is recorded as a new version.
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Profile ID"
+// @Param request body service.RollbackDeviceConfigRequest true "Version to restore"
+// @Success 200 {object} response.Response{data=domain.DeviceConfigProfile}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /devices/config-profiles/{id}/rollback [post]
+func (h *DeviceConfigHandler) Rollback(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid profile ID")
+		return
+	}
+
+	var req service.RollbackDeviceConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Version is required")
+		return
+	}
+
+	profile, err := h.configService.Rollback(c.Request.Context(), id, &req, middleware.GetUserID(c))
+	if err != nil {
+		respondDeviceConfigError(c, err, "Failed to roll back device config profile")
+		return
+	}
+
+	response.Success(c, profile)
+}
+
+// ListSchemas godoc
+// @Summary List device config schemas
+// @Description List the JSON Schemas device configs are validated against, one per platform
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} response.Response{data=[]domain.DeviceConfigSchema}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /devices/config-schemas [get]
+func (h *DeviceConfigHandler) ListSchemas(c *gin.Context) {
+	schemas, err := h.configService.ListSchemas(c.Request.Context())
+	if err != nil {
+		response.InternalError(c, "Failed to list device config schemas")
+		return
+	}
+
+	response.Success(c, schemas)
+}
+
+// GetSchema godoc
+// @Summary Get device config schema
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param platform path string true "Device platform"
+// @Success 200 {object} response.Response{data=domain.DeviceConfigSchema}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /devices/config-schemas/{platform} [get]
+func (h *DeviceConfigHandler) GetSchema(c *gin.Context) {
+	schema, err := h.configService.GetSchema(c.Request.Context(), c.Param("platform"))
+	if err != nil {
+		respondDeviceConfigError(c, err, "Failed to get device config schema")
+		return
+	}
+
+	response.Success(c, schema)
+}
+
+// PutSchema godoc
+// @Summary Set device config schema
+// @Description Set the JSON Schema the effective configs of a platform must satisfy. The request body is the schema itself. Existing organization and platform profiles must satisfy it.
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param platform path string true "Device platform"
+// @Param request body object true "JSON Schema"
+// @Success 200 {object} response.Response{data=domain.DeviceConfigSchema}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /devices/config-schemas/{platform} [put]
+func (h *DeviceConfigHandler) PutSchema(c *gin.Context) {
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil || !json.Valid(raw) {
+		response.BadRequest(c, "Request body must be a JSON Schema")
+		return
+	}
+
+	schema, err := h.configService.PutSchema(c.Request.Context(), c.Param("platform"), raw, middleware.GetUserID(c))
+	if err != nil {
+		respondDeviceConfigError(c, err, "Failed to set device config schema")
+		return
+	}
+
+	response.Success(c, schema)
+}
+
+// DeleteSchema godoc
+// @Summary Delete device config schema
+// @Description Stop validating the configs of a platform
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param platform path string true "Device platform"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /devices/config-schemas/{platform} [delete]
+func (h *DeviceConfigHandler) DeleteSchema(c *gin.Context) {
+	if err := h.configService.DeleteSchema(c.Request.Context(), c.Param("platform")); err != nil {
+		respondDeviceConfigError(c, err, "Failed to delete device config schema")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Device config schema deleted"})
+}
+
+func respondDeviceConfigError(c *gin.Context, err error, fallback string) {
+	var validationErr *service.DeviceConfigValidationError
+	if errors.As(err, &validationErr) {
+		details := make([]response.FieldError, len(validationErr.Errors))
+		for i, e := range validationErr.Errors {
+			details[i] = response.FieldError{Field: e.Path, Message: e.Message}
+		}
+		response.ErrorWithDetails(c, http.StatusBadRequest, "DEVICE_CONFIG_INVALID", validationErr.Error(), details)
+		return
+	}
+	var schemaErr *service.InvalidDeviceConfigSchemaError
+	if errors.As(err, &schemaErr) {
+		response.BadRequest(c, schemaErr.Error())
+		return
+	}
+
+	switch err {
+	case service.ErrDeviceNotFound:
+		response.NotFound(c, "Device not found")
+	case service.ErrOrganizationNotFound:
+		response.NotFound(c, "Organization not found")
+	case service.ErrDeviceConfigProfileNotFound, service.ErrDeviceConfigVersionNotFound, service.ErrDeviceConfigSchemaNotFound:
+		response.NotFound(c, err.Error())
+	case service.ErrInvalidDeviceConfigProfile, service.ErrInvalidDeviceConfig, service.ErrInvalidDevicePlatform:
+		response.BadRequest(c, err.Error())
+	case service.ErrDeviceConfigProfileExists, service.ErrDeviceConfigConflict:
+		response.Conflict(c, err.Error())
+	default:
+		response.InternalError(c, fallback)
+	}
+}
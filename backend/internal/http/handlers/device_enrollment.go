@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"strconv"
+
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeviceEnrollmentHandler struct {
+	enrollmentService *service.DeviceEnrollmentService
+}
+
+func NewDeviceEnrollmentHandler(enrollmentService *service.DeviceEnrollmentService) *DeviceEnrollmentHandler {
+	return &DeviceEnrollmentHandler{
+		enrollmentService: enrollmentService,
+	}
+}
+
+// ListPending godoc
+// @Summary List devices awaiting approval
+// @Description List registered devices waiting for an administrator's approval, optionally of one organization
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param organization_id query int false "Organization ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.Device}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /devices/pending [get]
+func (h *DeviceEnrollmentHandler) ListPending(c *gin.Context) {
+	var orgID *int64
+	if value := c.Query("organization_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			response.BadRequest(c, "Invalid organization ID")
+			return
+		}
+		orgID = &id
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.enrollmentService.ListPending(c.Request.Context(), orgID, page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list pending devices")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// Approve godoc
+// @Summary Approve device
+// @Description Approve a pending or rejected device so users can sign in on it
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Device ID"
+// @Success 200 {object} response.Response{data=domain.Device}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /devices/{id}/approve [post]
+func (h *DeviceEnrollmentHandler) Approve(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid device ID")
+		return
+	}
+
+	device, err := h.enrollmentService.Approve(c.Request.Context(), id, middleware.GetUserID(c))
+	if err != nil {
+		respondDeviceEnrollmentError(c, err, "Failed to approve device")
+		return
+	}
+
+	response.Success(c, device)
+}
+
+// Reject godoc
+// @Summary Reject device
+// @Description Reject a device. Users cannot sign in on it and its active sessions are ended.
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Device ID"
+// @Param request body service.RejectDeviceRequest false "Rejection reason"
+// @Success 200 {object} response.Response{data=domain.Device}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /devices/{id}/reject [post]
+func (h *DeviceEnrollmentHandler) Reject(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid device ID")
+		return
+	}
+
+	var req service.RejectDeviceRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, "Invalid request body")
+			return
+		}
+	}
+
+	device, err := h.enrollmentService.Reject(c.Request.Context(), id, &req, middleware.GetUserID(c))
+	if err != nil {
+		respondDeviceEnrollmentError(c, err, "Failed to reject device")
+		return
+	}
+
+	response.Success(c, device)
+}
+
+// ListPolicies godoc
+// @Summary List device enrollment policies
+// @Description List the policies choosing between automatic and manual device approval, optionally for one organization
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param organization_id query int false "Organization ID"
+// @Success 200 {object} response.Response{data=[]domain.DeviceEnrollmentPolicy}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /devices/enrollment-policies [get]
+func (h *DeviceEnrollmentHandler) ListPolicies(c *gin.Context) {
+	var orgID *int64
+	if value := c.Query("organization_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			response.BadRequest(c, "Invalid organization ID")
+			return
+		}
+		orgID = &id
+	}
+
+	policies, err := h.enrollmentService.ListPolicies(c.Request.Context(), orgID)
+	if err != nil {
+		response.InternalError(c, "Failed to list device enrollment policies")
+		return
+	}
+
+	response.Success(c, policies)
+}
+
+// CreatePolicy godoc
+// @Summary Create device enrollment policy
+// @Description Choose automatic or manual approval of new devices for an organization, a device platform or both
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.DeviceEnrollmentPolicyRequest true "Device enrollment policy"
+// @Success 201 {object} response.Response{data=domain.DeviceEnrollmentPolicy}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /devices/enrollment-policies [post]
+func (h *DeviceEnrollmentHandler) CreatePolicy(c *gin.Context) {
+	var req service.DeviceEnrollmentPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	policy, err := h.enrollmentService.CreatePolicy(c.Request.Context(), &req, middleware.GetUserID(c))
+	if err != nil {
+		respondDeviceEnrollmentError(c, err, "Failed to create device enrollment policy")
+		return
+	}
+
+	response.Created(c, policy)
+}
+
+// UpdatePolicy godoc
+// @Summary Update device enrollment policy
+// @Description Replace a device enrollment policy. Devices already registered keep their approval status.
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Policy ID"
+// @Param request body service.DeviceEnrollmentPolicyRequest true "Device enrollment policy"
+// @Success 200 {object} response.Response{data=domain.DeviceEnrollmentPolicy}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /devices/enrollment-policies/{id} [put]
+func (h *DeviceEnrollmentHandler) UpdatePolicy(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid policy ID")
+		return
+	}
+
+	var req service.DeviceEnrollmentPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	policy, err := h.enrollmentService.UpdatePolicy(c.Request.Context(), id, &req, middleware.GetUserID(c))
+	if err != nil {
+		respondDeviceEnrollmentError(c, err, "Failed to update device enrollment policy")
+		return
+	}
+
+	response.Success(c, policy)
+}
+
+// DeletePolicy godoc
+// @Summary Delete device enrollment policy
+// @Description Delete a device enrollment policy
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Policy ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /devices/enrollment-policies/{id} [delete]
+func (h *DeviceEnrollmentHandler) DeletePolicy(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid policy ID")
+		return
+	}
+
+	if err := h.enrollmentService.DeletePolicy(c.Request.Context(), id); err != nil {
+		respondDeviceEnrollmentError(c, err, "Failed to delete device enrollment policy")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Device enrollment policy deleted"})
+}
+
+func respondDeviceEnrollmentError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrDeviceNotFound:
+		response.NotFound(c, "Device not found")
+	case service.ErrDeviceApprovalUnchanged:
+		response.Conflict(c, err.Error())
+	case service.ErrDeviceEnrollmentPolicyNotFound:
+		response.NotFound(c, "Device enrollment policy not found")
+	case service.ErrInvalidDeviceEnrollmentPolicy:
+		response.BadRequest(c, err.Error())
+	case service.ErrOrganizationNotFound:
+		response.BadRequest(c, "Organization not found")
+	default:
+		response.InternalError(c, fallback)
+	}
+}
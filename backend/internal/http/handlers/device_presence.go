@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultUptimeRange is the period of uptime reports without a from date
+const defaultUptimeRange = 30 * 24 * time.Hour
+
+type DevicePresenceHandler struct {
+	presenceService *service.DevicePresenceService
+}
+
+func NewDevicePresenceHandler(presenceService *service.DevicePresenceService) *DevicePresenceHandler {
+	return &DevicePresenceHandler{
+		presenceService: presenceService,
+	}
+}
+
+// Stats godoc
+// @Summary Device fleet statistics
+// @Description Count active devices and how many are online by platform, organization and app version, with the number of open silence alerts
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param organization_id query int false "Organization ID"
+// @Success 200 {object} response.Response{data=service.DeviceFleetStats}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /devices/stats [get]
+func (h *DevicePresenceHandler) Stats(c *gin.Context) {
+	orgID, ok := organizationQuery(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.presenceService.Stats(c.Request.Context(), orgID)
+	if err != nil {
+		response.InternalError(c, "Failed to get device statistics")
+		return
+	}
+
+	response.Success(c, stats)
+}
+
+// ListOnline godoc
+// @Summary List online devices
+// @Description List the active devices currently online, optionally of one organization or platform
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param organization_id query int false "Organization ID"
+// @Param platform query string false "Platform"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.Device}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /devices/online [get]
+func (h *DevicePresenceHandler) ListOnline(c *gin.Context) {
+	orgID, ok := organizationQuery(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.presenceService.ListOnline(c.Request.Context(), orgID, c.Query("platform"), page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list online devices")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// ListEvents godoc
+// @Summary Device presence history
+// @Description List the times a device went online and offline, newest first
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Device ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.DevicePresenceEvent}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /devices/{id}/presence [get]
+func (h *DevicePresenceHandler) ListEvents(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid device ID")
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.presenceService.ListEvents(c.Request.Context(), id, page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list device presence events")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// ListAlerts godoc
+// @Summary List device silence alerts
+// @Description List the alerts raised for POS and kiosk devices that stopped sending heartbeats, newest first
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param organization_id query int false "Organization ID"
+// @Param open query bool false "Only alerts not resolved yet"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.DeviceSilenceAlert}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /devices/alerts [get]
+func (h *DevicePresenceHandler) ListAlerts(c *gin.Context) {
+	orgID, ok := organizationQuery(c)
+	if !ok {
+		return
+	}
+	openOnly := c.Query("open") == "true"
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.presenceService.ListAlerts(c.Request.Context(), orgID, openOnly, page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list device alerts")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// DeviceUptime godoc
+// @Summary Device uptime
+// @Description Report how long a device was online over a period, with its outages. Time before the device's first heartbeat is not counted.
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Device ID"
+// @Param from query string false "Start of the period (RFC 3339), 30 days before to by default"
+// @Param to query string false "End of the period (RFC 3339), now by default"
+// @Success 200 {object} response.Response{data=service.DeviceUptime}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /devices/{id}/uptime [get]
+func (h *DevicePresenceHandler) DeviceUptime(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid device ID")
+		return
+	}
+	from, to, ok := uptimeRange(c)
+	if !ok {
+		return
+	}
+
+	uptime, err := h.presenceService.DeviceUptime(c.Request.Context(), id, from, to)
+	if err != nil {
+		respondDevicePresenceError(c, err, "Failed to get device uptime")
+		return
+	}
+
+	response.Success(c, uptime)
+}
+
+// FleetUptime godoc
+// @Summary Fleet uptime
+// @Description Report how long the active devices of an organization, a platform or both were online over a period, for SLA reporting
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param organization_id query int false "Organization ID"
+// @Param platform query string false "Platform"
+// @Param from query string false "Start of the period (RFC 3339), 30 days before to by default"
+// @Param to query string false "End of the period (RFC 3339), now by default"
+// @Success 200 {object} response.Response{data=service.FleetUptime}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /devices/uptime [get]
+func (h *DevicePresenceHandler) FleetUptime(c *gin.Context) {
+	orgID, ok := organizationQuery(c)
+	if !ok {
+		return
+	}
+	from, to, ok := uptimeRange(c)
+	if !ok {
+		return
+	}
+
+	uptime, err := h.presenceService.FleetUptime(c.Request.Context(), orgID, c.Query("platform"), from, to)
+	if err != nil {
+		respondDevicePresenceError(c, err, "Failed to get fleet uptime")
+		return
+	}
+
+	response.Success(c, uptime)
+}
+
+// ListPolicies godoc
+// @Summary List device presence policies
+// @Description List the policies setting how long POS and kiosk devices may stay silent before administrators are alerted
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param organization_id query int false "Organization ID"
+// @Success 200 {object} response.Response{data=[]domain.DevicePresencePolicy}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /devices/presence-policies [get]
+func (h *DevicePresenceHandler) ListPolicies(c *gin.Context) {
+	orgID, ok := organizationQuery(c)
+	if !ok {
+		return
+	}
+
+	policies, err := h.presenceService.ListPolicies(c.Request.Context(), orgID)
+	if err != nil {
+		response.InternalError(c, "Failed to list device presence policies")
+		return
+	}
+
+	response.Success(c, policies)
+}
+
+// CreatePolicy godoc
+// @Summary Create device presence policy
+// @Description Set how many minutes the POS and kiosk devices of an organization may stay silent before administrators are alerted; 0 turns the alerts off
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.DevicePresencePolicyRequest true "Device presence policy"
+// @Success 201 {object} response.Response{data=domain.DevicePresencePolicy}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /devices/presence-policies [post]
+func (h *DevicePresenceHandler) CreatePolicy(c *gin.Context) {
+	var req service.DevicePresencePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	policy, err := h.presenceService.CreatePolicy(c.Request.Context(), &req, middleware.GetUserID(c))
+	if err != nil {
+		respondDevicePresenceError(c, err, "Failed to create device presence policy")
+		return
+	}
+
+	response.Created(c, policy)
+}
+
+// UpdatePolicy godoc
+// @Summary Update device presence policy
+// @Description Replace a device presence policy. Its organization cannot change.
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Policy ID"
+// @Param request body service.DevicePresencePolicyRequest true "Device presence policy"
+// @Success 200 {object} response.Response{data=domain.DevicePresencePolicy}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /devices/presence-policies/{id} [put]
+func (h *DevicePresenceHandler) UpdatePolicy(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid policy ID")
+		return
+	}
+
+	var req service.DevicePresencePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	policy, err := h.presenceService.UpdatePolicy(c.Request.Context(), id, &req, middleware.GetUserID(c))
+	if err != nil {
+		respondDevicePresenceError(c, err, "Failed to update device presence policy")
+		return
+	}
+
+	response.Success(c, policy)
+}
+
+// DeletePolicy godoc
+// @Summary Delete device presence policy
+// @Description Delete a device presence policy; the organization falls back to the default silence threshold
+// @Tags Devices
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Policy ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /devices/presence-policies/{id} [delete]
+func (h *DevicePresenceHandler) DeletePolicy(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid policy ID")
+		return
+	}
+
+	if err := h.presenceService.DeletePolicy(c.Request.Context(), id); err != nil {
+		respondDevicePresenceError(c, err, "Failed to delete device presence policy")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Device presence policy deleted"})
+}
+
+// organizationQuery parses the optional organization_id query parameter,
+// responding 400 when it is invalid
+func organizationQuery(c *gin.Context) (*int64, bool) {
+	value := c.Query("organization_id")
+	if value == "" {
+		return nil, true
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid organization ID")
+		return nil, false
+	}
+	return &id, true
+}
+
+// uptimeRange parses the from and to query parameters of uptime reports,
+// responding 400 when they are invalid
+func uptimeRange(c *gin.Context) (time.Time, time.Time, bool) {
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			response.BadRequest(c, "Invalid to date, expected RFC 3339")
+			return time.Time{}, time.Time{}, false
+		}
+		to = parsed
+	}
+	from := to.Add(-defaultUptimeRange)
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			response.BadRequest(c, "Invalid from date, expected RFC 3339")
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed
+	}
+	return from, to, true
+}
+
+func respondDevicePresenceError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrDeviceNotFound:
+		response.NotFound(c, "Device not found")
+	case service.ErrOrganizationNotFound:
+		response.NotFound(c, "Organization not found")
+	case service.ErrDevicePresencePolicyNotFound:
+		response.NotFound(c, "Device presence policy not found")
+	case service.ErrDevicePresencePolicyExists:
+		response.Conflict(c, err.Error())
+	case service.ErrInvalidUptimeRange:
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalError(c, fallback)
+	}
+}
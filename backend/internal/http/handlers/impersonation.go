@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"strconv"
+
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImpersonationHandler struct {
+	impersonationService *service.ImpersonationService
+}
+
+func NewImpersonationHandler(impersonationService *service.ImpersonationService) *ImpersonationHandler {
+	return &ImpersonationHandler{
+		impersonationService: impersonationService,
+	}
+}
+
+// Start godoc
+// @Summary Impersonate a user
+// @Description Open a short-lived session as the user to see exactly what they see. Destructive permissions are withheld and the start is audited.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "User ID"
+// @Param request body service.StartImpersonationRequest true "Reason"
+// @Success 200 {object} response.Response{data=service.LoginResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /users/{id}/impersonate [post]
+func (h *ImpersonationHandler) Start(c *gin.Context) {
+	targetID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	var req service.StartImpersonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Reason is required")
+		return
+	}
+
+	result, err := h.impersonationService.Start(
+		c.Request.Context(),
+		middleware.GetClaims(c),
+		targetID,
+		&req,
+		middleware.GetClientIP(c),
+		c.Request.UserAgent(),
+	)
+	if err != nil {
+		switch err {
+		case service.ErrUserNotFound:
+			response.NotFound(c, "User not found")
+		case service.ErrUserInactive:
+			response.BadRequest(c, "User is inactive")
+		case service.ErrCannotImpersonateSelf:
+			response.BadRequest(c, err.Error())
+		case service.ErrNestedImpersonation, service.ErrImpersonationForbidden:
+			response.Forbidden(c, err.Error())
+		default:
+			response.InternalError(c, "Failed to start impersonation")
+		}
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// Stop godoc
+// @Summary Stop impersonating
+// @Description End the current impersonation session. The administrator's own session stays active.
+// @Tags Auth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Router /auth/impersonation/stop [post]
+func (h *ImpersonationHandler) Stop(c *gin.Context) {
+	err := h.impersonationService.Stop(c.Request.Context(), middleware.GetClaims(c), middleware.GetClientIP(c), c.Request.UserAgent())
+	if err != nil {
+		if err == service.ErrNotImpersonating {
+			response.BadRequest(c, "Not impersonating a user")
+			return
+		}
+		response.InternalError(c, "Failed to stop impersonation")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Impersonation ended"})
+}
+
+// ListEvents godoc
+// @Summary List impersonation events
+// @Description List impersonation starts and stops where the user was the administrator or the target
+// @Tags Users
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "User ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.ImpersonationEvent}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /users/{id}/impersonation-events [get]
+func (h *ImpersonationHandler) ListEvents(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.impersonationService.ListEvents(c.Request.Context(), userID, page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list impersonation events")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
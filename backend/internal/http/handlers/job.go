@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"strconv"
+
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/scheduler"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	scheduler          *scheduler.Scheduler
+	maintenanceService *service.MaintenanceService
+}
+
+func NewJobHandler(scheduler *scheduler.Scheduler, maintenanceService *service.MaintenanceService) *JobHandler {
+	return &JobHandler{
+		scheduler:          scheduler,
+		maintenanceService: maintenanceService,
+	}
+}
+
+// List godoc
+// @Summary List background jobs
+// @Description List scheduled maintenance jobs with their schedule, next run and last run
+// @Tags Jobs
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} response.Response{data=[]scheduler.JobInfo}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /jobs [get]
+func (h *JobHandler) List(c *gin.Context) {
+	jobs, err := h.scheduler.Jobs(c.Request.Context())
+	if err != nil {
+		response.InternalError(c, "Failed to list jobs")
+		return
+	}
+
+	response.Success(c, jobs)
+}
+
+// ListRuns godoc
+// @Summary List job runs
+// @Description List the job run history, newest first
+// @Tags Jobs
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param job_name query string false "Job name"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.JobRun}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /jobs/runs [get]
+func (h *JobHandler) ListRuns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.maintenanceService.ListJobRuns(c.Request.Context(), c.Query("job_name"), page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list job runs")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// Run godoc
+// @Summary Run a job now
+// @Description Run a background job immediately and wait for it to finish
+// @Tags Jobs
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param name path string true "Job name"
+// @Success 200 {object} response.Response{data=domain.JobRun}
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /jobs/{name}/run [post]
+func (h *JobHandler) Run(c *gin.Context) {
+	run, err := h.scheduler.RunNow(c.Request.Context(), c.Param("name"), middleware.GetUserID(c))
+	if err != nil {
+		switch err {
+		case scheduler.ErrJobNotFound:
+			response.NotFound(c, "Job not found")
+		case scheduler.ErrJobRunning:
+			response.Conflict(c, "Job is already running")
+		default:
+			response.InternalError(c, "Failed to run job")
+		}
+		return
+	}
+
+	response.Success(c, run)
+}
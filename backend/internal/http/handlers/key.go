@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"gebase/internal/auth"
+	"gebase/internal/http/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KeyHandler struct {
+	keyRing *auth.KeyRing
+}
+
+func NewKeyHandler(keyRing *auth.KeyRing) *KeyHandler {
+	return &KeyHandler{
+		keyRing: keyRing,
+	}
+}
+
+// JWKS godoc
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying platform and system tokens
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} auth.JSONWebKeySet
+// @Failure 500 {object} response.Response
+// @Router /.well-known/jwks.json [get]
+func (h *KeyHandler) JWKS(c *gin.Context) {
+	set, err := h.keyRing.JWKS(c.Request.Context())
+	if err != nil {
+		response.InternalError(c, "Failed to load signing keys")
+		return
+	}
+
+	// Served as a bare key set, as JWKS consumers expect
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(200, set)
+}
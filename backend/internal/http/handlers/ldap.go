@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"strconv"
+
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LDAPHandler struct {
+	ldapService *service.LDAPService
+}
+
+func NewLDAPHandler(ldapService *service.LDAPService) *LDAPHandler {
+	return &LDAPHandler{
+		ldapService: ldapService,
+	}
+}
+
+// GetConfig godoc
+// @Summary Get organization LDAP settings
+// @Description Get the LDAP / Active Directory server used when the organization selects the ldap backend
+// @Tags Organizations
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Organization ID"
+// @Success 200 {object} response.Response{data=domain.OrganizationLDAPConfig}
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /organizations/{id}/ldap [get]
+func (h *LDAPHandler) GetConfig(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid organization ID")
+		return
+	}
+
+	cfg, err := h.ldapService.GetConfig(c.Request.Context(), id)
+	if err != nil {
+		switch err {
+		case service.ErrOrganizationNotFound:
+			response.NotFound(c, "Organization not found")
+		case service.ErrLDAPNotConfigured:
+			response.NotFound(c, "LDAP is not configured")
+		default:
+			response.InternalError(c, "Failed to get LDAP settings")
+		}
+		return
+	}
+
+	response.Success(c, cfg)
+}
+
+// UpdateConfig godoc
+// @Summary Update organization LDAP settings
+// @Description Create or replace the organization's LDAP / Active Directory server settings
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Organization ID"
+// @Param request body service.UpdateLDAPConfigRequest true "LDAP settings"
+// @Success 200 {object} response.Response{data=domain.OrganizationLDAPConfig}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /organizations/{id}/ldap [put]
+func (h *LDAPHandler) UpdateConfig(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid organization ID")
+		return
+	}
+
+	var req service.UpdateLDAPConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "URL and base DN are required")
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	cfg, err := h.ldapService.UpdateConfig(c.Request.Context(), id, &req, userID)
+	if err != nil {
+		switch err {
+		case service.ErrOrganizationNotFound:
+			response.NotFound(c, "Organization not found")
+		case service.ErrInvalidLDAPURL:
+			response.BadRequest(c, err.Error())
+		default:
+			response.InternalError(c, "Failed to update LDAP settings")
+		}
+		return
+	}
+
+	response.Success(c, cfg)
+}
+
+// ListGroupMappings godoc
+// @Summary List LDAP group mappings
+// @Description List the directory groups mapped to roles for an organization
+// @Tags Organizations
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Organization ID"
+// @Success 200 {object} response.Response{data=[]domain.LDAPGroupRoleMapping}
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /organizations/{id}/ldap/group-mappings [get]
+func (h *LDAPHandler) ListGroupMappings(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid organization ID")
+		return
+	}
+
+	mappings, err := h.ldapService.ListGroupMappings(c.Request.Context(), id)
+	if err != nil {
+		if err == service.ErrOrganizationNotFound {
+			response.NotFound(c, "Organization not found")
+			return
+		}
+		response.InternalError(c, "Failed to list group mappings")
+		return
+	}
+
+	response.Success(c, mappings)
+}
+
+// CreateGroupMapping godoc
+// @Summary Map an LDAP group to a role
+// @Description Grant a role to members of a directory group. Roles are synced on every directory login.
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Organization ID"
+// @Param request body service.CreateLDAPGroupMappingRequest true "Group DN and role"
+// @Success 201 {object} response.Response{data=domain.LDAPGroupRoleMapping}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /organizations/{id}/ldap/group-mappings [post]
+func (h *LDAPHandler) CreateGroupMapping(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid organization ID")
+		return
+	}
+
+	var req service.CreateLDAPGroupMappingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Group DN and role are required")
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	mapping, err := h.ldapService.CreateGroupMapping(c.Request.Context(), id, &req, userID)
+	if err != nil {
+		switch err {
+		case service.ErrOrganizationNotFound:
+			response.NotFound(c, "Organization not found")
+		case service.ErrRoleNotFound:
+			response.BadRequest(c, "Role not found")
+		default:
+			response.InternalError(c, "Failed to create group mapping")
+		}
+		return
+	}
+
+	response.Created(c, mapping)
+}
+
+// DeleteGroupMapping godoc
+// @Summary Delete an LDAP group mapping
+// @Description Stop granting a role to members of a directory group
+// @Tags Organizations
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Organization ID"
+// @Param mapping_id path int true "Mapping ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /organizations/{id}/ldap/group-mappings/{mapping_id} [delete]
+func (h *LDAPHandler) DeleteGroupMapping(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid organization ID")
+		return
+	}
+	mappingID, err := strconv.ParseInt(c.Param("mapping_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid mapping ID")
+		return
+	}
+
+	if err := h.ldapService.DeleteGroupMapping(c.Request.Context(), id, mappingID); err != nil {
+		if err == service.ErrLDAPMappingNotFound {
+			response.NotFound(c, "Group mapping not found")
+			return
+		}
+		response.InternalError(c, "Failed to delete group mapping")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Group mapping deleted"})
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"strconv"
+
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/repository"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LoginRiskHandler struct {
+	riskService *service.LoginRiskService
+}
+
+func NewLoginRiskHandler(riskService *service.LoginRiskService) *LoginRiskHandler {
+	return &LoginRiskHandler{
+		riskService: riskService,
+	}
+}
+
+// ListEvents godoc
+// @Summary List login risk events
+// @Description List the risk assessments of logins whose password was correct, newest first, with the signals raised and the action taken
+// @Tags Users
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param organization_id query int false "Organization ID"
+// @Param action query string false "Action (allow, step_up, block)"
+// @Param min_score query int false "Minimum score"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.LoginRiskEvent}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /users/login-risk-events [get]
+func (h *LoginRiskHandler) ListEvents(c *gin.Context) {
+	orgID, ok := organizationQuery(c)
+	if !ok {
+		return
+	}
+	h.listEvents(c, repository.LoginRiskEventFilter{OrganizationID: orgID})
+}
+
+// ListUserEvents godoc
+// @Summary List login risk events of a user
+// @Description List the risk assessments of a user's logins, newest first
+// @Tags Users
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "User ID"
+// @Param action query string false "Action (allow, step_up, block)"
+// @Param min_score query int false "Minimum score"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.LoginRiskEvent}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /users/{id}/login-risk-events [get]
+func (h *LoginRiskHandler) ListUserEvents(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+	h.listEvents(c, repository.LoginRiskEventFilter{UserID: &id})
+}
+
+func (h *LoginRiskHandler) listEvents(c *gin.Context, filter repository.LoginRiskEventFilter) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	filter.Action = c.Query("action")
+	filter.MinScore, _ = strconv.Atoi(c.Query("min_score"))
+
+	result, err := h.riskService.ListEvents(c.Request.Context(), filter, page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list login risk events")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// ListPolicies godoc
+// @Summary List login risk policies
+// @Description List the policies setting how organizations score logins and when they require a second factor, block the login or alert administrators
+// @Tags Users
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param organization_id query int false "Organization ID"
+// @Success 200 {object} response.Response{data=[]domain.LoginRiskPolicy}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /users/login-risk-policies [get]
+func (h *LoginRiskHandler) ListPolicies(c *gin.Context) {
+	orgID, ok := organizationQuery(c)
+	if !ok {
+		return
+	}
+
+	policies, err := h.riskService.ListPolicies(c.Request.Context(), orgID)
+	if err != nil {
+		response.InternalError(c, "Failed to list login risk policies")
+		return
+	}
+
+	response.Success(c, policies)
+}
+
+// CreatePolicy godoc
+// @Summary Create login risk policy
+// @Description Set the signal scores and thresholds of an organization. Fields left out take the global defaults; thresholds of 0 never trigger. Business hours (0-24, business_days as ISO weekdays "1,2,3,4,5") enable the off-hours signal when start and end differ.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.LoginRiskPolicyRequest true "Login risk policy"
+// @Success 201 {object} response.Response{data=domain.LoginRiskPolicy}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /users/login-risk-policies [post]
+func (h *LoginRiskHandler) CreatePolicy(c *gin.Context) {
+	var req service.LoginRiskPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	policy, err := h.riskService.CreatePolicy(c.Request.Context(), &req, middleware.GetUserID(c))
+	if err != nil {
+		respondLoginRiskError(c, err, "Failed to create login risk policy")
+		return
+	}
+
+	response.Created(c, policy)
+}
+
+// UpdatePolicy godoc
+// @Summary Update login risk policy
+// @Description Change a login risk policy; fields left out keep their value. Its organization cannot change.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Policy ID"
+// @Param request body service.LoginRiskPolicyRequest true "Login risk policy"
+// @Success 200 {object} response.Response{data=domain.LoginRiskPolicy}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /users/login-risk-policies/{id} [put]
+func (h *LoginRiskHandler) UpdatePolicy(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid policy ID")
+		return
+	}
+
+	var req service.LoginRiskPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	policy, err := h.riskService.UpdatePolicy(c.Request.Context(), id, &req, middleware.GetUserID(c))
+	if err != nil {
+		respondLoginRiskError(c, err, "Failed to update login risk policy")
+		return
+	}
+
+	response.Success(c, policy)
+}
+
+// DeletePolicy godoc
+// @Summary Delete login risk policy
+// @Description Delete a login risk policy; the organization falls back to the global settings
+// @Tags Users
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Policy ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /users/login-risk-policies/{id} [delete]
+func (h *LoginRiskHandler) DeletePolicy(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid policy ID")
+		return
+	}
+
+	if err := h.riskService.DeletePolicy(c.Request.Context(), id); err != nil {
+		respondLoginRiskError(c, err, "Failed to delete login risk policy")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Login risk policy deleted"})
+}
+
+func respondLoginRiskError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrOrganizationNotFound:
+		response.NotFound(c, "Organization not found")
+	case service.ErrLoginRiskPolicyNotFound:
+		response.NotFound(c, "Login risk policy not found")
+	case service.ErrLoginRiskPolicyExists:
+		response.Conflict(c, err.Error())
+	case service.ErrInvalidLoginRiskTimezone, service.ErrInvalidLoginRiskWeekdays, service.ErrInvalidLoginRiskThreshold:
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalError(c, fallback)
+	}
+}
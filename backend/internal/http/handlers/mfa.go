@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAHandler struct {
+	mfaService *service.MFAService
+}
+
+func NewMFAHandler(mfaService *service.MFAService) *MFAHandler {
+	return &MFAHandler{
+		mfaService: mfaService,
+	}
+}
+
+// GetStatus godoc
+// @Summary Get MFA status
+// @Description Get multi-factor authentication status of current user
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} response.Response{data=service.MFAStatus}
+// @Failure 401 {object} response.Response
+// @Router /auth/mfa [get]
+func (h *MFAHandler) GetStatus(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	status, err := h.mfaService.GetStatus(c.Request.Context(), userID)
+	if err != nil {
+		response.InternalError(c, "Failed to get MFA status")
+		return
+	}
+
+	response.Success(c, status)
+}
+
+// Enroll godoc
+// @Summary Start MFA enrollment
+// @Description Generate a TOTP secret and provisioning URI for an authenticator app
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} response.Response{data=service.MFAEnrollment}
+// @Failure 401 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /auth/mfa/enroll [post]
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	enrollment, err := h.mfaService.BeginEnrollment(c.Request.Context(), userID)
+	if err != nil {
+		if err == service.ErrMFAAlreadyEnabled {
+			response.Conflict(c, "MFA is already enabled")
+			return
+		}
+		response.InternalError(c, "Failed to start MFA enrollment")
+		return
+	}
+
+	response.Success(c, enrollment)
+}
+
+// Confirm godoc
+// @Summary Confirm MFA enrollment
+// @Description Activate MFA with a first code from the authenticator app and return recovery codes
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.MFACodeRequest true "TOTP code"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /auth/mfa/confirm [post]
+func (h *MFAHandler) Confirm(c *gin.Context) {
+	var req service.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Code is required")
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	codes, err := h.mfaService.ConfirmEnrollment(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		respondMFAError(c, err, "Failed to confirm MFA enrollment")
+		return
+	}
+
+	response.Success(c, gin.H{"recovery_codes": codes})
+}
+
+// Disable godoc
+// @Summary Disable MFA
+// @Description Remove MFA enrollment of current user after verifying a code
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.MFACodeRequest true "TOTP or recovery code"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /auth/mfa/disable [post]
+func (h *MFAHandler) Disable(c *gin.Context) {
+	var req service.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Code is required")
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	if err := h.mfaService.Disable(c.Request.Context(), userID, req.Code); err != nil {
+		respondMFAError(c, err, "Failed to disable MFA")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "MFA disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace MFA recovery codes of current user after verifying a code
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.MFACodeRequest true "TOTP or recovery code"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Router /auth/mfa/recovery-codes [post]
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req service.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Code is required")
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	codes, err := h.mfaService.RegenerateRecoveryCodes(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		respondMFAError(c, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	response.Success(c, gin.H{"recovery_codes": codes})
+}
+
+// Reset godoc
+// @Summary Reset user MFA
+// @Description Admin removal of a user's MFA enrollment (e.g. lost device)
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "User ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /users/{id}/mfa [delete]
+func (h *MFAHandler) Reset(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	if err := h.mfaService.Reset(c.Request.Context(), id); err != nil {
+		if err == service.ErrUserNotFound {
+			response.NotFound(c, "User not found")
+			return
+		}
+		response.InternalError(c, "Failed to reset MFA")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "MFA reset successfully"})
+}
+
+// respondMFAError maps MFA service errors shared by several endpoints
+func respondMFAError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrInvalidMFACode:
+		response.Error(c, http.StatusUnauthorized, "INVALID_MFA_CODE", "Invalid verification code")
+	case service.ErrInvalidMFAToken:
+		response.Error(c, http.StatusUnauthorized, "INVALID_MFA_TOKEN", "MFA token is invalid or expired")
+	case service.ErrMFANotEnrolled:
+		response.Error(c, http.StatusBadRequest, "MFA_NOT_ENABLED", "MFA is not enabled")
+	case service.ErrMFANotStarted:
+		response.Error(c, http.StatusBadRequest, "MFA_NOT_STARTED", "MFA enrollment has not been started")
+	case service.ErrMFAAlreadyEnabled:
+		response.Conflict(c, "MFA is already enabled")
+	case service.ErrMFARequired:
+		response.Error(c, http.StatusForbidden, "MFA_REQUIRED", "MFA is required by your organization")
+	default:
+		response.InternalError(c, fallback)
+	}
+}
@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthHandler serves the authorization server. The protocol endpoints
+// (/oauth/*, discovery) answer in the RFC 6749 / OIDC wire format rather than
+// the API response envelope, since standard client libraries consume them.
+type OAuthHandler struct {
+	oauthService *service.OAuthService
+	consentURL   string
+}
+
+func NewOAuthHandler(oauthService *service.OAuthService, consentURL string) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService: oauthService,
+		consentURL:   consentURL,
+	}
+}
+
+// Discovery godoc
+// @Summary OpenID provider metadata
+// @Description OpenID Connect discovery document of the built-in authorization server
+// @Tags OAuth
+// @Produce json
+// @Success 200 {object} service.DiscoveryDocument
+// @Router /.well-known/openid-configuration [get]
+func (h *OAuthHandler) Discovery(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, h.oauthService.Discovery())
+}
+
+// Authorize godoc
+// @Summary Authorization endpoint
+// @Description Validate an authorization request and send the browser to the login and consent page
+// @Tags OAuth
+// @Param response_type query string true "Must be code"
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string true "Registered redirect URI"
+// @Param scope query string false "Space separated scopes"
+// @Param state query string false "Opaque client state"
+// @Param nonce query string false "ID token nonce"
+// @Param code_challenge query string false "PKCE challenge"
+// @Param code_challenge_method query string false "Must be S256"
+// @Success 302
+// @Failure 400 {object} response.Response
+// @Router /oauth/authorize [get]
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	var req service.AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, "client_id and redirect_uri are required")
+		return
+	}
+
+	_, _, redirectable, err := h.oauthService.ValidateAuthorizeRequest(c.Request.Context(), &req)
+	if err != nil {
+		var oauthErr *service.OAuthError
+		if !errors.As(err, &oauthErr) {
+			response.InternalError(c, "Failed to validate authorization request")
+			return
+		}
+		if !redirectable {
+			// Never redirect to an unverified URI
+			response.Error(c, oauthErr.Status, strings.ToUpper(oauthErr.Code), oauthErr.Description)
+			return
+		}
+		params := url.Values{"error": {oauthErr.Code}, "error_description": {oauthErr.Description}}
+		if req.State != "" {
+			params.Set("state", req.State)
+		}
+		c.Redirect(http.StatusFound, appendQuery(req.RedirectURI, params.Encode()))
+		return
+	}
+
+	// The frontend logs the user in and posts the decision to /api/v1/oauth/authorize
+	c.Redirect(http.StatusFound, appendQuery(h.consentURL, c.Request.URL.RawQuery))
+}
+
+// Decide godoc
+// @Summary Authorize a client
+// @Description Issue an authorization code for the logged-in user, or report that consent is required
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.AuthorizeRequest true "Authorization request and consent decision"
+// @Success 200 {object} response.Response{data=service.AuthorizeResult}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Router /oauth/authorize [post]
+func (h *OAuthHandler) Decide(c *gin.Context) {
+	var req service.AuthorizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "client_id and redirect_uri are required")
+		return
+	}
+
+	result, err := h.oauthService.Authorize(c.Request.Context(), middleware.GetClaims(c), &req)
+	if err != nil {
+		var oauthErr *service.OAuthError
+		if errors.As(err, &oauthErr) {
+			response.Error(c, oauthErr.Status, strings.ToUpper(oauthErr.Code), oauthErr.Description)
+			return
+		}
+		response.InternalError(c, "Failed to authorize client")
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// Token godoc
+// @Summary Token endpoint
+// @Description Exchange an authorization code, refresh token or client credentials for tokens
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "authorization_code, refresh_token or client_credentials"
+// @Param X-Device-Proof header string false "Proof signed with the device key, required to redeem refresh tokens issued on a device with a key"
+// @Success 200 {object} service.TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /oauth/token [post]
+func (h *OAuthHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var req service.TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "malformed token request"})
+		return
+	}
+
+	// client_secret_basic takes precedence over client_secret_post
+	basicAuth := false
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		basicAuth = true
+		req.ClientID, _ = url.QueryUnescape(id)
+		req.ClientSecret, _ = url.QueryUnescape(secret)
+	}
+
+	result, err := h.oauthService.Token(c.Request.Context(), &req, middleware.GetDeviceProof(c), middleware.GetClientIP(c), c.Request.UserAgent())
+	if err != nil {
+		var oauthErr *service.OAuthError
+		if errors.As(err, &oauthErr) {
+			if oauthErr.Code == "invalid_client" && basicAuth {
+				c.Header("WWW-Authenticate", `Basic realm="gebase"`)
+			}
+			c.JSON(oauthErr.Status, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// UserInfo godoc
+// @Summary UserInfo endpoint
+// @Description OpenID Connect claims of the user the access token was issued to
+// @Tags OAuth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} map[string]string
+// @Router /oauth/userinfo [get]
+func (h *OAuthHandler) UserInfo(c *gin.Context) {
+	info, err := h.oauthService.UserInfo(c.Request.Context(), middleware.GetClaims(c))
+	if err != nil {
+		var oauthErr *service.OAuthError
+		if errors.As(err, &oauthErr) {
+			c.Header("WWW-Authenticate", `Bearer error="`+oauthErr.Code+`"`)
+			c.JSON(oauthErr.Status, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// ListConsents godoc
+// @Summary List authorized applications
+// @Description List the OAuth clients the current user has granted access to
+// @Tags OAuth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} response.Response{data=[]domain.OAuthConsent}
+// @Failure 401 {object} response.Response
+// @Router /auth/oauth/consents [get]
+func (h *OAuthHandler) ListConsents(c *gin.Context) {
+	consents, err := h.oauthService.ListConsents(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		response.InternalError(c, "Failed to list authorized applications")
+		return
+	}
+
+	response.Success(c, consents)
+}
+
+// RevokeConsent godoc
+// @Summary Revoke an application
+// @Description Withdraw an OAuth client's access and end its sessions
+// @Tags OAuth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param client_id path string true "Client ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /auth/oauth/consents/{client_id} [delete]
+func (h *OAuthHandler) RevokeConsent(c *gin.Context) {
+	err := h.oauthService.RevokeConsent(c.Request.Context(), middleware.GetUserID(c), c.Param("client_id"))
+	if err != nil {
+		if err == service.ErrOAuthClientNotFound {
+			response.NotFound(c, "Client not found")
+			return
+		}
+		response.InternalError(c, "Failed to revoke application access")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Application access revoked"})
+}
+
+// ListClients godoc
+// @Summary List OAuth clients
+// @Description List applications registered with the authorization server
+// @Tags OAuth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.OAuthClient}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /oauth/clients [get]
+func (h *OAuthHandler) ListClients(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.oauthService.ListClients(c.Request.Context(), page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list clients")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// GetClient godoc
+// @Summary Get OAuth client
+// @Tags OAuth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Client record ID"
+// @Success 200 {object} response.Response{data=domain.OAuthClient}
+// @Failure 404 {object} response.Response
+// @Router /oauth/clients/{id} [get]
+func (h *OAuthHandler) GetClient(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid client ID")
+		return
+	}
+
+	client, err := h.oauthService.GetClient(c.Request.Context(), id)
+	if err != nil {
+		response.NotFound(c, "Client not found")
+		return
+	}
+
+	response.Success(c, client)
+}
+
+// CreateClient godoc
+// @Summary Register OAuth client
+// @Description Register an application; the secret of a confidential client is only returned once
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.CreateOAuthClientRequest true "Client registration"
+// @Success 201 {object} response.Response{data=service.OAuthClientCredentials}
+// @Failure 400 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /oauth/clients [post]
+func (h *OAuthHandler) CreateClient(c *gin.Context) {
+	var req service.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	result, err := h.oauthService.CreateClient(c.Request.Context(), &req, middleware.GetUserID(c))
+	if err != nil {
+		if err == service.ErrOAuthClientIDExists {
+			response.Conflict(c, "Client ID already exists")
+			return
+		}
+		response.InternalError(c, "Failed to create client")
+		return
+	}
+
+	response.Created(c, result)
+}
+
+// UpdateClient godoc
+// @Summary Update OAuth client
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Client record ID"
+// @Param request body service.UpdateOAuthClientRequest true "Client changes"
+// @Success 200 {object} response.Response{data=domain.OAuthClient}
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /oauth/clients/{id} [put]
+func (h *OAuthHandler) UpdateClient(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid client ID")
+		return
+	}
+
+	var req service.UpdateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	client, err := h.oauthService.UpdateClient(c.Request.Context(), id, &req, middleware.GetUserID(c))
+	if err != nil {
+		if err == service.ErrOAuthClientNotFound {
+			response.NotFound(c, "Client not found")
+			return
+		}
+		response.InternalError(c, "Failed to update client")
+		return
+	}
+
+	response.Success(c, client)
+}
+
+// RotateClientSecret godoc
+// @Summary Rotate OAuth client secret
+// @Tags OAuth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Client record ID"
+// @Success 200 {object} response.Response{data=service.OAuthClientCredentials}
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /oauth/clients/{id}/rotate-secret [post]
+func (h *OAuthHandler) RotateClientSecret(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid client ID")
+		return
+	}
+
+	result, err := h.oauthService.RotateClientSecret(c.Request.Context(), id, middleware.GetUserID(c))
+	if err != nil {
+		var oauthErr *service.OAuthError
+		switch {
+		case err == service.ErrOAuthClientNotFound:
+			response.NotFound(c, "Client not found")
+		case errors.As(err, &oauthErr):
+			response.BadRequest(c, "Public clients have no secret")
+		default:
+			response.InternalError(c, "Failed to rotate client secret")
+		}
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// DeleteClient godoc
+// @Summary Delete OAuth client
+// @Tags OAuth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Client record ID"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /oauth/clients/{id} [delete]
+func (h *OAuthHandler) DeleteClient(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid client ID")
+		return
+	}
+
+	if err := h.oauthService.DeleteClient(c.Request.Context(), id); err != nil {
+		if err == service.ErrOAuthClientNotFound {
+			response.NotFound(c, "Client not found")
+			return
+		}
+		response.InternalError(c, "Failed to delete client")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Client deleted successfully"})
+}
+
+func appendQuery(base, rawQuery string) string {
+	if rawQuery == "" {
+		return base
+	}
+	if strings.Contains(base, "?") {
+		return base + "&" + rawQuery
+	}
+	return base + "?" + rawQuery
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gebase/internal/auth"
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCHandler struct {
+	oidcService *service.OIDCService
+}
+
+func NewOIDCHandler(oidcService *service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+	}
+}
+
+// Login godoc
+// @Summary Start OIDC login
+// @Description Create an authorization request (code flow with PKCE) for the external identity provider
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body service.OIDCLoginRequest true "Device UID"
+// @Success 200 {object} response.Response{data=service.OIDCAuthorization}
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /auth/oidc/login [post]
+func (h *OIDCHandler) Login(c *gin.Context) {
+	var req service.OIDCLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	result, err := h.oidcService.BeginLogin(c.Request.Context(), &req, middleware.GetClientIP(c))
+	if err != nil {
+		respondOIDCError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// Callback godoc
+// @Summary Complete OIDC login
+// @Description Exchange the authorization code returned by the identity provider for platform tokens
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body service.OIDCCallbackRequest true "Authorization code and state"
+// @Success 200 {object} response.Response{data=service.LoginResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /auth/oidc/callback [post]
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	var req service.OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	ipAddress := middleware.GetClientIP(c)
+	userAgent := c.Request.UserAgent()
+
+	result, err := h.oidcService.CompleteLogin(c.Request.Context(), &req, ipAddress, userAgent)
+	if err != nil {
+		respondOIDCError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+func respondOIDCError(c *gin.Context, err error) {
+	if respondLoginBlocked(c, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, service.ErrOIDCDisabled):
+		response.NotFound(c, "OIDC login is not enabled")
+	case errors.Is(err, service.ErrOIDCInvalidState):
+		response.Error(c, http.StatusBadRequest, "INVALID_OIDC_STATE", "Login request is invalid or has expired")
+	case errors.Is(err, auth.ErrOIDCExchange), errors.Is(err, auth.ErrOIDCInvalidIDToken):
+		response.Unauthorized(c, "Identity provider authentication failed")
+	case errors.Is(err, auth.ErrOIDCDiscovery):
+		response.Error(c, http.StatusBadGateway, "OIDC_PROVIDER_UNAVAILABLE", "Identity provider is unavailable")
+	case errors.Is(err, service.ErrOIDCInvalidSubject), errors.Is(err, service.ErrOIDCMissingEmail):
+		response.Error(c, http.StatusBadRequest, "INVALID_OIDC_IDENTITY", err.Error())
+	case errors.Is(err, service.ErrOIDCAccountConflict):
+		response.Conflict(c, "Email is already used by another account")
+	case errors.Is(err, service.ErrOIDCUserNotProvisioned):
+		response.Forbidden(c, "User is not registered")
+	case errors.Is(err, service.ErrUserInactive):
+		response.Forbidden(c, "User account is inactive")
+	case errors.Is(err, service.ErrDeviceNotFound):
+		response.Error(c, http.StatusBadRequest, "DEVICE_NOT_REGISTERED", "Device is not registered")
+	case errors.Is(err, service.ErrDeviceNotActive):
+		response.Forbidden(c, "Device is deactivated")
+	case errors.Is(err, service.ErrDevicePendingApproval):
+		response.Error(c, http.StatusForbidden, "DEVICE_PENDING_APPROVAL", "Device is awaiting administrator approval")
+	case errors.Is(err, service.ErrDeviceRejected):
+		response.Error(c, http.StatusForbidden, "DEVICE_REJECTED", "Device was rejected by an administrator")
+	case errors.Is(err, service.ErrDeviceOrganizationMismatch):
+		response.Error(c, http.StatusForbidden, "DEVICE_ORGANIZATION_MISMATCH", "Device belongs to another organization")
+	case errors.Is(err, auth.ErrSessionLimitReached):
+		respondSessionLimitReached(c)
+	default:
+		response.InternalError(c, "OIDC login failed")
+	}
+}
@@ -326,3 +326,79 @@ func (h *OrganizationHandler) DisableSystem(c *gin.Context) {
 
 	response.Success(c, gin.H{"message": "System disabled"})
 }
+
+// GetSecurityPolicy godoc
+// @Summary Get organization security policy
+// @Description Get authentication requirements for an organization
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Organization ID"
+// @Success 200 {object} response.Response{data=domain.OrganizationSecurityPolicy}
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /organizations/{id}/security-policy [get]
+func (h *OrganizationHandler) GetSecurityPolicy(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid organization ID")
+		return
+	}
+
+	policy, err := h.orgService.GetSecurityPolicy(c.Request.Context(), id)
+	if err != nil {
+		if err == service.ErrOrganizationNotFound {
+			response.NotFound(c, "Organization not found")
+			return
+		}
+		response.InternalError(c, "Failed to get security policy")
+		return
+	}
+
+	response.Success(c, policy)
+}
+
+// UpdateSecurityPolicy godoc
+// @Summary Update organization security policy
+// @Description Update authentication requirements for an organization
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Organization ID"
+// @Param request body service.UpdateSecurityPolicyRequest true "Security policy"
+// @Success 200 {object} response.Response{data=domain.OrganizationSecurityPolicy}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /organizations/{id}/security-policy [put]
+func (h *OrganizationHandler) UpdateSecurityPolicy(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid organization ID")
+		return
+	}
+
+	var req service.UpdateSecurityPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	policy, err := h.orgService.UpdateSecurityPolicy(c.Request.Context(), id, &req, userID)
+	if err != nil {
+		switch err {
+		case service.ErrOrganizationNotFound:
+			response.NotFound(c, "Organization not found")
+		case service.ErrLDAPNotConfigured:
+			response.BadRequest(c, "Configure the LDAP server before selecting the ldap backend")
+		default:
+			response.InternalError(c, "Failed to update security policy")
+		}
+		return
+	}
+
+	response.Success(c, policy)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"strconv"
+
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PermissionHandler struct {
+	permissionService *service.PermissionService
+}
+
+func NewPermissionHandler(permissionService *service.PermissionService) *PermissionHandler {
+	return &PermissionHandler{
+		permissionService: permissionService,
+	}
+}
+
+// List godoc
+// @Summary List permissions
+// @Description List the permissions of a system, or platform permissions without system_id
+// @Tags Permissions
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param system_id query int false "System ID"
+// @Success 200 {object} response.Response{data=[]domain.Permission}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /permissions [get]
+func (h *PermissionHandler) List(c *gin.Context) {
+	var systemID *int
+	if raw := c.Query("system_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			response.BadRequest(c, "Invalid system ID")
+			return
+		}
+		systemID = &id
+	}
+
+	permissions, err := h.permissionService.ListPermissions(c.Request.Context(), systemID)
+	if err != nil {
+		response.InternalError(c, "Failed to list permissions")
+		return
+	}
+
+	response.Success(c, permissions)
+}
+
+// Update godoc
+// @Summary Update permission
+// @Description Rename, activate or deactivate a permission, or set how recently users must have entered their credentials to use it (recent_auth_minutes, 0 for never). Deactivated permissions are not granted by any role. Fields left out keep their value.
+// @Tags Permissions
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Permission ID"
+// @Param request body service.UpdatePermissionRequest true "Permission"
+// @Success 200 {object} response.Response{data=domain.Permission}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /permissions/{id} [put]
+func (h *PermissionHandler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "Invalid permission ID")
+		return
+	}
+
+	var req service.UpdatePermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	permission, err := h.permissionService.UpdatePermission(c.Request.Context(), id, &req, middleware.GetUserID(c))
+	if err != nil {
+		if err == service.ErrPermissionNotFound {
+			response.NotFound(c, "Permission not found")
+			return
+		}
+		response.InternalError(c, "Failed to update permission")
+		return
+	}
+
+	response.Success(c, permission)
+}
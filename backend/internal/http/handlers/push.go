@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PushHandler struct {
+	pushService *service.PushService
+}
+
+func NewPushHandler(pushService *service.PushService) *PushHandler {
+	return &PushHandler{
+		pushService: pushService,
+	}
+}
+
+// Send godoc
+// @Summary Send push notification
+// @Description Send a push notification to every device of a user, one device, an organization or a platform, from a template or explicit content. Delivery happens in the background; failures are retried and rejected push tokens removed.
+// @Tags Push
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.SendPushRequest true "Notification"
+// @Success 201 {object} response.Response{data=domain.PushNotification}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /push/send [post]
+func (h *PushHandler) Send(c *gin.Context) {
+	h.send(c)
+}
+
+// SendInternal godoc
+// @Summary Send push notification (internal)
+// @Description Send a push notification on behalf of another service, authenticated with the access token of its service account. Takes the same request as /push/send.
+// @Tags Push
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param request body service.SendPushRequest true "Notification"
+// @Success 201 {object} response.Response{data=domain.PushNotification}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /internal/push/send [post]
+func (h *PushHandler) SendInternal(c *gin.Context) {
+	h.send(c)
+}
+
+func (h *PushHandler) send(c *gin.Context) {
+	var req service.SendPushRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	notification, err := h.pushService.Send(c.Request.Context(), &req, middleware.GetUserID(c))
+	if err != nil {
+		respondPushError(c, err, "Failed to send push notification")
+		return
+	}
+
+	response.Created(c, notification)
+}
+
+// ListNotifications godoc
+// @Summary List push notifications
+// @Description List sent push notifications, newest first, with their deliveries counted by status
+// @Tags Push
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param target query string false "Target type (user, device, organization, platform)"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]service.PushNotificationSummary}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /push/notifications [get]
+func (h *PushHandler) ListNotifications(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.pushService.ListNotifications(c.Request.Context(), c.Query("target"), page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list push notifications")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// GetNotification godoc
+// @Summary Get push notification
+// @Description Get a push notification with its deliveries counted by status
+// @Tags Push
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Notification ID"
+// @Success 200 {object} response.Response{data=service.PushNotificationSummary}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /push/notifications/{id} [get]
+func (h *PushHandler) GetNotification(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid notification ID")
+		return
+	}
+
+	notification, err := h.pushService.GetNotification(c.Request.Context(), id)
+	if err != nil {
+		respondPushError(c, err, "Failed to get push notification")
+		return
+	}
+
+	response.Success(c, notification)
+}
+
+// ListDeliveries godoc
+// @Summary List push deliveries
+// @Description List the per-device deliveries of a push notification
+// @Tags Push
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Notification ID"
+// @Param status query string false "Status (pending, sent, failed, invalid_token)"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.PushDelivery}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /push/notifications/{id}/deliveries [get]
+func (h *PushHandler) ListDeliveries(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid notification ID")
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.pushService.ListDeliveries(c.Request.Context(), id, c.Query("status"), page, pageSize)
+	if err != nil {
+		respondPushError(c, err, "Failed to list push deliveries")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// ListTemplates godoc
+// @Summary List push templates
+// @Description List push notification templates
+// @Tags Push
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param search query string false "Search by code or name"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.PushTemplate}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /push/templates [get]
+func (h *PushHandler) ListTemplates(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.pushService.ListTemplates(c.Request.Context(), c.Query("search"), page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list push templates")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// GetTemplate godoc
+// @Summary Get push template
+// @Description Get a push notification template
+// @Tags Push
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Template ID"
+// @Success 200 {object} response.Response{data=domain.PushTemplate}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /push/templates/{id} [get]
+func (h *PushHandler) GetTemplate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid template ID")
+		return
+	}
+
+	tmpl, err := h.pushService.GetTemplate(c.Request.Context(), id)
+	if err != nil {
+		respondPushError(c, err, "Failed to get push template")
+		return
+	}
+
+	response.Success(c, tmpl)
+}
+
+// CreateTemplate godoc
+// @Summary Create push template
+// @Description Create a push notification template. Title, body and data values are Go templates filled with the variables given when sending, e.g. "Order {{.order_id}} is ready".
+// @Tags Push
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.PushTemplateRequest true "Push template"
+// @Success 201 {object} response.Response{data=domain.PushTemplate}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /push/templates [post]
+func (h *PushHandler) CreateTemplate(c *gin.Context) {
+	var req service.PushTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	tmpl, err := h.pushService.CreateTemplate(c.Request.Context(), &req, middleware.GetUserID(c))
+	if err != nil {
+		respondPushError(c, err, "Failed to create push template")
+		return
+	}
+
+	response.Created(c, tmpl)
+}
+
+// UpdateTemplate godoc
+// @Summary Update push template
+// @Description Replace a push notification template
+// @Tags Push
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Template ID"
+// @Param request body service.PushTemplateRequest true "Push template"
+// @Success 200 {object} response.Response{data=domain.PushTemplate}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /push/templates/{id} [put]
+func (h *PushHandler) UpdateTemplate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid template ID")
+		return
+	}
+
+	var req service.PushTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	tmpl, err := h.pushService.UpdateTemplate(c.Request.Context(), id, &req, middleware.GetUserID(c))
+	if err != nil {
+		respondPushError(c, err, "Failed to update push template")
+		return
+	}
+
+	response.Success(c, tmpl)
+}
+
+// DeleteTemplate godoc
+// @Summary Delete push template
+// @Description Delete a push notification template
+// @Tags Push
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Template ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /push/templates/{id} [delete]
+func (h *PushHandler) DeleteTemplate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid template ID")
+		return
+	}
+
+	if err := h.pushService.DeleteTemplate(c.Request.Context(), id); err != nil {
+		respondPushError(c, err, "Failed to delete push template")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Push template deleted"})
+}
+
+func respondPushError(c *gin.Context, err error, fallback string) {
+	var templateErr *service.InvalidPushTemplateError
+	if errors.As(err, &templateErr) {
+		response.BadRequest(c, templateErr.Error())
+		return
+	}
+
+	switch err {
+	case service.ErrUserNotFound:
+		response.NotFound(c, "User not found")
+	case service.ErrDeviceNotFound:
+		response.NotFound(c, "Device not found")
+	case service.ErrOrganizationNotFound:
+		response.NotFound(c, "Organization not found")
+	case service.ErrPushTemplateNotFound, service.ErrPushNotificationNotFound:
+		response.NotFound(c, err.Error())
+	case service.ErrInvalidPushTarget, service.ErrEmptyPushContent:
+		response.BadRequest(c, err.Error())
+	case service.ErrPushTemplateExists:
+		response.Conflict(c, err.Error())
+	default:
+		response.InternalError(c, fallback)
+	}
+}
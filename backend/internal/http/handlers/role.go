@@ -256,6 +256,10 @@ func (h *RoleHandler) AssignPermissions(c *gin.Context) {
 
 	userID := middleware.GetUserID(c)
 	if err := h.roleService.AssignPermissions(c.Request.Context(), id, req.PermissionIDs, userID); err != nil {
+		if err == service.ErrRoleNotFound {
+			response.NotFound(c, "Role not found")
+			return
+		}
 		response.InternalError(c, "Failed to assign permissions")
 		return
 	}
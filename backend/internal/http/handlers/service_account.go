@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"strconv"
+
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ServiceAccountHandler struct {
+	serviceAccountService *service.ServiceAccountService
+}
+
+func NewServiceAccountHandler(serviceAccountService *service.ServiceAccountService) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		serviceAccountService: serviceAccountService,
+	}
+}
+
+// List godoc
+// @Summary List service accounts
+// @Description List service accounts, optionally of one organization
+// @Tags Service Accounts
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param organization_id query int false "Organization ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response{data=[]domain.User}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /service-accounts [get]
+func (h *ServiceAccountHandler) List(c *gin.Context) {
+	var orgID *int64
+	if value := c.Query("organization_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			response.BadRequest(c, "Invalid organization ID")
+			return
+		}
+		orgID = &id
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.serviceAccountService.List(c.Request.Context(), orgID, page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list service accounts")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// Create godoc
+// @Summary Create service account
+// @Description Create a non-human user for an integration. Assign roles with PUT /users/{id}/roles and issue tokens with POST /service-accounts/{id}/tokens; service accounts cannot log in.
+// @Tags Service Accounts
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.CreateServiceAccountRequest true "Service account"
+// @Success 201 {object} response.Response{data=domain.User}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /service-accounts [post]
+func (h *ServiceAccountHandler) Create(c *gin.Context) {
+	var req service.CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	account, err := h.serviceAccountService.Create(c.Request.Context(), &req, middleware.GetUserID(c))
+	if err != nil {
+		if err == service.ErrEmailAlreadyExists {
+			response.Conflict(c, "Email already exists")
+			return
+		}
+		response.InternalError(c, "Failed to create service account")
+		return
+	}
+
+	response.Created(c, account)
+}
+
+// Get godoc
+// @Summary Get service account
+// @Description Get a service account by ID
+// @Tags Service Accounts
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Service account ID"
+// @Success 200 {object} response.Response{data=domain.User}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /service-accounts/{id} [get]
+func (h *ServiceAccountHandler) Get(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid service account ID")
+		return
+	}
+
+	account, err := h.serviceAccountService.Get(c.Request.Context(), id)
+	if err != nil {
+		response.NotFound(c, "Service account not found")
+		return
+	}
+
+	response.Success(c, account)
+}
+
+// Update godoc
+// @Summary Update service account
+// @Description Rename or (de)activate a service account. The tokens of an inactive account are refused.
+// @Tags Service Accounts
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Service account ID"
+// @Param request body service.UpdateServiceAccountRequest true "Service account"
+// @Success 200 {object} response.Response{data=domain.User}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /service-accounts/{id} [put]
+func (h *ServiceAccountHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid service account ID")
+		return
+	}
+
+	var req service.UpdateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	account, err := h.serviceAccountService.Update(c.Request.Context(), id, &req, middleware.GetUserID(c))
+	if err != nil {
+		if err == service.ErrServiceAccountNotFound {
+			response.NotFound(c, "Service account not found")
+			return
+		}
+		response.InternalError(c, "Failed to update service account")
+		return
+	}
+
+	response.Success(c, account)
+}
+
+// Delete godoc
+// @Summary Delete service account
+// @Description Delete a service account and revoke all of its tokens
+// @Tags Service Accounts
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Service account ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /service-accounts/{id} [delete]
+func (h *ServiceAccountHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid service account ID")
+		return
+	}
+
+	if err := h.serviceAccountService.Delete(c.Request.Context(), id, middleware.GetUserID(c)); err != nil {
+		if err == service.ErrServiceAccountNotFound {
+			response.NotFound(c, "Service account not found")
+			return
+		}
+		response.InternalError(c, "Failed to delete service account")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Service account deleted"})
+}
+
+// ListTokens godoc
+// @Summary List service account tokens
+// @Description List the access tokens of a service account
+// @Tags Service Accounts
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Service account ID"
+// @Success 200 {object} response.Response{data=[]domain.PersonalAccessToken}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /service-accounts/{id}/tokens [get]
+func (h *ServiceAccountHandler) ListTokens(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid service account ID")
+		return
+	}
+
+	tokens, err := h.serviceAccountService.ListTokens(c.Request.Context(), id)
+	if err != nil {
+		respondAccessTokenError(c, err)
+		return
+	}
+
+	response.Success(c, tokens)
+}
+
+// CreateToken godoc
+// @Summary Create service account token
+// @Description Issue an access token for a service account, bound to one system and a subset of the account's permissions there. The token is only returned in this response. Permissions that require recent authentication answer STEP_UP_REQUIRED unless the session reauthenticated within their window.
+// @Tags Service Accounts
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Service account ID"
+// @Param request body service.CreateAccessTokenRequest true "Token"
+// @Success 201 {object} response.Response{data=service.AccessTokenCredentials}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /service-accounts/{id}/tokens [post]
+func (h *ServiceAccountHandler) CreateToken(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid service account ID")
+		return
+	}
+
+	var req service.CreateAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	result, err := h.serviceAccountService.CreateToken(c.Request.Context(), id, &req, middleware.GetUserID(c), middleware.GetAuthenticatedAt(c))
+	if err != nil {
+		respondAccessTokenError(c, err)
+		return
+	}
+
+	response.Created(c, result)
+}
+
+// RevokeToken godoc
+// @Summary Revoke service account token
+// @Description Revoke an access token of a service account
+// @Tags Service Accounts
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Service account ID"
+// @Param token_id path int true "Token ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /service-accounts/{id}/tokens/{token_id} [delete]
+func (h *ServiceAccountHandler) RevokeToken(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid service account ID")
+		return
+	}
+	tokenID, err := strconv.ParseInt(c.Param("token_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid token ID")
+		return
+	}
+
+	if err := h.serviceAccountService.RevokeToken(c.Request.Context(), id, tokenID, middleware.GetUserID(c)); err != nil {
+		respondAccessTokenError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Access token revoked"})
+}
@@ -0,0 +1,498 @@
+package handlers
+
+import (
+	"strconv"
+
+	"gebase/internal/auth"
+	"gebase/internal/http/response"
+	"gebase/internal/middleware"
+	"gebase/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	authService    *service.AuthService
+	limitService   *service.SessionLimitService
+	timeoutService *service.SessionTimeoutService
+}
+
+func NewSessionHandler(
+	authService *service.AuthService,
+	limitService *service.SessionLimitService,
+	timeoutService *service.SessionTimeoutService,
+) *SessionHandler {
+	return &SessionHandler{
+		authService:    authService,
+		limitService:   limitService,
+		timeoutService: timeoutService,
+	}
+}
+
+// ListMine godoc
+// @Summary List my sessions
+// @Description List the current user's active sessions with device, IP, last activity and current system
+// @Tags Auth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} response.Response{data=[]service.SessionInfo}
+// @Failure 401 {object} response.Response
+// @Router /auth/sessions [get]
+func (h *SessionHandler) ListMine(c *gin.Context) {
+	sessions, err := h.authService.ListSessions(c.Request.Context(), middleware.GetUserID(c), middleware.GetSessionID(c))
+	if err != nil {
+		response.InternalError(c, "Failed to list sessions")
+		return
+	}
+
+	response.Success(c, sessions)
+}
+
+// RevokeMine godoc
+// @Summary Revoke one of my sessions
+// @Description End one of the current user's sessions. Revoking the current session logs out.
+// @Tags Auth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Session ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /auth/sessions/{id} [delete]
+func (h *SessionHandler) RevokeMine(c *gin.Context) {
+	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid session ID")
+		return
+	}
+
+	err = h.authService.RevokeSession(c.Request.Context(), middleware.GetUserID(c), sessionID, auth.LogoutReasonRevokedByUser)
+	if err != nil {
+		if err == service.ErrSessionNotFound {
+			response.NotFound(c, "Session not found")
+			return
+		}
+		response.InternalError(c, "Failed to revoke session")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Session revoked"})
+}
+
+// RevokeOthers godoc
+// @Summary Revoke my other sessions
+// @Description End all of the current user's sessions except the one making the request
+// @Tags Auth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Router /auth/sessions [delete]
+func (h *SessionHandler) RevokeOthers(c *gin.Context) {
+	if err := h.authService.RevokeOtherSessions(c.Request.Context(), middleware.GetUserID(c), middleware.GetSessionID(c)); err != nil {
+		response.InternalError(c, "Failed to revoke sessions")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Other sessions revoked"})
+}
+
+// ListForUser godoc
+// @Summary List user sessions
+// @Description List the active sessions of a user
+// @Tags Users
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "User ID"
+// @Success 200 {object} response.Response{data=[]service.SessionInfo}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /users/{id}/sessions [get]
+func (h *SessionHandler) ListForUser(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(c.Request.Context(), userID, middleware.GetSessionID(c))
+	if err != nil {
+		response.InternalError(c, "Failed to list sessions")
+		return
+	}
+
+	response.Success(c, sessions)
+}
+
+// RevokeForUser godoc
+// @Summary Revoke a user session
+// @Description End one session of a user
+// @Tags Users
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "User ID"
+// @Param session_id path int true "Session ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /users/{id}/sessions/{session_id} [delete]
+func (h *SessionHandler) RevokeForUser(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+	sessionID, err := strconv.ParseInt(c.Param("session_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid session ID")
+		return
+	}
+
+	err = h.authService.RevokeSession(c.Request.Context(), userID, sessionID, auth.LogoutReasonRevokedByAdmin)
+	if err != nil {
+		if err == service.ErrSessionNotFound {
+			response.NotFound(c, "Session not found")
+			return
+		}
+		response.InternalError(c, "Failed to revoke session")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Session revoked"})
+}
+
+// RevokeAllForUser godoc
+// @Summary Revoke all user sessions
+// @Description End every session of a user, logging them out on all devices
+// @Tags Users
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "User ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /users/{id}/sessions [delete]
+func (h *SessionHandler) RevokeAllForUser(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	if err := h.authService.RemoteLogout(c.Request.Context(), userID, nil, auth.LogoutReasonRevokedByAdmin); err != nil {
+		response.InternalError(c, "Failed to revoke sessions")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Sessions revoked"})
+}
+
+// List godoc
+// @Summary List active sessions
+// @Description List active sessions of all users with pagination
+// @Tags Sessions
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /sessions [get]
+func (h *SessionHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.authService.ListActiveSessions(c.Request.Context(), page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list sessions")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// ListPolicies godoc
+// @Summary List session limit policies
+// @Description List concurrent session limit policies, optionally filtered by organization or role
+// @Tags Sessions
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param organization_id query int false "Organization ID"
+// @Param role_id query int false "Role ID"
+// @Success 200 {object} response.Response{data=[]domain.SessionLimitPolicy}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /sessions/policies [get]
+func (h *SessionHandler) ListPolicies(c *gin.Context) {
+	var orgID *int64
+	if value := c.Query("organization_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			response.BadRequest(c, "Invalid organization ID")
+			return
+		}
+		orgID = &id
+	}
+	var roleID *int
+	if value := c.Query("role_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			response.BadRequest(c, "Invalid role ID")
+			return
+		}
+		roleID = &id
+	}
+
+	policies, err := h.limitService.ListPolicies(c.Request.Context(), orgID, roleID)
+	if err != nil {
+		response.InternalError(c, "Failed to list session limit policies")
+		return
+	}
+
+	response.Success(c, policies)
+}
+
+// CreatePolicy godoc
+// @Summary Create session limit policy
+// @Description Limit concurrent sessions per platform for everyone, an organization, a role or both
+// @Tags Sessions
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.SessionLimitPolicyRequest true "Session limit policy"
+// @Success 201 {object} response.Response{data=domain.SessionLimitPolicy}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /sessions/policies [post]
+func (h *SessionHandler) CreatePolicy(c *gin.Context) {
+	var req service.SessionLimitPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	policy, err := h.limitService.CreatePolicy(c.Request.Context(), &req, middleware.GetUserID(c))
+	if err != nil {
+		respondSessionLimitError(c, err, "Failed to create session limit policy")
+		return
+	}
+
+	response.Created(c, policy)
+}
+
+// UpdatePolicy godoc
+// @Summary Update session limit policy
+// @Description Replace a concurrent session limit policy
+// @Tags Sessions
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Policy ID"
+// @Param request body service.SessionLimitPolicyRequest true "Session limit policy"
+// @Success 200 {object} response.Response{data=domain.SessionLimitPolicy}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /sessions/policies/{id} [put]
+func (h *SessionHandler) UpdatePolicy(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid policy ID")
+		return
+	}
+
+	var req service.SessionLimitPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	policy, err := h.limitService.UpdatePolicy(c.Request.Context(), id, &req, middleware.GetUserID(c))
+	if err != nil {
+		respondSessionLimitError(c, err, "Failed to update session limit policy")
+		return
+	}
+
+	response.Success(c, policy)
+}
+
+// DeletePolicy godoc
+// @Summary Delete session limit policy
+// @Description Delete a concurrent session limit policy
+// @Tags Sessions
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Policy ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /sessions/policies/{id} [delete]
+func (h *SessionHandler) DeletePolicy(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid policy ID")
+		return
+	}
+
+	if err := h.limitService.DeletePolicy(c.Request.Context(), id); err != nil {
+		respondSessionLimitError(c, err, "Failed to delete session limit policy")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Session limit policy deleted"})
+}
+
+// ListTimeoutPolicies godoc
+// @Summary List session timeout policies
+// @Description List idle timeout and sliding expiry policies, optionally for one organization
+// @Tags Sessions
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param organization_id query int false "Organization ID"
+// @Success 200 {object} response.Response{data=[]domain.SessionTimeoutPolicy}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /sessions/timeout-policies [get]
+func (h *SessionHandler) ListTimeoutPolicies(c *gin.Context) {
+	var orgID *int64
+	if value := c.Query("organization_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			response.BadRequest(c, "Invalid organization ID")
+			return
+		}
+		orgID = &id
+	}
+
+	policies, err := h.timeoutService.ListPolicies(c.Request.Context(), orgID)
+	if err != nil {
+		response.InternalError(c, "Failed to list session timeout policies")
+		return
+	}
+
+	response.Success(c, policies)
+}
+
+// CreateTimeoutPolicy godoc
+// @Summary Create session timeout policy
+// @Description Override the global idle timeout and sliding expiry for an organization, a device platform or both
+// @Tags Sessions
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.SessionTimeoutPolicyRequest true "Session timeout policy"
+// @Success 201 {object} response.Response{data=domain.SessionTimeoutPolicy}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /sessions/timeout-policies [post]
+func (h *SessionHandler) CreateTimeoutPolicy(c *gin.Context) {
+	var req service.SessionTimeoutPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	policy, err := h.timeoutService.CreatePolicy(c.Request.Context(), &req, middleware.GetUserID(c))
+	if err != nil {
+		respondSessionTimeoutError(c, err, "Failed to create session timeout policy")
+		return
+	}
+
+	response.Created(c, policy)
+}
+
+// UpdateTimeoutPolicy godoc
+// @Summary Update session timeout policy
+// @Description Replace a session timeout policy
+// @Tags Sessions
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Policy ID"
+// @Param request body service.SessionTimeoutPolicyRequest true "Session timeout policy"
+// @Success 200 {object} response.Response{data=domain.SessionTimeoutPolicy}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /sessions/timeout-policies/{id} [put]
+func (h *SessionHandler) UpdateTimeoutPolicy(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid policy ID")
+		return
+	}
+
+	var req service.SessionTimeoutPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	policy, err := h.timeoutService.UpdatePolicy(c.Request.Context(), id, &req, middleware.GetUserID(c))
+	if err != nil {
+		respondSessionTimeoutError(c, err, "Failed to update session timeout policy")
+		return
+	}
+
+	response.Success(c, policy)
+}
+
+// DeleteTimeoutPolicy godoc
+// @Summary Delete session timeout policy
+// @Description Delete a session timeout policy
+// @Tags Sessions
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Policy ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /sessions/timeout-policies/{id} [delete]
+func (h *SessionHandler) DeleteTimeoutPolicy(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid policy ID")
+		return
+	}
+
+	if err := h.timeoutService.DeletePolicy(c.Request.Context(), id); err != nil {
+		respondSessionTimeoutError(c, err, "Failed to delete session timeout policy")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Session timeout policy deleted"})
+}
+
+func respondSessionLimitError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrSessionLimitPolicyNotFound:
+		response.NotFound(c, "Session limit policy not found")
+	case service.ErrInvalidSessionLimitPolicy:
+		response.BadRequest(c, err.Error())
+	case service.ErrOrganizationNotFound:
+		response.BadRequest(c, "Organization not found")
+	case service.ErrRoleNotFound:
+		response.BadRequest(c, "Role not found")
+	default:
+		response.InternalError(c, fallback)
+	}
+}
+
+func respondSessionTimeoutError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrSessionTimeoutPolicyNotFound:
+		response.NotFound(c, "Session timeout policy not found")
+	case service.ErrInvalidSessionTimeoutPolicy:
+		response.BadRequest(c, err.Error())
+	case service.ErrOrganizationNotFound:
+		response.BadRequest(c, "Organization not found")
+	default:
+		response.InternalError(c, fallback)
+	}
+}
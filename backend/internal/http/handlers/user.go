@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"net/http"
 	"strconv"
 
 	"gebase/internal/http/response"
@@ -11,12 +13,14 @@ import (
 )
 
 type UserHandler struct {
-	userService *service.UserService
+	userService     *service.UserService
+	loginProtection *service.LoginProtectionService
 }
 
-func NewUserHandler(userService *service.UserService) *UserHandler {
+func NewUserHandler(userService *service.UserService, loginProtection *service.LoginProtectionService) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:     userService,
+		loginProtection: loginProtection,
 	}
 }
 
@@ -72,6 +76,9 @@ func (h *UserHandler) Create(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	user, err := h.userService.CreateUser(c.Request.Context(), &req, userID)
 	if err != nil {
+		if respondPasswordPolicy(c, err) {
+			return
+		}
 		switch err {
 		case service.ErrEmailAlreadyExists:
 			response.Conflict(c, "Email already exists")
@@ -280,15 +287,18 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 	}
 
 	var req struct {
-		Password string `json:"password" binding:"required,min=6"`
+		Password string `json:"password" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.BadRequest(c, "Password must be at least 6 characters")
+		response.BadRequest(c, "Password is required")
 		return
 	}
 
 	currentUserID := middleware.GetUserID(c)
 	if err := h.userService.ResetPassword(c.Request.Context(), id, req.Password, currentUserID); err != nil {
+		if respondPasswordPolicy(c, err) {
+			return
+		}
 		if err == service.ErrUserNotFound {
 			response.NotFound(c, "User not found")
 			return
@@ -299,3 +309,188 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 
 	response.Success(c, gin.H{"message": "Password reset successfully"})
 }
+
+// GetLockout godoc
+// @Summary Get user lockout status
+// @Description Get login lockout state and recent failure count of a user
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "User ID"
+// @Success 200 {object} response.Response{data=service.LoginLockStatus}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /users/{id}/lockout [get]
+func (h *UserHandler) GetLockout(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	if _, err := h.userService.GetUser(c.Request.Context(), id); err != nil {
+		response.NotFound(c, "User not found")
+		return
+	}
+
+	status, err := h.loginProtection.GetStatus(c.Request.Context(), id)
+	if err != nil {
+		response.InternalError(c, "Failed to get lockout status")
+		return
+	}
+
+	response.Success(c, status)
+}
+
+// Unlock godoc
+// @Summary Unlock user
+// @Description Lift a login lockout and reset the failed attempt count of a user
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "User ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /users/{id}/unlock [post]
+func (h *UserHandler) Unlock(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	if _, err := h.userService.GetUser(c.Request.Context(), id); err != nil {
+		response.NotFound(c, "User not found")
+		return
+	}
+
+	currentUserID := middleware.GetUserID(c)
+	if err := h.loginProtection.Unlock(c.Request.Context(), id, currentUserID); err != nil {
+		response.InternalError(c, "Failed to unlock user")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "User unlocked"})
+}
+
+// GetLoginAttempts godoc
+// @Summary List user login attempts
+// @Description Get paginated login attempt history of a user
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "User ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /users/{id}/login-attempts [get]
+func (h *UserHandler) GetLoginAttempts(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.loginProtection.ListAttempts(c.Request.Context(), id, page, pageSize)
+	if err != nil {
+		response.InternalError(c, "Failed to list login attempts")
+		return
+	}
+
+	response.SuccessWithMeta(c, result.Data, response.FromPagination(
+		result.Page, result.PageSize, result.Total, result.TotalPages,
+	))
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Change the current user's password. Also completes a forced password change.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.ChangePasswordRequest true "Old and new password"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Router /auth/change-password [put]
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var req service.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Old and new password are required")
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	if err := h.userService.ChangePassword(c.Request.Context(), userID, req.OldPassword, req.NewPassword); err != nil {
+		if respondPasswordPolicy(c, err) {
+			return
+		}
+		switch err {
+		case service.ErrInvalidCredentials:
+			response.Unauthorized(c, "Current password is incorrect")
+		case service.ErrUserNotFound:
+			response.NotFound(c, "User not found")
+		default:
+			response.InternalError(c, "Failed to change password")
+		}
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Password changed successfully. Refresh your token to continue."})
+}
+
+// GetPasswordPolicy godoc
+// @Summary Get password policy
+// @Description Get the password policy that applies to the current user
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} response.Response{data=service.PasswordPolicy}
+// @Failure 401 {object} response.Response
+// @Router /auth/password-policy [get]
+func (h *UserHandler) GetPasswordPolicy(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	policy, err := h.userService.GetPasswordPolicy(c.Request.Context(), userID)
+	if err != nil {
+		response.InternalError(c, "Failed to get password policy")
+		return
+	}
+
+	response.Success(c, policy)
+}
+
+// respondPasswordPolicy writes policy violations as validation details and
+// reports whether err was a policy error. Passwords kept in an organization
+// directory cannot be set here at all.
+func respondPasswordPolicy(c *gin.Context, err error) bool {
+	if errors.Is(err, service.ErrPasswordManagedByLDAP) {
+		response.Error(c, http.StatusConflict, "PASSWORD_MANAGED_EXTERNALLY", "Password is managed by the organization directory")
+		return true
+	}
+
+	var policyErr *service.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	details := make([]response.FieldError, len(policyErr.Violations))
+	for i, v := range policyErr.Violations {
+		details[i] = response.FieldError{Field: v.Field, Message: v.Message}
+	}
+	response.ErrorWithDetails(c, http.StatusBadRequest, "PASSWORD_POLICY_VIOLATION", "Password does not satisfy the password policy", details)
+	return true
+}
@@ -4,6 +4,7 @@ import (
 	"gebase/internal/config"
 	"gebase/internal/http/handlers"
 	"gebase/internal/middleware"
+	"gebase/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
@@ -38,6 +39,23 @@ func (r *Router) Setup(
 	systemHandler *handlers.SystemHandler,
 	roleHandler *handlers.RoleHandler,
 	menuHandler *handlers.MenuHandler,
+	keyHandler *handlers.KeyHandler,
+	mfaHandler *handlers.MFAHandler,
+	oidcHandler *handlers.OIDCHandler,
+	oauthHandler *handlers.OAuthHandler,
+	ldapHandler *handlers.LDAPHandler,
+	sessionHandler *handlers.SessionHandler,
+	jobHandler *handlers.JobHandler,
+	impersonationHandler *handlers.ImpersonationHandler,
+	accessTokenHandler *handlers.AccessTokenHandler,
+	serviceAccountHandler *handlers.ServiceAccountHandler,
+	deviceEnrollmentHandler *handlers.DeviceEnrollmentHandler,
+	deviceCommandHandler *handlers.DeviceCommandHandler,
+	deviceConfigHandler *handlers.DeviceConfigHandler,
+	devicePresenceHandler *handlers.DevicePresenceHandler,
+	pushHandler *handlers.PushHandler,
+	loginRiskHandler *handlers.LoginRiskHandler,
+	permissionHandler *handlers.PermissionHandler,
 ) *gin.Engine {
 	// Global middleware
 	r.engine.Use(middleware.CORS(r.cfg))
@@ -48,36 +66,62 @@ func (r *Router) Setup(
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Public signing keys for services verifying our tokens
+	r.engine.GET("/.well-known/jwks.json", keyHandler.JWKS)
+
+	// OAuth 2.0 / OIDC authorization server protocol endpoints
+	r.engine.GET("/.well-known/openid-configuration", oauthHandler.Discovery)
+	r.engine.GET("/.well-known/oauth-authorization-server", oauthHandler.Discovery)
+	oauth := r.engine.Group("/oauth")
+	{
+		oauth.GET("/authorize", oauthHandler.Authorize)
+		oauth.POST("/token", oauthHandler.Token)
+		oauth.GET("/userinfo", authMiddleware.OAuth(service.ScopeOpenID), oauthHandler.UserInfo)
+		oauth.POST("/userinfo", authMiddleware.OAuth(service.ScopeOpenID), oauthHandler.UserInfo)
+	}
+
 	// API v1
 	api := r.engine.Group("/api/v1")
 
 	// Public routes
-	r.setupPublicRoutes(api, authHandler, deviceHandler)
+	r.setupPublicRoutes(api, authMiddleware, authHandler, deviceHandler, oidcHandler, deviceCommandHandler, deviceConfigHandler)
 
 	// Protected routes
 	r.setupProtectedRoutes(api, authMiddleware, rbacMiddleware, deviceMiddleware,
-		authHandler, deviceHandler, userHandler, orgHandler, systemHandler, roleHandler, menuHandler)
+		authHandler, deviceHandler, userHandler, orgHandler, systemHandler, roleHandler, menuHandler, mfaHandler, oauthHandler, ldapHandler, sessionHandler, jobHandler, impersonationHandler, accessTokenHandler, serviceAccountHandler, deviceEnrollmentHandler, deviceCommandHandler, deviceConfigHandler, devicePresenceHandler, pushHandler, loginRiskHandler, permissionHandler)
 
 	return r.engine
 }
 
 func (r *Router) setupPublicRoutes(
 	api *gin.RouterGroup,
+	authMiddleware *middleware.AuthMiddleware,
 	authHandler *handlers.AuthHandler,
 	deviceHandler *handlers.DeviceHandler,
+	oidcHandler *handlers.OIDCHandler,
+	deviceCommandHandler *handlers.DeviceCommandHandler,
+	deviceConfigHandler *handlers.DeviceConfigHandler,
 ) {
 	// Auth
 	auth := api.Group("/auth")
 	{
 		auth.POST("/login", authHandler.Login)
+		auth.POST("/login/mfa", authHandler.LoginMFA)
+		auth.POST("/login/mfa/enroll", authHandler.LoginMFAEnroll)
 		auth.POST("/refresh", authHandler.RefreshToken)
+		auth.POST("/forgot-password", authHandler.ForgotPassword)
+		auth.POST("/reset-password", authHandler.ResetPassword)
+		auth.POST("/oidc/login", oidcHandler.Login)
+		auth.POST("/oidc/callback", oidcHandler.Callback)
 	}
 
 	// Device registration
 	devices := api.Group("/devices")
 	{
-		devices.POST("/register", deviceHandler.Register)
+		devices.POST("/register", authMiddleware.OptionalAuth(), deviceHandler.Register)
 		devices.POST("/heartbeat", deviceHandler.Heartbeat)
+		devices.POST("/commands/:command_id/ack", deviceCommandHandler.Acknowledge)
+		devices.GET("/config", deviceConfigHandler.Fetch)
 	}
 }
 
@@ -93,22 +137,78 @@ func (r *Router) setupProtectedRoutes(
 	systemHandler *handlers.SystemHandler,
 	roleHandler *handlers.RoleHandler,
 	menuHandler *handlers.MenuHandler,
+	mfaHandler *handlers.MFAHandler,
+	oauthHandler *handlers.OAuthHandler,
+	ldapHandler *handlers.LDAPHandler,
+	sessionHandler *handlers.SessionHandler,
+	jobHandler *handlers.JobHandler,
+	impersonationHandler *handlers.ImpersonationHandler,
+	accessTokenHandler *handlers.AccessTokenHandler,
+	serviceAccountHandler *handlers.ServiceAccountHandler,
+	deviceEnrollmentHandler *handlers.DeviceEnrollmentHandler,
+	deviceCommandHandler *handlers.DeviceCommandHandler,
+	deviceConfigHandler *handlers.DeviceConfigHandler,
+	devicePresenceHandler *handlers.DevicePresenceHandler,
+	pushHandler *handlers.PushHandler,
+	loginRiskHandler *handlers.LoginRiskHandler,
+	permissionHandler *handlers.PermissionHandler,
 ) {
-	// Protected routes require auth and device verification
-	protected := api.Group("")
-	protected.Use(authMiddleware.Auth())
-	protected.Use(deviceMiddleware.Device())
+	// Authenticated routes require auth and device verification. Personal
+	// access tokens are accepted without device headers.
+	authenticated := api.Group("")
+	authenticated.Use(authMiddleware.Auth())
+	authenticated.Use(deviceMiddleware.Device())
+
+	// Auth routes still available while a password change is pending
+	account := authenticated.Group("/auth")
+	account.Use(authMiddleware.RequireSession())
+	{
+		account.POST("/logout", authHandler.Logout)
+		account.GET("/me", authHandler.Me)
+		account.PUT("/change-password", authMiddleware.DenyImpersonation(), userHandler.ChangePassword)
+		account.POST("/reauthenticate", authMiddleware.DenyImpersonation(), authHandler.Reauthenticate)
+		account.GET("/password-policy", userHandler.GetPasswordPolicy)
+		account.POST("/impersonation/stop", impersonationHandler.Stop)
+	}
+
+	// Protected routes additionally require a current password
+	protected := authenticated.Group("")
+	protected.Use(authMiddleware.RequireCurrentPassword())
 
 	// Auth routes (authenticated)
 	auth := protected.Group("/auth")
+	auth.Use(authMiddleware.RequireSession())
 	{
-		auth.POST("/logout", authHandler.Logout)
-		auth.GET("/me", authHandler.Me)
 		auth.POST("/switch-system", authHandler.SwitchSystem)
 		auth.POST("/exit-system", authHandler.ExitSystem)
 		auth.GET("/systems", authHandler.GetAvailableSystems)
 		auth.GET("/permissions", authHandler.GetPermissions)
 		auth.GET("/menus", authHandler.GetMenus)
+		auth.GET("/mfa", mfaHandler.GetStatus)
+		auth.POST("/mfa/enroll", authMiddleware.DenyImpersonation(), mfaHandler.Enroll)
+		auth.POST("/mfa/confirm", authMiddleware.DenyImpersonation(), mfaHandler.Confirm)
+		auth.POST("/mfa/disable", authMiddleware.DenyImpersonation(), mfaHandler.Disable)
+		auth.POST("/mfa/recovery-codes", authMiddleware.DenyImpersonation(), mfaHandler.RegenerateRecoveryCodes)
+		auth.GET("/oauth/consents", oauthHandler.ListConsents)
+		auth.DELETE("/oauth/consents/:client_id", authMiddleware.DenyImpersonation(), oauthHandler.RevokeConsent)
+		auth.GET("/sessions", sessionHandler.ListMine)
+		auth.DELETE("/sessions", authMiddleware.DenyImpersonation(), sessionHandler.RevokeOthers)
+		auth.DELETE("/sessions/:id", authMiddleware.DenyImpersonation(), sessionHandler.RevokeMine)
+		auth.GET("/tokens", accessTokenHandler.ListMine)
+		auth.POST("/tokens", authMiddleware.DenyImpersonation(), accessTokenHandler.CreateMine)
+		auth.DELETE("/tokens/:id", authMiddleware.DenyImpersonation(), accessTokenHandler.RevokeMine)
+	}
+
+	// OAuth authorization decisions and client registration
+	oauth := protected.Group("/oauth")
+	{
+		oauth.POST("/authorize", authMiddleware.RequireSession(), authMiddleware.DenyImpersonation(), oauthHandler.Decide)
+		oauth.GET("/clients", rbacMiddleware.RequirePermission("admin.system.view"), oauthHandler.ListClients)
+		oauth.POST("/clients", rbacMiddleware.RequirePermission("admin.system.create"), oauthHandler.CreateClient)
+		oauth.GET("/clients/:id", rbacMiddleware.RequirePermission("admin.system.view"), oauthHandler.GetClient)
+		oauth.PUT("/clients/:id", rbacMiddleware.RequirePermission("admin.system.update"), oauthHandler.UpdateClient)
+		oauth.DELETE("/clients/:id", rbacMiddleware.RequirePermission("admin.system.delete"), oauthHandler.DeleteClient)
+		oauth.POST("/clients/:id/rotate-secret", rbacMiddleware.RequirePermission("admin.system.update"), oauthHandler.RotateClientSecret)
 	}
 
 	// Users
@@ -116,12 +216,63 @@ func (r *Router) setupProtectedRoutes(
 	{
 		users.GET("", rbacMiddleware.RequirePermission("admin.user.view"), userHandler.List)
 		users.POST("", rbacMiddleware.RequirePermission("admin.user.create"), userHandler.Create)
+		users.GET("/login-risk-events", rbacMiddleware.RequirePermission("admin.user.view"), loginRiskHandler.ListEvents)
+		users.GET("/login-risk-policies", rbacMiddleware.RequirePermission("admin.user.view"), loginRiskHandler.ListPolicies)
+		users.POST("/login-risk-policies", rbacMiddleware.RequirePermission("admin.user.create"), loginRiskHandler.CreatePolicy)
+		users.PUT("/login-risk-policies/:id", rbacMiddleware.RequirePermission("admin.user.update"), loginRiskHandler.UpdatePolicy)
+		users.DELETE("/login-risk-policies/:id", rbacMiddleware.RequirePermission("admin.user.delete"), loginRiskHandler.DeletePolicy)
 		users.GET("/:id", rbacMiddleware.RequirePermission("admin.user.view"), userHandler.Get)
 		users.PUT("/:id", rbacMiddleware.RequirePermission("admin.user.update"), userHandler.Update)
 		users.DELETE("/:id", rbacMiddleware.RequirePermission("admin.user.delete"), userHandler.Delete)
 		users.GET("/:id/roles", rbacMiddleware.RequirePermission("admin.user.view"), userHandler.GetRoles)
 		users.PUT("/:id/roles", rbacMiddleware.RequirePermission("admin.user.update"), userHandler.AssignRoles)
-		users.POST("/:id/reset-password", rbacMiddleware.RequirePermission("admin.user.update"), userHandler.ResetPassword)
+		users.POST("/:id/reset-password", rbacMiddleware.RequirePermission("admin.user.reset_password"), userHandler.ResetPassword)
+		users.GET("/:id/lockout", rbacMiddleware.RequirePermission("admin.user.view"), userHandler.GetLockout)
+		users.POST("/:id/unlock", rbacMiddleware.RequirePermission("admin.user.update"), userHandler.Unlock)
+		users.GET("/:id/login-attempts", rbacMiddleware.RequirePermission("admin.user.view"), userHandler.GetLoginAttempts)
+		users.GET("/:id/login-risk-events", rbacMiddleware.RequirePermission("admin.user.view"), loginRiskHandler.ListUserEvents)
+		users.DELETE("/:id/mfa", rbacMiddleware.RequirePermission("admin.user.update"), mfaHandler.Reset)
+		users.GET("/:id/sessions", rbacMiddleware.RequirePermission("admin.session.view"), sessionHandler.ListForUser)
+		users.DELETE("/:id/sessions", rbacMiddleware.RequirePermission("admin.session.delete"), sessionHandler.RevokeAllForUser)
+		users.DELETE("/:id/sessions/:session_id", rbacMiddleware.RequirePermission("admin.session.delete"), sessionHandler.RevokeForUser)
+		users.POST("/:id/impersonate", rbacMiddleware.RequirePermission("admin.user.impersonate"), impersonationHandler.Start)
+		users.GET("/:id/impersonation-events", rbacMiddleware.RequirePermission("admin.user.view"), impersonationHandler.ListEvents)
+		users.GET("/:id/tokens", rbacMiddleware.RequirePermission("admin.user.view"), accessTokenHandler.ListForUser)
+		users.DELETE("/:id/tokens/:token_id", rbacMiddleware.RequirePermission("admin.user.update"), accessTokenHandler.RevokeForUser)
+	}
+
+	// Service accounts
+	serviceAccounts := protected.Group("/service-accounts")
+	{
+		serviceAccounts.GET("", rbacMiddleware.RequirePermission("admin.user.view"), serviceAccountHandler.List)
+		serviceAccounts.POST("", rbacMiddleware.RequirePermission("admin.user.create"), serviceAccountHandler.Create)
+		serviceAccounts.GET("/:id", rbacMiddleware.RequirePermission("admin.user.view"), serviceAccountHandler.Get)
+		serviceAccounts.PUT("/:id", rbacMiddleware.RequirePermission("admin.user.update"), serviceAccountHandler.Update)
+		serviceAccounts.DELETE("/:id", rbacMiddleware.RequirePermission("admin.user.delete"), serviceAccountHandler.Delete)
+		serviceAccounts.GET("/:id/tokens", rbacMiddleware.RequirePermission("admin.user.view"), serviceAccountHandler.ListTokens)
+		serviceAccounts.POST("/:id/tokens", rbacMiddleware.RequirePermission("admin.user.update"), serviceAccountHandler.CreateToken)
+		serviceAccounts.DELETE("/:id/tokens/:token_id", rbacMiddleware.RequirePermission("admin.user.update"), serviceAccountHandler.RevokeToken)
+	}
+
+	// Push notifications
+	pushes := protected.Group("/push")
+	{
+		pushes.POST("/send", rbacMiddleware.RequirePermission("admin.push.send"), pushHandler.Send)
+		pushes.GET("/notifications", rbacMiddleware.RequirePermission("admin.device.view"), pushHandler.ListNotifications)
+		pushes.GET("/notifications/:id", rbacMiddleware.RequirePermission("admin.device.view"), pushHandler.GetNotification)
+		pushes.GET("/notifications/:id/deliveries", rbacMiddleware.RequirePermission("admin.device.view"), pushHandler.ListDeliveries)
+		pushes.GET("/templates", rbacMiddleware.RequirePermission("admin.device.view"), pushHandler.ListTemplates)
+		pushes.POST("/templates", rbacMiddleware.RequirePermission("admin.device.create"), pushHandler.CreateTemplate)
+		pushes.GET("/templates/:id", rbacMiddleware.RequirePermission("admin.device.view"), pushHandler.GetTemplate)
+		pushes.PUT("/templates/:id", rbacMiddleware.RequirePermission("admin.device.update"), pushHandler.UpdateTemplate)
+		pushes.DELETE("/templates/:id", rbacMiddleware.RequirePermission("admin.device.delete"), pushHandler.DeleteTemplate)
+	}
+
+	// Internal APIs called by other services with their service account's
+	// access token
+	internal := protected.Group("/internal", authMiddleware.RequireAccessToken())
+	{
+		internal.POST("/push/send", rbacMiddleware.RequirePermission("admin.push.send"), pushHandler.SendInternal)
 	}
 
 	// Organizations
@@ -137,6 +288,13 @@ func (r *Router) setupProtectedRoutes(
 		orgs.GET("/:id/systems", rbacMiddleware.RequirePermission("admin.organization.view"), orgHandler.GetSystems)
 		orgs.POST("/:id/systems", rbacMiddleware.RequirePermission("admin.organization.update"), orgHandler.EnableSystem)
 		orgs.DELETE("/:id/systems/:system_id", rbacMiddleware.RequirePermission("admin.organization.update"), orgHandler.DisableSystem)
+		orgs.GET("/:id/security-policy", rbacMiddleware.RequirePermission("admin.organization.view"), orgHandler.GetSecurityPolicy)
+		orgs.PUT("/:id/security-policy", rbacMiddleware.RequirePermission("admin.organization.update"), orgHandler.UpdateSecurityPolicy)
+		orgs.GET("/:id/ldap", rbacMiddleware.RequirePermission("admin.organization.view"), ldapHandler.GetConfig)
+		orgs.PUT("/:id/ldap", rbacMiddleware.RequirePermission("admin.organization.update"), ldapHandler.UpdateConfig)
+		orgs.GET("/:id/ldap/group-mappings", rbacMiddleware.RequirePermission("admin.organization.view"), ldapHandler.ListGroupMappings)
+		orgs.POST("/:id/ldap/group-mappings", rbacMiddleware.RequirePermission("admin.organization.update"), ldapHandler.CreateGroupMapping)
+		orgs.DELETE("/:id/ldap/group-mappings/:mapping_id", rbacMiddleware.RequirePermission("admin.organization.update"), ldapHandler.DeleteGroupMapping)
 	}
 
 	// Systems
@@ -160,11 +318,18 @@ func (r *Router) setupProtectedRoutes(
 		roles.PUT("/:id", rbacMiddleware.RequirePermission("admin.role.update"), roleHandler.Update)
 		roles.DELETE("/:id", rbacMiddleware.RequirePermission("admin.role.delete"), roleHandler.Delete)
 		roles.GET("/:id/permissions", rbacMiddleware.RequirePermission("admin.role.view"), roleHandler.GetPermissions)
-		roles.PUT("/:id/permissions", rbacMiddleware.RequirePermission("admin.role.update"), roleHandler.AssignPermissions)
+		roles.PUT("/:id/permissions", rbacMiddleware.RequirePermission("admin.role.assign_permissions"), roleHandler.AssignPermissions)
 		roles.GET("/:id/menus", rbacMiddleware.RequirePermission("admin.role.view"), roleHandler.GetMenus)
 		roles.PUT("/:id/menus", rbacMiddleware.RequirePermission("admin.role.update"), roleHandler.AssignMenus)
 	}
 
+	// Permissions
+	permissions := protected.Group("/permissions")
+	{
+		permissions.GET("", rbacMiddleware.RequirePermission("admin.permission.view"), permissionHandler.List)
+		permissions.PUT("/:id", rbacMiddleware.RequirePermission("admin.permission.update"), permissionHandler.Update)
+	}
+
 	// Menus
 	menus := protected.Group("/menus")
 	{
@@ -180,11 +345,67 @@ func (r *Router) setupProtectedRoutes(
 	devices := protected.Group("/devices")
 	{
 		devices.GET("", rbacMiddleware.RequirePermission("admin.device.view"), deviceHandler.List)
+		devices.GET("/pending", rbacMiddleware.RequirePermission("admin.device.view"), deviceEnrollmentHandler.ListPending)
+		devices.GET("/stats", rbacMiddleware.RequirePermission("admin.device.view"), devicePresenceHandler.Stats)
+		devices.GET("/online", rbacMiddleware.RequirePermission("admin.device.view"), devicePresenceHandler.ListOnline)
+		devices.GET("/alerts", rbacMiddleware.RequirePermission("admin.device.view"), devicePresenceHandler.ListAlerts)
+		devices.GET("/uptime", rbacMiddleware.RequirePermission("admin.device.view"), devicePresenceHandler.FleetUptime)
+		devices.GET("/presence-policies", rbacMiddleware.RequirePermission("admin.device.view"), devicePresenceHandler.ListPolicies)
+		devices.POST("/presence-policies", rbacMiddleware.RequirePermission("admin.device.create"), devicePresenceHandler.CreatePolicy)
+		devices.PUT("/presence-policies/:id", rbacMiddleware.RequirePermission("admin.device.update"), devicePresenceHandler.UpdatePolicy)
+		devices.DELETE("/presence-policies/:id", rbacMiddleware.RequirePermission("admin.device.delete"), devicePresenceHandler.DeletePolicy)
+		devices.GET("/enrollment-policies", rbacMiddleware.RequirePermission("admin.device.view"), deviceEnrollmentHandler.ListPolicies)
+		devices.POST("/enrollment-policies", rbacMiddleware.RequirePermission("admin.device.create"), deviceEnrollmentHandler.CreatePolicy)
+		devices.PUT("/enrollment-policies/:id", rbacMiddleware.RequirePermission("admin.device.update"), deviceEnrollmentHandler.UpdatePolicy)
+		devices.DELETE("/enrollment-policies/:id", rbacMiddleware.RequirePermission("admin.device.delete"), deviceEnrollmentHandler.DeletePolicy)
+		devices.GET("/config-profiles", rbacMiddleware.RequirePermission("admin.device.view"), deviceConfigHandler.ListProfiles)
+		devices.POST("/config-profiles", rbacMiddleware.RequirePermission("admin.device.update"), deviceConfigHandler.CreateProfile)
+		devices.GET("/config-profiles/:id", rbacMiddleware.RequirePermission("admin.device.view"), deviceConfigHandler.GetProfile)
+		devices.PUT("/config-profiles/:id", rbacMiddleware.RequirePermission("admin.device.update"), deviceConfigHandler.UpdateProfile)
+		devices.DELETE("/config-profiles/:id", rbacMiddleware.RequirePermission("admin.device.update"), deviceConfigHandler.DeleteProfile)
+		devices.GET("/config-profiles/:id/versions", rbacMiddleware.RequirePermission("admin.device.view"), deviceConfigHandler.ListVersions)
+		devices.POST("/config-profiles/:id/rollback", rbacMiddleware.RequirePermission("admin.device.update"), deviceConfigHandler.Rollback)
+		devices.GET("/config-schemas", rbacMiddleware.RequirePermission("admin.device.view"), deviceConfigHandler.ListSchemas)
+		devices.GET("/config-schemas/:platform", rbacMiddleware.RequirePermission("admin.device.view"), deviceConfigHandler.GetSchema)
+		devices.PUT("/config-schemas/:platform", rbacMiddleware.RequirePermission("admin.device.update"), deviceConfigHandler.PutSchema)
+		devices.DELETE("/config-schemas/:platform", rbacMiddleware.RequirePermission("admin.device.update"), deviceConfigHandler.DeleteSchema)
 		devices.GET("/:id", rbacMiddleware.RequirePermission("admin.device.view"), deviceHandler.Get)
 		devices.PUT("/:id", rbacMiddleware.RequirePermission("admin.device.update"), deviceHandler.Update)
 		devices.DELETE("/:id", rbacMiddleware.RequirePermission("admin.device.delete"), deviceHandler.Deactivate)
-		devices.PUT("/:id/config", rbacMiddleware.RequirePermission("admin.device.update"), deviceHandler.UpdateConfig)
+		devices.GET("/:id/config", rbacMiddleware.RequirePermission("admin.device.view"), deviceConfigHandler.GetDeviceConfig)
+		devices.PUT("/:id/config", rbacMiddleware.RequirePermission("admin.device.update"), deviceConfigHandler.SetDeviceConfig)
+		devices.DELETE("/:id/key", rbacMiddleware.RequirePermission("admin.device.update"), deviceHandler.ResetKey)
+		devices.POST("/:id/key/approve", rbacMiddleware.RequirePermission("admin.device.update"), deviceHandler.ApproveKey)
+		devices.GET("/:id/commands", rbacMiddleware.RequirePermission("admin.device.view"), deviceCommandHandler.List)
+		devices.POST("/:id/commands", rbacMiddleware.RequirePermission("admin.device.update"), deviceCommandHandler.Enqueue)
+		devices.DELETE("/:id/commands/:command_id", rbacMiddleware.RequirePermission("admin.device.update"), deviceCommandHandler.Cancel)
 		devices.GET("/:id/sessions", rbacMiddleware.RequirePermission("admin.device.view"), deviceHandler.GetSessions)
+		devices.GET("/:id/presence", rbacMiddleware.RequirePermission("admin.device.view"), devicePresenceHandler.ListEvents)
+		devices.GET("/:id/uptime", rbacMiddleware.RequirePermission("admin.device.view"), devicePresenceHandler.DeviceUptime)
+		devices.POST("/:id/approve", rbacMiddleware.RequirePermission("admin.device.update"), deviceEnrollmentHandler.Approve)
+		devices.POST("/:id/reject", rbacMiddleware.RequirePermission("admin.device.update"), deviceEnrollmentHandler.Reject)
+	}
+
+	// Sessions (monitoring)
+	sessions := protected.Group("/sessions")
+	{
+		sessions.GET("", rbacMiddleware.RequirePermission("admin.session.view"), sessionHandler.List)
+		sessions.GET("/policies", rbacMiddleware.RequirePermission("admin.session.view"), sessionHandler.ListPolicies)
+		sessions.POST("/policies", rbacMiddleware.RequirePermission("admin.session.create"), sessionHandler.CreatePolicy)
+		sessions.PUT("/policies/:id", rbacMiddleware.RequirePermission("admin.session.update"), sessionHandler.UpdatePolicy)
+		sessions.DELETE("/policies/:id", rbacMiddleware.RequirePermission("admin.session.delete"), sessionHandler.DeletePolicy)
+		sessions.GET("/timeout-policies", rbacMiddleware.RequirePermission("admin.session.view"), sessionHandler.ListTimeoutPolicies)
+		sessions.POST("/timeout-policies", rbacMiddleware.RequirePermission("admin.session.create"), sessionHandler.CreateTimeoutPolicy)
+		sessions.PUT("/timeout-policies/:id", rbacMiddleware.RequirePermission("admin.session.update"), sessionHandler.UpdateTimeoutPolicy)
+		sessions.DELETE("/timeout-policies/:id", rbacMiddleware.RequirePermission("admin.session.delete"), sessionHandler.DeleteTimeoutPolicy)
+	}
+
+	// Background jobs (monitoring)
+	jobs := protected.Group("/jobs")
+	{
+		jobs.GET("", rbacMiddleware.RequirePermission("admin.monitoring.view"), jobHandler.List)
+		jobs.GET("/runs", rbacMiddleware.RequirePermission("admin.monitoring.view"), jobHandler.ListRuns)
+		jobs.POST("/:name/run", rbacMiddleware.RequirePermission("admin.monitoring.update"), jobHandler.Run)
 	}
 }
 
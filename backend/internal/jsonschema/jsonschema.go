@@ -0,0 +1,405 @@
+// Package jsonschema validates JSON documents against a subset of JSON
+// Schema (draft 2020-12) that covers configuration documents: type, enum,
+// const, properties, required, additionalProperties, items, minItems,
+// maxItems, uniqueItems, minimum, maximum, exclusiveMinimum,
+// exclusiveMaximum, multipleOf, minLength, maxLength and pattern.
+// Annotations ($schema, $id, $comment, title, description, default,
+// examples, deprecated, readOnly, writeOnly) are accepted and ignored; any
+// other keyword is rejected when the schema is compiled rather than
+// silently skipped.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var annotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true, "deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+var types = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true,
+	"number": true, "integer": true, "string": true,
+}
+
+// Schema is a compiled schema
+type Schema struct {
+	// always is set for the boolean schemas true and false
+	always *bool
+
+	types                []string
+	enum                 []interface{}
+	constValue           *interface{}
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	items                *Schema
+	minItems, maxItems   *int
+	uniqueItems          bool
+	minimum, maximum     *float64
+	exclusiveMinimum     *float64
+	exclusiveMaximum     *float64
+	multipleOf           *float64
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+}
+
+// ValidationError is one way a document fails a schema
+type ValidationError struct {
+	// Path is the JSON pointer (RFC 6901) of the offending value, "" for the document
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Compile parses a schema document
+func Compile(raw []byte) (*Schema, error) {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+	return compile(doc, "")
+}
+
+func compile(doc interface{}, path string) (*Schema, error) {
+	if always, ok := doc.(bool); ok {
+		return &Schema{always: &always}, nil
+	}
+	fields, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, &compileError{path: path, err: fmt.Errorf("schema must be an object or a boolean")}
+	}
+
+	s := &Schema{}
+	for keyword, value := range fields {
+		at := path + "/" + escape(keyword)
+		var err error
+		switch keyword {
+		case "type":
+			s.types, err = compileTypes(value)
+		case "enum":
+			values, isArray := value.([]interface{})
+			if !isArray || len(values) == 0 {
+				err = fmt.Errorf("must be a non-empty array")
+			}
+			s.enum = values
+		case "const":
+			constValue := value
+			s.constValue = &constValue
+		case "properties":
+			properties, isObject := value.(map[string]interface{})
+			if !isObject {
+				err = fmt.Errorf("must be an object")
+				break
+			}
+			s.properties = make(map[string]*Schema, len(properties))
+			for name, property := range properties {
+				if s.properties[name], err = compile(property, at+"/"+escape(name)); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			s.required, err = compileStrings(value)
+		case "additionalProperties":
+			s.additionalProperties, err = compile(value, at)
+		case "items":
+			s.items, err = compile(value, at)
+		case "minItems":
+			s.minItems, err = compileCount(value)
+		case "maxItems":
+			s.maxItems, err = compileCount(value)
+		case "uniqueItems":
+			unique, isBool := value.(bool)
+			if !isBool {
+				err = fmt.Errorf("must be a boolean")
+			}
+			s.uniqueItems = unique
+		case "minimum":
+			s.minimum, err = compileNumber(value)
+		case "maximum":
+			s.maximum, err = compileNumber(value)
+		case "exclusiveMinimum":
+			s.exclusiveMinimum, err = compileNumber(value)
+		case "exclusiveMaximum":
+			s.exclusiveMaximum, err = compileNumber(value)
+		case "multipleOf":
+			if s.multipleOf, err = compileNumber(value); err == nil && *s.multipleOf <= 0 {
+				err = fmt.Errorf("must be greater than 0")
+			}
+		case "minLength":
+			s.minLength, err = compileCount(value)
+		case "maxLength":
+			s.maxLength, err = compileCount(value)
+		case "pattern":
+			expr, isString := value.(string)
+			if !isString {
+				err = fmt.Errorf("must be a string")
+				break
+			}
+			s.pattern, err = regexp.Compile(expr)
+		default:
+			if !annotations[keyword] {
+				err = fmt.Errorf("unsupported keyword")
+			}
+		}
+		if err != nil {
+			if _, nested := err.(*compileError); nested {
+				return nil, err
+			}
+			return nil, &compileError{path: at, err: err}
+		}
+	}
+	return s, nil
+}
+
+type compileError struct {
+	path string
+	err  error
+}
+
+func (e *compileError) Error() string {
+	return pointer(e.path) + ": " + e.err.Error()
+}
+
+func compileTypes(value interface{}) ([]string, error) {
+	var names []string
+	switch v := value.(type) {
+	case string:
+		names = []string{v}
+	case []interface{}:
+		strs, err := compileStrings(v)
+		if err != nil {
+			return nil, err
+		}
+		names = strs
+	default:
+		return nil, fmt.Errorf("must be a type name or an array of them")
+	}
+	for _, name := range names {
+		if !types[name] {
+			return nil, fmt.Errorf("unknown type %q", name)
+		}
+	}
+	return names, nil
+}
+
+func compileStrings(value interface{}) ([]string, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be an array of strings")
+	}
+	strs := make([]string, len(values))
+	for i, v := range values {
+		str, isString := v.(string)
+		if !isString {
+			return nil, fmt.Errorf("must be an array of strings")
+		}
+		strs[i] = str
+	}
+	return strs, nil
+}
+
+func compileNumber(value interface{}) (*float64, error) {
+	number, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("must be a number")
+	}
+	return &number, nil
+}
+
+func compileCount(value interface{}) (*int, error) {
+	number, ok := value.(float64)
+	if !ok || number < 0 || number != math.Trunc(number) {
+		return nil, fmt.Errorf("must be a non-negative integer")
+	}
+	count := int(number)
+	return &count, nil
+}
+
+// Validate checks a document decoded with encoding/json (objects as
+// map[string]interface{}, numbers as float64) and returns every violation,
+// ordered by path
+func (s *Schema) Validate(doc interface{}) []ValidationError {
+	var errs []ValidationError
+	s.validate(doc, "", &errs)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	return errs
+}
+
+func (s *Schema) validate(value interface{}, path string, errs *[]ValidationError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.always != nil {
+		if !*s.always {
+			fail("is not allowed")
+		}
+		return
+	}
+
+	if len(s.types) > 0 && !matchesType(value, s.types) {
+		fail("must be %s", strings.Join(s.types, " or "))
+		return
+	}
+	if s.constValue != nil && !reflect.DeepEqual(value, *s.constValue) {
+		fail("must be %s", display(*s.constValue))
+	}
+	if s.enum != nil && !contains(s.enum, value) {
+		options := make([]string, len(s.enum))
+		for i, option := range s.enum {
+			options[i] = display(option)
+		}
+		fail("must be one of %s", strings.Join(options, ", "))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		s.validateObject(v, path, errs)
+	case []interface{}:
+		s.validateArray(v, path, errs)
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			fail("must be at least %v", *s.minimum)
+		}
+		if s.maximum != nil && v > *s.maximum {
+			fail("must be at most %v", *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && v <= *s.exclusiveMinimum {
+			fail("must be greater than %v", *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && v >= *s.exclusiveMaximum {
+			fail("must be less than %v", *s.exclusiveMaximum)
+		}
+		if s.multipleOf != nil {
+			quotient := v / *s.multipleOf
+			if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+				fail("must be a multiple of %v", *s.multipleOf)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.minLength != nil && length < *s.minLength {
+			fail("must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			fail("must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match %s", s.pattern.String())
+		}
+	}
+}
+
+func (s *Schema) validateObject(object map[string]interface{}, path string, errs *[]ValidationError) {
+	for _, name := range s.required {
+		if _, ok := object[name]; !ok {
+			*errs = append(*errs, ValidationError{Path: path + "/" + escape(name), Message: "is required"})
+		}
+	}
+	for name, value := range object {
+		at := path + "/" + escape(name)
+		if property, ok := s.properties[name]; ok {
+			property.validate(value, at, errs)
+		} else if s.additionalProperties != nil {
+			s.additionalProperties.validate(value, at, errs)
+		}
+	}
+}
+
+func (s *Schema) validateArray(array []interface{}, path string, errs *[]ValidationError) {
+	if s.minItems != nil && len(array) < *s.minItems {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("must have at least %d items", *s.minItems)})
+	}
+	if s.maxItems != nil && len(array) > *s.maxItems {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("must have at most %d items", *s.maxItems)})
+	}
+	if s.uniqueItems {
+		for i := range array {
+			if contains(array[:i], array[i]) {
+				*errs = append(*errs, ValidationError{Path: path, Message: "items must be unique"})
+				break
+			}
+		}
+	}
+	if s.items != nil {
+		for i, item := range array {
+			s.items.validate(item, fmt.Sprintf("%s/%d", path, i), errs)
+		}
+	}
+}
+
+func matchesType(value interface{}, names []string) bool {
+	for _, name := range names {
+		switch v := value.(type) {
+		case nil:
+			if name == "null" {
+				return true
+			}
+		case bool:
+			if name == "boolean" {
+				return true
+			}
+		case map[string]interface{}:
+			if name == "object" {
+				return true
+			}
+		case []interface{}:
+			if name == "array" {
+				return true
+			}
+		case float64:
+			if name == "number" || (name == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case string:
+			if name == "string" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func display(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// escape encodes a property name as a JSON pointer reference token
+func escape(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
+
+func pointer(path string) string {
+	if path == "" {
+		return "schema"
+	}
+	return path
+}
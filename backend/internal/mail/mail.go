@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"gebase/internal/config"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email messages
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewSender returns an SMTP sender when SMTP_HOST is set, otherwise a sender
+// that only logs messages (development)
+func NewSender(cfg *config.Config) Sender {
+	if cfg.Mail.SMTPHost == "" {
+		return &LogSender{}
+	}
+	return NewSMTPSender(cfg)
+}
+
+// LogSender writes messages to the application log instead of delivering them
+type LogSender struct{}
+
+func (s *LogSender) Send(ctx context.Context, msg *Message) error {
+	log.Printf("Mail to %s: %s\n%s", strings.Join(msg.To, ", "), msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,179 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+
+	"gebase/internal/config"
+)
+
+// fakeSMTPSession is what a fakeSMTPServer received in one connection
+type fakeSMTPSession struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTPServer accepts one connection on the loopback interface and
+// records the transaction, offering AUTH PLAIN but not STARTTLS
+type fakeSMTPServer struct {
+	listener net.Listener
+	sessions chan fakeSMTPSession
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &fakeSMTPServer{listener: listener, sessions: make(chan fakeSMTPSession, 1)}
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTPServer) port() string {
+	return strings.TrimPrefix(s.listener.Addr().String(), "127.0.0.1:")
+}
+
+func (s *fakeSMTPServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var session fakeSMTPSession
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-fake")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			_, credentials, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(credentials)
+			session.auth = string(decoded)
+			reply("235 authenticated")
+		case "MAIL":
+			session.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			reply("250 ok")
+		case "RCPT":
+			session.to = append(session.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			session.data = data.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			s.sessions <- session
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func newTestSMTPSender(server *fakeSMTPServer, username string) *SMTPSender {
+	return NewSMTPSender(&config.Config{Mail: config.MailConfig{
+		// PlainAuth only sends credentials unencrypted to localhost
+		SMTPHost:     "localhost",
+		SMTPPort:     server.port(),
+		SMTPUsername: username,
+		SMTPPassword: "secret",
+		SMTPStartTLS: true,
+		From:         "no-reply@example.com",
+	}})
+}
+
+func TestSMTPSenderDeliversMessage(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	sender := newTestSMTPSender(server, "")
+
+	err := sender.Send(context.Background(), &Message{
+		To:      []string{"alice@example.com", "bob@example.com"},
+		Subject: "Нууц үг сэргээх",
+		Body:    "Hello,\n\nOpen the link.\n",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	session := <-server.sessions
+
+	if session.auth != "" {
+		t.Errorf("authenticated without a username: %q", session.auth)
+	}
+	if session.from != "no-reply@example.com" {
+		t.Errorf("MAIL FROM = %q", session.from)
+	}
+	if strings.Join(session.to, ",") != "alice@example.com,bob@example.com" {
+		t.Errorf("RCPT TO = %v", session.to)
+	}
+
+	header, body, ok := strings.Cut(session.data, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("message has no header/body separator: %q", session.data)
+	}
+	for _, want := range []string{
+		"From: no-reply@example.com",
+		"To: alice@example.com, bob@example.com",
+		"Subject: =?utf-8?q?",
+		"Content-Type: text/plain; charset=utf-8",
+	} {
+		if !strings.Contains(header, want) {
+			t.Errorf("header lacks %q:\n%s", want, header)
+		}
+	}
+	if body != "Hello,\r\n\r\nOpen the link.\r\n" {
+		t.Errorf("body = %q, want CRLF line endings", body)
+	}
+}
+
+func TestSMTPSenderAuthenticates(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	sender := newTestSMTPSender(server, "mailer")
+
+	err := sender.Send(context.Background(), &Message{
+		To:      []string{"alice@example.com"},
+		Subject: "Reset your password",
+		Body:    "Hello\n",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	session := <-server.sessions
+
+	if session.auth != "\x00mailer\x00secret" {
+		t.Errorf("AUTH PLAIN credentials = %q", session.auth)
+	}
+}
@@ -1,28 +1,62 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"gebase/internal/auth"
+	"gebase/internal/domain"
+	"gebase/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthMiddleware struct {
-	jwtService     *auth.JWTService
-	sessionService *auth.SessionService
+	jwtService         *auth.JWTService
+	sessionService     *auth.SessionService
+	revocationService  *auth.RevocationService
+	accessTokenService *service.AccessTokenService
+	deviceService      *service.DeviceService
 }
 
-func NewAuthMiddleware(jwtService *auth.JWTService, sessionService *auth.SessionService) *AuthMiddleware {
+func NewAuthMiddleware(jwtService *auth.JWTService, sessionService *auth.SessionService, revocationService *auth.RevocationService, accessTokenService *service.AccessTokenService, deviceService *service.DeviceService) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtService:     jwtService,
-		sessionService: sessionService,
+		jwtService:         jwtService,
+		sessionService:     sessionService,
+		revocationService:  revocationService,
+		accessTokenService: accessTokenService,
+		deviceService:      deviceService,
 	}
 }
 
-// Auth validates JWT token and sets user context
+// Auth validates a JWT or personal access token and sets user context.
+// OAuth access tokens are rejected; routes OAuth clients may call use OAuth.
 func (m *AuthMiddleware) Auth() gin.HandlerFunc {
+	return m.authenticate(false, nil)
+}
+
+// OAuth is Auth for routes OAuth clients may call on behalf of users. It also
+// accepts OAuth access tokens, provided they were granted every scope given.
+func (m *AuthMiddleware) OAuth(scopes ...string) gin.HandlerFunc {
+	return m.authenticate(true, scopes)
+}
+
+// OptionalAuth is Auth for routes open to anonymous callers: requests
+// without an Authorization header pass through unauthenticated
+func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
+	authenticate := m.Auth()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		authenticate(c)
+	}
+}
+
+func (m *AuthMiddleware) authenticate(allowOAuth bool, scopes []string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -48,6 +82,11 @@ func (m *AuthMiddleware) Auth() gin.HandlerFunc {
 			return
 		}
 
+		if strings.HasPrefix(parts[1], domain.PersonalAccessTokenPrefix) {
+			m.authenticateAccessToken(c, parts[1])
+			return
+		}
+
 		claims, err := m.jwtService.ValidateToken(parts[1])
 		if err != nil {
 			if err == auth.ErrExpiredToken {
@@ -70,9 +109,81 @@ func (m *AuthMiddleware) Auth() gin.HandlerFunc {
 			return
 		}
 
+		// OAuth access tokens are limited to the routes of their scopes
+		if claims.IsOAuthToken() {
+			if !allowOAuth {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"success": false,
+					"error": gin.H{
+						"code":    "INVALID_TOKEN_TYPE",
+						"message": "OAuth access tokens cannot be used for this endpoint",
+					},
+				})
+				return
+			}
+			for _, scope := range scopes {
+				if !claims.HasScope(scope) {
+					c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+						"success": false,
+						"error": gin.H{
+							"code":    "INSUFFICIENT_SCOPE",
+							"message": "The " + scope + " scope is required",
+						},
+					})
+					return
+				}
+			}
+		} else if !claims.IsAccessToken() {
+			// Refresh and MFA challenge tokens are only accepted by their own endpoints
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVALID_TOKEN_TYPE",
+					"message": "Token cannot be used as an access token",
+				},
+			})
+			return
+		}
+
+		// Check server-side revocation
+		revoked, err := m.revocationService.IsRevoked(c.Request.Context(), claims.ID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "TOKEN_CHECK_FAILED",
+					"message": "Failed to verify token",
+				},
+			})
+			return
+		}
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "TOKEN_REVOKED",
+					"message": "Token has been revoked",
+				},
+			})
+			return
+		}
+
 		// Validate session
 		session, err := m.sessionService.GetSessionByID(c.Request.Context(), claims.SessionID)
-		if err != nil || !m.sessionService.IsSessionValid(c.Request.Context(), session) {
+		if err == nil {
+			err = m.sessionService.ValidateSession(c.Request.Context(), session)
+		}
+		if err == auth.ErrSessionIdleTimeout {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "SESSION_IDLE_TIMEOUT",
+					"message": "Session timed out due to inactivity",
+				},
+			})
+			return
+		}
+		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"success": false,
 				"error": gin.H{
@@ -83,8 +194,16 @@ func (m *AuthMiddleware) Auth() gin.HandlerFunc {
 			return
 		}
 
+		// Device-bound tokens are only accepted with a proof signed by their device key
+		if claims.IsDeviceBound() {
+			if err := m.deviceService.VerifyTokenBinding(c.Request.Context(), claims, GetDeviceProof(c)); err != nil {
+				abortDeviceProof(c, err)
+				return
+			}
+		}
+
 		// Update session activity
-		go m.sessionService.UpdateActivity(c.Request.Context(), claims.SessionID)
+		go m.sessionService.UpdateActivity(context.WithoutCancel(c.Request.Context()), session)
 
 		// Set context values
 		c.Set("user_id", claims.UserID)
@@ -102,6 +221,144 @@ func (m *AuthMiddleware) Auth() gin.HandlerFunc {
 		if claims.RoleIDs != nil {
 			c.Set("role_ids", claims.RoleIDs)
 		}
+		if claims.ImpersonatorID != nil {
+			c.Set("impersonator_id", *claims.ImpersonatorID)
+		}
+		// An OAuth client's session carries the user's last login for
+		// auth_time, but the client never confirmed credentials itself
+		if session.AuthenticatedAt != nil && session.OAuthClientID == nil {
+			c.Set("authenticated_at", *session.AuthenticatedAt)
+		}
+
+		c.Next()
+	}
+}
+
+// authenticateAccessToken sets user context from a personal access token.
+// Such requests have no session or device; the token's system and
+// permissions bound what they may do.
+func (m *AuthMiddleware) authenticateAccessToken(c *gin.Context, raw string) {
+	token, user, err := m.accessTokenService.Authenticate(c.Request.Context(), raw)
+	if err == service.ErrInvalidAccessToken {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INVALID_TOKEN",
+				"message": "Access token is invalid, expired or revoked",
+			},
+		})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "TOKEN_CHECK_FAILED",
+				"message": "Failed to verify token",
+			},
+		})
+		return
+	}
+
+	go m.accessTokenService.TouchLastUsed(context.WithoutCancel(c.Request.Context()), token, GetClientIP(c))
+
+	claims := &auth.Claims{
+		UserID:         user.ID,
+		Email:          user.Email,
+		OrganizationID: user.OrganizationID,
+		TokenType:      auth.TokenTypePersonalAccess,
+		SystemID:       &token.SystemID,
+		SystemCode:     token.System.Code,
+	}
+
+	c.Set("user_id", claims.UserID)
+	c.Set("email", claims.Email)
+	c.Set("organization_id", claims.OrganizationID)
+	c.Set("session_id", claims.SessionID)
+	c.Set("device_id", claims.DeviceID)
+	c.Set("token_type", string(claims.TokenType))
+	c.Set("claims", claims)
+	c.Set("system_id", token.SystemID)
+	c.Set("system_code", claims.SystemCode)
+	c.Set("access_token", token)
+
+	c.Next()
+}
+
+// RequireSession rejects personal access tokens on routes that act on the
+// caller's own session or account
+func (m *AuthMiddleware) RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims != nil && claims.IsPersonalAccessToken() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "SESSION_REQUIRED",
+					"message": "This action requires an interactive session and is not available to access tokens",
+				},
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAccessToken admits only personal access tokens, for internal APIs
+// called by other services through their service accounts
+func (m *AuthMiddleware) RequireAccessToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil || !claims.IsPersonalAccessToken() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "ACCESS_TOKEN_REQUIRED",
+					"message": "This endpoint is only available to access tokens",
+				},
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// DenyImpersonation rejects requests made while impersonating, for account
+// changes only the user may make (password, MFA, consents, sessions)
+func (m *AuthMiddleware) DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims != nil && claims.IsImpersonated() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "IMPERSONATION_RESTRICTED",
+					"message": "This action is not available while impersonating a user",
+				},
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireCurrentPassword rejects tokens issued while a password change is pending
+func (m *AuthMiddleware) RequireCurrentPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims != nil && claims.PasswordChangeRequired {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "PASSWORD_CHANGE_REQUIRED",
+					"message": "Password must be changed before continuing",
+				},
+			})
+			return
+		}
 
 		c.Next()
 	}
@@ -191,8 +448,29 @@ func GetSystemID(c *gin.Context) *int {
 	return &id
 }
 
-// GetClaims helper to get claims from context
+// GetAccessToken helper to get the personal access token of the request (may be nil)
+func GetAccessToken(c *gin.Context) *domain.PersonalAccessToken {
+	token, exists := c.Get("access_token")
+	if !exists {
+		return nil
+	}
+	return token.(*domain.PersonalAccessToken)
+}
+
+// GetAuthenticatedAt helper to get when the user last entered their
+// credentials in the request's session (may be nil)
+func GetAuthenticatedAt(c *gin.Context) *time.Time {
+	at, exists := c.Get("authenticated_at")
+	if !exists {
+		return nil
+	}
+	t := at.(time.Time)
+	return &t
+}
+
+// GetClaims helper to get claims from context (nil on unauthenticated routes)
 func GetClaims(c *gin.Context) *auth.Claims {
 	claims, _ := c.Get("claims")
-	return claims.(*auth.Claims)
+	authClaims, _ := claims.(*auth.Claims)
+	return authClaims
 }
@@ -1,13 +1,20 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
+	"strings"
 
+	"gebase/internal/auth"
 	"gebase/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
+// DeviceProofHeader carries the device proof: a JWT signed with the device
+// key covering the request method, path, time and a nonce, as in DPoP
+const DeviceProofHeader = "X-Device-Proof"
+
 type DeviceMiddleware struct {
 	deviceService *service.DeviceService
 }
@@ -22,6 +29,13 @@ func NewDeviceMiddleware(deviceService *service.DeviceService) *DeviceMiddleware
 func (m *DeviceMiddleware) Device() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		deviceUID := c.GetHeader("X-Device-UID")
+
+		// Personal access tokens are not bound to a device
+		if deviceUID == "" && GetAccessToken(c) != nil {
+			c.Next()
+			return
+		}
+
 		if deviceUID == "" {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 				"success": false,
@@ -68,6 +82,26 @@ func (m *DeviceMiddleware) Device() gin.HandlerFunc {
 				})
 				return
 			}
+			if err == service.ErrDevicePendingApproval {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"success": false,
+					"error": gin.H{
+						"code":    "DEVICE_PENDING_APPROVAL",
+						"message": "Device is awaiting administrator approval.",
+					},
+				})
+				return
+			}
+			if err == service.ErrDeviceRejected {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"success": false,
+					"error": gin.H{
+						"code":    "DEVICE_REJECTED",
+						"message": "Device was rejected. Please contact administrator.",
+					},
+				})
+				return
+			}
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 				"success": false,
 				"error": gin.H{
@@ -78,8 +112,18 @@ func (m *DeviceMiddleware) Device() gin.HandlerFunc {
 			return
 		}
 
+		// The request must be signed with the device key and its token issued on the device
+		var claims *auth.Claims
+		if value, exists := c.Get("claims"); exists {
+			claims = value.(*auth.Claims)
+		}
+		if err := m.deviceService.VerifyProof(c.Request.Context(), device, claims, GetDeviceProof(c)); err != nil {
+			abortDeviceProof(c, err)
+			return
+		}
+
 		// Update heartbeat asynchronously
-		go m.deviceService.Heartbeat(c.Request.Context(), deviceUID)
+		go m.deviceService.Heartbeat(context.WithoutCancel(c.Request.Context()), deviceUID)
 
 		// Set device info in context
 		c.Set("device", device)
@@ -98,16 +142,61 @@ func (m *DeviceMiddleware) OptionalDevice() gin.HandlerFunc {
 
 		if deviceUID != "" {
 			device, err := m.deviceService.VerifyDevice(c.Request.Context(), deviceUID)
+			if err == nil {
+				err = m.deviceService.VerifyProof(c.Request.Context(), device, nil, GetDeviceProof(c))
+			}
 			if err == nil {
 				c.Set("device", device)
 				c.Set("device_uid", deviceUID)
 				c.Set("platform", platform)
 
 				// Update heartbeat asynchronously
-				go m.deviceService.Heartbeat(c.Request.Context(), deviceUID)
+				go m.deviceService.Heartbeat(context.WithoutCancel(c.Request.Context()), deviceUID)
 			}
 		}
 
 		c.Next()
 	}
 }
+
+// GetDeviceProof returns the device proof of a request along with the method,
+// path and access token it must cover
+func GetDeviceProof(c *gin.Context) *auth.DeviceProofRequest {
+	proof := &auth.DeviceProofRequest{
+		Proof:  c.GetHeader(DeviceProofHeader),
+		Method: c.Request.Method,
+		Path:   c.Request.URL.Path,
+	}
+	parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+	if len(parts) == 2 && parts[0] == "Bearer" {
+		proof.AccessToken = parts[1]
+	}
+	return proof
+}
+
+// abortDeviceProof rejects a request whose device proof or token binding failed
+func abortDeviceProof(c *gin.Context, err error) {
+	status, code, message := http.StatusUnauthorized, "", ""
+	switch err {
+	case auth.ErrDeviceProofRequired:
+		code, message = "DEVICE_PROOF_REQUIRED", "X-Device-Proof header is required for this device"
+	case auth.ErrInvalidDeviceProof, auth.ErrInvalidDeviceKey:
+		code, message = "DEVICE_PROOF_INVALID", "Device proof is invalid or expired"
+	case auth.ErrDeviceProofReplayed:
+		code, message = "DEVICE_PROOF_REPLAYED", "Device proof has already been used"
+	case auth.ErrDeviceKeyMismatch, auth.ErrDeviceMismatch:
+		code, message = "TOKEN_DEVICE_MISMATCH", "Token was not issued to this device"
+	case auth.ErrDeviceKeyRequired:
+		status, code, message = http.StatusForbidden, "DEVICE_KEY_REQUIRED", "Device must register a public key. Please register the device again."
+	default:
+		status, code, message = http.StatusInternalServerError, "DEVICE_VERIFICATION_FAILED", "Failed to verify device"
+	}
+
+	c.AbortWithStatusJSON(status, gin.H{
+		"success": false,
+		"error": gin.H{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
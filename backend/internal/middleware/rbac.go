@@ -2,7 +2,10 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
+	"gebase/internal/config"
 	"gebase/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -10,17 +13,119 @@ import (
 
 type RBACMiddleware struct {
 	permissionService *service.PermissionService
+	config            *config.Config
 }
 
-func NewRBACMiddleware(permissionService *service.PermissionService) *RBACMiddleware {
+func NewRBACMiddleware(permissionService *service.PermissionService, cfg *config.Config) *RBACMiddleware {
 	return &RBACMiddleware{
 		permissionService: permissionService,
+		config:            cfg,
 	}
 }
 
+// restrictedForImpersonation reports whether a permission is withheld from
+// administrators impersonating a user: the restricted actions and
+// impersonation itself
+func (m *RBACMiddleware) restrictedForImpersonation(c *gin.Context, permissionCode string) bool {
+	if _, impersonating := c.Get("impersonator_id"); !impersonating {
+		return false
+	}
+	if permissionCode == service.ImpersonatePermission {
+		return true
+	}
+	action := permissionCode[strings.LastIndex(permissionCode, ".")+1:]
+	for _, restricted := range m.config.Impersonation.RestrictedActions {
+		if action == restricted {
+			return true
+		}
+	}
+	return false
+}
+
+// exceedsAccessToken reports whether a permission is outside the scope of the
+// personal access token authenticating the request
+func exceedsAccessToken(c *gin.Context, permissionCode string) bool {
+	token := GetAccessToken(c)
+	return token != nil && !token.AllowsPermission(permissionCode)
+}
+
+func abortAccessTokenScope(c *gin.Context, permissionCode string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"success": false,
+		"error": gin.H{
+			"code":       "TOKEN_SCOPE_INSUFFICIENT",
+			"message":    "The access token does not grant this permission",
+			"permission": permissionCode,
+		},
+	})
+}
+
+func abortImpersonationRestricted(c *gin.Context, permissionCode string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"success": false,
+		"error": gin.H{
+			"code":       "IMPERSONATION_RESTRICTED",
+			"message":    "This action is not available while impersonating a user",
+			"permission": permissionCode,
+		},
+	})
+}
+
+// needsStepUp reports whether a permission asks for more recent
+// authentication than the request's session has, returning the window it
+// asks for. Access tokens are exempt: a permission that asks for recent
+// authentication is only granted to one by a session recent enough to use it.
+func (m *RBACMiddleware) needsStepUp(c *gin.Context, permissionCode string) (time.Duration, bool, error) {
+	if GetAccessToken(c) != nil {
+		return 0, false, nil
+	}
+	window, err := m.permissionService.RecentAuthWindow(c.Request.Context(), GetUserID(c), GetSystemID(c), permissionCode)
+	if err != nil || window == 0 {
+		return 0, false, err
+	}
+	authenticatedAt := GetAuthenticatedAt(c)
+	if authenticatedAt != nil && time.Since(*authenticatedAt) <= window {
+		return window, false, nil
+	}
+	return window, true, nil
+}
+
+// AbortStepUpRequired asks the client to reauthenticate before using a
+// permission that wants credentials entered within window
+func AbortStepUpRequired(c *gin.Context, permissionCode string, window time.Duration) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"success": false,
+		"error": gin.H{
+			"code":                "STEP_UP_REQUIRED",
+			"message":             "This action requires you to confirm your credentials. Reauthenticate and try again.",
+			"permission":          permissionCode,
+			"recent_auth_minutes": int(window / time.Minute),
+		},
+	})
+}
+
+func abortPermissionCheckFailed(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		"success": false,
+		"error": gin.H{
+			"code":    "PERMISSION_CHECK_FAILED",
+			"message": "Failed to check permission",
+		},
+	})
+}
+
 // RequirePermission checks if user has the specified permission
 func (m *RBACMiddleware) RequirePermission(permissionCode string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if m.restrictedForImpersonation(c, permissionCode) {
+			abortImpersonationRestricted(c, permissionCode)
+			return
+		}
+		if exceedsAccessToken(c, permissionCode) {
+			abortAccessTokenScope(c, permissionCode)
+			return
+		}
+
 		userID := GetUserID(c)
 		systemID := GetSystemID(c)
 
@@ -32,13 +137,7 @@ func (m *RBACMiddleware) RequirePermission(permissionCode string) gin.HandlerFun
 		)
 
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error": gin.H{
-					"code":    "PERMISSION_CHECK_FAILED",
-					"message": "Failed to check permission",
-				},
-			})
+			abortPermissionCheckFailed(c)
 			return
 		}
 
@@ -54,6 +153,16 @@ func (m *RBACMiddleware) RequirePermission(permissionCode string) gin.HandlerFun
 			return
 		}
 
+		window, stepUp, err := m.needsStepUp(c, permissionCode)
+		if err != nil {
+			abortPermissionCheckFailed(c)
+			return
+		}
+		if stepUp {
+			AbortStepUpRequired(c, permissionCode, window)
+			return
+		}
+
 		c.Next()
 	}
 }
@@ -64,18 +173,46 @@ func (m *RBACMiddleware) RequireAnyPermission(permissionCodes ...string) gin.Han
 		userID := GetUserID(c)
 		systemID := GetSystemID(c)
 
+		// A held permission that needs step-up only counts once the user
+		// reauthenticates; challenge for it if nothing else grants access
+		stepUpCode := ""
+		var stepUpWindow time.Duration
+
 		for _, code := range permissionCodes {
+			if m.restrictedForImpersonation(c, code) || exceedsAccessToken(c, code) {
+				continue
+			}
 			hasPermission, err := m.permissionService.CheckPermission(
 				c.Request.Context(),
 				userID,
 				systemID,
 				code,
 			)
+			if err != nil {
+				abortPermissionCheckFailed(c)
+				return
+			}
+			if !hasPermission {
+				continue
+			}
 
-			if err == nil && hasPermission {
+			window, stepUp, err := m.needsStepUp(c, code)
+			if err != nil {
+				abortPermissionCheckFailed(c)
+				return
+			}
+			if !stepUp {
 				c.Next()
 				return
 			}
+			if stepUpCode == "" {
+				stepUpCode, stepUpWindow = code, window
+			}
+		}
+
+		if stepUpCode != "" {
+			AbortStepUpRequired(c, stepUpCode, stepUpWindow)
+			return
 		}
 
 		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
@@ -96,6 +233,14 @@ func (m *RBACMiddleware) RequireAllPermissions(permissionCodes ...string) gin.Ha
 		systemID := GetSystemID(c)
 
 		for _, code := range permissionCodes {
+			if m.restrictedForImpersonation(c, code) {
+				abortImpersonationRestricted(c, code)
+				return
+			}
+			if exceedsAccessToken(c, code) {
+				abortAccessTokenScope(c, code)
+				return
+			}
 			hasPermission, err := m.permissionService.CheckPermission(
 				c.Request.Context(),
 				userID,
@@ -114,6 +259,16 @@ func (m *RBACMiddleware) RequireAllPermissions(permissionCodes ...string) gin.Ha
 				})
 				return
 			}
+
+			window, stepUp, err := m.needsStepUp(c, code)
+			if err != nil {
+				abortPermissionCheckFailed(c)
+				return
+			}
+			if stepUp {
+				AbortStepUpRequired(c, code, window)
+				return
+			}
 		}
 
 		c.Next()
@@ -0,0 +1,180 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"gebase/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	apnsProduction = "https://api.push.apple.com"
+	apnsSandbox    = "https://api.sandbox.push.apple.com"
+	// apnsTokenTTL is how long a provider token is reused; APNs rejects
+	// tokens older than an hour and refreshing more often than every 20
+	// minutes
+	apnsTokenTTL = 40 * time.Minute
+)
+
+// APNsProvider delivers messages through the Apple Push Notification service
+// over HTTP/2, authenticating with tokens signed by the account's .p8 key
+type APNsProvider struct {
+	host       string
+	keyID      string
+	teamID     string
+	topic      string
+	key        *ecdsa.PrivateKey
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+func NewAPNsProvider(cfg *config.Config) (*APNsProvider, error) {
+	if cfg.Push.APNsKeyID == "" || cfg.Push.APNsTeamID == "" || cfg.Push.APNsTopic == "" {
+		return nil, errors.New("PUSH_APNS_KEY_ID, PUSH_APNS_TEAM_ID and PUSH_APNS_TOPIC are required")
+	}
+	raw, err := os.ReadFile(cfg.Push.APNsKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APNs key: %w", err)
+	}
+
+	host := apnsProduction
+	if cfg.Push.APNsSandbox {
+		host = apnsSandbox
+	}
+
+	return &APNsProvider{
+		host:   host,
+		keyID:  cfg.Push.APNsKeyID,
+		teamID: cfg.Push.APNsTeamID,
+		topic:  cfg.Push.APNsTopic,
+		key:    key,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				ForceAttemptHTTP2: true,
+				TLSClientConfig:   &tls.Config{MinVersion: tls.VersionTLS12},
+				IdleConnTimeout:   5 * time.Minute,
+			},
+		},
+	}, nil
+}
+
+type apnsAlert struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type apnsAps struct {
+	Alert            *apnsAlert `json:"alert,omitempty"`
+	Sound            string     `json:"sound,omitempty"`
+	ContentAvailable int        `json:"content-available,omitempty"`
+}
+
+func (p *APNsProvider) Send(ctx context.Context, msg *Message) error {
+	token, err := p.providerToken()
+	if err != nil {
+		return err
+	}
+
+	// Custom data sits next to the aps dictionary
+	payload := make(map[string]interface{}, len(msg.Data)+1)
+	for key, value := range msg.Data {
+		payload[key] = value
+	}
+	pushType, priority := "alert", "10"
+	if msg.IsSilent() {
+		payload["aps"] = apnsAps{ContentAvailable: 1}
+		pushType, priority = "background", "5"
+	} else {
+		payload["aps"] = apnsAps{Alert: &apnsAlert{Title: msg.Title, Body: msg.Body}, Sound: "default"}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	endpoint := p.host + "/3/device/" + url.PathEscape(msg.Token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("apns-topic", p.topic)
+	req.Header.Set("apns-push-type", pushType)
+	req.Header.Set("apns-priority", priority)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return &TemporaryError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var errResp struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&errResp)
+
+	switch errResp.Reason {
+	case "BadDeviceToken", "DeviceTokenNotForTopic", "Unregistered":
+		return ErrInvalidToken
+	case "ExpiredProviderToken":
+		p.mu.Lock()
+		p.token = ""
+		p.mu.Unlock()
+		return &TemporaryError{Err: errors.New("apns provider token expired")}
+	}
+	if resp.StatusCode == http.StatusGone {
+		return ErrInvalidToken
+	}
+	return statusError("apns", resp, errResp.Reason)
+}
+
+// providerToken returns the cached provider token, signing a new one once it
+// is apnsTokenTTL old
+func (p *APNsProvider) providerToken() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token != "" && time.Since(p.issuedAt) < apnsTokenTTL {
+		return p.token, nil
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": p.teamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = p.keyID
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		return "", err
+	}
+
+	p.token = signed
+	p.issuedAt = now
+	return p.token, nil
+}
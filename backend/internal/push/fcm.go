@@ -0,0 +1,230 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gebase/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	fcmScope    = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmEndpoint = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+)
+
+// fcmServiceAccount is the subset of a Google service account key used to
+// obtain access tokens
+type fcmServiceAccount struct {
+	ProjectID   string `json:"project_id"`
+	PrivateKey  string `json:"private_key"`
+	ClientEmail string `json:"client_email"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// FCMProvider delivers messages through the Firebase Cloud Messaging HTTP v1
+// API, authenticating with OAuth access tokens obtained for a service account
+type FCMProvider struct {
+	endpoint    string
+	clientEmail string
+	tokenURI    string
+	key         *rsa.PrivateKey
+	httpClient  *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+func NewFCMProvider(cfg *config.Config) (*FCMProvider, error) {
+	raw, err := os.ReadFile(cfg.Push.FCMCredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	var account fcmServiceAccount
+	if err := json.Unmarshal(raw, &account); err != nil {
+		return nil, fmt.Errorf("invalid service account key: %w", err)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid service account private key: %w", err)
+	}
+
+	projectID := cfg.Push.FCMProjectID
+	if projectID == "" {
+		projectID = account.ProjectID
+	}
+	if projectID == "" || account.ClientEmail == "" || account.TokenURI == "" {
+		return nil, errors.New("service account key lacks project_id, client_email or token_uri")
+	}
+
+	return &FCMProvider{
+		endpoint:    fmt.Sprintf(fcmEndpoint, url.PathEscape(projectID)),
+		clientEmail: account.ClientEmail,
+		tokenURI:    account.TokenURI,
+		key:         key,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+type fcmMessage struct {
+	Message struct {
+		Token        string            `json:"token"`
+		Notification *fcmNotification  `json:"notification,omitempty"`
+		Data         map[string]string `json:"data,omitempty"`
+		Android      *fcmAndroid       `json:"android,omitempty"`
+	} `json:"message"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type fcmAndroid struct {
+	Priority string `json:"priority"`
+}
+
+type fcmErrorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			Type      string `json:"@type"`
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func (p *FCMProvider) Send(ctx context.Context, msg *Message) error {
+	accessToken, err := p.token(ctx)
+	if err != nil {
+		return &TemporaryError{Err: err}
+	}
+
+	var payload fcmMessage
+	payload.Message.Token = msg.Token
+	payload.Message.Data = msg.Data
+	if msg.IsSilent() {
+		payload.Message.Android = &fcmAndroid{Priority: "normal"}
+	} else {
+		payload.Message.Notification = &fcmNotification{Title: msg.Title, Body: msg.Body}
+		payload.Message.Android = &fcmAndroid{Priority: "high"}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return &TemporaryError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var errResp fcmErrorResponse
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	_ = json.Unmarshal(respBody, &errResp)
+
+	for _, detail := range errResp.Error.Details {
+		if detail.ErrorCode == "UNREGISTERED" || detail.ErrorCode == "SENDER_ID_MISMATCH" {
+			return ErrInvalidToken
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrInvalidToken
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		// The access token was revoked early; fetch a new one on retry
+		p.mu.Lock()
+		p.accessToken = ""
+		p.mu.Unlock()
+		return &TemporaryError{Err: fmt.Errorf("fcm rejected the access token: %s", errResp.Error.Message)}
+	}
+
+	reason := errResp.Error.Message
+	if reason == "" {
+		reason = strings.TrimSpace(string(respBody))
+	}
+	return statusError("fcm", resp, reason)
+}
+
+// token returns a cached access token, requesting a new one with a signed
+// JWT assertion shortly before it expires
+func (p *FCMProvider) token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.accessToken != "" && time.Until(p.expiresAt) > time.Minute {
+		return p.accessToken, nil
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   p.clientEmail,
+		"scope": fcmScope,
+		"aud":   p.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(p.key)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return "", fmt.Errorf("fcm token request failed with %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	p.accessToken = token.AccessToken
+	p.expiresAt = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	return p.accessToken, nil
+}
@@ -0,0 +1,194 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+)
+
+// Push providers
+const (
+	ProviderFCM  = "fcm"
+	ProviderAPNs = "apns"
+)
+
+var (
+	// ErrInvalidToken is returned when the provider no longer accepts a
+	// device's push token, e.g. because the app was uninstalled
+	ErrInvalidToken = errors.New("push token is no longer valid")
+	// ErrNotConfigured is returned for devices whose provider has no credentials
+	ErrNotConfigured = errors.New("push provider is not configured")
+)
+
+// TemporaryError is a delivery failure worth retrying, such as throttling
+// or a provider outage. RetryAfter is the wait the provider asked for, if any.
+type TemporaryError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *TemporaryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TemporaryError) Unwrap() error {
+	return e.Err
+}
+
+// Message is a notification to one device. Messages without a title and body
+// are delivered silently, for the app to act on their data.
+type Message struct {
+	Token string
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// IsSilent reports whether the message carries only data
+func (m *Message) IsSilent() bool {
+	return m.Title == "" && m.Body == ""
+}
+
+// Provider delivers messages through one push service
+type Provider interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// ProviderFor returns the provider reaching devices of a platform: APNs for
+// Apple platforms, FCM for all others
+func ProviderFor(platform domain.DevicePlatform) string {
+	switch platform {
+	case domain.PlatformIOS, domain.PlatformTabletIOS, domain.PlatformMacDesktop:
+		return ProviderAPNs
+	default:
+		return ProviderFCM
+	}
+}
+
+// Sender routes messages to the provider of each device's platform
+type Sender struct {
+	providers map[string]Provider
+}
+
+// NewSender loads the configured providers. When neither FCM nor APNs is
+// configured every message goes to a FakeProvider that only logs it
+// (development). A provider whose credentials fail to load is left out, so
+// its devices fail with ErrNotConfigured.
+func NewSender(cfg *config.Config) *Sender {
+	if cfg.Push.FCMCredentialsFile == "" && cfg.Push.APNsKeyFile == "" {
+		fake := NewFakeProvider()
+		return NewSenderWithProviders(map[string]Provider{ProviderFCM: fake, ProviderAPNs: fake})
+	}
+
+	providers := make(map[string]Provider)
+	if cfg.Push.FCMCredentialsFile != "" {
+		fcm, err := NewFCMProvider(cfg)
+		if err != nil {
+			log.Printf("Warning: failed to load FCM credentials: %v", err)
+		} else {
+			providers[ProviderFCM] = fcm
+		}
+	}
+	if cfg.Push.APNsKeyFile != "" {
+		apns, err := NewAPNsProvider(cfg)
+		if err != nil {
+			log.Printf("Warning: failed to load APNs key: %v", err)
+		} else {
+			providers[ProviderAPNs] = apns
+		}
+	}
+	return NewSenderWithProviders(providers)
+}
+
+// NewSenderWithProviders returns a sender using the given providers, keyed
+// by ProviderFCM and ProviderAPNs
+func NewSenderWithProviders(providers map[string]Provider) *Sender {
+	return &Sender{providers: providers}
+}
+
+// Send delivers a message through the named provider
+func (s *Sender) Send(ctx context.Context, provider string, msg *Message) error {
+	p, ok := s.providers[provider]
+	if !ok {
+		return ErrNotConfigured
+	}
+	return p.Send(ctx, msg)
+}
+
+// FakeProvider records messages instead of delivering them, for development
+// and tests. Tokens marked invalid fail with ErrInvalidToken; failures queued
+// with Fail are returned before that.
+type FakeProvider struct {
+	mu       sync.Mutex
+	sent     []Message
+	invalid  map[string]bool
+	failures map[string][]error
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{invalid: make(map[string]bool), failures: make(map[string][]error)}
+}
+
+func (p *FakeProvider) Send(ctx context.Context, msg *Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if failures := p.failures[msg.Token]; len(failures) > 0 {
+		p.failures[msg.Token] = failures[1:]
+		return failures[0]
+	}
+	if p.invalid[msg.Token] {
+		return ErrInvalidToken
+	}
+	p.sent = append(p.sent, *msg)
+	log.Printf("Push to %s: %s\n%s %v", msg.Token, msg.Title, msg.Body, msg.Data)
+	return nil
+}
+
+// Invalidate makes later messages to token fail with ErrInvalidToken
+func (p *FakeProvider) Invalidate(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.invalid[token] = true
+}
+
+// Fail makes the next messages to token fail with errs, one error each
+func (p *FakeProvider) Fail(token string, errs ...error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures[token] = append(p.failures[token], errs...)
+}
+
+// Sent returns the messages recorded so far
+func (p *FakeProvider) Sent() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message(nil), p.sent...)
+}
+
+// statusError turns a failed provider response into an error, temporary
+// for throttling and server errors
+func statusError(provider string, resp *http.Response, reason string) error {
+	err := fmt.Errorf("%s responded %d: %s", provider, resp.StatusCode, reason)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return &TemporaryError{Err: err, RetryAfter: retryAfter(resp)}
+	}
+	return err
+}
+
+// retryAfter reads the Retry-After header given in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
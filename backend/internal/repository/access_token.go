@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type PersonalAccessTokenRepository struct {
+	*BaseRepository[domain.PersonalAccessToken]
+}
+
+func NewPersonalAccessTokenRepository(db *gorm.DB) *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{
+		BaseRepository: NewBaseRepository[domain.PersonalAccessToken](db),
+	}
+}
+
+func (r *PersonalAccessTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*domain.PersonalAccessToken, error) {
+	var token domain.PersonalAccessToken
+	err := r.DB.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// FindByUserID returns a user's tokens, newest first, including revoked and
+// expired ones
+func (r *PersonalAccessTokenRepository) FindByUserID(ctx context.Context, userID int64) ([]domain.PersonalAccessToken, error) {
+	var tokens []domain.PersonalAccessToken
+	err := r.DB.WithContext(ctx).
+		Preload("System").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *PersonalAccessTokenRepository) FindByUserAndID(ctx context.Context, userID, id int64) (*domain.PersonalAccessToken, error) {
+	var token domain.PersonalAccessToken
+	err := r.DB.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Revoke revokes a token. It reports false if the token was already revoked.
+func (r *PersonalAccessTokenRepository) Revoke(ctx context.Context, id int64, revokedBy int64) (bool, error) {
+	now := time.Now()
+	result := r.DB.WithContext(ctx).Model(&domain.PersonalAccessToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"revoked_at": &now,
+			"revoked_by": revokedBy,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RevokeByUserID revokes every active token of a user
+func (r *PersonalAccessTokenRepository) RevokeByUserID(ctx context.Context, userID int64, revokedBy int64) error {
+	now := time.Now()
+	return r.DB.WithContext(ctx).Model(&domain.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"revoked_at": &now,
+			"revoked_by": revokedBy,
+		}).Error
+}
+
+// TouchLastUsed records a use of the token. Writes are skipped while the
+// recorded use is more recent than interval so busy tokens do not write on
+// every request.
+func (r *PersonalAccessTokenRepository) TouchLastUsed(ctx context.Context, id int64, ipAddress string, interval time.Duration) error {
+	now := time.Now()
+	return r.DB.WithContext(ctx).Model(&domain.PersonalAccessToken{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ? OR last_used_ip <> ?)", id, now.Add(-interval), ipAddress).
+		Updates(map[string]interface{}{
+			"last_used_at": &now,
+			"last_used_ip": ipAddress,
+		}).Error
+}
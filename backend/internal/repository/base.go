@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 
 	"gorm.io/gorm"
 )
@@ -14,6 +15,11 @@ func NewBaseRepository[T any](db *gorm.DB) *BaseRepository[T] {
 	return &BaseRepository[T]{DB: db}
 }
 
+// IsNotFound reports whether err means the requested record does not exist
+func IsNotFound(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound)
+}
+
 type PaginationParams struct {
 	Page     int
 	PageSize int
@@ -66,18 +66,81 @@ func (r *DeviceRepository) UpdateHeartbeat(ctx context.Context, uid string) erro
 	now := time.Now()
 	return r.DB.WithContext(ctx).Model(&domain.Device{}).
 		Where("device_uid = ?", uid).
-		Update("last_heartbeat", &now).Error
-}
-
-func (r *DeviceRepository) UpdateConfig(ctx context.Context, id int64, configJSON string, updatedBy int64) error {
-	return r.DB.WithContext(ctx).Model(&domain.Device{}).
-		Where("id = ?", id).
 		Updates(map[string]interface{}{
-			"config_json": configJSON,
-			"updated_by":  updatedBy,
+			"last_heartbeat": &now,
+			"stale_since":    nil,
 		}).Error
 }
 
+// MarkStale flags active devices without a heartbeat since cutoff; devices
+// that never sent one count from registration
+func (r *DeviceRepository) MarkStale(ctx context.Context, cutoff time.Time) (int64, error) {
+	now := time.Now()
+	result := r.DB.WithContext(ctx).Model(&domain.Device{}).
+		Where("is_active = true AND stale_since IS NULL").
+		Where("COALESCE(last_heartbeat, registered_at, created_date) < ?", cutoff).
+		Update("stale_since", &now)
+	return result.RowsAffected, result.Error
+}
+
+// FindPending returns devices awaiting approval, oldest first, optionally of
+// one organization
+func (r *DeviceRepository) FindPending(ctx context.Context, orgID *int64, params PaginationParams) (*PaginatedResult[domain.Device], error) {
+	var devices []domain.Device
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.Device{}).
+		Where("approval_status = ? AND is_active = true", domain.DeviceApprovalPending)
+	if orgID != nil {
+		query = query.Where("organization_id = ?", *orgID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.
+		Preload("Organization").
+		Order("created_date").
+		Offset(params.GetOffset()).
+		Limit(params.GetLimit()).
+		Find(&devices).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.Device]{
+		Data:       devices,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// Review records an administrator's approval or rejection of a device.
+// Approval also marks the device registered.
+func (r *DeviceRepository) Review(ctx context.Context, id int64, status, reason string, reviewedBy int64) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"approval_status":  status,
+		"rejection_reason": reason,
+		"reviewed_by":      reviewedBy,
+		"reviewed_at":      &now,
+		"updated_by":       reviewedBy,
+	}
+	if status == domain.DeviceApprovalApproved {
+		updates["is_registered"] = true
+		updates["registered_at"] = &now
+	}
+	return r.DB.WithContext(ctx).Model(&domain.Device{}).Where("id = ?", id).Updates(updates).Error
+}
+
 func (r *DeviceRepository) Register(ctx context.Context, uid string, updatedBy int64) error {
 	now := time.Now()
 	return r.DB.WithContext(ctx).Model(&domain.Device{}).
@@ -89,11 +152,188 @@ func (r *DeviceRepository) Register(ctx context.Context, uid string, updatedBy i
 		}).Error
 }
 
-func (r *DeviceRepository) FindOnlineDevices(ctx context.Context, threshold time.Duration) ([]domain.Device, error) {
+// FindOnline returns the active devices currently online, optionally of one
+// organization or platform
+func (r *DeviceRepository) FindOnline(ctx context.Context, orgID *int64, platform string, params PaginationParams) (*PaginatedResult[domain.Device], error) {
+	var devices []domain.Device
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.Device{}).Where("is_online = true AND is_active = true")
+	if orgID != nil {
+		query = query.Where("organization_id = ?", *orgID)
+	}
+	if platform != "" {
+		query = query.Where("platform = ?", platform)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if err := query.Order("id").Offset(params.GetOffset()).Limit(params.GetLimit()).Find(&devices).Error; err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.Device]{
+		Data:       devices,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// FindSilent returns the active, offline devices of platforms
+// without an open alert that were last seen before cutoff
+func (r *DeviceRepository) FindSilent(ctx context.Context, platforms []domain.DevicePlatform, cutoff time.Time) ([]domain.Device, error) {
 	var devices []domain.Device
-	cutoff := time.Now().Add(-threshold)
 	err := r.DB.WithContext(ctx).
-		Where("last_heartbeat > ? AND is_active = true", cutoff).
+		Where("platform IN ? AND is_active = true AND is_online IS NOT TRUE", platforms).
+		Where("last_heartbeat IS NOT NULL AND last_heartbeat < ?", cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM device_silence_alerts a WHERE a.device_id = devices.id AND a.resolved_at IS NULL)").
 		Find(&devices).Error
 	return devices, err
 }
+
+// FindForReport returns the active devices, optionally of one organization
+// or platform
+func (r *DeviceRepository) FindForReport(ctx context.Context, orgID *int64, platform string) ([]domain.Device, error) {
+	var devices []domain.Device
+	query := r.DB.WithContext(ctx).Where("is_active = true")
+	if orgID != nil {
+		query = query.Where("organization_id = ?", *orgID)
+	}
+	if platform != "" {
+		query = query.Where("platform = ?", platform)
+	}
+	err := query.Order("id").Find(&devices).Error
+	return devices, err
+}
+
+// FindPushTargets returns the active, approved devices with a push token of
+// a user (devices they have an active session on), a device, an organization
+// (devices an administrator approved for it or a member signed in on) or a
+// platform, optionally narrowed to a platform
+func (r *DeviceRepository) FindPushTargets(ctx context.Context, targetType string, targetID int64, platform string) ([]domain.Device, error) {
+	var devices []domain.Device
+	query := r.DB.WithContext(ctx).
+		Where("is_active = true AND approval_status = ? AND push_token <> ''", domain.DeviceApprovalApproved)
+
+	switch targetType {
+	case domain.PushTargetUser:
+		query = query.Where("id IN (?)", r.DB.Model(&domain.Session{}).
+			Select("device_id").
+			Where("user_id = ? AND is_active = true AND expires_at > ?", targetID, time.Now()))
+	case domain.PushTargetDevice:
+		query = query.Where("id = ?", targetID)
+	case domain.PushTargetOrganization:
+		// The organization of a device may have been declared by an older client
+		query = query.Where("organization_id = ?", targetID).
+			Where("reviewed_by IS NOT NULL OR id IN (?)", r.DB.Model(&domain.Session{}).
+				Select("device_id").
+				Where("organization_id = ?", targetID))
+	}
+	if platform != "" {
+		query = query.Where("platform = ?", platform)
+	}
+
+	err := query.Order("id").Find(&devices).Error
+	return devices, err
+}
+
+// ClearPushToken removes a push token the provider rejected, unless the
+// device registered a new one meanwhile
+func (r *DeviceRepository) ClearPushToken(ctx context.Context, id int64, token string) error {
+	return r.DB.WithContext(ctx).Model(&domain.Device{}).
+		Where("id = ? AND push_token = ?", id, token).
+		Update("push_token", "").Error
+}
+
+// DeviceCount is the number of devices in a group and how many are online
+type DeviceCount struct {
+	Key    string `json:"key"`
+	Name   string `json:"name,omitempty"`
+	Total  int64  `json:"total"`
+	Online int64  `json:"online"`
+}
+
+// CountByGroup counts the active devices, optionally of one organization,
+// grouped by platform, organization or app version
+func (r *DeviceRepository) CountByGroup(ctx context.Context, groupBy string, orgID *int64) ([]DeviceCount, error) {
+	var counts []DeviceCount
+
+	query := r.DB.WithContext(ctx).Table("devices").
+		Where("devices.is_active = true AND devices.deleted_date IS NULL")
+	if orgID != nil {
+		query = query.Where("devices.organization_id = ?", *orgID)
+	}
+
+	switch groupBy {
+	case "organization":
+		query = query.
+			Select("COALESCE(CAST(devices.organization_id AS TEXT), '') AS key, COALESCE(MAX(organizations.name), '') AS name, " +
+				"COUNT(*) AS total, COUNT(*) FILTER (WHERE devices.is_online) AS online").
+			Joins("LEFT JOIN organizations ON organizations.id = devices.organization_id").
+			Group("devices.organization_id")
+	case "app_version":
+		query = query.
+			Select("COALESCE(devices.app_version, '') AS key, COUNT(*) AS total, COUNT(*) FILTER (WHERE devices.is_online) AS online").
+			Group("devices.app_version")
+	default:
+		query = query.
+			Select("devices.platform AS key, COUNT(*) AS total, COUNT(*) FILTER (WHERE devices.is_online) AS online").
+			Group("devices.platform")
+	}
+
+	err := query.Order("total DESC").Scan(&counts).Error
+	return counts, err
+}
+
+// ClearKey removes the key of a device so it can register a new one
+func (r *DeviceRepository) ClearKey(ctx context.Context, id int64, updatedBy int64) error {
+	return r.DB.WithContext(ctx).Model(&domain.Device{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"public_key_jwk":         "",
+			"key_algorithm":          "",
+			"key_thumbprint":         "",
+			"key_bound_at":           nil,
+			"pending_key_jwk":        "",
+			"pending_key_algorithm":  "",
+			"pending_key_thumbprint": "",
+			"pending_key_at":         nil,
+			"updated_by":             updatedBy,
+		}).Error
+}
+
+// ClaimOrganization assigns a device that has no organization to orgID,
+// setting its approval status with it. It reports false when the device
+// already had an organization.
+func (r *DeviceRepository) ClaimOrganization(ctx context.Context, id, orgID int64, approvalStatus string) (bool, error) {
+	result := r.DB.WithContext(ctx).Model(&domain.Device{}).
+		Where("id = ? AND organization_id IS NULL", id).
+		Updates(map[string]interface{}{
+			"organization_id": orgID,
+			"approval_status": approvalStatus,
+			"is_registered":   approvalStatus == domain.DeviceApprovalApproved,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// SetPendingKey records a key awaiting an administrator's approval,
+// replacing any earlier one
+func (r *DeviceRepository) SetPendingKey(ctx context.Context, id int64, jwk, algorithm, thumbprint string) error {
+	return r.DB.WithContext(ctx).Model(&domain.Device{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"pending_key_jwk":        jwk,
+			"pending_key_algorithm":  algorithm,
+			"pending_key_thumbprint": thumbprint,
+			"pending_key_at":         time.Now(),
+		}).Error
+}
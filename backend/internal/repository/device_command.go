@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type DeviceCommandRepository struct {
+	*BaseRepository[domain.DeviceCommand]
+}
+
+func NewDeviceCommandRepository(db *gorm.DB) *DeviceCommandRepository {
+	return &DeviceCommandRepository{
+		BaseRepository: NewBaseRepository[domain.DeviceCommand](db),
+	}
+}
+
+// openStatuses are the statuses of commands still waiting for the device
+var openStatuses = []string{domain.DeviceCommandPending, domain.DeviceCommandDelivered}
+
+// FindOpenByDevice returns the unexpired commands of a device that it has
+// not acknowledged yet, oldest first
+func (r *DeviceCommandRepository) FindOpenByDevice(ctx context.Context, deviceID int64, limit int) ([]domain.DeviceCommand, error) {
+	var commands []domain.DeviceCommand
+	err := r.DB.WithContext(ctx).
+		Where("device_id = ? AND status IN ? AND expires_at > ?", deviceID, openStatuses, time.Now()).
+		Order("id").
+		Limit(limit).
+		Find(&commands).Error
+	return commands, err
+}
+
+// MarkDelivered records that commands were returned in a heartbeat
+func (r *DeviceCommandRepository) MarkDelivered(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	now := time.Now()
+	return r.DB.WithContext(ctx).Model(&domain.DeviceCommand{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{
+			"status":         domain.DeviceCommandDelivered,
+			"delivered_at":   gorm.Expr("COALESCE(delivered_at, ?)", now),
+			"delivery_count": gorm.Expr("delivery_count + 1"),
+		}).Error
+}
+
+// Acknowledge stores the result a device reported for one of its commands.
+// It reports false when the command is no longer open.
+func (r *DeviceCommandRepository) Acknowledge(ctx context.Context, id, deviceID int64, status, resultJSON, message string) (bool, error) {
+	now := time.Now()
+	result := r.DB.WithContext(ctx).Model(&domain.DeviceCommand{}).
+		Where("id = ? AND device_id = ? AND status IN ? AND expires_at > ?", id, deviceID, openStatuses, now).
+		Updates(map[string]interface{}{
+			"status":          status,
+			"acknowledged_at": &now,
+			"result_json":     resultJSON,
+			"result_message":  message,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Cancel withdraws an open command. It reports false when the command is no
+// longer open.
+func (r *DeviceCommandRepository) Cancel(ctx context.Context, id, deviceID, cancelledBy int64) (bool, error) {
+	now := time.Now()
+	result := r.DB.WithContext(ctx).Model(&domain.DeviceCommand{}).
+		Where("id = ? AND device_id = ? AND status IN ?", id, deviceID, openStatuses).
+		Updates(map[string]interface{}{
+			"status":       domain.DeviceCommandCancelled,
+			"cancelled_by": cancelledBy,
+			"cancelled_at": &now,
+			"updated_by":   cancelledBy,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ExpireOverdue marks open commands past their expiry as expired
+func (r *DeviceCommandRepository) ExpireOverdue(ctx context.Context) (int64, error) {
+	result := r.DB.WithContext(ctx).Model(&domain.DeviceCommand{}).
+		Where("status IN ? AND expires_at <= ?", openStatuses, time.Now()).
+		Update("status", domain.DeviceCommandExpired)
+	return result.RowsAffected, result.Error
+}
+
+// FindByDevice returns the command history of a device, newest first,
+// optionally with one status
+func (r *DeviceCommandRepository) FindByDevice(ctx context.Context, deviceID int64, status string, params PaginationParams) (*PaginatedResult[domain.DeviceCommand], error) {
+	var commands []domain.DeviceCommand
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.DeviceCommand{}).Where("device_id = ?", deviceID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.
+		Preload("Issuer").
+		Order("id DESC").
+		Offset(params.GetOffset()).
+		Limit(params.GetLimit()).
+		Find(&commands).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.DeviceCommand]{
+		Data:       commands,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// DeleteFinishedBefore removes commands that were closed before cutoff
+func (r *DeviceCommandRepository) DeleteFinishedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result := r.DB.WithContext(ctx).Unscoped().
+		Where("status NOT IN ? AND updated_date < ?", openStatuses, cutoff).
+		Delete(&domain.DeviceCommand{})
+	return result.RowsAffected, result.Error
+}
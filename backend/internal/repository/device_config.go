@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type DeviceConfigProfileRepository struct {
+	*BaseRepository[domain.DeviceConfigProfile]
+}
+
+func NewDeviceConfigProfileRepository(db *gorm.DB) *DeviceConfigProfileRepository {
+	return &DeviceConfigProfileRepository{
+		BaseRepository: NewBaseRepository[domain.DeviceConfigProfile](db),
+	}
+}
+
+// FindLayers returns the profiles that make up the config of a device: its
+// organization's, its platform's and its own, whichever exist
+func (r *DeviceConfigProfileRepository) FindLayers(ctx context.Context, orgID *int64, platform domain.DevicePlatform, deviceID int64) ([]domain.DeviceConfigProfile, error) {
+	var profiles []domain.DeviceConfigProfile
+	conditions := "(scope = ? AND platform = ?) OR (scope = ? AND device_id = ?)"
+	args := []interface{}{domain.DeviceConfigScopePlatform, platform, domain.DeviceConfigScopeDevice, deviceID}
+	if orgID != nil {
+		conditions += " OR (scope = ? AND organization_id = ?)"
+		args = append(args, domain.DeviceConfigScopeOrganization, *orgID)
+	}
+	err := r.DB.WithContext(ctx).Where(conditions, args...).Find(&profiles).Error
+	return profiles, err
+}
+
+// FindByScope returns every profile of a scope
+func (r *DeviceConfigProfileRepository) FindByScope(ctx context.Context, scope string) ([]domain.DeviceConfigProfile, error) {
+	var profiles []domain.DeviceConfigProfile
+	err := r.DB.WithContext(ctx).Where("scope = ?", scope).Order("id").Find(&profiles).Error
+	return profiles, err
+}
+
+// FindOrganizationProfile returns the profile of an organization
+func (r *DeviceConfigProfileRepository) FindOrganizationProfile(ctx context.Context, orgID int64) (*domain.DeviceConfigProfile, error) {
+	return r.FindOneByCondition(ctx, map[string]interface{}{
+		"scope":           domain.DeviceConfigScopeOrganization,
+		"organization_id": orgID,
+	})
+}
+
+// FindPlatformProfile returns the profile of a platform
+func (r *DeviceConfigProfileRepository) FindPlatformProfile(ctx context.Context, platform domain.DevicePlatform) (*domain.DeviceConfigProfile, error) {
+	return r.FindOneByCondition(ctx, map[string]interface{}{
+		"scope":    domain.DeviceConfigScopePlatform,
+		"platform": platform,
+	})
+}
+
+// FindDeviceProfile returns the overrides of a device
+func (r *DeviceConfigProfileRepository) FindDeviceProfile(ctx context.Context, deviceID int64) (*domain.DeviceConfigProfile, error) {
+	return r.FindOneByCondition(ctx, map[string]interface{}{
+		"scope":     domain.DeviceConfigScopeDevice,
+		"device_id": deviceID,
+	})
+}
+
+// FindFiltered lists profiles, optionally limited to a scope, organization or platform
+func (r *DeviceConfigProfileRepository) FindFiltered(ctx context.Context, scope string, orgID *int64, platform string, params PaginationParams) (*PaginatedResult[domain.DeviceConfigProfile], error) {
+	var profiles []domain.DeviceConfigProfile
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.DeviceConfigProfile{})
+	if scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+	if orgID != nil {
+		query = query.Where("organization_id = ?", *orgID)
+	}
+	if platform != "" {
+		query = query.Where("platform = ?", platform)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.
+		Preload("Organization").
+		Order("id").
+		Offset(params.GetOffset()).
+		Limit(params.GetLimit()).
+		Find(&profiles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.DeviceConfigProfile]{
+		Data:       profiles,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// CreateWithVersion creates a profile along with its first version
+func (r *DeviceConfigProfileRepository) CreateWithVersion(ctx context.Context, profile *domain.DeviceConfigProfile, version *domain.DeviceConfigVersion) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(profile).Error; err != nil {
+			return err
+		}
+		version.ProfileID = profile.ID
+		return tx.Create(version).Error
+	})
+}
+
+// SaveVersion stores version as the new config of its profile. It reports
+// false, changing nothing, when the profile is no longer at version-1
+// because another change got there first.
+func (r *DeviceConfigProfileRepository) SaveVersion(ctx context.Context, version *domain.DeviceConfigVersion) (bool, error) {
+	saved := false
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.DeviceConfigProfile{}).
+			Where("id = ? AND version = ?", version.ProfileID, version.Version-1).
+			Updates(map[string]interface{}{
+				"config_json": version.ConfigJSON,
+				"version":     version.Version,
+				"updated_by":  version.CreatedBy,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if err := tx.Create(version).Error; err != nil {
+			return err
+		}
+		saved = true
+		return nil
+	})
+	return saved, err
+}
+
+// DeleteWithHistory deletes a profile; its versions are kept for audit
+func (r *DeviceConfigProfileRepository) DeleteWithHistory(ctx context.Context, id, deletedBy int64) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.DeviceConfigProfile{}).Where("id = ?", id).Update("deleted_by", deletedBy).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.DeviceConfigProfile{}, id).Error
+	})
+}
+
+type DeviceConfigVersionRepository struct {
+	*BaseRepository[domain.DeviceConfigVersion]
+}
+
+func NewDeviceConfigVersionRepository(db *gorm.DB) *DeviceConfigVersionRepository {
+	return &DeviceConfigVersionRepository{
+		BaseRepository: NewBaseRepository[domain.DeviceConfigVersion](db),
+	}
+}
+
+// FindVersion returns one version of a profile
+func (r *DeviceConfigVersionRepository) FindVersion(ctx context.Context, profileID int64, version int) (*domain.DeviceConfigVersion, error) {
+	return r.FindOneByCondition(ctx, map[string]interface{}{
+		"profile_id": profileID,
+		"version":    version,
+	})
+}
+
+// FindByProfile returns the history of a profile, newest first
+func (r *DeviceConfigVersionRepository) FindByProfile(ctx context.Context, profileID int64, params PaginationParams) (*PaginatedResult[domain.DeviceConfigVersion], error) {
+	var versions []domain.DeviceConfigVersion
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.DeviceConfigVersion{}).Where("profile_id = ?", profileID)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.
+		Order("version DESC").
+		Offset(params.GetOffset()).
+		Limit(params.GetLimit()).
+		Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.DeviceConfigVersion]{
+		Data:       versions,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+type DeviceConfigSchemaRepository struct {
+	*BaseRepository[domain.DeviceConfigSchema]
+}
+
+func NewDeviceConfigSchemaRepository(db *gorm.DB) *DeviceConfigSchemaRepository {
+	return &DeviceConfigSchemaRepository{
+		BaseRepository: NewBaseRepository[domain.DeviceConfigSchema](db),
+	}
+}
+
+// FindByPlatform returns the schema of a platform
+func (r *DeviceConfigSchemaRepository) FindByPlatform(ctx context.Context, platform domain.DevicePlatform) (*domain.DeviceConfigSchema, error) {
+	return r.FindOneByCondition(ctx, map[string]interface{}{"platform": platform})
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type DeviceEnrollmentPolicyRepository struct {
+	*BaseRepository[domain.DeviceEnrollmentPolicy]
+}
+
+func NewDeviceEnrollmentPolicyRepository(db *gorm.DB) *DeviceEnrollmentPolicyRepository {
+	return &DeviceEnrollmentPolicyRepository{
+		BaseRepository: NewBaseRepository[domain.DeviceEnrollmentPolicy](db),
+	}
+}
+
+// FindApplicable returns the active policies that apply to devices of orgID,
+// including global ones
+func (r *DeviceEnrollmentPolicyRepository) FindApplicable(ctx context.Context, orgID *int64) ([]domain.DeviceEnrollmentPolicy, error) {
+	var policies []domain.DeviceEnrollmentPolicy
+	query := r.DB.WithContext(ctx).Where("is_active = true")
+
+	if orgID != nil {
+		query = query.Where("organization_id IS NULL OR organization_id = ?", *orgID)
+	} else {
+		query = query.Where("organization_id IS NULL")
+	}
+
+	err := query.Find(&policies).Error
+	return policies, err
+}
+
+// FindFiltered lists policies, optionally limited to an organization
+func (r *DeviceEnrollmentPolicyRepository) FindFiltered(ctx context.Context, orgID *int64) ([]domain.DeviceEnrollmentPolicy, error) {
+	var policies []domain.DeviceEnrollmentPolicy
+	query := r.DB.WithContext(ctx).Preload("Organization")
+
+	if orgID != nil {
+		query = query.Where("organization_id = ?", *orgID)
+	}
+
+	err := query.Order("id").Find(&policies).Error
+	return policies, err
+}
@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DevicePresenceEventRepository struct {
+	*BaseRepository[domain.DevicePresenceEvent]
+}
+
+func NewDevicePresenceEventRepository(db *gorm.DB) *DevicePresenceEventRepository {
+	return &DevicePresenceEventRepository{
+		BaseRepository: NewBaseRepository[domain.DevicePresenceEvent](db),
+	}
+}
+
+// MarkOnline flags a device online, records the transition and resolves its
+// open silence alert. It reports false when the device already was online.
+func (r *DevicePresenceEventRepository) MarkOnline(ctx context.Context, deviceUID string, at time.Time) (bool, error) {
+	changed := false
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var devices []domain.Device
+		result := tx.Model(&devices).Clauses(clause.Returning{}).
+			Where("device_uid = ? AND is_online IS NOT TRUE", deviceUID).
+			Updates(map[string]interface{}{
+				"is_online":           true,
+				"presence_changed_at": at,
+			})
+		if result.Error != nil || len(devices) == 0 {
+			return result.Error
+		}
+
+		device := &devices[0]
+		if err := tx.Create(presenceEvent(device, domain.DevicePresenceOnline, at)).Error; err != nil {
+			return err
+		}
+		changed = true
+		return tx.Model(&domain.DeviceSilenceAlert{}).
+			Where("device_id = ? AND resolved_at IS NULL", device.ID).
+			Update("resolved_at", at).Error
+	})
+	return changed, err
+}
+
+// MarkOffline flags online devices without a heartbeat since cutoff offline
+// and records the transitions, dated at their last heartbeat
+func (r *DevicePresenceEventRepository) MarkOffline(ctx context.Context, cutoff time.Time) (int64, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var devices []domain.Device
+		result := tx.Model(&devices).Clauses(clause.Returning{}).
+			Where("is_online = true AND (last_heartbeat IS NULL OR last_heartbeat < ?)", cutoff).
+			Updates(map[string]interface{}{
+				"is_online":           false,
+				"presence_changed_at": time.Now(),
+			})
+		if result.Error != nil || len(devices) == 0 {
+			return result.Error
+		}
+
+		events := make([]domain.DevicePresenceEvent, len(devices))
+		for i := range devices {
+			lastSeen := cutoff
+			if devices[i].LastHeartbeat != nil {
+				lastSeen = *devices[i].LastHeartbeat
+			}
+			events[i] = *presenceEvent(&devices[i], domain.DevicePresenceOffline, lastSeen)
+		}
+		count = int64(len(events))
+		return tx.Create(&events).Error
+	})
+	return count, err
+}
+
+func presenceEvent(device *domain.Device, status string, at time.Time) *domain.DevicePresenceEvent {
+	return &domain.DevicePresenceEvent{
+		DeviceID:       device.ID,
+		Status:         status,
+		OrganizationID: device.OrganizationID,
+		Platform:       device.Platform,
+		AppVersion:     device.AppVersion,
+		OccurredAt:     at,
+	}
+}
+
+// FindByDevice returns the presence history of a device, newest first
+func (r *DevicePresenceEventRepository) FindByDevice(ctx context.Context, deviceID int64, params PaginationParams) (*PaginatedResult[domain.DevicePresenceEvent], error) {
+	var events []domain.DevicePresenceEvent
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.DevicePresenceEvent{}).Where("device_id = ?", deviceID)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.
+		Order("occurred_at DESC, id DESC").
+		Offset(params.GetOffset()).
+		Limit(params.GetLimit()).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.DevicePresenceEvent]{
+		Data:       events,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// FindLastBefore returns the latest event at or before t of each device, the
+// state the devices were in when a reporting period starts
+func (r *DevicePresenceEventRepository) FindLastBefore(ctx context.Context, deviceIDs []int64, t time.Time) ([]domain.DevicePresenceEvent, error) {
+	var events []domain.DevicePresenceEvent
+	if len(deviceIDs) == 0 {
+		return events, nil
+	}
+	err := r.DB.WithContext(ctx).
+		Raw(`SELECT DISTINCT ON (device_id) * FROM device_presence_events
+			WHERE device_id IN ? AND occurred_at <= ?
+			ORDER BY device_id, occurred_at DESC, id DESC`, deviceIDs, t).
+		Scan(&events).Error
+	return events, err
+}
+
+// FindBetween returns the events of devices in [from, to), oldest first
+func (r *DevicePresenceEventRepository) FindBetween(ctx context.Context, deviceIDs []int64, from, to time.Time) ([]domain.DevicePresenceEvent, error) {
+	var events []domain.DevicePresenceEvent
+	if len(deviceIDs) == 0 {
+		return events, nil
+	}
+	err := r.DB.WithContext(ctx).
+		Where("device_id IN ? AND occurred_at > ? AND occurred_at < ?", deviceIDs, from, to).
+		Order("device_id, occurred_at, id").
+		Find(&events).Error
+	return events, err
+}
+
+// DeleteOlderThan purges events before cutoff, keeping the latest one of each
+// device so its state is still known
+func (r *DevicePresenceEventRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	result := r.DB.WithContext(ctx).Exec(`DELETE FROM device_presence_events e
+		WHERE e.occurred_at < ? AND EXISTS (
+			SELECT 1 FROM device_presence_events later
+			WHERE later.device_id = e.device_id AND later.occurred_at > e.occurred_at
+		)`, cutoff)
+	return result.RowsAffected, result.Error
+}
+
+type DevicePresencePolicyRepository struct {
+	*BaseRepository[domain.DevicePresencePolicy]
+}
+
+func NewDevicePresencePolicyRepository(db *gorm.DB) *DevicePresencePolicyRepository {
+	return &DevicePresencePolicyRepository{
+		BaseRepository: NewBaseRepository[domain.DevicePresencePolicy](db),
+	}
+}
+
+// FindByOrganization returns the policy of an organization
+func (r *DevicePresencePolicyRepository) FindByOrganization(ctx context.Context, orgID int64) (*domain.DevicePresencePolicy, error) {
+	return r.FindOneByCondition(ctx, map[string]interface{}{"organization_id": orgID})
+}
+
+// FindFiltered lists policies, optionally limited to an organization
+func (r *DevicePresencePolicyRepository) FindFiltered(ctx context.Context, orgID *int64) ([]domain.DevicePresencePolicy, error) {
+	var policies []domain.DevicePresencePolicy
+	query := r.DB.WithContext(ctx).Preload("Organization")
+
+	if orgID != nil {
+		query = query.Where("organization_id = ?", *orgID)
+	}
+
+	err := query.Order("id").Find(&policies).Error
+	return policies, err
+}
+
+// FindActive returns the active policies
+func (r *DevicePresencePolicyRepository) FindActive(ctx context.Context) ([]domain.DevicePresencePolicy, error) {
+	var policies []domain.DevicePresencePolicy
+	err := r.DB.WithContext(ctx).Where("is_active = true").Find(&policies).Error
+	return policies, err
+}
+
+type DeviceSilenceAlertRepository struct {
+	*BaseRepository[domain.DeviceSilenceAlert]
+}
+
+func NewDeviceSilenceAlertRepository(db *gorm.DB) *DeviceSilenceAlertRepository {
+	return &DeviceSilenceAlertRepository{
+		BaseRepository: NewBaseRepository[domain.DeviceSilenceAlert](db),
+	}
+}
+
+// Raise opens an alert, reporting false when the device already has one
+func (r *DeviceSilenceAlertRepository) Raise(ctx context.Context, alert *domain.DeviceSilenceAlert) (bool, error) {
+	result := r.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(alert)
+	return result.RowsAffected > 0, result.Error
+}
+
+// FindFiltered lists alerts, newest first, optionally only open ones or those of an organization
+func (r *DeviceSilenceAlertRepository) FindFiltered(ctx context.Context, orgID *int64, openOnly bool, params PaginationParams) (*PaginatedResult[domain.DeviceSilenceAlert], error) {
+	var alerts []domain.DeviceSilenceAlert
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.DeviceSilenceAlert{})
+	if orgID != nil {
+		query = query.Where("organization_id = ?", *orgID)
+	}
+	if openOnly {
+		query = query.Where("resolved_at IS NULL")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.
+		Preload("Device").
+		Order("raised_at DESC").
+		Offset(params.GetOffset()).
+		Limit(params.GetLimit()).
+		Find(&alerts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.DeviceSilenceAlert]{
+		Data:       alerts,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// CountOpen counts open alerts, optionally of one organization
+func (r *DeviceSilenceAlertRepository) CountOpen(ctx context.Context, orgID *int64) (int64, error) {
+	var count int64
+	query := r.DB.WithContext(ctx).Model(&domain.DeviceSilenceAlert{}).Where("resolved_at IS NULL")
+	if orgID != nil {
+		query = query.Where("organization_id = ?", *orgID)
+	}
+	err := query.Count(&count).Error
+	return count, err
+}
+
+// DeleteResolvedBefore purges alerts resolved before cutoff
+func (r *DeviceSilenceAlertRepository) DeleteResolvedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result := r.DB.WithContext(ctx).
+		Where("resolved_at IS NOT NULL AND resolved_at < ?", cutoff).
+		Delete(&domain.DeviceSilenceAlert{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DeviceProofNonceRepository struct {
+	*BaseRepository[domain.DeviceProofNonce]
+}
+
+func NewDeviceProofNonceRepository(db *gorm.DB) *DeviceProofNonceRepository {
+	return &DeviceProofNonceRepository{
+		BaseRepository: NewBaseRepository[domain.DeviceProofNonce](db),
+	}
+}
+
+// Use records the jti of a proof signed with a device key. It reports false
+// when the same key already used the jti.
+func (r *DeviceProofNonceRepository) Use(ctx context.Context, thumbprint, jti string, expiresAt time.Time) (bool, error) {
+	result := r.DB.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&domain.DeviceProofNonce{
+			KeyThumbprint: thumbprint,
+			JTI:           jti,
+			ExpiresAt:     expiresAt,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *DeviceProofNonceRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result := r.DB.WithContext(ctx).
+		Where("expires_at < ?", time.Now()).
+		Delete(&domain.DeviceProofNonce{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type ImpersonationEventRepository struct {
+	*BaseRepository[domain.ImpersonationEvent]
+}
+
+func NewImpersonationEventRepository(db *gorm.DB) *ImpersonationEventRepository {
+	return &ImpersonationEventRepository{
+		BaseRepository: NewBaseRepository[domain.ImpersonationEvent](db),
+	}
+}
+
+// FindByUser returns events where the user impersonated or was impersonated, newest first
+func (r *ImpersonationEventRepository) FindByUser(ctx context.Context, userID int64, params PaginationParams) (*PaginatedResult[domain.ImpersonationEvent], error) {
+	var events []domain.ImpersonationEvent
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.ImpersonationEvent{}).
+		Where("impersonator_id = ? OR target_user_id = ?", userID, userID)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.
+		Preload("Impersonator").
+		Preload("TargetUser").
+		Order("created_at DESC").
+		Offset(params.GetOffset()).
+		Limit(params.GetLimit()).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.ImpersonationEvent]{
+		Data:       events,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
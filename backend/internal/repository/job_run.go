@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type JobRunRepository struct {
+	*BaseRepository[domain.JobRun]
+}
+
+func NewJobRunRepository(db *gorm.DB) *JobRunRepository {
+	return &JobRunRepository{
+		BaseRepository: NewBaseRepository[domain.JobRun](db),
+	}
+}
+
+// Claim records the start of a run and reports whether this caller owns it.
+// A run already recorded for the same job and scheduled time, started by
+// another replica, is left untouched.
+func (r *JobRunRepository) Claim(ctx context.Context, run *domain.JobRun) (bool, error) {
+	result := r.DB.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(run)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Finish stores the outcome of a run
+func (r *JobRunRepository) Finish(ctx context.Context, run *domain.JobRun) error {
+	return r.DB.WithContext(ctx).Model(&domain.JobRun{}).
+		Where("id = ?", run.ID).
+		Updates(map[string]interface{}{
+			"finished_at": run.FinishedAt,
+			"status":      run.Status,
+			"affected":    run.Affected,
+			"error":       run.Error,
+		}).Error
+}
+
+// FindLatestByJob returns the most recent run of a job
+func (r *JobRunRepository) FindLatestByJob(ctx context.Context, jobName string) (*domain.JobRun, error) {
+	var run domain.JobRun
+	err := r.DB.WithContext(ctx).
+		Where("job_name = ?", jobName).
+		Order("started_at DESC").
+		First(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// FindFiltered lists runs newest first, optionally for one job
+func (r *JobRunRepository) FindFiltered(ctx context.Context, jobName string, params PaginationParams) (*PaginatedResult[domain.JobRun], error) {
+	var runs []domain.JobRun
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.JobRun{})
+	if jobName != "" {
+		query = query.Where("job_name = ?", jobName)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if err := query.Order("started_at DESC").Offset(params.GetOffset()).Limit(params.GetLimit()).Find(&runs).Error; err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.JobRun]{
+		Data:       runs,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// DeleteOlderThan prunes the run history
+func (r *JobRunRepository) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	result := r.DB.WithContext(ctx).Where("started_at < ?", before).Delete(&domain.JobRun{})
+	return result.RowsAffected, result.Error
+}
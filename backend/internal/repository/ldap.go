@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type OrganizationLDAPConfigRepository struct {
+	*BaseRepository[domain.OrganizationLDAPConfig]
+}
+
+func NewOrganizationLDAPConfigRepository(db *gorm.DB) *OrganizationLDAPConfigRepository {
+	return &OrganizationLDAPConfigRepository{
+		BaseRepository: NewBaseRepository[domain.OrganizationLDAPConfig](db),
+	}
+}
+
+func (r *OrganizationLDAPConfigRepository) FindByOrganizationID(ctx context.Context, orgID int64) (*domain.OrganizationLDAPConfig, error) {
+	var config domain.OrganizationLDAPConfig
+	err := r.DB.WithContext(ctx).Where("organization_id = ?", orgID).First(&config).Error
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+type LDAPGroupRoleMappingRepository struct {
+	*BaseRepository[domain.LDAPGroupRoleMapping]
+}
+
+func NewLDAPGroupRoleMappingRepository(db *gorm.DB) *LDAPGroupRoleMappingRepository {
+	return &LDAPGroupRoleMappingRepository{
+		BaseRepository: NewBaseRepository[domain.LDAPGroupRoleMapping](db),
+	}
+}
+
+func (r *LDAPGroupRoleMappingRepository) FindByOrganizationID(ctx context.Context, orgID int64) ([]domain.LDAPGroupRoleMapping, error) {
+	var mappings []domain.LDAPGroupRoleMapping
+	err := r.DB.WithContext(ctx).
+		Preload("Role").
+		Preload("System").
+		Where("organization_id = ?", orgID).
+		Order("id").
+		Find(&mappings).Error
+	return mappings, err
+}
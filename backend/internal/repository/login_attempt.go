@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type LoginAttemptRepository struct {
+	*BaseRepository[domain.LoginAttempt]
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		BaseRepository: NewBaseRepository[domain.LoginAttempt](db),
+	}
+}
+
+// FindFailuresByUser returns failed attempts of a user since a point in time, newest first
+func (r *LoginAttemptRepository) FindFailuresByUser(ctx context.Context, userID int64, since time.Time) ([]domain.LoginAttempt, error) {
+	var attempts []domain.LoginAttempt
+	err := r.DB.WithContext(ctx).
+		Where("user_id = ? AND success = ? AND created_at > ?", userID, false, since).
+		Order("created_at DESC").
+		Find(&attempts).Error
+	return attempts, err
+}
+
+// FindFailuresByIP returns failed attempts from an address since a point in time, newest first
+func (r *LoginAttemptRepository) FindFailuresByIP(ctx context.Context, ipAddress string, since time.Time) ([]domain.LoginAttempt, error) {
+	var attempts []domain.LoginAttempt
+	err := r.DB.WithContext(ctx).
+		Where("ip_address = ? AND success = ? AND created_at > ?", ipAddress, false, since).
+		Order("created_at DESC").
+		Find(&attempts).Error
+	return attempts, err
+}
+
+func (r *LoginAttemptRepository) FindLastSuccessByUser(ctx context.Context, userID int64) (*domain.LoginAttempt, error) {
+	var attempt domain.LoginAttempt
+	err := r.DB.WithContext(ctx).
+		Where("user_id = ? AND success = ?", userID, true).
+		Order("created_at DESC").
+		First(&attempt).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (r *LoginAttemptRepository) FindByUser(ctx context.Context, userID int64, params PaginationParams) (*PaginatedResult[domain.LoginAttempt], error) {
+	var attempts []domain.LoginAttempt
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.LoginAttempt{}).Where("user_id = ?", userID)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if err := query.Order("created_at DESC").Offset(params.GetOffset()).Limit(params.GetLimit()).Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.LoginAttempt]{
+		Data:       attempts,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// DeleteOlderThan prunes the ledger
+func (r *LoginAttemptRepository) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	result := r.DB.WithContext(ctx).Where("created_at < ?", before).Delete(&domain.LoginAttempt{})
+	return result.RowsAffected, result.Error
+}
+
+type LoginLockoutRepository struct {
+	*BaseRepository[domain.LoginLockout]
+}
+
+func NewLoginLockoutRepository(db *gorm.DB) *LoginLockoutRepository {
+	return &LoginLockoutRepository{
+		BaseRepository: NewBaseRepository[domain.LoginLockout](db),
+	}
+}
+
+// FindLatestByUser returns the most recent lockout of an account
+func (r *LoginLockoutRepository) FindLatestByUser(ctx context.Context, userID int64) (*domain.LoginLockout, error) {
+	var lockout domain.LoginLockout
+	err := r.DB.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		First(&lockout).Error
+	if err != nil {
+		return nil, err
+	}
+	return &lockout, nil
+}
+
+// FindLatestByIP returns the most recent lockout of a client address
+func (r *LoginLockoutRepository) FindLatestByIP(ctx context.Context, ipAddress string) (*domain.LoginLockout, error) {
+	var lockout domain.LoginLockout
+	err := r.DB.WithContext(ctx).
+		Where("ip_address = ? AND user_id IS NULL", ipAddress).
+		Order("created_at DESC").
+		First(&lockout).Error
+	if err != nil {
+		return nil, err
+	}
+	return &lockout, nil
+}
+
+// CountByUserSince counts lockouts of an account, used to escalate repeat lockouts
+func (r *LoginLockoutRepository) CountByUserSince(ctx context.Context, userID int64, since time.Time) (int64, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Model(&domain.LoginLockout{}).
+		Where("user_id = ? AND created_at > ?", userID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// CountByIPSince counts lockouts of a client address, used to escalate repeat lockouts
+func (r *LoginLockoutRepository) CountByIPSince(ctx context.Context, ipAddress string, since time.Time) (int64, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Model(&domain.LoginLockout{}).
+		Where("ip_address = ? AND user_id IS NULL AND created_at > ?", ipAddress, since).
+		Count(&count).Error
+	return count, err
+}
+
+// UnlockUser lifts the active lockouts of an account
+func (r *LoginLockoutRepository) UnlockUser(ctx context.Context, userID int64, unlockedBy int64) error {
+	now := time.Now()
+	return r.DB.WithContext(ctx).Model(&domain.LoginLockout{}).
+		Where("user_id = ? AND unlocked_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"unlocked_at": &now,
+			"unlocked_by": unlockedBy,
+		}).Error
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type LoginRiskPolicyRepository struct {
+	*BaseRepository[domain.LoginRiskPolicy]
+}
+
+func NewLoginRiskPolicyRepository(db *gorm.DB) *LoginRiskPolicyRepository {
+	return &LoginRiskPolicyRepository{
+		BaseRepository: NewBaseRepository[domain.LoginRiskPolicy](db),
+	}
+}
+
+// FindByOrganization returns the policy of an organization
+func (r *LoginRiskPolicyRepository) FindByOrganization(ctx context.Context, orgID int64) (*domain.LoginRiskPolicy, error) {
+	return r.FindOneByCondition(ctx, map[string]interface{}{"organization_id": orgID})
+}
+
+// FindFiltered lists policies, optionally limited to an organization
+func (r *LoginRiskPolicyRepository) FindFiltered(ctx context.Context, orgID *int64) ([]domain.LoginRiskPolicy, error) {
+	var policies []domain.LoginRiskPolicy
+	query := r.DB.WithContext(ctx).Preload("Organization")
+
+	if orgID != nil {
+		query = query.Where("organization_id = ?", *orgID)
+	}
+
+	err := query.Order("id").Find(&policies).Error
+	return policies, err
+}
+
+type LoginRiskEventRepository struct {
+	*BaseRepository[domain.LoginRiskEvent]
+}
+
+func NewLoginRiskEventRepository(db *gorm.DB) *LoginRiskEventRepository {
+	return &LoginRiskEventRepository{
+		BaseRepository: NewBaseRepository[domain.LoginRiskEvent](db),
+	}
+}
+
+// LoginRiskEventFilter narrows a login risk event listing; empty fields match all
+type LoginRiskEventFilter struct {
+	OrganizationID *int64
+	UserID         *int64
+	Action         string
+	MinScore       int
+}
+
+// FindFiltered lists events, newest first
+func (r *LoginRiskEventRepository) FindFiltered(ctx context.Context, filter LoginRiskEventFilter, params PaginationParams) (*PaginatedResult[domain.LoginRiskEvent], error) {
+	var events []domain.LoginRiskEvent
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.LoginRiskEvent{})
+	if filter.OrganizationID != nil {
+		query = query.Where("organization_id = ?", *filter.OrganizationID)
+	}
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.MinScore > 0 {
+		query = query.Where("score >= ?", filter.MinScore)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.
+		Preload("User").
+		Preload("Device").
+		Order("created_at DESC, id DESC").
+		Offset(params.GetOffset()).
+		Limit(params.GetLimit()).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.LoginRiskEvent]{
+		Data:       events,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// DeleteOlderThan purges events created before cutoff
+func (r *LoginRiskEventRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	result := r.DB.WithContext(ctx).Where("created_at < ?", cutoff).Delete(&domain.LoginRiskEvent{})
+	return result.RowsAffected, result.Error
+}
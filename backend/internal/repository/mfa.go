@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type UserMFARepository struct {
+	*BaseRepository[domain.UserMFA]
+}
+
+func NewUserMFARepository(db *gorm.DB) *UserMFARepository {
+	return &UserMFARepository{
+		BaseRepository: NewBaseRepository[domain.UserMFA](db),
+	}
+}
+
+func (r *UserMFARepository) FindByUserID(ctx context.Context, userID int64) (*domain.UserMFA, error) {
+	var mfa domain.UserMFA
+	err := r.DB.WithContext(ctx).Where("user_id = ?", userID).First(&mfa).Error
+	if err != nil {
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+// MarkUsed records the accepted TOTP step. It reports false if an equal or later
+// step was already used, so a code cannot be replayed concurrently.
+func (r *UserMFARepository) MarkUsed(ctx context.Context, id int64, step int64) (bool, error) {
+	now := time.Now()
+	result := r.DB.WithContext(ctx).Model(&domain.UserMFA{}).
+		Where("id = ? AND last_used_step < ?", id, step).
+		Updates(map[string]interface{}{
+			"last_used_step": step,
+			"last_used_at":   &now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *UserMFARepository) DeleteByUserID(ctx context.Context, userID int64) error {
+	return r.DB.WithContext(ctx).Unscoped().Where("user_id = ?", userID).Delete(&domain.UserMFA{}).Error
+}
+
+type MFARecoveryCodeRepository struct {
+	*BaseRepository[domain.MFARecoveryCode]
+}
+
+func NewMFARecoveryCodeRepository(db *gorm.DB) *MFARecoveryCodeRepository {
+	return &MFARecoveryCodeRepository{
+		BaseRepository: NewBaseRepository[domain.MFARecoveryCode](db),
+	}
+}
+
+// Replace swaps all recovery codes of a user for a new set of hashes
+func (r *MFARecoveryCodeRepository) Replace(ctx context.Context, userID int64, codeHashes []string) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		for _, hash := range codeHashes {
+			code := domain.MFARecoveryCode{
+				UserID:   userID,
+				CodeHash: hash,
+			}
+			if err := tx.Create(&code).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Consume marks an unused code as used and reports whether one matched
+func (r *MFARecoveryCodeRepository) Consume(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	now := time.Now()
+	result := r.DB.WithContext(ctx).Model(&domain.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", &now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *MFARecoveryCodeRepository) CountUnused(ctx context.Context, userID int64) (int64, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Model(&domain.MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *MFARecoveryCodeRepository) DeleteByUserID(ctx context.Context, userID int64) error {
+	return r.DB.WithContext(ctx).Where("user_id = ?", userID).Delete(&domain.MFARecoveryCode{}).Error
+}
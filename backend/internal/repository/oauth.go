@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type OAuthClientRepository struct {
+	*BaseRepository[domain.OAuthClient]
+}
+
+func NewOAuthClientRepository(db *gorm.DB) *OAuthClientRepository {
+	return &OAuthClientRepository{
+		BaseRepository: NewBaseRepository[domain.OAuthClient](db),
+	}
+}
+
+func (r *OAuthClientRepository) FindByClientID(ctx context.Context, clientID string) (*domain.OAuthClient, error) {
+	var client domain.OAuthClient
+	err := r.DB.WithContext(ctx).Where("client_id = ?", clientID).First(&client).Error
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+type OAuthConsentRepository struct {
+	*BaseRepository[domain.OAuthConsent]
+}
+
+func NewOAuthConsentRepository(db *gorm.DB) *OAuthConsentRepository {
+	return &OAuthConsentRepository{
+		BaseRepository: NewBaseRepository[domain.OAuthConsent](db),
+	}
+}
+
+func (r *OAuthConsentRepository) FindByUserAndClient(ctx context.Context, userID, clientID int64) (*domain.OAuthConsent, error) {
+	var consent domain.OAuthConsent
+	err := r.DB.WithContext(ctx).Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error
+	if err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+func (r *OAuthConsentRepository) FindByUser(ctx context.Context, userID int64) ([]domain.OAuthConsent, error) {
+	var consents []domain.OAuthConsent
+	err := r.DB.WithContext(ctx).
+		Preload("Client").
+		Where("user_id = ?", userID).
+		Order("granted_at DESC").
+		Find(&consents).Error
+	return consents, err
+}
+
+func (r *OAuthConsentRepository) DeleteByUserAndClient(ctx context.Context, userID, clientID int64) error {
+	return r.DB.WithContext(ctx).Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&domain.OAuthConsent{}).Error
+}
+
+type OAuthAuthorizationCodeRepository struct {
+	*BaseRepository[domain.OAuthAuthorizationCode]
+}
+
+func NewOAuthAuthorizationCodeRepository(db *gorm.DB) *OAuthAuthorizationCodeRepository {
+	return &OAuthAuthorizationCodeRepository{
+		BaseRepository: NewBaseRepository[domain.OAuthAuthorizationCode](db),
+	}
+}
+
+func (r *OAuthAuthorizationCodeRepository) FindByHash(ctx context.Context, codeHash string) (*domain.OAuthAuthorizationCode, error) {
+	var code domain.OAuthAuthorizationCode
+	err := r.DB.WithContext(ctx).Where("code_hash = ?", codeHash).First(&code).Error
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// MarkUsed consumes a code. It reports false if the code was already redeemed.
+func (r *OAuthAuthorizationCodeRepository) MarkUsed(ctx context.Context, id int64) (bool, error) {
+	now := time.Now()
+	result := r.DB.WithContext(ctx).Model(&domain.OAuthAuthorizationCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", &now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *OAuthAuthorizationCodeRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result := r.DB.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&domain.OAuthAuthorizationCode{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type OIDCLoginStateRepository struct {
+	*BaseRepository[domain.OIDCLoginState]
+}
+
+func NewOIDCLoginStateRepository(db *gorm.DB) *OIDCLoginStateRepository {
+	return &OIDCLoginStateRepository{
+		BaseRepository: NewBaseRepository[domain.OIDCLoginState](db),
+	}
+}
+
+func (r *OIDCLoginStateRepository) FindByHash(ctx context.Context, stateHash string) (*domain.OIDCLoginState, error) {
+	var state domain.OIDCLoginState
+	err := r.DB.WithContext(ctx).Where("state_hash = ?", stateHash).First(&state).Error
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// MarkUsed consumes a state. It reports false if the state was already used,
+// so a callback cannot be replayed.
+func (r *OIDCLoginStateRepository) MarkUsed(ctx context.Context, id int64) (bool, error) {
+	now := time.Now()
+	result := r.DB.WithContext(ctx).Model(&domain.OIDCLoginState{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", &now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *OIDCLoginStateRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result := r.DB.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&domain.OIDCLoginState{})
+	return result.RowsAffected, result.Error
+}
@@ -36,6 +36,16 @@ func (r *OrganizationRepository) FindBySsoOrgID(ctx context.Context, ssoOrgID in
 	return &org, nil
 }
 
+// CreateProvisioned inserts an organization created from an external
+// identity, storing an unknown registration number as NULL
+func (r *OrganizationRepository) CreateProvisioned(ctx context.Context, org *domain.Organization) error {
+	query := r.DB.WithContext(ctx)
+	if org.RegNo == "" {
+		query = query.Omit("RegNo")
+	}
+	return query.Create(org).Error
+}
+
 func (r *OrganizationRepository) FindWithChildren(ctx context.Context, id int64) (*domain.Organization, error) {
 	var org domain.Organization
 	err := r.DB.WithContext(ctx).
@@ -110,3 +120,22 @@ func (r *OrganizationSystemRepository) FindByOrgAndSystem(ctx context.Context, o
 	}
 	return &orgSystem, nil
 }
+
+type OrganizationSecurityPolicyRepository struct {
+	*BaseRepository[domain.OrganizationSecurityPolicy]
+}
+
+func NewOrganizationSecurityPolicyRepository(db *gorm.DB) *OrganizationSecurityPolicyRepository {
+	return &OrganizationSecurityPolicyRepository{
+		BaseRepository: NewBaseRepository[domain.OrganizationSecurityPolicy](db),
+	}
+}
+
+func (r *OrganizationSecurityPolicyRepository) FindByOrganizationID(ctx context.Context, orgID int64) (*domain.OrganizationSecurityPolicy, error) {
+	var policy domain.OrganizationSecurityPolicy
+	err := r.DB.WithContext(ctx).Where("organization_id = ?", orgID).First(&policy).Error
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type PasswordHistoryRepository struct {
+	*BaseRepository[domain.PasswordHistory]
+}
+
+func NewPasswordHistoryRepository(db *gorm.DB) *PasswordHistoryRepository {
+	return &PasswordHistoryRepository{
+		BaseRepository: NewBaseRepository[domain.PasswordHistory](db),
+	}
+}
+
+// FindRecent returns the latest password hashes of a user, newest first
+func (r *PasswordHistoryRepository) FindRecent(ctx context.Context, userID int64, limit int) ([]domain.PasswordHistory, error) {
+	var history []domain.PasswordHistory
+	err := r.DB.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&history).Error
+	return history, err
+}
+
+// Prune keeps only the latest keep entries of a user
+func (r *PasswordHistoryRepository) Prune(ctx context.Context, userID int64, keep int) error {
+	recent := r.DB.Model(&domain.PasswordHistory{}).
+		Select("id").
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(keep)
+
+	return r.DB.WithContext(ctx).
+		Where("user_id = ? AND id NOT IN (?)", userID, recent).
+		Delete(&domain.PasswordHistory{}).Error
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type PasswordResetRequestRepository struct {
+	*BaseRepository[domain.PasswordResetRequest]
+}
+
+func NewPasswordResetRequestRepository(db *gorm.DB) *PasswordResetRequestRepository {
+	return &PasswordResetRequestRepository{
+		BaseRepository: NewBaseRepository[domain.PasswordResetRequest](db),
+	}
+}
+
+// FindByEmailSince returns the requests for an email since a point in time, newest first
+func (r *PasswordResetRequestRepository) FindByEmailSince(ctx context.Context, email string, since time.Time) ([]domain.PasswordResetRequest, error) {
+	var requests []domain.PasswordResetRequest
+	err := r.DB.WithContext(ctx).
+		Where("email = ? AND created_at > ?", email, since).
+		Order("created_at DESC").
+		Find(&requests).Error
+	return requests, err
+}
+
+// FindByIPSince returns the requests from an address since a point in time, newest first
+func (r *PasswordResetRequestRepository) FindByIPSince(ctx context.Context, ipAddress string, since time.Time) ([]domain.PasswordResetRequest, error) {
+	var requests []domain.PasswordResetRequest
+	err := r.DB.WithContext(ctx).
+		Where("ip_address = ? AND created_at > ?", ipAddress, since).
+		Order("created_at DESC").
+		Find(&requests).Error
+	return requests, err
+}
+
+// DeleteOlderThan prunes the ledger
+func (r *PasswordResetRequestRepository) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	result := r.DB.WithContext(ctx).Where("created_at < ?", before).Delete(&domain.PasswordResetRequest{})
+	return result.RowsAffected, result.Error
+}
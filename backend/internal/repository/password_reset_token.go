@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type PasswordResetTokenRepository struct {
+	*BaseRepository[domain.PasswordResetToken]
+}
+
+func NewPasswordResetTokenRepository(db *gorm.DB) *PasswordResetTokenRepository {
+	return &PasswordResetTokenRepository{
+		BaseRepository: NewBaseRepository[domain.PasswordResetToken](db),
+	}
+}
+
+func (r *PasswordResetTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error) {
+	var token domain.PasswordResetToken
+	err := r.DB.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed consumes a token. It reports false if the token was already used,
+// so the same token cannot be redeemed twice concurrently.
+func (r *PasswordResetTokenRepository) MarkUsed(ctx context.Context, id int64) (bool, error) {
+	now := time.Now()
+	result := r.DB.WithContext(ctx).Model(&domain.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", &now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// InvalidateByUserID consumes every outstanding token of a user
+func (r *PasswordResetTokenRepository) InvalidateByUserID(ctx context.Context, userID int64) error {
+	now := time.Now()
+	return r.DB.WithContext(ctx).Model(&domain.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", &now).Error
+}
+
+func (r *PasswordResetTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result := r.DB.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&domain.PasswordResetToken{})
+	return result.RowsAffected, result.Error
+}
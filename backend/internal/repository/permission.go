@@ -42,7 +42,7 @@ func (r *PermissionRepository) FindPlatformPermissions(ctx context.Context) ([]d
 func (r *PermissionRepository) FindByRoleID(ctx context.Context, roleID int) ([]domain.Permission, error) {
 	var permissions []domain.Permission
 	err := r.DB.WithContext(ctx).
-		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id AND role_permissions.deleted_date IS NULL").
 		Where("role_permissions.role_id = ?", roleID).
 		Find(&permissions).Error
 	return permissions, err
@@ -53,9 +53,11 @@ func (r *PermissionRepository) FindUserPermissions(ctx context.Context, userID i
 
 	query := r.DB.WithContext(ctx).
 		Distinct("permissions.*").
-		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
-		Joins("JOIN user_system_roles ON user_system_roles.role_id = role_permissions.role_id").
-		Where("user_system_roles.user_id = ? AND user_system_roles.is_active = true", userID)
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id AND role_permissions.deleted_date IS NULL").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id AND roles.deleted_date IS NULL").
+		Joins("JOIN user_system_roles ON user_system_roles.role_id = role_permissions.role_id AND user_system_roles.deleted_date IS NULL").
+		Where("user_system_roles.user_id = ? AND user_system_roles.is_active = true", userID).
+		Where("permissions.is_active = true")
 
 	if systemID != nil {
 		query = query.Where("(user_system_roles.system_id = ? OR user_system_roles.system_id IS NULL)", *systemID)
@@ -67,15 +69,39 @@ func (r *PermissionRepository) FindUserPermissions(ctx context.Context, userID i
 	return permissions, err
 }
 
+// PermissionGrant is a permission a user holds through a role assigned in
+// SystemID, or platform-wide when SystemID is nil
+type PermissionGrant struct {
+	SystemID *int
+	Code     string
+}
+
+// FindUserPermissionGrants returns the active permissions a user holds in
+// every system
+func (r *PermissionRepository) FindUserPermissionGrants(ctx context.Context, userID int64) ([]PermissionGrant, error) {
+	var grants []PermissionGrant
+	err := r.DB.WithContext(ctx).
+		Model(&domain.Permission{}).
+		Distinct("user_system_roles.system_id", "permissions.code").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id AND role_permissions.deleted_date IS NULL").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id AND roles.deleted_date IS NULL").
+		Joins("JOIN user_system_roles ON user_system_roles.role_id = role_permissions.role_id AND user_system_roles.deleted_date IS NULL").
+		Where("user_system_roles.user_id = ? AND user_system_roles.is_active = true", userID).
+		Where("permissions.is_active = true").
+		Scan(&grants).Error
+	return grants, err
+}
+
 func (r *PermissionRepository) CheckUserPermission(ctx context.Context, userID int64, systemID *int, permissionCode string) (bool, error) {
 	var count int64
 
 	query := r.DB.WithContext(ctx).
 		Model(&domain.Permission{}).
-		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
-		Joins("JOIN user_system_roles ON user_system_roles.role_id = role_permissions.role_id").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id AND role_permissions.deleted_date IS NULL").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id AND roles.deleted_date IS NULL").
+		Joins("JOIN user_system_roles ON user_system_roles.role_id = role_permissions.role_id AND user_system_roles.deleted_date IS NULL").
 		Where("user_system_roles.user_id = ? AND user_system_roles.is_active = true", userID).
-		Where("permissions.code = ?", permissionCode)
+		Where("permissions.code = ? AND permissions.is_active = true", permissionCode)
 
 	if systemID != nil {
 		query = query.Where("(user_system_roles.system_id = ? OR user_system_roles.system_id IS NULL)", *systemID)
@@ -86,3 +112,18 @@ func (r *PermissionRepository) CheckUserPermission(ctx context.Context, userID i
 	err := query.Count(&count).Error
 	return count > 0, err
 }
+
+// FindRecentAuthMinutes returns how recently a session must have entered its
+// credentials to use a permission, 0 when the permission does not ask for it
+// or does not exist
+func (r *PermissionRepository) FindRecentAuthMinutes(ctx context.Context, code string) (int, error) {
+	var minutes []int
+	err := r.DB.WithContext(ctx).
+		Model(&domain.Permission{}).
+		Where("code = ?", code).
+		Pluck("recent_auth_minutes", &minutes).Error
+	if err != nil || len(minutes) == 0 {
+		return 0, err
+	}
+	return minutes[0], nil
+}
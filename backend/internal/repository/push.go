@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type PushTemplateRepository struct {
+	*BaseRepository[domain.PushTemplate]
+}
+
+func NewPushTemplateRepository(db *gorm.DB) *PushTemplateRepository {
+	return &PushTemplateRepository{
+		BaseRepository: NewBaseRepository[domain.PushTemplate](db),
+	}
+}
+
+// FindByCode returns the template with a code
+func (r *PushTemplateRepository) FindByCode(ctx context.Context, code string) (*domain.PushTemplate, error) {
+	return r.FindOneByCondition(ctx, map[string]interface{}{"code": code})
+}
+
+// FindFiltered lists templates by code, optionally matching a search term
+func (r *PushTemplateRepository) FindFiltered(ctx context.Context, search string, params PaginationParams) (*PaginatedResult[domain.PushTemplate], error) {
+	var templates []domain.PushTemplate
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.PushTemplate{})
+	if search != "" {
+		query = query.Where("code ILIKE ? OR name ILIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if err := query.Order("code").Offset(params.GetOffset()).Limit(params.GetLimit()).Find(&templates).Error; err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.PushTemplate]{
+		Data:       templates,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+type PushNotificationRepository struct {
+	*BaseRepository[domain.PushNotification]
+}
+
+func NewPushNotificationRepository(db *gorm.DB) *PushNotificationRepository {
+	return &PushNotificationRepository{
+		BaseRepository: NewBaseRepository[domain.PushNotification](db),
+	}
+}
+
+// CreateWithDeliveries stores a notification and its deliveries
+func (r *PushNotificationRepository) CreateWithDeliveries(ctx context.Context, notification *domain.PushNotification, deliveries []domain.PushDelivery) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(notification).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+		for i := range deliveries {
+			deliveries[i].NotificationID = notification.ID
+		}
+		return tx.CreateInBatches(deliveries, 500).Error
+	})
+}
+
+// FindFiltered lists notifications, newest first, optionally of one target type
+func (r *PushNotificationRepository) FindFiltered(ctx context.Context, targetType string, params PaginationParams) (*PaginatedResult[domain.PushNotification], error) {
+	var notifications []domain.PushNotification
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.PushNotification{})
+	if targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.
+		Order("created_at DESC, id DESC").
+		Offset(params.GetOffset()).
+		Limit(params.GetLimit()).
+		Find(&notifications).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.PushNotification]{
+		Data:       notifications,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// DeleteOlderThan purges notifications created before cutoff with their deliveries
+func (r *PushNotificationRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	var deleted int64
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		old := tx.Model(&domain.PushNotification{}).Select("id").Where("created_at < ?", cutoff)
+		if err := tx.Where("notification_id IN (?)", old).Delete(&domain.PushDelivery{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("created_at < ?", cutoff).Delete(&domain.PushNotification{})
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	return deleted, err
+}
+
+type PushDeliveryRepository struct {
+	*BaseRepository[domain.PushDelivery]
+}
+
+func NewPushDeliveryRepository(db *gorm.DB) *PushDeliveryRepository {
+	return &PushDeliveryRepository{
+		BaseRepository: NewBaseRepository[domain.PushDelivery](db),
+	}
+}
+
+// Claim takes up to limit pending deliveries that are due, optionally of one
+// notification, counting an attempt and holding them for lease so
+// concurrent dispatchers skip them. Deliveries of a dispatcher that dies are
+// retried once the lease ends.
+func (r *PushDeliveryRepository) Claim(ctx context.Context, notificationID *int64, maxAttempts int, lease time.Duration, limit int) ([]domain.PushDelivery, error) {
+	now := time.Now()
+	filter := ""
+	args := []interface{}{now.Add(lease), now, domain.PushDeliveryPending, now, maxAttempts}
+	if notificationID != nil {
+		filter = "AND notification_id = ?"
+		args = append(args, *notificationID)
+	}
+	args = append(args, limit)
+
+	var deliveries []domain.PushDelivery
+	err := r.DB.WithContext(ctx).Raw(`UPDATE push_deliveries
+		SET attempts = attempts + 1, next_attempt_at = ?, updated_at = ?
+		WHERE id IN (
+			SELECT id FROM push_deliveries
+			WHERE status = ? AND next_attempt_at <= ? AND attempts < ? `+filter+`
+			ORDER BY next_attempt_at, id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, args...).
+		Scan(&deliveries).Error
+	return deliveries, err
+}
+
+// Finish records the outcome of an attempt. nextAttemptAt is only used for
+// deliveries left pending.
+func (r *PushDeliveryRepository) Finish(ctx context.Context, id int64, status, lastError string, nextAttemptAt *time.Time) error {
+	updates := map[string]interface{}{
+		"status":          status,
+		"last_error":      lastError,
+		"next_attempt_at": nextAttemptAt,
+	}
+	if status == domain.PushDeliverySent {
+		updates["sent_at"] = time.Now()
+	}
+	return r.DB.WithContext(ctx).Model(&domain.PushDelivery{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// FailExhausted fails pending deliveries out of attempts whose last lease
+// ended without an outcome
+func (r *PushDeliveryRepository) FailExhausted(ctx context.Context, maxAttempts int) (int64, error) {
+	result := r.DB.WithContext(ctx).Model(&domain.PushDelivery{}).
+		Where("status = ? AND attempts >= ? AND next_attempt_at <= ?", domain.PushDeliveryPending, maxAttempts, time.Now()).
+		Updates(map[string]interface{}{
+			"status":          domain.PushDeliveryFailed,
+			"next_attempt_at": nil,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// FindByNotification lists the deliveries of a notification, optionally with one status
+func (r *PushDeliveryRepository) FindByNotification(ctx context.Context, notificationID int64, status string, params PaginationParams) (*PaginatedResult[domain.PushDelivery], error) {
+	var deliveries []domain.PushDelivery
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.PushDelivery{}).Where("notification_id = ?", notificationID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.
+		Preload("Device").
+		Order("id").
+		Offset(params.GetOffset()).
+		Limit(params.GetLimit()).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.PushDelivery]{
+		Data:       deliveries,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// PushDeliveryCount is the number of deliveries of a notification in a status
+type PushDeliveryCount struct {
+	NotificationID int64
+	Status         string
+	Count          int64
+}
+
+// CountByStatus counts the deliveries of notifications by status
+func (r *PushDeliveryRepository) CountByStatus(ctx context.Context, notificationIDs []int64) ([]PushDeliveryCount, error) {
+	var counts []PushDeliveryCount
+	if len(notificationIDs) == 0 {
+		return counts, nil
+	}
+	err := r.DB.WithContext(ctx).Model(&domain.PushDelivery{}).
+		Select("notification_id, status, COUNT(*) AS count").
+		Where("notification_id IN ?", notificationIDs).
+		Group("notification_id, status").
+		Scan(&counts).Error
+	return counts, err
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RevokedTokenRepository struct {
+	*BaseRepository[domain.RevokedToken]
+}
+
+func NewRevokedTokenRepository(db *gorm.DB) *RevokedTokenRepository {
+	return &RevokedTokenRepository{
+		BaseRepository: NewBaseRepository[domain.RevokedToken](db),
+	}
+}
+
+// Revoke records a revoked token, ignoring tokens that are already revoked
+func (r *RevokedTokenRepository) Revoke(ctx context.Context, token *domain.RevokedToken) error {
+	return r.DB.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "token_id"}}, DoNothing: true}).
+		Create(token).Error
+}
+
+func (r *RevokedTokenRepository) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Model(&domain.RevokedToken{}).
+		Where("token_id = ?", tokenID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *RevokedTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result := r.DB.WithContext(ctx).
+		Where("expires_at < ?", time.Now()).
+		Delete(&domain.RevokedToken{})
+	return result.RowsAffected, result.Error
+}
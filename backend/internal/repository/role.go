@@ -170,6 +170,15 @@ func (r *UserSystemRoleRepository) FindByUserAndSystem(ctx context.Context, user
 	return roles, err
 }
 
+func (r *UserSystemRoleRepository) FindUserIDsByRoleID(ctx context.Context, roleID int) ([]int64, error) {
+	var userIDs []int64
+	err := r.DB.WithContext(ctx).Model(&domain.UserSystemRole{}).
+		Distinct("user_id").
+		Where("role_id = ?", roleID).
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
 func (r *UserSystemRoleRepository) AssignRoles(ctx context.Context, userID int64, systemID *int, roleIDs []int, orgID *int64, createdBy int64) error {
 	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		query := tx.Where("user_id = ?", userID)
@@ -38,7 +38,22 @@ func (r *SessionRepository) FindByUserID(ctx context.Context, userID int64) ([]d
 	err := r.DB.WithContext(ctx).
 		Preload("Device").
 		Preload("CurrentSystem").
-		Where("user_id = ? AND is_active = true", userID).
+		Preload("OAuthClient").
+		Where("user_id = ? AND is_active = true AND expires_at > ?", userID, time.Now()).
+		Order("COALESCE(last_activity, created_date) DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// FindHistoryByUser returns up to limit sessions a user opened since a point,
+// ended ones included, newest first. Sessions opened by an impersonator are
+// left out.
+func (r *SessionRepository) FindHistoryByUser(ctx context.Context, userID int64, since time.Time, limit int) ([]domain.Session, error) {
+	var sessions []domain.Session
+	err := r.DB.WithContext(ctx).
+		Where("user_id = ? AND impersonator_id IS NULL AND created_date >= ?", userID, since).
+		Order("created_date DESC").
+		Limit(limit).
 		Find(&sessions).Error
 	return sessions, err
 }
@@ -52,6 +67,30 @@ func (r *SessionRepository) FindByDeviceID(ctx context.Context, deviceID int64)
 	return sessions, err
 }
 
+// FindLiveByUserAndPlatforms returns a user's unexpired active sessions on
+// devices of the given platforms, oldest first
+func (r *SessionRepository) FindLiveByUserAndPlatforms(ctx context.Context, userID int64, platforms []domain.DevicePlatform) ([]domain.Session, error) {
+	var sessions []domain.Session
+	err := r.DB.WithContext(ctx).
+		Joins("JOIN devices ON devices.id = sessions.device_id").
+		Where("sessions.user_id = ? AND sessions.is_active = true AND sessions.expires_at > ?", userID, time.Now()).
+		Where("devices.platform IN ?", platforms).
+		Order("sessions.created_date ASC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// FindLiveByDevice returns the unexpired active sessions of any user on a
+// device, oldest first
+func (r *SessionRepository) FindLiveByDevice(ctx context.Context, deviceID int64) ([]domain.Session, error) {
+	var sessions []domain.Session
+	err := r.DB.WithContext(ctx).
+		Where("device_id = ? AND is_active = true AND expires_at > ?", deviceID, time.Now()).
+		Order("created_date ASC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
 func (r *SessionRepository) FindActiveSessions(ctx context.Context, params PaginationParams) (*PaginatedResult[domain.Session], error) {
 	var sessions []domain.Session
 	var total int64
@@ -88,23 +127,75 @@ func (r *SessionRepository) FindActiveSessions(ctx context.Context, params Pagin
 	}, nil
 }
 
-func (r *SessionRepository) UpdateActivity(ctx context.Context, sessionID int64) error {
+// UpdateActivity stamps last activity and, when expiresAt is set, extends the session
+func (r *SessionRepository) UpdateActivity(ctx context.Context, sessionID int64, expiresAt *time.Time) error {
 	now := time.Now()
+	updates := map[string]interface{}{
+		"last_activity": &now,
+	}
+	if expiresAt != nil {
+		updates["expires_at"] = *expiresAt
+	}
+	return r.DB.WithContext(ctx).Model(&domain.Session{}).
+		Where("id = ?", sessionID).
+		Updates(updates).Error
+}
+
+// UpdateAuthenticatedAt stamps when the user last entered their credentials
+// in a session
+func (r *SessionRepository) UpdateAuthenticatedAt(ctx context.Context, sessionID int64, at time.Time) error {
 	return r.DB.WithContext(ctx).Model(&domain.Session{}).
 		Where("id = ?", sessionID).
-		Update("last_activity", &now).Error
+		Update("authenticated_at", &at).Error
 }
 
-func (r *SessionRepository) UpdateCurrentSystem(ctx context.Context, sessionID int64, systemID int) error {
+func (r *SessionRepository) UpdateCurrentSystem(ctx context.Context, sessionID int64, systemID int, tokenID string, tokenExpiresAt time.Time) error {
 	now := time.Now()
 	return r.DB.WithContext(ctx).Model(&domain.Session{}).
 		Where("id = ?", sessionID).
 		Updates(map[string]interface{}{
-			"current_system_id":  systemID,
-			"last_system_switch": &now,
+			"current_system_id":       systemID,
+			"last_system_switch":      &now,
+			"system_token_id":         tokenID,
+			"system_token_expires_at": &tokenExpiresAt,
+		}).Error
+}
+
+func (r *SessionRepository) ClearCurrentSystem(ctx context.Context, sessionID int64) error {
+	return r.DB.WithContext(ctx).Model(&domain.Session{}).
+		Where("id = ?", sessionID).
+		Updates(map[string]interface{}{
+			"current_system_id":       nil,
+			"system_token_id":         "",
+			"system_token_expires_at": nil,
 		}).Error
 }
 
+func (r *SessionRepository) ClearSystemToken(ctx context.Context, sessionID int64) error {
+	return r.DB.WithContext(ctx).Model(&domain.Session{}).
+		Where("id = ?", sessionID).
+		Updates(map[string]interface{}{
+			"system_token_id":         "",
+			"system_token_expires_at": nil,
+		}).Error
+}
+
+// FindWithSystemToken returns active sessions of the given users that hold an
+// outstanding system token, optionally limited to one system
+func (r *SessionRepository) FindWithSystemToken(ctx context.Context, userIDs []int64, systemID *int) ([]domain.Session, error) {
+	var sessions []domain.Session
+	query := r.DB.WithContext(ctx).
+		Where("user_id IN ? AND is_active = true", userIDs).
+		Where("system_token_id <> '' AND system_token_expires_at > ?", time.Now())
+
+	if systemID != nil {
+		query = query.Where("current_system_id = ?", *systemID)
+	}
+
+	err := query.Find(&sessions).Error
+	return sessions, err
+}
+
 func (r *SessionRepository) Logout(ctx context.Context, sessionID int64, reason string) error {
 	now := time.Now()
 	return r.DB.WithContext(ctx).Model(&domain.Session{}).
@@ -127,6 +218,29 @@ func (r *SessionRepository) LogoutByUserID(ctx context.Context, userID int64, re
 		}).Error
 }
 
+// LogoutOtherSessions terminates every active session of a user except one
+func (r *SessionRepository) LogoutOtherSessions(ctx context.Context, userID, keepSessionID int64, reason string) error {
+	now := time.Now()
+	return r.DB.WithContext(ctx).Model(&domain.Session{}).
+		Where("user_id = ? AND id <> ? AND is_active = true", userID, keepSessionID).
+		Updates(map[string]interface{}{
+			"is_active":     false,
+			"logout_at":     &now,
+			"logout_reason": reason,
+		}).Error
+}
+
+func (r *SessionRepository) LogoutByOAuthClient(ctx context.Context, userID, clientID int64, reason string) error {
+	now := time.Now()
+	return r.DB.WithContext(ctx).Model(&domain.Session{}).
+		Where("user_id = ? AND oauth_client_id = ? AND is_active = true", userID, clientID).
+		Updates(map[string]interface{}{
+			"is_active":     false,
+			"logout_at":     &now,
+			"logout_reason": reason,
+		}).Error
+}
+
 func (r *SessionRepository) LogoutByDeviceID(ctx context.Context, deviceID int64, reason string) error {
 	now := time.Now()
 	return r.DB.WithContext(ctx).Model(&domain.Session{}).
@@ -146,15 +260,16 @@ func (r *SessionRepository) CountActiveSessions(ctx context.Context) (int64, err
 	return count, err
 }
 
-func (r *SessionRepository) CleanupExpiredSessions(ctx context.Context) error {
+func (r *SessionRepository) CleanupExpiredSessions(ctx context.Context) (int64, error) {
 	now := time.Now()
-	return r.DB.WithContext(ctx).Model(&domain.Session{}).
+	result := r.DB.WithContext(ctx).Model(&domain.Session{}).
 		Where("expires_at < ? AND is_active = true", now).
 		Updates(map[string]interface{}{
 			"is_active":     false,
 			"logout_at":     &now,
 			"logout_reason": "expired",
-		}).Error
+		})
+	return result.RowsAffected, result.Error
 }
 
 type SessionSystemHistoryRepository struct {
@@ -186,3 +301,49 @@ func (r *SessionSystemHistoryRepository) RecordSwitch(ctx context.Context, sessi
 	}
 	return r.DB.WithContext(ctx).Create(&history).Error
 }
+
+type RefreshTokenRepository struct {
+	*BaseRepository[domain.RefreshToken]
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		BaseRepository: NewBaseRepository[domain.RefreshToken](db),
+	}
+}
+
+func (r *RefreshTokenRepository) FindByTokenID(ctx context.Context, tokenID string) (*domain.RefreshToken, error) {
+	var token domain.RefreshToken
+	err := r.DB.WithContext(ctx).Where("token_id = ?", tokenID).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed consumes a refresh token. It reports false if the token was already
+// used or revoked, so concurrent refreshes cannot both succeed.
+func (r *RefreshTokenRepository) MarkUsed(ctx context.Context, id int64) (bool, error) {
+	now := time.Now()
+	result := r.DB.WithContext(ctx).Model(&domain.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", &now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *RefreshTokenRepository) RevokeBySessionID(ctx context.Context, sessionID int64) error {
+	now := time.Now()
+	return r.DB.WithContext(ctx).Model(&domain.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", &now).Error
+}
+
+func (r *RefreshTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result := r.DB.WithContext(ctx).
+		Where("expires_at < ?", time.Now()).
+		Delete(&domain.RefreshToken{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type SessionLimitPolicyRepository struct {
+	*BaseRepository[domain.SessionLimitPolicy]
+}
+
+func NewSessionLimitPolicyRepository(db *gorm.DB) *SessionLimitPolicyRepository {
+	return &SessionLimitPolicyRepository{
+		BaseRepository: NewBaseRepository[domain.SessionLimitPolicy](db),
+	}
+}
+
+// FindApplicable returns the active policies that apply to a member of orgID
+// holding roleIDs, including global ones
+func (r *SessionLimitPolicyRepository) FindApplicable(ctx context.Context, orgID *int64, roleIDs []int) ([]domain.SessionLimitPolicy, error) {
+	var policies []domain.SessionLimitPolicy
+	query := r.DB.WithContext(ctx).Where("is_active = true")
+
+	if orgID != nil {
+		query = query.Where("organization_id IS NULL OR organization_id = ?", *orgID)
+	} else {
+		query = query.Where("organization_id IS NULL")
+	}
+	if len(roleIDs) > 0 {
+		query = query.Where("role_id IS NULL OR role_id IN ?", roleIDs)
+	} else {
+		query = query.Where("role_id IS NULL")
+	}
+
+	err := query.Find(&policies).Error
+	return policies, err
+}
+
+// FindFiltered lists policies, optionally limited to an organization or role
+func (r *SessionLimitPolicyRepository) FindFiltered(ctx context.Context, orgID *int64, roleID *int) ([]domain.SessionLimitPolicy, error) {
+	var policies []domain.SessionLimitPolicy
+	query := r.DB.WithContext(ctx).
+		Preload("Organization").
+		Preload("Role")
+
+	if orgID != nil {
+		query = query.Where("organization_id = ?", *orgID)
+	}
+	if roleID != nil {
+		query = query.Where("role_id = ?", *roleID)
+	}
+
+	err := query.Order("id").Find(&policies).Error
+	return policies, err
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type SessionTimeoutPolicyRepository struct {
+	*BaseRepository[domain.SessionTimeoutPolicy]
+}
+
+func NewSessionTimeoutPolicyRepository(db *gorm.DB) *SessionTimeoutPolicyRepository {
+	return &SessionTimeoutPolicyRepository{
+		BaseRepository: NewBaseRepository[domain.SessionTimeoutPolicy](db),
+	}
+}
+
+// FindApplicable returns the active policies that apply to sessions of orgID,
+// including global ones
+func (r *SessionTimeoutPolicyRepository) FindApplicable(ctx context.Context, orgID *int64) ([]domain.SessionTimeoutPolicy, error) {
+	var policies []domain.SessionTimeoutPolicy
+	query := r.DB.WithContext(ctx).Where("is_active = true")
+
+	if orgID != nil {
+		query = query.Where("organization_id IS NULL OR organization_id = ?", *orgID)
+	} else {
+		query = query.Where("organization_id IS NULL")
+	}
+
+	err := query.Find(&policies).Error
+	return policies, err
+}
+
+// FindFiltered lists policies, optionally limited to an organization
+func (r *SessionTimeoutPolicyRepository) FindFiltered(ctx context.Context, orgID *int64) ([]domain.SessionTimeoutPolicy, error) {
+	var policies []domain.SessionTimeoutPolicy
+	query := r.DB.WithContext(ctx).Preload("Organization")
+
+	if orgID != nil {
+		query = query.Where("organization_id = ?", *orgID)
+	}
+
+	err := query.Order("id").Find(&policies).Error
+	return policies, err
+}
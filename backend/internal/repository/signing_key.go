@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gebase/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type SigningKeyRepository struct {
+	*BaseRepository[domain.SigningKey]
+}
+
+func NewSigningKeyRepository(db *gorm.DB) *SigningKeyRepository {
+	return &SigningKeyRepository{
+		BaseRepository: NewBaseRepository[domain.SigningKey](db),
+	}
+}
+
+// FindVerifiable returns keys that may still verify tokens, newest first
+func (r *SigningKeyRepository) FindVerifiable(ctx context.Context, algorithm domain.SigningAlgorithm) ([]domain.SigningKey, error) {
+	var keys []domain.SigningKey
+	err := r.DB.WithContext(ctx).
+		Where("algorithm = ? AND (expires_at IS NULL OR expires_at > ?)", algorithm, time.Now()).
+		Order("activated_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+func (r *SigningKeyRepository) Retire(ctx context.Context, id int64, expiresAt time.Time) error {
+	now := time.Now()
+	return r.DB.WithContext(ctx).Model(&domain.SigningKey{}).
+		Where("id = ? AND retired_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"retired_at": &now,
+			"expires_at": &expiresAt,
+		}).Error
+}
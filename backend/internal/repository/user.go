@@ -45,6 +45,23 @@ func (r *UserRepository) FindBySsoUserID(ctx context.Context, ssoUserID int64) (
 	return &user, nil
 }
 
+// CreateProvisioned inserts a user created from an external identity. An
+// unknown registration number is stored as NULL so it does not collide with
+// the unique index.
+func (r *UserRepository) CreateProvisioned(ctx context.Context, user *domain.User) error {
+	query := r.DB.WithContext(ctx)
+	if user.RegNo == "" {
+		query = query.Omit("RegNo")
+	}
+	return query.Create(user).Error
+}
+
+// UpdateSSOProfile writes only the given columns, used to link and refresh
+// accounts from an external identity without touching other fields
+func (r *UserRepository) UpdateSSOProfile(ctx context.Context, id int64, updates map[string]interface{}) error {
+	return r.DB.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).Updates(updates).Error
+}
+
 func (r *UserRepository) FindWithRoles(ctx context.Context, id int64) (*domain.User, error) {
 	var user domain.User
 	err := r.DB.WithContext(ctx).
@@ -87,6 +104,66 @@ func (r *UserRepository) FindByOrganization(ctx context.Context, orgID int64, pa
 	}, nil
 }
 
+// FindServiceAccounts returns service accounts, optionally of one organization
+func (r *UserRepository) FindServiceAccounts(ctx context.Context, orgID *int64, params PaginationParams) (*PaginatedResult[domain.User], error) {
+	var users []domain.User
+	var total int64
+
+	query := r.DB.WithContext(ctx).Model(&domain.User{}).Where("service_account = ?", true)
+	if orgID != nil {
+		query = query.Where("organization_id = ?", *orgID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if err := query.Order("id").Offset(params.GetOffset()).Limit(params.GetLimit()).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / params.GetLimit()
+	if int(total)%params.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &PaginatedResult[domain.User]{
+		Data:       users,
+		Page:       params.Page,
+		PageSize:   params.GetLimit(),
+		Total:      total,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// FindWithPermission returns the active users of an organization holding a
+// permission through any of their roles. A nil orgID matches users without
+// an organization (platform administrators). Service accounts are excluded.
+func (r *UserRepository) FindWithPermission(ctx context.Context, orgID *int64, permissionCode string) ([]domain.User, error) {
+	var users []domain.User
+
+	query := r.DB.WithContext(ctx).
+		Distinct("users.*").
+		Joins("JOIN user_system_roles ON user_system_roles.user_id = users.id AND user_system_roles.is_active = true").
+		Joins("JOIN role_permissions ON role_permissions.role_id = user_system_roles.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("permissions.code = ? AND users.is_active = true AND users.service_account IS NOT TRUE", permissionCode)
+
+	if orgID != nil {
+		query = query.Where("users.organization_id = ?", *orgID)
+	} else {
+		query = query.Where("users.organization_id IS NULL")
+	}
+
+	err := query.Find(&users).Error
+	return users, err
+}
+
+func (r *UserRepository) SetMustChangePassword(ctx context.Context, userID int64, mustChange bool) error {
+	return r.DB.WithContext(ctx).Model(&domain.User{}).Where("id = ?", userID).
+		Update("must_change_password", mustChange).Error
+}
+
 func (r *UserRepository) UpdateLastLogin(ctx context.Context, userID int64) error {
 	return r.DB.WithContext(ctx).Model(&domain.User{}).Where("id = ?", userID).
 		Update("last_login_at", gorm.Expr("NOW()")).Error
@@ -0,0 +1,264 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrJobRunning   = errors.New("job is already running")
+	ErrDuplicateJob = errors.New("job is already registered")
+)
+
+// JobFunc performs a job and returns the number of records it affected
+type JobFunc func(ctx context.Context) (int64, error)
+
+// JobInfo describes a registered job
+type JobInfo struct {
+	Name     string         `json:"name"`
+	Schedule string         `json:"schedule"`
+	NextRun  time.Time      `json:"next_run"`
+	LastRun  *domain.JobRun `json:"last_run,omitempty"`
+}
+
+type job struct {
+	name     string
+	spec     string
+	schedule Schedule
+	run      JobFunc
+}
+
+// Scheduler runs registered jobs on cron schedules inside the server
+// process. Every replica runs the scheduler; a Postgres advisory lock per job
+// elects the replica that executes a run, and the job run history guarantees
+// a scheduled time is handled once even when replicas fire moments apart.
+type Scheduler struct {
+	db       *gorm.DB
+	runRepo  *repository.JobRunRepository
+	instance string
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(db *gorm.DB, runRepo *repository.JobRunRepository) *Scheduler {
+	instance, err := os.Hostname()
+	if err != nil {
+		instance = "unknown"
+	}
+	return &Scheduler{
+		db:       db,
+		runRepo:  runRepo,
+		instance: instance,
+		jobs:     make(map[string]*job),
+	}
+}
+
+// Register adds a job. It must be called before Start.
+func (s *Scheduler) Register(name, spec string, run JobFunc) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return ErrDuplicateJob
+	}
+	s.jobs[name] = &job{name: name, spec: spec, schedule: schedule, run: run}
+	return nil
+}
+
+// Start runs every registered job on its schedule until Stop is called or
+// ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, j)
+	}
+	log.Printf("Scheduler started with %d jobs", len(s.jobs))
+}
+
+// Stop cancels running jobs and waits for them to return
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	s.wg.Wait()
+	log.Println("Scheduler stopped")
+}
+
+// Jobs lists the registered jobs with their next and last runs
+func (s *Scheduler) Jobs(ctx context.Context) ([]JobInfo, error) {
+	s.mu.Lock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mu.Unlock()
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].name < jobs[b].name })
+
+	now := time.Now()
+	infos := make([]JobInfo, 0, len(jobs))
+	for _, j := range jobs {
+		info := JobInfo{Name: j.name, Schedule: j.spec, NextRun: j.schedule.Next(now)}
+		last, err := s.runRepo.FindLatestByJob(ctx, j.name)
+		if err != nil && !repository.IsNotFound(err) {
+			return nil, err
+		}
+		info.LastRun = last
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// RunNow executes a job immediately, outside its schedule
+func (s *Scheduler) RunNow(ctx context.Context, name string, triggeredBy int64) (*domain.JobRun, error) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	run, err := s.execute(ctx, j, time.Now(), &triggeredBy)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, ErrJobRunning
+	}
+	return run, nil
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	defer s.wg.Done()
+
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("Warning: job %s has no upcoming run", j.name)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if _, err := s.execute(ctx, j, next, nil); err != nil {
+			log.Printf("Warning: job %s: %v", j.name, err)
+		}
+	}
+}
+
+// execute runs a job under its advisory lock. It returns a nil run when
+// another replica holds the lock or already handled scheduledAt.
+func (s *Scheduler) execute(ctx context.Context, j *job, scheduledAt time.Time, triggeredBy *int64) (*domain.JobRun, error) {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	// Advisory locks belong to a database session, so lock and unlock on the
+	// same connection
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	key := lockKey(j.name)
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		return nil, fmt.Errorf("acquire lock: %w", err)
+	}
+	if !acquired {
+		return nil, nil
+	}
+	defer func() {
+		// Unlock even when ctx was cancelled by shutdown
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			log.Printf("Warning: failed to release lock of job %s: %v", j.name, err)
+		}
+	}()
+
+	run := &domain.JobRun{
+		JobName:     j.name,
+		ScheduledAt: scheduledAt,
+		StartedAt:   time.Now(),
+		Status:      domain.JobRunRunning,
+		Instance:    s.instance,
+		TriggeredBy: triggeredBy,
+	}
+	claimed, err := s.runRepo.Claim(ctx, run)
+	if err != nil {
+		return nil, fmt.Errorf("record run: %w", err)
+	}
+	if !claimed {
+		return nil, nil
+	}
+
+	affected, runErr := s.safeRun(ctx, j)
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Affected = affected
+	run.Status = domain.JobRunSucceeded
+	if runErr != nil {
+		run.Status = domain.JobRunFailed
+		run.Error = runErr.Error()
+		log.Printf("Warning: job %s failed: %v", j.name, runErr)
+	}
+	if err := s.runRepo.Finish(context.Background(), run); err != nil {
+		return run, fmt.Errorf("record run result: %w", err)
+	}
+	return run, nil
+}
+
+// safeRun keeps a panicking job from taking down the server
+func (s *Scheduler) safeRun(ctx context.Context, j *job) (affected int64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return j.run(ctx)
+}
+
+// lockKey derives the advisory lock key of a job from its name
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("gebase.job." + name))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+)
+
+// accessTokenTouchInterval throttles last-used writes of busy tokens
+const accessTokenTouchInterval = time.Minute
+
+var (
+	ErrAccessTokenNotFound   = errors.New("access token not found")
+	ErrInvalidAccessToken    = errors.New("access token is invalid, expired or revoked")
+	ErrAccessTokenExpiry     = errors.New("access token expiry must be in the future and within the maximum lifetime")
+	ErrAccessTokenPermission = errors.New("access token permissions must be a subset of the owner's permissions in the system")
+	ErrStepUpRequired        = errors.New("permission requires recent authentication")
+)
+
+// StepUpRequiredError wraps ErrStepUpRequired with the permission that asks
+// for recent authentication and how recent it must be
+type StepUpRequiredError struct {
+	Permission string
+	Window     time.Duration
+}
+
+func (e *StepUpRequiredError) Error() string {
+	return ErrStepUpRequired.Error() + ": " + e.Permission
+}
+
+func (e *StepUpRequiredError) Unwrap() error {
+	return ErrStepUpRequired
+}
+
+// AccessTokenService manages personal access tokens, the credentials of
+// scripts, CI jobs and service accounts
+type AccessTokenService struct {
+	config         *config.Config
+	tokenRepo      *repository.PersonalAccessTokenRepository
+	userRepo       *repository.UserRepository
+	systemRepo     *repository.SystemRepository
+	permissionRepo *repository.PermissionRepository
+}
+
+func NewAccessTokenService(
+	cfg *config.Config,
+	tokenRepo *repository.PersonalAccessTokenRepository,
+	userRepo *repository.UserRepository,
+	systemRepo *repository.SystemRepository,
+	permissionRepo *repository.PermissionRepository,
+) *AccessTokenService {
+	return &AccessTokenService{
+		config:         cfg,
+		tokenRepo:      tokenRepo,
+		userRepo:       userRepo,
+		systemRepo:     systemRepo,
+		permissionRepo: permissionRepo,
+	}
+}
+
+type CreateAccessTokenRequest struct {
+	Name        string   `json:"name" binding:"required,max=100"`
+	SystemID    int      `json:"system_id" binding:"required"`
+	Permissions []string `json:"permissions" binding:"required,min=1"`
+	// ExpiresAt defaults to ACCESS_TOKEN_DEFAULT_EXPIRY from now
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// AccessTokenCredentials carries a new token. The token itself is only
+// returned here.
+type AccessTokenCredentials struct {
+	Token       *domain.PersonalAccessToken `json:"token"`
+	AccessToken string                      `json:"access_token"`
+}
+
+// Create issues a token for userID. Every requested permission must be held
+// by the user in the system; impersonation cannot be delegated to a token.
+// Tokens skip step-up, so a permission that asks for recent authentication
+// is only granted when the creating session, which last entered its
+// credentials at authenticatedAt, is recent enough to use it.
+func (s *AccessTokenService) Create(ctx context.Context, userID int64, req *CreateAccessTokenRequest, createdBy int64, authenticatedAt *time.Time) (*AccessTokenCredentials, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.IsActive == nil || !*user.IsActive {
+		return nil, ErrUserInactive
+	}
+
+	if _, err := s.systemRepo.FindByID(ctx, req.SystemID); err != nil {
+		return nil, ErrSystemNotFound
+	}
+
+	expiresAt := time.Now().Add(s.config.AccessToken.DefaultExpiry)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if !expiresAt.After(time.Now()) || expiresAt.After(time.Now().Add(s.config.AccessToken.MaxExpiry)) {
+		return nil, ErrAccessTokenExpiry
+	}
+
+	permissions, err := s.grantablePermissions(ctx, userID, req.SystemID, req.Permissions, authenticatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := randomURLToken(32)
+	if err != nil {
+		return nil, err
+	}
+	raw := domain.PersonalAccessTokenPrefix + secret
+
+	token := &domain.PersonalAccessToken{
+		UserID:      userID,
+		Name:        req.Name,
+		TokenPrefix: raw[:len(domain.PersonalAccessTokenPrefix)+8],
+		TokenHash:   hashAccessToken(raw),
+		SystemID:    req.SystemID,
+		Permissions: strings.Join(permissions, " "),
+		ExpiresAt:   expiresAt,
+		CreatedBy:   &createdBy,
+	}
+	if err := s.tokenRepo.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return &AccessTokenCredentials{Token: token, AccessToken: raw}, nil
+}
+
+// List returns a user's tokens
+func (s *AccessTokenService) List(ctx context.Context, userID int64) ([]domain.PersonalAccessToken, error) {
+	return s.tokenRepo.FindByUserID(ctx, userID)
+}
+
+// Revoke revokes one of a user's tokens
+func (s *AccessTokenService) Revoke(ctx context.Context, userID, tokenID int64, revokedBy int64) error {
+	if _, err := s.tokenRepo.FindByUserAndID(ctx, userID, tokenID); err != nil {
+		return ErrAccessTokenNotFound
+	}
+	_, err := s.tokenRepo.Revoke(ctx, tokenID, revokedBy)
+	return err
+}
+
+// RevokeAll revokes every token of a user
+func (s *AccessTokenService) RevokeAll(ctx context.Context, userID int64, revokedBy int64) error {
+	return s.tokenRepo.RevokeByUserID(ctx, userID, revokedBy)
+}
+
+// Authenticate resolves a presented token to the token record and its
+// active owner
+func (s *AccessTokenService) Authenticate(ctx context.Context, raw string) (*domain.PersonalAccessToken, *domain.User, error) {
+	if !strings.HasPrefix(raw, domain.PersonalAccessTokenPrefix) {
+		return nil, nil, ErrInvalidAccessToken
+	}
+
+	token, err := s.tokenRepo.FindByHash(ctx, hashAccessToken(raw))
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, nil, ErrInvalidAccessToken
+		}
+		return nil, nil, err
+	}
+	if !token.IsUsable() {
+		return nil, nil, ErrInvalidAccessToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, nil, ErrInvalidAccessToken
+		}
+		return nil, nil, err
+	}
+	if user.IsActive == nil || !*user.IsActive {
+		return nil, nil, ErrInvalidAccessToken
+	}
+
+	token.System, err = s.systemRepo.FindByID(ctx, token.SystemID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return token, user, nil
+}
+
+// TouchLastUsed records that a token was used from ipAddress
+func (s *AccessTokenService) TouchLastUsed(ctx context.Context, token *domain.PersonalAccessToken, ipAddress string) {
+	if err := s.tokenRepo.TouchLastUsed(ctx, token.ID, ipAddress, accessTokenTouchInterval); err != nil {
+		log.Printf("Warning: failed to record use of access token %d: %v", token.ID, err)
+	}
+}
+
+// grantablePermissions checks requested codes against the user's current
+// permissions in the system and the creating session's recent
+// authentication, and returns them deduplicated
+func (s *AccessTokenService) grantablePermissions(ctx context.Context, userID int64, systemID int, requested []string, authenticatedAt *time.Time) ([]string, error) {
+	held, err := s.permissionRepo.FindUserPermissions(ctx, userID, &systemID)
+	if err != nil {
+		return nil, err
+	}
+	holds := make(map[string]bool, len(held))
+	recentAuth := make(map[string]time.Duration, len(held))
+	for _, p := range held {
+		holds[p.Code] = true
+		recentAuth[p.Code] = time.Duration(p.RecentAuthMinutes) * time.Minute
+	}
+
+	var codes []string
+	seen := make(map[string]bool, len(requested))
+	for _, code := range requested {
+		if seen[code] {
+			continue
+		}
+		if !holds[code] || code == ImpersonatePermission {
+			return nil, ErrAccessTokenPermission
+		}
+		if window := recentAuth[code]; window > 0 && (authenticatedAt == nil || time.Since(*authenticatedAt) > window) {
+			return nil, &StepUpRequiredError{Permission: code, Window: window}
+		}
+		seen[code] = true
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func hashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
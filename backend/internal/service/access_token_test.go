@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+)
+
+// newAccessTokenTestService has user 1 hold admin.user.view and
+// admin.user.delete, which asks for authentication within 15 minutes, in
+// system 1
+func newAccessTokenTestService(t *testing.T) *AccessTokenService {
+	t.Helper()
+
+	db := newTestDB(t,
+		&domain.User{}, &domain.System{}, &domain.Role{}, &domain.Permission{},
+		&domain.RolePermission{}, &domain.UserSystemRole{}, &domain.PersonalAccessToken{},
+	)
+	systemID := 1
+	for _, record := range []interface{}{
+		&domain.User{ID: 1, Email: "alice@example.com", IsActive: domain.Ptr(true)},
+		&domain.System{ID: 1, Code: "admin", Name: "Admin"},
+		&domain.Role{ID: 1, Code: "admin", SystemID: &systemID},
+		&domain.Permission{ID: 1, Code: "admin.user.view", SystemID: &systemID, IsActive: domain.Ptr(true)},
+		&domain.Permission{ID: 2, Code: "admin.user.delete", SystemID: &systemID, IsActive: domain.Ptr(true), RecentAuthMinutes: 15},
+		&domain.RolePermission{RoleID: 1, PermissionID: 1},
+		&domain.RolePermission{RoleID: 1, PermissionID: 2},
+		&domain.UserSystemRole{UserID: 1, SystemID: &systemID, RoleID: 1},
+	} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("create %T: %v", record, err)
+		}
+	}
+
+	cfg := &config.Config{AccessToken: config.AccessTokenConfig{DefaultExpiry: time.Hour, MaxExpiry: 24 * time.Hour}}
+	return NewAccessTokenService(cfg,
+		repository.NewPersonalAccessTokenRepository(db),
+		repository.NewUserRepository(db),
+		repository.NewSystemRepository(db),
+		repository.NewPermissionRepository(db),
+	)
+}
+
+func TestAccessTokenCreateRequiresStepUp(t *testing.T) {
+	recent := time.Now().Add(-time.Minute)
+	stale := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name            string
+		permissions     []string
+		authenticatedAt *time.Time
+		wantStepUp      bool
+	}{
+		{"stale session", []string{"admin.user.view", "admin.user.delete"}, &stale, true},
+		{"never authenticated", []string{"admin.user.delete"}, nil, true},
+		{"recent session", []string{"admin.user.delete"}, &recent, false},
+		{"no step-up permission", []string{"admin.user.view"}, &stale, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newAccessTokenTestService(t)
+			req := &CreateAccessTokenRequest{Name: "ci", SystemID: 1, Permissions: tt.permissions}
+
+			credentials, err := s.Create(context.Background(), 1, req, 1, tt.authenticatedAt)
+			if !tt.wantStepUp {
+				if err != nil {
+					t.Fatalf("Create: %v", err)
+				}
+				if credentials.AccessToken == "" {
+					t.Fatal("Create returned no token")
+				}
+				return
+			}
+
+			var stepUp *StepUpRequiredError
+			if !errors.As(err, &stepUp) || !errors.Is(err, ErrStepUpRequired) {
+				t.Fatalf("Create error = %v, want StepUpRequiredError", err)
+			}
+			if stepUp.Permission != "admin.user.delete" || stepUp.Window != 15*time.Minute {
+				t.Fatalf("step-up for %s within %v, want admin.user.delete within 15m", stepUp.Permission, stepUp.Window)
+			}
+			if tokens, _ := s.List(context.Background(), 1); len(tokens) != 0 {
+				t.Fatalf("%d tokens were created", len(tokens))
+			}
+		})
+	}
+}
+
+func TestAccessTokenCreateRejectsPermissionsNotHeld(t *testing.T) {
+	tooLate := time.Now().Add(48 * time.Hour)
+	tests := []struct {
+		name    string
+		req     CreateAccessTokenRequest
+		wantErr error
+	}{
+		{"permission not held", CreateAccessTokenRequest{Permissions: []string{"admin.user.view", "admin.role.update"}}, ErrAccessTokenPermission},
+		{"beyond the maximum lifetime", CreateAccessTokenRequest{Permissions: []string{"admin.user.view"}, ExpiresAt: &tooLate}, ErrAccessTokenExpiry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newAccessTokenTestService(t)
+			tt.req.Name, tt.req.SystemID = "ci", 1
+			if _, err := s.Create(context.Background(), 1, &tt.req, 1, nil); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAccessTokenAuthenticate(t *testing.T) {
+	s := newAccessTokenTestService(t)
+	ctx := context.Background()
+	create := func() *AccessTokenCredentials {
+		credentials, err := s.Create(ctx, 1, &CreateAccessTokenRequest{Name: "ci", SystemID: 1, Permissions: []string{"admin.user.view"}}, 1, nil)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		return credentials
+	}
+
+	credentials := create()
+	token, user, err := s.Authenticate(ctx, credentials.AccessToken)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if token.ID != credentials.Token.ID || user.ID != 1 || token.Permissions != "admin.user.view" || token.System == nil {
+		t.Fatalf("Authenticate = token %d with %q for user %d, want token %d with admin.user.view for user 1",
+			token.ID, token.Permissions, user.ID, credentials.Token.ID)
+	}
+	if _, _, err := s.Authenticate(ctx, credentials.AccessToken+"x"); !errors.Is(err, ErrInvalidAccessToken) {
+		t.Fatalf("Authenticate with an altered token error = %v, want ErrInvalidAccessToken", err)
+	}
+
+	if err := s.Revoke(ctx, 1, credentials.Token.ID, 1); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, _, err := s.Authenticate(ctx, credentials.AccessToken); !errors.Is(err, ErrInvalidAccessToken) {
+		t.Fatalf("Authenticate with a revoked token error = %v, want ErrInvalidAccessToken", err)
+	}
+
+	// Tokens stop working with their owner
+	credentials = create()
+	if err := s.userRepo.DB.Model(&domain.User{}).Where("id = ?", 1).Update("is_active", false).Error; err != nil {
+		t.Fatalf("deactivate user: %v", err)
+	}
+	if _, _, err := s.Authenticate(ctx, credentials.AccessToken); !errors.Is(err, ErrInvalidAccessToken) {
+		t.Fatalf("Authenticate for an inactive owner error = %v, want ErrInvalidAccessToken", err)
+	}
+}
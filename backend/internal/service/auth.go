@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"time"
 
 	"gebase/internal/auth"
 	"gebase/internal/domain"
@@ -21,18 +22,27 @@ var (
 	ErrSessionExpired     = errors.New("session has expired")
 	ErrSystemNotFound     = errors.New("system not found")
 	ErrNoSystemAccess     = errors.New("user does not have access to this system")
+	ErrMFACodeRequired    = errors.New("mfa code is required")
 )
 
 type AuthService struct {
-	userRepo          *repository.UserRepository
-	deviceRepo        *repository.DeviceRepository
-	systemRepo        *repository.SystemRepository
+	userRepo           *repository.UserRepository
+	deviceRepo         *repository.DeviceRepository
+	systemRepo         *repository.SystemRepository
 	userSystemRoleRepo *repository.UserSystemRoleRepository
-	roleRepo          *repository.RoleRepository
-	permissionRepo    *repository.PermissionRepository
-	menuRepo          *repository.MenuRepository
-	jwtService        *auth.JWTService
-	sessionService    *auth.SessionService
+	roleRepo           *repository.RoleRepository
+	permissionRepo     *repository.PermissionRepository
+	menuRepo           *repository.MenuRepository
+	jwtService         *auth.JWTService
+	sessionService     *auth.SessionService
+	revocationService  *auth.RevocationService
+	mfaService         *MFAService
+	loginProtection    *LoginProtectionService
+	passwordPolicy     *PasswordPolicyService
+	authenticators     *Authenticators
+	riskService        *LoginRiskService
+	proofVerifier      *auth.DeviceProofVerifier
+	deviceEnrollment   *DeviceEnrollmentService
 }
 
 func NewAuthService(
@@ -45,17 +55,33 @@ func NewAuthService(
 	menuRepo *repository.MenuRepository,
 	jwtService *auth.JWTService,
 	sessionService *auth.SessionService,
+	revocationService *auth.RevocationService,
+	mfaService *MFAService,
+	loginProtection *LoginProtectionService,
+	passwordPolicy *PasswordPolicyService,
+	authenticators *Authenticators,
+	riskService *LoginRiskService,
+	proofVerifier *auth.DeviceProofVerifier,
+	deviceEnrollment *DeviceEnrollmentService,
 ) *AuthService {
 	return &AuthService{
-		userRepo:          userRepo,
-		deviceRepo:        deviceRepo,
-		systemRepo:        systemRepo,
+		userRepo:           userRepo,
+		deviceRepo:         deviceRepo,
+		systemRepo:         systemRepo,
 		userSystemRoleRepo: userSystemRoleRepo,
-		roleRepo:          roleRepo,
-		permissionRepo:    permissionRepo,
-		menuRepo:          menuRepo,
-		jwtService:        jwtService,
-		sessionService:    sessionService,
+		roleRepo:           roleRepo,
+		permissionRepo:     permissionRepo,
+		menuRepo:           menuRepo,
+		jwtService:         jwtService,
+		sessionService:     sessionService,
+		revocationService:  revocationService,
+		mfaService:         mfaService,
+		loginProtection:    loginProtection,
+		passwordPolicy:     passwordPolicy,
+		authenticators:     authenticators,
+		riskService:        riskService,
+		proofVerifier:      proofVerifier,
+		deviceEnrollment:   deviceEnrollment,
 	}
 }
 
@@ -66,30 +92,98 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	AccessToken  string          `json:"access_token"`
-	RefreshToken string          `json:"refresh_token"`
-	TokenType    string          `json:"token_type"`
-	ExpiresIn    int64           `json:"expires_in"`
-	User         *domain.User    `json:"user"`
-	Systems      []domain.System `json:"available_systems"`
+	AccessToken   string          `json:"access_token,omitempty"`
+	RefreshToken  string          `json:"refresh_token,omitempty"`
+	TokenType     string          `json:"token_type,omitempty"`
+	ExpiresIn     int64           `json:"expires_in,omitempty"`
+	User          *domain.User    `json:"user,omitempty"`
+	Systems       []domain.System `json:"available_systems,omitempty"`
+	MFAChallenge  *MFAChallenge   `json:"mfa_challenge,omitempty"`
+	RecoveryCodes []string        `json:"recovery_codes,omitempty"`
+	// PasswordChangeRequired means the tokens only allow changing the password
+	PasswordChangeRequired bool `json:"password_change_required,omitempty"`
+}
+
+// MFAChallenge is returned instead of tokens when a second factor is needed.
+// When EnrollmentRequired is set the user must enroll before logging in.
+// RiskStepUp means the second factor is asked for because the login is
+// unusual for the account.
+type MFAChallenge struct {
+	MFAToken           string `json:"mfa_token"`
+	ExpiresIn          int64  `json:"expires_in"`
+	EnrollmentRequired bool   `json:"enrollment_required"`
+	RiskStepUp         bool   `json:"risk_step_up,omitempty"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
 }
 
 // Login authenticates user and returns platform token
 func (s *AuthService) Login(ctx context.Context, req *LoginRequest, ipAddress, userAgent string) (*LoginResponse, error) {
+	attempt := LoginAttemptInfo{
+		Email:     req.Email,
+		IPAddress: ipAddress,
+		DeviceUID: req.DeviceUID,
+		UserAgent: userAgent,
+	}
+
 	// Find user by email
 	user, err := s.userRepo.FindByEmail(ctx, req.Email)
-	if err != nil {
-		return nil, ErrInvalidCredentials
+	var userID *int64
+	if err == nil {
+		userID = &user.ID
+	}
+
+	// Reject locked accounts and addresses before checking the password
+	if err := s.loginProtection.Check(ctx, userID, ipAddress); err != nil {
+		var blocked *LoginBlockedError
+		if errors.As(err, &blocked) {
+			_ = s.loginProtection.RecordBlocked(ctx, userID, attempt)
+		}
+		return nil, err
+	}
+
+	if userID == nil {
+		return nil, s.loginFailed(ctx, nil, attempt, domain.LoginFailureUnknownUser, ErrInvalidCredentials)
 	}
 
 	// Check if user is active
 	if user.IsActive == nil || !*user.IsActive {
-		return nil, ErrUserInactive
+		return nil, s.loginFailed(ctx, userID, attempt, domain.LoginFailureUserInactive, ErrUserInactive)
+	}
+
+	// Service accounts authenticate with access tokens only
+	if user.IsServiceAccount() {
+		return nil, s.loginFailed(ctx, userID, attempt, domain.LoginFailureServiceAccount, ErrInvalidCredentials)
+	}
+
+	// Verify password against the organization's authentication backend
+	authenticator, err := s.authenticators.For(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if err := authenticator.Authenticate(ctx, user, req.Password); err != nil {
+		if err == ErrInvalidCredentials {
+			return nil, s.loginFailed(ctx, userID, attempt, domain.LoginFailureInvalidPassword, err)
+		}
+		return nil, err
 	}
 
-	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		return nil, ErrInvalidCredentials
+	// An expired password must be changed before the tokens can be used.
+	// Directory passwords expire under the directory's own policy.
+	if authenticator.IsLocal() && (user.MustChangePassword == nil || !*user.MustChangePassword) {
+		expired, err := s.passwordPolicy.IsExpired(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		if expired {
+			if err := s.userRepo.SetMustChangePassword(ctx, user.ID, true); err != nil {
+				return nil, err
+			}
+			user.MustChangePassword = domain.Ptr(true)
+		}
 	}
 
 	// Verify device
@@ -98,8 +192,185 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest, ipAddress, u
 		return nil, ErrDeviceNotFound
 	}
 
-	if device.IsActive == nil || !*device.IsActive {
-		return nil, ErrDeviceNotActive
+	if err := checkDeviceUsable(device); err != nil {
+		return nil, err
+	}
+	if err := s.deviceEnrollment.AdmitUser(ctx, device, user); err != nil {
+		return nil, err
+	}
+
+	// Require a second factor when enrolled or mandated by organization policy
+	mfaEnabled, err := s.mfaService.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	mfaRequired, err := s.mfaService.IsRequired(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unusual logins need a second factor or are rejected
+	risk, err := s.riskService.Evaluate(ctx, user, device, ipAddress, userAgent, mfaEnabled)
+	if err != nil {
+		return nil, err
+	}
+	if risk.Action == domain.LoginRiskBlock {
+		return nil, ErrLoginRiskBlocked
+	}
+	stepUp := risk.Action == domain.LoginRiskStepUp
+
+	if mfaEnabled || mfaRequired || stepUp {
+		mfaToken, claims, err := s.jwtService.GenerateMFAChallengeToken(user, device)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResponse{
+			MFAChallenge: &MFAChallenge{
+				MFAToken:           mfaToken,
+				ExpiresIn:          int64(time.Until(claims.ExpiresAt.Time).Seconds()),
+				EnrollmentRequired: !mfaEnabled,
+				RiskStepUp:         stepUp,
+			},
+		}, nil
+	}
+
+	return s.completeLogin(ctx, user, device, ipAddress, userAgent)
+}
+
+// CompleteMFALogin finishes a login started with Login using a TOTP or
+// recovery code. For users who had to enroll it confirms the enrollment and
+// returns their recovery codes with the tokens.
+func (s *AuthService) CompleteMFALogin(ctx context.Context, req *MFALoginRequest, ipAddress, userAgent string) (*LoginResponse, error) {
+	claims, err := s.validateMFAChallenge(ctx, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.IsActive == nil || !*user.IsActive {
+		return nil, ErrUserInactive
+	}
+
+	attempt := LoginAttemptInfo{
+		Email:     user.Email,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+
+	// Second factor guesses are throttled like passwords
+	if err := s.loginProtection.Check(ctx, &user.ID, ipAddress); err != nil {
+		var blocked *LoginBlockedError
+		if errors.As(err, &blocked) {
+			_ = s.loginProtection.RecordBlocked(ctx, &user.ID, attempt)
+		}
+		return nil, err
+	}
+
+	mfaEnabled, err := s.mfaService.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	if mfaEnabled {
+		err = s.mfaService.Verify(ctx, user.ID, req.Code)
+	} else {
+		recoveryCodes, err = s.mfaService.ConfirmEnrollment(ctx, user.ID, req.Code)
+	}
+	if err == ErrInvalidMFACode {
+		err = s.loginFailed(ctx, &user.ID, attempt, domain.LoginFailureInvalidMFACode, err)
+		if expireErr := s.expireMFAChallenge(ctx, claims); expireErr != nil {
+			return nil, expireErr
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The challenge is single use
+	if err := s.revocationService.RevokeClaims(ctx, claims, auth.RevokeReasonMFACompleted); err != nil {
+		return nil, err
+	}
+
+	device, err := s.deviceRepo.FindByID(ctx, claims.DeviceID)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	if err := checkDeviceUsable(device); err != nil {
+		return nil, err
+	}
+	if err := s.deviceEnrollment.AdmitUser(ctx, device, user); err != nil {
+		return nil, err
+	}
+
+	result, err := s.completeLogin(ctx, user, device, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	result.RecoveryCodes = recoveryCodes
+	return result, nil
+}
+
+// BeginMFALoginEnrollment starts TOTP enrollment for a user whose organization
+// requires MFA but who has not enrolled yet
+func (s *AuthService) BeginMFALoginEnrollment(ctx context.Context, mfaToken string) (*MFAEnrollment, error) {
+	claims, err := s.validateMFAChallenge(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.mfaService.BeginEnrollment(ctx, claims.UserID)
+}
+
+func (s *AuthService) validateMFAChallenge(ctx context.Context, mfaToken string) (*auth.Claims, error) {
+	claims, err := s.jwtService.ValidateToken(mfaToken)
+	if err != nil || !claims.IsMFAChallengeToken() {
+		return nil, ErrInvalidMFAToken
+	}
+
+	revoked, err := s.revocationService.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidMFAToken
+	}
+	return claims, nil
+}
+
+// expireMFAChallenge revokes a login challenge once it has taken its share of
+// wrong codes, so that further guesses need the password again
+func (s *AuthService) expireMFAChallenge(ctx context.Context, claims *auth.Claims) error {
+	exhausted, err := s.loginProtection.ChallengeExhausted(ctx, claims.UserID, claims.IssuedAt.Time)
+	if err != nil || !exhausted {
+		return err
+	}
+	return s.revocationService.RevokeClaims(ctx, claims, auth.RevokeReasonMFAExhausted)
+}
+
+// loginFailed records a failed attempt and returns the lockout it triggered, or cause
+func (s *AuthService) loginFailed(ctx context.Context, userID *int64, attempt LoginAttemptInfo, reason string, cause error) error {
+	if err := s.loginProtection.RecordFailure(ctx, userID, attempt, reason); err != nil {
+		return err
+	}
+	return cause
+}
+
+// completeLogin creates the session and issues platform and refresh tokens.
+// Callers have verified every factor the login needs, so it also resets the
+// account's failure count.
+func (s *AuthService) completeLogin(ctx context.Context, user *domain.User, device *domain.Device, ipAddress, userAgent string) (*LoginResponse, error) {
+	attempt := LoginAttemptInfo{
+		Email:     user.Email,
+		IPAddress: ipAddress,
+		DeviceUID: device.DeviceUID,
+		UserAgent: userAgent,
+	}
+	if err := s.loginProtection.RecordSuccess(ctx, user.ID, attempt); err != nil {
+		return nil, err
 	}
 
 	// Create session
@@ -115,7 +386,7 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest, ipAddress, u
 	}
 
 	// Generate refresh token
-	refreshToken, err := s.jwtService.GenerateRefreshToken(user, session)
+	refreshToken, err := s.issueRefreshToken(ctx, user, session, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -132,12 +403,13 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest, ipAddress, u
 	systems := userWithRoles.GetAvailableSystems()
 
 	return &LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    86400, // 24 hours in seconds
-		User:         user,
-		Systems:      systems,
+		AccessToken:            accessToken,
+		RefreshToken:           refreshToken,
+		TokenType:              "Bearer",
+		ExpiresIn:              86400, // 24 hours in seconds
+		User:                   user,
+		Systems:                systems,
+		PasswordChangeRequired: user.MustChangePassword != nil && *user.MustChangePassword,
 	}, nil
 }
 
@@ -146,14 +418,14 @@ type SwitchSystemRequest struct {
 }
 
 type SwitchSystemResponse struct {
-	SystemToken         string         `json:"system_token"`
-	TokenType           string         `json:"token_type"`
-	ExpiresIn           int64          `json:"expires_in"`
-	CurrentSystem       *domain.System `json:"current_system"`
-	CurrentRole         *domain.Role   `json:"current_role"`
+	SystemToken         string               `json:"system_token"`
+	TokenType           string               `json:"token_type"`
+	ExpiresIn           int64                `json:"expires_in"`
+	CurrentSystem       *domain.System       `json:"current_system"`
+	CurrentRole         *domain.Role         `json:"current_role"`
 	CurrentOrganization *domain.Organization `json:"current_organization,omitempty"`
-	Permissions         []string       `json:"permissions"`
-	Menus               []domain.Menu  `json:"menus"`
+	Permissions         []string             `json:"permissions"`
+	Menus               []domain.Menu        `json:"menus"`
 }
 
 // SwitchSystem switches to a specific system and returns system token
@@ -194,17 +466,22 @@ func (s *AuthService) SwitchSystem(ctx context.Context, claims *auth.Claims, req
 		return nil, ErrSessionNotFound
 	}
 
-	// Switch system in session
-	if err := s.sessionService.SwitchSystem(ctx, session.ID, system.ID, ipAddress); err != nil {
+	// Revoke the system token previously issued for this session
+	if err := s.revocationService.RevokeSessionSystemToken(ctx, session, auth.RevokeReasonSystemSwitch); err != nil {
 		return nil, err
 	}
 
 	// Generate system token
-	accessToken, err := s.jwtService.GenerateSystemToken(user, session, system, roleIDs)
+	accessToken, tokenClaims, err := s.jwtService.GenerateSystemToken(user, session, system, roleIDs)
 	if err != nil {
 		return nil, err
 	}
 
+	// Switch system in session
+	if err := s.sessionService.SwitchSystem(ctx, session.ID, system.ID, tokenClaims, ipAddress); err != nil {
+		return nil, err
+	}
+
 	// Get user permissions for this system
 	permissions, err := s.permissionRepo.FindUserPermissions(ctx, claims.UserID, &system.ID)
 	if err != nil {
@@ -237,13 +514,31 @@ func (s *AuthService) SwitchSystem(ctx context.Context, claims *auth.Claims, req
 	}, nil
 }
 
-// RefreshToken refreshes the access token
-func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*LoginResponse, error) {
+// RefreshToken refreshes the access token. Devices with a key must sign the
+// request, so a refresh token is useless away from its device.
+func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string, proof *auth.DeviceProofRequest) (*LoginResponse, error) {
 	// Validate refresh token
 	claims, err := s.jwtService.ValidateToken(refreshToken)
 	if err != nil {
 		return nil, err
 	}
+	// OAuth refresh tokens can only be redeemed by their client at /oauth/token
+	if !claims.IsRefreshToken() || claims.ClientID != "" {
+		return nil, auth.ErrInvalidToken
+	}
+
+	device, err := s.deviceRepo.FindByID(ctx, claims.DeviceID)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	if claims.IsDeviceBound() {
+		err = s.proofVerifier.VerifyBinding(ctx, device, claims, proof)
+	} else {
+		err = s.proofVerifier.VerifyRequest(ctx, device, claims, proof)
+	}
+	if err != nil {
+		return nil, err
+	}
 
 	// Get session
 	session, err := s.sessionService.GetSessionByID(ctx, claims.SessionID)
@@ -251,11 +546,20 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*L
 		return nil, ErrSessionNotFound
 	}
 
-	// Check session validity
-	if !s.sessionService.IsSessionValid(ctx, session) {
+	// Check session validity; idle sessions are reported separately
+	if err := s.sessionService.ValidateSession(ctx, session); err != nil {
+		if err == auth.ErrSessionIdleTimeout {
+			return nil, err
+		}
 		return nil, ErrSessionExpired
 	}
 
+	// Consume the presented token; a replayed token revokes the session
+	previous, err := s.sessionService.RotateRefreshToken(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get user
 	user, err := s.userRepo.FindByID(ctx, claims.UserID)
 	if err != nil {
@@ -268,7 +572,7 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*L
 		return nil, err
 	}
 
-	newRefreshToken, err := s.jwtService.GenerateRefreshToken(user, session)
+	newRefreshToken, err := s.issueRefreshToken(ctx, user, session, &previous.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -282,15 +586,122 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*L
 	systems := userWithRoles.GetAvailableSystems()
 
 	return &LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: newRefreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    86400,
-		User:         user,
-		Systems:      systems,
+		AccessToken:            accessToken,
+		RefreshToken:           newRefreshToken,
+		TokenType:              "Bearer",
+		ExpiresIn:              86400,
+		User:                   user,
+		Systems:                systems,
+		PasswordChangeRequired: user.MustChangePassword != nil && *user.MustChangePassword,
 	}, nil
 }
 
+// ExitSystem returns the session to platform context and revokes its system token
+func (s *AuthService) ExitSystem(ctx context.Context, claims *auth.Claims) error {
+	session, err := s.sessionService.GetSessionByID(ctx, claims.SessionID)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+
+	if err := s.revocationService.RevokeSessionSystemToken(ctx, session, auth.RevokeReasonExitSystem); err != nil {
+		return err
+	}
+
+	// The presented token may be an older system token no longer bound to the session
+	if claims.IsSystemToken() {
+		if err := s.revocationService.RevokeClaims(ctx, claims, auth.RevokeReasonExitSystem); err != nil {
+			return err
+		}
+	}
+
+	return s.sessionService.ExitSystem(ctx, session.ID)
+}
+
+// issueRefreshToken generates a refresh token and records it in the session's rotation family
+func (s *AuthService) issueRefreshToken(ctx context.Context, user *domain.User, session *domain.Session, parentID *int64) (string, error) {
+	token, claims, err := s.jwtService.GenerateRefreshToken(user, session)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := s.sessionService.IssueRefreshToken(ctx, claims, parentID); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+type ReauthenticateRequest struct {
+	Password string `json:"password" binding:"required"`
+	// Code is a TOTP or recovery code, required when MFA is enabled
+	Code string `json:"code"`
+}
+
+type ReauthenticateResponse struct {
+	AuthenticatedAt time.Time `json:"authenticated_at"`
+}
+
+// Reauthenticate checks the credentials of a signed in user again and stamps
+// the session, unlocking permissions that require recent authentication.
+// Failures count towards the account lockout like failed logins.
+func (s *AuthService) Reauthenticate(ctx context.Context, userID, sessionID int64, req *ReauthenticateRequest, ipAddress, userAgent string) (*ReauthenticateResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.IsActive == nil || !*user.IsActive {
+		return nil, ErrUserInactive
+	}
+
+	attempt := LoginAttemptInfo{
+		Email:     user.Email,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+
+	if err := s.loginProtection.Check(ctx, &user.ID, ipAddress); err != nil {
+		var blocked *LoginBlockedError
+		if errors.As(err, &blocked) {
+			_ = s.loginProtection.RecordBlocked(ctx, &user.ID, attempt)
+		}
+		return nil, err
+	}
+
+	authenticator, err := s.authenticators.For(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if err := authenticator.Authenticate(ctx, user, req.Password); err != nil {
+		if err == ErrInvalidCredentials {
+			return nil, s.loginFailed(ctx, &user.ID, attempt, domain.LoginFailureInvalidPassword, err)
+		}
+		return nil, err
+	}
+
+	// Enrolled users confirm their second factor too
+	mfaEnabled, err := s.mfaService.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled {
+		if req.Code == "" {
+			return nil, ErrMFACodeRequired
+		}
+		if err := s.mfaService.Verify(ctx, user.ID, req.Code); err != nil {
+			if err == ErrInvalidMFACode {
+				return nil, s.loginFailed(ctx, &user.ID, attempt, domain.LoginFailureInvalidMFACode, err)
+			}
+			return nil, err
+		}
+	}
+
+	authenticatedAt, err := s.sessionService.MarkAuthenticated(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &ReauthenticateResponse{AuthenticatedAt: authenticatedAt}, nil
+}
+
 // Logout terminates the current session
 func (s *AuthService) Logout(ctx context.Context, sessionID int64) error {
 	return s.sessionService.Logout(ctx, sessionID, "user")
@@ -304,6 +715,53 @@ func (s *AuthService) RemoteLogout(ctx context.Context, userID int64, deviceID *
 	return s.sessionService.LogoutUser(ctx, userID, reason)
 }
 
+// SessionInfo is an active session as listed to its user or an administrator
+type SessionInfo struct {
+	domain.Session
+	// IsCurrent marks the session the request was made with
+	IsCurrent bool `json:"is_current"`
+}
+
+// ListSessions returns the active sessions of a user with their device,
+// current system and last activity. currentSessionID is flagged as current.
+func (s *AuthService) ListSessions(ctx context.Context, userID, currentSessionID int64) ([]SessionInfo, error) {
+	sessions, err := s.sessionService.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]SessionInfo, len(sessions))
+	for i, session := range sessions {
+		result[i] = SessionInfo{
+			Session:   session,
+			IsCurrent: session.ID == currentSessionID,
+		}
+	}
+	return result, nil
+}
+
+// RevokeSession terminates one session of a user
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID int64, reason string) error {
+	session, err := s.sessionService.GetSessionByID(ctx, sessionID)
+	if err != nil || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	if session.IsActive == nil || !*session.IsActive {
+		return nil
+	}
+	return s.sessionService.Logout(ctx, sessionID, reason)
+}
+
+// RevokeOtherSessions terminates all sessions of a user except the current one
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, userID, currentSessionID int64) error {
+	return s.sessionService.LogoutOtherSessions(ctx, userID, currentSessionID, auth.LogoutReasonRevokedByUser)
+}
+
+// ListActiveSessions returns all active sessions with pagination
+func (s *AuthService) ListActiveSessions(ctx context.Context, page, pageSize int) (*repository.PaginatedResult[domain.Session], error) {
+	return s.sessionService.GetActiveSessions(ctx, page, pageSize)
+}
+
 // ValidateToken validates a token and returns claims
 func (s *AuthService) ValidateToken(ctx context.Context, token string) (*auth.Claims, error) {
 	return s.jwtService.ValidateToken(token)
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gebase/internal/auth"
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const testPassword = "correct horse battery"
+
+type authTestEnv struct {
+	db      *gorm.DB
+	service *AuthService
+	jwt     *auth.JWTService
+	secret  string
+}
+
+// newAuthTestEnv wires password logins for alice@example.com, who has
+// enrolled TOTP, on device "device-1". Lockouts take 5 failures and a login
+// challenge 3 wrong codes; progressive delays are off.
+func newAuthTestEnv(t *testing.T) *authTestEnv {
+	t.Helper()
+
+	db := newTestDB(t,
+		&domain.User{}, &domain.Device{}, &domain.Session{}, &domain.RefreshToken{}, &domain.SessionLimitPolicy{},
+		&domain.LoginAttempt{}, &domain.LoginLockout{}, &domain.RevokedToken{},
+		&domain.UserMFA{}, &domain.MFARecoveryCode{}, &domain.OrganizationSecurityPolicy{},
+		&domain.System{}, &domain.Role{}, &domain.UserSystemRole{}, &domain.DeviceEnrollmentPolicy{},
+		&domain.DeviceProofNonce{}, &domain.SessionTimeoutPolicy{},
+	)
+
+	cfg := &config.Config{
+		JWT: config.JWTConfig{
+			Secret:              "test-secret",
+			SigningAlgorithm:    string(domain.SigningAlgorithmHS256),
+			PlatformExpiry:      time.Hour,
+			RefreshExpiry:       24 * time.Hour,
+			RevocationCacheSize: 100,
+		},
+		MFA: config.MFAConfig{ChallengeExpiry: 5 * time.Minute, ChallengeMaxAttempts: 3},
+		Login: config.LoginConfig{
+			MaxAccountFailures: 5,
+			MaxIPFailures:      100,
+			FailureWindow:      15 * time.Minute,
+			LockoutDuration:    15 * time.Minute,
+			MaxLockoutDuration: 24 * time.Hour,
+			FreeAttempts:       100,
+		},
+	}
+
+	userRepo := repository.NewUserRepository(db)
+	deviceRepo := repository.NewDeviceRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	orgPolicyRepo := repository.NewOrganizationSecurityPolicyRepository(db)
+	secretBox := auth.NewSecretBox(cfg)
+	jwtService := auth.NewJWTService(cfg, auth.NewKeyRing(cfg, nil, secretBox))
+	sessionService := auth.NewSessionService(cfg, sessionRepo, nil,
+		repository.NewRefreshTokenRepository(db), deviceRepo, repository.NewUserSystemRoleRepository(db),
+		repository.NewSessionLimitPolicyRepository(db), repository.NewSessionTimeoutPolicyRepository(db))
+	revocationService := auth.NewRevocationService(cfg, repository.NewRevokedTokenRepository(db), sessionRepo, nil)
+	mfaService := NewMFAService(cfg, userRepo, repository.NewUserMFARepository(db),
+		repository.NewMFARecoveryCodeRepository(db), orgPolicyRepo, secretBox)
+	service := NewAuthService(userRepo, deviceRepo, nil, nil, nil, nil, nil,
+		jwtService, sessionService, revocationService, mfaService,
+		NewLoginProtectionService(cfg, repository.NewLoginAttemptRepository(db), repository.NewLoginLockoutRepository(db)),
+		NewPasswordPolicyService(cfg, orgPolicyRepo, nil),
+		NewAuthenticators(orgPolicyRepo, nil),
+		NewLoginRiskService(cfg, nil, nil, nil, nil, nil, nil, nil, nil),
+		auth.NewDeviceProofVerifier(cfg, repository.NewDeviceProofNonceRepository(db)),
+		NewDeviceEnrollmentService(cfg, repository.NewDeviceEnrollmentPolicyRepository(db), deviceRepo, nil, userRepo, nil, nil),
+	)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("generate secret: %v", err)
+	}
+	secretEnc, err := secretBox.Seal([]byte(secret))
+	if err != nil {
+		t.Fatalf("seal secret: %v", err)
+	}
+	confirmedAt := time.Now()
+	for _, record := range []interface{}{
+		&domain.User{ID: 1, Email: "alice@example.com", PasswordHash: string(hash), IsActive: domain.Ptr(true)},
+		&domain.UserMFA{UserID: 1, SecretEnc: secretEnc, IsEnabled: domain.Ptr(true), ConfirmedAt: &confirmedAt},
+		&domain.Device{DeviceUID: "device-1", IsActive: domain.Ptr(true)},
+	} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("create %T: %v", record, err)
+		}
+	}
+
+	return &authTestEnv{db: db, service: service, jwt: jwtService, secret: secret}
+}
+
+// login signs in with the password and returns the second-factor challenge
+func (e *authTestEnv) login(t *testing.T) string {
+	t.Helper()
+
+	resp, err := e.service.Login(context.Background(), &LoginRequest{
+		Email: "alice@example.com", Password: testPassword, DeviceUID: "device-1",
+	}, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if resp.MFAChallenge == nil {
+		t.Fatal("Login issued tokens without asking for the second factor")
+	}
+	return resp.MFAChallenge.MFAToken
+}
+
+// code returns the TOTP code of the user at step
+func (e *authTestEnv) code(t *testing.T, step int64) string {
+	t.Helper()
+
+	code, err := auth.TOTPCode(e.secret, step)
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	return code
+}
+
+// wrongCode returns a code that no step within the accepted skew matches
+func (e *authTestEnv) wrongCode(t *testing.T) string {
+	t.Helper()
+
+	current := auth.TOTPStep(time.Now())
+	valid := map[string]bool{}
+	for step := current - auth.TOTPSkew - 1; step <= current+auth.TOTPSkew+1; step++ {
+		valid[e.code(t, step)] = true
+	}
+	for _, code := range []string{"000000", "111111", "222222", "333333", "444444", "555555", "666666"} {
+		if !valid[code] {
+			return code
+		}
+	}
+	t.Fatal("no wrong code found")
+	return ""
+}
+
+func TestCompleteMFALoginExpiresChallengeAfterWrongCodes(t *testing.T) {
+	env := newAuthTestEnv(t)
+	ctx := context.Background()
+	challenge := env.login(t)
+	wrong := env.wrongCode(t)
+
+	for i := 0; i < 3; i++ {
+		_, err := env.service.CompleteMFALogin(ctx, &MFALoginRequest{MFAToken: challenge, Code: wrong}, "127.0.0.1", "test")
+		if !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("wrong code %d: error = %v, want ErrInvalidMFACode", i+1, err)
+		}
+	}
+
+	// Even the right code needs a new challenge now
+	right := env.code(t, auth.TOTPStep(time.Now()))
+	if _, err := env.service.CompleteMFALogin(ctx, &MFALoginRequest{MFAToken: challenge, Code: right}, "127.0.0.1", "test"); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("CompleteMFALogin after 3 wrong codes error = %v, want ErrInvalidMFAToken", err)
+	}
+
+	resp, err := env.service.CompleteMFALogin(ctx, &MFALoginRequest{MFAToken: env.login(t), Code: right}, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("CompleteMFALogin with a new challenge: %v", err)
+	}
+	if resp.AccessToken == "" {
+		t.Fatal("CompleteMFALogin returned no access token")
+	}
+}
+
+func TestPasswordDoesNotResetMFAFailures(t *testing.T) {
+	env := newAuthTestEnv(t)
+	ctx := context.Background()
+	wrong := env.wrongCode(t)
+
+	// A fresh challenge for every guess still adds up to a lockout
+	var err error
+	for i := 0; i < 5; i++ {
+		_, err = env.service.CompleteMFALogin(ctx, &MFALoginRequest{MFAToken: env.login(t), Code: wrong}, "127.0.0.1", "test")
+	}
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("fifth wrong code error = %v, want ErrAccountLocked", err)
+	}
+
+	_, err = env.service.Login(ctx, &LoginRequest{Email: "alice@example.com", Password: testPassword, DeviceUID: "device-1"}, "127.0.0.1", "test")
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Login error = %v, want ErrAccountLocked", err)
+	}
+}
+
+// signIn completes a login with the code of the current TOTP step
+func (e *authTestEnv) signIn(t *testing.T) *LoginResponse {
+	t.Helper()
+
+	req := &MFALoginRequest{MFAToken: e.login(t), Code: e.code(t, auth.TOTPStep(time.Now()))}
+	resp, err := e.service.CompleteMFALogin(context.Background(), req, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("CompleteMFALogin: %v", err)
+	}
+	return resp
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	env := newAuthTestEnv(t)
+	ctx := context.Background()
+	first := env.signIn(t)
+
+	second, err := env.service.RefreshToken(ctx, first.RefreshToken, nil)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatal("RefreshToken did not rotate the refresh token")
+	}
+
+	// Replaying the consumed token ends the session for both holders
+	if _, err := env.service.RefreshToken(ctx, first.RefreshToken, nil); !errors.Is(err, auth.ErrTokenReused) {
+		t.Fatalf("replayed RefreshToken error = %v, want ErrTokenReused", err)
+	}
+	if _, err := env.service.RefreshToken(ctx, second.RefreshToken, nil); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("RefreshToken after reuse error = %v, want ErrSessionExpired", err)
+	}
+
+	claims, err := env.jwt.ValidateToken(second.RefreshToken)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	var session domain.Session
+	if err := env.db.First(&session, claims.SessionID).Error; err != nil {
+		t.Fatalf("find session: %v", err)
+	}
+	if session.IsActive == nil || *session.IsActive || session.LogoutReason != auth.LogoutReasonTokenReuse {
+		t.Fatalf("session active = %v, logout reason %q, want logged out for token reuse", session.IsActive, session.LogoutReason)
+	}
+}
+
+func TestReauthenticateStampsSession(t *testing.T) {
+	env := newAuthTestEnv(t)
+	ctx := context.Background()
+	claims, err := env.jwt.ValidateToken(env.signIn(t).AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	stale := time.Now().Add(-time.Hour)
+	if err := env.db.Model(&domain.Session{}).Where("id = ?", claims.SessionID).Update("authenticated_at", stale).Error; err != nil {
+		t.Fatalf("age session: %v", err)
+	}
+	authenticatedAt := func() time.Time {
+		var session domain.Session
+		if err := env.db.First(&session, claims.SessionID).Error; err != nil {
+			t.Fatalf("find session: %v", err)
+		}
+		return *session.AuthenticatedAt
+	}
+
+	// The password alone is not enough for a user with a second factor
+	failures := []struct {
+		req     ReauthenticateRequest
+		wantErr error
+	}{
+		{ReauthenticateRequest{Password: "wrong"}, ErrInvalidCredentials},
+		{ReauthenticateRequest{Password: testPassword}, ErrMFACodeRequired},
+		{ReauthenticateRequest{Password: testPassword, Code: env.wrongCode(t)}, ErrInvalidMFACode},
+	}
+	for _, f := range failures {
+		if _, err := env.service.Reauthenticate(ctx, 1, claims.SessionID, &f.req, "127.0.0.1", "test"); !errors.Is(err, f.wantErr) {
+			t.Fatalf("Reauthenticate error = %v, want %v", err, f.wantErr)
+		}
+	}
+	if got := authenticatedAt(); time.Since(got) < 59*time.Minute {
+		t.Fatalf("failed reauthentication stamped the session at %v", got)
+	}
+
+	// The code of the login is spent; the next step's code is accepted
+	req := &ReauthenticateRequest{Password: testPassword, Code: env.code(t, auth.TOTPStep(time.Now())+1)}
+	resp, err := env.service.Reauthenticate(ctx, 1, claims.SessionID, req, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("Reauthenticate: %v", err)
+	}
+	if got := authenticatedAt(); time.Since(got) > time.Minute || time.Since(resp.AuthenticatedAt) > time.Minute {
+		t.Fatalf("session authenticated at %v, response %v, want now", got, resp.AuthenticatedAt)
+	}
+}
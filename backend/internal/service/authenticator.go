@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnknownAuthBackend = errors.New("unknown authentication backend")
+
+// Authenticator verifies the password of a user that was already found by
+// email. Implementations return ErrInvalidCredentials for a wrong password so
+// the attempt is counted by login protection; any other error aborts the login.
+type Authenticator interface {
+	Authenticate(ctx context.Context, user *domain.User, password string) error
+	// IsLocal reports whether the password is stored here, and so is subject
+	// to the local password policy, expiry and self-service changes
+	IsLocal() bool
+}
+
+// PasswordAuthenticator checks the bcrypt hash stored on the user
+type PasswordAuthenticator struct{}
+
+func (PasswordAuthenticator) Authenticate(ctx context.Context, user *domain.User, password string) error {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+func (PasswordAuthenticator) IsLocal() bool {
+	return true
+}
+
+// Authenticators selects the authenticator configured in the security policy
+// of a user's organization. Users without an organization or policy use the
+// local password.
+type Authenticators struct {
+	policyRepo *repository.OrganizationSecurityPolicyRepository
+	backends   map[string]Authenticator
+}
+
+func NewAuthenticators(
+	policyRepo *repository.OrganizationSecurityPolicyRepository,
+	ldapService *LDAPService,
+) *Authenticators {
+	return &Authenticators{
+		policyRepo: policyRepo,
+		backends: map[string]Authenticator{
+			domain.AuthBackendLocal: PasswordAuthenticator{},
+			domain.AuthBackendLDAP:  ldapService,
+		},
+	}
+}
+
+// For returns the authenticator of the user's organization
+func (a *Authenticators) For(ctx context.Context, user *domain.User) (Authenticator, error) {
+	backend := domain.AuthBackendLocal
+	if user.OrganizationID != nil {
+		policy, err := a.policyRepo.FindByOrganizationID(ctx, *user.OrganizationID)
+		if err != nil && !repository.IsNotFound(err) {
+			return nil, err
+		}
+		if policy != nil && policy.AuthBackend != "" {
+			backend = policy.AuthBackend
+		}
+	}
+
+	authenticator, ok := a.backends[backend]
+	if !ok {
+		return nil, ErrUnknownAuthBackend
+	}
+	return authenticator, nil
+}
+
+// IsLocal reports whether the user's password is managed by this platform
+func (a *Authenticators) IsLocal(ctx context.Context, user *domain.User) (bool, error) {
+	authenticator, err := a.For(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	return authenticator.IsLocal(), nil
+}
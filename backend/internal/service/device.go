@@ -2,40 +2,72 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"time"
 
+	"gebase/internal/auth"
+	"gebase/internal/config"
 	"gebase/internal/domain"
 	"gebase/internal/repository"
 )
 
 var (
-	ErrDeviceUIDExists = errors.New("device UID already exists")
+	ErrDeviceUIDExists       = errors.New("device UID already exists")
+	ErrDevicePendingApproval = errors.New("device is awaiting approval")
+	ErrDeviceRejected        = errors.New("device was rejected")
+	// ErrDeviceOrganizationMismatch is returned when a user signs in on a
+	// device of another organization
+	ErrDeviceOrganizationMismatch = errors.New("device belongs to another organization")
+	// ErrDeviceKeyPendingApproval is returned when a key was sent for a
+	// device that had none by a caller without a session on it
+	ErrDeviceKeyPendingApproval = errors.New("device key is awaiting approval")
+	ErrNoPendingDeviceKey       = errors.New("device has no key awaiting approval")
 )
 
 type DeviceService struct {
-	deviceRepo  *repository.DeviceRepository
-	sessionRepo *repository.SessionRepository
+	config            *config.Config
+	deviceRepo        *repository.DeviceRepository
+	sessionRepo       *repository.SessionRepository
+	enrollmentService *DeviceEnrollmentService
+	commandService    *DeviceCommandService
+	configService     *DeviceConfigService
+	presenceService   *DevicePresenceService
+	proofVerifier     *auth.DeviceProofVerifier
 }
 
 func NewDeviceService(
+	cfg *config.Config,
 	deviceRepo *repository.DeviceRepository,
 	sessionRepo *repository.SessionRepository,
+	enrollmentService *DeviceEnrollmentService,
+	commandService *DeviceCommandService,
+	configService *DeviceConfigService,
+	presenceService *DevicePresenceService,
+	proofVerifier *auth.DeviceProofVerifier,
 ) *DeviceService {
 	return &DeviceService{
-		deviceRepo:  deviceRepo,
-		sessionRepo: sessionRepo,
+		config:            cfg,
+		deviceRepo:        deviceRepo,
+		sessionRepo:       sessionRepo,
+		enrollmentService: enrollmentService,
+		commandService:    commandService,
+		configService:     configService,
+		presenceService:   presenceService,
+		proofVerifier:     proofVerifier,
 	}
 }
 
 type RegisterDeviceRequest struct {
-	DeviceUID      string                `json:"device_uid" binding:"required"`
-	Name           string                `json:"name" binding:"required"`
-	Platform       domain.DevicePlatform `json:"platform" binding:"required"`
-	OSVersion      string                `json:"os_version"`
-	AppVersion     string                `json:"app_version"`
-	PushToken      string                `json:"push_token"`
-	OrganizationID *int64                `json:"organization_id"`
+	DeviceUID  string                `json:"device_uid" binding:"required"`
+	Name       string                `json:"name" binding:"required"`
+	Platform   domain.DevicePlatform `json:"platform" binding:"required"`
+	OSVersion  string                `json:"os_version"`
+	AppVersion string                `json:"app_version"`
+	PushToken  string                `json:"push_token"`
+	// PublicKey is the device's proof-of-possession public JWK. The request
+	// must then carry a proof signed with its private key.
+	PublicKey json.RawMessage `json:"public_key"`
 }
 
 type UpdateDeviceRequest struct {
@@ -46,18 +78,66 @@ type UpdateDeviceRequest struct {
 	IsActive   *bool  `json:"is_active"`
 }
 
-// RegisterDevice registers a new device or returns existing one
-func (s *DeviceService) RegisterDevice(ctx context.Context, req *RegisterDeviceRequest) (*domain.Device, error) {
+// RegisterDevice registers a new device or returns existing one. New devices
+// are approved or queued for approval by the enrollment policy of the
+// organization of the session in claims; without one the device has no
+// organization until a user signs in on it. A device with
+// a key can only be updated with a proof signed by that key, and keeps it
+// until an administrator resets it. A key for an existing device without one
+// is only bound when claims are of a session on that device; otherwise it
+// waits for an administrator's approval. Likewise the platform and push token
+// of an existing device only change with a proof or a session on it.
+func (s *DeviceService) RegisterDevice(ctx context.Context, req *RegisterDeviceRequest, proof *auth.DeviceProofRequest, claims *auth.Claims) (*domain.Device, error) {
+	var key *auth.DeviceKey
+	if len(req.PublicKey) > 0 && string(req.PublicKey) != "null" {
+		parsed, err := auth.ParseDeviceKey(req.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		// The proof shows the device holds the private half of the key
+		if err := s.proofVerifier.Verify(ctx, parsed, proof); err != nil {
+			return nil, err
+		}
+		key = parsed
+	}
+
 	// Check if device already exists
 	existing, err := s.deviceRepo.FindByUID(ctx, req.DeviceUID)
 	if err == nil && existing != nil {
+		onDevice := claims != nil && claims.IsAccessToken() && claims.DeviceID == existing.ID
+		// A device with a key only gets past the checks below with a proof
+		verified := onDevice || existing.HasKey()
+		if existing.HasKey() {
+			if key == nil {
+				if err := s.proofVerifier.VerifyRequest(ctx, existing, nil, proof); err != nil {
+					return nil, err
+				}
+			} else if key.Thumbprint != existing.KeyThumbprint {
+				return nil, auth.ErrDeviceKeyMismatch
+			}
+		} else if key != nil {
+			if !onDevice {
+				// Anyone knowing the UID could send a key; leave the device as it is
+				if err := s.deviceRepo.SetPendingKey(ctx, existing.ID, key.JWK, key.Algorithm, key.Thumbprint); err != nil {
+					return nil, err
+				}
+				return nil, ErrDeviceKeyPendingApproval
+			}
+			bindDeviceKey(existing, key)
+		} else if s.config.Device.ProofRequired {
+			return nil, auth.ErrDeviceKeyRequired
+		}
+
 		// Update existing device info
 		existing.Name = req.Name
-		existing.Platform = req.Platform
 		existing.OSVersion = req.OSVersion
 		existing.AppVersion = req.AppVersion
-		if req.PushToken != "" {
-			existing.PushToken = req.PushToken
+		// Anyone knowing the UID could redirect the device's notifications
+		if verified {
+			existing.Platform = req.Platform
+			if req.PushToken != "" {
+				existing.PushToken = req.PushToken
+			}
 		}
 		now := time.Now()
 		existing.LastHeartbeat = &now
@@ -68,7 +148,15 @@ func (s *DeviceService) RegisterDevice(ctx context.Context, req *RegisterDeviceR
 		return existing, nil
 	}
 
+	if key == nil && s.config.Device.ProofRequired {
+		return nil, auth.ErrDeviceKeyRequired
+	}
+
 	// Create new device
+	var orgID *int64
+	if claims != nil && claims.IsAccessToken() {
+		orgID = claims.OrganizationID
+	}
 	device := &domain.Device{
 		DeviceUID:      req.DeviceUID,
 		Name:           req.Name,
@@ -76,33 +164,149 @@ func (s *DeviceService) RegisterDevice(ctx context.Context, req *RegisterDeviceR
 		OSVersion:      req.OSVersion,
 		AppVersion:     req.AppVersion,
 		PushToken:      req.PushToken,
-		OrganizationID: req.OrganizationID,
+		OrganizationID: orgID,
 		IsRegistered:   domain.Ptr(false),
 		IsActive:       domain.Ptr(true),
-		ConfigJSON:     "{}",
+	}
+	if key != nil {
+		bindDeviceKey(device, key)
+	}
+	if err := s.enrollmentService.Enroll(ctx, device); err != nil {
+		return nil, err
 	}
 
 	if err := s.deviceRepo.Create(ctx, device); err != nil {
 		return nil, err
 	}
 
+	if !device.IsApproved() {
+		go s.enrollmentService.NotifyPending(context.WithoutCancel(ctx), device)
+	}
+
 	return device, nil
 }
 
-// Heartbeat updates device heartbeat timestamp
+// bindDeviceKey sets the proof-of-possession key of a device
+func bindDeviceKey(device *domain.Device, key *auth.DeviceKey) {
+	device.PublicKeyJWK = key.JWK
+	device.KeyAlgorithm = key.Algorithm
+	device.KeyThumbprint = key.Thumbprint
+	device.KeyBoundAt = domain.Ptr(time.Now())
+}
+
+// Heartbeat updates device heartbeat timestamp and marks the device online
 func (s *DeviceService) Heartbeat(ctx context.Context, deviceUID string) error {
-	return s.deviceRepo.UpdateHeartbeat(ctx, deviceUID)
+	if err := s.deviceRepo.UpdateHeartbeat(ctx, deviceUID); err != nil {
+		return err
+	}
+	return s.presenceService.RecordHeartbeat(ctx, deviceUID)
+}
+
+// HeartbeatResponse is returned to a device for its heartbeat
+type HeartbeatResponse struct {
+	// Commands are queued commands the device has not acknowledged yet
+	Commands []domain.DeviceCommand `json:"commands"`
+	// ConfigVersion is the version of the device's effective config; the
+	// device fetches its config again when it differs from the one it has
+	ConfigVersion string `json:"config_version"`
+}
+
+// ReportHeartbeat records a heartbeat sent by a device itself, which must be
+// signed with the device key, and returns its open commands and config
+// version.
+// Commands reach deactivated devices too, so lost devices can be locked or
+// wiped.
+func (s *DeviceService) ReportHeartbeat(ctx context.Context, deviceUID string, proof *auth.DeviceProofRequest) (*HeartbeatResponse, error) {
+	device, err := s.deviceRepo.FindByUID(ctx, deviceUID)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	if err := s.verifyDeviceRequest(ctx, device, proof); err != nil {
+		return nil, err
+	}
+	if err := s.deviceRepo.UpdateHeartbeat(ctx, deviceUID); err != nil {
+		return nil, err
+	}
+	if err := s.presenceService.RecordHeartbeat(ctx, deviceUID); err != nil {
+		return nil, err
+	}
+
+	commands, err := s.commandService.Deliver(ctx, device.ID)
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.configService.Effective(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+	return &HeartbeatResponse{Commands: commands, ConfigVersion: config.Version}, nil
+}
+
+// FetchConfig returns the effective config of a device to the device itself,
+// which must sign the request with its key
+func (s *DeviceService) FetchConfig(ctx context.Context, deviceUID string, proof *auth.DeviceProofRequest) (*EffectiveDeviceConfig, error) {
+	device, err := s.deviceRepo.FindByUID(ctx, deviceUID)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	if err := s.verifyDeviceRequest(ctx, device, proof); err != nil {
+		return nil, err
+	}
+	return s.configService.Effective(ctx, device)
+}
+
+// AcknowledgeCommand records the result a device reports for a command it
+// received with a heartbeat, signed with the device key
+func (s *DeviceService) AcknowledgeCommand(ctx context.Context, deviceUID string, commandID int64, req *AcknowledgeDeviceCommandRequest, proof *auth.DeviceProofRequest) (*domain.DeviceCommand, error) {
+	device, err := s.deviceRepo.FindByUID(ctx, deviceUID)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	if err := s.verifyDeviceRequest(ctx, device, proof); err != nil {
+		return nil, err
+	}
+	return s.commandService.Acknowledge(ctx, device.ID, commandID, req)
+}
+
+// verifyDeviceRequest checks a request a device makes on its own, without a
+// session. Its UID is no secret, so only a proof signed with its key
+// identifies it, whatever DEVICE_PROOF_REQUIRED says; devices without a key
+// must register one first.
+func (s *DeviceService) verifyDeviceRequest(ctx context.Context, device *domain.Device, proof *auth.DeviceProofRequest) error {
+	if !device.HasKey() {
+		return auth.ErrDeviceKeyRequired
+	}
+	return s.proofVerifier.VerifyRequest(ctx, device, nil, proof)
+}
+
+// VerifyProof checks that a request from device is signed with its key and
+// that the access token of the request, if any, was issued on it
+func (s *DeviceService) VerifyProof(ctx context.Context, device *domain.Device, claims *auth.Claims, proof *auth.DeviceProofRequest) error {
+	return s.proofVerifier.VerifyRequest(ctx, device, claims, proof)
+}
+
+// VerifyTokenBinding checks a request authenticated with device-bound claims
+// carries a proof signed with the key of the device they were issued on
+func (s *DeviceService) VerifyTokenBinding(ctx context.Context, claims *auth.Claims, proof *auth.DeviceProofRequest) error {
+	device, err := s.deviceRepo.FindByID(ctx, claims.DeviceID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return auth.ErrDeviceKeyMismatch
+		}
+		return err
+	}
+	return s.proofVerifier.VerifyBinding(ctx, device, claims, proof)
 }
 
-// VerifyDevice verifies if device exists and is active
+// VerifyDevice verifies if device exists, is active and is approved
 func (s *DeviceService) VerifyDevice(ctx context.Context, deviceUID string) (*domain.Device, error) {
 	device, err := s.deviceRepo.FindByUID(ctx, deviceUID)
 	if err != nil {
 		return nil, ErrDeviceNotFound
 	}
 
-	if device.IsActive == nil || !*device.IsActive {
-		return nil, ErrDeviceNotActive
+	if err := checkDeviceUsable(device); err != nil {
+		return nil, err
 	}
 
 	return device, nil
@@ -170,11 +374,6 @@ func (s *DeviceService) UpdateDevice(ctx context.Context, id int64, req *UpdateD
 	return device, nil
 }
 
-// UpdateDeviceConfig updates device remote configuration
-func (s *DeviceService) UpdateDeviceConfig(ctx context.Context, id int64, configJSON string, updatedBy int64) error {
-	return s.deviceRepo.UpdateConfig(ctx, id, configJSON, updatedBy)
-}
-
 // DeactivateDevice deactivates a device and terminates its sessions
 func (s *DeviceService) DeactivateDevice(ctx context.Context, id int64, reason string, deactivatedBy int64) error {
 	device, err := s.deviceRepo.FindByID(ctx, id)
@@ -193,9 +392,46 @@ func (s *DeviceService) DeactivateDevice(ctx context.Context, id int64, reason s
 	return s.sessionRepo.LogoutByDeviceID(ctx, id, reason)
 }
 
-// GetOnlineDevices returns devices with recent heartbeat
-func (s *DeviceService) GetOnlineDevices(ctx context.Context, threshold time.Duration) ([]domain.Device, error) {
-	return s.deviceRepo.FindOnlineDevices(ctx, threshold)
+// ResetDeviceKey removes the key of a device so it can register a new one,
+// ending its sessions as their tokens are bound to the old key
+func (s *DeviceService) ResetDeviceKey(ctx context.Context, id int64, resetBy int64) error {
+	if _, err := s.deviceRepo.FindByID(ctx, id); err != nil {
+		return ErrDeviceNotFound
+	}
+	if err := s.deviceRepo.ClearKey(ctx, id, resetBy); err != nil {
+		return err
+	}
+	return s.sessionRepo.LogoutByDeviceID(ctx, id, auth.LogoutReasonDeviceKeyReset)
+}
+
+// ApproveDeviceKey binds the key awaiting approval to a device, ending its
+// sessions as their tokens are not bound to it
+func (s *DeviceService) ApproveDeviceKey(ctx context.Context, id int64, approvedBy int64) (*domain.Device, error) {
+	device, err := s.deviceRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	if !device.HasPendingKey() {
+		return nil, ErrNoPendingDeviceKey
+	}
+
+	device.PublicKeyJWK = device.PendingKeyJWK
+	device.KeyAlgorithm = device.PendingKeyAlgorithm
+	device.KeyThumbprint = device.PendingKeyThumbprint
+	device.KeyBoundAt = domain.Ptr(time.Now())
+	device.PendingKeyJWK = ""
+	device.PendingKeyAlgorithm = ""
+	device.PendingKeyThumbprint = ""
+	device.PendingKeyAt = nil
+	device.UpdatedBy = &approvedBy
+
+	if err := s.deviceRepo.Update(ctx, device); err != nil {
+		return nil, err
+	}
+	if err := s.sessionRepo.LogoutByDeviceID(ctx, id, auth.LogoutReasonDeviceKeyApproved); err != nil {
+		return nil, err
+	}
+	return device, nil
 }
 
 // GetDeviceSessions returns active sessions for a device
@@ -203,7 +439,16 @@ func (s *DeviceService) GetDeviceSessions(ctx context.Context, deviceID int64) (
 	return s.sessionRepo.FindByDeviceID(ctx, deviceID)
 }
 
-// ApproveDevice marks device as registered/approved
-func (s *DeviceService) ApproveDevice(ctx context.Context, deviceUID string, approvedBy int64) error {
-	return s.deviceRepo.Register(ctx, deviceUID, approvedBy)
+// checkDeviceUsable rejects devices that are deactivated or not approved
+func checkDeviceUsable(device *domain.Device) error {
+	if device.IsActive == nil || !*device.IsActive {
+		return ErrDeviceNotActive
+	}
+	switch device.ApprovalStatus {
+	case domain.DeviceApprovalPending:
+		return ErrDevicePendingApproval
+	case domain.DeviceApprovalRejected:
+		return ErrDeviceRejected
+	}
+	return nil
 }
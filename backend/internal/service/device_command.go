@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gebase/internal/auth"
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+)
+
+// maxCommandsPerHeartbeat bounds how many commands one heartbeat returns;
+// the rest follow with later heartbeats
+const maxCommandsPerHeartbeat = 20
+
+var (
+	ErrDeviceCommandNotFound      = errors.New("device command not found")
+	ErrInvalidDeviceCommand       = errors.New("type must be lock, logout_all, wipe_cache, reload_config or update_app, payload a JSON object and update_app needs a version")
+	ErrDeviceCommandClosed        = errors.New("device command was already acknowledged, cancelled or has expired")
+	ErrInvalidDeviceCommandResult = errors.New("status must be succeeded or failed and result a JSON object")
+)
+
+// DeviceCommandService queues remote commands for devices, hands them out
+// with heartbeats and records what the devices report back
+type DeviceCommandService struct {
+	config         *config.Config
+	commandRepo    *repository.DeviceCommandRepository
+	deviceRepo     *repository.DeviceRepository
+	sessionService *auth.SessionService
+}
+
+func NewDeviceCommandService(
+	cfg *config.Config,
+	commandRepo *repository.DeviceCommandRepository,
+	deviceRepo *repository.DeviceRepository,
+	sessionService *auth.SessionService,
+) *DeviceCommandService {
+	return &DeviceCommandService{
+		config:         cfg,
+		commandRepo:    commandRepo,
+		deviceRepo:     deviceRepo,
+		sessionService: sessionService,
+	}
+}
+
+type EnqueueDeviceCommandRequest struct {
+	Type    string          `json:"type" binding:"required"`
+	Payload json.RawMessage `json:"payload"`
+	// ExpiresIn is the command's lifetime in seconds; DEVICE_COMMAND_EXPIRY applies when zero
+	ExpiresIn int64 `json:"expires_in" binding:"min=0"`
+}
+
+type AcknowledgeDeviceCommandRequest struct {
+	Status  string          `json:"status" binding:"required"`
+	Result  json.RawMessage `json:"result"`
+	Message string          `json:"message" binding:"max=1000"`
+}
+
+// Enqueue queues a command for a device. logout_all also ends the device's
+// sessions right away, so they are gone even if the device never picks the
+// command up.
+func (s *DeviceCommandService) Enqueue(ctx context.Context, deviceID int64, req *EnqueueDeviceCommandRequest, issuedBy int64) (*domain.DeviceCommand, error) {
+	if _, err := s.deviceRepo.FindByID(ctx, deviceID); err != nil {
+		return nil, ErrDeviceNotFound
+	}
+
+	if !domain.IsValidDeviceCommandType(req.Type) {
+		return nil, ErrInvalidDeviceCommand
+	}
+	payload, fields, err := jsonObject(req.Payload)
+	if err != nil {
+		return nil, ErrInvalidDeviceCommand
+	}
+	if req.Type == domain.DeviceCommandUpdateApp {
+		if version, _ := fields["version"].(string); version == "" {
+			return nil, ErrInvalidDeviceCommand
+		}
+	}
+
+	expiry := s.config.Device.CommandExpiry
+	if req.ExpiresIn > 0 {
+		expiry = time.Duration(req.ExpiresIn) * time.Second
+	}
+
+	command := &domain.DeviceCommand{
+		DeviceID:    deviceID,
+		Type:        req.Type,
+		PayloadJSON: payload,
+		Status:      domain.DeviceCommandPending,
+		IssuedBy:    &issuedBy,
+		ExpiresAt:   time.Now().Add(expiry),
+		ResultJSON:  "{}",
+	}
+	command.CreatedBy = &issuedBy
+
+	if err := s.commandRepo.Create(ctx, command); err != nil {
+		return nil, err
+	}
+
+	if req.Type == domain.DeviceCommandLogoutAll {
+		if err := s.sessionService.LogoutDevice(ctx, deviceID, auth.LogoutReasonRemoteCommand); err != nil {
+			return nil, err
+		}
+	}
+
+	return command, nil
+}
+
+// Deliver returns the open commands of a device for its heartbeat response
+// and records their delivery. Commands are delivered again with every
+// heartbeat until the device acknowledges them.
+func (s *DeviceCommandService) Deliver(ctx context.Context, deviceID int64) ([]domain.DeviceCommand, error) {
+	commands, err := s.commandRepo.FindOpenByDevice(ctx, deviceID, maxCommandsPerHeartbeat)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(commands))
+	now := time.Now()
+	for i := range commands {
+		ids[i] = commands[i].ID
+		commands[i].Status = domain.DeviceCommandDelivered
+		commands[i].DeliveryCount++
+		if commands[i].DeliveredAt == nil {
+			commands[i].DeliveredAt = &now
+		}
+	}
+	if err := s.commandRepo.MarkDelivered(ctx, ids); err != nil {
+		return nil, err
+	}
+
+	return commands, nil
+}
+
+// Acknowledge records the result a device reports for one of its commands
+func (s *DeviceCommandService) Acknowledge(ctx context.Context, deviceID, commandID int64, req *AcknowledgeDeviceCommandRequest) (*domain.DeviceCommand, error) {
+	if req.Status != domain.DeviceCommandSucceeded && req.Status != domain.DeviceCommandFailed {
+		return nil, ErrInvalidDeviceCommandResult
+	}
+	result, _, err := jsonObject(req.Result)
+	if err != nil {
+		return nil, ErrInvalidDeviceCommandResult
+	}
+
+	command, err := s.find(ctx, deviceID, commandID)
+	if err != nil {
+		return nil, err
+	}
+
+	acknowledged, err := s.commandRepo.Acknowledge(ctx, command.ID, deviceID, req.Status, result, req.Message)
+	if err != nil {
+		return nil, err
+	}
+	if !acknowledged {
+		return nil, ErrDeviceCommandClosed
+	}
+
+	return s.commandRepo.FindByID(ctx, command.ID)
+}
+
+// Cancel withdraws a command the device has not acknowledged yet
+func (s *DeviceCommandService) Cancel(ctx context.Context, deviceID, commandID, cancelledBy int64) error {
+	command, err := s.find(ctx, deviceID, commandID)
+	if err != nil {
+		return err
+	}
+
+	cancelled, err := s.commandRepo.Cancel(ctx, command.ID, deviceID, cancelledBy)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return ErrDeviceCommandClosed
+	}
+	return nil
+}
+
+// List returns the command history of a device, newest first
+func (s *DeviceCommandService) List(ctx context.Context, deviceID int64, status string, page, pageSize int) (*repository.PaginatedResult[domain.DeviceCommand], error) {
+	return s.commandRepo.FindByDevice(ctx, deviceID, status, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+func (s *DeviceCommandService) find(ctx context.Context, deviceID, commandID int64) (*domain.DeviceCommand, error) {
+	command, err := s.commandRepo.FindByID(ctx, commandID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, ErrDeviceCommandNotFound
+		}
+		return nil, err
+	}
+	if command.DeviceID != deviceID {
+		return nil, ErrDeviceCommandNotFound
+	}
+	return command, nil
+}
+
+// jsonObject validates an optional JSON object, returning it as stored in a
+// jsonb column ("{}" when absent) along with its fields
+func jsonObject(raw json.RawMessage) (string, map[string]interface{}, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "{}", map[string]interface{}{}, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return "", nil, err
+	}
+	return string(raw), fields, nil
+}
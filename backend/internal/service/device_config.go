@@ -0,0 +1,643 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gebase/internal/domain"
+	"gebase/internal/jsonschema"
+	"gebase/internal/repository"
+)
+
+var (
+	ErrDeviceConfigProfileNotFound = errors.New("device config profile not found")
+	ErrDeviceConfigProfileExists   = errors.New("a config profile already exists for this target")
+	ErrDeviceConfigVersionNotFound = errors.New("device config version not found")
+	ErrDeviceConfigConflict        = errors.New("device config profile was changed by someone else; reload it and try again")
+	ErrInvalidDeviceConfigProfile  = errors.New("scope must be organization, platform or device with only its matching target set")
+	ErrInvalidDeviceConfig         = errors.New("config must be a JSON object")
+	ErrDeviceConfigSchemaNotFound  = errors.New("device config schema not found")
+	ErrInvalidDevicePlatform       = errors.New("unknown device platform")
+)
+
+// DeviceConfigValidationError reports a config that fails its platform's schema
+type DeviceConfigValidationError struct {
+	Platform domain.DevicePlatform
+	// OrganizationID is the organization whose devices would get the invalid
+	// config, nil for devices outside organizations
+	OrganizationID *int64
+	Errors         []jsonschema.ValidationError
+}
+
+func (e *DeviceConfigValidationError) Error() string {
+	target := "devices without an organization"
+	if e.OrganizationID != nil {
+		target = fmt.Sprintf("devices of organization %d", *e.OrganizationID)
+	}
+	return fmt.Sprintf("effective %s config of %s fails its schema", e.Platform, target)
+}
+
+// DeviceConfigService manages layered, versioned device configuration and
+// the per-platform schemas it is validated against
+type DeviceConfigService struct {
+	profileRepo *repository.DeviceConfigProfileRepository
+	versionRepo *repository.DeviceConfigVersionRepository
+	schemaRepo  *repository.DeviceConfigSchemaRepository
+	deviceRepo  *repository.DeviceRepository
+	orgRepo     *repository.OrganizationRepository
+}
+
+func NewDeviceConfigService(
+	profileRepo *repository.DeviceConfigProfileRepository,
+	versionRepo *repository.DeviceConfigVersionRepository,
+	schemaRepo *repository.DeviceConfigSchemaRepository,
+	deviceRepo *repository.DeviceRepository,
+	orgRepo *repository.OrganizationRepository,
+) *DeviceConfigService {
+	return &DeviceConfigService{
+		profileRepo: profileRepo,
+		versionRepo: versionRepo,
+		schemaRepo:  schemaRepo,
+		deviceRepo:  deviceRepo,
+		orgRepo:     orgRepo,
+	}
+}
+
+type CreateDeviceConfigProfileRequest struct {
+	Scope          string          `json:"scope" binding:"required,oneof=organization platform device"`
+	OrganizationID *int64          `json:"organization_id"`
+	Platform       string          `json:"platform"`
+	DeviceID       *int64          `json:"device_id"`
+	Config         json.RawMessage `json:"config" binding:"required"`
+	Note           string          `json:"note" binding:"max=500"`
+}
+
+type UpdateDeviceConfigRequest struct {
+	Config json.RawMessage `json:"config" binding:"required"`
+	Note   string          `json:"note" binding:"max=500"`
+	// BaseVersion is the profile version the change was made against; when
+	// set, the update fails if the profile has changed since
+	BaseVersion int `json:"base_version" binding:"min=0"`
+}
+
+type RollbackDeviceConfigRequest struct {
+	Version int    `json:"version" binding:"required,min=1"`
+	Note    string `json:"note" binding:"max=500"`
+}
+
+// DeviceConfigChange is one entry of a version's diff
+type DeviceConfigChange struct {
+	// Op is add, remove or replace
+	Op   string      `json:"op"`
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// DeviceConfigLayer identifies a profile that contributed to an effective config
+type DeviceConfigLayer struct {
+	Scope     string `json:"scope"`
+	ProfileID int64  `json:"profile_id"`
+	Version   int    `json:"version"`
+}
+
+// EffectiveDeviceConfig is the config a device runs with
+type EffectiveDeviceConfig struct {
+	Config map[string]interface{} `json:"config"`
+	// Version changes whenever the effective config does; devices compare it
+	// with the one in their heartbeat response to know when to refetch
+	Version string              `json:"version"`
+	Layers  []DeviceConfigLayer `json:"layers"`
+}
+
+// ListProfiles returns config profiles, optionally filtered
+func (s *DeviceConfigService) ListProfiles(ctx context.Context, scope string, orgID *int64, platform string, page, pageSize int) (*repository.PaginatedResult[domain.DeviceConfigProfile], error) {
+	return s.profileRepo.FindFiltered(ctx, scope, orgID, platform, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// GetProfile returns a config profile
+func (s *DeviceConfigService) GetProfile(ctx context.Context, id int64) (*domain.DeviceConfigProfile, error) {
+	profile, err := s.profileRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrDeviceConfigProfileNotFound
+	}
+	return profile, nil
+}
+
+// CreateProfile creates a config profile for an organization, a platform or
+// a device, validated against the schemas it affects
+func (s *DeviceConfigService) CreateProfile(ctx context.Context, req *CreateDeviceConfigProfileRequest, createdBy int64) (*domain.DeviceConfigProfile, error) {
+	profile := &domain.DeviceConfigProfile{Scope: req.Scope}
+	var existing *domain.DeviceConfigProfile
+	var err error
+
+	switch req.Scope {
+	case domain.DeviceConfigScopeOrganization:
+		if req.OrganizationID == nil || req.Platform != "" || req.DeviceID != nil {
+			return nil, ErrInvalidDeviceConfigProfile
+		}
+		if _, err := s.orgRepo.FindByID(ctx, *req.OrganizationID); err != nil {
+			return nil, ErrOrganizationNotFound
+		}
+		profile.OrganizationID = req.OrganizationID
+		existing, err = s.profileRepo.FindOrganizationProfile(ctx, *req.OrganizationID)
+	case domain.DeviceConfigScopePlatform:
+		if req.Platform == "" || req.OrganizationID != nil || req.DeviceID != nil {
+			return nil, ErrInvalidDeviceConfigProfile
+		}
+		if !domain.IsValidDevicePlatform(req.Platform) {
+			return nil, ErrInvalidDevicePlatform
+		}
+		profile.Platform = domain.DevicePlatform(req.Platform)
+		existing, err = s.profileRepo.FindPlatformProfile(ctx, profile.Platform)
+	case domain.DeviceConfigScopeDevice:
+		if req.DeviceID == nil || req.OrganizationID != nil || req.Platform != "" {
+			return nil, ErrInvalidDeviceConfigProfile
+		}
+		if _, err := s.deviceRepo.FindByID(ctx, *req.DeviceID); err != nil {
+			return nil, ErrDeviceNotFound
+		}
+		profile.DeviceID = req.DeviceID
+		existing, err = s.profileRepo.FindDeviceProfile(ctx, *req.DeviceID)
+	default:
+		return nil, ErrInvalidDeviceConfigProfile
+	}
+	if err == nil && existing != nil {
+		return nil, ErrDeviceConfigProfileExists
+	}
+	if err != nil && !repository.IsNotFound(err) {
+		return nil, err
+	}
+
+	return s.create(ctx, profile, req.Config, req.Note, createdBy)
+}
+
+// UpdateProfile replaces the config of a profile, recording a new version
+func (s *DeviceConfigService) UpdateProfile(ctx context.Context, id int64, req *UpdateDeviceConfigRequest, updatedBy int64) (*domain.DeviceConfigProfile, error) {
+	profile, err := s.GetProfile(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.BaseVersion != 0 && req.BaseVersion != profile.Version {
+		return nil, ErrDeviceConfigConflict
+	}
+	return s.save(ctx, profile, req.Config, req.Note, nil, updatedBy)
+}
+
+// SetDeviceOverrides creates or updates the overrides of one device
+func (s *DeviceConfigService) SetDeviceOverrides(ctx context.Context, deviceID int64, req *UpdateDeviceConfigRequest, updatedBy int64) (*domain.DeviceConfigProfile, error) {
+	if _, err := s.deviceRepo.FindByID(ctx, deviceID); err != nil {
+		return nil, ErrDeviceNotFound
+	}
+
+	profile, err := s.profileRepo.FindDeviceProfile(ctx, deviceID)
+	if err != nil {
+		if !repository.IsNotFound(err) {
+			return nil, err
+		}
+		profile = &domain.DeviceConfigProfile{Scope: domain.DeviceConfigScopeDevice, DeviceID: &deviceID}
+		return s.create(ctx, profile, req.Config, req.Note, updatedBy)
+	}
+	if req.BaseVersion != 0 && req.BaseVersion != profile.Version {
+		return nil, ErrDeviceConfigConflict
+	}
+	return s.save(ctx, profile, req.Config, req.Note, nil, updatedBy)
+}
+
+// DeleteProfile deletes a config profile. Its history is kept.
+func (s *DeviceConfigService) DeleteProfile(ctx context.Context, id, deletedBy int64) error {
+	if _, err := s.GetProfile(ctx, id); err != nil {
+		return err
+	}
+	return s.profileRepo.DeleteWithHistory(ctx, id, deletedBy)
+}
+
+// ListVersions returns the history of a profile, newest first
+func (s *DeviceConfigService) ListVersions(ctx context.Context, profileID int64, page, pageSize int) (*repository.PaginatedResult[domain.DeviceConfigVersion], error) {
+	if _, err := s.GetProfile(ctx, profileID); err != nil {
+		return nil, err
+	}
+	return s.versionRepo.FindByProfile(ctx, profileID, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// Rollback restores the config of an earlier version as a new version, so
+// the rollback itself shows up in the history
+func (s *DeviceConfigService) Rollback(ctx context.Context, profileID int64, req *RollbackDeviceConfigRequest, rolledBackBy int64) (*domain.DeviceConfigProfile, error) {
+	profile, err := s.GetProfile(ctx, profileID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.versionRepo.FindVersion(ctx, profileID, req.Version)
+	if err != nil {
+		return nil, ErrDeviceConfigVersionNotFound
+	}
+
+	note := req.Note
+	if note == "" {
+		note = fmt.Sprintf("Rollback to version %d", target.Version)
+	}
+	return s.save(ctx, profile, json.RawMessage(target.ConfigJSON), note, &target.Version, rolledBackBy)
+}
+
+// Effective computes the config of a device from its layers
+func (s *DeviceConfigService) Effective(ctx context.Context, device *domain.Device) (*EffectiveDeviceConfig, error) {
+	profiles, err := s.profileRepo.FindLayers(ctx, device.OrganizationID, device.Platform, device.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	effective := &EffectiveDeviceConfig{Config: map[string]interface{}{}, Layers: []DeviceConfigLayer{}}
+	for _, scope := range []string{domain.DeviceConfigScopeOrganization, domain.DeviceConfigScopePlatform, domain.DeviceConfigScopeDevice} {
+		for i := range profiles {
+			if profiles[i].Scope != scope {
+				continue
+			}
+			effective.Config = mergePatch(effective.Config, profileConfig(&profiles[i]))
+			effective.Layers = append(effective.Layers, DeviceConfigLayer{
+				Scope:     scope,
+				ProfileID: profiles[i].ID,
+				Version:   profiles[i].Version,
+			})
+		}
+	}
+
+	// Map keys marshal sorted, so equal configs hash alike
+	encoded, err := json.Marshal(effective.Config)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(encoded)
+	effective.Version = hex.EncodeToString(sum[:8])
+	return effective, nil
+}
+
+// GetEffectiveConfig computes the config of a device by ID
+func (s *DeviceConfigService) GetEffectiveConfig(ctx context.Context, deviceID int64) (*EffectiveDeviceConfig, error) {
+	device, err := s.deviceRepo.FindByID(ctx, deviceID)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	return s.Effective(ctx, device)
+}
+
+// ListSchemas returns the config schemas of all platforms that have one
+func (s *DeviceConfigService) ListSchemas(ctx context.Context) ([]domain.DeviceConfigSchema, error) {
+	return s.schemaRepo.FindAll(ctx)
+}
+
+// GetSchema returns the config schema of a platform
+func (s *DeviceConfigService) GetSchema(ctx context.Context, platform string) (*domain.DeviceConfigSchema, error) {
+	schema, err := s.schemaRepo.FindByPlatform(ctx, domain.DevicePlatform(platform))
+	if err != nil {
+		return nil, ErrDeviceConfigSchemaNotFound
+	}
+	return schema, nil
+}
+
+// PutSchema sets the config schema of a platform. The organization and
+// platform profiles must already satisfy it.
+func (s *DeviceConfigService) PutSchema(ctx context.Context, platform string, raw json.RawMessage, updatedBy int64) (*domain.DeviceConfigSchema, error) {
+	if !domain.IsValidDevicePlatform(platform) {
+		return nil, ErrInvalidDevicePlatform
+	}
+	compiled, err := jsonschema.Compile(raw)
+	if err != nil {
+		return nil, &InvalidDeviceConfigSchemaError{err: err}
+	}
+
+	orgProfiles, err := s.profileRepo.FindByScope(ctx, domain.DeviceConfigScopeOrganization)
+	if err != nil {
+		return nil, err
+	}
+	platformConfig, err := s.platformConfig(ctx, domain.DevicePlatform(platform))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPlatformConfig(compiled, domain.DevicePlatform(platform), orgProfiles, platformConfig); err != nil {
+		return nil, err
+	}
+
+	schema, err := s.schemaRepo.FindByPlatform(ctx, domain.DevicePlatform(platform))
+	if err != nil {
+		if !repository.IsNotFound(err) {
+			return nil, err
+		}
+		schema = &domain.DeviceConfigSchema{Platform: domain.DevicePlatform(platform), SchemaJSON: string(raw)}
+		schema.CreatedBy = &updatedBy
+		if err := s.schemaRepo.Create(ctx, schema); err != nil {
+			return nil, err
+		}
+		return schema, nil
+	}
+
+	schema.SchemaJSON = string(raw)
+	schema.UpdatedBy = &updatedBy
+	if err := s.schemaRepo.Update(ctx, schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// DeleteSchema removes the config schema of a platform, so its configs are
+// no longer validated
+func (s *DeviceConfigService) DeleteSchema(ctx context.Context, platform string) error {
+	schema, err := s.GetSchema(ctx, platform)
+	if err != nil {
+		return err
+	}
+	return s.schemaRepo.Delete(ctx, schema.ID)
+}
+
+// InvalidDeviceConfigSchemaError reports a schema that cannot be compiled
+type InvalidDeviceConfigSchemaError struct {
+	err error
+}
+
+func (e *InvalidDeviceConfigSchemaError) Error() string {
+	return "invalid schema: " + e.err.Error()
+}
+
+func (s *DeviceConfigService) create(ctx context.Context, profile *domain.DeviceConfigProfile, raw json.RawMessage, note string, createdBy int64) (*domain.DeviceConfigProfile, error) {
+	config, err := parseDeviceConfig(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validate(ctx, profile, config); err != nil {
+		return nil, err
+	}
+
+	configJSON, diffJSON, err := encodeDeviceConfigChange(map[string]interface{}{}, config)
+	if err != nil {
+		return nil, err
+	}
+	profile.ConfigJSON = configJSON
+	profile.Version = 1
+	profile.CreatedBy = &createdBy
+
+	version := &domain.DeviceConfigVersion{
+		Version:    1,
+		ConfigJSON: configJSON,
+		DiffJSON:   diffJSON,
+		Note:       note,
+	}
+	version.CreatedBy = &createdBy
+
+	if err := s.profileRepo.CreateWithVersion(ctx, profile, version); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func (s *DeviceConfigService) save(ctx context.Context, profile *domain.DeviceConfigProfile, raw json.RawMessage, note string, rolledBackFrom *int, updatedBy int64) (*domain.DeviceConfigProfile, error) {
+	config, err := parseDeviceConfig(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validate(ctx, profile, config); err != nil {
+		return nil, err
+	}
+
+	configJSON, diffJSON, err := encodeDeviceConfigChange(profileConfig(profile), config)
+	if err != nil {
+		return nil, err
+	}
+
+	version := &domain.DeviceConfigVersion{
+		ProfileID:      profile.ID,
+		Version:        profile.Version + 1,
+		ConfigJSON:     configJSON,
+		DiffJSON:       diffJSON,
+		Note:           note,
+		RolledBackFrom: rolledBackFrom,
+	}
+	version.CreatedBy = &updatedBy
+
+	saved, err := s.profileRepo.SaveVersion(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return nil, ErrDeviceConfigConflict
+	}
+
+	profile.ConfigJSON = configJSON
+	profile.Version = version.Version
+	profile.UpdatedBy = &updatedBy
+	return profile, nil
+}
+
+// validate checks the effective configs a profile change leads to against
+// the schemas of the platforms involved. Device overrides are only checked
+// when they change themselves.
+func (s *DeviceConfigService) validate(ctx context.Context, profile *domain.DeviceConfigProfile, config map[string]interface{}) error {
+	switch profile.Scope {
+	case domain.DeviceConfigScopeOrganization:
+		schemas, err := s.schemaRepo.FindAll(ctx)
+		if err != nil {
+			return err
+		}
+		for _, schema := range schemas {
+			compiled, err := jsonschema.Compile([]byte(schema.SchemaJSON))
+			if err != nil {
+				return err
+			}
+			platformConfig, err := s.platformConfig(ctx, schema.Platform)
+			if err != nil {
+				return err
+			}
+			if errs := compiled.Validate(layerConfigs(config, platformConfig)); len(errs) > 0 {
+				return &DeviceConfigValidationError{Platform: schema.Platform, OrganizationID: profile.OrganizationID, Errors: errs}
+			}
+		}
+		return nil
+
+	case domain.DeviceConfigScopePlatform:
+		compiled, err := s.compiledSchema(ctx, profile.Platform)
+		if err != nil || compiled == nil {
+			return err
+		}
+		orgProfiles, err := s.profileRepo.FindByScope(ctx, domain.DeviceConfigScopeOrganization)
+		if err != nil {
+			return err
+		}
+		return checkPlatformConfig(compiled, profile.Platform, orgProfiles, config)
+
+	case domain.DeviceConfigScopeDevice:
+		device, err := s.deviceRepo.FindByID(ctx, *profile.DeviceID)
+		if err != nil {
+			return ErrDeviceNotFound
+		}
+		compiled, err := s.compiledSchema(ctx, device.Platform)
+		if err != nil || compiled == nil {
+			return err
+		}
+		orgConfig := map[string]interface{}{}
+		if device.OrganizationID != nil {
+			orgProfile, err := s.profileRepo.FindOrganizationProfile(ctx, *device.OrganizationID)
+			if err != nil && !repository.IsNotFound(err) {
+				return err
+			}
+			if orgProfile != nil {
+				orgConfig = profileConfig(orgProfile)
+			}
+		}
+		platformConfig, err := s.platformConfig(ctx, device.Platform)
+		if err != nil {
+			return err
+		}
+		if errs := compiled.Validate(layerConfigs(orgConfig, platformConfig, config)); len(errs) > 0 {
+			return &DeviceConfigValidationError{Platform: device.Platform, OrganizationID: device.OrganizationID, Errors: errs}
+		}
+		return nil
+	}
+	return ErrInvalidDeviceConfigProfile
+}
+
+// compiledSchema returns the schema of a platform, nil when it has none
+func (s *DeviceConfigService) compiledSchema(ctx context.Context, platform domain.DevicePlatform) (*jsonschema.Schema, error) {
+	schema, err := s.schemaRepo.FindByPlatform(ctx, platform)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return jsonschema.Compile([]byte(schema.SchemaJSON))
+}
+
+// platformConfig returns the config of a platform's profile, empty when it has none
+func (s *DeviceConfigService) platformConfig(ctx context.Context, platform domain.DevicePlatform) (map[string]interface{}, error) {
+	profile, err := s.profileRepo.FindPlatformProfile(ctx, platform)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return map[string]interface{}{}, nil
+		}
+		return nil, err
+	}
+	return profileConfig(profile), nil
+}
+
+// checkPlatformConfig validates platformConfig layered over each
+// organization profile, and over nothing for devices outside organizations
+func checkPlatformConfig(schema *jsonschema.Schema, platform domain.DevicePlatform, orgProfiles []domain.DeviceConfigProfile, platformConfig map[string]interface{}) error {
+	if errs := schema.Validate(layerConfigs(platformConfig)); len(errs) > 0 {
+		return &DeviceConfigValidationError{Platform: platform, Errors: errs}
+	}
+	for i := range orgProfiles {
+		if errs := schema.Validate(layerConfigs(profileConfig(&orgProfiles[i]), platformConfig)); len(errs) > 0 {
+			return &DeviceConfigValidationError{Platform: platform, OrganizationID: orgProfiles[i].OrganizationID, Errors: errs}
+		}
+	}
+	return nil
+}
+
+// parseDeviceConfig decodes a config layer, which must be a JSON object
+func parseDeviceConfig(raw json.RawMessage) (map[string]interface{}, error) {
+	var config map[string]interface{}
+	if err := json.Unmarshal(raw, &config); err != nil || config == nil {
+		return nil, ErrInvalidDeviceConfig
+	}
+	return config, nil
+}
+
+// profileConfig decodes the stored config of a profile
+func profileConfig(profile *domain.DeviceConfigProfile) map[string]interface{} {
+	config := map[string]interface{}{}
+	_ = json.Unmarshal([]byte(profile.ConfigJSON), &config)
+	return config
+}
+
+// encodeDeviceConfigChange encodes a new config and its diff from the old one
+func encodeDeviceConfigChange(old, new map[string]interface{}) (string, string, error) {
+	configJSON, err := json.Marshal(new)
+	if err != nil {
+		return "", "", err
+	}
+	changes := []DeviceConfigChange{}
+	diffConfig("", old, new, &changes)
+	diffJSON, err := json.Marshal(changes)
+	if err != nil {
+		return "", "", err
+	}
+	return string(configJSON), string(diffJSON), nil
+}
+
+// layerConfigs applies config layers in order, starting from an empty config
+func layerConfigs(layers ...map[string]interface{}) map[string]interface{} {
+	config := map[string]interface{}{}
+	for _, layer := range layers {
+		config = mergePatch(config, layer)
+	}
+	return config
+}
+
+// mergePatch applies patch to a copy of target as a JSON merge patch (RFC 7386)
+func mergePatch(target, patch map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(target)+len(patch))
+	for key, value := range target {
+		merged[key] = value
+	}
+	for key, value := range patch {
+		switch v := value.(type) {
+		case nil:
+			delete(merged, key)
+		case map[string]interface{}:
+			base, _ := merged[key].(map[string]interface{})
+			merged[key] = mergePatch(base, v)
+		default:
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// diffConfig appends the changes from old to new, descending into objects,
+// with JSON pointer paths in key order
+func diffConfig(path string, old, new interface{}, changes *[]DeviceConfigChange) {
+	oldObject, oldIsObject := old.(map[string]interface{})
+	newObject, newIsObject := new.(map[string]interface{})
+	if !oldIsObject || !newIsObject {
+		if !reflect.DeepEqual(old, new) {
+			*changes = append(*changes, DeviceConfigChange{Op: "replace", Path: path, Old: old, New: new})
+		}
+		return
+	}
+
+	keys := make([]string, 0, len(oldObject)+len(newObject))
+	for key := range oldObject {
+		keys = append(keys, key)
+	}
+	for key := range newObject {
+		if _, ok := oldObject[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		at := path + "/" + strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+		oldValue, inOld := oldObject[key]
+		newValue, inNew := newObject[key]
+		switch {
+		case !inNew:
+			*changes = append(*changes, DeviceConfigChange{Op: "remove", Path: at, Old: oldValue})
+		case !inOld:
+			*changes = append(*changes, DeviceConfigChange{Op: "add", Path: at, New: newValue})
+		default:
+			diffConfig(at, oldValue, newValue, changes)
+		}
+	}
+}
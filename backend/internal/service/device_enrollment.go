@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gebase/internal/auth"
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/mail"
+	"gebase/internal/repository"
+)
+
+// DeviceApprovePermission is held by the administrators notified of devices
+// awaiting approval
+const DeviceApprovePermission = "admin.device.update"
+
+var (
+	ErrDeviceEnrollmentPolicyNotFound = errors.New("device enrollment policy not found")
+	ErrInvalidDeviceEnrollmentPolicy  = errors.New("platform must be a device platform or platform class and mode must be auto or manual")
+	ErrDeviceApprovalUnchanged        = errors.New("device already has this approval status")
+)
+
+// DeviceEnrollmentService decides whether new devices are approved on
+// registration and runs the approval queue for the others
+type DeviceEnrollmentService struct {
+	config      *config.Config
+	policyRepo  *repository.DeviceEnrollmentPolicyRepository
+	deviceRepo  *repository.DeviceRepository
+	orgRepo     *repository.OrganizationRepository
+	userRepo    *repository.UserRepository
+	sessionRepo *repository.SessionRepository
+	mailSender  mail.Sender
+}
+
+func NewDeviceEnrollmentService(
+	cfg *config.Config,
+	policyRepo *repository.DeviceEnrollmentPolicyRepository,
+	deviceRepo *repository.DeviceRepository,
+	orgRepo *repository.OrganizationRepository,
+	userRepo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+	mailSender mail.Sender,
+) *DeviceEnrollmentService {
+	return &DeviceEnrollmentService{
+		config:      cfg,
+		policyRepo:  policyRepo,
+		deviceRepo:  deviceRepo,
+		orgRepo:     orgRepo,
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		mailSender:  mailSender,
+	}
+}
+
+type DeviceEnrollmentPolicyRequest struct {
+	OrganizationID *int64 `json:"organization_id"`
+	Platform       string `json:"platform"`
+	Mode           string `json:"mode" binding:"required"`
+	IsActive       *bool  `json:"is_active"`
+}
+
+type RejectDeviceRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// Enroll sets the approval status of a device about to be created from the
+// enrollment policy of its organization and platform
+func (s *DeviceEnrollmentService) Enroll(ctx context.Context, device *domain.Device) error {
+	mode, err := s.mode(ctx, device.OrganizationID, device.Platform)
+	if err != nil {
+		return err
+	}
+
+	if mode == domain.DeviceEnrollmentManual {
+		device.ApprovalStatus = domain.DeviceApprovalPending
+		device.IsRegistered = domain.Ptr(false)
+		return nil
+	}
+
+	device.ApprovalStatus = domain.DeviceApprovalApproved
+	device.IsRegistered = domain.Ptr(true)
+	device.RegisteredAt = domain.Ptr(time.Now())
+	return nil
+}
+
+// AdmitUser checks that user may sign in on device. Devices registered
+// without a session have no organization, as the client cannot be trusted to
+// name one: such a device joins the user's organization on its first login,
+// and is held for approval when that organization's policy asks for it and
+// no administrator approved the device. A device of another organization is
+// refused.
+func (s *DeviceEnrollmentService) AdmitUser(ctx context.Context, device *domain.Device, user *domain.User) error {
+	if device.OrganizationID != nil {
+		if user.OrganizationID == nil || *user.OrganizationID != *device.OrganizationID {
+			return ErrDeviceOrganizationMismatch
+		}
+		return nil
+	}
+	if user.OrganizationID == nil {
+		return nil
+	}
+
+	status := device.ApprovalStatus
+	if status == domain.DeviceApprovalApproved && device.ReviewedBy == nil {
+		mode, err := s.mode(ctx, user.OrganizationID, device.Platform)
+		if err != nil {
+			return err
+		}
+		if mode == domain.DeviceEnrollmentManual {
+			status = domain.DeviceApprovalPending
+		}
+	}
+
+	claimed, err := s.deviceRepo.ClaimOrganization(ctx, device.ID, *user.OrganizationID, status)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		// Another login claimed the device first
+		current, err := s.deviceRepo.FindByID(ctx, device.ID)
+		if err != nil {
+			return err
+		}
+		if current.OrganizationID == nil {
+			return ErrDeviceNotFound
+		}
+		*device = *current
+		return s.AdmitUser(ctx, device, user)
+	}
+
+	device.OrganizationID = user.OrganizationID
+	if status != device.ApprovalStatus {
+		device.ApprovalStatus = status
+		device.IsRegistered = domain.Ptr(false)
+		go s.NotifyPending(context.WithoutCancel(ctx), device)
+		return ErrDevicePendingApproval
+	}
+	return nil
+}
+
+// NotifyPending emails the administrators who may approve a device that a
+// new one is waiting. Failures are logged, as registration already succeeded.
+func (s *DeviceEnrollmentService) NotifyPending(ctx context.Context, device *domain.Device) {
+	admins, err := s.userRepo.FindWithPermission(ctx, device.OrganizationID, DeviceApprovePermission)
+	if err != nil {
+		log.Printf("Warning: failed to find approvers of device %d: %v", device.ID, err)
+		return
+	}
+	if len(admins) == 0 {
+		log.Printf("Warning: no administrator can approve device %d", device.ID)
+		return
+	}
+
+	to := make([]string, 0, len(admins))
+	for _, admin := range admins {
+		to = append(to, admin.Email)
+	}
+	msg := &mail.Message{
+		To:      to,
+		Subject: "Device awaiting approval: " + device.Name,
+		Body: fmt.Sprintf(
+			"A new device has registered and is waiting for approval:\n\n"+
+				"Name: %s\nPlatform: %s\nOS version: %s\nApp version: %s\nDevice UID: %s\n\n"+
+				"Approve or reject it under Devices > Pending in the admin console. "+
+				"Users cannot sign in on the device until it is approved.\n",
+			device.Name, device.Platform, device.OSVersion, device.AppVersion, device.DeviceUID,
+		),
+	}
+	if err := s.mailSender.Send(ctx, msg); err != nil {
+		log.Printf("Warning: failed to notify approvers of device %d: %v", device.ID, err)
+	}
+}
+
+// ListPending returns devices awaiting approval, optionally of one organization
+func (s *DeviceEnrollmentService) ListPending(ctx context.Context, orgID *int64, page, pageSize int) (*repository.PaginatedResult[domain.Device], error) {
+	return s.deviceRepo.FindPending(ctx, orgID, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// Approve lets a pending or previously rejected device be used
+func (s *DeviceEnrollmentService) Approve(ctx context.Context, id int64, approvedBy int64) (*domain.Device, error) {
+	device, err := s.deviceRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	if device.IsApproved() {
+		return nil, ErrDeviceApprovalUnchanged
+	}
+
+	if err := s.deviceRepo.Review(ctx, id, domain.DeviceApprovalApproved, "", approvedBy); err != nil {
+		return nil, err
+	}
+	return s.deviceRepo.FindByID(ctx, id)
+}
+
+// Reject refuses a device. Rejecting an approved device also ends its sessions.
+func (s *DeviceEnrollmentService) Reject(ctx context.Context, id int64, req *RejectDeviceRequest, rejectedBy int64) (*domain.Device, error) {
+	device, err := s.deviceRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	if device.ApprovalStatus == domain.DeviceApprovalRejected {
+		return nil, ErrDeviceApprovalUnchanged
+	}
+
+	if err := s.deviceRepo.Review(ctx, id, domain.DeviceApprovalRejected, req.Reason, rejectedBy); err != nil {
+		return nil, err
+	}
+	if err := s.sessionRepo.LogoutByDeviceID(ctx, id, auth.LogoutReasonDeviceRejected); err != nil {
+		return nil, err
+	}
+	return s.deviceRepo.FindByID(ctx, id)
+}
+
+// ListPolicies returns device enrollment policies, optionally for one organization
+func (s *DeviceEnrollmentService) ListPolicies(ctx context.Context, orgID *int64) ([]domain.DeviceEnrollmentPolicy, error) {
+	return s.policyRepo.FindFiltered(ctx, orgID)
+}
+
+// CreatePolicy creates a device enrollment policy
+func (s *DeviceEnrollmentService) CreatePolicy(ctx context.Context, req *DeviceEnrollmentPolicyRequest, createdBy int64) (*domain.DeviceEnrollmentPolicy, error) {
+	policy := &domain.DeviceEnrollmentPolicy{}
+	if err := s.apply(ctx, policy, req); err != nil {
+		return nil, err
+	}
+	policy.CreatedBy = &createdBy
+
+	if err := s.policyRepo.Create(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// UpdatePolicy replaces a device enrollment policy. Devices already
+// registered keep their approval status.
+func (s *DeviceEnrollmentService) UpdatePolicy(ctx context.Context, id int64, req *DeviceEnrollmentPolicyRequest, updatedBy int64) (*domain.DeviceEnrollmentPolicy, error) {
+	policy, err := s.policyRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrDeviceEnrollmentPolicyNotFound
+	}
+	if err := s.apply(ctx, policy, req); err != nil {
+		return nil, err
+	}
+	policy.UpdatedBy = &updatedBy
+
+	if err := s.policyRepo.Update(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// DeletePolicy deletes a device enrollment policy
+func (s *DeviceEnrollmentService) DeletePolicy(ctx context.Context, id int64) error {
+	if _, err := s.policyRepo.FindByID(ctx, id); err != nil {
+		return ErrDeviceEnrollmentPolicyNotFound
+	}
+	return s.policyRepo.Delete(ctx, id)
+}
+
+func (s *DeviceEnrollmentService) apply(ctx context.Context, policy *domain.DeviceEnrollmentPolicy, req *DeviceEnrollmentPolicyRequest) error {
+	if !domain.IsValidSessionPolicyPlatform(req.Platform) || !domain.IsValidDeviceEnrollmentMode(req.Mode) {
+		return ErrInvalidDeviceEnrollmentPolicy
+	}
+	if req.OrganizationID != nil {
+		if _, err := s.orgRepo.FindByID(ctx, *req.OrganizationID); err != nil {
+			return ErrOrganizationNotFound
+		}
+	}
+
+	policy.OrganizationID = req.OrganizationID
+	policy.Platform = req.Platform
+	policy.Mode = req.Mode
+	policy.IsActive = req.IsActive
+	if policy.IsActive == nil {
+		policy.IsActive = domain.Ptr(true)
+	}
+	return nil
+}
+
+// mode resolves the enrollment mode of the most specific matching policy,
+// falling back to DEVICE_ENROLLMENT_MODE
+func (s *DeviceEnrollmentService) mode(ctx context.Context, orgID *int64, platform domain.DevicePlatform) (string, error) {
+	policies, err := s.policyRepo.FindApplicable(ctx, orgID)
+	if err != nil {
+		return "", err
+	}
+
+	var best *domain.DeviceEnrollmentPolicy
+	for i := range policies {
+		policy := &policies[i]
+		if !policy.Matches(platform) {
+			continue
+		}
+		if best == nil || policy.Specificity(platform) > best.Specificity(platform) {
+			best = policy
+		}
+	}
+	if best != nil {
+		return best.Mode, nil
+	}
+
+	if s.config.Device.EnrollmentMode == domain.DeviceEnrollmentManual {
+		return domain.DeviceEnrollmentManual, nil
+	}
+	return domain.DeviceEnrollmentAuto, nil
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/mail"
+	"gebase/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// testMailSender hands sent messages to the test
+type testMailSender chan *mail.Message
+
+func (s testMailSender) Send(ctx context.Context, msg *mail.Message) error {
+	s <- msg
+	return nil
+}
+
+type enrollmentTestEnv struct {
+	db      *gorm.DB
+	service *DeviceEnrollmentService
+	mail    testMailSender
+}
+
+// newEnrollmentTestEnv enrolls devices automatically except in
+// organization 5, whose policy asks for approval by admin@example.com
+func newEnrollmentTestEnv(t *testing.T) *enrollmentTestEnv {
+	t.Helper()
+
+	db := newTestDB(t,
+		&domain.Device{}, &domain.DeviceEnrollmentPolicy{},
+		&domain.User{}, &domain.UserSystemRole{}, &domain.RolePermission{}, &domain.Permission{},
+	)
+	orgID := int64(5)
+	for _, record := range []interface{}{
+		&domain.DeviceEnrollmentPolicy{OrganizationID: &orgID, Mode: domain.DeviceEnrollmentManual},
+		&domain.User{ID: 1, Email: "admin@example.com", OrganizationID: &orgID, IsActive: domain.Ptr(true)},
+		&domain.Permission{ID: 1, Code: DeviceApprovePermission},
+		&domain.RolePermission{RoleID: 1, PermissionID: 1},
+		&domain.UserSystemRole{UserID: 1, RoleID: 1},
+	} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("create %T: %v", record, err)
+		}
+	}
+
+	sender := make(testMailSender, 1)
+	cfg := &config.Config{Device: config.DeviceConfig{EnrollmentMode: domain.DeviceEnrollmentAuto}}
+	return &enrollmentTestEnv{
+		db:   db,
+		mail: sender,
+		service: NewDeviceEnrollmentService(cfg,
+			repository.NewDeviceEnrollmentPolicyRepository(db),
+			repository.NewDeviceRepository(db),
+			repository.NewOrganizationRepository(db),
+			repository.NewUserRepository(db),
+			repository.NewSessionRepository(db),
+			sender,
+		),
+	}
+}
+
+// register enrolls a device the way a registration without a session does
+func (e *enrollmentTestEnv) register(t *testing.T, uid string) *domain.Device {
+	t.Helper()
+
+	device := &domain.Device{DeviceUID: uid, Platform: domain.PlatformAndroid, IsActive: domain.Ptr(true)}
+	if err := e.service.Enroll(context.Background(), device); err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	if err := e.db.Create(device).Error; err != nil {
+		t.Fatalf("create device: %v", err)
+	}
+	return device
+}
+
+func (e *enrollmentTestEnv) reload(t *testing.T, device *domain.Device) domain.Device {
+	t.Helper()
+
+	var stored domain.Device
+	if err := e.db.First(&stored, device.ID).Error; err != nil {
+		t.Fatalf("find device: %v", err)
+	}
+	return stored
+}
+
+func TestAdmitUserHoldsDeviceForOrganizationApproval(t *testing.T) {
+	env := newEnrollmentTestEnv(t)
+	orgID := int64(5)
+
+	// Without an organization the device escapes organization 5's policy
+	device := env.register(t, "kiosk-1")
+	if !device.IsApproved() {
+		t.Fatalf("device approval = %s, want approved by the global default", device.ApprovalStatus)
+	}
+
+	err := env.service.AdmitUser(context.Background(), device, &domain.User{ID: 2, OrganizationID: &orgID})
+	if !errors.Is(err, ErrDevicePendingApproval) {
+		t.Fatalf("AdmitUser error = %v, want ErrDevicePendingApproval", err)
+	}
+	stored := env.reload(t, device)
+	if stored.OrganizationID == nil || *stored.OrganizationID != orgID || stored.ApprovalStatus != domain.DeviceApprovalPending {
+		t.Fatalf("device = org %v, %s, want org 5 pending", stored.OrganizationID, stored.ApprovalStatus)
+	}
+	select {
+	case msg := <-env.mail:
+		if len(msg.To) != 1 || msg.To[0] != "admin@example.com" {
+			t.Fatalf("approval request sent to %v", msg.To)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no approval request was sent")
+	}
+
+	// The device stays in organization 5
+	if err := env.service.AdmitUser(context.Background(), &stored, &domain.User{ID: 3}); !errors.Is(err, ErrDeviceOrganizationMismatch) {
+		t.Fatalf("AdmitUser for a user without an organization error = %v, want ErrDeviceOrganizationMismatch", err)
+	}
+}
+
+func TestAdmitUser(t *testing.T) {
+	org5, org6 := int64(5), int64(6)
+	reviewer := int64(1)
+	tests := []struct {
+		name       string
+		deviceOrg  *int64
+		reviewedBy *int64
+		userOrg    *int64
+		wantErr    error
+		wantOrg    *int64
+	}{
+		{"auto organization", nil, nil, &org6, nil, &org6},
+		{"approved by an administrator", nil, &reviewer, &org5, nil, &org5},
+		{"user without organization", nil, nil, nil, nil, nil},
+		{"same organization", &org5, nil, &org5, nil, &org5},
+		{"other organization", &org6, nil, &org5, ErrDeviceOrganizationMismatch, &org6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newEnrollmentTestEnv(t)
+			device := env.register(t, "device-1")
+			err := env.db.Model(device).Updates(map[string]interface{}{"organization_id": tt.deviceOrg, "reviewed_by": tt.reviewedBy}).Error
+			if err != nil {
+				t.Fatalf("update device: %v", err)
+			}
+			device.OrganizationID, device.ReviewedBy = tt.deviceOrg, tt.reviewedBy
+
+			err = env.service.AdmitUser(context.Background(), device, &domain.User{ID: 2, OrganizationID: tt.userOrg})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AdmitUser error = %v, want %v", err, tt.wantErr)
+			}
+			stored := env.reload(t, device)
+			if (stored.OrganizationID == nil) != (tt.wantOrg == nil) || (tt.wantOrg != nil && *stored.OrganizationID != *tt.wantOrg) {
+				t.Fatalf("device organization = %v, want %v", stored.OrganizationID, tt.wantOrg)
+			}
+			if !stored.IsApproved() {
+				t.Fatalf("device approval = %s, want approved", stored.ApprovalStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,489 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/mail"
+	"gebase/internal/repository"
+)
+
+// DeviceSilenceAlertPermission is held by the administrators alerted of
+// silent devices
+const DeviceSilenceAlertPermission = "admin.device.update"
+
+// maxUptimeRange bounds the period of one uptime report
+const maxUptimeRange = 366 * 24 * time.Hour
+
+var (
+	ErrDevicePresencePolicyNotFound = errors.New("device presence policy not found")
+	ErrDevicePresencePolicyExists   = errors.New("organization already has a device presence policy")
+	ErrInvalidUptimeRange           = errors.New("from must be before to and the range at most 366 days")
+)
+
+// DevicePresenceService tracks when devices go online and offline, alerts
+// administrators of silent POS and kiosk devices and reports fleet status
+// and uptime
+type DevicePresenceService struct {
+	config     *config.Config
+	eventRepo  *repository.DevicePresenceEventRepository
+	policyRepo *repository.DevicePresencePolicyRepository
+	alertRepo  *repository.DeviceSilenceAlertRepository
+	deviceRepo *repository.DeviceRepository
+	orgRepo    *repository.OrganizationRepository
+	userRepo   *repository.UserRepository
+	mailSender mail.Sender
+}
+
+func NewDevicePresenceService(
+	cfg *config.Config,
+	eventRepo *repository.DevicePresenceEventRepository,
+	policyRepo *repository.DevicePresencePolicyRepository,
+	alertRepo *repository.DeviceSilenceAlertRepository,
+	deviceRepo *repository.DeviceRepository,
+	orgRepo *repository.OrganizationRepository,
+	userRepo *repository.UserRepository,
+	mailSender mail.Sender,
+) *DevicePresenceService {
+	return &DevicePresenceService{
+		config:     cfg,
+		eventRepo:  eventRepo,
+		policyRepo: policyRepo,
+		alertRepo:  alertRepo,
+		deviceRepo: deviceRepo,
+		orgRepo:    orgRepo,
+		userRepo:   userRepo,
+		mailSender: mailSender,
+	}
+}
+
+type DevicePresencePolicyRequest struct {
+	OrganizationID      int64 `json:"organization_id" binding:"required"`
+	SilenceAlertMinutes int   `json:"silence_alert_minutes" binding:"min=0"`
+	IsActive            *bool `json:"is_active"`
+}
+
+// DeviceFleetStats summarizes the active devices of the fleet
+type DeviceFleetStats struct {
+	Total      int64                    `json:"total"`
+	Online     int64                    `json:"online"`
+	Offline    int64                    `json:"offline"`
+	OpenAlerts int64                    `json:"open_alerts"`
+	ByPlatform []repository.DeviceCount `json:"by_platform"`
+	// ByOrganization is keyed by organization ID, "" for devices outside organizations
+	ByOrganization []repository.DeviceCount `json:"by_organization"`
+	ByAppVersion   []repository.DeviceCount `json:"by_app_version"`
+}
+
+// DeviceOutage is a period a device was offline
+type DeviceOutage struct {
+	From time.Time `json:"from"`
+	// To is nil while the device is still offline
+	To              *time.Time `json:"to"`
+	DurationSeconds int64      `json:"duration_seconds"`
+}
+
+// DeviceUptime is the availability of a device over a period. Time before
+// the device's first presence event is not monitored and not counted.
+type DeviceUptime struct {
+	DeviceID         int64          `json:"device_id"`
+	DeviceName       string         `json:"device_name,omitempty"`
+	Platform         string         `json:"platform,omitempty"`
+	MonitoredSeconds int64          `json:"monitored_seconds"`
+	OnlineSeconds    int64          `json:"online_seconds"`
+	OfflineSeconds   int64          `json:"offline_seconds"`
+	UptimePercent    float64        `json:"uptime_percent"`
+	Outages          []DeviceOutage `json:"outages,omitempty"`
+}
+
+// FleetUptime is the availability of a set of devices over a period
+type FleetUptime struct {
+	From             time.Time      `json:"from"`
+	To               time.Time      `json:"to"`
+	MonitoredSeconds int64          `json:"monitored_seconds"`
+	OnlineSeconds    int64          `json:"online_seconds"`
+	UptimePercent    float64        `json:"uptime_percent"`
+	Devices          []DeviceUptime `json:"devices"`
+}
+
+// RecordHeartbeat marks a device online when its heartbeat ends an offline
+// period, resolving its silence alert
+func (s *DevicePresenceService) RecordHeartbeat(ctx context.Context, deviceUID string) error {
+	_, err := s.eventRepo.MarkOnline(ctx, deviceUID, time.Now())
+	return err
+}
+
+// Sweep marks devices offline once they missed heartbeats for
+// DEVICE_ONLINE_THRESHOLD, then raises alerts for POS and kiosk devices
+// silent longer than their organization allows. It returns the number of
+// transitions and alerts.
+func (s *DevicePresenceService) Sweep(ctx context.Context) (int64, error) {
+	offline, err := s.eventRepo.MarkOffline(ctx, time.Now().Add(-s.config.Device.OnlineThreshold))
+	if err != nil {
+		return offline, err
+	}
+	alerts, err := s.raiseSilenceAlerts(ctx)
+	return offline + alerts, err
+}
+
+func (s *DevicePresenceService) raiseSilenceAlerts(ctx context.Context) (int64, error) {
+	policies, err := s.policyRepo.FindActive(ctx)
+	if err != nil {
+		return 0, err
+	}
+	thresholds := make(map[int64]time.Duration, len(policies))
+	shortest := s.config.Device.SilenceAlertAfter
+	for _, policy := range policies {
+		threshold := time.Duration(policy.SilenceAlertMinutes) * time.Minute
+		thresholds[policy.OrganizationID] = threshold
+		if threshold > 0 && (shortest == 0 || threshold < shortest) {
+			shortest = threshold
+		}
+	}
+	if shortest == 0 {
+		return 0, nil
+	}
+
+	var platforms []domain.DevicePlatform
+	for _, platform := range domain.AllPlatforms {
+		if domain.IsMonitoredForSilence(platform) {
+			platforms = append(platforms, platform)
+		}
+	}
+
+	now := time.Now()
+	devices, err := s.deviceRepo.FindSilent(ctx, platforms, now.Add(-shortest))
+	if err != nil {
+		return 0, err
+	}
+
+	raised := map[int64][]domain.Device{}
+	var orgIDs []int64
+	var count int64
+	for _, device := range devices {
+		threshold := s.config.Device.SilenceAlertAfter
+		if device.OrganizationID != nil {
+			if orgThreshold, ok := thresholds[*device.OrganizationID]; ok {
+				threshold = orgThreshold
+			}
+		}
+		if threshold == 0 || now.Sub(*device.LastHeartbeat) < threshold {
+			continue
+		}
+
+		alert := &domain.DeviceSilenceAlert{
+			DeviceID:       device.ID,
+			OrganizationID: device.OrganizationID,
+			Platform:       device.Platform,
+			LastSeenAt:     *device.LastHeartbeat,
+		}
+		ok, err := s.alertRepo.Raise(ctx, alert)
+		if err != nil {
+			return count, err
+		}
+		if !ok {
+			continue
+		}
+		count++
+
+		var orgID int64
+		if device.OrganizationID != nil {
+			orgID = *device.OrganizationID
+		}
+		if _, seen := raised[orgID]; !seen {
+			orgIDs = append(orgIDs, orgID)
+		}
+		raised[orgID] = append(raised[orgID], device)
+	}
+
+	for _, orgID := range orgIDs {
+		s.notifySilent(ctx, orgID, raised[orgID])
+	}
+	return count, nil
+}
+
+// notifySilent emails the administrators of an organization (0 for devices
+// outside organizations) about newly silent devices. Failures are logged so
+// one organization's mail problem does not hold up the others.
+func (s *DevicePresenceService) notifySilent(ctx context.Context, orgID int64, devices []domain.Device) {
+	var scope *int64
+	if orgID != 0 {
+		scope = &orgID
+	}
+	admins, err := s.userRepo.FindWithPermission(ctx, scope, DeviceSilenceAlertPermission)
+	if err != nil {
+		log.Printf("Warning: failed to find administrators to alert of silent devices: %v", err)
+		return
+	}
+	if len(admins) == 0 {
+		log.Printf("Warning: no administrator to alert of %d silent devices of organization %d", len(devices), orgID)
+		return
+	}
+
+	to := make([]string, 0, len(admins))
+	for _, admin := range admins {
+		to = append(to, admin.Email)
+	}
+
+	var lines strings.Builder
+	for _, device := range devices {
+		fmt.Fprintf(&lines, "- %s (%s, %s), last seen %s\n",
+			device.Name, device.Platform, device.DeviceUID, device.LastHeartbeat.Format(time.RFC1123))
+	}
+	subject := fmt.Sprintf("Device silent: %s", devices[0].Name)
+	if len(devices) > 1 {
+		subject = fmt.Sprintf("%d devices silent", len(devices))
+	}
+	msg := &mail.Message{
+		To:      to,
+		Subject: subject,
+		Body: "The following devices stopped sending heartbeats:\n\n" + lines.String() +
+			"\nThe alerts resolve on their own when the devices come back online. " +
+			"Open alerts are listed under Devices > Alerts in the admin console.\n",
+	}
+	if err := s.mailSender.Send(ctx, msg); err != nil {
+		log.Printf("Warning: failed to alert administrators of silent devices: %v", err)
+	}
+}
+
+// Stats summarizes the active devices, optionally of one organization
+func (s *DevicePresenceService) Stats(ctx context.Context, orgID *int64) (*DeviceFleetStats, error) {
+	stats := &DeviceFleetStats{}
+	var err error
+
+	if stats.ByPlatform, err = s.deviceRepo.CountByGroup(ctx, "platform", orgID); err != nil {
+		return nil, err
+	}
+	if stats.ByOrganization, err = s.deviceRepo.CountByGroup(ctx, "organization", orgID); err != nil {
+		return nil, err
+	}
+	if stats.ByAppVersion, err = s.deviceRepo.CountByGroup(ctx, "app_version", orgID); err != nil {
+		return nil, err
+	}
+	if stats.OpenAlerts, err = s.alertRepo.CountOpen(ctx, orgID); err != nil {
+		return nil, err
+	}
+
+	for _, count := range stats.ByPlatform {
+		stats.Total += count.Total
+		stats.Online += count.Online
+	}
+	stats.Offline = stats.Total - stats.Online
+	return stats, nil
+}
+
+// ListOnline returns the devices currently online
+func (s *DevicePresenceService) ListOnline(ctx context.Context, orgID *int64, platform string, page, pageSize int) (*repository.PaginatedResult[domain.Device], error) {
+	return s.deviceRepo.FindOnline(ctx, orgID, platform, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// ListEvents returns the presence history of a device, newest first
+func (s *DevicePresenceService) ListEvents(ctx context.Context, deviceID int64, page, pageSize int) (*repository.PaginatedResult[domain.DevicePresenceEvent], error) {
+	return s.eventRepo.FindByDevice(ctx, deviceID, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// ListAlerts returns silence alerts, newest first
+func (s *DevicePresenceService) ListAlerts(ctx context.Context, orgID *int64, openOnly bool, page, pageSize int) (*repository.PaginatedResult[domain.DeviceSilenceAlert], error) {
+	return s.alertRepo.FindFiltered(ctx, orgID, openOnly, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// DeviceUptime reports the availability of one device over [from, to),
+// listing its outages
+func (s *DevicePresenceService) DeviceUptime(ctx context.Context, deviceID int64, from, to time.Time) (*DeviceUptime, error) {
+	device, err := s.deviceRepo.FindByID(ctx, deviceID)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	uptimes, err := s.uptime(ctx, []domain.Device{*device}, from, to, true)
+	if err != nil {
+		return nil, err
+	}
+	return &uptimes[0], nil
+}
+
+// FleetUptime reports the availability of the active devices of an
+// organization, a platform or both over [from, to)
+func (s *DevicePresenceService) FleetUptime(ctx context.Context, orgID *int64, platform string, from, to time.Time) (*FleetUptime, error) {
+	devices, err := s.deviceRepo.FindForReport(ctx, orgID, platform)
+	if err != nil {
+		return nil, err
+	}
+	uptimes, err := s.uptime(ctx, devices, from, to, false)
+	if err != nil {
+		return nil, err
+	}
+
+	fleet := &FleetUptime{From: from, To: to, Devices: uptimes}
+	for _, uptime := range uptimes {
+		fleet.MonitoredSeconds += uptime.MonitoredSeconds
+		fleet.OnlineSeconds += uptime.OnlineSeconds
+	}
+	fleet.UptimePercent = uptimePercent(fleet.OnlineSeconds, fleet.MonitoredSeconds)
+	return fleet, nil
+}
+
+// uptime replays the presence events of devices over [from, to), starting
+// from the state each was in at from
+func (s *DevicePresenceService) uptime(ctx context.Context, devices []domain.Device, from, to time.Time, withOutages bool) ([]DeviceUptime, error) {
+	if !from.Before(to) || to.Sub(from) > maxUptimeRange {
+		return nil, ErrInvalidUptimeRange
+	}
+	now := time.Now()
+	if to.After(now) {
+		to = now
+	}
+
+	ids := make([]int64, len(devices))
+	for i := range devices {
+		ids[i] = devices[i].ID
+	}
+	initial, err := s.eventRepo.FindLastBefore(ctx, ids, from)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.eventRepo.FindBetween(ctx, ids, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	initialStatus := make(map[int64]string, len(initial))
+	for _, event := range initial {
+		initialStatus[event.DeviceID] = event.Status
+	}
+	byDevice := make(map[int64][]domain.DevicePresenceEvent)
+	for _, event := range events {
+		byDevice[event.DeviceID] = append(byDevice[event.DeviceID], event)
+	}
+
+	uptimes := make([]DeviceUptime, len(devices))
+	for i, device := range devices {
+		uptime := DeviceUptime{DeviceID: device.ID, DeviceName: device.Name, Platform: string(device.Platform)}
+		status, start := initialStatus[device.ID], from
+		var outage *DeviceOutage
+
+		account := func(until time.Time) {
+			if status == "" || !until.After(start) {
+				return
+			}
+			seconds := int64(until.Sub(start).Seconds())
+			uptime.MonitoredSeconds += seconds
+			if status == domain.DevicePresenceOnline {
+				uptime.OnlineSeconds += seconds
+			} else {
+				uptime.OfflineSeconds += seconds
+			}
+		}
+
+		if status == domain.DevicePresenceOffline {
+			outage = &DeviceOutage{From: from}
+		}
+		for _, event := range byDevice[device.ID] {
+			account(event.OccurredAt)
+			if event.Status == domain.DevicePresenceOffline && outage == nil {
+				outage = &DeviceOutage{From: event.OccurredAt}
+			}
+			if event.Status == domain.DevicePresenceOnline && outage != nil {
+				end := event.OccurredAt
+				outage.To = &end
+				outage.DurationSeconds = int64(end.Sub(outage.From).Seconds())
+				uptime.Outages = append(uptime.Outages, *outage)
+				outage = nil
+			}
+			status, start = event.Status, event.OccurredAt
+		}
+		account(to)
+		if outage != nil {
+			outage.DurationSeconds = int64(to.Sub(outage.From).Seconds())
+			uptime.Outages = append(uptime.Outages, *outage)
+		}
+
+		uptime.UptimePercent = uptimePercent(uptime.OnlineSeconds, uptime.MonitoredSeconds)
+		if !withOutages {
+			uptime.Outages = nil
+		}
+		uptimes[i] = uptime
+	}
+	return uptimes, nil
+}
+
+func uptimePercent(online, monitored int64) float64 {
+	if monitored == 0 {
+		return 0
+	}
+	return float64(online*10000/monitored) / 100
+}
+
+// ListPolicies returns device presence policies, optionally of one organization
+func (s *DevicePresenceService) ListPolicies(ctx context.Context, orgID *int64) ([]domain.DevicePresencePolicy, error) {
+	return s.policyRepo.FindFiltered(ctx, orgID)
+}
+
+// CreatePolicy creates the presence policy of an organization
+func (s *DevicePresenceService) CreatePolicy(ctx context.Context, req *DevicePresencePolicyRequest, createdBy int64) (*domain.DevicePresencePolicy, error) {
+	if _, err := s.orgRepo.FindByID(ctx, req.OrganizationID); err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+	if _, err := s.policyRepo.FindByOrganization(ctx, req.OrganizationID); err == nil {
+		return nil, ErrDevicePresencePolicyExists
+	} else if !repository.IsNotFound(err) {
+		return nil, err
+	}
+
+	policy := &domain.DevicePresencePolicy{
+		OrganizationID:      req.OrganizationID,
+		SilenceAlertMinutes: req.SilenceAlertMinutes,
+		IsActive:            req.IsActive,
+	}
+	if policy.IsActive == nil {
+		policy.IsActive = domain.Ptr(true)
+	}
+	policy.CreatedBy = &createdBy
+
+	if err := s.policyRepo.Create(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// UpdatePolicy replaces a presence policy; its organization cannot change
+func (s *DevicePresenceService) UpdatePolicy(ctx context.Context, id int64, req *DevicePresencePolicyRequest, updatedBy int64) (*domain.DevicePresencePolicy, error) {
+	policy, err := s.policyRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrDevicePresencePolicyNotFound
+	}
+
+	policy.SilenceAlertMinutes = req.SilenceAlertMinutes
+	if req.IsActive != nil {
+		policy.IsActive = req.IsActive
+	}
+	policy.UpdatedBy = &updatedBy
+
+	if err := s.policyRepo.Update(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// DeletePolicy deletes a presence policy; the organization falls back to
+// DEVICE_SILENCE_ALERT_AFTER
+func (s *DevicePresenceService) DeletePolicy(ctx context.Context, id int64) error {
+	if _, err := s.policyRepo.FindByID(ctx, id); err != nil {
+		return ErrDevicePresencePolicyNotFound
+	}
+	return s.policyRepo.Delete(ctx, id)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"gebase/internal/auth"
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// newDeviceTestService registers devices without keys, enrolling them
+// automatically
+func newDeviceTestService(t *testing.T) (*DeviceService, *gorm.DB) {
+	t.Helper()
+
+	db := newTestDB(t, &domain.Device{}, &domain.DeviceEnrollmentPolicy{}, &domain.Session{})
+	cfg := &config.Config{Device: config.DeviceConfig{EnrollmentMode: domain.DeviceEnrollmentAuto}}
+	deviceRepo := repository.NewDeviceRepository(db)
+	enrollment := NewDeviceEnrollmentService(cfg, repository.NewDeviceEnrollmentPolicyRepository(db), deviceRepo, nil, nil, nil, nil)
+	return NewDeviceService(cfg, deviceRepo, repository.NewSessionRepository(db), enrollment, nil, nil, nil,
+		auth.NewDeviceProofVerifier(cfg, nil)), db
+}
+
+func TestRegisterDeviceKeepsPushTokenWithoutSession(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   *auth.Claims
+		wantPush string
+	}{
+		{"no session", nil, "token-1"},
+		{"session on another device", &auth.Claims{DeviceID: 99, TokenType: auth.TokenTypePlatform}, "token-1"},
+		{"session on the device", &auth.Claims{DeviceID: 1, TokenType: auth.TokenTypePlatform}, "token-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, db := newDeviceTestService(t)
+			ctx := context.Background()
+			req := &RegisterDeviceRequest{DeviceUID: "device-1", Name: "Phone", Platform: domain.PlatformAndroid, PushToken: "token-1"}
+			device, err := s.RegisterDevice(ctx, req, nil, nil)
+			if err != nil {
+				t.Fatalf("RegisterDevice: %v", err)
+			}
+			if device.ID != 1 {
+				t.Fatalf("device ID = %d, want 1", device.ID)
+			}
+
+			req = &RegisterDeviceRequest{DeviceUID: "device-1", Name: "Phone", Platform: domain.PlatformIOS, PushToken: "token-2"}
+			if _, err := s.RegisterDevice(ctx, req, nil, tt.claims); err != nil {
+				t.Fatalf("re-registration: %v", err)
+			}
+
+			var stored domain.Device
+			if err := db.First(&stored, device.ID).Error; err != nil {
+				t.Fatalf("find device: %v", err)
+			}
+			if stored.PushToken != tt.wantPush {
+				t.Fatalf("push token = %q, want %q", stored.PushToken, tt.wantPush)
+			}
+			wantPlatform := domain.PlatformAndroid
+			if tt.wantPush == "token-2" {
+				wantPlatform = domain.PlatformIOS
+			}
+			if stored.Platform != wantPlatform {
+				t.Fatalf("platform = %s, want %s", stored.Platform, wantPlatform)
+			}
+		})
+	}
+}
+
+func TestFindPushTargetsOfOrganizationSkipsDeclaredOrganization(t *testing.T) {
+	_, db := newDeviceTestService(t)
+	orgID, reviewer := int64(5), int64(1)
+	for _, record := range []interface{}{
+		// Declared by the client only
+		&domain.Device{ID: 1, DeviceUID: "declared", OrganizationID: &orgID},
+		// A member signed in on it
+		&domain.Device{ID: 2, DeviceUID: "signed-in", OrganizationID: &orgID},
+		&domain.Session{SessionToken: "session-1", UserID: 1, DeviceID: 2, OrganizationID: &orgID},
+		// An administrator approved it for the organization
+		&domain.Device{ID: 3, DeviceUID: "reviewed", OrganizationID: &orgID, ReviewedBy: &reviewer},
+	} {
+		if device, ok := record.(*domain.Device); ok {
+			device.PushToken = "token-" + device.DeviceUID
+			device.IsActive = domain.Ptr(true)
+			device.ApprovalStatus = domain.DeviceApprovalApproved
+		}
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("create %T: %v", record, err)
+		}
+	}
+
+	devices, err := repository.NewDeviceRepository(db).FindPushTargets(context.Background(), domain.PushTargetOrganization, orgID, "")
+	if err != nil {
+		t.Fatalf("FindPushTargets: %v", err)
+	}
+	if len(devices) != 2 || devices[0].ID != 2 || devices[1].ID != 3 {
+		t.Fatalf("push targets = %+v, want devices 2 and 3", devices)
+	}
+}
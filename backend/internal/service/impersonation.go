@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gebase/internal/auth"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+)
+
+// ImpersonatePermission allows starting a session as another user
+const ImpersonatePermission = "admin.user.impersonate"
+
+var (
+	ErrCannotImpersonateSelf  = errors.New("cannot impersonate yourself")
+	ErrNestedImpersonation    = errors.New("cannot impersonate while impersonating")
+	ErrImpersonationForbidden = errors.New("user cannot be impersonated")
+	ErrNotImpersonating       = errors.New("session is not an impersonation session")
+)
+
+// ImpersonationService lets administrators act as another user to see what
+// they see. Sessions are short-lived, carry the administrator in their
+// claims and are audited against both users.
+type ImpersonationService struct {
+	userRepo       *repository.UserRepository
+	permissionRepo *repository.PermissionRepository
+	eventRepo      *repository.ImpersonationEventRepository
+	jwtService     *auth.JWTService
+	sessionService *auth.SessionService
+}
+
+func NewImpersonationService(
+	userRepo *repository.UserRepository,
+	permissionRepo *repository.PermissionRepository,
+	eventRepo *repository.ImpersonationEventRepository,
+	jwtService *auth.JWTService,
+	sessionService *auth.SessionService,
+) *ImpersonationService {
+	return &ImpersonationService{
+		userRepo:       userRepo,
+		permissionRepo: permissionRepo,
+		eventRepo:      eventRepo,
+		jwtService:     jwtService,
+		sessionService: sessionService,
+	}
+}
+
+type StartImpersonationRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// ImpersonationInfo describes an active impersonation for the UI banner
+type ImpersonationInfo struct {
+	Impersonator *domain.User `json:"impersonator"`
+	ExpiresAt    time.Time    `json:"expires_at"`
+}
+
+// CurrentUser is the /auth/me payload: the user plus the impersonation
+// banner state
+type CurrentUser struct {
+	*domain.User
+	IsImpersonated bool               `json:"is_impersonated"`
+	Impersonation  *ImpersonationInfo `json:"impersonation,omitempty"`
+}
+
+// Start opens a session as targetID for the administrator in claims, on the
+// administrator's device. Users who may impersonate themselves, or who hold
+// any permission the administrator lacks in some system, cannot be
+// impersonated, so the feature cannot be used to gain access.
+func (s *ImpersonationService) Start(ctx context.Context, claims *auth.Claims, targetID int64, req *StartImpersonationRequest, ipAddress, userAgent string) (*LoginResponse, error) {
+	if claims.IsImpersonated() {
+		return nil, ErrNestedImpersonation
+	}
+	if claims.UserID == targetID {
+		return nil, ErrCannotImpersonateSelf
+	}
+
+	target, err := s.userRepo.FindByID(ctx, targetID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if target.IsActive == nil || !*target.IsActive {
+		return nil, ErrUserInactive
+	}
+
+	privileged, err := s.permissionRepo.CheckUserPermission(ctx, target.ID, claims.SystemID, ImpersonatePermission)
+	if err != nil {
+		return nil, err
+	}
+	if privileged {
+		return nil, ErrImpersonationForbidden
+	}
+	exceeds, err := s.exceedsImpersonator(ctx, claims.UserID, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	if exceeds {
+		return nil, ErrImpersonationForbidden
+	}
+
+	session, err := s.sessionService.CreateImpersonationSession(ctx, target.ID, claims.UserID, claims.DeviceID, ipAddress, userAgent, target.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.jwtService.GeneratePlatformToken(target, session)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, refreshClaims, err := s.jwtService.GenerateRefreshToken(target, session)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.sessionService.IssueRefreshToken(ctx, refreshClaims, nil); err != nil {
+		return nil, err
+	}
+
+	if err := s.record(ctx, session, domain.ImpersonationStarted, req.Reason, ipAddress, userAgent); err != nil {
+		return nil, err
+	}
+
+	userWithRoles, err := s.userRepo.FindWithRoles(ctx, target.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		AccessToken:            accessToken,
+		RefreshToken:           refreshToken,
+		TokenType:              "Bearer",
+		ExpiresIn:              int64(time.Until(session.ExpiresAt).Seconds()),
+		User:                   target,
+		Systems:                userWithRoles.GetAvailableSystems(),
+		PasswordChangeRequired: target.MustChangePassword != nil && *target.MustChangePassword,
+	}, nil
+}
+
+// exceedsImpersonator reports whether the target holds a permission the
+// impersonator does not, in any system
+func (s *ImpersonationService) exceedsImpersonator(ctx context.Context, impersonatorID, targetID int64) (bool, error) {
+	held, err := s.permissionRepo.FindUserPermissionGrants(ctx, impersonatorID)
+	if err != nil {
+		return false, err
+	}
+	platform := make(map[string]bool)
+	bySystem := make(map[int]map[string]bool)
+	for _, grant := range held {
+		if grant.SystemID == nil {
+			platform[grant.Code] = true
+			continue
+		}
+		if bySystem[*grant.SystemID] == nil {
+			bySystem[*grant.SystemID] = make(map[string]bool)
+		}
+		bySystem[*grant.SystemID][grant.Code] = true
+	}
+
+	grants, err := s.permissionRepo.FindUserPermissionGrants(ctx, targetID)
+	if err != nil {
+		return false, err
+	}
+	for _, grant := range grants {
+		if platform[grant.Code] {
+			continue
+		}
+		// A platform-wide grant applies in every system, so only a
+		// platform-wide grant of the impersonator's covers it
+		if grant.SystemID == nil || !bySystem[*grant.SystemID][grant.Code] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Stop ends the impersonation session in claims. The administrator's own
+// session is untouched.
+func (s *ImpersonationService) Stop(ctx context.Context, claims *auth.Claims, ipAddress, userAgent string) error {
+	if !claims.IsImpersonated() {
+		return ErrNotImpersonating
+	}
+
+	session, err := s.sessionService.GetSessionByID(ctx, claims.SessionID)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+	if err := s.sessionService.Logout(ctx, session.ID, auth.LogoutReasonImpersonationEnded); err != nil {
+		return err
+	}
+
+	return s.record(ctx, session, domain.ImpersonationStopped, "", ipAddress, userAgent)
+}
+
+// GetCurrentUser returns the user in claims with the impersonation banner state
+func (s *ImpersonationService) GetCurrentUser(ctx context.Context, claims *auth.Claims) (*CurrentUser, error) {
+	user, err := s.userRepo.FindWithRoles(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := &CurrentUser{User: user}
+	if !claims.IsImpersonated() {
+		return current, nil
+	}
+
+	impersonator, err := s.userRepo.FindByID(ctx, *claims.ImpersonatorID)
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.sessionService.GetSessionByID(ctx, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	current.IsImpersonated = true
+	current.Impersonation = &ImpersonationInfo{
+		Impersonator: impersonator,
+		ExpiresAt:    session.ExpiresAt,
+	}
+	return current, nil
+}
+
+// ListEvents returns the impersonation audit trail of a user, both as
+// administrator and as target
+func (s *ImpersonationService) ListEvents(ctx context.Context, userID int64, page, pageSize int) (*repository.PaginatedResult[domain.ImpersonationEvent], error) {
+	return s.eventRepo.FindByUser(ctx, userID, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+func (s *ImpersonationService) record(ctx context.Context, session *domain.Session, event, reason, ipAddress, userAgent string) error {
+	return s.eventRepo.Create(ctx, &domain.ImpersonationEvent{
+		SessionID:      session.ID,
+		ImpersonatorID: *session.ImpersonatorID,
+		TargetUserID:   session.UserID,
+		Event:          event,
+		Reason:         reason,
+		IPAddress:      ipAddress,
+		UserAgent:      userAgent,
+	})
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gebase/internal/auth"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+)
+
+// newImpersonationTestService gives administrator 1 and target 2 the roles
+// in assignments; role 10 holds admin.user.view and role 11 admin.user.view
+// and admin.role.update
+func newImpersonationTestService(t *testing.T, assignments ...domain.UserSystemRole) *ImpersonationService {
+	t.Helper()
+
+	db := newTestDB(t, &domain.User{}, &domain.Role{}, &domain.Permission{}, &domain.RolePermission{}, &domain.UserSystemRole{})
+	records := []interface{}{
+		&domain.User{ID: 2, Email: "target@example.com", IsActive: domain.Ptr(true)},
+		&domain.Role{ID: 10, Code: "viewer"},
+		&domain.Role{ID: 11, Code: "role_admin"},
+		&domain.Permission{ID: 1, Code: "admin.user.view", IsActive: domain.Ptr(true)},
+		&domain.Permission{ID: 2, Code: "admin.role.update", IsActive: domain.Ptr(true)},
+		&domain.RolePermission{RoleID: 10, PermissionID: 1},
+		&domain.RolePermission{RoleID: 11, PermissionID: 1},
+		&domain.RolePermission{RoleID: 11, PermissionID: 2},
+	}
+	for i := range assignments {
+		records = append(records, &assignments[i])
+	}
+	for _, record := range records {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("create %T: %v", record, err)
+		}
+	}
+
+	return NewImpersonationService(repository.NewUserRepository(db), repository.NewPermissionRepository(db), nil, nil, nil)
+}
+
+func TestImpersonationRefusesTargetsExceedingImpersonator(t *testing.T) {
+	system1, system2 := 1, 2
+	tests := []struct {
+		name        string
+		assignments []domain.UserSystemRole
+		want        bool
+	}{
+		{"same role", []domain.UserSystemRole{
+			{UserID: 1, SystemID: &system1, RoleID: 11},
+			{UserID: 2, SystemID: &system1, RoleID: 10},
+		}, false},
+		{"platform role covers every system", []domain.UserSystemRole{
+			{UserID: 1, RoleID: 11},
+			{UserID: 2, SystemID: &system2, RoleID: 11},
+		}, false},
+		{"more permissions", []domain.UserSystemRole{
+			{UserID: 1, SystemID: &system1, RoleID: 10},
+			{UserID: 2, SystemID: &system1, RoleID: 11},
+		}, true},
+		{"another system", []domain.UserSystemRole{
+			{UserID: 1, SystemID: &system1, RoleID: 11},
+			{UserID: 2, SystemID: &system2, RoleID: 10},
+		}, true},
+		{"platform role against a system role", []domain.UserSystemRole{
+			{UserID: 1, SystemID: &system1, RoleID: 11},
+			{UserID: 2, RoleID: 10},
+		}, true},
+		{"inactive assignment", []domain.UserSystemRole{
+			{UserID: 1, SystemID: &system1, RoleID: 10},
+			{UserID: 2, SystemID: &system1, RoleID: 10},
+			{UserID: 2, SystemID: &system1, RoleID: 11, IsActive: domain.Ptr(false)},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newImpersonationTestService(t, tt.assignments...)
+			exceeds, err := s.exceedsImpersonator(context.Background(), 1, 2)
+			if err != nil {
+				t.Fatalf("exceedsImpersonator: %v", err)
+			}
+			if exceeds != tt.want {
+				t.Fatalf("exceedsImpersonator = %v, want %v", exceeds, tt.want)
+			}
+		})
+	}
+}
+
+func TestImpersonationStartRefusesMorePrivilegedTarget(t *testing.T) {
+	system1 := 1
+	s := newImpersonationTestService(t,
+		domain.UserSystemRole{UserID: 1, SystemID: &system1, RoleID: 10},
+		domain.UserSystemRole{UserID: 2, SystemID: &system1, RoleID: 11},
+	)
+
+	claims := &auth.Claims{UserID: 1, SystemID: &system1}
+	_, err := s.Start(context.Background(), claims, 2, &StartImpersonationRequest{Reason: "support"}, "127.0.0.1", "test")
+	if !errors.Is(err, ErrImpersonationForbidden) {
+		t.Fatalf("Start error = %v, want ErrImpersonationForbidden", err)
+	}
+}
@@ -0,0 +1,422 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"gebase/internal/auth"
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+var (
+	ErrLDAPNotConfigured     = errors.New("ldap is not configured for this organization")
+	ErrDirectoryUnavailable  = errors.New("directory server is unavailable")
+	ErrInvalidLDAPURL        = errors.New("ldap url must use the ldap:// or ldaps:// scheme")
+	ErrLDAPMappingNotFound   = errors.New("ldap group mapping not found")
+	ErrPasswordManagedByLDAP = errors.New("password is managed by the organization directory")
+)
+
+const defaultLDAPUserFilter = "(mail={email})"
+
+// LDAPService authenticates users of organizations whose security policy
+// selects the ldap backend: it finds the user's entry with a service account,
+// binds as that entry with the supplied password and maps the entry's groups
+// to roles.
+type LDAPService struct {
+	config             *config.Config
+	configRepo         *repository.OrganizationLDAPConfigRepository
+	mappingRepo        *repository.LDAPGroupRoleMappingRepository
+	orgRepo            *repository.OrganizationRepository
+	roleRepo           *repository.RoleRepository
+	userSystemRoleRepo *repository.UserSystemRoleRepository
+	revocationService  *auth.RevocationService
+	secretBox          *auth.SecretBox
+	permissionCache    *PermissionCache
+}
+
+func NewLDAPService(
+	cfg *config.Config,
+	configRepo *repository.OrganizationLDAPConfigRepository,
+	mappingRepo *repository.LDAPGroupRoleMappingRepository,
+	orgRepo *repository.OrganizationRepository,
+	roleRepo *repository.RoleRepository,
+	userSystemRoleRepo *repository.UserSystemRoleRepository,
+	revocationService *auth.RevocationService,
+	secretBox *auth.SecretBox,
+	permissionCache *PermissionCache,
+) *LDAPService {
+	return &LDAPService{
+		config:             cfg,
+		configRepo:         configRepo,
+		mappingRepo:        mappingRepo,
+		orgRepo:            orgRepo,
+		roleRepo:           roleRepo,
+		userSystemRoleRepo: userSystemRoleRepo,
+		revocationService:  revocationService,
+		secretBox:          secretBox,
+		permissionCache:    permissionCache,
+	}
+}
+
+type UpdateLDAPConfigRequest struct {
+	URL                string `json:"url" binding:"required"`
+	StartTLS           *bool  `json:"start_tls"`
+	InsecureSkipVerify *bool  `json:"insecure_skip_verify"`
+	BindDN             string `json:"bind_dn"`
+	// BindPassword is only changed when set; an empty value keeps the current one
+	BindPassword   string `json:"bind_password"`
+	BaseDN         string `json:"base_dn" binding:"required"`
+	UserFilter     string `json:"user_filter"`
+	GroupAttribute string `json:"group_attribute"`
+	SyncRoles      *bool  `json:"sync_roles"`
+}
+
+type CreateLDAPGroupMappingRequest struct {
+	GroupDN string `json:"group_dn" binding:"required"`
+	RoleID  int    `json:"role_id" binding:"required"`
+}
+
+// Authenticate implements Authenticator
+func (s *LDAPService) Authenticate(ctx context.Context, user *domain.User, password string) error {
+	if user.OrganizationID == nil {
+		return ErrLDAPNotConfigured
+	}
+	cfg, err := s.configRepo.FindByOrganizationID(ctx, *user.OrganizationID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return ErrLDAPNotConfigured
+		}
+		return err
+	}
+
+	// An empty password would be an unauthenticated bind, which succeeds
+	if password == "" {
+		return ErrInvalidCredentials
+	}
+
+	conn, err := s.connect(cfg)
+	if err != nil {
+		log.Printf("Warning: ldap connection for organization %d failed: %v", cfg.OrganizationID, err)
+		return ErrDirectoryUnavailable
+	}
+	defer conn.Close()
+
+	entry, err := s.findUser(conn, cfg, user.Email)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			return err
+		}
+		log.Printf("Warning: ldap search for organization %d failed: %v", cfg.OrganizationID, err)
+		return ErrDirectoryUnavailable
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return ErrInvalidCredentials
+		}
+		log.Printf("Warning: ldap bind for organization %d failed: %v", cfg.OrganizationID, err)
+		return ErrDirectoryUnavailable
+	}
+
+	if cfg.SyncRoles != nil && *cfg.SyncRoles {
+		// A failed sync keeps the previous roles rather than blocking the login
+		groups := entry.GetAttributeValues(groupAttribute(cfg))
+		if err := s.syncRoles(ctx, user, groups); err != nil {
+			log.Printf("Warning: failed to sync ldap roles of user %d: %v", user.ID, err)
+		}
+	}
+	return nil
+}
+
+// IsLocal implements Authenticator
+func (s *LDAPService) IsLocal() bool {
+	return false
+}
+
+func (s *LDAPService) connect(cfg *domain.OrganizationLDAPConfig) (*ldap.Conn, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: cfg.InsecureSkipVerify != nil && *cfg.InsecureSkipVerify,
+	}
+
+	timeout := s.config.LDAP.Timeout
+	conn, err := ldap.DialURL(cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+
+	if cfg.StartTLS != nil && *cfg.StartTLS && u.Scheme == "ldap" {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// findUser binds as the service account and returns the single entry
+// matching the user filter
+func (s *LDAPService) findUser(conn *ldap.Conn, cfg *domain.OrganizationLDAPConfig, email string) (*ldap.Entry, error) {
+	if cfg.BindDN != "" {
+		bindPassword := ""
+		if cfg.BindPassword != "" {
+			plain, err := s.secretBox.Open(cfg.BindPassword)
+			if err != nil {
+				return nil, err
+			}
+			bindPassword = string(plain)
+		}
+		if err := conn.Bind(cfg.BindDN, bindPassword); err != nil {
+			return nil, err
+		}
+	}
+
+	username := email
+	if at := strings.LastIndex(email, "@"); at > 0 {
+		username = email[:at]
+	}
+	filter := cfg.UserFilter
+	if filter == "" {
+		filter = defaultLDAPUserFilter
+	}
+	filter = strings.NewReplacer(
+		"{email}", ldap.EscapeFilter(email),
+		"{username}", ldap.EscapeFilter(username),
+	).Replace(filter)
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		cfg.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2, // more than one match is ambiguous
+		int(s.config.LDAP.Timeout.Seconds()),
+		false,
+		filter,
+		[]string{"dn", groupAttribute(cfg)},
+		nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if len(result.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	return result.Entries[0], nil
+}
+
+// syncRoles makes the directory authoritative for the systems that have group
+// mappings: the user's roles in each of them are replaced by the roles mapped
+// from their groups. Roles in other systems are left alone.
+func (s *LDAPService) syncRoles(ctx context.Context, user *domain.User, groups []string) error {
+	mappings, err := s.mappingRepo.FindByOrganizationID(ctx, *user.OrganizationID)
+	if err != nil || len(mappings) == 0 {
+		return err
+	}
+
+	// Keyed by system ID, with 0 for platform roles
+	systems := make(map[int]*int)
+	desired := make(map[int][]int)
+	for _, mapping := range mappings {
+		key := systemKey(mapping.SystemID)
+		systems[key] = mapping.SystemID
+		for _, group := range groups {
+			if mapping.Matches(group) {
+				desired[key] = appendUniqueInt(desired[key], mapping.RoleID)
+				break
+			}
+		}
+	}
+
+	for key, systemID := range systems {
+		current, err := s.userSystemRoleRepo.FindByUserAndSystem(ctx, user.ID, systemID)
+		if err != nil {
+			return err
+		}
+		currentIDs := make([]int, 0, len(current))
+		for _, usr := range current {
+			currentIDs = appendUniqueInt(currentIDs, usr.RoleID)
+		}
+		if sameInts(currentIDs, desired[key]) {
+			continue
+		}
+
+		if err := s.userSystemRoleRepo.AssignRoles(ctx, user.ID, systemID, desired[key], user.OrganizationID, user.ID); err != nil {
+			return err
+		}
+		if err := s.permissionCache.InvalidateUser(ctx, user.ID); err != nil {
+			return err
+		}
+		if err := s.revocationService.RevokeSystemTokensForUser(ctx, user.ID, systemID, auth.RevokeReasonRolesChanged); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetConfig returns the LDAP settings of an organization
+func (s *LDAPService) GetConfig(ctx context.Context, orgID int64) (*domain.OrganizationLDAPConfig, error) {
+	if _, err := s.orgRepo.FindByID(ctx, orgID); err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+
+	cfg, err := s.configRepo.FindByOrganizationID(ctx, orgID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, ErrLDAPNotConfigured
+		}
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// UpdateConfig creates or replaces the LDAP settings of an organization
+func (s *LDAPService) UpdateConfig(ctx context.Context, orgID int64, req *UpdateLDAPConfigRequest, updatedBy int64) (*domain.OrganizationLDAPConfig, error) {
+	cfg, err := s.GetConfig(ctx, orgID)
+	if err == ErrLDAPNotConfigured {
+		cfg = &domain.OrganizationLDAPConfig{OrganizationID: orgID}
+	} else if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		return nil, ErrInvalidLDAPURL
+	}
+
+	cfg.URL = req.URL
+	cfg.StartTLS = req.StartTLS
+	cfg.InsecureSkipVerify = req.InsecureSkipVerify
+	cfg.BindDN = req.BindDN
+	cfg.BaseDN = req.BaseDN
+	cfg.UserFilter = req.UserFilter
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = defaultLDAPUserFilter
+	}
+	cfg.GroupAttribute = req.GroupAttribute
+	cfg.SyncRoles = req.SyncRoles
+	if req.BindPassword != "" {
+		sealed, err := s.secretBox.Seal([]byte(req.BindPassword))
+		if err != nil {
+			return nil, err
+		}
+		cfg.BindPassword = sealed
+	}
+	if req.BindDN == "" {
+		cfg.BindPassword = ""
+	}
+
+	if cfg.ID == 0 {
+		cfg.CreatedBy = &updatedBy
+		err = s.configRepo.Create(ctx, cfg)
+	} else {
+		cfg.UpdatedBy = &updatedBy
+		err = s.configRepo.Update(ctx, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ListGroupMappings returns the group to role mappings of an organization
+func (s *LDAPService) ListGroupMappings(ctx context.Context, orgID int64) ([]domain.LDAPGroupRoleMapping, error) {
+	if _, err := s.orgRepo.FindByID(ctx, orgID); err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+	return s.mappingRepo.FindByOrganizationID(ctx, orgID)
+}
+
+// CreateGroupMapping maps a directory group to a role. The mapping applies to
+// the role's system.
+func (s *LDAPService) CreateGroupMapping(ctx context.Context, orgID int64, req *CreateLDAPGroupMappingRequest, createdBy int64) (*domain.LDAPGroupRoleMapping, error) {
+	if _, err := s.orgRepo.FindByID(ctx, orgID); err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+	role, err := s.roleRepo.FindByID(ctx, req.RoleID)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+
+	mapping := &domain.LDAPGroupRoleMapping{
+		OrganizationID: orgID,
+		GroupDN:        strings.TrimSpace(req.GroupDN),
+		RoleID:         role.ID,
+		SystemID:       role.SystemID,
+	}
+	mapping.CreatedBy = &createdBy
+
+	if err := s.mappingRepo.Create(ctx, mapping); err != nil {
+		return nil, err
+	}
+	mapping.Role = role
+	return mapping, nil
+}
+
+// DeleteGroupMapping removes a group mapping of an organization
+func (s *LDAPService) DeleteGroupMapping(ctx context.Context, orgID, mappingID int64) error {
+	mapping, err := s.mappingRepo.FindByID(ctx, mappingID)
+	if err != nil || mapping.OrganizationID != orgID {
+		return ErrLDAPMappingNotFound
+	}
+	return s.mappingRepo.Delete(ctx, mappingID)
+}
+
+func groupAttribute(cfg *domain.OrganizationLDAPConfig) string {
+	if cfg.GroupAttribute == "" {
+		return "memberOf"
+	}
+	return cfg.GroupAttribute
+}
+
+func systemKey(systemID *int) int {
+	if systemID == nil {
+		return 0
+	}
+	return *systemID
+}
+
+func appendUniqueInt(values []int, value int) []int {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func sameInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]int(nil), a...)
+	b = append([]int(nil), b...)
+	sort.Ints(a)
+	sort.Ints(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// testLDAPEntry is a directory entry; Password is what binding as it takes
+type testLDAPEntry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// testLDAPServer is an in-process directory speaking enough LDAPv3 for the
+// LDAP authenticator: simple binds and subtree searches with equality,
+// presence, substring, and, or and not filters, honouring the size limit.
+// Attribute names and values compare case-insensitively.
+type testLDAPServer struct {
+	listener net.Listener
+	entries  []testLDAPEntry
+
+	mu      sync.Mutex
+	binds   []string
+	filters []string
+}
+
+func newTestLDAPServer(t *testing.T, entries ...testLDAPEntry) *testLDAPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &testLDAPServer{listener: listener, entries: entries}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testLDAPServer) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+// Binds returns the DNs bound as, in order
+func (s *testLDAPServer) Binds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.binds...)
+}
+
+// Filters returns the search filters received, in their string form
+func (s *testLDAPServer) Filters() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.filters...)
+}
+
+func (s *testLDAPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			code := s.bind(op)
+			s.write(conn, messageID, ldap.ApplicationBindResponse, code)
+		case ldap.ApplicationSearchRequest:
+			entries, code := s.search(op)
+			for _, entry := range entries {
+				if _, err := conn.Write(entry(messageID).Bytes()); err != nil {
+					return
+				}
+			}
+			s.write(conn, messageID, ldap.ApplicationSearchResultDone, code)
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			s.write(conn, messageID, ldap.ApplicationExtendedResponse, ldap.LDAPResultUnwillingToPerform)
+		}
+	}
+}
+
+func (s *testLDAPServer) bind(op *ber.Packet) uint16 {
+	dn := berString(op.Children[1])
+	password := berString(op.Children[2])
+
+	s.mu.Lock()
+	s.binds = append(s.binds, dn)
+	s.mu.Unlock()
+
+	if dn == "" && password == "" {
+		return ldap.LDAPResultSuccess
+	}
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) && password != "" && entry.Password == password {
+			return ldap.LDAPResultSuccess
+		}
+	}
+	return ldap.LDAPResultInvalidCredentials
+}
+
+// search returns the matching entries, cut at the size limit
+func (s *testLDAPServer) search(op *ber.Packet) ([]func(int64) *ber.Packet, uint16) {
+	baseDN := strings.ToLower(berString(op.Children[0]))
+	sizeLimit := int(op.Children[3].Value.(int64))
+	filter := op.Children[6]
+	var requested []string
+	for _, attribute := range op.Children[7].Children {
+		requested = append(requested, berString(attribute))
+	}
+
+	if compiled, err := ldap.DecompileFilter(filter); err == nil {
+		s.mu.Lock()
+		s.filters = append(s.filters, compiled)
+		s.mu.Unlock()
+	}
+
+	var results []func(int64) *ber.Packet
+	for _, entry := range s.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.DN), baseDN) {
+			continue
+		}
+		matched, err := matchFilter(filter, entry)
+		if err != nil {
+			return nil, ldap.LDAPResultProtocolError
+		}
+		if !matched {
+			continue
+		}
+		if sizeLimit > 0 && len(results) == sizeLimit {
+			return results, ldap.LDAPResultSizeLimitExceeded
+		}
+		entry := entry
+		results = append(results, func(messageID int64) *ber.Packet {
+			return searchResultEntry(messageID, entry, requested)
+		})
+	}
+	return results, ldap.LDAPResultSuccess
+}
+
+func (s *testLDAPServer) write(conn net.Conn, messageID int64, tag ber.Tag, code uint16) {
+	response := newLDAPMessage(messageID)
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	response.AppendChild(result)
+	_, _ = conn.Write(response.Bytes())
+}
+
+func newLDAPMessage(messageID int64) *ber.Packet {
+	message := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Message")
+	message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	return message
+}
+
+func searchResultEntry(messageID int64, entry testLDAPEntry, requested []string) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "DN"))
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, name := range requested {
+		values, ok := entryAttribute(entry, name)
+		if !ok {
+			continue
+		}
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	result.AppendChild(attributes)
+
+	message := newLDAPMessage(messageID)
+	message.AppendChild(result)
+	return message
+}
+
+func entryAttribute(entry testLDAPEntry, name string) ([]string, bool) {
+	for attribute, values := range entry.Attributes {
+		if strings.EqualFold(attribute, name) {
+			return values, true
+		}
+	}
+	return nil, false
+}
+
+func matchFilter(filter *ber.Packet, entry testLDAPEntry) (bool, error) {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if matched, err := matchFilter(child, entry); err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matched, err := matchFilter(child, entry); err != nil || matched {
+				return matched, err
+			}
+		}
+		return false, nil
+	case ldap.FilterNot:
+		matched, err := matchFilter(filter.Children[0], entry)
+		return !matched, err
+	case ldap.FilterPresent:
+		_, ok := entryAttribute(entry, berString(filter))
+		return ok, nil
+	case ldap.FilterEqualityMatch:
+		values, _ := entryAttribute(entry, berString(filter.Children[0]))
+		for _, value := range values {
+			if strings.EqualFold(value, berString(filter.Children[1])) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case ldap.FilterSubstrings:
+		values, _ := entryAttribute(entry, berString(filter.Children[0]))
+		for _, value := range values {
+			if matchSubstrings(strings.ToLower(value), filter.Children[1].Children) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, errors.New("unsupported filter")
+}
+
+func matchSubstrings(value string, parts []*ber.Packet) bool {
+	for _, part := range parts {
+		substring := strings.ToLower(berString(part))
+		switch part.Tag {
+		case ldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(value, substring) {
+				return false
+			}
+			value = value[len(substring):]
+		case ldap.FilterSubstringsAny:
+			at := strings.Index(value, substring)
+			if at < 0 {
+				return false
+			}
+			value = value[at+len(substring):]
+		case ldap.FilterSubstringsFinal:
+			if !strings.HasSuffix(value, substring) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// berString reads a primitive string, whatever its class
+func berString(packet *ber.Packet) string {
+	if value, ok := packet.Value.(string); ok {
+		return value
+	}
+	return packet.Data.String()
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gebase/internal/auth"
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+const testLDAPServiceDN = "cn=svc,dc=example,dc=com"
+
+type ldapTestEnv struct {
+	db      *gorm.DB
+	server  *testLDAPServer
+	service *LDAPService
+	config  *domain.OrganizationLDAPConfig
+	user    *domain.User
+}
+
+// newLDAPTestEnv configures organization 1 to authenticate against an
+// in-process directory holding entries, through the service account
+func newLDAPTestEnv(t *testing.T, entries ...testLDAPEntry) *ldapTestEnv {
+	t.Helper()
+
+	db := newTestDB(t,
+		&domain.OrganizationLDAPConfig{}, &domain.LDAPGroupRoleMapping{},
+		&domain.UserSystemRole{}, &domain.Role{}, &domain.Session{}, &domain.RevokedToken{},
+	)
+	entries = append(entries, testLDAPEntry{DN: testLDAPServiceDN, Password: "svc-secret"})
+	server := newTestLDAPServer(t, entries...)
+
+	cfg := &config.Config{
+		JWT:  config.JWTConfig{Secret: "test-secret"},
+		LDAP: config.LDAPConfig{Timeout: 5 * time.Second},
+	}
+	box := auth.NewSecretBox(cfg)
+	sealed, err := box.Seal([]byte("svc-secret"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	ldapConfig := &domain.OrganizationLDAPConfig{
+		OrganizationID: 1,
+		URL:            server.URL(),
+		BindDN:         testLDAPServiceDN,
+		BindPassword:   sealed,
+		BaseDN:         "dc=example,dc=com",
+		UserFilter:     defaultLDAPUserFilter,
+		SyncRoles:      domain.Ptr(true),
+	}
+	if err := db.Create(ldapConfig).Error; err != nil {
+		t.Fatalf("create ldap config: %v", err)
+	}
+
+	userSystemRoleRepo := repository.NewUserSystemRoleRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	return &ldapTestEnv{
+		db:     db,
+		server: server,
+		config: ldapConfig,
+		user:   &domain.User{ID: 7, Email: "alice@example.com", OrganizationID: domain.Ptr(int64(1))},
+		service: NewLDAPService(cfg,
+			repository.NewOrganizationLDAPConfigRepository(db),
+			repository.NewLDAPGroupRoleMappingRepository(db),
+			repository.NewOrganizationRepository(db),
+			repository.NewRoleRepository(db),
+			userSystemRoleRepo,
+			auth.NewRevocationService(cfg, repository.NewRevokedTokenRepository(db), sessionRepo, userSystemRoleRepo),
+			box,
+			nil,
+		),
+	}
+}
+
+func aliceEntry(groups ...string) testLDAPEntry {
+	return testLDAPEntry{
+		DN:       "uid=alice,ou=people,dc=example,dc=com",
+		Password: "alice-secret",
+		Attributes: map[string][]string{
+			"objectClass": {"person"},
+			"uid":         {"alice"},
+			"mail":        {"Alice@Example.com"},
+			"memberOf":    groups,
+		},
+	}
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     string
+		password  string
+		wantErr   error
+		wantBinds []string
+	}{
+		{"valid", "alice@example.com", "alice-secret", nil,
+			[]string{testLDAPServiceDN, "uid=alice,ou=people,dc=example,dc=com"}},
+		{"wrong password", "alice@example.com", "guess", ErrInvalidCredentials,
+			[]string{testLDAPServiceDN, "uid=alice,ou=people,dc=example,dc=com"}},
+		{"unknown user", "bob@example.com", "alice-secret", ErrInvalidCredentials,
+			[]string{testLDAPServiceDN}},
+		// An empty password would bind anonymously
+		{"empty password", "alice@example.com", "", ErrInvalidCredentials, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newLDAPTestEnv(t, aliceEntry())
+			env.user.Email = tt.email
+
+			err := env.service.Authenticate(context.Background(), env.user, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate error = %v, want %v", err, tt.wantErr)
+			}
+			if binds := env.server.Binds(); strings.Join(binds, "|") != strings.Join(tt.wantBinds, "|") {
+				t.Fatalf("binds = %v, want %v", binds, tt.wantBinds)
+			}
+		})
+	}
+}
+
+func TestLDAPAuthenticateEscapesFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		userFilter string
+		email      string
+		wantFilter string
+	}{
+		{"email", "(mail={email})", "*@example.com", `(mail=\2a@example.com)`},
+		{"username", "(&(objectClass=person)(uid={username}))", "al*@example.com", `(&(objectClass=person)(uid=al\2a))`},
+		{"injection", "(mail={email})", "*)(uid=*", `(mail=\2a\29\28uid=\2a)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newLDAPTestEnv(t, aliceEntry())
+			env.config.UserFilter = tt.userFilter
+			if err := env.db.Save(env.config).Error; err != nil {
+				t.Fatalf("save ldap config: %v", err)
+			}
+			env.user.Email = tt.email
+
+			// Unescaped, the wildcard would match alice and bind as her
+			err := env.service.Authenticate(context.Background(), env.user, "alice-secret")
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("Authenticate error = %v, want ErrInvalidCredentials", err)
+			}
+			if filters := env.server.Filters(); len(filters) != 1 || filters[0] != tt.wantFilter {
+				t.Fatalf("filters = %v, want %s", filters, tt.wantFilter)
+			}
+		})
+	}
+}
+
+func TestLDAPAuthenticateRejectsAmbiguousMatch(t *testing.T) {
+	duplicate := aliceEntry()
+	duplicate.DN = "uid=alice,ou=contractors,dc=example,dc=com"
+	env := newLDAPTestEnv(t, aliceEntry(), duplicate)
+
+	err := env.service.Authenticate(context.Background(), env.user, "alice-secret")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate error = %v, want ErrInvalidCredentials", err)
+	}
+	if binds := env.server.Binds(); len(binds) != 1 {
+		t.Fatalf("bound as %v, want only the service account", binds)
+	}
+}
+
+func TestLDAPAuthenticateSyncsRoles(t *testing.T) {
+	system1, system2 := 1, 2
+	env := newLDAPTestEnv(t, aliceEntry("CN=Admins,OU=Groups,DC=example,DC=com", "cn=unmapped,dc=example,dc=com"))
+	ctx := context.Background()
+
+	for _, mapping := range []domain.LDAPGroupRoleMapping{
+		{OrganizationID: 1, GroupDN: "cn=admins,ou=groups,dc=example,dc=com", RoleID: 1, SystemID: &system1},
+		{OrganizationID: 1, GroupDN: "cn=ops,ou=groups,dc=example,dc=com", RoleID: 2, SystemID: &system1},
+		{OrganizationID: 1, GroupDN: "cn=staff,ou=groups,dc=example,dc=com", RoleID: 3},
+		// Another organization's mapping of alice's group does not apply
+		{OrganizationID: 2, GroupDN: "cn=admins,ou=groups,dc=example,dc=com", RoleID: 4, SystemID: &system1},
+	} {
+		if err := env.db.Create(&mapping).Error; err != nil {
+			t.Fatalf("create mapping: %v", err)
+		}
+	}
+	for _, usr := range []domain.UserSystemRole{
+		{UserID: 7, SystemID: &system1, RoleID: 2},
+		{UserID: 7, RoleID: 3},
+		// System 2 has no mappings, so its roles are managed locally
+		{UserID: 7, SystemID: &system2, RoleID: 5},
+	} {
+		if err := env.db.Create(&usr).Error; err != nil {
+			t.Fatalf("create role assignment: %v", err)
+		}
+	}
+
+	if err := env.service.Authenticate(ctx, env.user, "alice-secret"); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	repo := repository.NewUserSystemRoleRepository(env.db)
+	for _, tt := range []struct {
+		name     string
+		systemID *int
+		want     []int
+	}{
+		{"mapped system", &system1, []int{1}},
+		{"platform", nil, nil},
+		{"unmapped system", &system2, []int{5}},
+	} {
+		assignments, err := repo.FindByUserAndSystem(ctx, 7, tt.systemID)
+		if err != nil {
+			t.Fatalf("FindByUserAndSystem: %v", err)
+		}
+		var roleIDs []int
+		for _, usr := range assignments {
+			roleIDs = append(roleIDs, usr.RoleID)
+		}
+		sort.Ints(roleIDs)
+		if fmt.Sprint(roleIDs) != fmt.Sprint(tt.want) {
+			t.Errorf("%s roles = %v, want %v", tt.name, roleIDs, tt.want)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+)
+
+var (
+	ErrAccountLocked   = errors.New("account is temporarily locked")
+	ErrTooManyAttempts = errors.New("too many login attempts")
+)
+
+// LoginBlockedError wraps ErrAccountLocked or ErrTooManyAttempts with how long
+// the client has to wait before trying again
+type LoginBlockedError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoginBlockedError) Unwrap() error {
+	return e.Err
+}
+
+// LoginAttemptInfo describes the client making a login attempt
+type LoginAttemptInfo struct {
+	Email     string
+	IPAddress string
+	DeviceUID string
+	UserAgent string
+}
+
+type LoginLockStatus struct {
+	Locked         bool                 `json:"locked"`
+	LockedUntil    *time.Time           `json:"locked_until,omitempty"`
+	RecentFailures int                  `json:"recent_failures"`
+	Lockout        *domain.LoginLockout `json:"lockout,omitempty"`
+}
+
+// LoginProtectionService keeps the login attempt ledger and enforces progressive
+// delays and temporary lockouts per account and per client IP
+type LoginProtectionService struct {
+	config      *config.Config
+	attemptRepo *repository.LoginAttemptRepository
+	lockoutRepo *repository.LoginLockoutRepository
+}
+
+func NewLoginProtectionService(
+	cfg *config.Config,
+	attemptRepo *repository.LoginAttemptRepository,
+	lockoutRepo *repository.LoginLockoutRepository,
+) *LoginProtectionService {
+	return &LoginProtectionService{
+		config:      cfg,
+		attemptRepo: attemptRepo,
+		lockoutRepo: lockoutRepo,
+	}
+}
+
+// Check returns a *LoginBlockedError if the client address or account is locked,
+// or if the account must still wait out its progressive delay
+func (s *LoginProtectionService) Check(ctx context.Context, userID *int64, ipAddress string) error {
+	ipLockout, err := s.latestIPLockout(ctx, ipAddress)
+	if err != nil {
+		return err
+	}
+	if ipLockout != nil && ipLockout.IsActive() {
+		return &LoginBlockedError{Err: ErrTooManyAttempts, RetryAfter: time.Until(ipLockout.LockedUntil)}
+	}
+
+	if userID == nil {
+		return nil
+	}
+
+	lockout, err := s.latestUserLockout(ctx, *userID)
+	if err != nil {
+		return err
+	}
+	if lockout != nil && lockout.IsActive() {
+		return &LoginBlockedError{Err: ErrAccountLocked, RetryAfter: time.Until(lockout.LockedUntil)}
+	}
+
+	failures, err := s.accountFailures(ctx, *userID, lockout)
+	if err != nil {
+		return err
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+
+	wait := time.Until(failures[0].CreatedAt.Add(s.delay(len(failures))))
+	if wait > 0 {
+		return &LoginBlockedError{Err: ErrTooManyAttempts, RetryAfter: wait}
+	}
+	return nil
+}
+
+// RecordSuccess records a completed login, which resets the account's failure
+// count. It is recorded only once every factor has been verified.
+func (s *LoginProtectionService) RecordSuccess(ctx context.Context, userID int64, info LoginAttemptInfo) error {
+	return s.attemptRepo.Create(ctx, &domain.LoginAttempt{
+		Email:     info.Email,
+		UserID:    &userID,
+		IPAddress: info.IPAddress,
+		DeviceUID: info.DeviceUID,
+		UserAgent: info.UserAgent,
+		Success:   true,
+	})
+}
+
+// RecordBlocked records an attempt rejected by Check. Blocked attempts count
+// towards the client address but not towards the account.
+func (s *LoginProtectionService) RecordBlocked(ctx context.Context, userID *int64, info LoginAttemptInfo) error {
+	return s.record(ctx, userID, info, domain.LoginFailureLocked)
+}
+
+// RecordFailure records a failed attempt and locks the account or client
+// address once its threshold is reached, returning a *LoginBlockedError if so
+func (s *LoginProtectionService) RecordFailure(ctx context.Context, userID *int64, info LoginAttemptInfo, reason string) error {
+	if err := s.record(ctx, userID, info, reason); err != nil {
+		return err
+	}
+
+	if err := s.lockIPIfNeeded(ctx, info.IPAddress); err != nil {
+		return err
+	}
+	if userID == nil {
+		return nil
+	}
+	return s.lockUserIfNeeded(ctx, *userID)
+}
+
+// ChallengeExhausted reports whether an account has entered as many wrong
+// second-factor codes since a login challenge was issued as one challenge allows
+func (s *LoginProtectionService) ChallengeExhausted(ctx context.Context, userID int64, issuedAt time.Time) (bool, error) {
+	failures, err := s.attemptRepo.FindFailuresByUser(ctx, userID, issuedAt)
+	if err != nil {
+		return false, err
+	}
+
+	wrongCodes := 0
+	for _, failure := range failures {
+		if failure.FailureReason == domain.LoginFailureInvalidMFACode {
+			wrongCodes++
+		}
+	}
+	return wrongCodes >= s.config.MFA.ChallengeMaxAttempts, nil
+}
+
+// GetStatus returns the lockout state of an account
+func (s *LoginProtectionService) GetStatus(ctx context.Context, userID int64) (*LoginLockStatus, error) {
+	lockout, err := s.latestUserLockout(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	failures, err := s.accountFailures(ctx, userID, lockout)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &LoginLockStatus{RecentFailures: len(failures), Lockout: lockout}
+	if lockout != nil && lockout.IsActive() {
+		status.Locked = true
+		status.LockedUntil = &lockout.LockedUntil
+	}
+	return status, nil
+}
+
+// Unlock lifts an account lockout and resets its failure count
+func (s *LoginProtectionService) Unlock(ctx context.Context, userID int64, unlockedBy int64) error {
+	return s.lockoutRepo.UnlockUser(ctx, userID, unlockedBy)
+}
+
+// ListAttempts returns the login ledger of an account
+func (s *LoginProtectionService) ListAttempts(ctx context.Context, userID int64, page, pageSize int) (*repository.PaginatedResult[domain.LoginAttempt], error) {
+	return s.attemptRepo.FindByUser(ctx, userID, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+func (s *LoginProtectionService) record(ctx context.Context, userID *int64, info LoginAttemptInfo, reason string) error {
+	return s.attemptRepo.Create(ctx, &domain.LoginAttempt{
+		Email:         info.Email,
+		UserID:        userID,
+		IPAddress:     info.IPAddress,
+		DeviceUID:     info.DeviceUID,
+		UserAgent:     info.UserAgent,
+		Success:       false,
+		FailureReason: reason,
+	})
+}
+
+func (s *LoginProtectionService) lockUserIfNeeded(ctx context.Context, userID int64) error {
+	lockout, err := s.latestUserLockout(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	failures, err := s.accountFailures(ctx, userID, lockout)
+	if err != nil {
+		return err
+	}
+	if len(failures) < s.config.Login.MaxAccountFailures {
+		return nil
+	}
+
+	previous, err := s.lockoutRepo.CountByUserSince(ctx, userID, time.Now().Add(-s.config.Login.MaxLockoutDuration))
+	if err != nil {
+		return err
+	}
+
+	duration := s.lockoutDuration(previous)
+	if err := s.lockoutRepo.Create(ctx, &domain.LoginLockout{
+		UserID:      &userID,
+		FailedCount: len(failures),
+		LockedUntil: time.Now().Add(duration),
+	}); err != nil {
+		return err
+	}
+
+	return &LoginBlockedError{Err: ErrAccountLocked, RetryAfter: duration}
+}
+
+func (s *LoginProtectionService) lockIPIfNeeded(ctx context.Context, ipAddress string) error {
+	if ipAddress == "" {
+		return nil
+	}
+
+	lockout, err := s.latestIPLockout(ctx, ipAddress)
+	if err != nil {
+		return err
+	}
+
+	since := time.Now().Add(-s.config.Login.FailureWindow)
+	if lockout != nil && lockout.ResetAt().After(since) {
+		since = lockout.ResetAt()
+	}
+
+	failures, err := s.attemptRepo.FindFailuresByIP(ctx, ipAddress, since)
+	if err != nil {
+		return err
+	}
+	if len(failures) < s.config.Login.MaxIPFailures {
+		return nil
+	}
+
+	previous, err := s.lockoutRepo.CountByIPSince(ctx, ipAddress, time.Now().Add(-s.config.Login.MaxLockoutDuration))
+	if err != nil {
+		return err
+	}
+
+	duration := s.lockoutDuration(previous)
+	if err := s.lockoutRepo.Create(ctx, &domain.LoginLockout{
+		IPAddress:   ipAddress,
+		FailedCount: len(failures),
+		LockedUntil: time.Now().Add(duration),
+	}); err != nil {
+		return err
+	}
+
+	return &LoginBlockedError{Err: ErrTooManyAttempts, RetryAfter: duration}
+}
+
+// accountFailures returns the failures that count towards locking an account:
+// within the failure window and after the last success or lockout
+func (s *LoginProtectionService) accountFailures(ctx context.Context, userID int64, lockout *domain.LoginLockout) ([]domain.LoginAttempt, error) {
+	since := time.Now().Add(-s.config.Login.FailureWindow)
+	if lockout != nil && lockout.ResetAt().After(since) {
+		since = lockout.ResetAt()
+	}
+
+	success, err := s.attemptRepo.FindLastSuccessByUser(ctx, userID)
+	if err != nil && !repository.IsNotFound(err) {
+		return nil, err
+	}
+	if success != nil && success.CreatedAt.After(since) {
+		since = success.CreatedAt
+	}
+
+	failures, err := s.attemptRepo.FindFailuresByUser(ctx, userID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	counted := failures[:0]
+	for _, failure := range failures {
+		if failure.FailureReason != domain.LoginFailureLocked {
+			counted = append(counted, failure)
+		}
+	}
+	return counted, nil
+}
+
+// delay is the wait required after the given number of consecutive failures
+func (s *LoginProtectionService) delay(failures int) time.Duration {
+	extra := failures - s.config.Login.FreeAttempts
+	if extra <= 0 {
+		return 0
+	}
+
+	delay := s.config.Login.DelayBase
+	for i := 1; i < extra && delay < s.config.Login.DelayMax; i++ {
+		delay *= 2
+	}
+	if delay > s.config.Login.DelayMax {
+		delay = s.config.Login.DelayMax
+	}
+	return delay
+}
+
+// lockoutDuration doubles for every recent lockout, up to the configured maximum
+func (s *LoginProtectionService) lockoutDuration(previous int64) time.Duration {
+	duration := s.config.Login.LockoutDuration
+	for i := int64(0); i < previous && duration < s.config.Login.MaxLockoutDuration; i++ {
+		duration *= 2
+	}
+	if duration > s.config.Login.MaxLockoutDuration {
+		duration = s.config.Login.MaxLockoutDuration
+	}
+	return duration
+}
+
+func (s *LoginProtectionService) latestUserLockout(ctx context.Context, userID int64) (*domain.LoginLockout, error) {
+	lockout, err := s.lockoutRepo.FindLatestByUser(ctx, userID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return lockout, nil
+}
+
+func (s *LoginProtectionService) latestIPLockout(ctx context.Context, ipAddress string) (*domain.LoginLockout, error) {
+	lockout, err := s.lockoutRepo.FindLatestByIP(ctx, ipAddress)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return lockout, nil
+}
@@ -0,0 +1,570 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/geoip"
+	"gebase/internal/mail"
+	"gebase/internal/repository"
+)
+
+// LoginRiskAlertPermission is held by the organization administrators
+// alerted of risky logins
+const LoginRiskAlertPermission = "admin.user.update"
+
+const (
+	// loginRiskHistoryLimit bounds the past sessions a login is compared with
+	loginRiskHistoryLimit = 500
+	// minTravelDistanceKm ignores jumps within the accuracy of city-level
+	// GeoIP data
+	minTravelDistanceKm = 200
+)
+
+var (
+	ErrLoginRiskBlocked          = errors.New("login blocked as unusual for this account")
+	ErrLoginRiskPolicyNotFound   = errors.New("login risk policy not found")
+	ErrLoginRiskPolicyExists     = errors.New("organization already has a login risk policy")
+	ErrInvalidLoginRiskTimezone  = errors.New("invalid timezone")
+	ErrInvalidLoginRiskWeekdays  = errors.New("business days must be comma separated weekdays from 1 (Monday) to 7 (Sunday)")
+	ErrInvalidLoginRiskThreshold = errors.New("block score must not be below the step-up score")
+)
+
+var loginRiskSignalText = map[string]string{
+	domain.LoginRiskNewDevice:        "a device not used before",
+	domain.LoginRiskUnusualNetwork:   "an unusual network or country",
+	domain.LoginRiskImpossibleTravel: "a location too far from the previous sign-in to have travelled in between",
+	domain.LoginRiskOffHours:         "outside business hours",
+}
+
+// LoginRiskService scores logins against the user's past sessions and
+// decides whether to allow them, require a second factor or block them,
+// alerting the user and organization administrators of risky ones
+type LoginRiskService struct {
+	config      *config.Config
+	policyRepo  *repository.LoginRiskPolicyRepository
+	eventRepo   *repository.LoginRiskEventRepository
+	sessionRepo *repository.SessionRepository
+	orgRepo     *repository.OrganizationRepository
+	userRepo    *repository.UserRepository
+	geoip       *geoip.Reader
+	mailSender  mail.Sender
+	pushService *PushService
+}
+
+// NewLoginRiskService creates the service. geo may be nil when no GeoIP
+// database is configured.
+func NewLoginRiskService(
+	cfg *config.Config,
+	policyRepo *repository.LoginRiskPolicyRepository,
+	eventRepo *repository.LoginRiskEventRepository,
+	sessionRepo *repository.SessionRepository,
+	orgRepo *repository.OrganizationRepository,
+	userRepo *repository.UserRepository,
+	geo *geoip.Reader,
+	mailSender mail.Sender,
+	pushService *PushService,
+) *LoginRiskService {
+	return &LoginRiskService{
+		config:      cfg,
+		policyRepo:  policyRepo,
+		eventRepo:   eventRepo,
+		sessionRepo: sessionRepo,
+		orgRepo:     orgRepo,
+		userRepo:    userRepo,
+		geoip:       geo,
+		mailSender:  mailSender,
+		pushService: pushService,
+	}
+}
+
+// LoginRiskPolicyRequest creates or updates a policy. Fields left out keep
+// their current value, or the global default on creation.
+type LoginRiskPolicyRequest struct {
+	OrganizationID        int64  `json:"organization_id" binding:"required"`
+	NewDeviceScore        *int   `json:"new_device_score" binding:"omitempty,min=0,max=100"`
+	UnusualNetworkScore   *int   `json:"unusual_network_score" binding:"omitempty,min=0,max=100"`
+	ImpossibleTravelScore *int   `json:"impossible_travel_score" binding:"omitempty,min=0,max=100"`
+	OffHoursScore         *int   `json:"off_hours_score" binding:"omitempty,min=0,max=100"`
+	StepUpScore           *int   `json:"step_up_score" binding:"omitempty,min=0"`
+	BlockScore            *int   `json:"block_score" binding:"omitempty,min=0"`
+	NotifyScore           *int   `json:"notify_score" binding:"omitempty,min=0"`
+	MaxTravelSpeedKmh     *int   `json:"max_travel_speed_kmh" binding:"omitempty,min=1"`
+	BusinessHoursStart    *int   `json:"business_hours_start" binding:"omitempty,min=0,max=24"`
+	BusinessHoursEnd      *int   `json:"business_hours_end" binding:"omitempty,min=0,max=24"`
+	BusinessDays          string `json:"business_days"`
+	Timezone              string `json:"timezone"`
+	IsActive              *bool  `json:"is_active"`
+}
+
+// LoginRiskAssessment is the outcome of scoring a login
+type LoginRiskAssessment struct {
+	Score   int
+	Signals []string
+	Action  string
+	Event   *domain.LoginRiskEvent
+}
+
+// Evaluate scores a login whose password was correct and records the
+// assessment. A login that needs a step-up from a user without a second
+// factor is blocked instead: enrolling one during the login would let
+// whoever holds the password pass the step-up.
+func (s *LoginRiskService) Evaluate(ctx context.Context, user *domain.User, device *domain.Device, ipAddress, userAgent string, mfaEnrolled bool) (*LoginRiskAssessment, error) {
+	if !s.config.LoginRisk.Enabled {
+		return &LoginRiskAssessment{Action: domain.LoginRiskAllow}, nil
+	}
+
+	policy, err := s.policyFor(ctx, user.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ip := net.ParseIP(ipAddress)
+	location := s.locate(ip)
+
+	history, err := s.sessionRepo.FindHistoryByUser(ctx, user.ID, now.Add(-s.config.LoginRisk.HistoryWindow), loginRiskHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	assessment := &LoginRiskAssessment{Action: domain.LoginRiskAllow}
+	raise := func(signal string, score int) {
+		assessment.Signals = append(assessment.Signals, signal)
+		assessment.Score += score
+	}
+
+	// Without history there is nothing to compare against, e.g. on the
+	// first login
+	if len(history) > 0 {
+		if isNewLoginDevice(history, device.ID) {
+			raise(domain.LoginRiskNewDevice, policy.NewDeviceScore)
+		}
+		if s.isUnusualNetwork(history, ip, location) {
+			raise(domain.LoginRiskUnusualNetwork, policy.UnusualNetworkScore)
+		}
+		if s.isImpossibleTravel(history, ip, location, now, policy.MaxTravelSpeedKmh) {
+			raise(domain.LoginRiskImpossibleTravel, policy.ImpossibleTravelScore)
+		}
+	}
+	if isOffHours(policy, now) {
+		raise(domain.LoginRiskOffHours, policy.OffHoursScore)
+	}
+
+	switch {
+	case reachesScore(assessment.Score, policy.BlockScore):
+		assessment.Action = domain.LoginRiskBlock
+	case reachesScore(assessment.Score, policy.StepUpScore):
+		assessment.Action = domain.LoginRiskStepUp
+		if !mfaEnrolled {
+			assessment.Action = domain.LoginRiskBlock
+		}
+	}
+
+	event := &domain.LoginRiskEvent{
+		UserID:         user.ID,
+		OrganizationID: user.OrganizationID,
+		DeviceID:       device.ID,
+		IPAddress:      ipAddress,
+		UserAgent:      userAgent,
+		Score:          assessment.Score,
+		Signals:        strings.Join(assessment.Signals, ","),
+		Action:         assessment.Action,
+	}
+	if location != nil {
+		event.Country = location.Country
+		event.City = location.City
+		if location.HasCoordinates {
+			event.Latitude = &location.Latitude
+			event.Longitude = &location.Longitude
+		}
+	}
+	if err := s.eventRepo.Create(ctx, event); err != nil {
+		return nil, err
+	}
+	assessment.Event = event
+
+	if reachesScore(assessment.Score, policy.NotifyScore) {
+		go s.notify(context.WithoutCancel(ctx), user, device, event)
+	}
+	return assessment, nil
+}
+
+// policyFor returns the active policy of an organization, or one built from
+// the global settings
+func (s *LoginRiskService) policyFor(ctx context.Context, orgID *int64) (*domain.LoginRiskPolicy, error) {
+	if orgID != nil {
+		policy, err := s.policyRepo.FindByOrganization(ctx, *orgID)
+		if err == nil && policy.IsActive != nil && *policy.IsActive {
+			return policy, nil
+		}
+		if err != nil && !repository.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return s.defaultPolicy(), nil
+}
+
+func (s *LoginRiskService) defaultPolicy() *domain.LoginRiskPolicy {
+	return &domain.LoginRiskPolicy{
+		NewDeviceScore:        domain.DefaultNewDeviceScore,
+		UnusualNetworkScore:   domain.DefaultUnusualNetworkScore,
+		ImpossibleTravelScore: domain.DefaultImpossibleTravelScore,
+		OffHoursScore:         domain.DefaultOffHoursScore,
+		StepUpScore:           s.config.LoginRisk.StepUpScore,
+		BlockScore:            s.config.LoginRisk.BlockScore,
+		NotifyScore:           s.config.LoginRisk.NotifyScore,
+		MaxTravelSpeedKmh:     s.config.LoginRisk.MaxTravelSpeed,
+		BusinessDays:          "1,2,3,4,5",
+		Timezone:              "UTC",
+	}
+}
+
+func (s *LoginRiskService) locate(ip net.IP) *geoip.Location {
+	if s.geoip == nil || ip == nil {
+		return nil
+	}
+	location, err := s.geoip.Lookup(ip)
+	if err != nil {
+		log.Printf("Warning: failed to locate %s: %v", ip, err)
+		return nil
+	}
+	return location
+}
+
+func isNewLoginDevice(history []domain.Session, deviceID int64) bool {
+	for _, session := range history {
+		if session.DeviceID == deviceID {
+			return false
+		}
+	}
+	return true
+}
+
+// isUnusualNetwork reports a login from an address range the user has not
+// signed in from. When the addresses can be located a new range in a
+// country seen before is usual, as mobile and home connections change
+// addresses often.
+func (s *LoginRiskService) isUnusualNetwork(history []domain.Session, ip net.IP, location *geoip.Location) bool {
+	if ip == nil {
+		return false
+	}
+	prefix := networkPrefix(ip)
+	for _, session := range history {
+		if networkPrefix(net.ParseIP(session.IPAddress)) == prefix {
+			return false
+		}
+	}
+
+	if location == nil || location.Country == "" {
+		return true
+	}
+	located := make(map[string]bool)
+	for _, session := range history {
+		if located[session.IPAddress] {
+			continue
+		}
+		located[session.IPAddress] = true
+		past := s.locate(net.ParseIP(session.IPAddress))
+		if past != nil && past.Country == location.Country {
+			return false
+		}
+	}
+	return true
+}
+
+// isImpossibleTravel reports a login too far from where the user was last
+// seen to have got there at maxSpeed km/h
+func (s *LoginRiskService) isImpossibleTravel(history []domain.Session, ip net.IP, location *geoip.Location, now time.Time, maxSpeed int) bool {
+	if location == nil || !location.HasCoordinates || maxSpeed <= 0 {
+		return false
+	}
+
+	var last *domain.Session
+	var lastSeen time.Time
+	for i := range history {
+		seen := sessionLastSeen(&history[i])
+		if last == nil || seen.After(lastSeen) {
+			last, lastSeen = &history[i], seen
+		}
+	}
+	if last.IPAddress == ip.String() {
+		return false
+	}
+	previous := s.locate(net.ParseIP(last.IPAddress))
+	if previous == nil || !previous.HasCoordinates {
+		return false
+	}
+
+	distance := geoip.Distance(previous.Latitude, previous.Longitude, location.Latitude, location.Longitude)
+	if distance < minTravelDistanceKm {
+		return false
+	}
+	hours := now.Sub(lastSeen).Hours()
+	if hours < 1.0/60 {
+		hours = 1.0 / 60
+	}
+	return distance/hours > float64(maxSpeed)
+}
+
+func sessionLastSeen(session *domain.Session) time.Time {
+	var seen time.Time
+	if session.CreatedDate != nil {
+		seen = *session.CreatedDate
+	}
+	if session.LastActivity != nil && session.LastActivity.After(seen) {
+		seen = *session.LastActivity
+	}
+	return seen
+}
+
+// networkPrefix returns the /24 of an IPv4 or the /48 of an IPv6 address
+func networkPrefix(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// isOffHours reports a login outside the policy's business hours
+func isOffHours(policy *domain.LoginRiskPolicy, now time.Time) bool {
+	if policy.BusinessHoursStart == policy.BusinessHoursEnd {
+		return false
+	}
+	tz, err := time.LoadLocation(policy.Timezone)
+	if err != nil {
+		tz = time.UTC
+	}
+	local := now.In(tz)
+
+	days, err := parseWeekdays(policy.BusinessDays)
+	if err != nil {
+		return false
+	}
+	if !days[local.Weekday()] {
+		return true
+	}
+
+	hour := local.Hour()
+	if policy.BusinessHoursStart < policy.BusinessHoursEnd {
+		return hour < policy.BusinessHoursStart || hour >= policy.BusinessHoursEnd
+	}
+	// Business hours running past midnight
+	return hour < policy.BusinessHoursStart && hour >= policy.BusinessHoursEnd
+}
+
+// parseWeekdays parses comma separated ISO weekdays, 1 being Monday
+func parseWeekdays(value string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		day, err := strconv.Atoi(part)
+		if err != nil || day < 1 || day > 7 {
+			return nil, ErrInvalidLoginRiskWeekdays
+		}
+		days[time.Weekday(day%7)] = true
+	}
+	if len(days) == 0 {
+		return nil, ErrInvalidLoginRiskWeekdays
+	}
+	return days, nil
+}
+
+func reachesScore(score, threshold int) bool {
+	return threshold > 0 && score >= threshold
+}
+
+// notify alerts the user by mail and push, and the administrators of the
+// user's organization by mail
+func (s *LoginRiskService) notify(ctx context.Context, user *domain.User, device *domain.Device, event *domain.LoginRiskEvent) {
+	var reasons []string
+	for _, signal := range strings.Split(event.Signals, ",") {
+		if text, ok := loginRiskSignalText[signal]; ok {
+			reasons = append(reasons, "- "+text)
+		}
+	}
+	place := event.IPAddress
+	if event.City != "" {
+		place = fmt.Sprintf("%s (%s, %s)", event.IPAddress, event.City, event.Country)
+	} else if event.Country != "" {
+		place = fmt.Sprintf("%s (%s)", event.IPAddress, event.Country)
+	}
+	details := fmt.Sprintf("Time: %s\nDevice: %s (%s)\nAddress: %s\nRisk score: %d\n\nThe sign-in came from:\n%s\n",
+		event.CreatedAt.Format(time.RFC1123), device.Name, device.Platform, place, event.Score, strings.Join(reasons, "\n"))
+
+	outcome := "was allowed"
+	switch event.Action {
+	case domain.LoginRiskStepUp:
+		outcome = "required a second factor"
+	case domain.LoginRiskBlock:
+		outcome = "was blocked"
+	}
+
+	userMsg := &mail.Message{
+		To:      []string{user.Email},
+		Subject: "Unusual sign-in to your account",
+		Body: fmt.Sprintf("We noticed an unusual sign-in to your account, which %s.\n\n%s\n"+
+			"If this was not you, change your password and contact your administrator.\n", outcome, details),
+	}
+	if err := s.mailSender.Send(ctx, userMsg); err != nil {
+		log.Printf("Warning: failed to alert user %d of a risky login: %v", user.ID, err)
+	}
+
+	if s.pushService != nil {
+		_, err := s.pushService.SendToUser(ctx, user.ID, &PushContent{
+			Title: "Unusual sign-in",
+			Body:  fmt.Sprintf("A sign-in from %s %s. Not you? Change your password.", place, outcome),
+			Data: map[string]string{
+				"type":     "login_risk",
+				"event_id": strconv.FormatInt(event.ID, 10),
+			},
+		})
+		if err != nil {
+			log.Printf("Warning: failed to push risky login alert to user %d: %v", user.ID, err)
+		}
+	}
+
+	admins, err := s.userRepo.FindWithPermission(ctx, user.OrganizationID, LoginRiskAlertPermission)
+	if err != nil {
+		log.Printf("Warning: failed to find administrators to alert of a risky login: %v", err)
+		return
+	}
+	to := make([]string, 0, len(admins))
+	for _, admin := range admins {
+		if admin.ID != user.ID {
+			to = append(to, admin.Email)
+		}
+	}
+	if len(to) == 0 {
+		return
+	}
+
+	adminMsg := &mail.Message{
+		To:      to,
+		Subject: fmt.Sprintf("Risky sign-in: %s", user.Email),
+		Body: fmt.Sprintf("An unusual sign-in to the account of %s %s.\n\n%s\n"+
+			"Risky sign-ins are listed under Users > Login risk in the admin console.\n", user.Email, outcome, details),
+	}
+	if err := s.mailSender.Send(ctx, adminMsg); err != nil {
+		log.Printf("Warning: failed to alert administrators of a risky login: %v", err)
+	}
+}
+
+// ListEvents returns login risk events, newest first
+func (s *LoginRiskService) ListEvents(ctx context.Context, filter repository.LoginRiskEventFilter, page, pageSize int) (*repository.PaginatedResult[domain.LoginRiskEvent], error) {
+	return s.eventRepo.FindFiltered(ctx, filter, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// ListPolicies returns login risk policies, optionally of one organization
+func (s *LoginRiskService) ListPolicies(ctx context.Context, orgID *int64) ([]domain.LoginRiskPolicy, error) {
+	return s.policyRepo.FindFiltered(ctx, orgID)
+}
+
+// CreatePolicy creates the login risk policy of an organization, starting
+// from the global settings
+func (s *LoginRiskService) CreatePolicy(ctx context.Context, req *LoginRiskPolicyRequest, createdBy int64) (*domain.LoginRiskPolicy, error) {
+	if _, err := s.orgRepo.FindByID(ctx, req.OrganizationID); err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+	if _, err := s.policyRepo.FindByOrganization(ctx, req.OrganizationID); err == nil {
+		return nil, ErrLoginRiskPolicyExists
+	} else if !repository.IsNotFound(err) {
+		return nil, err
+	}
+
+	policy := s.defaultPolicy()
+	policy.OrganizationID = req.OrganizationID
+	policy.IsActive = domain.Ptr(true)
+	if err := applyLoginRiskPolicy(policy, req); err != nil {
+		return nil, err
+	}
+	policy.CreatedBy = &createdBy
+
+	if err := s.policyRepo.Create(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// UpdatePolicy changes a login risk policy; its organization cannot change
+func (s *LoginRiskService) UpdatePolicy(ctx context.Context, id int64, req *LoginRiskPolicyRequest, updatedBy int64) (*domain.LoginRiskPolicy, error) {
+	policy, err := s.policyRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrLoginRiskPolicyNotFound
+	}
+
+	if err := applyLoginRiskPolicy(policy, req); err != nil {
+		return nil, err
+	}
+	policy.UpdatedBy = &updatedBy
+
+	if err := s.policyRepo.Update(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// DeletePolicy deletes a login risk policy; the organization falls back to
+// the global settings
+func (s *LoginRiskService) DeletePolicy(ctx context.Context, id int64) error {
+	if _, err := s.policyRepo.FindByID(ctx, id); err != nil {
+		return ErrLoginRiskPolicyNotFound
+	}
+	return s.policyRepo.Delete(ctx, id)
+}
+
+func applyLoginRiskPolicy(policy *domain.LoginRiskPolicy, req *LoginRiskPolicyRequest) error {
+	setInt := func(dst *int, src *int) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	setInt(&policy.NewDeviceScore, req.NewDeviceScore)
+	setInt(&policy.UnusualNetworkScore, req.UnusualNetworkScore)
+	setInt(&policy.ImpossibleTravelScore, req.ImpossibleTravelScore)
+	setInt(&policy.OffHoursScore, req.OffHoursScore)
+	setInt(&policy.StepUpScore, req.StepUpScore)
+	setInt(&policy.BlockScore, req.BlockScore)
+	setInt(&policy.NotifyScore, req.NotifyScore)
+	setInt(&policy.MaxTravelSpeedKmh, req.MaxTravelSpeedKmh)
+	setInt(&policy.BusinessHoursStart, req.BusinessHoursStart)
+	setInt(&policy.BusinessHoursEnd, req.BusinessHoursEnd)
+
+	if req.BusinessDays != "" {
+		if _, err := parseWeekdays(req.BusinessDays); err != nil {
+			return err
+		}
+		policy.BusinessDays = req.BusinessDays
+	}
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			return ErrInvalidLoginRiskTimezone
+		}
+		policy.Timezone = req.Timezone
+	}
+	if req.IsActive != nil {
+		policy.IsActive = req.IsActive
+	}
+
+	if policy.BlockScore > 0 && policy.StepUpScore > 0 && policy.BlockScore < policy.StepUpScore {
+		return ErrInvalidLoginRiskThreshold
+	}
+	return nil
+}
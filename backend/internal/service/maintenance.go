@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gebase/internal/auth"
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+)
+
+// MaintenanceService holds the housekeeping tasks run by the job scheduler
+type MaintenanceService struct {
+	config           *config.Config
+	sessionService   *auth.SessionService
+	deviceRepo       *repository.DeviceRepository
+	loginAttemptRepo *repository.LoginAttemptRepository
+	resetTokenRepo   *repository.PasswordResetTokenRepository
+	resetRequestRepo *repository.PasswordResetRequestRepository
+	oidcStateRepo    *repository.OIDCLoginStateRepository
+	oauthCodeRepo    *repository.OAuthAuthorizationCodeRepository
+	revokedTokenRepo *repository.RevokedTokenRepository
+	refreshTokenRepo *repository.RefreshTokenRepository
+	proofNonceRepo   *repository.DeviceProofNonceRepository
+	commandRepo      *repository.DeviceCommandRepository
+	presenceRepo     *repository.DevicePresenceEventRepository
+	silenceAlertRepo *repository.DeviceSilenceAlertRepository
+	pushRepo         *repository.PushNotificationRepository
+	loginRiskRepo    *repository.LoginRiskEventRepository
+	jobRunRepo       *repository.JobRunRepository
+}
+
+func NewMaintenanceService(
+	cfg *config.Config,
+	sessionService *auth.SessionService,
+	deviceRepo *repository.DeviceRepository,
+	loginAttemptRepo *repository.LoginAttemptRepository,
+	resetTokenRepo *repository.PasswordResetTokenRepository,
+	resetRequestRepo *repository.PasswordResetRequestRepository,
+	oidcStateRepo *repository.OIDCLoginStateRepository,
+	oauthCodeRepo *repository.OAuthAuthorizationCodeRepository,
+	revokedTokenRepo *repository.RevokedTokenRepository,
+	refreshTokenRepo *repository.RefreshTokenRepository,
+	proofNonceRepo *repository.DeviceProofNonceRepository,
+	commandRepo *repository.DeviceCommandRepository,
+	presenceRepo *repository.DevicePresenceEventRepository,
+	silenceAlertRepo *repository.DeviceSilenceAlertRepository,
+	pushRepo *repository.PushNotificationRepository,
+	loginRiskRepo *repository.LoginRiskEventRepository,
+	jobRunRepo *repository.JobRunRepository,
+) *MaintenanceService {
+	return &MaintenanceService{
+		config:           cfg,
+		sessionService:   sessionService,
+		deviceRepo:       deviceRepo,
+		loginAttemptRepo: loginAttemptRepo,
+		resetTokenRepo:   resetTokenRepo,
+		resetRequestRepo: resetRequestRepo,
+		oidcStateRepo:    oidcStateRepo,
+		oauthCodeRepo:    oauthCodeRepo,
+		revokedTokenRepo: revokedTokenRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		proofNonceRepo:   proofNonceRepo,
+		commandRepo:      commandRepo,
+		presenceRepo:     presenceRepo,
+		silenceAlertRepo: silenceAlertRepo,
+		pushRepo:         pushRepo,
+		loginRiskRepo:    loginRiskRepo,
+		jobRunRepo:       jobRunRepo,
+	}
+}
+
+// CleanupExpiredSessions ends sessions past their expiry
+func (s *MaintenanceService) CleanupExpiredSessions(ctx context.Context) (int64, error) {
+	return s.sessionService.CleanupExpiredSessions(ctx)
+}
+
+// MarkStaleDevices flags devices that stopped sending heartbeats
+func (s *MaintenanceService) MarkStaleDevices(ctx context.Context) (int64, error) {
+	return s.deviceRepo.MarkStale(ctx, time.Now().Add(-s.config.Scheduler.StaleDeviceAfter))
+}
+
+// ExpireDeviceCommands closes device commands that were not acknowledged in time
+func (s *MaintenanceService) ExpireDeviceCommands(ctx context.Context) (int64, error) {
+	return s.commandRepo.ExpireOverdue(ctx)
+}
+
+// PurgeLogs deletes login attempts, login risk events, password reset
+// requests, job runs, finished
+// device commands and push notifications older than the retention period,
+// along with expired tokens, login states and device proof nonces that can
+// no longer be used. Device presence
+// history and resolved silence alerts are kept for the longer
+// DEVICE_PRESENCE_RETENTION to cover uptime reports. Every table is
+// attempted even when one fails.
+func (s *MaintenanceService) PurgeLogs(ctx context.Context) (int64, error) {
+	cutoff := time.Now().Add(-s.config.Scheduler.LogRetention)
+	presenceCutoff := time.Now().Add(-s.config.Device.PresenceRetention)
+
+	purges := []func(context.Context) (int64, error){
+		func(ctx context.Context) (int64, error) { return s.loginAttemptRepo.DeleteOlderThan(ctx, cutoff) },
+		func(ctx context.Context) (int64, error) { return s.jobRunRepo.DeleteOlderThan(ctx, cutoff) },
+		func(ctx context.Context) (int64, error) { return s.commandRepo.DeleteFinishedBefore(ctx, cutoff) },
+		func(ctx context.Context) (int64, error) { return s.pushRepo.DeleteOlderThan(ctx, cutoff) },
+		func(ctx context.Context) (int64, error) { return s.loginRiskRepo.DeleteOlderThan(ctx, cutoff) },
+		func(ctx context.Context) (int64, error) { return s.resetRequestRepo.DeleteOlderThan(ctx, cutoff) },
+		s.resetTokenRepo.DeleteExpired,
+		s.oidcStateRepo.DeleteExpired,
+		s.oauthCodeRepo.DeleteExpired,
+		s.revokedTokenRepo.DeleteExpired,
+		s.refreshTokenRepo.DeleteExpired,
+		s.proofNonceRepo.DeleteExpired,
+		func(ctx context.Context) (int64, error) { return s.presenceRepo.DeleteOlderThan(ctx, presenceCutoff) },
+		func(ctx context.Context) (int64, error) {
+			return s.silenceAlertRepo.DeleteResolvedBefore(ctx, presenceCutoff)
+		},
+	}
+
+	var total int64
+	var errs []error
+	for _, purge := range purges {
+		deleted, err := purge(ctx)
+		total += deleted
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// ListJobRuns returns the job run history, optionally for one job
+func (s *MaintenanceService) ListJobRuns(ctx context.Context, jobName string, page, pageSize int) (*repository.PaginatedResult[domain.JobRun], error) {
+	return s.jobRunRepo.FindFiltered(ctx, jobName, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
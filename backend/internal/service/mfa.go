@@ -0,0 +1,343 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gebase/internal/auth"
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+)
+
+var (
+	ErrMFANotEnrolled    = errors.New("mfa is not enrolled")
+	ErrMFAAlreadyEnabled = errors.New("mfa is already enabled")
+	ErrMFARequired       = errors.New("mfa is required by organization policy")
+	ErrInvalidMFACode    = errors.New("invalid mfa code")
+	ErrInvalidMFAToken   = errors.New("invalid mfa token")
+	ErrMFANotStarted     = errors.New("mfa enrollment has not been started")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type MFAService struct {
+	config        *config.Config
+	userRepo      *repository.UserRepository
+	mfaRepo       *repository.UserMFARepository
+	recoveryRepo  *repository.MFARecoveryCodeRepository
+	orgPolicyRepo *repository.OrganizationSecurityPolicyRepository
+	secretBox     *auth.SecretBox
+}
+
+func NewMFAService(
+	cfg *config.Config,
+	userRepo *repository.UserRepository,
+	mfaRepo *repository.UserMFARepository,
+	recoveryRepo *repository.MFARecoveryCodeRepository,
+	orgPolicyRepo *repository.OrganizationSecurityPolicyRepository,
+	secretBox *auth.SecretBox,
+) *MFAService {
+	return &MFAService{
+		config:        cfg,
+		userRepo:      userRepo,
+		mfaRepo:       mfaRepo,
+		recoveryRepo:  recoveryRepo,
+		orgPolicyRepo: orgPolicyRepo,
+		secretBox:     secretBox,
+	}
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFAStatus struct {
+	Enabled                bool       `json:"enabled"`
+	Required               bool       `json:"required"`
+	ConfirmedAt            *time.Time `json:"confirmed_at,omitempty"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+}
+
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// IsEnabled reports whether the user has a confirmed TOTP enrollment
+func (s *MFAService) IsEnabled(ctx context.Context, userID int64) (bool, error) {
+	mfa, err := s.mfaRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return mfa.IsConfirmed(), nil
+}
+
+// IsRequired reports whether the user's organization policy requires MFA for them
+func (s *MFAService) IsRequired(ctx context.Context, userID int64) (bool, error) {
+	user, err := s.userRepo.FindWithRoles(ctx, userID)
+	if err != nil {
+		return false, ErrUserNotFound
+	}
+	if user.OrganizationID == nil {
+		return false, nil
+	}
+
+	policy, err := s.orgPolicyRepo.FindByOrganizationID(ctx, *user.OrganizationID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if policy.RequireMFA != nil && *policy.RequireMFA {
+		return true, nil
+	}
+	if policy.RequireMFAForAdmins != nil && *policy.RequireMFAForAdmins {
+		for _, usr := range user.UserSystemRoles {
+			if usr.Role != nil && usr.Role.IsAdminRole() {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// GetStatus returns the MFA state of a user
+func (s *MFAService) GetStatus(ctx context.Context, userID int64) (*MFAStatus, error) {
+	required, err := s.IsRequired(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &MFAStatus{Required: required}
+
+	mfa, err := s.mfaRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return status, nil
+		}
+		return nil, err
+	}
+	if !mfa.IsConfirmed() {
+		return status, nil
+	}
+
+	status.Enabled = true
+	status.ConfirmedAt = mfa.ConfirmedAt
+	status.RecoveryCodesRemaining, err = s.recoveryRepo.CountUnused(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// BeginEnrollment generates a new TOTP secret. It only takes effect once
+// confirmed with ConfirmEnrollment; restarting replaces a pending secret.
+func (s *MFAService) BeginEnrollment(ctx context.Context, userID int64) (*MFAEnrollment, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	mfa, err := s.mfaRepo.FindByUserID(ctx, userID)
+	if err != nil && !repository.IsNotFound(err) {
+		return nil, err
+	}
+	if mfa != nil && mfa.IsConfirmed() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.secretBox.Seal([]byte(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	if mfa == nil {
+		mfa = &domain.UserMFA{
+			UserID:    userID,
+			SecretEnc: sealed,
+			IsEnabled: domain.Ptr(false),
+		}
+		if err := s.mfaRepo.Create(ctx, mfa); err != nil {
+			return nil, err
+		}
+	} else {
+		mfa.SecretEnc = sealed
+		mfa.IsEnabled = domain.Ptr(false)
+		mfa.ConfirmedAt = nil
+		mfa.LastUsedStep = 0
+		if err := s.mfaRepo.Update(ctx, mfa); err != nil {
+			return nil, err
+		}
+	}
+
+	return &MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(s.config.MFA.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment activates a pending enrollment with a first valid code and
+// returns a fresh set of recovery codes, shown to the user only once
+func (s *MFAService) ConfirmEnrollment(ctx context.Context, userID int64, code string) ([]string, error) {
+	mfa, err := s.mfaRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, ErrMFANotStarted
+		}
+		return nil, err
+	}
+	if mfa.IsConfirmed() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	if err := s.verifyTOTP(ctx, mfa, code); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	mfa.IsEnabled = domain.Ptr(true)
+	mfa.ConfirmedAt = &now
+	if err := s.mfaRepo.Update(ctx, mfa); err != nil {
+		return nil, err
+	}
+
+	return s.replaceRecoveryCodes(ctx, userID)
+}
+
+// Verify checks a TOTP code or, failing that, consumes a recovery code
+func (s *MFAService) Verify(ctx context.Context, userID int64, code string) error {
+	mfa, err := s.mfaRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return ErrMFANotEnrolled
+		}
+		return err
+	}
+	if !mfa.IsConfirmed() {
+		return ErrMFANotEnrolled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == auth.TOTPDigits {
+		return s.verifyTOTP(ctx, mfa, code)
+	}
+
+	consumed, err := s.recoveryRepo.Consume(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// Disable removes the user's enrollment after verifying a current code.
+// Users whose organization requires MFA cannot opt out.
+func (s *MFAService) Disable(ctx context.Context, userID int64, code string) error {
+	required, err := s.IsRequired(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrMFARequired
+	}
+
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+	return s.Reset(ctx, userID)
+}
+
+// Reset removes a user's enrollment and recovery codes (admin recovery)
+func (s *MFAService) Reset(ctx context.Context, userID int64) error {
+	if _, err := s.userRepo.FindByID(ctx, userID); err != nil {
+		return ErrUserNotFound
+	}
+	if err := s.recoveryRepo.DeleteByUserID(ctx, userID); err != nil {
+		return err
+	}
+	return s.mfaRepo.DeleteByUserID(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after verifying a current code
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error) {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(ctx, userID)
+}
+
+func (s *MFAService) verifyTOTP(ctx context.Context, mfa *domain.UserMFA, code string) error {
+	secret, err := s.secretBox.Open(mfa.SecretEnc)
+	if err != nil {
+		return err
+	}
+
+	step, ok := auth.ValidateTOTP(string(secret), code, time.Now(), mfa.LastUsedStep)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	// Conditional update so the same code cannot be accepted twice
+	marked, err := s.mfaRepo.MarkUsed(ctx, mfa.ID, step)
+	if err != nil {
+		return err
+	}
+	if !marked {
+		return ErrInvalidMFACode
+	}
+	mfa.LastUsedStep = step
+	return nil
+}
+
+func (s *MFAService) replaceRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	count := s.config.MFA.RecoveryCodeCount
+	codes := make([]string, count)
+	hashes := make([]string, count)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashRecoveryCode(code)
+	}
+
+	if err := s.recoveryRepo.Replace(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a code formatted as xxxxx-xxxxx
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, 7)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	encoded := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))[:10]
+	return encoded[:5] + "-" + encoded[5:], nil
+}
+
+// hashRecoveryCode normalizes case and separators before hashing
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gebase/internal/auth"
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+)
+
+// newMFATestService has user 1 enrolled in TOTP with the returned secret
+func newMFATestService(t *testing.T) (*MFAService, string) {
+	t.Helper()
+
+	db := newTestDB(t, &domain.User{}, &domain.UserMFA{}, &domain.MFARecoveryCode{})
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: "test-secret"}}
+	box := auth.NewSecretBox(cfg)
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("generate secret: %v", err)
+	}
+	secretEnc, err := box.Seal([]byte(secret))
+	if err != nil {
+		t.Fatalf("seal secret: %v", err)
+	}
+	confirmedAt := time.Now()
+	for _, record := range []interface{}{
+		&domain.User{ID: 1, Email: "alice@example.com", IsActive: domain.Ptr(true)},
+		&domain.UserMFA{UserID: 1, SecretEnc: secretEnc, IsEnabled: domain.Ptr(true), ConfirmedAt: &confirmedAt},
+	} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("create %T: %v", record, err)
+		}
+	}
+
+	return NewMFAService(cfg, repository.NewUserRepository(db), repository.NewUserMFARepository(db),
+		repository.NewMFARecoveryCodeRepository(db), nil, box), secret
+}
+
+func TestVerifyRejectsReplayedTOTPCode(t *testing.T) {
+	s, secret := newMFATestService(t)
+	ctx := context.Background()
+	code := func(step int64) string {
+		code, err := auth.TOTPCode(secret, step)
+		if err != nil {
+			t.Fatalf("TOTPCode: %v", err)
+		}
+		return code
+	}
+	current := auth.TOTPStep(time.Now())
+
+	if err := s.Verify(ctx, 1, code(current)); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := s.Verify(ctx, 1, code(current)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("replayed code error = %v, want ErrInvalidMFACode", err)
+	}
+	// Codes of earlier steps are still within the skew but already spent
+	if err := s.Verify(ctx, 1, code(current-1)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("code of the previous step error = %v, want ErrInvalidMFACode", err)
+	}
+	if err := s.Verify(ctx, 1, code(current+1)); err != nil {
+		t.Fatalf("code of the next step: %v", err)
+	}
+}
@@ -0,0 +1,789 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gebase/internal/auth"
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+	ErrOAuthClientIDExists = errors.New("oauth client id already exists")
+)
+
+// Scopes understood by the authorization server itself; clients may be
+// registered with additional API scopes
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeOfflineAccess = "offline_access"
+)
+
+// OAuthError is an RFC 6749 error response
+type OAuthError struct {
+	Code        string
+	Description string
+	Status      int
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(status int, code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description, Status: status}
+}
+
+// AuthorizeRequest carries the authorization request parameters. Approve is
+// the user's consent decision; it is omitted until the consent screen is shown.
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type" form:"response_type"`
+	ClientID            string `json:"client_id" form:"client_id" binding:"required"`
+	RedirectURI         string `json:"redirect_uri" form:"redirect_uri" binding:"required"`
+	Scope               string `json:"scope" form:"scope"`
+	State               string `json:"state" form:"state"`
+	Nonce               string `json:"nonce" form:"nonce"`
+	CodeChallenge       string `json:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" form:"code_challenge_method"`
+	Approve             *bool  `json:"approve" form:"-"`
+}
+
+// AuthorizeResult either asks for consent or tells the frontend where to send the browser
+type AuthorizeResult struct {
+	ConsentRequired bool                `json:"consent_required"`
+	Client          *OAuthClientSummary `json:"client,omitempty"`
+	Scopes          []string            `json:"scopes,omitempty"`
+	RedirectURL     string              `json:"redirect_url,omitempty"`
+}
+
+// OAuthClientSummary is the public information shown on the consent screen
+type OAuthClientSummary struct {
+	ClientID    string `json:"client_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// TokenRequest carries the token endpoint form parameters
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// TokenResponse is the RFC 6749 token response
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// DiscoveryDocument is served at /.well-known/openid-configuration
+type DiscoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+type CreateOAuthClientRequest struct {
+	ClientID       string   `json:"client_id"`
+	Name           string   `json:"name" binding:"required"`
+	Description    string   `json:"description"`
+	RedirectURIs   []string `json:"redirect_uris"`
+	GrantTypes     []string `json:"grant_types" binding:"required,min=1"`
+	Scopes         []string `json:"scopes"`
+	IsConfidential *bool    `json:"is_confidential"`
+	IsFirstParty   *bool    `json:"is_first_party"`
+	OrganizationID *int64   `json:"organization_id"`
+}
+
+type UpdateOAuthClientRequest struct {
+	Name           *string  `json:"name"`
+	Description    *string  `json:"description"`
+	RedirectURIs   []string `json:"redirect_uris"`
+	GrantTypes     []string `json:"grant_types"`
+	Scopes         []string `json:"scopes"`
+	IsFirstParty   *bool    `json:"is_first_party"`
+	IsActive       *bool    `json:"is_active"`
+	OrganizationID *int64   `json:"organization_id"`
+}
+
+// OAuthClientCredentials is returned once when a client is created or its secret rotated
+type OAuthClientCredentials struct {
+	Client       *domain.OAuthClient `json:"client"`
+	ClientSecret string              `json:"client_secret,omitempty"`
+}
+
+// OAuthService implements the built-in OAuth 2.0 / OpenID Connect
+// authorization server. User-facing tokens are OAuth tokens bound to a
+// session of their own, accepted only by routes mapped to their scopes.
+type OAuthService struct {
+	config         *config.Config
+	clientRepo     *repository.OAuthClientRepository
+	consentRepo    *repository.OAuthConsentRepository
+	codeRepo       *repository.OAuthAuthorizationCodeRepository
+	userRepo       *repository.UserRepository
+	deviceRepo     *repository.DeviceRepository
+	jwtService     *auth.JWTService
+	sessionService *auth.SessionService
+	proofVerifier  *auth.DeviceProofVerifier
+}
+
+func NewOAuthService(
+	cfg *config.Config,
+	clientRepo *repository.OAuthClientRepository,
+	consentRepo *repository.OAuthConsentRepository,
+	codeRepo *repository.OAuthAuthorizationCodeRepository,
+	userRepo *repository.UserRepository,
+	deviceRepo *repository.DeviceRepository,
+	jwtService *auth.JWTService,
+	sessionService *auth.SessionService,
+	proofVerifier *auth.DeviceProofVerifier,
+) *OAuthService {
+	return &OAuthService{
+		config:         cfg,
+		clientRepo:     clientRepo,
+		consentRepo:    consentRepo,
+		codeRepo:       codeRepo,
+		userRepo:       userRepo,
+		deviceRepo:     deviceRepo,
+		jwtService:     jwtService,
+		sessionService: sessionService,
+		proofVerifier:  proofVerifier,
+	}
+}
+
+// Discovery returns the OpenID provider metadata
+func (s *OAuthService) Discovery() *DiscoveryDocument {
+	issuer := strings.TrimSuffix(s.config.OAuth.Issuer, "/")
+	return &DiscoveryDocument{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserinfoEndpoint:                  issuer + "/oauth/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{domain.OAuthGrantAuthorizationCode, domain.OAuthGrantRefreshToken, domain.OAuthGrantClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{s.jwtService.Algorithm()},
+		ScopesSupported:                   []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"sub", "email", "name", "given_name", "family_name", "organization_id", "nonce", "auth_time"},
+	}
+}
+
+// ValidateAuthorizeRequest checks the client and redirect URI. Errors for an
+// unknown client or redirect URI must be shown to the user rather than
+// redirected; the returned bool reports whether redirecting is safe.
+func (s *OAuthService) ValidateAuthorizeRequest(ctx context.Context, req *AuthorizeRequest) (*domain.OAuthClient, []string, bool, error) {
+	client, err := s.clientRepo.FindByClientID(ctx, req.ClientID)
+	if err != nil || client.IsActive == nil || !*client.IsActive {
+		return nil, nil, false, oauthError(http.StatusBadRequest, "invalid_client", "unknown client")
+	}
+	if !client.HasRedirectURI(req.RedirectURI) {
+		return nil, nil, false, oauthError(http.StatusBadRequest, "invalid_request", "redirect_uri is not registered for this client")
+	}
+
+	if req.ResponseType != "code" {
+		return client, nil, true, oauthError(http.StatusBadRequest, "unsupported_response_type", "only the code response type is supported")
+	}
+	if !client.AllowsGrant(domain.OAuthGrantAuthorizationCode) {
+		return client, nil, true, oauthError(http.StatusBadRequest, "unauthorized_client", "client may not use the authorization code grant")
+	}
+
+	scopes := strings.Fields(req.Scope)
+	for _, scope := range scopes {
+		if !client.AllowsScope(scope) {
+			return client, nil, true, oauthError(http.StatusBadRequest, "invalid_scope", "scope "+scope+" is not allowed for this client")
+		}
+	}
+
+	if req.CodeChallenge == "" && client.IsPublic() {
+		return client, nil, true, oauthError(http.StatusBadRequest, "invalid_request", "public clients must use PKCE")
+	}
+	if req.CodeChallenge != "" && req.CodeChallengeMethod != "S256" {
+		return client, nil, true, oauthError(http.StatusBadRequest, "invalid_request", "code_challenge_method must be S256")
+	}
+
+	return client, scopes, true, nil
+}
+
+// Authorize handles the decision of a logged-in user. It returns the
+// consent screen details when the user has not yet granted the scopes, and
+// otherwise the redirect URL carrying the authorization code or the error.
+func (s *OAuthService) Authorize(ctx context.Context, claims *auth.Claims, req *AuthorizeRequest) (*AuthorizeResult, error) {
+	client, scopes, redirectable, err := s.ValidateAuthorizeRequest(ctx, req)
+	if err != nil {
+		var oauthErr *OAuthError
+		if redirectable && errors.As(err, &oauthErr) {
+			return &AuthorizeResult{RedirectURL: authorizeRedirect(req, url.Values{
+				"error":             {oauthErr.Code},
+				"error_description": {oauthErr.Description},
+			})}, nil
+		}
+		return nil, err
+	}
+
+	if req.Approve != nil && !*req.Approve {
+		return &AuthorizeResult{RedirectURL: authorizeRedirect(req, url.Values{
+			"error":             {"access_denied"},
+			"error_description": {"the user denied the request"},
+		})}, nil
+	}
+
+	firstParty := client.IsFirstParty != nil && *client.IsFirstParty
+	if !firstParty {
+		consent, err := s.consentRepo.FindByUserAndClient(ctx, claims.UserID, client.ID)
+		if err != nil && !repository.IsNotFound(err) {
+			return nil, err
+		}
+		granted := consent != nil && consent.Covers(scopes)
+
+		if !granted {
+			if req.Approve == nil {
+				return &AuthorizeResult{
+					ConsentRequired: true,
+					Client: &OAuthClientSummary{
+						ClientID:    client.ClientID,
+						Name:        client.Name,
+						Description: client.Description,
+					},
+					Scopes: scopes,
+				}, nil
+			}
+			if err := s.recordConsent(ctx, claims.UserID, client.ID, consent, scopes); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	code, err := randomURLToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	// auth_time is when the user entered their credentials, not when the
+	// access token presented here was refreshed
+	session, err := s.sessionService.GetSessionByID(ctx, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.AuthenticatedAt == nil {
+		return &AuthorizeResult{RedirectURL: authorizeRedirect(req, url.Values{
+			"error":             {"login_required"},
+			"error_description": {"the session does not record when the user authenticated"},
+		})}, nil
+	}
+	authTime := *session.AuthenticatedAt
+	if err := s.codeRepo.Create(ctx, &domain.OAuthAuthorizationCode{
+		CodeHash:            hashOAuthSecret(code),
+		ClientID:            client.ID,
+		UserID:              claims.UserID,
+		DeviceID:            claims.DeviceID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               strings.Join(scopes, " "),
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		AuthTime:            authTime,
+		ExpiresAt:           time.Now().Add(s.config.OAuth.CodeExpiry),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &AuthorizeResult{RedirectURL: authorizeRedirect(req, url.Values{
+		"code": {code},
+		"iss":  {strings.TrimSuffix(s.config.OAuth.Issuer, "/")},
+	})}, nil
+}
+
+// Token implements the token endpoint for the authorization code, refresh
+// token and client credentials grants. Refresh tokens bound to a device key
+// must come with a proof signed by it.
+func (s *OAuthService) Token(ctx context.Context, req *TokenRequest, proof *auth.DeviceProofRequest, ipAddress, userAgent string) (*TokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrant(req.GrantType) {
+		return nil, oauthError(http.StatusBadRequest, "unauthorized_client", "client may not use this grant type")
+	}
+
+	switch req.GrantType {
+	case domain.OAuthGrantAuthorizationCode:
+		return s.exchangeCode(ctx, client, req, ipAddress, userAgent)
+	case domain.OAuthGrantRefreshToken:
+		return s.refresh(ctx, client, req, proof)
+	case domain.OAuthGrantClientCredentials:
+		return s.clientCredentials(client, req)
+	default:
+		return nil, oauthError(http.StatusBadRequest, "unsupported_grant_type", "grant type is not supported")
+	}
+}
+
+func (s *OAuthService) exchangeCode(ctx context.Context, client *domain.OAuthClient, req *TokenRequest, ipAddress, userAgent string) (*TokenResponse, error) {
+	invalidGrant := oauthError(http.StatusBadRequest, "invalid_grant", "authorization code is invalid or expired")
+
+	code, err := s.codeRepo.FindByHash(ctx, hashOAuthSecret(req.Code))
+	if err != nil || !code.IsUsable() || code.ClientID != client.ID || code.RedirectURI != req.RedirectURI {
+		return nil, invalidGrant
+	}
+	if code.CodeChallenge != "" {
+		if req.CodeVerifier == "" || subtle.ConstantTimeCompare([]byte(auth.PKCEChallenge(req.CodeVerifier)), []byte(code.CodeChallenge)) != 1 {
+			return nil, oauthError(http.StatusBadRequest, "invalid_grant", "code_verifier does not match the code challenge")
+		}
+	}
+
+	used, err := s.codeRepo.MarkUsed(ctx, code.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, invalidGrant
+	}
+
+	user, err := s.userRepo.FindByID(ctx, code.UserID)
+	if err != nil || user.IsActive == nil || !*user.IsActive {
+		return nil, invalidGrant
+	}
+
+	session, err := s.sessionService.CreateClientSession(ctx, user.ID, code.DeviceID, client.ID, ipAddress, userAgent, user.OrganizationID, code.AuthTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueUserTokens(ctx, client, user, session, strings.Fields(code.Scope), code.Nonce, code.AuthTime, nil)
+}
+
+func (s *OAuthService) refresh(ctx context.Context, client *domain.OAuthClient, req *TokenRequest, proof *auth.DeviceProofRequest) (*TokenResponse, error) {
+	invalidGrant := oauthError(http.StatusBadRequest, "invalid_grant", "refresh token is invalid or expired")
+
+	claims, err := s.jwtService.ValidateToken(req.RefreshToken)
+	if err != nil || !claims.IsRefreshToken() || claims.ClientID != client.ClientID {
+		return nil, invalidGrant
+	}
+	if claims.IsDeviceBound() {
+		device, err := s.deviceRepo.FindByID(ctx, claims.DeviceID)
+		if err != nil {
+			return nil, invalidGrant
+		}
+		if err := s.proofVerifier.VerifyBinding(ctx, device, claims, proof); err != nil {
+			return nil, oauthError(http.StatusBadRequest, "invalid_grant", "refresh token is bound to a device key; a valid X-Device-Proof is required")
+		}
+	}
+
+	session, err := s.sessionService.GetSessionByID(ctx, claims.SessionID)
+	if err != nil || !s.sessionService.IsSessionValid(ctx, session) {
+		return nil, invalidGrant
+	}
+
+	// A narrower scope may be requested, never a wider one
+	scopes := strings.Fields(claims.Scope)
+	if req.Scope != "" {
+		requested := strings.Fields(req.Scope)
+		for _, scope := range requested {
+			if !containsScope(scopes, scope) {
+				return nil, oauthError(http.StatusBadRequest, "invalid_scope", "scope "+scope+" was not granted")
+			}
+		}
+		scopes = requested
+		if !containsScope(scopes, ScopeOfflineAccess) {
+			scopes = append(scopes, ScopeOfflineAccess)
+		}
+	}
+
+	previous, err := s.sessionService.RotateRefreshToken(ctx, claims)
+	if err != nil {
+		return nil, invalidGrant
+	}
+
+	user, err := s.userRepo.FindByID(ctx, claims.UserID)
+	if err != nil || user.IsActive == nil || !*user.IsActive {
+		return nil, invalidGrant
+	}
+
+	if session.AuthenticatedAt == nil {
+		return nil, invalidGrant
+	}
+	return s.issueUserTokens(ctx, client, user, session, scopes, "", *session.AuthenticatedAt, &previous.ID)
+}
+
+func (s *OAuthService) clientCredentials(client *domain.OAuthClient, req *TokenRequest) (*TokenResponse, error) {
+	if client.IsPublic() {
+		return nil, oauthError(http.StatusBadRequest, "unauthorized_client", "public clients may not use client credentials")
+	}
+
+	var scopes []string
+	if req.Scope == "" {
+		// Default to every API scope of the client; user scopes make no sense here
+		for _, scope := range strings.Fields(client.Scopes) {
+			if !isUserScope(scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	} else {
+		for _, scope := range strings.Fields(req.Scope) {
+			if !client.AllowsScope(scope) || isUserScope(scope) {
+				return nil, oauthError(http.StatusBadRequest, "invalid_scope", "scope "+scope+" is not allowed for this client")
+			}
+			scopes = append(scopes, scope)
+		}
+	}
+
+	scope := strings.Join(scopes, " ")
+	token, claims, err := s.jwtService.GenerateClientToken(client.ClientID, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(claims.ExpiresAt.Time).Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// issueUserTokens issues the access token and, depending on the scopes, a
+// refresh token (offline_access) and an ID token (openid)
+func (s *OAuthService) issueUserTokens(ctx context.Context, client *domain.OAuthClient, user *domain.User, session *domain.Session, scopes []string, nonce string, authTime time.Time, parentRefreshID *int64) (*TokenResponse, error) {
+	scope := strings.Join(scopes, " ")
+
+	accessToken, claims, err := s.jwtService.GenerateOAuthAccessToken(user, session, client.ClientID, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(claims.ExpiresAt.Time).Seconds()),
+		Scope:       scope,
+	}
+
+	if containsScope(scopes, ScopeOfflineAccess) && client.AllowsGrant(domain.OAuthGrantRefreshToken) {
+		refreshToken, refreshClaims, err := s.jwtService.GenerateOAuthRefreshToken(user, session, client.ClientID, scope)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.sessionService.IssueRefreshToken(ctx, refreshClaims, parentRefreshID); err != nil {
+			return nil, err
+		}
+		result.RefreshToken = refreshToken
+	}
+
+	if containsScope(scopes, ScopeOpenID) {
+		idToken, err := s.jwtService.GenerateIDToken(user, client.ClientID, nonce, authTime, scopes)
+		if err != nil {
+			return nil, err
+		}
+		result.IDToken = idToken
+	}
+
+	return result, nil
+}
+
+// UserInfo returns the OpenID claims of the token's user, limited to the
+// scopes granted to the client. First-party platform tokens see every claim.
+func (s *OAuthService) UserInfo(ctx context.Context, claims *auth.Claims) (map[string]interface{}, error) {
+	user, err := s.userRepo.FindByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	scopes := []string{ScopeProfile, ScopeEmail}
+	if claims.IsOAuthToken() {
+		scopes = strings.Fields(claims.Scope)
+		if !containsScope(scopes, ScopeOpenID) {
+			return nil, oauthError(http.StatusForbidden, "insufficient_scope", "the openid scope is required")
+		}
+	}
+
+	info := map[string]interface{}{
+		"sub": strconv.FormatInt(user.ID, 10),
+	}
+	if containsScope(scopes, ScopeEmail) {
+		info["email"] = user.Email
+	}
+	if containsScope(scopes, ScopeProfile) {
+		info["name"] = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		info["given_name"] = user.FirstName
+		info["family_name"] = user.LastName
+		info["locale"] = user.LanguageCode
+		if user.OrganizationID != nil {
+			info["organization_id"] = *user.OrganizationID
+		}
+	}
+	return info, nil
+}
+
+// ListConsents returns the clients a user has granted access to
+func (s *OAuthService) ListConsents(ctx context.Context, userID int64) ([]domain.OAuthConsent, error) {
+	return s.consentRepo.FindByUser(ctx, userID)
+}
+
+// RevokeConsent withdraws a client's access and ends the sessions it holds
+func (s *OAuthService) RevokeConsent(ctx context.Context, userID int64, clientID string) error {
+	client, err := s.clientRepo.FindByClientID(ctx, clientID)
+	if err != nil {
+		return ErrOAuthClientNotFound
+	}
+	if err := s.consentRepo.DeleteByUserAndClient(ctx, userID, client.ID); err != nil {
+		return err
+	}
+	return s.sessionService.LogoutOAuthClient(ctx, userID, client.ID, auth.LogoutReasonConsentRevoked)
+}
+
+// ListClients returns registered clients
+func (s *OAuthService) ListClients(ctx context.Context, page, pageSize int) (*repository.PaginatedResult[domain.OAuthClient], error) {
+	return s.clientRepo.FindWithPagination(ctx, repository.PaginationParams{Page: page, PageSize: pageSize})
+}
+
+// GetClient returns a registered client
+func (s *OAuthService) GetClient(ctx context.Context, id int64) (*domain.OAuthClient, error) {
+	client, err := s.clientRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrOAuthClientNotFound
+	}
+	return client, nil
+}
+
+// CreateClient registers a client. Confidential clients get a secret that is
+// only returned here.
+func (s *OAuthService) CreateClient(ctx context.Context, req *CreateOAuthClientRequest, createdBy int64) (*OAuthClientCredentials, error) {
+	clientID := req.ClientID
+	if clientID == "" {
+		generated, err := randomURLToken(16)
+		if err != nil {
+			return nil, err
+		}
+		clientID = generated
+	} else if _, err := s.clientRepo.FindByClientID(ctx, clientID); err == nil {
+		return nil, ErrOAuthClientIDExists
+	}
+
+	confidential := req.IsConfidential == nil || *req.IsConfidential
+	client := &domain.OAuthClient{
+		ClientID:       clientID,
+		Name:           req.Name,
+		Description:    req.Description,
+		RedirectURIs:   strings.Join(req.RedirectURIs, " "),
+		GrantTypes:     strings.Join(req.GrantTypes, " "),
+		Scopes:         strings.Join(req.Scopes, " "),
+		IsConfidential: domain.Ptr(confidential),
+		IsFirstParty:   domain.Ptr(req.IsFirstParty != nil && *req.IsFirstParty),
+		IsActive:       domain.Ptr(true),
+		OrganizationID: req.OrganizationID,
+	}
+	client.CreatedBy = &createdBy
+
+	var secret string
+	if confidential {
+		var err error
+		secret, client.SecretHash, err = generateClientSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.clientRepo.Create(ctx, client); err != nil {
+		return nil, err
+	}
+
+	return &OAuthClientCredentials{Client: client, ClientSecret: secret}, nil
+}
+
+// UpdateClient changes a client's registration
+func (s *OAuthService) UpdateClient(ctx context.Context, id int64, req *UpdateOAuthClientRequest, updatedBy int64) (*domain.OAuthClient, error) {
+	client, err := s.clientRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrOAuthClientNotFound
+	}
+
+	if req.Name != nil {
+		client.Name = *req.Name
+	}
+	if req.Description != nil {
+		client.Description = *req.Description
+	}
+	if req.RedirectURIs != nil {
+		client.RedirectURIs = strings.Join(req.RedirectURIs, " ")
+	}
+	if req.GrantTypes != nil {
+		client.GrantTypes = strings.Join(req.GrantTypes, " ")
+	}
+	if req.Scopes != nil {
+		client.Scopes = strings.Join(req.Scopes, " ")
+	}
+	if req.IsFirstParty != nil {
+		client.IsFirstParty = req.IsFirstParty
+	}
+	if req.IsActive != nil {
+		client.IsActive = req.IsActive
+	}
+	if req.OrganizationID != nil {
+		client.OrganizationID = req.OrganizationID
+	}
+	client.UpdatedBy = &updatedBy
+
+	if err := s.clientRepo.Update(ctx, client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// RotateClientSecret replaces a confidential client's secret
+func (s *OAuthService) RotateClientSecret(ctx context.Context, id int64, updatedBy int64) (*OAuthClientCredentials, error) {
+	client, err := s.clientRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrOAuthClientNotFound
+	}
+	if client.IsPublic() {
+		return nil, oauthError(http.StatusBadRequest, "invalid_request", "public clients have no secret")
+	}
+
+	secret, hash, err := generateClientSecret()
+	if err != nil {
+		return nil, err
+	}
+	client.SecretHash = hash
+	client.UpdatedBy = &updatedBy
+	if err := s.clientRepo.Update(ctx, client); err != nil {
+		return nil, err
+	}
+
+	return &OAuthClientCredentials{Client: client, ClientSecret: secret}, nil
+}
+
+// DeleteClient removes a client registration
+func (s *OAuthService) DeleteClient(ctx context.Context, id int64) error {
+	if _, err := s.clientRepo.FindByID(ctx, id); err != nil {
+		return ErrOAuthClientNotFound
+	}
+	return s.clientRepo.Delete(ctx, id)
+}
+
+// authenticateClient verifies client_secret_basic/post credentials; public
+// clients identify themselves by client_id only
+func (s *OAuthService) authenticateClient(ctx context.Context, clientID, clientSecret string) (*domain.OAuthClient, error) {
+	invalidClient := oauthError(http.StatusUnauthorized, "invalid_client", "client authentication failed")
+
+	if clientID == "" {
+		return nil, invalidClient
+	}
+	client, err := s.clientRepo.FindByClientID(ctx, clientID)
+	if err != nil || client.IsActive == nil || !*client.IsActive {
+		return nil, invalidClient
+	}
+
+	if client.IsPublic() {
+		if clientSecret != "" {
+			return nil, invalidClient
+		}
+		return client, nil
+	}
+	if clientSecret == "" || bcrypt.CompareHashAndPassword([]byte(client.SecretHash), []byte(clientSecret)) != nil {
+		return nil, invalidClient
+	}
+	return client, nil
+}
+
+func (s *OAuthService) recordConsent(ctx context.Context, userID, clientID int64, consent *domain.OAuthConsent, scopes []string) error {
+	if consent == nil {
+		return s.consentRepo.Create(ctx, &domain.OAuthConsent{
+			UserID:    userID,
+			ClientID:  clientID,
+			Scopes:    strings.Join(scopes, " "),
+			GrantedAt: time.Now(),
+		})
+	}
+
+	granted := strings.Fields(consent.Scopes)
+	for _, scope := range scopes {
+		if !containsScope(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	consent.Scopes = strings.Join(granted, " ")
+	consent.GrantedAt = time.Now()
+	return s.consentRepo.Update(ctx, consent)
+}
+
+// authorizeRedirect appends the response parameters and state to the redirect URI
+func authorizeRedirect(req *AuthorizeRequest, params url.Values) string {
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	separator := "?"
+	if strings.Contains(req.RedirectURI, "?") {
+		separator = "&"
+	}
+	return req.RedirectURI + separator + params.Encode()
+}
+
+func generateClientSecret() (string, string, error) {
+	secret, err := randomURLToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+	return secret, string(hash), nil
+}
+
+func hashOAuthSecret(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func isUserScope(scope string) bool {
+	switch scope {
+	case ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,399 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gebase/internal/auth"
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+)
+
+var (
+	ErrOIDCDisabled           = errors.New("oidc login is not enabled")
+	ErrOIDCInvalidState       = errors.New("oidc login state is invalid or expired")
+	ErrOIDCInvalidSubject     = errors.New("oidc subject is not a valid sso user id")
+	ErrOIDCMissingEmail       = errors.New("oidc identity has no email")
+	ErrOIDCAccountConflict    = errors.New("email is already used by another account")
+	ErrOIDCUserNotProvisioned = errors.New("user has not been provisioned")
+)
+
+type OIDCLoginRequest struct {
+	DeviceUID string `json:"device_uid" binding:"required"`
+}
+
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// OIDCAuthorization is where the client must send the browser to log in
+type OIDCAuthorization struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+	ExpiresIn        int64  `json:"expires_in"`
+}
+
+// OIDCService logs users in through an external OpenID Connect provider.
+// IdP identities map to User.SsoUserID and Organization.SsoOrgID; unknown
+// ones are provisioned just in time.
+type OIDCService struct {
+	config          *config.Config
+	client          *auth.OIDCClient
+	stateRepo       *repository.OIDCLoginStateRepository
+	userRepo        *repository.UserRepository
+	orgRepo         *repository.OrganizationRepository
+	deviceRepo      *repository.DeviceRepository
+	loginProtection *LoginProtectionService
+	authService     *AuthService
+}
+
+func NewOIDCService(
+	cfg *config.Config,
+	client *auth.OIDCClient,
+	stateRepo *repository.OIDCLoginStateRepository,
+	userRepo *repository.UserRepository,
+	orgRepo *repository.OrganizationRepository,
+	deviceRepo *repository.DeviceRepository,
+	loginProtection *LoginProtectionService,
+	authService *AuthService,
+) *OIDCService {
+	return &OIDCService{
+		config:          cfg,
+		client:          client,
+		stateRepo:       stateRepo,
+		userRepo:        userRepo,
+		orgRepo:         orgRepo,
+		deviceRepo:      deviceRepo,
+		loginProtection: loginProtection,
+		authService:     authService,
+	}
+}
+
+// BeginLogin stores a new state, nonce and PKCE verifier and returns the
+// provider authorization URL
+func (s *OIDCService) BeginLogin(ctx context.Context, req *OIDCLoginRequest, ipAddress string) (*OIDCAuthorization, error) {
+	if !s.config.OIDC.Enabled {
+		return nil, ErrOIDCDisabled
+	}
+
+	device, err := s.deviceRepo.FindByUID(ctx, req.DeviceUID)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	if err := checkDeviceUsable(device); err != nil {
+		return nil, err
+	}
+
+	state, err := randomURLToken(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomURLToken(32)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomURLToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	authorizationURL, err := s.client.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry := s.config.OIDC.StateExpiry
+	if err := s.stateRepo.Create(ctx, &domain.OIDCLoginState{
+		StateHash:    hashOIDCState(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		DeviceUID:    req.DeviceUID,
+		IPAddress:    ipAddress,
+		ExpiresAt:    time.Now().Add(expiry),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &OIDCAuthorization{
+		AuthorizationURL: authorizationURL,
+		State:            state,
+		ExpiresIn:        int64(expiry.Seconds()),
+	}, nil
+}
+
+// CompleteLogin redeems the authorization code returned to the redirect URL
+// and creates a normal session with platform and refresh tokens. The second
+// factor is the identity provider's responsibility.
+func (s *OIDCService) CompleteLogin(ctx context.Context, req *OIDCCallbackRequest, ipAddress, userAgent string) (*LoginResponse, error) {
+	if !s.config.OIDC.Enabled {
+		return nil, ErrOIDCDisabled
+	}
+
+	state, err := s.stateRepo.FindByHash(ctx, hashOIDCState(req.State))
+	if err != nil || !state.IsUsable() {
+		return nil, ErrOIDCInvalidState
+	}
+	used, err := s.stateRepo.MarkUsed(ctx, state.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrOIDCInvalidState
+	}
+
+	token, err := s.client.Exchange(ctx, req.Code, state.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := s.client.VerifyIDToken(ctx, token.IDToken, state.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// Userinfo only fills claims the ID token does not carry
+	if token.AccessToken != "" {
+		info, err := s.client.UserInfo(ctx, token.AccessToken)
+		if err != nil {
+			log.Printf("Warning: failed to fetch oidc userinfo: %v", err)
+		} else if info["sub"] == claims["sub"] {
+			for key, value := range info {
+				if _, exists := claims[key]; !exists {
+					claims[key] = value
+				}
+			}
+		}
+	}
+
+	user, err := s.resolveUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsActive == nil || !*user.IsActive {
+		return nil, ErrUserInactive
+	}
+
+	attempt := LoginAttemptInfo{
+		Email:     user.Email,
+		IPAddress: ipAddress,
+		DeviceUID: state.DeviceUID,
+		UserAgent: userAgent,
+	}
+
+	// Administrative lockouts apply to federated logins too
+	if err := s.loginProtection.Check(ctx, &user.ID, ipAddress); err != nil {
+		var blocked *LoginBlockedError
+		if errors.As(err, &blocked) {
+			_ = s.loginProtection.RecordBlocked(ctx, &user.ID, attempt)
+		}
+		return nil, err
+	}
+
+	device, err := s.deviceRepo.FindByUID(ctx, state.DeviceUID)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	if err := checkDeviceUsable(device); err != nil {
+		return nil, err
+	}
+	if err := s.authService.deviceEnrollment.AdmitUser(ctx, device, user); err != nil {
+		return nil, err
+	}
+
+	return s.authService.completeLogin(ctx, user, device, ipAddress, userAgent)
+}
+
+// resolveUser finds the user bound to the IdP subject, links an existing
+// account with the same verified email, or provisions a new one
+func (s *OIDCService) resolveUser(ctx context.Context, claims map[string]interface{}) (*domain.User, error) {
+	cfg := s.config.OIDC
+
+	ssoUserID, ok := claimInt64(claims, cfg.UserIDClaim)
+	if !ok || ssoUserID <= 0 {
+		return nil, ErrOIDCInvalidSubject
+	}
+
+	org, err := s.resolveOrganization(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindBySsoUserID(ctx, ssoUserID)
+	if err == nil {
+		return s.syncUser(ctx, user, claims, org, nil)
+	}
+	if !repository.IsNotFound(err) {
+		return nil, err
+	}
+
+	email := strings.ToLower(claimString(claims, "email"))
+	if email == "" {
+		return nil, ErrOIDCMissingEmail
+	}
+
+	existing, err := s.userRepo.FindByEmail(ctx, email)
+	if err == nil {
+		// Only a verified email may take over an unlinked local account
+		if existing.SsoUserID != 0 || existing.IsServiceAccount() || !claimBool(claims, "email_verified") {
+			return nil, ErrOIDCAccountConflict
+		}
+		return s.syncUser(ctx, existing, claims, org, &ssoUserID)
+	}
+	if !repository.IsNotFound(err) {
+		return nil, err
+	}
+
+	if !cfg.ProvisionUsers {
+		return nil, ErrOIDCUserNotProvisioned
+	}
+
+	user = &domain.User{
+		SsoUserID:          ssoUserID,
+		Email:              email,
+		FirstName:          claimString(claims, "given_name"),
+		LastName:           claimString(claims, "family_name"),
+		RegNo:              claimString(claims, cfg.RegNoClaim),
+		IsActive:           domain.Ptr(true),
+		MustChangePassword: domain.Ptr(false),
+	}
+	if user.FirstName == "" {
+		user.FirstName = claimString(claims, "name")
+	}
+	if org != nil {
+		user.OrganizationID = &org.ID
+	}
+	if err := s.userRepo.CreateProvisioned(ctx, user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Provisioned user %d from oidc subject %d", user.ID, ssoUserID)
+	return user, nil
+}
+
+// syncUser refreshes the profile fields managed by the identity provider
+func (s *OIDCService) syncUser(ctx context.Context, user *domain.User, claims map[string]interface{}, org *domain.Organization, linkSsoUserID *int64) (*domain.User, error) {
+	updates := map[string]interface{}{}
+	if linkSsoUserID != nil {
+		updates["sso_user_id"] = *linkSsoUserID
+		user.SsoUserID = *linkSsoUserID
+	}
+	if firstName := claimString(claims, "given_name"); firstName != "" && firstName != user.FirstName {
+		updates["first_name"] = firstName
+		user.FirstName = firstName
+	}
+	if lastName := claimString(claims, "family_name"); lastName != "" && lastName != user.LastName {
+		updates["last_name"] = lastName
+		user.LastName = lastName
+	}
+	if org != nil && (user.OrganizationID == nil || *user.OrganizationID != org.ID) {
+		updates["organization_id"] = org.ID
+		user.OrganizationID = &org.ID
+	}
+
+	if len(updates) > 0 {
+		if err := s.userRepo.UpdateSSOProfile(ctx, user.ID, updates); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// resolveOrganization maps the organization claim to an organization,
+// provisioning it when allowed. Identities without the claim have none.
+func (s *OIDCService) resolveOrganization(ctx context.Context, claims map[string]interface{}) (*domain.Organization, error) {
+	cfg := s.config.OIDC
+	if cfg.OrgIDClaim == "" {
+		return nil, nil
+	}
+
+	ssoOrgID, ok := claimInt64(claims, cfg.OrgIDClaim)
+	if !ok || ssoOrgID <= 0 {
+		return nil, nil
+	}
+
+	org, err := s.orgRepo.FindBySsoOrgID(ctx, ssoOrgID)
+	if err == nil {
+		return org, nil
+	}
+	if !repository.IsNotFound(err) {
+		return nil, err
+	}
+	if !cfg.ProvisionOrganizations {
+		return nil, nil
+	}
+
+	name := claimString(claims, cfg.OrgNameClaim)
+	if name == "" {
+		name = fmt.Sprintf("Organization %d", ssoOrgID)
+	}
+	org = &domain.Organization{
+		SsoOrgID: ssoOrgID,
+		Name:     name,
+		TypeID:   cfg.DefaultOrgTypeID,
+		IsActive: domain.Ptr(true),
+	}
+	if err := s.orgRepo.CreateProvisioned(ctx, org); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Provisioned organization %d from oidc organization %d", org.ID, ssoOrgID)
+	return org, nil
+}
+
+func randomURLToken(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func hashOIDCState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+func claimString(claims map[string]interface{}, name string) string {
+	switch value := claims[name].(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// claimInt64 accepts numeric ids sent either as JSON numbers or strings
+func claimInt64(claims map[string]interface{}, name string) (int64, bool) {
+	switch value := claims[name].(type) {
+	case float64:
+		return int64(value), value == float64(int64(value))
+	case string:
+		id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		return id, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func claimBool(claims map[string]interface{}, name string) bool {
+	switch value := claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	default:
+		return false
+	}
+}
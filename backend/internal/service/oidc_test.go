@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gebase/internal/auth"
+	"gebase/internal/auth/oidctest"
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+type oidcTestEnv struct {
+	db       *gorm.DB
+	service  *OIDCService
+	provider *oidctest.Provider
+	jwt      *auth.JWTService
+}
+
+// newOIDCTestEnv wires the OIDC login against a mock provider, with a
+// registered device "device-1" and HS256 tokens
+func newOIDCTestEnv(t *testing.T) *oidcTestEnv {
+	t.Helper()
+
+	db := newTestDB(t,
+		&domain.User{}, &domain.Organization{}, &domain.Device{}, &domain.OIDCLoginState{},
+		&domain.Session{}, &domain.RefreshToken{}, &domain.SessionLimitPolicy{},
+		&domain.LoginAttempt{}, &domain.LoginLockout{},
+		&domain.System{}, &domain.Role{}, &domain.UserSystemRole{}, &domain.DeviceEnrollmentPolicy{},
+	)
+
+	provider := oidctest.NewProvider(t, "gebase", "secret", "http://localhost:3000/oidc/callback")
+	cfg := &config.Config{
+		JWT: config.JWTConfig{
+			Secret:           "test-secret",
+			SigningAlgorithm: string(domain.SigningAlgorithmHS256),
+			PlatformExpiry:   time.Hour,
+			RefreshExpiry:    24 * time.Hour,
+		},
+		OIDC: config.OIDCConfig{
+			Enabled:        true,
+			IssuerURL:      provider.URL(),
+			ClientID:       "gebase",
+			ClientSecret:   "secret",
+			RedirectURL:    "http://localhost:3000/oidc/callback",
+			Scopes:         []string{"openid", "email", "profile"},
+			UserIDClaim:    "sub",
+			ProvisionUsers: true,
+			StateExpiry:    10 * time.Minute,
+		},
+	}
+
+	userRepo := repository.NewUserRepository(db)
+	deviceRepo := repository.NewDeviceRepository(db)
+	jwtService := auth.NewJWTService(cfg, auth.NewKeyRing(cfg, nil, auth.NewSecretBox(cfg)))
+	sessionService := auth.NewSessionService(cfg, repository.NewSessionRepository(db), nil,
+		repository.NewRefreshTokenRepository(db), deviceRepo, repository.NewUserSystemRoleRepository(db),
+		repository.NewSessionLimitPolicyRepository(db), nil)
+	loginProtection := NewLoginProtectionService(cfg, repository.NewLoginAttemptRepository(db), repository.NewLoginLockoutRepository(db))
+	enrollment := NewDeviceEnrollmentService(cfg, repository.NewDeviceEnrollmentPolicyRepository(db), deviceRepo, nil, userRepo, nil, nil)
+	authService := NewAuthService(userRepo, deviceRepo, nil, nil, nil, nil, nil,
+		jwtService, sessionService, nil, nil, loginProtection, nil, nil, nil, nil, enrollment)
+
+	if err := db.Create(&domain.Device{DeviceUID: "device-1", IsActive: domain.Ptr(true)}).Error; err != nil {
+		t.Fatalf("create device: %v", err)
+	}
+
+	return &oidcTestEnv{
+		db:       db,
+		provider: provider,
+		jwt:      jwtService,
+		service: NewOIDCService(cfg, auth.NewOIDCClient(cfg), repository.NewOIDCLoginStateRepository(db),
+			userRepo, repository.NewOrganizationRepository(db), deviceRepo, loginProtection, authService),
+	}
+}
+
+// login begins a login, signs in at the provider and returns the callback
+func (e *oidcTestEnv) login(t *testing.T) *OIDCCallbackRequest {
+	t.Helper()
+
+	authorization, err := e.service.BeginLogin(context.Background(), &OIDCLoginRequest{DeviceUID: "device-1"}, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	code, state, err := e.provider.Authorize(authorization.AuthorizationURL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if state != authorization.State {
+		t.Fatalf("provider returned state %q, want %q", state, authorization.State)
+	}
+	return &OIDCCallbackRequest{Code: code, State: state}
+}
+
+func TestOIDCLoginProvisionsUser(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	ctx := context.Background()
+	env.provider.Claims = jwt.MapClaims{
+		"sub":         "1001",
+		"email":       "Alice@Example.com",
+		"given_name":  "Alice",
+		"family_name": "Smith",
+	}
+
+	callback := env.login(t)
+	resp, err := env.service.CompleteLogin(ctx, callback, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	if resp.User.SsoUserID != 1001 || resp.User.Email != "alice@example.com" || resp.User.FirstName != "Alice" {
+		t.Fatalf("unexpected user %+v", resp.User)
+	}
+	claims, err := env.jwt.ValidateToken(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if claims.UserID != resp.User.ID || claims.TokenType != auth.TokenTypePlatform {
+		t.Fatalf("unexpected access token claims %+v", claims)
+	}
+
+	// The state is single use
+	if _, err := env.service.CompleteLogin(ctx, callback, "127.0.0.1", "test"); !errors.Is(err, ErrOIDCInvalidState) {
+		t.Fatalf("replayed CompleteLogin error = %v, want ErrOIDCInvalidState", err)
+	}
+
+	// The next login finds the user by subject
+	resp2, err := env.service.CompleteLogin(ctx, env.login(t), "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("second CompleteLogin: %v", err)
+	}
+	if resp2.User.ID != resp.User.ID {
+		t.Fatalf("second login resolved user %d, want %d", resp2.User.ID, resp.User.ID)
+	}
+}
+
+func TestOIDCLoginLinksVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		verified bool
+		wantErr  error
+	}{
+		{"verified", true, nil},
+		{"unverified", false, ErrOIDCAccountConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOIDCTestEnv(t)
+			local := &domain.User{Email: "bob@example.com", FirstName: "Bob", IsActive: domain.Ptr(true)}
+			if err := env.db.Create(local).Error; err != nil {
+				t.Fatalf("create user: %v", err)
+			}
+			env.provider.Claims = jwt.MapClaims{"sub": "2002", "email": "bob@example.com", "email_verified": tt.verified}
+
+			resp, err := env.service.CompleteLogin(context.Background(), env.login(t), "127.0.0.1", "test")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CompleteLogin error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (resp.User.ID != local.ID || resp.User.SsoUserID != 2002) {
+				t.Fatalf("login did not link the local account: %+v", resp.User)
+			}
+		})
+	}
+}
+
+func TestOIDCLoginRejectsInvalidIDToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(claims jwt.MapClaims)
+	}{
+		{"nonce", func(claims jwt.MapClaims) { claims["nonce"] = "replayed" }},
+		{"audience", func(claims jwt.MapClaims) { claims["aud"] = "another-client" }},
+		{"expired", func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOIDCTestEnv(t)
+			env.provider.Claims = jwt.MapClaims{"sub": "3003", "email": "carol@example.com"}
+			env.provider.Tamper = tt.tamper
+
+			_, err := env.service.CompleteLogin(context.Background(), env.login(t), "127.0.0.1", "test")
+			if !errors.Is(err, auth.ErrOIDCInvalidIDToken) {
+				t.Fatalf("CompleteLogin error = %v, want ErrOIDCInvalidIDToken", err)
+			}
+			var users int64
+			env.db.Model(&domain.User{}).Count(&users)
+			if users != 0 {
+				t.Fatalf("%d users were provisioned", users)
+			}
+		})
+	}
+}
@@ -17,17 +17,23 @@ type OrganizationService struct {
 	orgRepo       *repository.OrganizationRepository
 	orgTypeRepo   *repository.OrganizationTypeRepository
 	orgSystemRepo *repository.OrganizationSystemRepository
+	orgPolicyRepo *repository.OrganizationSecurityPolicyRepository
+	ldapRepo      *repository.OrganizationLDAPConfigRepository
 }
 
 func NewOrganizationService(
 	orgRepo *repository.OrganizationRepository,
 	orgTypeRepo *repository.OrganizationTypeRepository,
 	orgSystemRepo *repository.OrganizationSystemRepository,
+	orgPolicyRepo *repository.OrganizationSecurityPolicyRepository,
+	ldapRepo *repository.OrganizationLDAPConfigRepository,
 ) *OrganizationService {
 	return &OrganizationService{
 		orgRepo:       orgRepo,
 		orgTypeRepo:   orgTypeRepo,
 		orgSystemRepo: orgSystemRepo,
+		orgPolicyRepo: orgPolicyRepo,
+		ldapRepo:      ldapRepo,
 	}
 }
 
@@ -68,6 +74,19 @@ type UpdateOrganizationRequest struct {
 	IsActive      *bool   `json:"is_active"`
 }
 
+type UpdateSecurityPolicyRequest struct {
+	RequireMFA            *bool  `json:"require_mfa"`
+	RequireMFAForAdmins   *bool  `json:"require_mfa_for_admins"`
+	AuthBackend           string `json:"auth_backend" binding:"omitempty,oneof=local ldap"`
+	PasswordMinLength     *int   `json:"password_min_length" binding:"omitempty,min=1,max=128"`
+	PasswordRequireUpper  *bool  `json:"password_require_upper"`
+	PasswordRequireLower  *bool  `json:"password_require_lower"`
+	PasswordRequireDigit  *bool  `json:"password_require_digit"`
+	PasswordRequireSymbol *bool  `json:"password_require_symbol"`
+	PasswordHistoryCount  *int   `json:"password_history_count" binding:"omitempty,min=0,max=24"`
+	PasswordMaxAgeDays    *int   `json:"password_max_age_days" binding:"omitempty,min=0"`
+}
+
 // ListOrganizations returns paginated list of organizations
 func (s *OrganizationService) ListOrganizations(ctx context.Context, page, pageSize int) (*repository.PaginatedResult[domain.Organization], error) {
 	return s.orgRepo.FindWithPagination(ctx, repository.PaginationParams{
@@ -262,3 +281,86 @@ func (s *OrganizationService) DisableSystem(ctx context.Context, orgID int64, sy
 	existing.UpdatedBy = &updatedBy
 	return s.orgSystemRepo.Update(ctx, existing)
 }
+
+// GetSecurityPolicy returns the security policy of an organization, or the
+// defaults if none has been saved
+func (s *OrganizationService) GetSecurityPolicy(ctx context.Context, orgID int64) (*domain.OrganizationSecurityPolicy, error) {
+	if _, err := s.orgRepo.FindByID(ctx, orgID); err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+
+	policy, err := s.orgPolicyRepo.FindByOrganizationID(ctx, orgID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return &domain.OrganizationSecurityPolicy{
+				OrganizationID:      orgID,
+				RequireMFA:          domain.Ptr(false),
+				RequireMFAForAdmins: domain.Ptr(false),
+				AuthBackend:         domain.AuthBackendLocal,
+			}, nil
+		}
+		return nil, err
+	}
+	return policy, nil
+}
+
+// UpdateSecurityPolicy creates or updates the security policy of an organization
+func (s *OrganizationService) UpdateSecurityPolicy(ctx context.Context, orgID int64, req *UpdateSecurityPolicyRequest, updatedBy int64) (*domain.OrganizationSecurityPolicy, error) {
+	policy, err := s.GetSecurityPolicy(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.RequireMFA != nil {
+		policy.RequireMFA = req.RequireMFA
+	}
+	if req.RequireMFAForAdmins != nil {
+		policy.RequireMFAForAdmins = req.RequireMFAForAdmins
+	}
+	if req.AuthBackend != "" {
+		// Switching to ldap before a server is configured would lock everyone out
+		if req.AuthBackend == domain.AuthBackendLDAP {
+			if _, err := s.ldapRepo.FindByOrganizationID(ctx, orgID); err != nil {
+				if repository.IsNotFound(err) {
+					return nil, ErrLDAPNotConfigured
+				}
+				return nil, err
+			}
+		}
+		policy.AuthBackend = req.AuthBackend
+	}
+	if req.PasswordMinLength != nil {
+		policy.PasswordMinLength = req.PasswordMinLength
+	}
+	if req.PasswordRequireUpper != nil {
+		policy.PasswordRequireUpper = req.PasswordRequireUpper
+	}
+	if req.PasswordRequireLower != nil {
+		policy.PasswordRequireLower = req.PasswordRequireLower
+	}
+	if req.PasswordRequireDigit != nil {
+		policy.PasswordRequireDigit = req.PasswordRequireDigit
+	}
+	if req.PasswordRequireSymbol != nil {
+		policy.PasswordRequireSymbol = req.PasswordRequireSymbol
+	}
+	if req.PasswordHistoryCount != nil {
+		policy.PasswordHistoryCount = req.PasswordHistoryCount
+	}
+	if req.PasswordMaxAgeDays != nil {
+		policy.PasswordMaxAgeDays = req.PasswordMaxAgeDays
+	}
+
+	if policy.ID == 0 {
+		policy.CreatedBy = &updatedBy
+		err = s.orgPolicyRepo.Create(ctx, policy)
+	} else {
+		policy.UpdatedBy = &updatedBy
+		err = s.orgPolicyRepo.Update(ctx, policy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
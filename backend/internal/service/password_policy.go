@@ -0,0 +1,293 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrPasswordPolicy = errors.New("password does not satisfy the password policy")
+
+// PasswordViolation is a single failed policy rule
+type PasswordViolation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every rule a password failed
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return ErrPasswordPolicy.Error()
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrPasswordPolicy
+}
+
+// PasswordPolicy is the effective policy for a user after organization overrides
+type PasswordPolicy struct {
+	MinLength     int           `json:"min_length"`
+	RequireUpper  bool          `json:"require_upper"`
+	RequireLower  bool          `json:"require_lower"`
+	RequireDigit  bool          `json:"require_digit"`
+	RequireSymbol bool          `json:"require_symbol"`
+	HistoryCount  int           `json:"history_count"`
+	MaxAge        time.Duration `json:"-"`
+	MaxAgeDays    int           `json:"max_age_days"`
+}
+
+type PasswordPolicyService struct {
+	config        *config.Config
+	orgPolicyRepo *repository.OrganizationSecurityPolicyRepository
+	historyRepo   *repository.PasswordHistoryRepository
+
+	breachedOnce sync.Once
+	breached     map[string]struct{}
+}
+
+func NewPasswordPolicyService(
+	cfg *config.Config,
+	orgPolicyRepo *repository.OrganizationSecurityPolicyRepository,
+	historyRepo *repository.PasswordHistoryRepository,
+) *PasswordPolicyService {
+	return &PasswordPolicyService{
+		config:        cfg,
+		orgPolicyRepo: orgPolicyRepo,
+		historyRepo:   historyRepo,
+	}
+}
+
+// GetPolicy returns the global policy with the organization's overrides applied
+func (s *PasswordPolicyService) GetPolicy(ctx context.Context, orgID *int64) (*PasswordPolicy, error) {
+	global := s.config.Password
+	policy := &PasswordPolicy{
+		MinLength:     global.MinLength,
+		RequireUpper:  global.RequireUpper,
+		RequireLower:  global.RequireLower,
+		RequireDigit:  global.RequireDigit,
+		RequireSymbol: global.RequireSymbol,
+		HistoryCount:  global.HistoryCount,
+		MaxAge:        global.MaxAge,
+	}
+
+	if orgID != nil {
+		org, err := s.orgPolicyRepo.FindByOrganizationID(ctx, *orgID)
+		if err != nil && !repository.IsNotFound(err) {
+			return nil, err
+		}
+		if org != nil {
+			applyPasswordOverrides(policy, org)
+		}
+	}
+
+	policy.MaxAgeDays = int(policy.MaxAge / (24 * time.Hour))
+	return policy, nil
+}
+
+// Validate checks a candidate password for a user against the effective policy,
+// the breached password list and the user's recent passwords. Users that do
+// not exist yet (ID 0) have no history.
+func (s *PasswordPolicyService) Validate(ctx context.Context, user *domain.User, password string) error {
+	policy, err := s.GetPolicy(ctx, user.OrganizationID)
+	if err != nil {
+		return err
+	}
+
+	var violations []PasswordViolation
+	add := func(message string) {
+		violations = append(violations, PasswordViolation{Field: "password", Message: message})
+	}
+
+	if len([]rune(password)) < policy.MinLength {
+		add(fmt.Sprintf("must be at least %d characters long", policy.MinLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if policy.RequireUpper && !hasUpper {
+		add("must contain an uppercase letter")
+	}
+	if policy.RequireLower && !hasLower {
+		add("must contain a lowercase letter")
+	}
+	if policy.RequireDigit && !hasDigit {
+		add("must contain a digit")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		add("must contain a symbol")
+	}
+
+	if s.isBreached(password) {
+		add("is too common or has appeared in a data breach")
+	}
+
+	if user.ID != 0 && policy.HistoryCount > 0 {
+		reused, err := s.isReused(ctx, user, password, policy.HistoryCount)
+		if err != nil {
+			return err
+		}
+		if reused {
+			add(fmt.Sprintf("must not match any of the last %d passwords", policy.HistoryCount))
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// RecordChange stores the replaced hash in the user's history and prunes it
+func (s *PasswordPolicyService) RecordChange(ctx context.Context, user *domain.User, previousHash string) error {
+	if previousHash == "" {
+		return nil
+	}
+
+	policy, err := s.GetPolicy(ctx, user.OrganizationID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.historyRepo.Create(ctx, &domain.PasswordHistory{
+		UserID:       user.ID,
+		PasswordHash: previousHash,
+	}); err != nil {
+		return err
+	}
+
+	// The current hash counts as one of the remembered passwords
+	keep := policy.HistoryCount - 1
+	if keep < 1 {
+		keep = 1
+	}
+	return s.historyRepo.Prune(ctx, user.ID, keep)
+}
+
+// IsExpired reports whether the user's password is older than the policy's max age
+func (s *PasswordPolicyService) IsExpired(ctx context.Context, user *domain.User) (bool, error) {
+	policy, err := s.GetPolicy(ctx, user.OrganizationID)
+	if err != nil {
+		return false, err
+	}
+	if policy.MaxAge <= 0 {
+		return false, nil
+	}
+
+	changedAt := user.PasswordChangedAt
+	if changedAt == nil {
+		changedAt = user.CreatedDate
+	}
+	if changedAt == nil {
+		return false, nil
+	}
+	return time.Since(*changedAt) > policy.MaxAge, nil
+}
+
+func (s *PasswordPolicyService) isReused(ctx context.Context, user *domain.User, password string, historyCount int) (bool, error) {
+	if user.PasswordHash != "" && bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil {
+		return true, nil
+	}
+
+	if historyCount <= 1 {
+		return false, nil
+	}
+
+	history, err := s.historyRepo.FindRecent(ctx, user.ID, historyCount-1)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range history {
+		if bcrypt.CompareHashAndPassword([]byte(entry.PasswordHash), []byte(password)) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *PasswordPolicyService) isBreached(password string) bool {
+	s.breachedOnce.Do(s.loadBreachedList)
+	_, found := s.breached[strings.ToLower(password)]
+	return found
+}
+
+// loadBreachedList reads one password per line; blank lines and # comments are skipped
+func (s *PasswordPolicyService) loadBreachedList() {
+	s.breached = make(map[string]struct{})
+
+	path := s.config.Password.BreachedListPath
+	if path == "" {
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Printf("Warning: failed to load breached password list: %v", err)
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s.breached[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("Warning: failed to read breached password list: %v", err)
+	}
+
+	log.Printf("Loaded %d breached passwords", len(s.breached))
+}
+
+func applyPasswordOverrides(policy *PasswordPolicy, org *domain.OrganizationSecurityPolicy) {
+	if org.PasswordMinLength != nil {
+		policy.MinLength = *org.PasswordMinLength
+	}
+	if org.PasswordRequireUpper != nil {
+		policy.RequireUpper = *org.PasswordRequireUpper
+	}
+	if org.PasswordRequireLower != nil {
+		policy.RequireLower = *org.PasswordRequireLower
+	}
+	if org.PasswordRequireDigit != nil {
+		policy.RequireDigit = *org.PasswordRequireDigit
+	}
+	if org.PasswordRequireSymbol != nil {
+		policy.RequireSymbol = *org.PasswordRequireSymbol
+	}
+	if org.PasswordHistoryCount != nil {
+		policy.HistoryCount = *org.PasswordHistoryCount
+	}
+	if org.PasswordMaxAgeDays != nil {
+		policy.MaxAge = time.Duration(*org.PasswordMaxAgeDays) * 24 * time.Hour
+	}
+}
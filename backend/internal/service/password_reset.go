@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"gebase/internal/auth"
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/mail"
+	"gebase/internal/repository"
+)
+
+var (
+	ErrInvalidResetToken    = errors.New("password reset token is invalid or expired")
+	ErrTooManyResetRequests = errors.New("too many password reset requests")
+)
+
+// ResetThrottledError wraps ErrTooManyResetRequests with how long the client
+// has to wait before requesting another reset
+type ResetThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ResetThrottledError) Error() string {
+	return ErrTooManyResetRequests.Error()
+}
+
+func (e *ResetThrottledError) Unwrap() error {
+	return ErrTooManyResetRequests
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email,max=80"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// PasswordResetService implements the self-service forgotten-password flow
+type PasswordResetService struct {
+	config         *config.Config
+	userRepo       *repository.UserRepository
+	tokenRepo      *repository.PasswordResetTokenRepository
+	requestRepo    *repository.PasswordResetRequestRepository
+	userService    *UserService
+	sessionService *auth.SessionService
+	mailSender     mail.Sender
+}
+
+func NewPasswordResetService(
+	cfg *config.Config,
+	userRepo *repository.UserRepository,
+	tokenRepo *repository.PasswordResetTokenRepository,
+	requestRepo *repository.PasswordResetRequestRepository,
+	userService *UserService,
+	sessionService *auth.SessionService,
+	mailSender mail.Sender,
+) *PasswordResetService {
+	return &PasswordResetService{
+		config:         cfg,
+		userRepo:       userRepo,
+		tokenRepo:      tokenRepo,
+		requestRepo:    requestRepo,
+		userService:    userService,
+		sessionService: sessionService,
+		mailSender:     mailSender,
+	}
+}
+
+// RequestReset mails a reset link to the user. Requests are throttled per
+// email and per client address whether or not the email is registered, and
+// the link is created and mailed in the background, so neither the result
+// nor the response time reveals which emails exist. Only throttling and
+// ledger failures are returned.
+func (s *PasswordResetService) RequestReset(ctx context.Context, email, ipAddress string) error {
+	// Case variants of an email share its limit
+	ledgerEmail := strings.ToLower(strings.TrimSpace(email))
+	if err := s.checkThrottle(ctx, ledgerEmail, ipAddress); err != nil {
+		return err
+	}
+	if err := s.requestRepo.Create(ctx, &domain.PasswordResetRequest{
+		Email:     ledgerEmail,
+		IPAddress: ipAddress,
+	}); err != nil {
+		return err
+	}
+
+	go s.issueReset(context.WithoutCancel(ctx), email, ipAddress)
+	return nil
+}
+
+// checkThrottle refuses a request once the address or the email reached its
+// limit within the window, until the oldest counted request leaves it
+func (s *PasswordResetService) checkThrottle(ctx context.Context, email, ipAddress string) error {
+	window := s.config.Mail.PasswordResetWindow
+	since := time.Now().Add(-window)
+
+	limits := []struct {
+		max  int
+		find func() ([]domain.PasswordResetRequest, error)
+	}{
+		{s.config.Mail.PasswordResetMaxPerIP, func() ([]domain.PasswordResetRequest, error) {
+			return s.requestRepo.FindByIPSince(ctx, ipAddress, since)
+		}},
+		{s.config.Mail.PasswordResetMaxPerEmail, func() ([]domain.PasswordResetRequest, error) {
+			return s.requestRepo.FindByEmailSince(ctx, email, since)
+		}},
+	}
+	for _, limit := range limits {
+		if limit.max <= 0 {
+			continue
+		}
+		requests, err := limit.find()
+		if err != nil {
+			return err
+		}
+		if len(requests) >= limit.max {
+			return &ResetThrottledError{
+				RetryAfter: time.Until(requests[limit.max-1].CreatedAt.Add(window)),
+			}
+		}
+	}
+	return nil
+}
+
+// issueReset creates the reset token and mails the link. Unknown, inactive
+// and directory accounts are skipped; failures are only logged.
+func (s *PasswordResetService) issueReset(ctx context.Context, email, ipAddress string) {
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		if !repository.IsNotFound(err) {
+			log.Printf("Warning: failed to look up password reset account: %v", err)
+		}
+		return
+	}
+	if user.IsActive == nil || !*user.IsActive {
+		return
+	}
+
+	// Directory users reset their password in the directory
+	if err := s.userService.requireLocalPassword(ctx, user); err != nil {
+		if err != ErrPasswordManagedByLDAP {
+			log.Printf("Warning: failed to check password source of user %d: %v", user.ID, err)
+		}
+		return
+	}
+
+	// Only the most recent link works
+	if err := s.tokenRepo.InvalidateByUserID(ctx, user.ID); err != nil {
+		log.Printf("Warning: failed to invalidate password reset tokens of user %d: %v", user.ID, err)
+		return
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		log.Printf("Warning: failed to generate password reset token: %v", err)
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	expiry := s.config.Mail.PasswordResetExpiry
+	if err := s.tokenRepo.Create(ctx, &domain.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashResetToken(token),
+		IPAddress: ipAddress,
+		ExpiresAt: time.Now().Add(expiry),
+	}); err != nil {
+		log.Printf("Warning: failed to create password reset token for user %d: %v", user.ID, err)
+		return
+	}
+
+	link := s.config.Mail.PasswordResetURL + "?token=" + url.QueryEscape(token)
+	msg := &mail.Message{
+		To:      []string{user.Email},
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nA password reset was requested for your account. "+
+				"Open the link below within %d minutes to choose a new password:\n\n%s\n\n"+
+				"If you did not request this, you can ignore this email.\n",
+			user.FirstName, int(expiry.Minutes()), link,
+		),
+	}
+	if err := s.mailSender.Send(ctx, msg); err != nil {
+		log.Printf("Warning: failed to send password reset email to user %d: %v", user.ID, err)
+	}
+}
+
+// ResetPassword redeems a reset token, sets the new password and terminates
+// all of the user's sessions
+func (s *PasswordResetService) ResetPassword(ctx context.Context, req *ResetPasswordRequest) error {
+	token, err := s.tokenRepo.FindByHash(ctx, hashResetToken(req.Token))
+	if err != nil || !token.IsUsable() {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	// Check the policy before consuming the token so the user can retry
+	if err := s.userService.passwordPolicy.Validate(ctx, user, req.Password); err != nil {
+		return err
+	}
+
+	used, err := s.tokenRepo.MarkUsed(ctx, token.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidResetToken
+	}
+
+	if err := s.userService.setPassword(ctx, user, req.Password, false, user.ID); err != nil {
+		return err
+	}
+
+	return s.sessionService.LogoutUser(ctx, user.ID, auth.LogoutReasonPasswordReset)
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
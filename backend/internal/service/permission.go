@@ -2,17 +2,22 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"gebase/internal/domain"
 	"gebase/internal/repository"
 )
 
+var ErrPermissionNotFound = errors.New("permission not found")
+
 type PermissionService struct {
 	permissionRepo *repository.PermissionRepository
 	moduleRepo     *repository.ModuleRepository
 	actionRepo     *repository.ActionRepository
 	systemRepo     *repository.SystemRepository
+	cache          *PermissionCache
 }
 
 func NewPermissionService(
@@ -20,18 +25,129 @@ func NewPermissionService(
 	moduleRepo *repository.ModuleRepository,
 	actionRepo *repository.ActionRepository,
 	systemRepo *repository.SystemRepository,
+	cache *PermissionCache,
 ) *PermissionService {
 	return &PermissionService{
 		permissionRepo: permissionRepo,
 		moduleRepo:     moduleRepo,
 		actionRepo:     actionRepo,
 		systemRepo:     systemRepo,
+		cache:          cache,
 	}
 }
 
 // CheckPermission checks if user has a specific permission
 func (s *PermissionService) CheckPermission(ctx context.Context, userID int64, systemID *int, permissionCode string) (bool, error) {
-	return s.permissionRepo.CheckUserPermission(ctx, userID, systemID, permissionCode)
+	if s.cache == nil {
+		return s.permissionRepo.CheckUserPermission(ctx, userID, systemID, permissionCode)
+	}
+
+	permissions, err := s.userPermissionSet(ctx, userID, systemID)
+	if err != nil {
+		return false, err
+	}
+	_, ok := permissions[permissionCode]
+	return ok, nil
+}
+
+// RecentAuthWindow returns how recently the user must have entered their
+// credentials to use a permission, 0 when any session will do
+func (s *PermissionService) RecentAuthWindow(ctx context.Context, userID int64, systemID *int, permissionCode string) (time.Duration, error) {
+	var minutes int
+	if s.cache == nil {
+		var err error
+		if minutes, err = s.permissionRepo.FindRecentAuthMinutes(ctx, permissionCode); err != nil {
+			return 0, err
+		}
+	} else {
+		permissions, err := s.userPermissionSet(ctx, userID, systemID)
+		if err != nil {
+			return 0, err
+		}
+		minutes = permissions[permissionCode]
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+// userPermissionSet returns the permission set of a user in a system from
+// the cache, loading it on a miss
+func (s *PermissionService) userPermissionSet(ctx context.Context, userID int64, systemID *int) (PermissionSet, error) {
+	return s.cache.Load(ctx, userID, systemID, func() (PermissionSet, error) {
+		found, err := s.permissionRepo.FindUserPermissions(ctx, userID, systemID)
+		if err != nil {
+			return nil, err
+		}
+		permissions := make(PermissionSet, len(found))
+		for _, p := range found {
+			permissions[p.Code] = p.RecentAuthMinutes
+		}
+		return permissions, nil
+	})
+}
+
+// InvalidateUserPermissions drops the cached permissions of a user, after
+// their roles change
+func (s *PermissionService) InvalidateUserPermissions(ctx context.Context, userID int64) error {
+	return s.cache.InvalidateUser(ctx, userID)
+}
+
+// InvalidateAllPermissions drops every cached permission set, after changes
+// to roles or permissions held by any number of users
+func (s *PermissionService) InvalidateAllPermissions(ctx context.Context) error {
+	return s.cache.InvalidateAll(ctx)
+}
+
+type UpdatePermissionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	IsActive    *bool  `json:"is_active"`
+	// RecentAuthMinutes requires users to have entered their credentials
+	// within this many minutes to use the permission; 0 turns it off
+	RecentAuthMinutes *int `json:"recent_auth_minutes" binding:"omitempty,min=0"`
+}
+
+// ListPermissions returns the permissions of a system, or platform
+// permissions when systemID is nil
+func (s *PermissionService) ListPermissions(ctx context.Context, systemID *int) ([]domain.Permission, error) {
+	if systemID == nil {
+		return s.permissionRepo.FindPlatformPermissions(ctx)
+	}
+	return s.permissionRepo.FindBySystemID(ctx, *systemID)
+}
+
+// UpdatePermission changes a permission. Deactivated permissions are no
+// longer granted by any role. Cached permissions are dropped so the change
+// applies on the next request.
+func (s *PermissionService) UpdatePermission(ctx context.Context, id int, req *UpdatePermissionRequest, updatedBy int64) (*domain.Permission, error) {
+	permission, err := s.permissionRepo.FindByID(ctx, id)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, ErrPermissionNotFound
+		}
+		return nil, err
+	}
+
+	if req.Name != "" {
+		permission.Name = req.Name
+	}
+	if req.Description != "" {
+		permission.Description = req.Description
+	}
+	if req.IsActive != nil {
+		permission.IsActive = req.IsActive
+	}
+	if req.RecentAuthMinutes != nil {
+		permission.RecentAuthMinutes = *req.RecentAuthMinutes
+	}
+	permission.UpdatedBy = &updatedBy
+
+	if err := s.permissionRepo.Update(ctx, permission); err != nil {
+		return nil, err
+	}
+	if err := s.cache.InvalidateAll(ctx); err != nil {
+		return nil, err
+	}
+	return permission, nil
 }
 
 // GetUserPermissions returns all permissions for a user in a system
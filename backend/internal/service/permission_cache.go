@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gebase/internal/cache"
+	"gebase/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PermissionSet maps the codes of the active permissions a user holds in a
+// system to their RecentAuthMinutes
+type PermissionSet map[string]int
+
+// PermissionCache holds the permission sets of users per system so
+// permission checks do not query the role tables on every request. Entries
+// are dropped when roles are assigned, role permissions change, roles are
+// deleted or permissions are changed, and expire after the configured TTL
+// otherwise. A nil cache caches nothing.
+//
+// Sets are kept in process; with the redis backend they are also shared
+// through Redis, which holds the generations that invalidate them across
+// instances and announces invalidations so other instances drop their
+// in-process copies.
+type PermissionCache struct {
+	local *cache.LRU[permissionCacheKey, PermissionSet]
+	// shared is nil for the memory backend
+	shared *redisPermissionStore
+	// generation counts invalidations seen by this process, so loads
+	// overlapping one are not kept in process
+	generation atomic.Uint64
+}
+
+// NewPermissionCache creates the cache of the configured backend, nil for
+// none. The redis backend needs client.
+func NewPermissionCache(cfg config.PermissionCacheConfig, client *redis.Client) (*PermissionCache, error) {
+	switch cfg.Backend {
+	case config.PermissionCacheNone:
+		return nil, nil
+	case config.PermissionCacheMemory, "":
+		return &PermissionCache{
+			local: cache.NewLRU[permissionCacheKey, PermissionSet](cfg.Size, cfg.TTL),
+		}, nil
+	case config.PermissionCacheRedis:
+		if client == nil {
+			return nil, errors.New("permission cache backend redis needs a redis client")
+		}
+		localTTL := cfg.LocalTTL
+		if cfg.TTL > 0 && (localTTL <= 0 || localTTL > cfg.TTL) {
+			localTTL = cfg.TTL
+		}
+		return &PermissionCache{
+			local:  cache.NewLRU[permissionCacheKey, PermissionSet](cfg.Size, localTTL),
+			shared: &redisPermissionStore{client: client, ttl: cfg.TTL},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown permission cache backend %q", cfg.Backend)
+}
+
+// Load returns the permission set of a user in a system, or platform when
+// systemID is nil, calling load on a miss and caching the result. Backend
+// failures are logged and fall back to load. A set loaded while an
+// invalidation ran is returned but not cached.
+func (c *PermissionCache) Load(ctx context.Context, userID int64, systemID *int, load func() (PermissionSet, error)) (PermissionSet, error) {
+	if c == nil {
+		return load()
+	}
+
+	key := newPermissionCacheKey(userID, systemID)
+	if permissions, ok := c.local.Get(key); ok {
+		return permissions, nil
+	}
+
+	generation := c.generation.Load()
+	permissions, err := c.loadShared(ctx, userID, systemID, load)
+	if err != nil {
+		return nil, err
+	}
+	if c.generation.Load() == generation {
+		c.local.Set(key, permissions)
+	}
+	return permissions, nil
+}
+
+// loadShared reads the set from Redis, falling back to load and writing the
+// result back stamped with the generations read before loading
+func (c *PermissionCache) loadShared(ctx context.Context, userID int64, systemID *int, load func() (PermissionSet, error)) (PermissionSet, error) {
+	if c.shared == nil {
+		return load()
+	}
+
+	permissions, stamp, err := c.shared.get(ctx, userID, systemID)
+	if err != nil {
+		log.Printf("Warning: failed to read permission cache of user %d: %v", userID, err)
+		return load()
+	}
+	if permissions != nil {
+		return permissions, nil
+	}
+
+	permissions, err = load()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.shared.set(ctx, userID, systemID, stamp, permissions); err != nil {
+		log.Printf("Warning: failed to write permission cache of user %d: %v", userID, err)
+	}
+	return permissions, nil
+}
+
+// InvalidateUser drops the cached permission sets of a user in every system
+func (c *PermissionCache) InvalidateUser(ctx context.Context, userID int64) error {
+	if c == nil {
+		return nil
+	}
+	c.dropLocal(userID)
+	if c.shared == nil {
+		return nil
+	}
+	return c.shared.invalidateUser(ctx, userID)
+}
+
+// InvalidateAll drops every cached permission set
+func (c *PermissionCache) InvalidateAll(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	c.dropLocal(0)
+	if c.shared == nil {
+		return nil
+	}
+	return c.shared.invalidateAll(ctx)
+}
+
+// Listen drops in-process sets invalidated by other instances until ctx is
+// done. It returns at once for caches not shared through Redis. Sets whose
+// invalidation is missed while the subscription reconnects expire after the
+// in-process TTL.
+func (c *PermissionCache) Listen(ctx context.Context) {
+	if c == nil || c.shared == nil {
+		return
+	}
+
+	sub := c.shared.client.Subscribe(ctx, redisPermissionChannel)
+	defer sub.Close()
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			userID, err := strconv.ParseInt(msg.Payload, 10, 64)
+			if err != nil {
+				log.Printf("Warning: ignoring permission cache invalidation %q: %v", msg.Payload, err)
+				continue
+			}
+			c.dropLocal(userID)
+		}
+	}
+}
+
+// dropLocal drops the in-process sets of a user, or of everyone for 0
+func (c *PermissionCache) dropLocal(userID int64) {
+	c.generation.Add(1)
+	if userID == 0 {
+		c.local.Purge()
+		return
+	}
+	c.local.DeleteFunc(func(key permissionCacheKey) bool {
+		return key.userID == userID
+	})
+}
+
+// permissionCacheKey identifies a user in a system. System IDs start at 1,
+// so 0 stands for platform permissions.
+type permissionCacheKey struct {
+	userID   int64
+	systemID int
+}
+
+func newPermissionCacheKey(userID int64, systemID *int) permissionCacheKey {
+	key := permissionCacheKey{userID: userID}
+	if systemID != nil {
+		key.systemID = *systemID
+	}
+	return key
+}
+
+const (
+	// redisPermissionKeyPrefix starts the key of the hash holding a user's
+	// permission sets, one field per system plus the user's generation
+	redisPermissionKeyPrefix = "gebase:permissions:"
+	// redisPermissionGenerationKey counts invalidations of every user
+	redisPermissionGenerationKey = "gebase:permissions-generation"
+	// redisPermissionChannel announces invalidations: a user ID, 0 for all
+	redisPermissionChannel = "gebase:permissions-invalidated"
+	// redisUserGenerationField holds a random value replaced whenever the
+	// user's permissions are invalidated
+	redisUserGenerationField = "generation"
+)
+
+type redisPermissionStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// redisPermissionStamp holds the generations a set was loaded under; the set
+// is only used while both are current
+type redisPermissionStamp struct {
+	Generation     int64  `json:"g"`
+	UserGeneration string `json:"u"`
+}
+
+// redisPermissionEntry is a set as stored in a user's hash. Each entry
+// expires on its own; the hash's TTL only reclaims users no longer seen.
+type redisPermissionEntry struct {
+	redisPermissionStamp
+	// ExpiresAt is in Unix milliseconds, 0 for never
+	ExpiresAt   int64         `json:"e"`
+	Permissions PermissionSet `json:"p"`
+}
+
+func redisPermissionKey(userID int64) string {
+	return redisPermissionKeyPrefix + strconv.FormatInt(userID, 10)
+}
+
+func redisPermissionField(systemID *int) string {
+	if systemID == nil {
+		return "platform"
+	}
+	return strconv.Itoa(*systemID)
+}
+
+// get returns the set when it is current, nil otherwise, and the stamp a set
+// loaded now must be written with
+func (s *redisPermissionStore) get(ctx context.Context, userID int64, systemID *int) (PermissionSet, redisPermissionStamp, error) {
+	var stamp redisPermissionStamp
+
+	pipe := s.client.Pipeline()
+	generation := pipe.Get(ctx, redisPermissionGenerationKey)
+	fields := pipe.HMGet(ctx, redisPermissionKey(userID), redisUserGenerationField, redisPermissionField(systemID))
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, stamp, err
+	}
+
+	if value, err := generation.Int64(); err == nil {
+		stamp.Generation = value
+	} else if !errors.Is(err, redis.Nil) {
+		return nil, stamp, err
+	}
+	values := fields.Val()
+	if value, ok := values[0].(string); ok {
+		stamp.UserGeneration = value
+	}
+	raw, ok := values[1].(string)
+	if !ok {
+		return nil, stamp, nil
+	}
+
+	var entry redisPermissionEntry
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+		return nil, stamp, err
+	}
+	if entry.redisPermissionStamp != stamp || (entry.ExpiresAt != 0 && time.Now().UnixMilli() >= entry.ExpiresAt) {
+		return nil, stamp, nil
+	}
+	return entry.Permissions, stamp, nil
+}
+
+func (s *redisPermissionStore) set(ctx context.Context, userID int64, systemID *int, stamp redisPermissionStamp, permissions PermissionSet) error {
+	entry := redisPermissionEntry{redisPermissionStamp: stamp, Permissions: permissions}
+	if s.ttl > 0 {
+		entry.ExpiresAt = time.Now().Add(s.ttl).UnixMilli()
+	}
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	key := redisPermissionKey(userID)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, redisPermissionField(systemID), value)
+		s.expire(ctx, pipe, key)
+		return nil
+	})
+	return err
+}
+
+// expire keeps a user's hash for twice the entry TTL after its last write.
+// Dropping it also drops the user's generation, but with every entry.
+func (s *redisPermissionStore) expire(ctx context.Context, pipe redis.Pipeliner, key string) {
+	if s.ttl > 0 {
+		pipe.PExpire(ctx, key, 2*s.ttl)
+	}
+}
+
+// invalidateUser replaces the user's generation, so sets loaded before are
+// not used, even those written back after this by loads already running
+func (s *redisPermissionStore) invalidateUser(ctx context.Context, userID int64) error {
+	key := redisPermissionKey(userID)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, redisUserGenerationField, strconv.FormatUint(rand.Uint64(), 36))
+		s.expire(ctx, pipe, key)
+		pipe.Publish(ctx, redisPermissionChannel, strconv.FormatInt(userID, 10))
+		return nil
+	})
+	return err
+}
+
+// invalidateAll bumps the generation of every user; stale hashes expire
+func (s *redisPermissionStore) invalidateAll(ctx context.Context) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, redisPermissionGenerationKey)
+		pipe.Publish(ctx, redisPermissionChannel, "0")
+		return nil
+	})
+	return err
+}
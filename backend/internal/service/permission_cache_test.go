@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+func newTestRedisCache(t testing.TB, server *miniredis.Miniredis, ttl time.Duration) *PermissionCache {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	c, err := NewPermissionCache(config.PermissionCacheConfig{
+		Backend:  config.PermissionCacheRedis,
+		Size:     100,
+		TTL:      ttl,
+		LocalTTL: ttl,
+	}, client)
+	if err != nil {
+		t.Fatalf("NewPermissionCache: %v", err)
+	}
+	return c
+}
+
+// countingLoad returns a load function counting its calls
+func countingLoad(calls *int, permissions PermissionSet) func() (PermissionSet, error) {
+	return func() (PermissionSet, error) {
+		*calls++
+		return permissions, nil
+	}
+}
+
+func TestPermissionCacheRedisSharesEntries(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+	first := newTestRedisCache(t, server, time.Minute)
+	second := newTestRedisCache(t, server, time.Minute)
+
+	var calls int
+	load := countingLoad(&calls, PermissionSet{"admin.user.view": 0})
+	for _, c := range []*PermissionCache{first, first, second, second} {
+		permissions, err := c.Load(ctx, 1, nil, load)
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if _, ok := permissions["admin.user.view"]; !ok {
+			t.Fatalf("Load returned %v", permissions)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("load called %d times, want 1", calls)
+	}
+}
+
+func TestPermissionCacheRedisInvalidatesOtherInstances(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := newTestRedisCache(t, server, time.Minute)
+	second := newTestRedisCache(t, server, time.Minute)
+	go second.Listen(ctx)
+	// Wait for the subscription, or the announcement below may be missed
+	for deadline := time.Now().Add(time.Second); server.PubSubNumSub(redisPermissionChannel)[redisPermissionChannel] == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("cache did not subscribe to invalidations")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	tests := []struct {
+		name       string
+		userID     int64
+		invalidate func() error
+	}{
+		{"user", 1, func() error { return first.InvalidateUser(ctx, 1) }},
+		{"all", 2, func() error { return first.InvalidateAll(ctx) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			load := countingLoad(&calls, PermissionSet{})
+			if _, err := second.Load(ctx, tt.userID, nil, load); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if err := tt.invalidate(); err != nil {
+				t.Fatalf("invalidate: %v", err)
+			}
+
+			// The in-process copy of the second instance goes once the
+			// announcement arrives; Redis no longer serves the old entry
+			for deadline := time.Now().Add(time.Second); second.local.Len() > 0; {
+				if time.Now().After(deadline) {
+					t.Fatal("in-process entry was not dropped")
+				}
+				time.Sleep(time.Millisecond)
+			}
+			if _, err := second.Load(ctx, tt.userID, nil, load); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if calls != 2 {
+				t.Fatalf("load called %d times, want 2", calls)
+			}
+		})
+	}
+}
+
+func TestPermissionCacheRedisIgnoresLoadsOverlappingInvalidation(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+	first := newTestRedisCache(t, server, time.Minute)
+	second := newTestRedisCache(t, server, time.Minute)
+
+	// The first instance loads the old set while the second invalidates it
+	_, err := first.Load(ctx, 1, nil, func() (PermissionSet, error) {
+		if err := second.InvalidateUser(ctx, 1); err != nil {
+			t.Fatalf("InvalidateUser: %v", err)
+		}
+		return PermissionSet{"admin.user.delete": 0}, nil
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	var calls int
+	permissions, err := second.Load(ctx, 1, nil, countingLoad(&calls, PermissionSet{}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if calls != 1 || len(permissions) != 0 {
+		t.Fatalf("stale set %v was used", permissions)
+	}
+}
+
+func TestPermissionCacheRedisEntriesExpireOnTheirOwn(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+	ttl := 100 * time.Millisecond
+	first := newTestRedisCache(t, server, ttl)
+	system := 1
+
+	var calls int
+	load := countingLoad(&calls, PermissionSet{})
+	if _, err := first.Load(ctx, 1, nil, load); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	time.Sleep(ttl / 2)
+	// Writing another system's entry keeps the user's hash alive
+	if _, err := first.Load(ctx, 1, &system, load); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	time.Sleep(ttl / 2)
+
+	// A fresh instance has nothing in process, so only Redis can answer
+	second := newTestRedisCache(t, server, ttl)
+	if _, err := second.Load(ctx, 1, nil, load); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("load called %d times, want 3", calls)
+	}
+}
+
+func TestPermissionCacheMemoryInvalidateUser(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewPermissionCache(config.PermissionCacheConfig{
+		Backend: config.PermissionCacheMemory,
+		Size:    100,
+		TTL:     time.Minute,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewPermissionCache: %v", err)
+	}
+
+	var calls int
+	load := countingLoad(&calls, PermissionSet{})
+	for _, userID := range []int64{1, 2, 1, 2} {
+		if _, err := c.Load(ctx, userID, nil, load); err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+	}
+	if err := c.InvalidateUser(ctx, 1); err != nil {
+		t.Fatalf("InvalidateUser: %v", err)
+	}
+	for _, userID := range []int64{1, 2} {
+		if _, err := c.Load(ctx, userID, nil, load); err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("load called %d times, want 3", calls)
+	}
+}
+
+// newBenchmarkPermissionDB holds a user with two roles granting 50
+// permissions each in system 1
+func newBenchmarkPermissionDB(b *testing.B) *gorm.DB {
+	b.Helper()
+
+	db := newTestDB(b, &domain.Permission{}, &domain.Role{}, &domain.RolePermission{}, &domain.UserSystemRole{})
+	systemID := 1
+	for roleID := 1; roleID <= 2; roleID++ {
+		db.Create(&domain.Role{ID: roleID, Code: "role", SystemID: &systemID})
+		db.Create(&domain.UserSystemRole{UserID: 1, SystemID: &systemID, RoleID: roleID})
+		for i := 0; i < 50; i++ {
+			id := (roleID-1)*50 + i + 1
+			db.Create(&domain.Permission{ID: id, Code: "bench.permission." + strconv.Itoa(id), SystemID: &systemID})
+			db.Create(&domain.RolePermission{RoleID: roleID, PermissionID: id})
+		}
+	}
+	return db
+}
+
+// BenchmarkCheckPermission reports the database queries each check costs
+// with every cache backend. Its timings pit an in-memory SQLite database
+// against a miniredis answering over loopback, so they do not predict
+// production latency, where a Redis round trip is not necessarily cheaper
+// than the permission query. What the shared tier saves is the queries/op:
+// the load on the database.
+func BenchmarkCheckPermission(b *testing.B) {
+	db := newBenchmarkPermissionDB(b)
+	server := miniredis.RunT(b)
+	var queries int64
+	err := db.Callback().Query().Before("gorm:query").Register("bench:count_queries", func(*gorm.DB) {
+		queries++
+	})
+	if err != nil {
+		b.Fatalf("register callback: %v", err)
+	}
+	systemID := 1
+	code := "bench.permission.75"
+
+	caches := []struct {
+		name  string
+		cache func() *PermissionCache
+		// purge drops the in-process copy before every check, to measure
+		// the Redis tier
+		purge bool
+	}{
+		{name: "uncached", cache: func() *PermissionCache { return nil }},
+		{name: "memory", cache: func() *PermissionCache {
+			c, _ := NewPermissionCache(config.PermissionCacheConfig{Backend: config.PermissionCacheMemory, Size: 100, TTL: time.Minute}, nil)
+			return c
+		}},
+		{name: "redis", cache: func() *PermissionCache { return newTestRedisCache(b, server, time.Minute) }},
+		{name: "redis-shared", cache: func() *PermissionCache { return newTestRedisCache(b, server, time.Minute) }, purge: true},
+	}
+	for _, bc := range caches {
+		b.Run(bc.name, func(b *testing.B) {
+			c := bc.cache()
+			s := NewPermissionService(repository.NewPermissionRepository(db), nil, nil, nil, c)
+			ctx := context.Background()
+
+			queries = 0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if bc.purge {
+					c.local.Purge()
+				}
+				ok, err := s.CheckPermission(ctx, 1, &systemID, code)
+				if err != nil || !ok {
+					b.Fatalf("CheckPermission = %v, %v", ok, err)
+				}
+			}
+			b.ReportMetric(float64(queries)/float64(b.N), "queries/op")
+		})
+	}
+}
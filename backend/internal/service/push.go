@@ -0,0 +1,586 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/push"
+	"gebase/internal/repository"
+)
+
+const (
+	// pushDispatchBatch is how many deliveries a dispatcher claims at once
+	pushDispatchBatch = 100
+	// pushDispatchWorkers is how many deliveries of a batch are sent concurrently
+	pushDispatchWorkers = 8
+	// pushDeliveryLease is how long a claimed delivery is held before
+	// another dispatcher may retry it
+	pushDeliveryLease = 5 * time.Minute
+	// maxPushRetryBackoff caps the doubling wait between attempts
+	maxPushRetryBackoff = time.Hour
+)
+
+var (
+	ErrPushTemplateNotFound     = errors.New("push template not found")
+	ErrPushTemplateExists       = errors.New("push template code already exists")
+	ErrPushNotificationNotFound = errors.New("push notification not found")
+	ErrInvalidPushTarget        = errors.New("target_id is required for user, device and organization targets, a valid platform for platform targets")
+	ErrEmptyPushContent         = errors.New("a template, title, body or data is required")
+)
+
+// InvalidPushTemplateError reports a template that cannot be parsed or
+// filled with the variables given
+type InvalidPushTemplateError struct {
+	err error
+}
+
+func (e *InvalidPushTemplateError) Error() string {
+	return "invalid push template: " + e.err.Error()
+}
+
+// PushService sends push notifications to the devices of a user, a single
+// device, an organization or a platform. Sending stores a delivery per
+// device that is dispatched right away; temporary failures are retried by
+// the push_retry job and rejected tokens are removed from their device.
+type PushService struct {
+	config           *config.Config
+	sender           *push.Sender
+	templateRepo     *repository.PushTemplateRepository
+	notificationRepo *repository.PushNotificationRepository
+	deliveryRepo     *repository.PushDeliveryRepository
+	deviceRepo       *repository.DeviceRepository
+	userRepo         *repository.UserRepository
+	orgRepo          *repository.OrganizationRepository
+}
+
+func NewPushService(
+	cfg *config.Config,
+	sender *push.Sender,
+	templateRepo *repository.PushTemplateRepository,
+	notificationRepo *repository.PushNotificationRepository,
+	deliveryRepo *repository.PushDeliveryRepository,
+	deviceRepo *repository.DeviceRepository,
+	userRepo *repository.UserRepository,
+	orgRepo *repository.OrganizationRepository,
+) *PushService {
+	return &PushService{
+		config:           cfg,
+		sender:           sender,
+		templateRepo:     templateRepo,
+		notificationRepo: notificationRepo,
+		deliveryRepo:     deliveryRepo,
+		deviceRepo:       deviceRepo,
+		userRepo:         userRepo,
+		orgRepo:          orgRepo,
+	}
+}
+
+// PushContent is what a notification says: a template filled with
+// variables, an explicit title and body, or both with the explicit values
+// taking precedence. Data is merged over the template's data. Content
+// without a title and body is delivered silently.
+type PushContent struct {
+	TemplateCode string            `json:"template_code"`
+	Variables    map[string]string `json:"variables"`
+	Title        string            `json:"title"`
+	Body         string            `json:"body"`
+	Data         map[string]string `json:"data"`
+}
+
+type SendPushRequest struct {
+	Target string `json:"target" binding:"required,oneof=user device organization platform"`
+	// TargetID is the user, device or organization
+	TargetID int64 `json:"target_id"`
+	// Platform is the platform targeted, or narrows the other targets
+	Platform string `json:"platform"`
+	PushContent
+}
+
+type PushTemplateRequest struct {
+	Code     string            `json:"code" binding:"required,max=100"`
+	Name     string            `json:"name" binding:"required,max=255"`
+	Title    string            `json:"title" binding:"max=255"`
+	Body     string            `json:"body"`
+	Data     map[string]string `json:"data"`
+	IsActive *bool             `json:"is_active"`
+}
+
+// PushNotificationSummary is a notification with its deliveries counted by status
+type PushNotificationSummary struct {
+	domain.PushNotification
+	Deliveries map[string]int64 `json:"deliveries"`
+}
+
+// SendToUser notifies every device a user has an active session on
+func (s *PushService) SendToUser(ctx context.Context, userID int64, content *PushContent) (*domain.PushNotification, error) {
+	return s.Send(ctx, &SendPushRequest{Target: domain.PushTargetUser, TargetID: userID, PushContent: *content}, 0)
+}
+
+// SendToDevice notifies one device
+func (s *PushService) SendToDevice(ctx context.Context, deviceID int64, content *PushContent) (*domain.PushNotification, error) {
+	return s.Send(ctx, &SendPushRequest{Target: domain.PushTargetDevice, TargetID: deviceID, PushContent: *content}, 0)
+}
+
+// SendToOrganization notifies the devices of an organization
+func (s *PushService) SendToOrganization(ctx context.Context, orgID int64, content *PushContent) (*domain.PushNotification, error) {
+	return s.Send(ctx, &SendPushRequest{Target: domain.PushTargetOrganization, TargetID: orgID, PushContent: *content}, 0)
+}
+
+// SendToPlatform notifies the devices of a platform
+func (s *PushService) SendToPlatform(ctx context.Context, platform domain.DevicePlatform, content *PushContent) (*domain.PushNotification, error) {
+	return s.Send(ctx, &SendPushRequest{Target: domain.PushTargetPlatform, Platform: string(platform), PushContent: *content}, 0)
+}
+
+// Send records a notification for every active, approved device of the
+// target that has a push token and dispatches it in the background.
+// createdBy is 0 for notifications sent by the system.
+func (s *PushService) Send(ctx context.Context, req *SendPushRequest, createdBy int64) (*domain.PushNotification, error) {
+	if req.Platform != "" && !domain.IsValidDevicePlatform(req.Platform) {
+		return nil, ErrInvalidPushTarget
+	}
+	notification := &domain.PushNotification{
+		TargetType: req.Target,
+		Platform:   domain.DevicePlatform(req.Platform),
+	}
+
+	switch req.Target {
+	case domain.PushTargetPlatform:
+		if req.Platform == "" || req.TargetID != 0 {
+			return nil, ErrInvalidPushTarget
+		}
+	case domain.PushTargetUser, domain.PushTargetDevice, domain.PushTargetOrganization:
+		if req.TargetID <= 0 {
+			return nil, ErrInvalidPushTarget
+		}
+		if err := s.checkTarget(ctx, req.Target, req.TargetID); err != nil {
+			return nil, err
+		}
+		notification.TargetID = &req.TargetID
+	default:
+		return nil, ErrInvalidPushTarget
+	}
+
+	msg, templateID, err := s.render(ctx, &req.PushContent)
+	if err != nil {
+		return nil, err
+	}
+	dataJSON, err := json.Marshal(msg.Data)
+	if err != nil {
+		return nil, err
+	}
+	notification.TemplateID = templateID
+	notification.Title = msg.Title
+	notification.Body = msg.Body
+	notification.DataJSON = string(dataJSON)
+	if createdBy != 0 {
+		notification.CreatedBy = &createdBy
+	}
+
+	devices, err := s.deviceRepo.FindPushTargets(ctx, req.Target, req.TargetID, req.Platform)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	deliveries := make([]domain.PushDelivery, len(devices))
+	for i, device := range devices {
+		deliveries[i] = domain.PushDelivery{
+			DeviceID:      device.ID,
+			Provider:      push.ProviderFor(device.Platform),
+			Token:         device.PushToken,
+			Status:        domain.PushDeliveryPending,
+			NextAttemptAt: &now,
+		}
+	}
+	notification.DeviceCount = len(deliveries)
+
+	if err := s.notificationRepo.CreateWithDeliveries(ctx, notification, deliveries); err != nil {
+		return nil, err
+	}
+
+	if len(deliveries) > 0 {
+		id := notification.ID
+		go func() {
+			if _, err := s.dispatch(context.WithoutCancel(ctx), &id); err != nil {
+				log.Printf("Warning: failed to dispatch push notification %d: %v", id, err)
+			}
+		}()
+	}
+	return notification, nil
+}
+
+func (s *PushService) checkTarget(ctx context.Context, targetType string, id int64) error {
+	switch targetType {
+	case domain.PushTargetUser:
+		if _, err := s.userRepo.FindByID(ctx, id); err != nil {
+			return ErrUserNotFound
+		}
+	case domain.PushTargetDevice:
+		if _, err := s.deviceRepo.FindByID(ctx, id); err != nil {
+			return ErrDeviceNotFound
+		}
+	case domain.PushTargetOrganization:
+		if _, err := s.orgRepo.FindByID(ctx, id); err != nil {
+			return ErrOrganizationNotFound
+		}
+	}
+	return nil
+}
+
+// render resolves the content of a notification, returning the template used
+func (s *PushService) render(ctx context.Context, content *PushContent) (*push.Message, *int64, error) {
+	msg := &push.Message{Data: map[string]string{}}
+	var templateID *int64
+
+	if content.TemplateCode != "" {
+		tmpl, err := s.templateRepo.FindByCode(ctx, content.TemplateCode)
+		if err != nil || tmpl.IsActive == nil || !*tmpl.IsActive {
+			return nil, nil, ErrPushTemplateNotFound
+		}
+		var data map[string]string
+		if err := json.Unmarshal([]byte(tmpl.DataJSON), &data); err != nil {
+			return nil, nil, err
+		}
+
+		if msg.Title, err = renderPushText(tmpl.Title, content.Variables); err != nil {
+			return nil, nil, err
+		}
+		if msg.Body, err = renderPushText(tmpl.Body, content.Variables); err != nil {
+			return nil, nil, err
+		}
+		for key, value := range data {
+			if msg.Data[key], err = renderPushText(value, content.Variables); err != nil {
+				return nil, nil, err
+			}
+		}
+		templateID = &tmpl.ID
+	}
+
+	if content.Title != "" {
+		msg.Title = content.Title
+	}
+	if content.Body != "" {
+		msg.Body = content.Body
+	}
+	for key, value := range content.Data {
+		msg.Data[key] = value
+	}
+
+	if msg.IsSilent() && len(msg.Data) == 0 {
+		return nil, nil, ErrEmptyPushContent
+	}
+	return msg, templateID, nil
+}
+
+func renderPushText(text string, variables map[string]string) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	tmpl, err := template.New("push").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", &InvalidPushTemplateError{err: err}
+	}
+	if variables == nil {
+		variables = map[string]string{}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, variables); err != nil {
+		return "", &InvalidPushTemplateError{err: err}
+	}
+	return buf.String(), nil
+}
+
+// RetryPending fails deliveries out of attempts and sends those due for a
+// retry. It returns the number of deliveries sent or failed.
+func (s *PushService) RetryPending(ctx context.Context) (int64, error) {
+	failed, err := s.deliveryRepo.FailExhausted(ctx, s.config.Push.MaxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	sent, err := s.dispatch(ctx, nil)
+	return failed + sent, err
+}
+
+// dispatch sends the due deliveries, optionally of one notification, until
+// none is left, returning the number sent
+func (s *PushService) dispatch(ctx context.Context, notificationID *int64) (int64, error) {
+	var sent atomic.Int64
+	messages := map[int64]*push.Message{}
+
+	for {
+		deliveries, err := s.deliveryRepo.Claim(ctx, notificationID, s.config.Push.MaxAttempts, pushDeliveryLease, pushDispatchBatch)
+		if err != nil || len(deliveries) == 0 {
+			return sent.Load(), err
+		}
+
+		for _, delivery := range deliveries {
+			if _, ok := messages[delivery.NotificationID]; ok {
+				continue
+			}
+			msg, err := s.notificationMessage(ctx, delivery.NotificationID)
+			if err != nil {
+				return sent.Load(), err
+			}
+			messages[delivery.NotificationID] = msg
+		}
+
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, pushDispatchWorkers)
+		for i := range deliveries {
+			delivery := &deliveries[i]
+			msg := *messages[delivery.NotificationID]
+			msg.Token = delivery.Token
+
+			wg.Add(1)
+			slots <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				if s.deliver(ctx, delivery, &msg) {
+					sent.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func (s *PushService) notificationMessage(ctx context.Context, id int64) (*push.Message, error) {
+	notification, err := s.notificationRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	msg := &push.Message{Title: notification.Title, Body: notification.Body}
+	if err := json.Unmarshal([]byte(notification.DataJSON), &msg.Data); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// deliver sends one delivery and records the outcome, reporting whether it was sent
+func (s *PushService) deliver(ctx context.Context, delivery *domain.PushDelivery, msg *push.Message) bool {
+	err := s.sender.Send(ctx, delivery.Provider, msg)
+
+	var status, lastError string
+	var nextAttemptAt *time.Time
+	var temporary *push.TemporaryError
+	switch {
+	case err == nil:
+		status = domain.PushDeliverySent
+	case errors.Is(err, push.ErrInvalidToken):
+		status = domain.PushDeliveryInvalidToken
+		if clearErr := s.deviceRepo.ClearPushToken(ctx, delivery.DeviceID, delivery.Token); clearErr != nil {
+			log.Printf("Warning: failed to clear push token of device %d: %v", delivery.DeviceID, clearErr)
+		}
+	case errors.As(err, &temporary) && delivery.Attempts < s.config.Push.MaxAttempts:
+		status = domain.PushDeliveryPending
+		next := time.Now().Add(s.retryBackoff(delivery.Attempts, temporary.RetryAfter))
+		nextAttemptAt = &next
+	default:
+		status = domain.PushDeliveryFailed
+	}
+	if err != nil {
+		lastError = err.Error()
+		if len(lastError) > 500 {
+			lastError = lastError[:500]
+		}
+	}
+
+	if err := s.deliveryRepo.Finish(ctx, delivery.ID, status, lastError, nextAttemptAt); err != nil {
+		log.Printf("Warning: failed to record push delivery %d: %v", delivery.ID, err)
+	}
+	return status == domain.PushDeliverySent
+}
+
+// retryBackoff doubles PUSH_RETRY_BACKOFF for every attempt made, waiting at
+// least as long as the provider asked
+func (s *PushService) retryBackoff(attempts int, retryAfter time.Duration) time.Duration {
+	backoff := s.config.Push.RetryBackoff
+	for i := 1; i < attempts && backoff < maxPushRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxPushRetryBackoff {
+		backoff = maxPushRetryBackoff
+	}
+	if retryAfter > backoff {
+		return retryAfter
+	}
+	return backoff
+}
+
+// ListNotifications returns notifications, newest first, with delivery counts
+func (s *PushService) ListNotifications(ctx context.Context, targetType string, page, pageSize int) (*repository.PaginatedResult[PushNotificationSummary], error) {
+	result, err := s.notificationRepo.FindFiltered(ctx, targetType, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summaries, err := s.summarize(ctx, result.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.PaginatedResult[PushNotificationSummary]{
+		Data:       summaries,
+		Page:       result.Page,
+		PageSize:   result.PageSize,
+		Total:      result.Total,
+		TotalPages: result.TotalPages,
+	}, nil
+}
+
+// GetNotification returns a notification with delivery counts
+func (s *PushService) GetNotification(ctx context.Context, id int64) (*PushNotificationSummary, error) {
+	notification, err := s.notificationRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrPushNotificationNotFound
+	}
+	summaries, err := s.summarize(ctx, []domain.PushNotification{*notification})
+	if err != nil {
+		return nil, err
+	}
+	return &summaries[0], nil
+}
+
+func (s *PushService) summarize(ctx context.Context, notifications []domain.PushNotification) ([]PushNotificationSummary, error) {
+	ids := make([]int64, len(notifications))
+	summaries := make([]PushNotificationSummary, len(notifications))
+	index := make(map[int64]int, len(notifications))
+	for i, notification := range notifications {
+		ids[i] = notification.ID
+		summaries[i] = PushNotificationSummary{PushNotification: notification, Deliveries: map[string]int64{}}
+		index[notification.ID] = i
+	}
+
+	counts, err := s.deliveryRepo.CountByStatus(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range counts {
+		summaries[index[count.NotificationID]].Deliveries[count.Status] = count.Count
+	}
+	return summaries, nil
+}
+
+// ListDeliveries returns the deliveries of a notification, optionally with one status
+func (s *PushService) ListDeliveries(ctx context.Context, notificationID int64, status string, page, pageSize int) (*repository.PaginatedResult[domain.PushDelivery], error) {
+	if _, err := s.notificationRepo.FindByID(ctx, notificationID); err != nil {
+		return nil, ErrPushNotificationNotFound
+	}
+	return s.deliveryRepo.FindByNotification(ctx, notificationID, status, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// ListTemplates returns push templates, optionally matching a search term
+func (s *PushService) ListTemplates(ctx context.Context, search string, page, pageSize int) (*repository.PaginatedResult[domain.PushTemplate], error) {
+	return s.templateRepo.FindFiltered(ctx, search, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// GetTemplate returns a push template
+func (s *PushService) GetTemplate(ctx context.Context, id int64) (*domain.PushTemplate, error) {
+	tmpl, err := s.templateRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrPushTemplateNotFound
+	}
+	return tmpl, nil
+}
+
+// CreateTemplate creates a push template
+func (s *PushService) CreateTemplate(ctx context.Context, req *PushTemplateRequest, createdBy int64) (*domain.PushTemplate, error) {
+	if _, err := s.templateRepo.FindByCode(ctx, req.Code); err == nil {
+		return nil, ErrPushTemplateExists
+	} else if !repository.IsNotFound(err) {
+		return nil, err
+	}
+
+	tmpl := &domain.PushTemplate{IsActive: domain.Ptr(true)}
+	if err := applyPushTemplate(tmpl, req); err != nil {
+		return nil, err
+	}
+	tmpl.CreatedBy = &createdBy
+
+	if err := s.templateRepo.Create(ctx, tmpl); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// UpdateTemplate replaces a push template
+func (s *PushService) UpdateTemplate(ctx context.Context, id int64, req *PushTemplateRequest, updatedBy int64) (*domain.PushTemplate, error) {
+	tmpl, err := s.templateRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrPushTemplateNotFound
+	}
+	if req.Code != tmpl.Code {
+		if _, err := s.templateRepo.FindByCode(ctx, req.Code); err == nil {
+			return nil, ErrPushTemplateExists
+		} else if !repository.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	if err := applyPushTemplate(tmpl, req); err != nil {
+		return nil, err
+	}
+	tmpl.UpdatedBy = &updatedBy
+
+	if err := s.templateRepo.Update(ctx, tmpl); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// DeleteTemplate deletes a push template; notifications sent with it are kept
+func (s *PushService) DeleteTemplate(ctx context.Context, id int64) error {
+	if _, err := s.templateRepo.FindByID(ctx, id); err != nil {
+		return ErrPushTemplateNotFound
+	}
+	return s.templateRepo.Delete(ctx, id)
+}
+
+// applyPushTemplate copies a request onto a template, checking every text parses
+func applyPushTemplate(tmpl *domain.PushTemplate, req *PushTemplateRequest) error {
+	texts := []string{req.Title, req.Body}
+	for _, value := range req.Data {
+		texts = append(texts, value)
+	}
+	for _, text := range texts {
+		if _, err := template.New("push").Parse(text); err != nil {
+			return &InvalidPushTemplateError{err: err}
+		}
+	}
+
+	data := req.Data
+	if data == nil {
+		data = map[string]string{}
+	}
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	tmpl.Code = req.Code
+	tmpl.Name = req.Name
+	tmpl.Title = req.Title
+	tmpl.Body = req.Body
+	tmpl.DataJSON = string(dataJSON)
+	if req.IsActive != nil {
+		tmpl.IsActive = req.IsActive
+	}
+	return nil
+}
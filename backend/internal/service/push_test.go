@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gebase/internal/config"
+	"gebase/internal/domain"
+	"gebase/internal/push"
+	"gebase/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+type pushTestEnv struct {
+	db       *gorm.DB
+	service  *PushService
+	provider *push.FakeProvider
+	device   *domain.Device
+}
+
+// testPushRetryBackoff is long enough for a dispatch to end before the
+// delivery it failed is due again
+const testPushRetryBackoff = 20 * time.Millisecond
+
+// newPushTestEnv sends through a FakeProvider to one Android device with
+// push token "token-1", trying deliveries maxAttempts times
+func newPushTestEnv(t *testing.T, maxAttempts int) *pushTestEnv {
+	t.Helper()
+
+	db := newTestDB(t, &domain.Device{}, &domain.PushNotification{}, &domain.PushDelivery{}, &domain.PushTemplate{})
+	// SQLite has no row locks; it runs one statement at a time anyway
+	err := db.Callback().Row().Before("gorm:row").Register("test:strip_row_locks", func(tx *gorm.DB) {
+		sql := tx.Statement.SQL.String()
+		if strings.Contains(sql, "FOR UPDATE SKIP LOCKED") {
+			tx.Statement.SQL.Reset()
+			tx.Statement.SQL.WriteString(strings.Replace(sql, "FOR UPDATE SKIP LOCKED", "", 1))
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	device := &domain.Device{DeviceUID: "device-1", Platform: domain.PlatformAndroid, PushToken: "token-1", IsActive: domain.Ptr(true)}
+	if err := db.Create(device).Error; err != nil {
+		t.Fatalf("create device: %v", err)
+	}
+
+	provider := push.NewFakeProvider()
+	cfg := &config.Config{Push: config.PushConfig{MaxAttempts: maxAttempts, RetryBackoff: testPushRetryBackoff}}
+	return &pushTestEnv{
+		db:       db,
+		provider: provider,
+		device:   device,
+		service: NewPushService(cfg,
+			push.NewSenderWithProviders(map[string]push.Provider{push.ProviderFCM: provider}),
+			repository.NewPushTemplateRepository(db),
+			repository.NewPushNotificationRepository(db),
+			repository.NewPushDeliveryRepository(db),
+			repository.NewDeviceRepository(db),
+			repository.NewUserRepository(db),
+			repository.NewOrganizationRepository(db),
+		),
+	}
+}
+
+// send notifies the device and waits for the first delivery attempt to end
+func (e *pushTestEnv) send(t *testing.T) *domain.PushNotification {
+	t.Helper()
+
+	notification, err := e.service.SendToDevice(context.Background(), e.device.ID, &PushContent{Title: "Hello", Body: "World"})
+	if err != nil {
+		t.Fatalf("SendToDevice: %v", err)
+	}
+	if notification.DeviceCount != 1 {
+		t.Fatalf("DeviceCount = %d, want 1", notification.DeviceCount)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		delivery := e.delivery(t, notification.ID)
+		if delivery.Attempts == 1 && (delivery.Status != domain.PushDeliveryPending || delivery.LastError != "") {
+			return notification
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery was not attempted: %+v", delivery)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (e *pushTestEnv) delivery(t *testing.T, notificationID int64) domain.PushDelivery {
+	t.Helper()
+
+	var delivery domain.PushDelivery
+	if err := e.db.Where("notification_id = ?", notificationID).First(&delivery).Error; err != nil {
+		t.Fatalf("find delivery: %v", err)
+	}
+	return delivery
+}
+
+// retry runs the push_retry job once deliveries failed once are due
+func (e *pushTestEnv) retry(t *testing.T) int64 {
+	t.Helper()
+
+	time.Sleep(2 * testPushRetryBackoff)
+	processed, err := e.service.RetryPending(context.Background())
+	if err != nil {
+		t.Fatalf("RetryPending: %v", err)
+	}
+	return processed
+}
+
+func TestPushDeliverySent(t *testing.T) {
+	env := newPushTestEnv(t, 3)
+
+	notification := env.send(t)
+	delivery := env.delivery(t, notification.ID)
+	if delivery.Status != domain.PushDeliverySent || delivery.SentAt == nil {
+		t.Fatalf("delivery = %+v, want sent", delivery)
+	}
+	sent := env.provider.Sent()
+	if len(sent) != 1 || sent[0].Token != "token-1" || sent[0].Title != "Hello" || sent[0].Body != "World" {
+		t.Fatalf("provider received %+v", sent)
+	}
+}
+
+func TestPushDeliveryRetriesTemporaryFailures(t *testing.T) {
+	env := newPushTestEnv(t, 3)
+	env.provider.Fail("token-1", &push.TemporaryError{Err: errors.New("fcm responded 503")})
+
+	notification := env.send(t)
+	delivery := env.delivery(t, notification.ID)
+	if delivery.Status != domain.PushDeliveryPending || delivery.NextAttemptAt == nil || delivery.LastError != "fcm responded 503" {
+		t.Fatalf("delivery after a temporary failure = %+v, want pending", delivery)
+	}
+
+	if processed := env.retry(t); processed != 1 {
+		t.Fatalf("RetryPending processed %d deliveries, want 1", processed)
+	}
+	delivery = env.delivery(t, notification.ID)
+	if delivery.Status != domain.PushDeliverySent || delivery.Attempts != 2 {
+		t.Fatalf("delivery after the retry = %+v, want sent on attempt 2", delivery)
+	}
+	if sent := env.provider.Sent(); len(sent) != 1 {
+		t.Fatalf("provider received %d messages, want 1", len(sent))
+	}
+}
+
+func TestPushDeliveryFailsOutOfAttempts(t *testing.T) {
+	env := newPushTestEnv(t, 2)
+	unavailable := &push.TemporaryError{Err: errors.New("fcm responded 503")}
+	env.provider.Fail("token-1", unavailable, unavailable, unavailable)
+
+	notification := env.send(t)
+	env.retry(t)
+	delivery := env.delivery(t, notification.ID)
+	if delivery.Status != domain.PushDeliveryFailed || delivery.Attempts != 2 {
+		t.Fatalf("delivery = %+v, want failed after 2 attempts", delivery)
+	}
+
+	// Failed deliveries are not retried
+	if processed := env.retry(t); processed != 0 {
+		t.Fatalf("RetryPending processed %d deliveries, want 0", processed)
+	}
+}
+
+func TestPushDeliveryDoesNotRetryPermanentFailures(t *testing.T) {
+	env := newPushTestEnv(t, 3)
+	env.provider.Fail("token-1", errors.New("fcm responded 400: INVALID_ARGUMENT"))
+
+	notification := env.send(t)
+	delivery := env.delivery(t, notification.ID)
+	if delivery.Status != domain.PushDeliveryFailed || delivery.NextAttemptAt != nil {
+		t.Fatalf("delivery = %+v, want failed", delivery)
+	}
+}
+
+func TestPushDeliveryClearsInvalidToken(t *testing.T) {
+	env := newPushTestEnv(t, 3)
+	env.provider.Invalidate("token-1")
+
+	notification := env.send(t)
+	delivery := env.delivery(t, notification.ID)
+	if delivery.Status != domain.PushDeliveryInvalidToken {
+		t.Fatalf("delivery = %+v, want invalid_token", delivery)
+	}
+
+	var device domain.Device
+	if err := env.db.First(&device, env.device.ID).Error; err != nil {
+		t.Fatalf("find device: %v", err)
+	}
+	if device.PushToken != "" {
+		t.Fatalf("push token %q was kept", device.PushToken)
+	}
+
+	// The device is no longer a target until it registers a new token
+	_, err := env.service.SendToDevice(context.Background(), env.device.ID, &PushContent{Title: "Hello"})
+	if err != nil {
+		t.Fatalf("SendToDevice: %v", err)
+	}
+	var deliveries int64
+	env.db.Model(&domain.PushDelivery{}).Count(&deliveries)
+	if deliveries != 1 {
+		t.Fatalf("%d deliveries exist, want 1", deliveries)
+	}
+}
+
+func TestPushDeliveryKeepsReplacedToken(t *testing.T) {
+	env := newPushTestEnv(t, 3)
+	env.provider.Invalidate("token-1")
+
+	// A delivery to the old token is due after the device registered a new one
+	now := time.Now()
+	notification := &domain.PushNotification{TargetType: domain.PushTargetDevice, Title: "Hello", DataJSON: "{}"}
+	err := repository.NewPushNotificationRepository(env.db).CreateWithDeliveries(context.Background(), notification, []domain.PushDelivery{{
+		DeviceID:      env.device.ID,
+		Provider:      push.ProviderFCM,
+		Token:         "token-1",
+		Status:        domain.PushDeliveryPending,
+		NextAttemptAt: &now,
+	}})
+	if err != nil {
+		t.Fatalf("create notification: %v", err)
+	}
+	if err := env.db.Model(env.device).Update("push_token", "token-2").Error; err != nil {
+		t.Fatalf("update push token: %v", err)
+	}
+
+	env.retry(t)
+	if delivery := env.delivery(t, notification.ID); delivery.Status != domain.PushDeliveryInvalidToken {
+		t.Fatalf("delivery = %+v, want invalid_token", delivery)
+	}
+	var device domain.Device
+	if err := env.db.First(&device, env.device.ID).Error; err != nil {
+		t.Fatalf("find device: %v", err)
+	}
+	if device.PushToken != "token-2" {
+		t.Fatalf("push token = %q, want the new token-2", device.PushToken)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 
+	"gebase/internal/auth"
 	"gebase/internal/domain"
 	"gebase/internal/repository"
 )
@@ -17,17 +18,23 @@ type RoleService struct {
 	roleRepo           *repository.RoleRepository
 	rolePermissionRepo *repository.RolePermissionRepository
 	roleMenuRepo       *repository.RoleMenuRepository
+	revocationService  *auth.RevocationService
+	permissionCache    *PermissionCache
 }
 
 func NewRoleService(
 	roleRepo *repository.RoleRepository,
 	rolePermissionRepo *repository.RolePermissionRepository,
 	roleMenuRepo *repository.RoleMenuRepository,
+	revocationService *auth.RevocationService,
+	permissionCache *PermissionCache,
 ) *RoleService {
 	return &RoleService{
 		roleRepo:           roleRepo,
 		rolePermissionRepo: rolePermissionRepo,
 		roleMenuRepo:       roleMenuRepo,
+		revocationService:  revocationService,
+		permissionCache:    permissionCache,
 	}
 }
 
@@ -155,12 +162,32 @@ func (s *RoleService) DeleteRole(ctx context.Context, id int, deletedBy int64) e
 		return err
 	}
 
-	return s.roleRepo.Delete(ctx, id)
+	if err := s.roleRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	if err := s.permissionCache.InvalidateAll(ctx); err != nil {
+		return err
+	}
+
+	return s.revocationService.RevokeSystemTokensForRole(ctx, id, role.SystemID, auth.RevokeReasonRoleDeleted)
 }
 
-// AssignPermissions assigns permissions to a role
+// AssignPermissions assigns permissions to a role, drops cached permissions and
+// revokes system tokens of its holders
 func (s *RoleService) AssignPermissions(ctx context.Context, roleID int, permissionIDs []int, assignedBy int64) error {
-	return s.rolePermissionRepo.AssignPermissions(ctx, roleID, permissionIDs, assignedBy)
+	role, err := s.roleRepo.FindByID(ctx, roleID)
+	if err != nil {
+		return ErrRoleNotFound
+	}
+
+	if err := s.rolePermissionRepo.AssignPermissions(ctx, roleID, permissionIDs, assignedBy); err != nil {
+		return err
+	}
+	if err := s.permissionCache.InvalidateAll(ctx); err != nil {
+		return err
+	}
+
+	return s.revocationService.RevokeSystemTokensForRole(ctx, roleID, role.SystemID, auth.RevokeReasonRoleChanged)
 }
 
 // AssignMenus assigns menus to a role
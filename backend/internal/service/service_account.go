@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+)
+
+var ErrServiceAccountNotFound = errors.New("service account not found")
+
+// ServiceAccountService manages non-human users for integrations. Service
+// accounts get roles like any user but cannot log in; they authenticate with
+// personal access tokens issued by administrators.
+type ServiceAccountService struct {
+	userRepo           *repository.UserRepository
+	accessTokenService *AccessTokenService
+}
+
+func NewServiceAccountService(
+	userRepo *repository.UserRepository,
+	accessTokenService *AccessTokenService,
+) *ServiceAccountService {
+	return &ServiceAccountService{
+		userRepo:           userRepo,
+		accessTokenService: accessTokenService,
+	}
+}
+
+type CreateServiceAccountRequest struct {
+	Name string `json:"name" binding:"required,max=150"`
+	// Email is the contact address of the team owning the account
+	Email          string `json:"email" binding:"required,email"`
+	OrganizationID *int64 `json:"organization_id"`
+}
+
+type UpdateServiceAccountRequest struct {
+	Name     string `json:"name" binding:"max=150"`
+	IsActive *bool  `json:"is_active"`
+}
+
+// List returns service accounts, optionally of one organization
+func (s *ServiceAccountService) List(ctx context.Context, orgID *int64, page, pageSize int) (*repository.PaginatedResult[domain.User], error) {
+	return s.userRepo.FindServiceAccounts(ctx, orgID, repository.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// Get returns a service account
+func (s *ServiceAccountService) Get(ctx context.Context, id int64) (*domain.User, error) {
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil || !user.IsServiceAccount() {
+		return nil, ErrServiceAccountNotFound
+	}
+	return user, nil
+}
+
+// Create registers a service account. It has no password and a generated
+// registration number, as it is not a person.
+func (s *ServiceAccountService) Create(ctx context.Context, req *CreateServiceAccountRequest, createdBy int64) (*domain.User, error) {
+	email := strings.ToLower(req.Email)
+	if existing, _ := s.userRepo.FindByEmail(ctx, email); existing != nil {
+		return nil, ErrEmailAlreadyExists
+	}
+
+	regNo, err := serviceAccountRegNo()
+	if err != nil {
+		return nil, err
+	}
+
+	user := &domain.User{
+		RegNo:              regNo,
+		FirstName:          req.Name,
+		Email:              email,
+		OrganizationID:     req.OrganizationID,
+		LanguageCode:       "mn",
+		IsActive:           domain.Ptr(true),
+		MustChangePassword: domain.Ptr(false),
+		ServiceAccount:     domain.Ptr(true),
+	}
+	user.CreatedBy = &createdBy
+
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Update renames or (de)activates a service account. Tokens of an inactive
+// account are refused.
+func (s *ServiceAccountService) Update(ctx context.Context, id int64, req *UpdateServiceAccountRequest, updatedBy int64) (*domain.User, error) {
+	user, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		user.FirstName = req.Name
+	}
+	if req.IsActive != nil {
+		user.IsActive = req.IsActive
+	}
+	user.UpdatedBy = &updatedBy
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Delete removes a service account and revokes its tokens
+func (s *ServiceAccountService) Delete(ctx context.Context, id int64, deletedBy int64) error {
+	user, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.accessTokenService.RevokeAll(ctx, id, deletedBy); err != nil {
+		return err
+	}
+
+	user.DeletedBy = &deletedBy
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	return s.userRepo.Delete(ctx, id)
+}
+
+// ListTokens returns the tokens of a service account
+func (s *ServiceAccountService) ListTokens(ctx context.Context, id int64) ([]domain.PersonalAccessToken, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.accessTokenService.List(ctx, id)
+}
+
+// CreateToken issues a token for a service account within the permissions
+// of its roles
+func (s *ServiceAccountService) CreateToken(ctx context.Context, id int64, req *CreateAccessTokenRequest, createdBy int64, authenticatedAt *time.Time) (*AccessTokenCredentials, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.accessTokenService.Create(ctx, id, req, createdBy, authenticatedAt)
+}
+
+// RevokeToken revokes a token of a service account
+func (s *ServiceAccountService) RevokeToken(ctx context.Context, id, tokenID int64, revokedBy int64) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.accessTokenService.Revoke(ctx, id, tokenID, revokedBy)
+}
+
+// serviceAccountRegNo generates a unique-enough registration number outside
+// the national format, which is two Cyrillic letters and eight digits
+func serviceAccountRegNo() (string, error) {
+	raw := make([]byte, 4)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return "SA" + strings.ToUpper(hex.EncodeToString(raw)), nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+)
+
+var (
+	ErrSessionLimitPolicyNotFound = errors.New("session limit policy not found")
+	ErrInvalidSessionLimitPolicy  = errors.New("platform must be a device platform or platform class")
+)
+
+// SessionLimitService manages the concurrent session limit policies enforced
+// by auth.SessionService.CreateSession
+type SessionLimitService struct {
+	limitRepo *repository.SessionLimitPolicyRepository
+	orgRepo   *repository.OrganizationRepository
+	roleRepo  *repository.RoleRepository
+}
+
+func NewSessionLimitService(
+	limitRepo *repository.SessionLimitPolicyRepository,
+	orgRepo *repository.OrganizationRepository,
+	roleRepo *repository.RoleRepository,
+) *SessionLimitService {
+	return &SessionLimitService{
+		limitRepo: limitRepo,
+		orgRepo:   orgRepo,
+		roleRepo:  roleRepo,
+	}
+}
+
+type SessionLimitPolicyRequest struct {
+	OrganizationID *int64 `json:"organization_id"`
+	RoleID         *int   `json:"role_id"`
+	Platform       string `json:"platform"`
+	Scope          string `json:"scope" binding:"omitempty,oneof=user device"`
+	MaxSessions    int    `json:"max_sessions" binding:"required,min=1"`
+	Action         string `json:"action" binding:"omitempty,oneof=reject evict_oldest"`
+	IsActive       *bool  `json:"is_active"`
+}
+
+// ListPolicies returns session limit policies, optionally filtered
+func (s *SessionLimitService) ListPolicies(ctx context.Context, orgID *int64, roleID *int) ([]domain.SessionLimitPolicy, error) {
+	return s.limitRepo.FindFiltered(ctx, orgID, roleID)
+}
+
+// CreatePolicy creates a session limit policy
+func (s *SessionLimitService) CreatePolicy(ctx context.Context, req *SessionLimitPolicyRequest, createdBy int64) (*domain.SessionLimitPolicy, error) {
+	policy := &domain.SessionLimitPolicy{}
+	if err := s.apply(ctx, policy, req); err != nil {
+		return nil, err
+	}
+	policy.CreatedBy = &createdBy
+
+	if err := s.limitRepo.Create(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// UpdatePolicy replaces a session limit policy
+func (s *SessionLimitService) UpdatePolicy(ctx context.Context, id int64, req *SessionLimitPolicyRequest, updatedBy int64) (*domain.SessionLimitPolicy, error) {
+	policy, err := s.limitRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrSessionLimitPolicyNotFound
+	}
+	if err := s.apply(ctx, policy, req); err != nil {
+		return nil, err
+	}
+	policy.UpdatedBy = &updatedBy
+
+	if err := s.limitRepo.Update(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// DeletePolicy deletes a session limit policy
+func (s *SessionLimitService) DeletePolicy(ctx context.Context, id int64) error {
+	if _, err := s.limitRepo.FindByID(ctx, id); err != nil {
+		return ErrSessionLimitPolicyNotFound
+	}
+	return s.limitRepo.Delete(ctx, id)
+}
+
+func (s *SessionLimitService) apply(ctx context.Context, policy *domain.SessionLimitPolicy, req *SessionLimitPolicyRequest) error {
+	if !domain.IsValidSessionPolicyPlatform(req.Platform) {
+		return ErrInvalidSessionLimitPolicy
+	}
+	if req.OrganizationID != nil {
+		if _, err := s.orgRepo.FindByID(ctx, *req.OrganizationID); err != nil {
+			return ErrOrganizationNotFound
+		}
+	}
+	if req.RoleID != nil {
+		if _, err := s.roleRepo.FindByID(ctx, *req.RoleID); err != nil {
+			return ErrRoleNotFound
+		}
+	}
+
+	policy.OrganizationID = req.OrganizationID
+	policy.RoleID = req.RoleID
+	policy.Platform = req.Platform
+	policy.Scope = req.Scope
+	if policy.Scope == "" {
+		policy.Scope = domain.SessionLimitScopeUser
+	}
+	policy.MaxSessions = req.MaxSessions
+	policy.Action = req.Action
+	if policy.Action == "" {
+		policy.Action = domain.SessionLimitEvictOldest
+	}
+	policy.IsActive = req.IsActive
+	if policy.IsActive == nil {
+		policy.IsActive = domain.Ptr(true)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"gebase/internal/domain"
+	"gebase/internal/repository"
+)
+
+var (
+	ErrSessionTimeoutPolicyNotFound = errors.New("session timeout policy not found")
+	ErrInvalidSessionTimeoutPolicy  = errors.New("platform must be a device platform or platform class")
+)
+
+// SessionTimeoutService manages the idle timeout and sliding expiry policies
+// enforced by auth.SessionService.ValidateSession
+type SessionTimeoutService struct {
+	timeoutRepo *repository.SessionTimeoutPolicyRepository
+	orgRepo     *repository.OrganizationRepository
+}
+
+func NewSessionTimeoutService(
+	timeoutRepo *repository.SessionTimeoutPolicyRepository,
+	orgRepo *repository.OrganizationRepository,
+) *SessionTimeoutService {
+	return &SessionTimeoutService{
+		timeoutRepo: timeoutRepo,
+		orgRepo:     orgRepo,
+	}
+}
+
+type SessionTimeoutPolicyRequest struct {
+	OrganizationID     *int64 `json:"organization_id"`
+	Platform           string `json:"platform"`
+	IdleTimeoutMinutes int    `json:"idle_timeout_minutes" binding:"min=0"`
+	SlidingExpiry      *bool  `json:"sliding_expiry"`
+	IsActive           *bool  `json:"is_active"`
+}
+
+// ListPolicies returns session timeout policies, optionally for one organization
+func (s *SessionTimeoutService) ListPolicies(ctx context.Context, orgID *int64) ([]domain.SessionTimeoutPolicy, error) {
+	return s.timeoutRepo.FindFiltered(ctx, orgID)
+}
+
+// CreatePolicy creates a session timeout policy
+func (s *SessionTimeoutService) CreatePolicy(ctx context.Context, req *SessionTimeoutPolicyRequest, createdBy int64) (*domain.SessionTimeoutPolicy, error) {
+	policy := &domain.SessionTimeoutPolicy{}
+	if err := s.apply(ctx, policy, req); err != nil {
+		return nil, err
+	}
+	policy.CreatedBy = &createdBy
+
+	if err := s.timeoutRepo.Create(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// UpdatePolicy replaces a session timeout policy
+func (s *SessionTimeoutService) UpdatePolicy(ctx context.Context, id int64, req *SessionTimeoutPolicyRequest, updatedBy int64) (*domain.SessionTimeoutPolicy, error) {
+	policy, err := s.timeoutRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, ErrSessionTimeoutPolicyNotFound
+	}
+	if err := s.apply(ctx, policy, req); err != nil {
+		return nil, err
+	}
+	policy.UpdatedBy = &updatedBy
+
+	if err := s.timeoutRepo.Update(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// DeletePolicy deletes a session timeout policy
+func (s *SessionTimeoutService) DeletePolicy(ctx context.Context, id int64) error {
+	if _, err := s.timeoutRepo.FindByID(ctx, id); err != nil {
+		return ErrSessionTimeoutPolicyNotFound
+	}
+	return s.timeoutRepo.Delete(ctx, id)
+}
+
+func (s *SessionTimeoutService) apply(ctx context.Context, policy *domain.SessionTimeoutPolicy, req *SessionTimeoutPolicyRequest) error {
+	if !domain.IsValidSessionPolicyPlatform(req.Platform) {
+		return ErrInvalidSessionTimeoutPolicy
+	}
+	if req.OrganizationID != nil {
+		if _, err := s.orgRepo.FindByID(ctx, *req.OrganizationID); err != nil {
+			return ErrOrganizationNotFound
+		}
+	}
+
+	policy.OrganizationID = req.OrganizationID
+	policy.Platform = req.Platform
+	policy.IdleTimeoutMinutes = req.IdleTimeoutMinutes
+	policy.SlidingExpiry = req.SlidingExpiry
+	policy.IsActive = req.IsActive
+	if policy.IsActive == nil {
+		policy.IsActive = domain.Ptr(true)
+	}
+	return nil
+}
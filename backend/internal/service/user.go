@@ -3,9 +3,13 @@ package service
 import (
 	"context"
 	"errors"
+	"time"
 
+	"gebase/internal/auth"
 	"gebase/internal/domain"
 	"gebase/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -14,20 +18,32 @@ var (
 )
 
 type UserService struct {
-	userRepo     *repository.UserRepository
-	roleRepo     *repository.UserSystemRoleRepository
-	sessionRepo  *repository.SessionRepository
+	userRepo          *repository.UserRepository
+	roleRepo          *repository.UserSystemRoleRepository
+	sessionRepo       *repository.SessionRepository
+	revocationService *auth.RevocationService
+	passwordPolicy    *PasswordPolicyService
+	authenticators    *Authenticators
+	permissionCache   *PermissionCache
 }
 
 func NewUserService(
 	userRepo *repository.UserRepository,
 	roleRepo *repository.UserSystemRoleRepository,
 	sessionRepo *repository.SessionRepository,
+	revocationService *auth.RevocationService,
+	passwordPolicy *PasswordPolicyService,
+	authenticators *Authenticators,
+	permissionCache *PermissionCache,
 ) *UserService {
 	return &UserService{
-		userRepo:    userRepo,
-		roleRepo:    roleRepo,
-		sessionRepo: sessionRepo,
+		userRepo:          userRepo,
+		roleRepo:          roleRepo,
+		sessionRepo:       sessionRepo,
+		revocationService: revocationService,
+		passwordPolicy:    passwordPolicy,
+		authenticators:    authenticators,
+		permissionCache:   permissionCache,
 	}
 }
 
@@ -40,11 +56,16 @@ type CreateUserRequest struct {
 	BirthDate      string `json:"birth_date"`
 	PhoneNo        string `json:"phone_no"`
 	Email          string `json:"email" binding:"required,email"`
-	Password       string `json:"password" binding:"required,min=6"`
+	Password       string `json:"password" binding:"required"`
 	OrganizationID *int64 `json:"organization_id"`
 	LanguageCode   string `json:"language_code"`
 }
 
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
 type UpdateUserRequest struct {
 	FamilyName     string  `json:"family_name"`
 	LastName       string  `json:"last_name"`
@@ -107,7 +128,11 @@ func (s *UserService) CreateUser(ctx context.Context, req *CreateUserRequest, cr
 		return nil, ErrRegNoAlreadyExists
 	}
 
-	// Hash password
+	// Validate and hash password
+	if err := s.passwordPolicy.Validate(ctx, &domain.User{OrganizationID: req.OrganizationID}, req.Password); err != nil {
+		return nil, err
+	}
+
 	passwordHash, err := HashPassword(req.Password)
 	if err != nil {
 		return nil, err
@@ -119,18 +144,19 @@ func (s *UserService) CreateUser(ctx context.Context, req *CreateUserRequest, cr
 	}
 
 	user := &domain.User{
-		RegNo:          req.RegNo,
-		FamilyName:     req.FamilyName,
-		LastName:       req.LastName,
-		FirstName:      req.FirstName,
-		Gender:         req.Gender,
-		BirthDate:      req.BirthDate,
-		PhoneNo:        req.PhoneNo,
-		Email:          req.Email,
-		PasswordHash:   passwordHash,
-		OrganizationID: req.OrganizationID,
-		LanguageCode:   languageCode,
-		IsActive:       domain.Ptr(true),
+		RegNo:             req.RegNo,
+		FamilyName:        req.FamilyName,
+		LastName:          req.LastName,
+		FirstName:         req.FirstName,
+		Gender:            req.Gender,
+		BirthDate:         req.BirthDate,
+		PhoneNo:           req.PhoneNo,
+		Email:             req.Email,
+		PasswordHash:      passwordHash,
+		PasswordChangedAt: domain.Ptr(time.Now()),
+		OrganizationID:    req.OrganizationID,
+		LanguageCode:      languageCode,
+		IsActive:          domain.Ptr(true),
 	}
 	user.CreatedBy = &createdBy
 
@@ -208,27 +234,25 @@ func (s *UserService) GetUserRoles(ctx context.Context, userID int64) ([]domain.
 	return s.roleRepo.FindByUserID(ctx, userID)
 }
 
-// AssignUserRoles assigns roles to user
+// AssignUserRoles assigns roles to user and revokes system tokens carrying the old roles
 func (s *UserService) AssignUserRoles(ctx context.Context, userID int64, systemID *int, roleIDs []int, orgID *int64, assignedBy int64) error {
-	return s.roleRepo.AssignRoles(ctx, userID, systemID, roleIDs, orgID, assignedBy)
+	if err := s.roleRepo.AssignRoles(ctx, userID, systemID, roleIDs, orgID, assignedBy); err != nil {
+		return err
+	}
+	if err := s.permissionCache.InvalidateUser(ctx, userID); err != nil {
+		return err
+	}
+	return s.revocationService.RevokeSystemTokensForUser(ctx, userID, systemID, auth.RevokeReasonRolesChanged)
 }
 
-// ResetPassword resets user password
+// ResetPassword resets user password and requires the user to change it on next login
 func (s *UserService) ResetPassword(ctx context.Context, userID int64, newPassword string, updatedBy int64) error {
 	user, err := s.userRepo.FindByID(ctx, userID)
 	if err != nil {
 		return ErrUserNotFound
 	}
 
-	passwordHash, err := HashPassword(newPassword)
-	if err != nil {
-		return err
-	}
-
-	user.PasswordHash = passwordHash
-	user.UpdatedBy = &updatedBy
-
-	return s.userRepo.Update(ctx, user)
+	return s.setPassword(ctx, user, newPassword, true, updatedBy)
 }
 
 // ChangePassword changes user password (requires old password)
@@ -238,27 +262,68 @@ func (s *UserService) ChangePassword(ctx context.Context, userID int64, oldPassw
 		return ErrUserNotFound
 	}
 
+	if err := s.requireLocalPassword(ctx, user); err != nil {
+		return err
+	}
+
 	// Verify old password
 	if err := verifyPassword(user.PasswordHash, oldPassword); err != nil {
 		return ErrInvalidCredentials
 	}
 
+	return s.setPassword(ctx, user, newPassword, false, user.ID)
+}
+
+// GetPasswordPolicy returns the password policy that applies to a user
+func (s *UserService) GetPasswordPolicy(ctx context.Context, userID int64) (*PasswordPolicy, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return s.passwordPolicy.GetPolicy(ctx, user.OrganizationID)
+}
+
+// setPassword validates a new password against the policy, keeps the previous
+// hash in the history and stores the new one
+func (s *UserService) setPassword(ctx context.Context, user *domain.User, newPassword string, mustChange bool, updatedBy int64) error {
+	if err := s.requireLocalPassword(ctx, user); err != nil {
+		return err
+	}
+
+	if err := s.passwordPolicy.Validate(ctx, user, newPassword); err != nil {
+		return err
+	}
+
 	passwordHash, err := HashPassword(newPassword)
 	if err != nil {
 		return err
 	}
 
+	if err := s.passwordPolicy.RecordChange(ctx, user, user.PasswordHash); err != nil {
+		return err
+	}
+
 	user.PasswordHash = passwordHash
-	user.UpdatedBy = &user.ID
+	user.PasswordChangedAt = domain.Ptr(time.Now())
+	user.MustChangePassword = domain.Ptr(mustChange)
+	user.UpdatedBy = &updatedBy
 
 	return s.userRepo.Update(ctx, user)
 }
 
-func verifyPassword(hash, password string) error {
-	return bcryptCompare(hash, password)
+// requireLocalPassword rejects password changes for users whose organization
+// authenticates against a directory
+func (s *UserService) requireLocalPassword(ctx context.Context, user *domain.User) error {
+	local, err := s.authenticators.IsLocal(ctx, user)
+	if err != nil {
+		return err
+	}
+	if !local {
+		return ErrPasswordManagedByLDAP
+	}
+	return nil
 }
 
-func bcryptCompare(hash, password string) error {
-	// Use golang.org/x/crypto/bcrypt
-	return nil // Placeholder - will use bcrypt.CompareHashAndPassword
+func verifyPassword(hash, password string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }